@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/jobs"
+)
+
+// acquireBackupJob registers the backup in the job registry of this host and
+// waits until other backups of the same targets to the same repository have
+// finished. The returned function removes the backup from the registry.
+func acquireBackupJob(ctx context.Context, opts BackupOptions, gopts GlobalOptions, targets []string) (func(), error) {
+	repo, err := ReadRepo(gopts)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if abs, err := filepath.Abs(target); err == nil {
+			target = abs
+		}
+		paths = append(paths, target)
+	}
+
+	registry, err := openJobRegistry(gopts)
+	if err != nil {
+		Warnf("unable to open the job registry, not checking for concurrent backups: %v\n", err)
+		return func() {}, nil
+	}
+
+	if opts.QueueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.QueueTimeout)
+		defer cancel()
+	}
+
+	job, err := registry.Acquire(ctx, jobs.Job{Repository: repo, Paths: paths}, func(running jobs.Job) {
+		if gopts.JSON {
+			return
+		}
+		if running.PID != 0 {
+			Verbosef("waiting for backup of %v by process %d to finish\n", strings.Join(running.Paths, ", "), running.PID)
+		} else {
+			Verbosef("waiting for another backup of the same paths to finish\n")
+		}
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, errors.Fatalf("another backup of the same paths is still running after waiting for %v", opts.QueueTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := job.Release(); err != nil {
+			Warnf("unable to remove backup from the job registry: %v\n", err)
+		}
+	}, nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/catalog"
+	"github.com/restic/restic/internal/restic"
+)
+
+// loadCatalog returns the catalog of the snapshot, or nil if the snapshot has
+// no usable catalog. If the catalog cannot be loaded, a warning is printed and
+// the caller should walk the trees of the snapshot instead.
+func loadCatalog(ctx context.Context, repo restic.BlobLoader, sn *restic.Snapshot) *catalog.Catalog {
+	c, err := catalog.Load(ctx, repo, sn)
+	if err != nil {
+		Warnf("unable to use the catalog of snapshot %s: %v\n", sn.ID().Str(), err)
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+)
+
+// chunkMapsCacheFile is the name of the file in the local cache which contains
+// the chunk maps recorded by backup --chunk-map-min-size.
+const chunkMapsCacheFile = "chunkmaps.json"
+
+// loadChunkMaps returns the chunk maps stored in the local cache. If they
+// cannot be loaded, all files are read completely during the next backup.
+func loadChunkMaps(repo *repository.Repository) *archiver.ChunkMaps {
+	var files map[string]*archiver.ChunkMap
+	err := repo.LoadCacheJSON(chunkMapsCacheFile, &files)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debug.Log("unable to load chunk maps: %v", err)
+		}
+		files = nil
+	}
+	return archiver.NewChunkMaps(files)
+}
+
+// saveChunkMaps stores the chunk maps in the local cache.
+func saveChunkMaps(repo *repository.Repository, maps *archiver.ChunkMaps) {
+	err := repo.SaveCacheJSON(chunkMapsCacheFile, maps.Files())
+	if err != nil {
+		Warnf("unable to save the chunk maps: %v\n", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"github.com/restic/restic/internal/debug"
@@ -19,9 +20,44 @@ func createGlobalContext() context.Context {
 	return ctx
 }
 
+var interruptHandler struct {
+	sync.Mutex
+	fn func(os.Signal)
+}
+
+// setInterruptHandler registers fn, which is called for the first SIGINT or
+// SIGTERM instead of cancelling the global context. Further signals cancel the
+// context as usual. The returned function removes the handler.
+func setInterruptHandler(fn func(os.Signal)) (remove func()) {
+	interruptHandler.Lock()
+	interruptHandler.fn = fn
+	interruptHandler.Unlock()
+
+	return func() {
+		interruptHandler.Lock()
+		interruptHandler.fn = nil
+		interruptHandler.Unlock()
+	}
+}
+
+// takeInterruptHandler returns the registered interrupt handler and removes it.
+func takeInterruptHandler() func(os.Signal) {
+	interruptHandler.Lock()
+	defer interruptHandler.Unlock()
+
+	fn := interruptHandler.fn
+	interruptHandler.fn = nil
+	return fn
+}
+
 // cleanupHandler handles the SIGINT and SIGTERM signals.
 func cleanupHandler(c <-chan os.Signal, cancel context.CancelFunc) {
 	s := <-c
+	if fn := takeInterruptHandler(); fn != nil {
+		debug.Log("signal %v received, calling interrupt handler", s)
+		fn(s)
+		s = <-c
+	}
 	debug.Log("signal %v received, cleaning up", s)
 	Warnf("%ssignal %v received, cleaning up\n", clearLine(0), s)
 
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var cmdBackend = &cobra.Command{
+	Use:   "backend",
+	Short: "Query the storage backend of the repository",
+}
+
+func init() {
+	cmdRoot.AddCommand(cmdBackend)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var cmdBackendDf = &cobra.Command{
+	Use:   "df",
+	Short: "Show the free space of the repository storage",
+	Long: `
+The "backend df" command shows the size and the free space of the storage the
+repository is located on. The available space is the space which can still be
+used by the repository, it is smaller than the free space if a quota applies.
+
+Only the SMB and NFS backends support querying the storage space.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBackendDf(cmd.Context(), globalOptions, args)
+	},
+}
+
+func init() {
+	cmdBackend.AddCommand(cmdBackendDf)
+}
+
+type spaceJSON struct {
+	Total     uint64 `json:"total"`
+	Used      uint64 `json:"used"`
+	Free      uint64 `json:"free"`
+	Available uint64 `json:"available"`
+}
+
+func runBackendDf(ctx context.Context, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the backend df command expects no arguments, only options - please see `restic help backend df` for usage and flags")
+	}
+
+	// no lock is created, as this would fail on a full share
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	space, err := repo.Space(ctx)
+	if errors.Is(err, repository.ErrNoSpaceInfo) {
+		return errors.Fatal("the backend of the repository does not support querying the storage space")
+	}
+	if err != nil {
+		return err
+	}
+
+	return printSpace(space, gopts)
+}
+
+func printSpace(space backend.SpaceInfo, gopts GlobalOptions) error {
+	used := uint64(0)
+	if space.Total > space.Free {
+		used = space.Total - space.Free
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(gopts.stdout).Encode(spaceJSON{
+			Total:     space.Total,
+			Used:      used,
+			Free:      space.Free,
+			Available: space.Available,
+		})
+	}
+
+	_, err := fmt.Fprintf(gopts.stdout, "Total:     %v\nUsed:      %v (%v)\nFree:      %v\nAvailable: %v\n",
+		ui.FormatBytes(space.Total), ui.FormatBytes(used), ui.FormatPercent(used, space.Total),
+		ui.FormatBytes(space.Free), ui.FormatBytes(space.Available))
+	return err
+}
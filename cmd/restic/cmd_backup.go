@@ -18,13 +18,18 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/fswatch"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/textfile"
+	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/backup"
+	"github.com/restic/restic/internal/ui/metrics"
 	"github.com/restic/restic/internal/ui/termstatus"
 )
 
@@ -40,7 +45,10 @@ EXIT STATUS
 
 Exit status is 0 if the command was successful.
 Exit status is 1 if there was a fatal error (no snapshot created).
-Exit status is 3 if some source data could not be read (incomplete snapshot created).
+Exit status is 3 if some source data could not be read or the backup was interrupted
+with --partial-on-interrupt (incomplete snapshot created).
+Exit status is 4 if the backup was aborted because more source data than allowed
+by --max-errors or --max-error-percent could not be read (no snapshot created).
 `,
 	PreRun: func(_ *cobra.Command, _ []string) {
 		if backupOptions.Host == "" {
@@ -64,30 +72,61 @@ Exit status is 3 if some source data could not be read (incomplete snapshot crea
 type BackupOptions struct {
 	excludePatternOptions
 
-	Parent            string
-	GroupBy           restic.SnapshotGroupByOptions
-	Force             bool
-	ExcludeOtherFS    bool
-	ExcludeIfPresent  []string
-	ExcludeCaches     bool
-	ExcludeLargerThan string
-	Stdin             bool
-	StdinFilename     string
-	StdinCommand      bool
-	Tags              restic.TagLists
-	Host              string
-	FilesFrom         []string
-	FilesFromVerbatim []string
-	FilesFromRaw      []string
-	TimeStamp         string
-	WithAtime         bool
-	IgnoreInode       bool
-	IgnoreCtime       bool
-	UseFsSnapshot     bool
-	DryRun            bool
-	ReadConcurrency   uint
-	NoScan            bool
-	SkipIfUnchanged   bool
+	Parent             string
+	GroupBy            restic.SnapshotGroupByOptions
+	Force              bool
+	ExcludeOtherFS     bool
+	ExcludeIfPresent   []string
+	ExcludeCaches      bool
+	ExcludeLargerThan  string
+	ExcludeNoDump      bool
+	ChunkMapMinSize    string
+	ExcludePresets     []string
+	RulesFromRepo      []string
+	Stdin              bool
+	StdinFilename      string
+	StdinCommand       bool
+	SourcePlugin       bool
+	Tags               restic.TagLists
+	Host               string
+	FilesFrom          []string
+	FilesFromVerbatim  []string
+	FilesFromRaw       []string
+	TimeStamp          string
+	WithAtime          bool
+	WithEFSMetadata    bool
+	EFSRaw             bool
+	OfflineFiles       archiver.OfflinePolicy
+	OfflineRecalls     uint
+	IgnoreInode        bool
+	IgnoreCtime        bool
+	UseFsSnapshot      bool
+	UseChangeJournal   bool
+	DryRun             bool
+	ReadConcurrency    uint
+	CPUWorkers         uint
+	NoScan             bool
+	SkipIfUnchanged    bool
+	Catalog            bool
+	PartialOnInterrupt bool
+	PauseOnBattery     bool
+	LowPowerThrottle   int
+	AllowedSSIDs       []string
+	AllowedInterfaces  []string
+	SkipMetered        bool
+	NetworkThrottle    int
+	MaxErrors          uint
+	MaxErrorPercent    float64
+	QueueTimeout       time.Duration
+	ExpireAfter        restic.Duration
+	CompressionLevel   repository.CompressionLevel
+
+	SnapshotPathPrefix string
+
+	// skipUnchangedDir and snapshotSaved are used by the watch command to
+	// only read the directories which have changed since the last snapshot.
+	skipUnchangedDir func(path string) bool
+	snapshotSaved    func(id restic.ID)
 }
 
 var backupOptions BackupOptions
@@ -95,6 +134,10 @@ var backupOptions BackupOptions
 // ErrInvalidSourceData is used to report an incomplete backup
 var ErrInvalidSourceData = errors.New("at least one source file could not be read")
 
+// ErrPartialSnapshot is returned when the backup was interrupted and a partial
+// snapshot was saved.
+var ErrPartialSnapshot = errors.New("backup was interrupted, saved a partial snapshot")
+
 func init() {
 	cmdRoot.AddCommand(cmdBackup)
 
@@ -110,11 +153,19 @@ func init() {
 	f.StringArrayVar(&backupOptions.ExcludeIfPresent, "exclude-if-present", nil, "takes `filename[:header]`, exclude contents of directories containing filename (except filename itself) if header of that file is as provided (can be specified multiple times)")
 	f.BoolVar(&backupOptions.ExcludeCaches, "exclude-caches", false, `excludes cache directories that are marked with a CACHEDIR.TAG file. See https://bford.info/cachedir/ for the Cache Directory Tagging Standard`)
 	f.StringVar(&backupOptions.ExcludeLargerThan, "exclude-larger-than", "", "max `size` of the files to be backed up (allowed suffixes: k/K, m/M, g/G, t/T)")
+	if runtime.GOOS == "linux" {
+		f.BoolVar(&backupOptions.ExcludeNoDump, "exclude-nodump", false, "exclude files and directories with the nodump flag set by chattr +d")
+		f.StringVar(&backupOptions.ChunkMapMinSize, "chunk-map-min-size", "", "only read the modified regions of changed files of at least `size` on btrfs (allowed suffixes: k/K, m/M, g/G, t/T)")
+	}
+	f.StringSliceVar(&backupOptions.ExcludePresets, "exclude-preset", nil, "exclude the built-in `preset`s of well-known junk paths ("+strings.Join(filter.PresetNames(), ", ")+"), can be specified multiple times")
+	f.StringArrayVar(&backupOptions.RulesFromRepo, "rules-from-repo", nil, "exclude the items matching the rule set `name[@version]` stored in the repository (can be specified multiple times)")
 	f.BoolVar(&backupOptions.Stdin, "stdin", false, "read backup from stdin")
 	f.StringVar(&backupOptions.StdinFilename, "stdin-filename", "stdin", "`filename` to use when reading from stdin")
 	f.BoolVar(&backupOptions.StdinCommand, "stdin-from-command", false, "interpret arguments as command to execute and store its stdout")
+	f.BoolVar(&backupOptions.SourcePlugin, "source-plugin", false, "interpret arguments as source plugin command and store the files it provides")
 	f.Var(&backupOptions.Tags, "tag", "add `tags` for the new snapshot in the format `tag[,tag,...]` (can be specified multiple times)")
 	f.UintVar(&backupOptions.ReadConcurrency, "read-concurrency", 0, "read `n` files concurrently (default: $RESTIC_READ_CONCURRENCY or 2)")
+	f.UintVar(&backupOptions.CPUWorkers, "cpu-workers", 0, "use `n` workers for hashing, compressing and encrypting data (default: $RESTIC_CPU_WORKERS or number of CPUs)")
 	f.StringVarP(&backupOptions.Host, "host", "H", "", "set the `hostname` for the snapshot manually (default: $RESTIC_HOST). To prevent an expensive rescan use the \"parent\" flag")
 	f.StringVar(&backupOptions.Host, "hostname", "", "set the `hostname` for the snapshot manually")
 	err := f.MarkDeprecated("hostname", "use --host")
@@ -131,14 +182,36 @@ func init() {
 	f.BoolVar(&backupOptions.IgnoreCtime, "ignore-ctime", false, "ignore ctime changes when checking for modified files")
 	f.BoolVarP(&backupOptions.DryRun, "dry-run", "n", false, "do not upload or write any data, just show what would be done")
 	f.BoolVar(&backupOptions.NoScan, "no-scan", false, "do not run scanner to estimate size of backup")
+	f.StringVar(&backupOptions.SnapshotPathPrefix, "snapshot-path-prefix", "", "store the backup targets below `path` in the snapshot instead of their actual location")
 	if runtime.GOOS == "windows" {
 		f.BoolVar(&backupOptions.UseFsSnapshot, "use-fs-snapshot", false, "use filesystem snapshot where possible (currently only Windows VSS)")
+		f.BoolVar(&backupOptions.UseFsSnapshot, "use-vss", false, "read locked and open files from a Volume Shadow Copy snapshot (same as --use-fs-snapshot)")
+		f.BoolVar(&backupOptions.WithEFSMetadata, "with-efs-metadata", false, "store the certificates of the users and recovery agents which can decrypt EFS-encrypted files")
+		f.BoolVar(&backupOptions.EFSRaw, "efs-raw", false, "save EFS-encrypted files in their raw encrypted form, without decrypting them")
+		f.BoolVar(&backupOptions.UseChangeJournal, "use-change-journal", false, "only read directories which contain changes according to the NTFS change journal since the parent snapshot")
+		f.Var(&backupOptions.OfflineFiles, "offline-files", "how to save offline files of storage tiering systems, one of (recall|metadata|skip) (default: recall)")
+		f.UintVar(&backupOptions.OfflineRecalls, "offline-recall-limit", 1, "recall at most `n` offline files at the same time, 0 for no limit")
 	}
 	f.BoolVar(&backupOptions.SkipIfUnchanged, "skip-if-unchanged", false, "skip snapshot creation if identical to parent snapshot")
+	f.BoolVar(&backupOptions.Catalog, "catalog", false, "store a catalog of all paths which speeds up find, ls and versions for huge snapshots")
+	f.BoolVar(&backupOptions.PartialOnInterrupt, "partial-on-interrupt", false, "on the first interrupt, save a snapshot tagged \"partial\" with the files backed up so far")
+	f.BoolVar(&backupOptions.PauseOnBattery, "pause-on-battery", false, "defer the backup and pause reading files while the system runs on battery")
+	f.IntVar(&backupOptions.LowPowerThrottle, "low-power-throttle", 0, "limit reading files to `rate` KiB/s while the system runs on battery or in power saver mode")
+	f.StringArrayVar(&backupOptions.AllowedSSIDs, "allowed-ssid", nil, "only run the backup when connected to the Wi-Fi network `ssid` or a wired network (can be specified multiple times)")
+	f.StringArrayVar(&backupOptions.AllowedInterfaces, "allowed-interface", nil, "only run the backup when the network `interface` is used for the default route (can be specified multiple times)")
+	f.BoolVar(&backupOptions.SkipMetered, "skip-metered", false, "do not run the backup over a metered network connection")
+	f.IntVar(&backupOptions.NetworkThrottle, "network-throttle", 0, "instead of skipping the backup on a network which is not allowed, limit uploads to `rate` KiB/s")
+	f.UintVar(&backupOptions.MaxErrors, "max-errors", 0, "abort the backup without creating a snapshot if more than `n` source files could not be read (default: unlimited)")
+	f.Float64Var(&backupOptions.MaxErrorPercent, "max-error-percent", 0, "abort the backup without creating a snapshot if more than `percent` of the source files could not be read (default: unlimited)")
+	f.Var(&backupOptions.ExpireAfter, "expire-after", "mark the snapshot as expired after `duration` (eg. 1y5m7d2h), see 'forget --honor-expiry'")
+	f.Var(&backupOptions.CompressionLevel, "compression-level", "override the zstd compression `level` for this backup, one of (fastest|default|better|best)")
+	f.DurationVar(&backupOptions.QueueTimeout, "queue-timeout", 0, "give up after waiting `duration` for another backup of the same paths to the same repository to finish (default: wait indefinitely)")
 
 	// parse read concurrency from env, on error the default value will be used
 	readConcurrency, _ := strconv.ParseUint(os.Getenv("RESTIC_READ_CONCURRENCY"), 10, 32)
 	backupOptions.ReadConcurrency = uint(readConcurrency)
+	cpuWorkers, _ := strconv.ParseUint(os.Getenv("RESTIC_CPU_WORKERS"), 10, 32)
+	backupOptions.CPUWorkers = uint(cpuWorkers)
 
 	// parse host from env, if not exists or empty the default value will be used
 	if host := os.Getenv("RESTIC_HOST"); host != "" {
@@ -286,14 +359,94 @@ func (opts BackupOptions) Check(gopts GlobalOptions, args []string) error {
 		if len(args) > 0 && !opts.StdinCommand {
 			return errors.Fatal("--stdin was specified and files/dirs were listed as arguments")
 		}
+		if opts.SnapshotPathPrefix != "" {
+			return errors.Fatal("--stdin and --snapshot-path-prefix cannot be used together")
+		}
+	}
+
+	if opts.MaxErrorPercent < 0 || opts.MaxErrorPercent > 100 {
+		return errors.Fatal("--max-error-percent must be between 0 and 100")
+	}
+	if opts.MaxErrorPercent > 0 && opts.NoScan {
+		return errors.Fatal("--max-error-percent cannot be used with --no-scan")
+	}
+	d := opts.ExpireAfter
+	if d.Hours < 0 || d.Days < 0 || d.Months < 0 || d.Years < 0 {
+		return errors.Fatal("durations containing negative values are not allowed for --expire-after")
+	}
+	if opts.CompressionLevel != repository.CompressionLevelUnset && gopts.Compression == repository.CompressionOff {
+		return errors.Fatal("--compression-level cannot be used with --compression off")
+	}
+
+	if opts.SourcePlugin {
+		if opts.Stdin || opts.StdinCommand {
+			return errors.Fatal("--source-plugin and --stdin cannot be used together")
+		}
+		if len(opts.FilesFrom) > 0 || len(opts.FilesFromVerbatim) > 0 || len(opts.FilesFromRaw) > 0 {
+			return errors.Fatal("--source-plugin and --files-from cannot be used together")
+		}
+		if len(args) == 0 {
+			return errors.Fatal("--source-plugin requires the plugin command as arguments")
+		}
+		if opts.SnapshotPathPrefix != "" {
+			return errors.Fatal("--source-plugin and --snapshot-path-prefix cannot be used together")
+		}
+	}
+
+	if opts.ChunkMapMinSize != "" {
+		size, err := ui.ParseBytes(opts.ChunkMapMinSize)
+		if err != nil {
+			return errors.Fatalf("invalid --chunk-map-min-size: %v", err)
+		}
+		if size <= 0 {
+			return errors.Fatal("--chunk-map-min-size must be positive")
+		}
+	}
+
+	if opts.SnapshotPathPrefix != "" && !filepath.IsAbs(opts.SnapshotPathPrefix) {
+		return errors.Fatal("--snapshot-path-prefix must be an absolute path")
+	}
+
+	if opts.UseChangeJournal {
+		if opts.Stdin || opts.StdinCommand || opts.SourcePlugin {
+			return errors.Fatal("--use-change-journal can only be used when backing up files")
+		}
+		// the change journal reports the real paths
+		if opts.SnapshotPathPrefix != "" {
+			return errors.Fatal("--use-change-journal and --snapshot-path-prefix cannot be used together")
+		}
+	}
+
+	if opts.LowPowerThrottle < 0 {
+		return errors.Fatal("--low-power-throttle must not be negative")
+	}
+
+	if opts.NetworkThrottle < 0 {
+		return errors.Fatal("--network-throttle must not be negative")
+	}
+	if opts.NetworkThrottle > 0 && !opts.networkPolicy().enabled() {
+		return errors.Fatal("--network-throttle requires --allowed-ssid, --allowed-interface or --skip-metered")
+	}
+
+	if opts.QueueTimeout < 0 {
+		return errors.Fatal("--queue-timeout must not be negative")
 	}
 
 	return nil
 }
 
+// networkPolicy returns the restrictions for the network used by the backup.
+func (opts BackupOptions) networkPolicy() networkPolicy {
+	return networkPolicy{
+		AllowedSSIDs:      opts.AllowedSSIDs,
+		AllowedInterfaces: opts.AllowedInterfaces,
+		SkipMetered:       opts.SkipMetered,
+	}
+}
+
 // collectRejectByNameFuncs returns a list of all functions which may reject data
 // from being saved in a snapshot based on path only
-func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository) (fs []RejectByNameFunc, err error) {
+func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository, ruleSets []repoRuleSet, excluded *presetExclusions, report func(item string)) (fs []RejectByNameFunc, err error) {
 	// exclude restic cache
 	if repo.Cache != nil {
 		f, err := rejectResticCache(repo)
@@ -310,6 +463,22 @@ func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository) (
 	}
 	fs = append(fs, fsPatterns...)
 
+	for _, rs := range ruleSets {
+		rsPatterns, err := rs.CollectPatterns()
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, rsPatterns...)
+	}
+
+	presets, err := filter.LookupPresets(opts.ExcludePresets)
+	if err != nil {
+		return nil, errors.Fatalf("--exclude-preset: %v", err)
+	}
+	for _, preset := range presets {
+		fs = append(fs, rejectByPreset(preset, excluded, report))
+	}
+
 	if opts.ExcludeCaches {
 		opts.ExcludeIfPresent = append(opts.ExcludeIfPresent, "CACHEDIR.TAG:Signature: 8a477f597d28d172789f06886806bc55")
 	}
@@ -330,7 +499,7 @@ func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository) (
 // from being saved in a snapshot based on path and file info
 func collectRejectFuncs(opts BackupOptions, targets []string) (fs []RejectFunc, err error) {
 	// allowed devices
-	if opts.ExcludeOtherFS && !opts.Stdin {
+	if opts.ExcludeOtherFS && !opts.Stdin && !opts.SourcePlugin {
 		f, err := rejectByDevice(targets)
 		if err != nil {
 			return nil, err
@@ -346,12 +515,93 @@ func collectRejectFuncs(opts BackupOptions, targets []string) (fs []RejectFunc,
 		fs = append(fs, f)
 	}
 
+	if opts.ExcludeNoDump && !opts.Stdin && !opts.SourcePlugin {
+		fs = append(fs, rejectNoDump)
+	}
+
 	return fs, nil
 }
 
+// collectVolumeInfo returns the metadata of all volumes whose root is one of
+// the targets.
+func collectVolumeInfo(targets []string) []fs.VolumeInfo {
+	var volumes []fs.VolumeInfo
+	for _, target := range targets {
+		if !fs.IsVolumeRoot(target) {
+			continue
+		}
+		info, err := fs.GetVolumeInfo(target)
+		if err != nil {
+			Warnf("unable to read volume information of %v: %v\n", target, err)
+			continue
+		}
+		if info != nil {
+			volumes = append(volumes, *info)
+		}
+	}
+	return volumes
+}
+
+// readChangeJournals returns a function which reports whether a directory is
+// unchanged since the parent snapshot according to the change journals. It
+// returns nil if the change journals cannot be used.
+func readChangeJournals(ctx context.Context, parent *restic.Snapshot, opts BackupOptions, journals []fs.ChangeJournal, printf func(string, ...interface{})) func(path string) bool {
+	if len(parent.ChangeJournals) == 0 {
+		printf("parent snapshot contains no change journal positions, will read all files\n")
+		return nil
+	}
+	// directories which were excluded before may be included now and vice versa
+	if strings.Join(parent.Excludes, "\x00") != strings.Join(opts.Excludes, "\x00") {
+		printf("exclude patterns differ from the parent snapshot, will read all files\n")
+		return nil
+	}
+
+	changes, err := fswatch.JournalChanges(ctx, parent.ChangeJournals, journals)
+	if err != nil {
+		printf("unable to read the change journal, will read all files: %v\n", err)
+		return nil
+	}
+	debug.Log("change journal contains %d changed paths", changes.Len())
+	return changes.Unchanged
+}
+
+// remapTargets moves the targets below prefix. The longest common parent
+// directory of all targets, or the target itself if there is only one, is
+// replaced by prefix. It returns the replaced directory and the new targets.
+func remapTargets(targets []string, prefix string) (source string, remapped []string, err error) {
+	absTargets := make([]string, 0, len(targets))
+	for _, target := range targets {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			return "", nil, err
+		}
+		absTargets = append(absTargets, abs)
+	}
+
+	source = absTargets[0]
+	for _, target := range absTargets[1:] {
+		for !fs.HasPathPrefix(source, target) {
+			if filepath.Dir(source) == source {
+				return "", nil, errors.Fatal("--snapshot-path-prefix requires all targets to be on the same volume")
+			}
+			source = filepath.Dir(source)
+		}
+	}
+
+	prefix = filepath.Clean(prefix)
+	for _, target := range absTargets {
+		rel, err := filepath.Rel(source, target)
+		if err != nil {
+			return "", nil, err
+		}
+		remapped = append(remapped, filepath.Join(prefix, rel))
+	}
+	return source, remapped, nil
+}
+
 // collectTargets returns a list of target files/dirs from several sources.
 func collectTargets(opts BackupOptions, args []string) (targets []string, err error) {
-	if opts.Stdin || opts.StdinCommand {
+	if opts.Stdin || opts.StdinCommand || opts.SourcePlugin {
 		return nil, nil
 	}
 
@@ -475,16 +725,90 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		}
 	}
 
+	var power *powerMonitor
+	if opts.PauseOnBattery || opts.LowPowerThrottle > 0 {
+		notify := func(msg string) {
+			if !gopts.JSON {
+				Verbosef("%s\n", msg)
+			}
+		}
+		powerCtx, cancelPower := context.WithCancel(ctx)
+		defer cancelPower()
+		power, err = newPowerMonitor(powerCtx, opts.PauseOnBattery, opts.LowPowerThrottle, readPowerState, notify)
+		if err != nil {
+			Warnf("unable to determine the power state, ignoring --pause-on-battery and --low-power-throttle: %v\n", err)
+		} else {
+			go power.Run(powerPollInterval)
+
+			if state, _ := power.current(); opts.PauseOnBattery && state.OnBattery {
+				notify("running on battery power, deferring backup until AC power is connected")
+				if err := power.WaitForAC(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if policy := opts.networkPolicy(); policy.enabled() {
+		info := readNetworkInfo()
+		debug.Log("network %+v", info)
+		if reason := policy.check(info); reason != "" {
+			if opts.NetworkThrottle == 0 {
+				if !gopts.JSON {
+					Printf("skipping backup: %v\n", reason)
+				}
+				return nil
+			}
+
+			if gopts.Limits.UploadKb == 0 || gopts.Limits.UploadKb > opts.NetworkThrottle {
+				gopts.Limits.UploadKb = opts.NetworkThrottle
+			}
+			if !gopts.JSON {
+				Verbosef("%v, limiting uploads to %v KiB/s\n", reason, gopts.Limits.UploadKb)
+			}
+		}
+	}
+
+	if !opts.DryRun {
+		release, err := acquireBackupJob(ctx, opts, gopts, targets)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
 	if gopts.verbosity >= 2 && !gopts.JSON {
 		Verbosef("open repository\n")
 	}
 
+	gopts.compressionLevel = opts.CompressionLevel
 	ctx, repo, unlock, err := openWithAppendLock(ctx, gopts, opts.DryRun)
 	if err != nil {
 		return err
 	}
 	defer unlock()
 
+	if opts.WithEFSMetadata {
+		if err := checkResticCompat(repo, "--with-efs-metadata"); err != nil {
+			return err
+		}
+	}
+	if opts.EFSRaw {
+		if err := checkResticCompat(repo, "--efs-raw"); err != nil {
+			return err
+		}
+	}
+	if opts.OfflineFiles == archiver.OfflineMetadata {
+		if err := checkResticCompat(repo, "--offline-files metadata"); err != nil {
+			return err
+		}
+	}
+	if opts.Catalog {
+		if err := checkResticCompat(repo, "--catalog"); err != nil {
+			return err
+		}
+	}
+
 	var progressPrinter backup.ProgressPrinter
 	if gopts.JSON {
 		progressPrinter = backup.NewJSONProgress(term, gopts.verbosity)
@@ -494,9 +818,47 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	progressReporter := backup.NewProgress(progressPrinter,
 		calculateProgressInterval(!gopts.Quiet, gopts.JSON))
 	defer progressReporter.Done()
+	if gopts.MetricsListen != "" {
+		metrics.ObserveBackup(progressReporter)
+	}
+
+	// the targets are the entries provided by the plugin
+	var plugin *fs.SourcePlugin
+	if opts.SourcePlugin {
+		if !gopts.JSON {
+			progressPrinter.V("start source plugin %v", args[0])
+		}
+		plugin, err = fs.NewSourcePlugin(ctx, args, globalOptions.stderr)
+		if err != nil {
+			return errors.Fatalf("unable to start source plugin: %v", err)
+		}
+		defer func() {
+			_ = plugin.Close()
+		}()
+
+		targets = plugin.Targets()
+		if len(targets) == 0 {
+			return errors.Fatal("source plugin did not return any entries")
+		}
+	}
 
 	// rejectByNameFuncs collect functions that can reject items from the backup based on path only
-	rejectByNameFuncs, err := collectRejectByNameFuncs(opts, repo)
+	presetExcluded := &presetExclusions{}
+	reportPresetExclusion := func(item string) {
+		if !gopts.JSON {
+			progressPrinter.V("excluded  %v by preset", item)
+		}
+	}
+	ruleSets, err := loadRepoRuleSets(ctx, repo, opts.RulesFromRepo)
+	if err != nil {
+		return err
+	}
+	for _, rs := range ruleSets {
+		progressPrinter.V("using rule set %v from the repository", rs)
+	}
+	// the presets of the rule sets are reported like the ones passed directly
+	opts.ExcludePresets = addRuleSetPresets(opts.ExcludePresets, ruleSets)
+	rejectByNameFuncs, err := collectRejectByNameFuncs(opts, repo, ruleSets, presetExcluded, reportPresetExclusion)
 	if err != nil {
 		return err
 	}
@@ -507,6 +869,30 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		return err
 	}
 
+	// the volume metadata must be read before the targets are remapped
+	var volumes []fs.VolumeInfo
+	if !opts.Stdin && !opts.StdinCommand && !opts.SourcePlugin {
+		volumes = collectVolumeInfo(targets)
+	}
+
+	// the positions in the change journals must be read before any file, all
+	// later changes are then contained in the next backup
+	var journals []fs.ChangeJournal
+	if opts.UseChangeJournal && !opts.DryRun {
+		journals, err = fswatch.QueryJournals(targets)
+		if err != nil {
+			Warnf("unable to use the change journal, reading all files: %v\n", err)
+		}
+	}
+
+	var remapSource string
+	if opts.SnapshotPathPrefix != "" {
+		remapSource, targets, err = remapTargets(targets, opts.SnapshotPathPrefix)
+		if err != nil {
+			return err
+		}
+	}
+
 	var parentSnapshot *restic.Snapshot
 	if !opts.Stdin {
 		parentSnapshot, err = findParentSnapshot(ctx, repo, opts, targets, timeStamp)
@@ -523,6 +909,30 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		}
 	}
 
+	// without a parent snapshot, all data found by the scanner is projected to
+	// be new and checked once the scan is complete
+	var checkScannedSpace func(size uint64)
+	if space, err := repo.Space(ctx); err == nil {
+		if parentSnapshot != nil && parentSnapshot.Summary != nil {
+			warnBackupSpace(space, parentSnapshot.Summary.DataAddedPacked)
+		} else {
+			checkScannedSpace = func(size uint64) {
+				warnBackupSpace(space, size)
+			}
+		}
+	} else if !errors.Is(err, repository.ErrNoSpaceInfo) {
+		Warnf("unable to query the free space of the repository: %v\n", err)
+	}
+
+	skipUnchangedDir := opts.skipUnchangedDir
+	if journals != nil && parentSnapshot != nil && skipUnchangedDir == nil {
+		printf := func(string, ...interface{}) {}
+		if !gopts.JSON {
+			printf = progressPrinter.P
+		}
+		skipUnchangedDir = readChangeJournals(ctx, parentSnapshot, opts, journals, printf)
+	}
+
 	if !gopts.JSON {
 		progressPrinter.V("load index files")
 	}
@@ -533,6 +943,7 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	if err != nil {
 		return err
 	}
+	oldIndexes := repo.IndexIDs()
 
 	selectByNameFilter := func(item string) bool {
 		for _, reject := range rejectByNameFuncs {
@@ -594,16 +1005,57 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		targets = []string{filename}
 	}
 
+	if plugin != nil {
+		targetFS = plugin
+	}
+
+	if power != nil {
+		targetFS = fs.Gate{FS: targetFS, Wait: power.Wait}
+	}
+
+	// the remapped file system must be the outermost one, as the archiver reads
+	// metadata like extended attributes from the real paths
+	if remapSource != "" {
+		remap := &fs.Remap{FS: targetFS, Source: remapSource, Target: opts.SnapshotPathPrefix}
+		targetFS = remap
+
+		// filters always operate on the real paths
+		filterByName, filter := selectByNameFilter, selectFilter
+		selectByNameFilter = func(item string) bool {
+			return filterByName(remap.RealPath(item))
+		}
+		selectFilter = func(item string, fi os.FileInfo) bool {
+			return filter(remap.RealPath(item), fi)
+		}
+	}
+
 	wg, wgCtx := errgroup.WithContext(ctx)
 	cancelCtx, cancel := context.WithCancel(wgCtx)
 	defer cancel()
 
+	budget := newErrorBudget(opts.MaxErrors, opts.MaxErrorPercent)
+
 	if !opts.NoScan {
 		sc := archiver.NewScanner(targetFS)
 		sc.SelectByName = selectByNameFilter
 		sc.Select = selectFilter
 		sc.Error = progressPrinter.ScannerError
 		sc.Result = progressReporter.ReportTotal
+		if budget.enabled() {
+			sc.Result = func(item string, s archiver.ScanStats) {
+				budget.ReportTotal(item, s)
+				progressReporter.ReportTotal(item, s)
+			}
+		}
+		if checkScannedSpace != nil {
+			report := sc.Result
+			sc.Result = func(item string, s archiver.ScanStats) {
+				report(item, s)
+				if item == "" {
+					checkScannedSpace(s.Bytes)
+				}
+			}
+		}
 
 		if !gopts.JSON {
 			progressPrinter.V("start scan on %v", targets)
@@ -611,10 +1063,28 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		wg.Go(func() error { return sc.Scan(cancelCtx, targets) })
 	}
 
-	arch := archiver.New(repo, targetFS, archiver.Options{ReadConcurrency: opts.ReadConcurrency})
+	arch := archiver.New(repo, targetFS, archiver.Options{
+		ReadConcurrency:     opts.ReadConcurrency,
+		SaveBlobConcurrency: opts.CPUWorkers,
+	})
 	arch.SelectByName = selectByNameFilter
 	arch.Select = selectFilter
 	arch.WithAtime = opts.WithAtime
+	arch.WithEFSMetadata = opts.WithEFSMetadata
+	arch.EFSRaw = opts.EFSRaw
+	arch.OfflinePolicy = opts.OfflineFiles
+	arch.OfflineRecalls = opts.OfflineRecalls
+	arch.SkipUnchangedDir = skipUnchangedDir
+	if opts.PartialOnInterrupt {
+		interrupt := make(chan struct{})
+		arch.Interrupt = interrupt
+		defer setInterruptHandler(func(s os.Signal) {
+			Warnf("%ssignal %v received, saving a partial snapshot, repeat to abort\n", clearLine(0), s)
+			// stop the scanner
+			cancel()
+			close(interrupt)
+		})()
+	}
 	success := true
 	arch.Error = func(item string, err error) error {
 		success = false
@@ -624,9 +1094,18 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		if reterr == nil && errors.IsFatal(err) {
 			reterr = err
 		}
+		if reterr == nil && budget.enabled() {
+			reterr = budget.Error()
+		}
 		return reterr
 	}
 	arch.CompleteItem = progressReporter.CompleteItem
+	if budget.enabled() {
+		arch.CompleteItem = func(item string, previous, current *restic.Node, s archiver.ItemStats, d time.Duration) {
+			budget.CompleteItem()
+			progressReporter.CompleteItem(item, previous, current, s, d)
+		}
+	}
 	arch.StartFile = progressReporter.StartFile
 	arch.CompleteBlob = progressReporter.CompleteBlob
 
@@ -639,6 +1118,19 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		arch.ChangeIgnoreFlags |= archiver.ChangeIgnoreCtime
 	}
 
+	var chunkMaps *archiver.ChunkMaps
+	if opts.ChunkMapMinSize != "" {
+		if repo.Cache == nil {
+			Warnf("--chunk-map-min-size requires the local cache, ignoring\n")
+		} else {
+			// the size was validated by opts.Check
+			minSize, _ := ui.ParseBytes(opts.ChunkMapMinSize)
+			chunkMaps = loadChunkMaps(repo)
+			arch.ChunkMaps = chunkMaps
+			arch.ChunkMapMinSize = uint64(minSize)
+		}
+	}
+
 	snapshotOpts := archiver.SnapshotOptions{
 		Excludes:        opts.Excludes,
 		Tags:            opts.Tags.Flatten(),
@@ -648,12 +1140,29 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 		ParentSnapshot:  parentSnapshot,
 		ProgramVersion:  "restic " + version,
 		SkipIfUnchanged: opts.SkipIfUnchanged,
+		Volumes:         volumes,
+		ExpireAfter:     opts.ExpireAfter,
+		Catalog:         opts.Catalog,
+	}
+	if journals != nil {
+		snapshotOpts.ChangeJournals = func() []fs.ChangeJournal {
+			// files missing from the snapshot must be read again next time
+			if !success {
+				return nil
+			}
+			select {
+			case <-arch.Interrupt:
+				return nil
+			default:
+			}
+			return journals
+		}
 	}
 
 	if !gopts.JSON {
 		progressPrinter.V("start backup on %v", targets)
 	}
-	_, id, summary, err := arch.Snapshot(ctx, targets, snapshotOpts)
+	sn, id, summary, err := arch.Snapshot(ctx, targets, snapshotOpts)
 
 	// cleanly shutdown all running goroutines
 	cancel()
@@ -661,13 +1170,44 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	// let's see if one returned an error
 	werr := wg.Wait()
 
+	if plugin != nil && err == nil {
+		// a failing plugin may have provided incomplete data
+		if perr := plugin.Close(); perr != nil {
+			Warnf("%v\n", perr)
+			success = false
+		}
+	}
+
 	// return original error
 	if err != nil {
+		if budget.Exceeded() {
+			Warnf("%v\n", budget)
+			return ErrErrorBudgetExceeded
+		}
 		return errors.Fatalf("unable to save snapshot: %v", err)
 	}
 
+	if !opts.DryRun && werr == nil && !id.IsNull() {
+		updateStatsCacheAfterBackup(ctx, repo, oldIndexes, id, sn, summary, gopts.Compression)
+		if chunkMaps != nil {
+			saveChunkMaps(repo, chunkMaps)
+		}
+		if opts.snapshotSaved != nil {
+			opts.snapshotSaved(id)
+		}
+	}
+
 	// Report finished execution
 	progressReporter.Finish(id, summary, opts.DryRun)
+	if !gopts.JSON {
+		for _, name := range opts.ExcludePresets {
+			name = strings.TrimSpace(name)
+			progressPrinter.P("Preset %v excluded %d items", name, presetExcluded.Count(name))
+		}
+	}
+	if summary != nil && summary.Interrupted && !id.IsNull() {
+		return ErrPartialSnapshot
+	}
 	if !success {
 		return ErrInvalidSourceData
 	}
@@ -675,3 +1215,24 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts GlobalOptions, ter
 	// Return error if any
 	return werr
 }
+
+// backupSpaceWarning returns a warning if the data a backup is projected to
+// add exceeds the space available on the repository storage, and an empty
+// string otherwise.
+func backupSpaceWarning(space backend.SpaceInfo, projected uint64) string {
+	if projected <= space.Available {
+		return ""
+	}
+	limit := "free space"
+	if space.Available < space.Free {
+		limit = "quota"
+	}
+	return fmt.Sprintf("warning: the backup is projected to add %v, but only %v of %v remain on the repository storage\n",
+		ui.FormatBytes(projected), ui.FormatBytes(space.Available), limit)
+}
+
+func warnBackupSpace(space backend.SpaceInfo, projected uint64) {
+	if msg := backupSpaceWarning(space, projected); msg != "" {
+		Warnf("%s", msg)
+	}
+}
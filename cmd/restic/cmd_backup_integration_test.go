@@ -2,14 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 	"github.com/restic/restic/internal/ui/termstatus"
@@ -111,6 +115,43 @@ func TestBackupWithRelativePath(t *testing.T) {
 	rtest.Assert(t, latestSn.Parent != nil && latestSn.Parent.Equal(firstSnapshotID), "second snapshot selected unexpected parent %v instead of %v", latestSn.Parent, firstSnapshotID)
 }
 
+func TestBackupSnapshotPathPrefix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a prefix without volume name")
+	}
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	prefix := "/srv/data"
+	opts := BackupOptions{SnapshotPathPrefix: prefix}
+	source := filepath.Join(env.testdata, "0", "0", "9")
+
+	testRunBackup(t, "", []string{source}, opts, env.gopts)
+	firstSnapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	sn, _ := testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, []string{prefix}, sn.Paths)
+
+	for _, item := range testRunLs(t, env.gopts, firstSnapshotID.String()) {
+		if item == "" {
+			continue
+		}
+		rtest.Assert(t, strings.HasPrefix(prefix, item) || strings.HasPrefix(item, prefix),
+			"unexpected path %q in snapshot", item)
+	}
+
+	// the second backup must use the first one as parent
+	testRunBackup(t, "", []string{source}, opts, env.gopts)
+	sn, _ = testRunSnapshots(t, env.gopts)
+	rtest.Assert(t, sn.Parent != nil && sn.Parent.Equal(firstSnapshotID), "unexpected parent snapshot %v", sn.Parent)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, firstSnapshotID)
+	diff := directoriesContentsDiff(source, filepath.Join(restoredir, prefix))
+	rtest.Assert(t, diff == "", "directories are not equal: %v", diff)
+}
+
 func TestBackupParentSelection(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -328,6 +369,51 @@ func TestBackupExclude(t *testing.T) {
 		"expected file %q not in first snapshot, but it's included", "passwords.txt")
 }
 
+func TestBackupRulesFromRepo(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	datadir := filepath.Join(env.base, "testdata")
+	for _, filename := range backupExcludeFilenames {
+		fp := filepath.Join(datadir, filename)
+		rtest.OK(t, os.MkdirAll(filepath.Dir(fp), 0755))
+		rtest.OK(t, os.WriteFile(fp, []byte(filename), 0644))
+	}
+
+	save := func(excludes ...string) {
+		_, err := withCaptureStdout(func() error {
+			opts := RulesOptions{excludePatternOptions: excludePatternOptions{Excludes: excludes}}
+			return runRulesSave(context.TODO(), opts, env.gopts, []string{"corp-default"})
+		})
+		rtest.OK(t, err)
+	}
+	save("*.tar.gz")
+	save("*.tar.gz", "private", "!*.c")
+
+	snapshots := make(map[string]struct{})
+	opts := BackupOptions{RulesFromRepo: []string{"corp-default"}}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	snapshots, snapshotID := lastSnapshot(snapshots, loadSnapshotMap(t, env.gopts))
+	files := testRunLs(t, env.gopts, snapshotID)
+	for _, file := range []string{"/testdata/foo.tar.gz", "/testdata/private/secret/passwords.txt"} {
+		rtest.Assert(t, !includes(files, file), "expected file %q not in snapshot, but it's included", file)
+	}
+	rtest.Assert(t, includes(files, "/testdata/work/source/test.c"), "expected file %q in snapshot", "test.c")
+
+	opts.RulesFromRepo = []string{"corp-default@1"}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	_, snapshotID = lastSnapshot(snapshots, loadSnapshotMap(t, env.gopts))
+	files = testRunLs(t, env.gopts, snapshotID)
+	rtest.Assert(t, !includes(files, "/testdata/foo.tar.gz"), "expected file %q not in snapshot, but it's included", "foo.tar.gz")
+	rtest.Assert(t, includes(files, "/testdata/private/secret/passwords.txt"), "expected file %q in snapshot", "passwords.txt")
+
+	opts.RulesFromRepo = []string{"missing"}
+	err := testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil, "backup with missing rule set succeeded")
+}
+
 func TestBackupErrors(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		return
@@ -350,6 +436,38 @@ func TestBackupErrors(t *testing.T) {
 	testListSnapshots(t, env.gopts, 1)
 }
 
+func TestBackupErrorBudget(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+
+	inaccessibleFile := filepath.Join(env.testdata, "0", "0", "9", "0")
+	rtest.OK(t, os.Chmod(inaccessibleFile, 0000))
+	defer func() {
+		rtest.OK(t, os.Chmod(inaccessibleFile, 0644))
+	}()
+
+	// a single error is within the budget
+	opts := BackupOptions{MaxErrors: 1}
+	err := testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err == ErrInvalidSourceData, "Wrong error returned: %v", err)
+	testListSnapshots(t, env.gopts, 1)
+
+	inaccessibleFile2 := filepath.Join(env.testdata, "0", "0", "9", "1")
+	rtest.OK(t, os.Chmod(inaccessibleFile2, 0000))
+	defer func() {
+		rtest.OK(t, os.Chmod(inaccessibleFile2, 0644))
+	}()
+
+	err = testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err == ErrErrorBudgetExceeded, "Wrong error returned: %v", err)
+	testListSnapshots(t, env.gopts, 1)
+}
+
 const (
 	incrementalFirstWrite  = 10 * 1042 * 1024
 	incrementalSecondWrite = 1 * 1042 * 1024
@@ -628,6 +746,53 @@ func TestStdinFromCommandFailNoOutputAndExitCode(t *testing.T) {
 	testRunCheck(t, env.gopts)
 }
 
+func TestBackupSourcePlugin(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	db := filepath.Join(env.base, "app.db")
+	cmd := exec.Command("python", "-c", `import sqlite3, sys
+db = sqlite3.connect(sys.argv[1])
+db.execute("CREATE TABLE users (name)")
+db.execute("CREATE TABLE orders (id, user)")
+db.execute("INSERT INTO users VALUES ('alice')")
+db.commit()`, db)
+	out, err := cmd.CombinedOutput()
+	rtest.Assert(t, err == nil, "creating database failed: %v\n%s", err, out)
+
+	plugin, err := filepath.Abs(filepath.Join("..", "..", "contrib", "source-plugins", "sqlite-tables.py"))
+	rtest.OK(t, err)
+
+	opts := BackupOptions{SourcePlugin: true}
+	testRunBackup(t, "", []string{"python", plugin, db}, opts, env.gopts)
+	testRunBackup(t, "", []string{"python", plugin, db}, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	testRunCheck(t, env.gopts)
+
+	files := testRunLs(t, env.gopts, snapshotIDs[0].String())
+	rtest.Equals(t, []string{"/app", "/app/orders.csv", "/app/users.csv", ""}, files)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, snapshotIDs[0])
+	data, err := os.ReadFile(filepath.Join(restoredir, "app", "users.csv"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "name\r\nalice\r\n", string(data))
+}
+
+func TestBackupSourcePluginFail(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	opts := BackupOptions{SourcePlugin: true}
+	err := testRunBackupAssumeFailure(t, "", []string{"python", "-c", `print('{"error":"failed"}')`}, opts, env.gopts)
+	rtest.Assert(t, err != nil, "Expected error while backing up")
+	testListSnapshots(t, env.gopts, 0)
+}
+
 func TestBackupEmptyPassword(t *testing.T) {
 	// basic sanity test that empty passwords work
 	env, cleanup := withTestEnvironment(t)
@@ -656,3 +821,78 @@ func TestBackupSkipIfUnchanged(t *testing.T) {
 
 	testRunCheck(t, env.gopts)
 }
+
+func TestBackupNetworkPolicy(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+
+	// the backup is skipped on interfaces which are not allowed
+	opts := BackupOptions{AllowedInterfaces: []string{"restic-test-does-not-exist"}}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 0)
+
+	// or runs with limited upload rate
+	opts.NetworkThrottle = 100000
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+
+	testRunCheck(t, env.gopts)
+}
+
+func TestBackupCompressionLevel(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{CompressionLevel: repository.CompressionLevelBest}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+	testRunCheck(t, env.gopts)
+
+	env.gopts.Compression = repository.CompressionOff
+	err := testRunBackupAssumeFailure(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, err != nil, "--compression-level was accepted with --compression off")
+}
+
+func TestBackupCatalog(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{Catalog: true}, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+	testRunCheck(t, env.gopts)
+
+	newest, snapmap := testRunSnapshots(t, env.gopts)
+	rtest.Assert(t, newest.Catalog != nil, "snapshot has no catalog")
+	var withoutCatalog restic.ID
+	for id, sn := range snapmap {
+		if sn.Catalog == nil {
+			withoutCatalog = id
+		}
+	}
+
+	// ls and find must return the same nodes with and without catalog
+	ls := func(id restic.ID) []string {
+		return testRunLs(t, env.gopts, id.String())[1:]
+	}
+	rtest.Equals(t, ls(withoutCatalog), ls(*newest.ID))
+
+	var results []testMatches
+	rtest.OK(t, json.Unmarshal(testRunFind(t, true, env.gopts, "testfile*"), &results))
+	rtest.Equals(t, 2, len(results))
+	rtest.Assert(t, len(results[0].Matches) > 0, "no matches found")
+	rtest.Equals(t, results[0].Matches, results[1].Matches)
+
+	// prune must keep the catalog
+	testRunForget(t, env.gopts, ForgetOptions{}, withoutCatalog.String())
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0"})
+	testRunCheck(t, env.gopts)
+	var pruned []testMatches
+	rtest.OK(t, json.Unmarshal(testRunFind(t, true, env.gopts, "testfile*"), &pruned))
+	rtest.Equals(t, 1, len(pruned))
+	rtest.Equals(t, results[1].Matches, pruned[0].Matches)
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/restic/restic/internal/backend"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -110,3 +111,30 @@ func TestReadFilenamesRaw(t *testing.T) {
 	rtest.Assert(t, strings.Contains(err.Error(), "zero byte"),
 		"wrong error message: %v", err.Error())
 }
+
+func TestRemapTargets(t *testing.T) {
+	dir := rtest.TempDir(t)
+	prefix := filepath.Join(dir, "prefix")
+
+	source, targets, err := remapTargets([]string{filepath.Join(dir, "mnt")}, prefix)
+	rtest.OK(t, err)
+	rtest.Equals(t, filepath.Join(dir, "mnt"), source)
+	rtest.Equals(t, []string{prefix}, targets)
+
+	source, targets, err = remapTargets([]string{filepath.Join(dir, "mnt", "a"), filepath.Join(dir, "mnt", "b", "c")}, prefix)
+	rtest.OK(t, err)
+	rtest.Equals(t, filepath.Join(dir, "mnt"), source)
+	rtest.Equals(t, []string{filepath.Join(prefix, "a"), filepath.Join(prefix, "b", "c")}, targets)
+}
+
+func TestBackupSpaceWarning(t *testing.T) {
+	space := backend.SpaceInfo{Total: 1000, Free: 500, Available: 500}
+	rtest.Equals(t, "", backupSpaceWarning(space, 500))
+
+	msg := backupSpaceWarning(space, 501)
+	rtest.Assert(t, strings.Contains(msg, "of free space remain"), "unexpected warning %q", msg)
+
+	space.Available = 100
+	msg = backupSpaceWarning(space, 200)
+	rtest.Assert(t, strings.Contains(msg, "of quota remain"), "unexpected warning %q", msg)
+}
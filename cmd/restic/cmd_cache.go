@@ -20,7 +20,9 @@ var cmdCache = &cobra.Command{
 	Use:   "cache",
 	Short: "Operate on local cache directories",
 	Long: `
-The "cache" command allows listing and cleaning local cache directories.
+The "cache" command allows listing and cleaning local cache directories. Cache
+directories of repositories with snapshots pinned using "cache pin" are never
+considered old.
 
 EXIT STATUS
 ===========
@@ -99,15 +101,17 @@ func runCache(opts CacheOptions, gopts GlobalOptions, args []string) error {
 	tab := table.New()
 
 	type data struct {
-		ID   string
-		Last string
-		Old  string
-		Size string
+		ID     string
+		Last   string
+		Old    string
+		Pinned string
+		Size   string
 	}
 
 	tab.AddColumn("Repo ID", "{{ .ID }}")
 	tab.AddColumn("Last Used", "{{ .Last }}")
 	tab.AddColumn("Old", "{{ .Old }}")
+	tab.AddColumn("Pinned", "{{ .Pinned }}")
 
 	if !opts.NoSize {
 		tab.AddColumn("Size", "{{ .Size }}")
@@ -128,8 +132,10 @@ func runCache(opts CacheOptions, gopts GlobalOptions, args []string) error {
 	})
 
 	for _, entry := range dirs {
-		var old string
-		if cache.IsOld(entry.ModTime(), time.Duration(opts.MaxAge)*24*time.Hour) {
+		var old, pinned string
+		if cache.Pinned(filepath.Join(cachedir, entry.Name())) {
+			pinned = "yes"
+		} else if cache.IsOld(entry.ModTime(), time.Duration(opts.MaxAge)*24*time.Hour) {
 			old = "yes"
 		}
 
@@ -151,6 +157,7 @@ func runCache(opts CacheOptions, gopts GlobalOptions, args []string) error {
 			name,
 			fmt.Sprintf("%d days ago", uint(time.Since(entry.ModTime()).Hours()/24)),
 			old,
+			pinned,
 			size,
 		})
 	}
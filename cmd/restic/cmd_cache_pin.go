@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/cache"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/table"
+)
+
+var cmdCachePin = &cobra.Command{
+	Use:   "pin [flags] [snapshotID ...]",
+	Short: "Keep the metadata of snapshots in the local cache",
+	Long: `
+The "pin" sub-command downloads the metadata of the given snapshots to the local
+cache and keeps it there, such that these snapshots can be browsed and restored
+quickly even if the backend is slow or degraded. With --data, the file contents
+of the snapshots are cached as well. The cache directory of a repository which
+contains pinned snapshots is never removed as old cache directory.
+
+Without arguments, the pinned snapshots are listed along with the number of
+their pack files which are currently cached. Pack files may be replaced when
+running "prune", run "cache pin" for the snapshots again afterwards to download
+the new pack files. Use "cache unpin" to release pinned snapshots.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCachePin(cmd.Context(), cachePinOptions, globalOptions, args)
+	},
+}
+
+var cmdCacheUnpin = &cobra.Command{
+	Use:   "unpin [flags] [snapshotID ...]",
+	Short: "Release snapshots pinned in the local cache",
+	Long: `
+The "unpin" sub-command releases snapshots which were pinned using "cache pin".
+Cached file contents which are not needed by other pinned snapshots are removed
+from the cache, the metadata is kept as part of the regular cache.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheUnpin(cmd.Context(), cacheUnpinOptions, globalOptions, args)
+	},
+}
+
+// CachePinOptions collects all options for the cache pin command.
+type CachePinOptions struct {
+	restic.SnapshotFilter
+	Data bool
+}
+
+// CacheUnpinOptions collects all options for the cache unpin command.
+type CacheUnpinOptions struct {
+	All bool
+}
+
+var cachePinOptions CachePinOptions
+var cacheUnpinOptions CacheUnpinOptions
+
+func init() {
+	cmdCache.AddCommand(cmdCachePin)
+	cmdCache.AddCommand(cmdCacheUnpin)
+
+	f := cmdCachePin.Flags()
+	initMultiSnapshotFilter(f, &cachePinOptions.SnapshotFilter, true)
+	f.BoolVar(&cachePinOptions.Data, "data", false, "also cache the file contents of the snapshots")
+
+	cmdCacheUnpin.Flags().BoolVar(&cacheUnpinOptions.All, "all", false, "release all pinned snapshots")
+}
+
+// cachePin records a snapshot pinned in the cache.
+type cachePin struct {
+	Time time.Time `json:"time"`
+	// Packs lists the pack files containing the trees of the snapshot.
+	Packs restic.IDs `json:"packs"`
+	// DataPacks lists the pack files containing the file contents, if they
+	// were pinned as well.
+	DataPacks restic.IDs `json:"data_packs,omitempty"`
+}
+
+// cachePins maps the IDs of the pinned snapshots to their pins.
+type cachePins map[string]cachePin
+
+func loadCachePins(repo *repository.Repository) (cachePins, error) {
+	pins := make(cachePins)
+	err := repo.LoadCacheJSON(cache.PinFile, &pins)
+	if errors.Is(err, os.ErrNotExist) {
+		return pins, nil
+	}
+	return pins, err
+}
+
+func saveCachePins(repo *repository.Repository, pins cachePins) error {
+	if len(pins) == 0 {
+		// the cache directory is no longer exempt from removal
+		return repo.Cache.RemoveFile(cache.PinFile)
+	}
+	return repo.SaveCacheJSON(cache.PinFile, pins)
+}
+
+// snapshotPacks returns the pack files containing the trees and, if data is
+// set, the data blobs of the snapshot.
+func snapshotPacks(ctx context.Context, repo restic.Repository, sn *restic.Snapshot, data bool) (treePacks, dataPacks restic.IDSet, err error) {
+	blobs := restic.NewBlobSet()
+	err = restic.FindUsedBlobs(ctx, repo, restic.IDs{*sn.Tree}, blobs, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	treePacks, dataPacks = restic.NewIDSet(), restic.NewIDSet()
+	for h := range blobs {
+		if h.Type == restic.DataBlob && !data {
+			continue
+		}
+		pbs := repo.LookupBlob(h.Type, h.ID)
+		if len(pbs) == 0 {
+			return nil, nil, errors.Errorf("blob %v of snapshot %v not found in index", h.ID.Str(), sn.ID().Str())
+		}
+		if h.Type == restic.TreeBlob {
+			treePacks.Insert(pbs[0].PackID)
+		} else {
+			dataPacks.Insert(pbs[0].PackID)
+		}
+	}
+	return treePacks, dataPacks, nil
+}
+
+func printCachePins(stdout io.Writer, repo *repository.Repository, pins cachePins) error {
+	ids := make([]string, 0, len(pins))
+	for id := range pins {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	cached := func(packs restic.IDs) string {
+		n := 0
+		for _, id := range packs {
+			if repo.Cache.Has(backend.Handle{Type: restic.PackFile, Name: id.String()}) {
+				n++
+			}
+		}
+		return fmt.Sprintf("%d / %d", n, len(packs))
+	}
+
+	tab := table.New()
+	tab.AddColumn("ID", "{{ .ID }}")
+	tab.AddColumn("Pinned", "{{ .Time }}")
+	tab.AddColumn("Tree packs", "{{ .Packs }}")
+	tab.AddColumn("Data packs", "{{ .DataPacks }}")
+
+	type row struct {
+		ID, Time, Packs, DataPacks string
+	}
+	for _, id := range ids {
+		pin := pins[id]
+		r := row{ID: id[:8], Time: pin.Time.Local().Format(TimeFormat), Packs: cached(pin.Packs)}
+		if pin.DataPacks != nil {
+			r.DataPacks = cached(pin.DataPacks)
+		}
+		tab.AddRow(r)
+	}
+	tab.AddFooter(fmt.Sprintf("%d snapshots pinned", len(pins)))
+
+	return tab.Write(stdout)
+}
+
+func runCachePin(ctx context.Context, opts CachePinOptions, gopts GlobalOptions, args []string) error {
+	if gopts.NoCache {
+		return errors.Fatal("the cache is disabled")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if repo.Cache == nil {
+		return errors.Fatal("no cache in use")
+	}
+	pins, err := loadCachePins(repo)
+	if err != nil {
+		return errors.Fatalf("unable to load pinned snapshots: %v", err)
+	}
+
+	if len(args) == 0 && opts.SnapshotFilter.Empty() {
+		if gopts.JSON {
+			return json.NewEncoder(globalOptions.stdout).Encode(pins)
+		}
+		return printCachePins(globalOptions.stdout, repo, pins)
+	}
+
+	var snapshots restic.Snapshots
+	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
+		snapshots = append(snapshots, sn)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(snapshots) == 0 {
+		return errors.Fatal("no snapshots to pin")
+	}
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err = repo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	packs := restic.NewIDSet()
+	for _, sn := range snapshots {
+		treePacks, dataPacks, err := snapshotPacks(ctx, repo, sn, opts.Data)
+		if err != nil {
+			return err
+		}
+		pin := cachePin{Time: time.Now(), Packs: treePacks.List()}
+		if opts.Data {
+			pin.DataPacks = dataPacks.List()
+		} else if old, ok := pins[sn.ID().String()]; ok {
+			// keep the data of a snapshot which was pinned including data
+			pin.DataPacks = old.DataPacks
+		}
+		pins[sn.ID().String()] = pin
+
+		packs.Merge(treePacks)
+		packs.Merge(dataPacks)
+	}
+
+	Verbosef("caching %d pack files of %d snapshots\n", len(packs), len(snapshots))
+	p := newProgressMax(!gopts.Quiet && !gopts.JSON, uint64(len(packs)), "pack files cached")
+	err = repo.CachePacks(ctx, packs, p)
+	if err != nil {
+		return err
+	}
+
+	if err := saveCachePins(repo, pins); err != nil {
+		return errors.Fatalf("unable to save pinned snapshots: %v", err)
+	}
+	Verbosef("pinned %d snapshots\n", len(snapshots))
+	return nil
+}
+
+func runCacheUnpin(ctx context.Context, opts CacheUnpinOptions, gopts GlobalOptions, args []string) error {
+	if len(args) == 0 && !opts.All {
+		return errors.Fatal("specify the snapshots to unpin or use --all")
+	}
+	if gopts.NoCache {
+		return errors.Fatal("the cache is disabled")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if repo.Cache == nil {
+		return errors.Fatal("no cache in use")
+	}
+	pins, err := loadCachePins(repo)
+	if err != nil {
+		return errors.Fatalf("unable to load pinned snapshots: %v", err)
+	}
+
+	released := make(cachePins)
+	for id, pin := range pins {
+		if opts.All {
+			released[id] = pin
+		}
+	}
+	for _, arg := range args {
+		// pinned snapshots may no longer exist in the repository, thus only
+		// match against the IDs of the pins
+		found := false
+		for id, pin := range pins {
+			if len(arg) <= len(id) && id[:len(arg)] == arg {
+				released[id] = pin
+				found = true
+			}
+		}
+		if !found {
+			Warnf("snapshot %v is not pinned\n", arg)
+		}
+	}
+
+	for id := range released {
+		delete(pins, id)
+	}
+
+	// remove the data of released snapshots unless it is still pinned
+	keep := restic.NewIDSet()
+	for _, pin := range pins {
+		keep.Merge(restic.NewIDSet(pin.DataPacks...))
+		keep.Merge(restic.NewIDSet(pin.Packs...))
+	}
+	removed := 0
+	for _, pin := range released {
+		for _, id := range pin.DataPacks {
+			if keep.Has(id) {
+				continue
+			}
+			if err := repo.Cache.Discard(backend.Handle{Type: restic.PackFile, Name: id.String()}); err != nil {
+				Warnf("unable to remove pack %v from the cache: %v\n", id.Str(), err)
+				continue
+			}
+			keep.Insert(id)
+			removed++
+		}
+	}
+
+	if err := saveCachePins(repo, pins); err != nil {
+		return errors.Fatalf("unable to save pinned snapshots: %v", err)
+	}
+	Verbosef("released %d snapshots, removed %d pack files from the cache\n", len(released), removed)
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/backend/cache"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunCachePin(t testing.TB, gopts GlobalOptions, opts CachePinOptions, args []string) {
+	rtest.OK(t, runCachePin(context.TODO(), opts, gopts, args))
+}
+
+func testRunCachePinList(t testing.TB, gopts GlobalOptions) cachePins {
+	gopts.JSON = true
+	buf, err := withCaptureStdout(func() error {
+		return runCachePin(context.TODO(), CachePinOptions{}, gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var pins cachePins
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &pins))
+	return pins
+}
+
+func TestCachePin(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	testRunCachePin(t, env.gopts, CachePinOptions{Data: true}, []string{snapshotID.String()})
+	pins := testRunCachePinList(t, env.gopts)
+	rtest.Equals(t, 1, len(pins))
+	pin, ok := pins[snapshotID.String()]
+	rtest.Assert(t, ok, "snapshot %v is not pinned", snapshotID.Str())
+	rtest.Assert(t, len(pin.Packs) > 0 && len(pin.DataPacks) > 0, "expected tree and data packs, got %v", pin)
+
+	cacheDirs, err := cache.All(env.cache)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(cacheDirs))
+	cacheDir := filepath.Join(env.cache, cacheDirs[0].Name())
+	rtest.Assert(t, cache.Pinned(cacheDir), "cache directory is not pinned")
+
+	// all data must be available from the cache
+	rtest.OK(t, os.RemoveAll(filepath.Join(env.repo, "data")))
+	target := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, target, snapshotID)
+	diff := directoriesContentsDiff(env.testdata, filepath.Join(target, "testdata"))
+	rtest.Assert(t, diff == "", "restored files differ from the original:\n%v", diff)
+
+	rtest.OK(t, runCacheUnpin(context.TODO(), CacheUnpinOptions{All: true}, env.gopts, nil))
+	rtest.Equals(t, 0, len(testRunCachePinList(t, env.gopts)))
+	rtest.Assert(t, !cache.Pinned(cacheDir), "cache directory is still pinned")
+}
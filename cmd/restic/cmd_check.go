@@ -4,6 +4,7 @@ import (
 	"context"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +19,7 @@ import (
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/event"
 	"github.com/restic/restic/internal/ui/progress"
 	"github.com/restic/restic/internal/ui/termstatus"
 )
@@ -32,6 +34,18 @@ finds. It can also be used to read all data and therefore simulate a restore.
 By default, the "check" command will always load all data directly from the
 repository and not use a local cache.
 
+The "--read-data-budget" option limits the amount of pack data downloaded by a
+single run. Packs which were never verified before are read first, followed by
+the packs whose last verification is the oldest. The set of verified packs is
+stored in the local cache directory, such that repeated runs eventually cover
+the whole repository.
+
+The "--manifests" option only verifies that the pack files listed in the
+manifests of the snapshots, which record the pack files uploaded by each
+backup, still exist in the repository and have the expected size. This only
+lists the pack files in the repository and thus quickly detects pack files
+deleted from the storage. Pack files removed by prune are ignored.
+
 EXIT STATUS
 ===========
 
@@ -52,8 +66,10 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 type CheckOptions struct {
 	ReadData       bool
 	ReadDataSubset string
+	ReadDataBudget string
 	CheckUnused    bool
 	WithCache      bool
+	Manifests      bool
 }
 
 var checkOptions CheckOptions
@@ -64,6 +80,7 @@ func init() {
 	f := cmdCheck.Flags()
 	f.BoolVar(&checkOptions.ReadData, "read-data", false, "read all data blobs")
 	f.StringVar(&checkOptions.ReadDataSubset, "read-data-subset", "", "read a `subset` of data packs, specified as 'n/t' for specific part, or either 'x%' or 'x.y%' or a size in bytes with suffixes k/K, m/M, g/G, t/T for a random subset")
+	f.StringVar(&checkOptions.ReadDataBudget, "read-data-budget", "", "read at most `size` bytes of data packs per run (with suffixes k/K, m/M, g/G, t/T), preferring packs that were not verified recently")
 	var ignored bool
 	f.BoolVar(&ignored, "check-unused", false, "find unused blobs")
 	err := f.MarkDeprecated("check-unused", "`--check-unused` is deprecated and will be ignored")
@@ -72,12 +89,28 @@ func init() {
 		panic(err)
 	}
 	f.BoolVar(&checkOptions.WithCache, "with-cache", false, "use existing cache, only read uncached data from repository")
+	f.BoolVar(&checkOptions.Manifests, "manifests", false, "only check that the pack files listed in the snapshot manifests exist and have the expected size")
 }
 
 func checkFlags(opts CheckOptions) error {
+	if opts.Manifests && (opts.ReadData || opts.ReadDataSubset != "" || opts.ReadDataBudget != "") {
+		return errors.Fatal("check flag --manifests cannot be used together with --read-data, --read-data-subset or --read-data-budget")
+	}
 	if opts.ReadData && opts.ReadDataSubset != "" {
 		return errors.Fatal("check flags --read-data and --read-data-subset cannot be used together")
 	}
+	if opts.ReadDataBudget != "" {
+		if opts.ReadData || opts.ReadDataSubset != "" {
+			return errors.Fatal("check flag --read-data-budget cannot be used together with --read-data or --read-data-subset")
+		}
+		budget, err := ui.ParseBytes(opts.ReadDataBudget)
+		if err != nil {
+			return errors.Fatal("check flag --read-data-budget has invalid value, please see documentation")
+		}
+		if budget <= 0 {
+			return errors.Fatal("check flag --read-data-budget=n n must be above 0")
+		}
+	}
 	if opts.ReadDataSubset != "" {
 		dataSubset, err := stringToIntSlice(opts.ReadDataSubset)
 		argumentError := errors.Fatal("check flag --read-data-subset has invalid value, please see documentation")
@@ -210,14 +243,47 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	}
 
 	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	events := newEventPrinter("check", gopts, term)
+	if events != nil {
+		printer = events
+	}
+	// warn reports non-critical problems
+	warn := func(msg string) {
+		if events != nil {
+			events.Warn(msg)
+		} else {
+			term.Print(msg)
+		}
+	}
+	newProgress := func(max uint64, description string) *progress.Counter {
+		if events != nil {
+			bar := events.NewCounter(description)
+			bar.SetMax(max)
+			return bar
+		}
+		return newTerminalProgressMax(!gopts.Quiet, max, description, term)
+	}
+	summary := &checkSummary{}
+	printSummary := func(errorsFound bool) {
+		if events != nil {
+			summary.ErrorsFound = errorsFound
+			events.Summary(summary)
+		}
+	}
+
+	// the coverage state must be stored in the persistent cache directory,
+	// thus determine it before a temporary cache is set up
+	coverageBaseDir := gopts.CacheDir
 
 	cleanup := prepareCheckCache(opts, &gopts, printer)
 	defer cleanup()
 
-	if !gopts.NoLock {
+	// check never modifies the repository, thus skip locking in read-only mode
+	noLock := gopts.NoLock || gopts.ReadOnly
+	if !noLock {
 		printer.P("create exclusive lock for repository\n")
 	}
-	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, gopts.NoLock)
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, noLock)
 	if err != nil {
 		return err
 	}
@@ -231,6 +297,9 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 
 	printer.P("load indexes\n")
 	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	if events != nil {
+		bar = events.NewCounter("index files loaded")
+	}
 	hints, errs := chkr.LoadIndex(ctx, bar)
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -243,14 +312,14 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	for _, hint := range hints {
 		switch hint.(type) {
 		case *checker.ErrDuplicatePacks:
-			term.Print(hint.Error())
+			warn(hint.Error())
 			suggestIndexRebuild = true
 		case *checker.ErrOldIndexFormat:
 			printer.E("error: %v\n", hint)
 			suggestLegacyIndexRebuild = true
 			errorsFound = true
 		case *checker.ErrMixedPack:
-			term.Print(hint.Error())
+			warn(hint.Error())
 			mixedFound = true
 		default:
 			printer.E("error: %v\n", hint)
@@ -259,13 +328,13 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	}
 
 	if suggestIndexRebuild {
-		term.Print("Duplicate packs are non-critical, you can run `restic repair index' to correct this.\n")
+		warn("Duplicate packs are non-critical, you can run `restic repair index' to correct this.\n")
 	}
 	if suggestLegacyIndexRebuild {
 		printer.E("Found indexes using the legacy format, you must run `restic repair index' to correct this.\n")
 	}
 	if mixedFound {
-		term.Print("Mixed packs with tree and data blobs are non-critical, you can run `restic prune` to correct this.\n")
+		warn("Mixed packs with tree and data blobs are non-critical, you can run `restic prune` to correct this.\n")
 	}
 
 	if len(errs) > 0 {
@@ -275,6 +344,25 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		return errors.Fatal("LoadIndex returned errors")
 	}
 
+	if opts.Manifests {
+		printer.P("check snapshot manifests\n")
+		errChan := make(chan error)
+		go chkr.Manifests(ctx, errChan)
+		for err := range errChan {
+			errorsFound = true
+			printer.E("%v\n", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		printSummary(errorsFound)
+		if errorsFound {
+			return errors.Fatal("repository contains errors")
+		}
+		printer.P("no errors were found\n")
+		return nil
+	}
+
 	orphanedPacks := 0
 	errChan := make(chan error)
 
@@ -284,6 +372,7 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	for err := range errChan {
 		if checker.IsOrphanedPack(err) {
 			orphanedPacks++
+			summary.OrphanedPacks++
 			printer.P("%v\n", err)
 		} else if err == checker.ErrLegacyLayout {
 			printer.P("repository still uses the S3 legacy layout\nPlease run `restic migrate s3legacy` to correct this.\n")
@@ -307,7 +396,7 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		bar := newTerminalProgressMax(!gopts.Quiet, 0, "snapshots", term)
+		bar := newProgress(0, "snapshots")
 		defer bar.Done()
 		chkr.Structure(ctx, bar, errChan)
 	}()
@@ -339,25 +428,32 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 		}
 		for _, id := range unused {
 			printer.P("unused blob %v\n", id)
+			summary.UnusedBlobs++
 			errorsFound = true
 		}
 	}
 
-	doReadData := func(packs map[restic.ID]int64) {
+	// doReadData reads the packs and returns the IDs of the packs which were
+	// verified successfully. It returns nil if errors occurred which cannot be
+	// attributed to a particular pack.
+	doReadData := func(packs map[restic.ID]int64) restic.IDs {
 		packCount := uint64(len(packs))
 
-		p := newTerminalProgressMax(!gopts.Quiet, packCount, "packs", term)
+		p := newProgress(packCount, "packs")
 		errChan := make(chan error)
 
 		go chkr.ReadPacks(ctx, packs, p, errChan)
 
 		var salvagePacks restic.IDs
+		unattributedErrors := false
 
 		for err := range errChan {
 			errorsFound = true
 			printer.E("%v\n", err)
 			if err, ok := err.(*repository.ErrPackData); ok {
 				salvagePacks = append(salvagePacks, err.PackID)
+			} else {
+				unattributedErrors = true
 			}
 		}
 		p.Done()
@@ -368,9 +464,23 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 			for _, id := range salvagePacks {
 				strIDs = append(strIDs, id.String())
 			}
+			summary.DamagedPacks = append(summary.DamagedPacks, strIDs...)
 			printer.E("restic repair packs %v\nrestic repair snapshots --forget\n\n", strings.Join(strIDs, " "))
 			printer.E("Corrupted blobs are either caused by hardware problems or bugs in restic. Please open an issue at https://github.com/restic/restic/issues/new/choose for further troubleshooting!\n")
 		}
+
+		if unattributedErrors || ctx.Err() != nil {
+			return nil
+		}
+
+		damaged := restic.NewIDSet(salvagePacks...)
+		var verified restic.IDs
+		for id := range packs {
+			if !damaged.Has(id) {
+				verified = append(verified, id)
+			}
+		}
+		return verified
 	}
 
 	switch {
@@ -412,12 +522,19 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 			return errors.Fatal("internal error: failed to select packs to check")
 		}
 		doReadData(packs)
+	case opts.ReadDataBudget != "":
+		budget, _ := ui.ParseBytes(opts.ReadDataBudget)
+		err := readDataWithBudget(ctx, repo.Config().ID, coverageBaseDir, chkr.GetPacks(), budget, printer, doReadData)
+		if err != nil {
+			return err
+		}
 	}
 
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
+	printSummary(errorsFound)
 	if errorsFound {
 		return errors.Fatal("repository contains errors")
 	}
@@ -426,6 +543,59 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts GlobalOptions, args
 	return nil
 }
 
+// checkSummary is the summary event of the check command.
+type checkSummary struct {
+	event.Header
+	ErrorsFound   bool     `json:"errors_found"`
+	OrphanedPacks int      `json:"orphaned_packs"`
+	UnusedBlobs   int      `json:"unused_blobs"`
+	DamagedPacks  []string `json:"damaged_packs,omitempty"`
+}
+
+// readDataWithBudget selects packs for reading according to the coverage
+// state stored in the cache directory and updates the state afterwards.
+func readDataWithBudget(ctx context.Context, repoID string, cacheDir string, allPacks map[restic.ID]int64, budget int64,
+	printer progress.Printer, readData func(map[restic.ID]int64) restic.IDs) error {
+
+	if cacheDir == "" {
+		var err error
+		cacheDir, err = cache.DefaultDir()
+		if err != nil {
+			return errors.Fatalf("unable to determine the cache directory to store the check coverage: %v", err)
+		}
+	}
+	filename := filepath.Join(cacheDir, repoID, "check-coverage.json")
+
+	cov, err := checker.LoadCoverage(filename)
+	if err != nil {
+		printer.E("unable to load check coverage from %v, starting from scratch: %v\n", filename, err)
+		cov = checker.NewCoverage()
+	}
+	cov.Forget(allPacks)
+
+	packs := checker.SelectPacksByBudget(allPacks, cov, budget)
+	var size int64
+	for _, s := range packs {
+		size += s
+	}
+	printer.P("read %d of %d data packs (%s of budget %s), %d packs were verified before\n",
+		len(packs), len(allPacks), ui.FormatBytes(uint64(size)), ui.FormatBytes(uint64(budget)), len(cov.Packs))
+
+	verified := readData(packs)
+	if verified == nil {
+		printer.E("check coverage was not updated due to errors\n")
+		return nil
+	}
+
+	cov.Record(verified, time.Now())
+	err = cov.Save(filename)
+	if err != nil {
+		printer.E("unable to save check coverage: %v\n", err)
+	}
+	printer.P("%d of %d data packs have been verified so far\n", len(cov.Packs), len(allPacks))
+	return nil
+}
+
 // selectPacksByBucket selects subsets of packs by ranges of buckets.
 func selectPacksByBucket(allPacks map[restic.ID]int64, bucket, totalBuckets uint) map[restic.ID]int64 {
 	packs := make(map[restic.ID]int64)
@@ -3,9 +3,14 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/event"
 	"github.com/restic/restic/internal/ui/termstatus"
 )
 
@@ -36,3 +41,70 @@ func testRunCheckOutput(gopts GlobalOptions, checkUnused bool) (string, error) {
 	})
 	return buf.String(), err
 }
+
+func testRunCheckManifests(gopts GlobalOptions) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	gopts.stdout = buf
+	gopts.stderr = buf
+	err := withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runCheck(context.TODO(), CheckOptions{Manifests: true}, gopts, nil, term)
+	})
+	return buf.String(), err
+}
+
+func TestCheckManifests(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	ctx, repo, unlock, err := openWithReadLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	sn, err := restic.LoadSnapshot(ctx, repo, snapshotIDs[0])
+	unlock()
+	rtest.OK(t, err)
+
+	manifestPacks := restic.NewIDSet()
+	for _, pack := range sn.Manifest {
+		manifestPacks.Insert(pack.ID)
+	}
+	rtest.Assert(t, manifestPacks.Equals(listPacks(env.gopts, t)), "manifest %v does not match the packs in the repository", sn.Manifest)
+
+	_, err = testRunCheckManifests(env.gopts)
+	rtest.OK(t, err)
+
+	removed := sn.Manifest[0].ID
+	removePacks(env.gopts, t, restic.NewIDSet(removed))
+	output, err := testRunCheckManifests(env.gopts)
+	rtest.Assert(t, err != nil, "expected error after removing a pack listed in the manifest")
+	rtest.Assert(t, strings.Contains(output, removed.String()), "missing pack %v not reported in output %q", removed, output)
+}
+
+func TestCheckJSON(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+
+	gopts := env.gopts
+	gopts.JSON = true
+	output, err := testRunCheckOutput(gopts, false)
+	rtest.OK(t, err)
+
+	var summary *checkSummary
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var header event.Header
+		rtest.OK(t, json.Unmarshal([]byte(line), &header))
+		rtest.Equals(t, event.SchemaVersion, header.SchemaVersion)
+		rtest.Equals(t, "check", header.Command)
+		if header.MessageType == "summary" {
+			summary = &checkSummary{}
+			rtest.OK(t, json.Unmarshal([]byte(line), summary))
+		}
+	}
+	rtest.Assert(t, summary != nil, "missing summary in output %q", output)
+	rtest.Assert(t, !summary.ErrorsFound, "unexpected errors in summary %v", summary)
+}
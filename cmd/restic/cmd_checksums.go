@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/walker"
+)
+
+var cmdChecksums = &cobra.Command{
+	Use:   "checksums [flags] snapshotID[:path]",
+	Short: "Print a checksum manifest of the files in a snapshot",
+	Long: `
+The "checksums" command prints the SHA-256 checksums of all files in a snapshot
+in a format which can be verified using standard tools. With the default format
+"sha256sum", the output can be checked using "sha256sum --check", the format
+"bsd" is understood by "shasum --check" and the BSD "sha256 -c".
+
+The paths are relative to the root of the snapshot, or to the given subfolder
+when using the "<snapshotID>:<subfolder>" syntax. This matches the layout of
+the files after restoring the snapshot into a target directory.
+
+No data has to be downloaded for files which were backed up by a version of
+restic that records the SHA-256 hash of each file, and for files consisting of
+a single blob, as the checksum is then equal to the blob ID. The contents of
+all other files are loaded from the repository to compute their checksum.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runChecksums(cmd.Context(), checksumsOptions, globalOptions, args)
+	},
+}
+
+// ChecksumsOptions collects all options for the checksums command.
+type ChecksumsOptions struct {
+	restic.SnapshotFilter
+	Format string
+}
+
+var checksumsOptions ChecksumsOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdChecksums)
+
+	f := cmdChecksums.Flags()
+	initSingleSnapshotFilter(f, &checksumsOptions.SnapshotFilter)
+	f.StringVar(&checksumsOptions.Format, "format", "sha256sum", "output `format`, either \"sha256sum\" or \"bsd\"")
+}
+
+// fileChecksum is the checksum of a file in a snapshot.
+type fileChecksum struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// formatChecksum returns the manifest line for the file. As done by the GNU
+// coreutils, backslashes and newlines in the path are escaped and the line is
+// prefixed with a backslash in this case.
+func formatChecksum(format string, c fileChecksum) string {
+	p := c.Path
+	prefix := ""
+	if strings.ContainsAny(p, "\\\n\r") {
+		p = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r").Replace(p)
+		prefix = "\\"
+	}
+
+	if format == "bsd" {
+		return fmt.Sprintf("%sSHA256 (%s) = %s", prefix, p, c.SHA256)
+	}
+	return fmt.Sprintf("%s%s  %s", prefix, c.SHA256, p)
+}
+
+// nodeSHA256 returns the SHA-256 checksum of the content of the file node. The
+// hash stored in the node is used if available. The IDs of data blobs are the
+// SHA-256 of their content, thus files consisting of a single blob do not have
+// to be loaded either.
+func nodeSHA256(ctx context.Context, repo restic.BlobLoader, node *restic.Node) (string, error) {
+	if node.ContentSHA256 != nil {
+		return node.ContentSHA256.String(), nil
+	}
+	if len(node.Content) == 1 {
+		return node.Content[0].String(), nil
+	}
+
+	h := sha256.New()
+	_, err := io.Copy(h, &blobReader{ctx: ctx, repo: repo, ids: node.Content})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func runChecksums(ctx context.Context, opts ChecksumsOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("specify exactly one snapshot ID")
+	}
+	switch opts.Format {
+	case "sha256sum", "bsd":
+	default:
+		return errors.Fatalf("unknown format %q, must be \"sha256sum\" or \"bsd\"", opts.Format)
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sn, subfolder, err := (&restic.SnapshotFilter{
+		Hosts: opts.Hosts,
+		Paths: opts.Paths,
+		Tags:  opts.Tags,
+	}).FindLatest(ctx, repo, repo, args[0])
+	if err != nil {
+		return errors.Fatalf("failed to find snapshot: %v", err)
+	}
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err = repo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	tree, err := restic.FindTreeDirectory(ctx, repo, sn.Tree, subfolder)
+	if err != nil {
+		return err
+	}
+
+	type hashTask struct {
+		path string
+		node *restic.Node
+		out  chan<- string
+	}
+	type printTask struct {
+		path string
+		sum  <-chan string
+	}
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	hashCh := make(chan hashTask)
+	// allows one file per worker to be hashed while the output is written
+	printCh := make(chan printTask, repo.Connections()*2)
+
+	wg.Go(func() error {
+		defer close(hashCh)
+		defer close(printCh)
+		return walker.Walk(wgCtx, repo, *tree, walker.WalkVisitor{
+			ProcessNode: func(_ restic.ID, nodepath string, node *restic.Node, err error) error {
+				if err != nil {
+					return err
+				}
+				if node == nil || node.Type != "file" {
+					return nil
+				}
+
+				ch := make(chan string, 1)
+				p := strings.TrimPrefix(path.Clean(nodepath), "/")
+				select {
+				case hashCh <- hashTask{path: p, node: node, out: ch}:
+				case <-wgCtx.Done():
+					return wgCtx.Err()
+				}
+				select {
+				case printCh <- printTask{path: p, sum: ch}:
+				case <-wgCtx.Done():
+					return wgCtx.Err()
+				}
+				return nil
+			},
+		})
+	})
+
+	for i := uint(0); i < repo.Connections(); i++ {
+		wg.Go(func() error {
+			for task := range hashCh {
+				sum, err := nodeSHA256(wgCtx, repo, task.node)
+				if err != nil {
+					return errors.Fatalf("unable to compute checksum of %v: %v", task.path, err)
+				}
+				task.out <- sum
+			}
+			return nil
+		})
+	}
+
+	wg.Go(func() error {
+		enc := json.NewEncoder(globalOptions.stdout)
+		for task := range printCh {
+			var sum string
+			select {
+			case sum = <-task.sum:
+			case <-wgCtx.Done():
+				return wgCtx.Err()
+			}
+
+			c := fileChecksum{Path: task.path, SHA256: sum}
+			if gopts.JSON {
+				if err := enc.Encode(c); err != nil {
+					return err
+				}
+				continue
+			}
+			Printf("%s\n", formatChecksum(opts.Format, c))
+		}
+		return nil
+	})
+
+	return wg.Wait()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunChecksums(t testing.TB, gopts GlobalOptions, opts ChecksumsOptions, snapshotID string) string {
+	buf, err := withCaptureStdout(func() error {
+		return runChecksums(context.TODO(), opts, gopts, []string{snapshotID})
+	})
+	rtest.OK(t, err)
+	return buf.String()
+}
+
+func TestChecksums(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	dir := filepath.Join(env.base, "data")
+	rtest.OK(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	files := map[string][]byte{
+		"empty":     {},
+		"small":     []byte("small file"),
+		"sub/large": rtest.Random(23, 8*1024*1024),
+	}
+	for name, content := range files {
+		rtest.OK(t, os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), content, 0600))
+	}
+	testRunBackup(t, "", []string{dir}, BackupOptions{}, env.gopts)
+
+	root, err := snapshotPath(dir)
+	rtest.OK(t, err)
+	sum := func(name string) string {
+		h := sha256.Sum256(files[name])
+		return hex.EncodeToString(h[:])
+	}
+
+	out := testRunChecksums(t, env.gopts, ChecksumsOptions{Format: "sha256sum"}, "latest:"+root)
+	rtest.Equals(t, sum("empty")+"  empty\n"+sum("small")+"  small\n"+sum("sub/large")+"  sub/large\n", out)
+
+	out = testRunChecksums(t, env.gopts, ChecksumsOptions{Format: "bsd"}, "latest")
+	prefix := strings.TrimPrefix(root, "/") + "/"
+	rtest.Equals(t, "SHA256 ("+prefix+"small) = "+sum("small")+"\n", strings.SplitAfter(out, "\n")[1])
+}
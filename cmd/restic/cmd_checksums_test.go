@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestFormatChecksum(t *testing.T) {
+	c := fileChecksum{Path: "dir/file", SHA256: "abc"}
+	rtest.Equals(t, "abc  dir/file", formatChecksum("sha256sum", c))
+	rtest.Equals(t, "SHA256 (dir/file) = abc", formatChecksum("bsd", c))
+
+	c.Path = "new\nline\\"
+	rtest.Equals(t, `\abc  new\nline\\`, formatChecksum("sha256sum", c))
+	rtest.Equals(t, `\SHA256 (new\nline\\) = abc`, formatChecksum("bsd", c))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdCompat = &cobra.Command{
+	Use:   "compat",
+	Short: "Check compatibility with upstream restic",
+	Long: `
+The "compat" command helps to keep repositories readable by upstream restic.
+Repositories which are created with "init --restic-compat" or marked using
+"migrate --restic-compat" only store data in formats upstream restic can read.
+	`,
+}
+
+func init() {
+	cmdRoot.AddCommand(cmdCompat)
+}
+
+// checkResticCompat returns an error if the repository is restricted to
+// formats readable by upstream restic, as option stores data upstream restic
+// cannot read.
+func checkResticCompat(repo restic.Repository, option string) error {
+	if repo.Config().ResticCompat {
+		return errors.Fatalf("%v cannot be used, the repository is restricted to formats readable by upstream restic", option)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+var cmdCompatCheck = &cobra.Command{
+	Use:   "check",
+	Short: "Report data which upstream restic cannot read",
+	Long: `
+The "check" sub-command reports all data in the repository which is specific to
+this fork of restic. It checks the repository config, all snapshots including
+those in the trash, and the metadata of all files and directories.
+
+The data is reported in two groups. Upstream restic cannot read a repository
+using a newer format version or features, and restores wrong data for files
+saved as raw EFS backup stream or as offline stub. Snapshots in the trash are
+listed as regular snapshots by upstream restic, and snapshots copied using
+"copy --trees-only" cannot be restored as their file contents are missing.
+All other data, like the POSIX ACLs, SELinux contexts and file flags stored as
+generic attributes, is ignored by upstream restic.
+
+Files and directories are counted once for each distinct directory in the
+repository, even if the directory is part of several snapshots.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if upstream restic can read all data in the repository, and
+non-zero if data was found which upstream restic cannot read or if there was
+any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCompatCheck(cmd.Context(), globalOptions, args)
+	},
+}
+
+func init() {
+	cmdCompat.AddCommand(cmdCompatCheck)
+}
+
+// compatFinding is data of a kind which is specific to this fork.
+type compatFinding struct {
+	// Kind is one of "config", "snapshot" and "node".
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Count       uint64 `json:"count"`
+	// Incompatible is set if upstream restic cannot read the data, otherwise
+	// the data is ignored by upstream restic.
+	Incompatible bool `json:"incompatible"`
+}
+
+// compatReport is the result of the compat check command.
+type compatReport struct {
+	Version      uint            `json:"version"`
+	ResticCompat bool            `json:"restic_compat"`
+	Compatible   bool            `json:"compatible"`
+	Findings     []compatFinding `json:"findings"`
+
+	index map[string]int
+}
+
+func (r *compatReport) add(kind, name, description string, incompatible bool) {
+	key := kind + "/" + name
+	if i, ok := r.index[key]; ok {
+		r.Findings[i].Count++
+		return
+	}
+	if r.index == nil {
+		r.index = make(map[string]int)
+	}
+	r.index[key] = len(r.Findings)
+	r.Findings = append(r.Findings, compatFinding{
+		Kind:         kind,
+		Name:         name,
+		Description:  description,
+		Count:        1,
+		Incompatible: incompatible,
+	})
+}
+
+// checkConfig adds the parts of the config upstream restic cannot read.
+func (r *compatReport) checkConfig(cfg restic.Config) {
+	if cfg.Version > restic.ResticCompatRepoVersion {
+		r.add("config", "version", fmt.Sprintf("repository format version %v", cfg.Version), true)
+	}
+	for _, feature := range cfg.Features {
+		r.add("config", "feature "+feature, fmt.Sprintf("repository feature %v", feature), true)
+	}
+	if len(cfg.CompressionDictionaries) > 0 {
+		r.add("config", "compression_dictionaries", "compression dictionaries", true)
+	}
+}
+
+// checkSnapshot adds the fields of the snapshot which are specific to this
+// fork.
+func (r *compatReport) checkSnapshot(sn *restic.Snapshot) {
+	if sn.Trashed != nil {
+		r.add("snapshot", "trashed", "snapshots in the trash, listed as regular snapshots by upstream restic", true)
+	}
+	if sn.Remote != nil {
+		r.add("snapshot", "remote", "snapshots without file contents, copied using --trees-only", true)
+	}
+	if sn.Catalog != nil {
+		r.add("snapshot", "catalog", "snapshots with catalog, its blobs are removed by prune of upstream restic", true)
+	}
+	if sn.Expires != nil {
+		r.add("snapshot", "expires", "snapshots with expiry date", false)
+	}
+	if len(sn.Notes) > 0 {
+		r.add("snapshot", "notes", "snapshots with notes", false)
+	}
+	if len(sn.Manifest) > 0 {
+		r.add("snapshot", "manifest", "snapshots with pack manifest", false)
+	}
+	if len(sn.Volumes) > 0 {
+		r.add("snapshot", "volumes", "snapshots with volume information", false)
+	}
+	if len(sn.ChangeJournals) > 0 {
+		r.add("snapshot", "change_journals", "snapshots with change journal positions", false)
+	}
+}
+
+// checkNode adds the data of the node which is specific to this fork.
+func (r *compatReport) checkNode(node *restic.Node) {
+	if node.EFSRaw() != nil {
+		r.add("node", string(restic.TypeEFSRaw), "files saved as raw EFS backup stream", true)
+	}
+	if node.OfflineStub() != nil {
+		r.add("node", string(restic.TypeOfflineStub), "offline files saved without content", true)
+	}
+	if node.ContentSHA256 != nil {
+		r.add("node", "content_sha256", "files with content hash", false)
+	}
+	for t := range node.GenericAttributes {
+		if restic.IsResticGenericAttribute(t) || t == restic.TypeEFSRaw || t == restic.TypeOfflineStub {
+			continue
+		}
+		r.add("node", string(t), fmt.Sprintf("files and directories with generic attribute %v", t), false)
+	}
+}
+
+// finish sorts the findings and determines whether upstream restic can read
+// the repository.
+func (r *compatReport) finish() {
+	kinds := map[string]int{"config": 0, "snapshot": 1, "node": 2}
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.Kind != b.Kind {
+			return kinds[a.Kind] < kinds[b.Kind]
+		}
+		if a.Incompatible != b.Incompatible {
+			return a.Incompatible
+		}
+		return a.Name < b.Name
+	})
+
+	r.Compatible = true
+	for _, f := range r.Findings {
+		if f.Incompatible {
+			r.Compatible = false
+		}
+	}
+	if r.Findings == nil {
+		r.Findings = []compatFinding{}
+	}
+}
+
+// checkRepoCompat returns the report for all data in the repository.
+func checkRepoCompat(ctx context.Context, repo restic.Repository, snapshotLister restic.Lister) (*compatReport, error) {
+	cfg := repo.Config()
+	report := &compatReport{
+		Version:      cfg.Version,
+		ResticCompat: cfg.ResticCompat,
+	}
+	report.checkConfig(cfg)
+
+	var trees restic.IDs
+	err := restic.ForAllSnapshots(ctx, snapshotLister, repo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			return errors.Fatalf("failed to load snapshot %v: %v", id.Str(), err)
+		}
+		report.checkSnapshot(sn)
+		if sn.Tree != nil {
+			trees = append(trees, *sn.Tree)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	visited := restic.NewIDSet()
+	wg, wgCtx := errgroup.WithContext(ctx)
+	treeStream := restic.StreamTrees(wgCtx, wg, repo, trees, func(treeID restic.ID) bool {
+		seen := visited.Has(treeID)
+		visited.Insert(treeID)
+		return seen
+	}, nil)
+	wg.Go(func() error {
+		for tree := range treeStream {
+			if tree.Error != nil {
+				return errors.Fatalf("failed to load tree %v: %v", tree.ID.Str(), tree.Error)
+			}
+			for _, node := range tree.Nodes {
+				report.checkNode(node)
+			}
+		}
+		return nil
+	})
+	if err := wg.Wait(); err != nil {
+		return nil, err
+	}
+
+	report.finish()
+	return report, nil
+}
+
+func printCompatReport(report *compatReport) {
+	marked := "no"
+	if report.ResticCompat {
+		marked = "yes"
+	}
+	Printf("repository format version %v, restricted to upstream restic formats: %v\n", report.Version, marked)
+
+	for _, incompatible := range []bool{true, false} {
+		header := false
+		for _, f := range report.Findings {
+			if f.Incompatible != incompatible {
+				continue
+			}
+			if !header {
+				if incompatible {
+					Printf("\nnot readable by upstream restic:\n")
+				} else {
+					Printf("\nignored by upstream restic:\n")
+				}
+				header = true
+			}
+			if f.Kind == "config" {
+				Printf("  %v\n", f.Description)
+			} else {
+				Printf("  %8d  %v\n", f.Count, f.Description)
+			}
+		}
+	}
+
+	if len(report.Findings) == 0 {
+		Printf("\nno data specific to this fork found\n")
+	}
+}
+
+func runCompatCheck(ctx context.Context, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the compat check command expects no arguments, only options - please see `restic help compat check` for usage and flags")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+	if err != nil {
+		return err
+	}
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err = repo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	report, err := checkRepoCompat(ctx, repo, snapshotLister)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		if err := json.NewEncoder(globalOptions.stdout).Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printCompatReport(report)
+	}
+
+	if !report.Compatible {
+		return errors.Fatal("repository contains data which upstream restic cannot read")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+func testRunCompatCheck(t testing.TB, gopts GlobalOptions) (*compatReport, error) {
+	gopts.JSON = true
+	var checkErr error
+	buf, err := withCaptureStdout(func() error {
+		checkErr = runCompatCheck(context.TODO(), gopts, nil)
+		return nil
+	})
+	rtest.OK(t, err)
+	if checkErr != nil && buf.Len() == 0 {
+		return nil, checkErr
+	}
+
+	var report compatReport
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &report))
+	return &report, checkErr
+}
+
+func TestCompatCheck(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInitWithOptions(t, env.gopts, InitOptions{RepositoryVersion: "2"})
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, BackupOptions{}, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "3")}, BackupOptions{}, env.gopts)
+
+	// the pack manifests and the content hashes of files are ignored by
+	// upstream restic
+	report, err := testRunCompatCheck(t, env.gopts)
+	rtest.OK(t, err)
+	rtest.Assert(t, report.Compatible, "repository is not compatible: %v", report.Findings)
+	var names []string
+	for _, f := range report.Findings {
+		names = append(names, f.Name)
+	}
+	rtest.Equals(t, []string{"manifest", "content_sha256"}, names)
+	rtest.Equals(t, uint64(2), report.Findings[0].Count)
+
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	testRunForget(t, env.gopts, ForgetOptions{TrashPeriod: restic.Duration{Days: 7}}, snapshotIDs[0].String())
+	report, err = testRunCompatCheck(t, env.gopts)
+	rtest.Assert(t, err != nil, "compat check succeeded for repository with trash")
+	rtest.Assert(t, !report.Compatible, "repository with trash is compatible")
+	rtest.Equals(t, compatFinding{
+		Kind:         "snapshot",
+		Name:         "trashed",
+		Description:  "snapshots in the trash, listed as regular snapshots by upstream restic",
+		Count:        1,
+		Incompatible: true,
+	}, report.Findings[0])
+
+	// upstream restic does not know the catalog blobs and prunes them
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, BackupOptions{Catalog: true}, env.gopts)
+	report, err = testRunCompatCheck(t, env.gopts)
+	rtest.Assert(t, err != nil, "compat check succeeded for repository with catalog")
+	found := false
+	for _, f := range report.Findings {
+		if f.Name == "catalog" {
+			found = true
+			rtest.Assert(t, f.Incompatible, "catalog is not reported as incompatible")
+		}
+	}
+	rtest.Assert(t, found, "catalog missing in findings %v", report.Findings)
+}
+
+func TestCompatInit(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	rtest.Assert(t, runInit(context.TODO(), InitOptions{RepositoryVersion: "3", ResticCompat: true}, env.gopts, nil) != nil,
+		"init of restic compatible repository with version 3 succeeded")
+
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+	testRunInitWithOptions(t, env2.gopts, InitOptions{RepositoryVersion: "stable", ResticCompat: true})
+	rtest.SetupTarTestFixture(t, env2.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, "", []string{filepath.Join(env2.testdata, "0", "0", "9", "2")}, BackupOptions{}, env2.gopts)
+
+	report, err := testRunCompatCheck(t, env2.gopts)
+	rtest.OK(t, err)
+	rtest.Assert(t, report.ResticCompat, "repository is not marked as restic compatible")
+	for _, f := range report.Findings {
+		rtest.Assert(t, f.Name == "manifest", "unexpected finding %v", f)
+	}
+
+	snapshotIDs := testListSnapshots(t, env2.gopts, 1)
+	err = testRunForgetMayFail(env2.gopts, ForgetOptions{TrashPeriod: restic.Duration{Days: 7}}, snapshotIDs[0].String())
+	rtest.Assert(t, err != nil, "snapshot moved to the trash of restic compatible repository")
+	err = testRunBackupAssumeFailure(t, "", []string{filepath.Join(env2.testdata, "0", "0", "9", "2")}, BackupOptions{Catalog: true}, env2.gopts)
+	rtest.Assert(t, err != nil, "backup with catalog to restic compatible repository succeeded")
+	_, err = withCaptureStdout(func() error {
+		opts := RulesOptions{excludePatternOptions: excludePatternOptions{Excludes: []string{"*.tmp"}}}
+		return runRulesSave(context.TODO(), opts, env2.gopts, []string{"corp-default"})
+	})
+	rtest.Assert(t, err != nil, "rule set saved in restic compatible repository")
+
+	err = withTermStatus(env2.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runMigrate(ctx, MigrateOptions{}, env2.gopts, []string{"upgrade_repo_v3"}, term)
+	})
+	rtest.OK(t, err)
+	report, err = testRunCompatCheck(t, env2.gopts)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(2), report.Version)
+}
+
+func TestCompatMigrate(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInitWithOptions(t, env.gopts, InitOptions{RepositoryVersion: "2"})
+	err := withTermStatus(env.gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runMigrate(ctx, MigrateOptions{ResticCompat: true}, env.gopts, nil, term)
+	})
+	rtest.OK(t, err)
+
+	report, err := testRunCompatCheck(t, env.gopts)
+	rtest.OK(t, err)
+	rtest.Assert(t, report.ResticCompat, "repository is not marked as restic compatible")
+}
@@ -3,11 +3,18 @@ package main
 import (
 	"context"
 	"fmt"
+	"strconv"
 
+	"github.com/restic/restic/internal/backend/location"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/event"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/restic/restic/internal/ui/termstatus"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/spf13/cobra"
@@ -29,10 +36,28 @@ between the files copied and files already stored in the destination repository.
 This means that copied files, which existed in both the source and destination
 repository, /may occupy up to twice their space/ in the destination repository.
 This can be mitigated by the "--copy-chunker-params" option when initializing a
-new destination repository using the "init" command.
+new destination repository using the "init" command, or by the "--rechunk"
+option, which splits the file contents again using the chunker parameters of the
+destination repository.
+
+The "--recompress" option additionally stores blobs again in compressed form
+which exist only uncompressed in the destination repository. The uncompressed
+copies can afterwards be removed using "prune".
+
+The "--trees-only" option copies only the snapshots and their tree blobs, which
+contain the directory structure and the metadata of all files, but not the file
+contents. The copied snapshots record the source repository which stores the
+file contents. This creates a small catalog repository, which can be used to
+browse and search the snapshots of an archive which is stored offline. Files of
+such snapshots cannot be restored from the catalog repository.
+
+Use "--dry-run" to show how much data would be transferred without copying
+anything.
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runCopy(cmd.Context(), copyOptions, globalOptions, args)
+		term, cancel := setupTermstatus()
+		defer cancel()
+		return runCopy(cmd.Context(), copyOptions, globalOptions, args, term)
 	},
 }
 
@@ -40,6 +65,11 @@ new destination repository using the "init" command.
 type CopyOptions struct {
 	secondaryRepoOptions
 	restic.SnapshotFilter
+	Rechunk         bool
+	Recompress      bool
+	TransferWorkers uint
+	TreesOnly       bool
+	DryRun          bool
 }
 
 var copyOptions CopyOptions
@@ -50,9 +80,20 @@ func init() {
 	f := cmdCopy.Flags()
 	initSecondaryRepoOptions(f, &copyOptions.secondaryRepoOptions, "destination", "to copy snapshots from")
 	initMultiSnapshotFilter(f, &copyOptions.SnapshotFilter, true)
+	f.BoolVar(&copyOptions.Rechunk, "rechunk", false, "rechunk file contents using the chunker parameters of the destination repository")
+	f.BoolVar(&copyOptions.Recompress, "recompress", false, "store blobs again in compressed form which exist only uncompressed in the destination repository")
+	f.UintVar(&copyOptions.TransferWorkers, "transfer-workers", 0, "number of packs to transfer in parallel (default: backend connection limit)")
+	f.BoolVar(&copyOptions.TreesOnly, "trees-only", false, "only copy the snapshots and their directory metadata, the file contents remain in the source repository")
+	f.BoolVarP(&copyOptions.DryRun, "dry-run", "n", false, "do not copy anything, only show how much data would be transferred")
 }
 
-func runCopy(ctx context.Context, opts CopyOptions, gopts GlobalOptions, args []string) error {
+func runCopy(ctx context.Context, opts CopyOptions, gopts GlobalOptions, args []string, term *termstatus.Terminal) error {
+	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	events := newEventPrinter("copy", gopts, term)
+	if events != nil {
+		printer = events
+	}
+
 	secondaryGopts, isFromRepo, err := fillSecondaryGlobalOpts(ctx, opts.secondaryRepoOptions, gopts, "destination")
 	if err != nil {
 		return err
@@ -62,18 +103,64 @@ func runCopy(ctx context.Context, opts CopyOptions, gopts GlobalOptions, args []
 		gopts, secondaryGopts = secondaryGopts, gopts
 	}
 
+	if opts.TreesOnly && opts.Rechunk {
+		return errors.Fatal("--trees-only and --rechunk cannot be used together")
+	}
+
+	if opts.TransferWorkers > 0 {
+		gopts, err = withConnections(gopts, opts.TransferWorkers)
+		if err != nil {
+			return err
+		}
+		secondaryGopts, err = withConnections(secondaryGopts, opts.TransferWorkers)
+		if err != nil {
+			return err
+		}
+	}
+
 	ctx, srcRepo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
 	if err != nil {
 		return err
 	}
 	defer unlock()
 
-	ctx, dstRepo, unlock, err := openWithAppendLock(ctx, secondaryGopts, false)
+	ctx, dstRepo, unlock, err := openWithAppendLock(ctx, secondaryGopts, opts.DryRun)
 	if err != nil {
 		return err
 	}
 	defer unlock()
 
+	if opts.TreesOnly {
+		if err := checkResticCompat(dstRepo, "--trees-only"); err != nil {
+			return err
+		}
+	}
+
+	if opts.Recompress && dstRepo.Config().Version < 2 {
+		return errors.Fatal("--recompress requires a destination repository with repository format version 2")
+	}
+
+	var remote *restic.SnapshotRemote
+	if opts.TreesOnly {
+		srcLocation, err := ReadRepo(gopts)
+		if err != nil {
+			return err
+		}
+		remote = &restic.SnapshotRemote{
+			Repository: srcRepo.Config().ID,
+			Location:   location.StripPassword(gopts.backends, srcLocation),
+		}
+	}
+
+	var rechunk *rechunker
+	if opts.Rechunk {
+		if srcRepo.Config().ChunkerPolynomial == dstRepo.Config().ChunkerPolynomial {
+			printer.P("source and destination repository use the same chunker parameters, no rechunking necessary\n")
+		} else {
+			rechunk = newRechunker(srcRepo, dstRepo, opts.Recompress)
+		}
+	}
+
 	srcSnapshotLister, err := restic.MemorizeList(ctx, srcRepo, restic.SnapshotFile)
 	if err != nil {
 		return err
@@ -85,13 +172,17 @@ func runCopy(ctx context.Context, opts CopyOptions, gopts GlobalOptions, args []
 	}
 
 	debug.Log("Loading source index")
-	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
-	if err := srcRepo.LoadIndex(ctx, bar); err != nil {
+	newIndexProgress := func() *progress.Counter {
+		if events != nil {
+			return events.NewCounter("index files loaded")
+		}
+		return newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	}
+	if err := srcRepo.LoadIndex(ctx, newIndexProgress()); err != nil {
 		return err
 	}
-	bar = newIndexProgress(gopts.Quiet, gopts.JSON)
 	debug.Log("Loading destination index")
-	if err := dstRepo.LoadIndex(ctx, bar); err != nil {
+	if err := dstRepo.LoadIndex(ctx, newIndexProgress()); err != nil {
 		return err
 	}
 
@@ -109,6 +200,14 @@ func runCopy(ctx context.Context, opts CopyOptions, gopts GlobalOptions, args []
 
 	// remember already processed trees across all snapshots
 	visitedTrees := restic.NewIDSet()
+	// in dry-run mode nothing is added to the destination index, thus
+	// remember which blobs would have been copied by a previous snapshot
+	var plannedBlobs restic.BlobSet
+	if opts.DryRun {
+		plannedBlobs = restic.NewBlobSet()
+	}
+	var total copyPlan
+	summary := &copySummary{DryRun: opts.DryRun}
 
 	for sn := range FindFilteredSnapshots(ctx, srcSnapshotLister, srcRepo, &opts.SnapshotFilter, args) {
 		// check whether the destination has a snapshot with the same persistent ID which has similar snapshot fields
@@ -120,42 +219,144 @@ func runCopy(ctx context.Context, opts CopyOptions, gopts GlobalOptions, args []
 		if originalSns, ok := dstSnapshotByOriginal[srcOriginal]; ok {
 			isCopy := false
 			for _, originalSn := range originalSns {
-				if similarSnapshots(originalSn, sn) {
-					Verboseff("\n%v\n", sn)
-					Verboseff("skipping source snapshot %s, was already copied to snapshot %s\n", sn.ID().Str(), originalSn.ID().Str())
+				// a metadata-only copy does not count as copy of the full snapshot
+				if originalSn.MetadataOnly() && !opts.TreesOnly && !sn.MetadataOnly() {
+					continue
+				}
+				// rechunked copies use different trees
+				if similarSnapshots(originalSn, sn, rechunk == nil) {
+					printer.V("\n%v\n", sn)
+					printer.V("skipping source snapshot %s, was already copied to snapshot %s\n", sn.ID().Str(), originalSn.ID().Str())
 					isCopy = true
 					break
 				}
 			}
 			if isCopy {
+				summary.SkippedSnapshots++
 				continue
 			}
 		}
-		Verbosef("\n%v\n", sn)
-		Verbosef("  copy started, this may take a while...\n")
-		if err := copyTree(ctx, srcRepo, dstRepo, visitedTrees, *sn.Tree, gopts.Quiet); err != nil {
+		printer.P("\n%v\n", sn)
+
+		if sn.MetadataOnly() && !opts.TreesOnly {
+			summary.SkippedSnapshots++
+			printer.E("skipping snapshot %s, the file contents are stored in repository %s, use --trees-only to copy it\n", sn.ID().Str(), sn.Remote.Repository)
+			continue
+		}
+
+		plan, err := planCopy(ctx, srcRepo, dstRepo, visitedTrees, plannedBlobs, *sn.Tree, rechunk != nil, opts.Recompress, opts.TreesOnly)
+		if err != nil {
+			return err
+		}
+		summary.add(plan)
+		if opts.DryRun {
+			total.add(plan)
+			printer.P("  would %v\n", plan)
+			continue
+		}
+
+		printer.P("  copy started, %v, this may take a while...\n", plan)
+		newTree := *sn.Tree
+		if rechunk != nil {
+			newTree, err = rechunkTree(ctx, rechunk, dstRepo, *sn.Tree, plan.files, printer)
+		} else {
+			err = copyTree(ctx, srcRepo, dstRepo, plan, printer)
+		}
+		if err != nil {
 			return err
 		}
 		debug.Log("tree copied")
 
 		// save snapshot
 		sn.Parent = nil // Parent does not have relevance in the new repo.
+		// the pack files listed in the manifest only exist in the source repo
+		sn.Manifest = nil
+		// the catalog is not copied
+		sn.Catalog = nil
 		// Use Original as a persistent snapshot ID
 		if sn.Original == nil {
 			sn.Original = sn.ID()
 		}
+		sn.Tree = &newTree
+		// keep the remote of snapshots which are already metadata-only
+		if opts.TreesOnly && sn.Remote == nil {
+			sn.Remote = remote
+		}
 		newID, err := restic.SaveSnapshot(ctx, dstRepo, sn)
 		if err != nil {
 			return err
 		}
-		Verbosef("snapshot %s saved\n", newID.Str())
+		printer.P("snapshot %s saved\n", newID.Str())
+		summary.CopiedSnapshots++
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if events != nil {
+		events.Summary(summary)
+	} else if opts.DryRun {
+		term.Print(fmt.Sprintf("\nwould %v in total\n", &total))
+	}
+	return nil
+}
+
+// copySummary is the summary event of the copy command. For a dry run, it
+// contains the data which would have been transferred.
+type copySummary struct {
+	event.Header
+	DryRun           bool   `json:"dry_run"`
+	CopiedSnapshots  int    `json:"copied_snapshots"`
+	SkippedSnapshots int    `json:"skipped_snapshots"`
+	Blobs            int    `json:"blobs"`
+	Packs            int    `json:"packs"`
+	Size             uint64 `json:"size"`
+	RechunkedFiles   uint64 `json:"rechunked_files,omitempty"`
+	RechunkedSize    uint64 `json:"rechunked_size,omitempty"`
+}
+
+func (s *copySummary) add(plan *copyPlan) {
+	if plan.rechunk {
+		s.RechunkedFiles += plan.files
+		s.RechunkedSize += plan.fileSize
+		return
+	}
+	s.Blobs += len(plan.blobs)
+	s.Packs += len(plan.packs)
+	s.Size += plan.size
+}
+
+// withConnections returns a copy of the global options which sets the
+// connection limit of the repository backend to n, unless the limit was
+// already specified using an extended option.
+func withConnections(gopts GlobalOptions, n uint) (GlobalOptions, error) {
+	repo, err := ReadRepo(gopts)
+	if err != nil {
+		return gopts, err
+	}
+	loc, err := location.Parse(gopts.backends, repo)
+	if err != nil {
+		return gopts, errors.Fatalf("parsing repository location failed: %v", err)
 	}
-	return ctx.Err()
+
+	extended := make(options.Options, len(gopts.extended)+1)
+	for k, v := range gopts.extended {
+		extended[k] = v
+	}
+	key := loc.Scheme + ".connections"
+	if _, ok := extended[key]; !ok {
+		extended[key] = strconv.FormatUint(uint64(n), 10)
+	}
+	gopts.extended = extended
+	return gopts, nil
 }
 
-func similarSnapshots(sna *restic.Snapshot, snb *restic.Snapshot) bool {
+func similarSnapshots(sna *restic.Snapshot, snb *restic.Snapshot, compareTree bool) bool {
 	// everything except Parent and Original must match
-	if !sna.Time.Equal(snb.Time) || !sna.Tree.Equal(*snb.Tree) || sna.Hostname != snb.Hostname ||
+	if compareTree && !sna.Tree.Equal(*snb.Tree) {
+		return false
+	}
+	if !sna.Time.Equal(snb.Time) || sna.Hostname != snb.Hostname ||
 		sna.Username != snb.Username || sna.UID != snb.UID || sna.GID != snb.GID ||
 		len(sna.Paths) != len(snb.Paths) || len(sna.Excludes) != len(snb.Excludes) ||
 		len(sna.Tags) != len(snb.Tags) {
@@ -172,8 +373,63 @@ func similarSnapshots(sna *restic.Snapshot, snb *restic.Snapshot) bool {
 	return true
 }
 
-func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Repository,
-	visitedTrees restic.IDSet, rootTreeID restic.ID, quiet bool) error {
+// copyPlan describes the data which must be transferred to copy a snapshot.
+type copyPlan struct {
+	blobs restic.BlobSet
+	packs restic.IDSet
+	// plaintext size of the blobs
+	size uint64
+
+	// number and size of the files to rechunk
+	files    uint64
+	fileSize uint64
+
+	rechunk bool
+}
+
+func (p *copyPlan) add(other *copyPlan) {
+	p.rechunk = other.rechunk
+	p.files += other.files
+	p.fileSize += other.fileSize
+	p.size += other.size
+	if p.blobs == nil {
+		p.blobs = restic.NewBlobSet()
+		p.packs = restic.NewIDSet()
+	}
+	p.blobs.Merge(other.blobs)
+	p.packs.Merge(other.packs)
+}
+
+func (p *copyPlan) String() string {
+	if p.rechunk {
+		return fmt.Sprintf("rechunk %d files (%v)", p.files, ui.FormatBytes(p.fileSize))
+	}
+	return fmt.Sprintf("transfer %d blobs (%v) from %d packs", len(p.blobs), ui.FormatBytes(p.size), len(p.packs))
+}
+
+// storedUncompressed returns true if the blob exists in the repository, but
+// none of its copies is compressed.
+func storedUncompressed(repo restic.Repository, h restic.BlobHandle) bool {
+	pbs := repo.LookupBlob(h.Type, h.ID)
+	for _, pb := range pbs {
+		if pb.IsCompressed() {
+			return false
+		}
+	}
+	return len(pbs) > 0
+}
+
+// planCopy determines which blobs of the tree are missing in the destination
+// repository. If rechunk is set, only the files which have to be rechunked are
+// counted instead. If treesOnly is set, data blobs are ignored.
+func planCopy(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Repository,
+	visitedTrees restic.IDSet, plannedBlobs restic.BlobSet, rootTreeID restic.ID, rechunk bool, recompress bool, treesOnly bool) (*copyPlan, error) {
+
+	plan := &copyPlan{
+		blobs:   restic.NewBlobSet(),
+		packs:   restic.NewIDSet(),
+		rechunk: rechunk,
+	}
 
 	wg, wgCtx := errgroup.WithContext(ctx)
 
@@ -183,14 +439,25 @@ func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Rep
 		return visited
 	}, nil)
 
-	copyBlobs := restic.NewBlobSet()
-	packList := restic.NewIDSet()
-
 	enqueue := func(h restic.BlobHandle) {
+		// Do we already have this blob?
+		if _, ok := dstRepo.LookupBlobSize(h.Type, h.ID); ok && !(recompress && storedUncompressed(dstRepo, h)) {
+			return
+		}
+		if plan.blobs.Has(h) || plannedBlobs.Has(h) {
+			return
+		}
+
 		pb := srcRepo.LookupBlob(h.Type, h.ID)
-		copyBlobs.Insert(h)
-		for _, p := range pb {
-			packList.Insert(p.PackID)
+		plan.blobs.Insert(h)
+		if plannedBlobs != nil {
+			plannedBlobs.Insert(h)
+		}
+		for i, p := range pb {
+			plan.packs.Insert(p.PackID)
+			if i == 0 {
+				plan.size += uint64(p.DataLength())
+			}
 		}
 	}
 
@@ -200,21 +467,27 @@ func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Rep
 				return fmt.Errorf("LoadTree(%v) returned error %v", tree.ID.Str(), tree.Error)
 			}
 
-			// Do we already have this tree blob?
-			treeHandle := restic.BlobHandle{ID: tree.ID, Type: restic.TreeBlob}
-			if _, ok := dstRepo.LookupBlobSize(treeHandle.Type, treeHandle.ID); !ok {
-				// copy raw tree bytes to avoid problems if the serialization changes
-				enqueue(treeHandle)
+			if rechunk {
+				for _, entry := range tree.Nodes {
+					if entry.Type == "file" && len(entry.Content) > 0 {
+						plan.files++
+						plan.fileSize += entry.Size
+					}
+				}
+				continue
+			}
+
+			// copy raw tree bytes to avoid problems if the serialization changes
+			enqueue(restic.BlobHandle{ID: tree.ID, Type: restic.TreeBlob})
+			if treesOnly {
+				continue
 			}
 
 			for _, entry := range tree.Nodes {
 				// Recursion into directories is handled by StreamTrees
 				// Copy the blobs for this file.
 				for _, blobID := range entry.Content {
-					h := restic.BlobHandle{Type: restic.DataBlob, ID: blobID}
-					if _, ok := dstRepo.LookupBlobSize(h.Type, h.ID); !ok {
-						enqueue(h)
-					}
+					enqueue(restic.BlobHandle{Type: restic.DataBlob, ID: blobID})
 				}
 			}
 		}
@@ -222,14 +495,41 @@ func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Rep
 	})
 	err := wg.Wait()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return plan, nil
+}
 
-	bar := newProgressMax(!quiet, uint64(len(packList)), "packs copied")
-	_, err = repository.Repack(ctx, srcRepo, dstRepo, packList, copyBlobs, bar)
+func copyTree(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Repository, plan *copyPlan, printer progress.Printer) error {
+	bar := printer.NewCounter("packs copied")
+	bar.SetMax(uint64(len(plan.packs)))
+	_, err := repository.Repack(ctx, srcRepo, dstRepo, plan.packs, plan.blobs, bar)
 	bar.Done()
 	if err != nil {
 		return errors.Fatal(err.Error())
 	}
 	return nil
 }
+
+func rechunkTree(ctx context.Context, rechunk *rechunker, dstRepo restic.Repository, rootTreeID restic.ID, files uint64, printer progress.Printer) (restic.ID, error) {
+	bar := printer.NewCounter("files rechunked")
+	bar.SetMax(files)
+	defer bar.Done()
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	dstRepo.StartPackUploader(wgCtx, wg)
+
+	var newTree restic.ID
+	wg.Go(func() error {
+		var err error
+		newTree, err = rechunk.RewriteTree(wgCtx, rootTreeID, bar)
+		if err != nil {
+			return err
+		}
+		return dstRepo.Flush(wgCtx)
+	})
+	if err := wg.Wait(); err != nil {
+		return restic.ID{}, errors.Fatal(err.Error())
+	}
+	return newTree, nil
+}
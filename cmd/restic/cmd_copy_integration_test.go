@@ -4,25 +4,31 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
 )
 
 func testRunCopy(t testing.TB, srcGopts GlobalOptions, dstGopts GlobalOptions) {
+	testRunCopyWithOptions(t, srcGopts, dstGopts, CopyOptions{})
+}
+
+func testRunCopyWithOptions(t testing.TB, srcGopts GlobalOptions, dstGopts GlobalOptions, copyOpts CopyOptions) {
 	gopts := srcGopts
 	gopts.Repo = dstGopts.Repo
 	gopts.password = dstGopts.password
 	gopts.InsecureNoPassword = dstGopts.InsecureNoPassword
-	copyOpts := CopyOptions{
-		secondaryRepoOptions: secondaryRepoOptions{
-			Repo:               srcGopts.Repo,
-			password:           srcGopts.password,
-			InsecureNoPassword: srcGopts.InsecureNoPassword,
-		},
+	copyOpts.secondaryRepoOptions = secondaryRepoOptions{
+		Repo:               srcGopts.Repo,
+		password:           srcGopts.password,
+		InsecureNoPassword: srcGopts.InsecureNoPassword,
 	}
 
-	rtest.OK(t, runCopy(context.TODO(), copyOpts, gopts, nil))
+	rtest.OK(t, withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runCopy(ctx, copyOpts, gopts, nil, term)
+	}))
 }
 
 func TestCopy(t *testing.T) {
@@ -155,3 +161,92 @@ func TestCopyToEmptyPassword(t *testing.T) {
 	testListSnapshots(t, env2.gopts, 1)
 	testRunCheck(t, env2.gopts)
 }
+
+func TestCopyDryRun(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+
+	testRunInit(t, env2.gopts)
+	testRunCopyWithOptions(t, env.gopts, env2.gopts, CopyOptions{DryRun: true})
+	testListSnapshots(t, env2.gopts, 0)
+
+	testRunCopyWithOptions(t, env.gopts, env2.gopts, CopyOptions{TransferWorkers: 4})
+	testListSnapshots(t, env2.gopts, 1)
+	testRunCheck(t, env2.gopts)
+}
+
+func TestCopyRechunk(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	// the repositories use different chunker polynomials
+	testRunInit(t, env2.gopts)
+	testRunCopyWithOptions(t, env.gopts, env2.gopts, CopyOptions{Rechunk: true})
+	copiedSnapshotIDs := testListSnapshots(t, env2.gopts, 1)
+	testRunCheck(t, env2.gopts)
+
+	// the rechunked snapshot must not be copied again
+	testRunCopyWithOptions(t, env.gopts, env2.gopts, CopyOptions{Rechunk: true})
+	testListSnapshots(t, env2.gopts, 1)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, snapshotIDs[0])
+	restoredir2 := filepath.Join(env2.base, "restore")
+	testRunRestore(t, env2.gopts, restoredir2, copiedSnapshotIDs[0])
+	diff := directoriesContentsDiff(restoredir, restoredir2)
+	rtest.Assert(t, diff == "", "directories are not equal: %v", diff)
+
+	// the rechunked data must deduplicate against a backup of the same data
+	stats := dirStats(env2.repo)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env2.gopts)
+	rtest.Assert(t, dirStats(env2.repo).size-stats.size < stats.size/10, "rechunked data was not deduplicated")
+}
+
+func TestCopyTreesOnly(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+
+	testRunInit(t, env2.gopts)
+	testRunCopyWithOptions(t, env.gopts, env2.gopts, CopyOptions{TreesOnly: true})
+	copiedSnapshotIDs := testListSnapshots(t, env2.gopts, 1)
+
+	// the catalog only contains the trees
+	stat := dirStats(env.repo)
+	stat2 := dirStats(env2.repo)
+	rtest.Assert(t, stat2.size < stat.size/10, "expected a much smaller repository: %v vs. %v", stat2.size, stat.size)
+
+	_, snapmap := testRunSnapshots(t, env2.gopts)
+	sn := snapmap[copiedSnapshotIDs[0]]
+	rtest.Assert(t, sn.Remote != nil, "copied snapshot has no remote")
+	rtest.Equals(t, env.gopts.Repo, sn.Remote.Location)
+
+	// the snapshot can be browsed and the repository passes check and prune
+	rtest.Assert(t, len(testRunLs(t, env2.gopts, copiedSnapshotIDs[0].String())) > 1, "no files listed")
+	testRunCheck(t, env2.gopts)
+	testRunPrune(t, env2.gopts, PruneOptions{MaxUnused: "5%"})
+	testRunCheck(t, env2.gopts)
+
+	err := testRunRestoreAssumeFailure(copiedSnapshotIDs[0].String(), RestoreOptions{Target: filepath.Join(env2.base, "restore")}, env2.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "only contains metadata"), "unexpected error %v", err)
+
+	// a regular copy adds the file contents
+	testRunCopy(t, env.gopts, env2.gopts)
+	testListSnapshots(t, env2.gopts, 2)
+	testRunCheck(t, env2.gopts)
+}
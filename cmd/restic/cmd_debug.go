@@ -195,7 +195,7 @@ var cmdDebugExamine = &cobra.Command{
 	},
 }
 
-func tryRepairWithBitflip(ctx context.Context, key *crypto.Key, input []byte, bytewise bool) []byte {
+func tryRepairWithBitflip(ctx context.Context, key *crypto.Key, input []byte, additionalData []byte, bytewise bool) []byte {
 	if bytewise {
 		Printf("        trying to repair blob by finding a broken byte\n")
 	} else {
@@ -221,7 +221,7 @@ func tryRepairWithBitflip(ctx context.Context, key *crypto.Key, input []byte, by
 				buf[idx] ^= pattern
 
 				nonce, plaintext := buf[:key.NonceSize()], buf[key.NonceSize():]
-				plaintext, err := key.Open(plaintext[:0], nonce, plaintext, nil)
+				plaintext, err := key.Open(plaintext[:0], nonce, plaintext, additionalData)
 				if err == nil {
 					Printf("\n")
 					Printf("        blob could be repaired by XORing byte %v with 0x%02x\n", idx, pattern)
@@ -311,7 +311,7 @@ func decryptUnsigned(ctx context.Context, k *crypto.Key, buf []byte) []byte {
 }
 
 func loadBlobs(ctx context.Context, opts DebugExamineOptions, repo restic.Repository, packID restic.ID, list []restic.Blob) error {
-	dec, err := zstd.NewReader(nil)
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(repo.Config().CompressionDictionaries...))
 	if err != nil {
 		panic(err)
 	}
@@ -337,15 +337,16 @@ func loadBlobs(ctx context.Context, opts DebugExamineOptions, repo restic.Reposi
 			}
 			buf := pack[blob.Offset : blob.Offset+blob.Length]
 			key := repo.Key()
+			ad := repository.BlobAdditionalData(repo.Config(), blob.BlobHandle)
 
 			nonce, plaintext := buf[:key.NonceSize()], buf[key.NonceSize():]
-			plaintext, err = key.Open(plaintext[:0], nonce, plaintext, nil)
+			plaintext, err = key.Open(plaintext[:0], nonce, plaintext, ad)
 			outputPrefix := ""
 			filePrefix := ""
 			if err != nil {
 				Warnf("error decrypting blob: %v\n", err)
 				if opts.TryRepair || opts.RepairByte {
-					plaintext = tryRepairWithBitflip(ctx, key, buf, opts.RepairByte)
+					plaintext = tryRepairWithBitflip(ctx, key, buf, ad, opts.RepairByte)
 				}
 				if plaintext != nil {
 					outputPrefix = "repaired "
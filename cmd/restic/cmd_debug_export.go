@@ -0,0 +1,400 @@
+//go:build debug
+// +build debug
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdDebugExportPack = &cobra.Command{
+	Use:   "export-pack [flags] pack-ID...",
+	Short: "Export pack files for offline analysis",
+	Long: `
+The "export-pack" command writes the given pack files to the target directory,
+together with a manifest.json file that lists the blobs contained in each pack
+file and whether the hashes of the pack file and of the blobs match.
+
+With --decrypt, the decrypted and decompressed content of each blob is written
+to a separate file. As this writes plaintext data to the local disk without
+any access control, the repository ID must be passed to --confirm.
+
+The repository is not modified.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDebugExportPack(cmd.Context(), globalOptions, debugExportOpts, args)
+	},
+}
+
+var cmdDebugExportTree = &cobra.Command{
+	Use:   "export-tree [flags] tree-ID...",
+	Short: "Export decrypted trees for offline analysis",
+	Long: `
+The "export-tree" command writes the decrypted JSON representation of the given
+trees to the target directory, together with a manifest.json file that lists
+the pack files containing each tree and whether the tree could be loaded. With
+--recursive, all subtrees are exported as well.
+
+As this writes plaintext metadata to the local disk without any access control,
+the repository ID must be passed to --confirm.
+
+The repository is not modified.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDebugExportTree(cmd.Context(), globalOptions, debugExportOpts, args)
+	},
+}
+
+// DebugExportOptions collects all options for the export-pack and export-tree
+// commands.
+type DebugExportOptions struct {
+	Target    string
+	Confirm   string
+	Decrypt   bool
+	Recursive bool
+}
+
+var debugExportOpts DebugExportOptions
+
+func init() {
+	cmdDebug.AddCommand(cmdDebugExportPack)
+	cmdDebug.AddCommand(cmdDebugExportTree)
+
+	for _, cmd := range []*cobra.Command{cmdDebugExportPack, cmdDebugExportTree} {
+		f := cmd.Flags()
+		f.StringVarP(&debugExportOpts.Target, "target", "t", "", "directory to write the exported data to (required)")
+		f.StringVar(&debugExportOpts.Confirm, "confirm", "", "pass the repository `id` to confirm writing decrypted data to the target")
+	}
+	cmdDebugExportPack.Flags().BoolVar(&debugExportOpts.Decrypt, "decrypt", false, "also write the decrypted content of all blobs")
+	cmdDebugExportTree.Flags().BoolVar(&debugExportOpts.Recursive, "recursive", false, "also export all subtrees")
+}
+
+// exportedPack is the manifest entry for a pack file.
+type exportedPack struct {
+	ID          restic.ID      `json:"id"`
+	Size        int            `json:"size"`
+	Hash        restic.ID      `json:"hash"`
+	HashMatches bool           `json:"hash_matches"`
+	File        string         `json:"file"`
+	Blobs       []exportedBlob `json:"blobs"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// exportedBlob is the manifest entry for a blob. For blobs of a pack file, the
+// offset and length refer to that pack file.
+type exportedBlob struct {
+	Type               restic.BlobType `json:"type"`
+	ID                 restic.ID       `json:"id"`
+	PackID             *restic.ID      `json:"pack_id,omitempty"`
+	Offset             uint            `json:"offset"`
+	Length             uint            `json:"length"`
+	UncompressedLength uint            `json:"uncompressed_length,omitempty"`
+	Hash               *restic.ID      `json:"hash,omitempty"`
+	HashMatches        *bool           `json:"hash_matches,omitempty"`
+	File               string          `json:"file,omitempty"`
+	Error              string          `json:"error,omitempty"`
+}
+
+// exportedTree is the manifest entry for a tree. Verified is set if one of the
+// copies could be decrypted and had the correct hash.
+type exportedTree struct {
+	ID       restic.ID      `json:"id"`
+	Copies   []exportedBlob `json:"copies"`
+	Verified bool           `json:"verified"`
+	File     string         `json:"file,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+func checkExportOptions(opts DebugExportOptions, repo *repository.Repository, decrypted bool) error {
+	if opts.Target == "" {
+		return errors.Fatal("please specify a target directory using --target")
+	}
+	if decrypted && opts.Confirm != repo.Config().ID {
+		return errors.Fatalf("decrypted data is written without any protection, pass --confirm %s to continue", repo.Config().ID)
+	}
+	return os.MkdirAll(opts.Target, 0700)
+}
+
+// writeExportFile writes data to a new file in dir. Existing files are never
+// overwritten.
+func writeExportFile(dir, name string, data []byte) error {
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeExportManifest(dir string, item interface{}) error {
+	buf, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeExportFile(dir, "manifest.json", append(buf, '\n'))
+}
+
+func runDebugExportPack(ctx context.Context, gopts GlobalOptions, opts DebugExportOptions, args []string) error {
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = checkExportOptions(opts, repo, opts.Decrypt)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]restic.ID, 0)
+	for _, name := range args {
+		id, err := restic.ParseID(name)
+		if err != nil {
+			id, err = restic.Find(ctx, repo, restic.PackFile, name)
+			if err != nil {
+				Warnf("error: %v\n", err)
+				continue
+			}
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		return errors.Fatal("no pack files to export")
+	}
+
+	// the index is used if the header of a pack file is damaged
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	err = repo.LoadIndex(ctx, bar)
+	if err != nil {
+		return err
+	}
+
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(repo.Config().CompressionDictionaries...))
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	packs := make([]exportedPack, 0, len(ids))
+	for _, id := range ids {
+		p, err := exportPack(ctx, opts, repo, dec, id)
+		if err != nil {
+			return err
+		}
+		packs = append(packs, p)
+	}
+
+	Verbosef("exported %d pack files to %v\n", len(packs), opts.Target)
+	return writeExportManifest(opts.Target, packs)
+}
+
+func exportPack(ctx context.Context, opts DebugExportOptions, repo *repository.Repository, dec *zstd.Decoder, id restic.ID) (exportedPack, error) {
+	Verbosef("export pack %v\n", id)
+
+	buf, err := repo.LoadRaw(ctx, restic.PackFile, id)
+	// also export damaged pack files
+	if buf == nil {
+		return exportedPack{}, err
+	}
+
+	p := exportedPack{
+		ID:   id,
+		Size: len(buf),
+		Hash: restic.Hash(buf),
+		File: "pack-" + id.String(),
+	}
+	p.HashMatches = p.Hash.Equal(id)
+	if err := writeExportFile(opts.Target, p.File, buf); err != nil {
+		return exportedPack{}, err
+	}
+
+	blobs, _, err := repo.ListPack(ctx, id, int64(len(buf)))
+	if err != nil {
+		// the pack header is damaged, fall back to the index
+		p.Error = err.Error()
+		blobs = nil
+		for b := range repo.ListPacksFromIndex(ctx, restic.NewIDSet(id)) {
+			blobs = append(blobs, b.Blobs...)
+		}
+	}
+
+	for _, blob := range blobs {
+		eb := exportedBlob{
+			Type:               blob.Type,
+			ID:                 blob.ID,
+			Offset:             blob.Offset,
+			Length:             blob.Length,
+			UncompressedLength: blob.UncompressedLength,
+		}
+
+		if opts.Decrypt {
+			plaintext, err := decryptPackedBlob(repo, dec, buf, blob)
+			if err != nil {
+				eb.Error = err.Error()
+			} else {
+				hash := restic.Hash(plaintext)
+				matches := hash.Equal(blob.ID)
+				eb.Hash = &hash
+				eb.HashMatches = &matches
+				eb.File = p.File + "-" + blob.Type.String() + "-" + blob.ID.String()
+				if err := writeExportFile(opts.Target, eb.File, plaintext); err != nil {
+					return exportedPack{}, err
+				}
+			}
+		}
+		p.Blobs = append(p.Blobs, eb)
+	}
+
+	return p, nil
+}
+
+// decryptPackedBlob returns the plaintext of blob, which is contained in the
+// pack file buf.
+func decryptPackedBlob(repo *repository.Repository, dec *zstd.Decoder, buf []byte, blob restic.Blob) ([]byte, error) {
+	if int(blob.Offset+blob.Length) > len(buf) {
+		return nil, errors.New("blob is truncated")
+	}
+	ciphertext := buf[blob.Offset : blob.Offset+blob.Length]
+
+	key := repo.Key()
+	if len(ciphertext) < key.NonceSize() {
+		return nil, errors.New("blob is too short")
+	}
+	nonce, ciphertext := ciphertext[:key.NonceSize()], ciphertext[key.NonceSize():]
+	ad := repository.BlobAdditionalData(repo.Config(), blob.BlobHandle)
+	plaintext, err := key.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, err
+	}
+
+	if blob.IsCompressed() {
+		return dec.DecodeAll(plaintext, make([]byte, 0, blob.DataLength()))
+	}
+	return plaintext, nil
+}
+
+func runDebugExportTree(ctx context.Context, gopts GlobalOptions, opts DebugExportOptions, args []string) error {
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = checkExportOptions(opts, repo, true)
+	if err != nil {
+		return err
+	}
+
+	queue := make([]restic.ID, 0, len(args))
+	for _, name := range args {
+		id, err := restic.ParseID(name)
+		if err != nil {
+			return errors.Fatalf("invalid tree ID %q: %v", name, err)
+		}
+		queue = append(queue, id)
+	}
+
+	if len(queue) == 0 {
+		return errors.Fatal("no trees to export")
+	}
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	err = repo.LoadIndex(ctx, bar)
+	if err != nil {
+		return err
+	}
+
+	var trees []exportedTree
+	seen := restic.NewIDSet()
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen.Has(id) {
+			continue
+		}
+		seen.Insert(id)
+
+		t, subtrees, err := exportTree(ctx, opts, repo, id)
+		if err != nil {
+			return err
+		}
+		trees = append(trees, t)
+		if opts.Recursive {
+			queue = append(queue, subtrees...)
+		}
+	}
+
+	Verbosef("exported %d trees to %v\n", len(trees), opts.Target)
+	return writeExportManifest(opts.Target, trees)
+}
+
+func exportTree(ctx context.Context, opts DebugExportOptions, repo *repository.Repository, id restic.ID) (exportedTree, restic.IDs, error) {
+	Verbosef("export tree %v\n", id)
+
+	t := exportedTree{ID: id}
+	for _, pb := range repo.LookupBlob(restic.TreeBlob, id) {
+		packID := pb.PackID
+		t.Copies = append(t.Copies, exportedBlob{
+			Type:               pb.Type,
+			ID:                 pb.ID,
+			PackID:             &packID,
+			Offset:             pb.Offset,
+			Length:             pb.Length,
+			UncompressedLength: pb.UncompressedLength,
+		})
+	}
+	if len(t.Copies) == 0 {
+		t.Error = "tree is not contained in the index"
+		return t, nil, nil
+	}
+
+	// the hash of the plaintext is verified while loading the blob
+	buf, err := repo.LoadBlob(ctx, restic.TreeBlob, id, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return t, nil, ctx.Err()
+		}
+		t.Error = err.Error()
+		return t, nil, nil
+	}
+
+	t.Verified = true
+	t.File = "tree-" + id.String() + ".json"
+	if err := writeExportFile(opts.Target, t.File, buf); err != nil {
+		return t, nil, err
+	}
+
+	tree := &restic.Tree{}
+	if err := json.Unmarshal(buf, tree); err != nil {
+		t.Error = err.Error()
+		return t, nil, nil
+	}
+	return t, tree.Subtrees(), nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/table"
+	"github.com/restic/restic/internal/ui/termstatus"
+	"github.com/spf13/cobra"
+)
+
+var cmdDictionary = &cobra.Command{
+	Use:   "dictionary",
+	Short: "Manage compression dictionaries",
+	Long: `
+The "dictionary" command manages the zstd dictionaries which are used to
+compress tree blobs and small data blobs. Dictionaries require repository
+version 3.
+	`,
+}
+
+var cmdDictionaryTrain = &cobra.Command{
+	Use:   "train [flags]",
+	Short: "Train a compression dictionary",
+	Long: `
+The "train" sub-command builds a zstd dictionary from randomly sampled tree
+blobs and small data blobs of the repository and stores it in the repository
+config. Afterwards, new tree blobs and data blobs of at most 64 KiB are
+compressed using the dictionary. Existing blobs are not recompressed.
+
+Before storing the dictionary, the command estimates how much it improves
+the compression using blobs which were not used for training. Use --dry-run
+to only print the estimate.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		term, cancel := setupTermstatus()
+		defer cancel()
+		return runDictionaryTrain(cmd.Context(), dictionaryTrainOptions, globalOptions, term, args)
+	},
+}
+
+var cmdDictionaryList = &cobra.Command{
+	Use:   "list",
+	Short: "List compression dictionaries",
+	Long: `
+The "list" sub-command lists the compression dictionaries stored in the
+repository config. New blobs are compressed using the last dictionary.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDictionaryList(cmd.Context(), globalOptions, args)
+	},
+}
+
+// DictionaryTrainOptions collects all options for the dictionary train command.
+type DictionaryTrainOptions struct {
+	Samples int
+	Size    string
+	DryRun  bool
+}
+
+var dictionaryTrainOptions DictionaryTrainOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdDictionary)
+	cmdDictionary.AddCommand(cmdDictionaryTrain)
+	cmdDictionary.AddCommand(cmdDictionaryList)
+
+	f := cmdDictionaryTrain.Flags()
+	f.IntVar(&dictionaryTrainOptions.Samples, "samples", 2000, "number of blobs to sample")
+	f.StringVar(&dictionaryTrainOptions.Size, "size", "64K", "maximum `size` of the dictionary (allowed suffixes: k/K, m/M)")
+	f.BoolVarP(&dictionaryTrainOptions.DryRun, "dry-run", "n", false, "do not store the dictionary, only estimate its benefit")
+}
+
+func runDictionaryTrain(ctx context.Context, opts DictionaryTrainOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the dictionary train command expects no arguments, only options")
+	}
+	if opts.Samples < 2 {
+		return errors.Fatal("--samples must be at least 2")
+	}
+	size, err := ui.ParseBytes(opts.Size)
+	if err != nil {
+		return errors.Fatalf("invalid --size: %v", err)
+	}
+
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, opts.DryRun)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if repo.Config().Version < 3 {
+		return errors.Fatal("compression dictionaries require repository version 3, use `restic migrate upgrade_repo_v3` to upgrade")
+	}
+
+	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+
+	printer.P("loading indexes...\n")
+	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	if err := repo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	printer.P("sampling blobs...\n")
+	samples, err := repository.SampleDictionaryBlobs(ctx, repo, opts.Samples)
+	if err != nil {
+		return err
+	}
+	if len(samples) < 2 {
+		return errors.Fatal("the repository does not contain enough blobs for training a dictionary")
+	}
+
+	// hold back every fifth sample to estimate the benefit of the dictionary
+	var training, evaluation [][]byte
+	for i, sample := range samples {
+		if i%5 == 4 {
+			evaluation = append(evaluation, sample)
+		} else {
+			training = append(training, sample)
+		}
+	}
+
+	printer.P("training dictionary using %d blobs...\n", len(training))
+	dict, err := repository.TrainDictionary(training, int(size))
+	if err != nil {
+		return err
+	}
+	id, err := repository.DictionaryID(dict)
+	if err != nil {
+		return err
+	}
+
+	without, err := repository.CompressedSize(evaluation, nil)
+	if err != nil {
+		return err
+	}
+	with, err := repository.CompressedSize(evaluation, dict)
+	if err != nil {
+		return err
+	}
+	printer.P("dictionary %08x has a size of %v\n", id, ui.FormatBytes(uint64(len(dict))))
+	printer.P("compressed size of %d sample blobs: %v without dictionary, %v with dictionary\n",
+		len(evaluation), ui.FormatBytes(uint64(without)), ui.FormatBytes(uint64(with)))
+
+	if opts.DryRun {
+		printer.P("dry run, dictionary was not stored\n")
+		return nil
+	}
+	if with >= without {
+		return errors.Fatal("the dictionary does not improve the compression, not storing it")
+	}
+
+	if err := repository.AddCompressionDictionary(ctx, repo, dict); err != nil {
+		return err
+	}
+	printer.P("stored dictionary %08x\n", id)
+	return nil
+}
+
+func runDictionaryList(ctx context.Context, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the dictionary list command expects no arguments, only options")
+	}
+
+	_, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	type dictInfo struct {
+		ID      string `json:"id"`
+		Size    int    `json:"size"`
+		Current bool   `json:"current"`
+	}
+
+	dicts := repo.Config().CompressionDictionaries
+	list := make([]dictInfo, 0, len(dicts))
+	for i, dict := range dicts {
+		id, err := repository.DictionaryID(dict)
+		if err != nil {
+			return err
+		}
+		list = append(list, dictInfo{
+			ID:      fmt.Sprintf("%08x", id),
+			Size:    len(dict),
+			Current: i == len(dicts)-1,
+		})
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(list)
+	}
+
+	tab := table.New()
+	tab.AddColumn(" ID", "{{if .Current}}*{{else}} {{end}}{{ .ID }}")
+	tab.AddColumn("Size", "{{ .Size }}")
+	for _, d := range list {
+		tab.AddRow(d)
+	}
+	return tab.Write(globalOptions.stdout)
+}
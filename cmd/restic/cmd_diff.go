@@ -36,10 +36,16 @@ To only compare files in specific subfolders, you can use the
 "<snapshotID>:<subfolder>" syntax, where "subfolder" is a path within the
 snapshot.
 
+With "--baseline", the snapshot given as argument is compared to the pinned
+baseline snapshot. Changes which are unexpected on a system in a known good
+state are marked with "!": added executable files and any changes in system
+directories. The system directories can be set using "--system-dir".
+
 EXIT STATUS
 ===========
 
 Exit status is 0 if the command was successful, and non-zero if there was any error.
+With "--baseline", exit status is also non-zero if unexpected changes were found.
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -50,6 +56,8 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 // DiffOptions collects all options for the diff command.
 type DiffOptions struct {
 	ShowMetadata bool
+	Baseline     string
+	SystemDirs   []string
 }
 
 var diffOptions DiffOptions
@@ -59,6 +67,8 @@ func init() {
 
 	f := cmdDiff.Flags()
 	f.BoolVar(&diffOptions.ShowMetadata, "metadata", false, "print changes in metadata")
+	f.StringVar(&diffOptions.Baseline, "baseline", "", "compare to the baseline `snapshot` and report unexpected changes")
+	f.StringArrayVar(&diffOptions.SystemDirs, "system-dir", nil, "report any changes below `dir` compared to the baseline (can be specified multiple times, default: well-known system directories)")
 }
 
 func loadSnapshot(ctx context.Context, be restic.Lister, repo restic.LoaderUnpacked, desc string) (*restic.Snapshot, string, error) {
@@ -74,12 +84,14 @@ type Comparer struct {
 	repo        restic.BlobLoader
 	opts        DiffOptions
 	printChange func(change *Change)
+	baseline    *baselineChecker
 }
 
 type Change struct {
 	MessageType string `json:"message_type"` // "change"
 	Path        string `json:"path"`
 	Modifier    string `json:"modifier"`
+	Alert       string `json:"alert,omitempty"`
 }
 
 func NewChange(path string, mode string) *Change {
@@ -141,6 +153,7 @@ type DiffStatsContainer struct {
 	SourceSnapshot                       string         `json:"source_snapshot"`
 	TargetSnapshot                       string         `json:"target_snapshot"`
 	ChangedFiles                         int            `json:"changed_files"`
+	BaselineAlerts                       int            `json:"baseline_alerts,omitempty"`
 	Added                                DiffStat       `json:"added"`
 	Removed                              DiffStat       `json:"removed"`
 	BlobsBefore, BlobsAfter, BlobsCommon restic.BlobSet `json:"-"`
@@ -166,6 +179,15 @@ func updateBlobs(repo restic.Loader, blobs restic.BlobSet, stats *DiffStat) {
 	}
 }
 
+// report prints the change of node, which is checked against the baseline if
+// one is used.
+func (c *Comparer) report(change *Change, node *restic.Node) {
+	if c.baseline != nil {
+		change.Alert = c.baseline.Check(change, node)
+	}
+	c.printChange(change)
+}
+
 func (c *Comparer) printDir(ctx context.Context, mode string, stats *DiffStat, blobs restic.BlobSet, prefix string, id restic.ID) error {
 	debug.Log("print %v tree %v", mode, id)
 	tree, err := restic.LoadTree(ctx, c.repo, id)
@@ -178,7 +200,7 @@ func (c *Comparer) printDir(ctx context.Context, mode string, stats *DiffStat, b
 		if node.Type == "dir" {
 			name += "/"
 		}
-		c.printChange(NewChange(name, mode))
+		c.report(NewChange(name, mode), node)
 		stats.Add(node)
 		addBlobs(blobs, node)
 
@@ -291,7 +313,7 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 			}
 
 			if mod != "" {
-				c.printChange(NewChange(name, mod))
+				c.report(NewChange(name, mod), node2)
 			}
 
 			if node1.Type == "dir" && node2.Type == "dir" {
@@ -310,7 +332,7 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 			if node1.Type == "dir" {
 				prefix += "/"
 			}
-			c.printChange(NewChange(prefix, "-"))
+			c.report(NewChange(prefix, "-"), node1)
 			stats.Removed.Add(node1)
 
 			if node1.Type == "dir" {
@@ -324,7 +346,7 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 			if node2.Type == "dir" {
 				prefix += "/"
 			}
-			c.printChange(NewChange(prefix, "+"))
+			c.report(NewChange(prefix, "+"), node2)
 			stats.Added.Add(node2)
 
 			if node2.Type == "dir" {
@@ -340,7 +362,12 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 }
 
 func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []string) error {
-	if len(args) != 2 {
+	if opts.Baseline != "" {
+		if len(args) != 1 {
+			return errors.Fatalf("specify one snapshot ID to compare to the baseline")
+		}
+		args = []string{opts.Baseline, args[0]}
+	} else if len(args) != 2 {
 		return errors.Fatalf("specify two snapshot IDs")
 	}
 
@@ -395,9 +422,16 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 		repo: repo,
 		opts: opts,
 		printChange: func(change *Change) {
+			if change.Alert != "" {
+				Printf("%-5s%v  (%v)\n", "!"+change.Modifier, change.Path, change.Alert)
+				return
+			}
 			Printf("%-5s%v\n", change.Modifier, change.Path)
 		},
 	}
+	if opts.Baseline != "" {
+		c.baseline = newBaselineChecker(opts.SystemDirs)
+	}
 
 	if gopts.JSON {
 		enc := json.NewEncoder(globalOptions.stdout)
@@ -432,6 +466,9 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 	both := stats.BlobsBefore.Intersect(stats.BlobsAfter)
 	updateBlobs(repo, stats.BlobsBefore.Sub(both).Sub(stats.BlobsCommon), &stats.Removed)
 	updateBlobs(repo, stats.BlobsAfter.Sub(both).Sub(stats.BlobsCommon), &stats.Added)
+	if c.baseline != nil {
+		stats.BaselineAlerts = c.baseline.alerts
+	}
 
 	if gopts.JSON {
 		err := json.NewEncoder(globalOptions.stdout).Encode(stats)
@@ -447,7 +484,13 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts GlobalOptions, args []
 		Printf("Tree Blobs:  %5d new, %5d removed\n", stats.Added.TreeBlobs, stats.Removed.TreeBlobs)
 		Printf("  Added:   %-5s\n", ui.FormatBytes(uint64(stats.Added.Bytes)))
 		Printf("  Removed: %-5s\n", ui.FormatBytes(uint64(stats.Removed.Bytes)))
+		if c.baseline != nil {
+			Printf("Unexpected changes compared to baseline: %d\n", stats.BaselineAlerts)
+		}
 	}
 
+	if stats.BaselineAlerts > 0 {
+		return errors.Fatalf("found %d unexpected changes compared to baseline snapshot %v", stats.BaselineAlerts, sn1.ID().Str())
+	}
 	return nil
 }
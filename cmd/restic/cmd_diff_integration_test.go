@@ -191,3 +191,33 @@ func TestDiffJSON(t *testing.T) {
 		stat.ChangedFiles == 1, "unexpected statistics")
 	rtest.Assert(t, stat.SourceSnapshot == firstSnapshotID && stat.TargetSnapshot == secondSnapshotID, "unexpected snapshot ids")
 }
+
+func TestDiffBaseline(t *testing.T) {
+	env, cleanup, firstSnapshotID, secondSnapshotID := setupDiffRepo(t)
+	defer cleanup()
+
+	env.gopts.Quiet = false
+	datadir := filepath.Join(env.base, "testdata")
+	runBaseline := func(systemDir string) (string, error) {
+		buf, err := withCaptureStdout(func() error {
+			opts := DiffOptions{
+				Baseline:   firstSnapshotID,
+				SystemDirs: []string{systemDir},
+			}
+			return runDiff(context.TODO(), opts, env.gopts, []string{secondSnapshotID})
+		})
+		return buf.String(), err
+	}
+
+	// no changes in the unmodified directory
+	out, err := runBaseline(filepath.Join(datadir, "testdir"))
+	rtest.OK(t, err)
+	rtest.Assert(t, !strings.Contains(out, "!"), "unexpected alert in output:\n%v", out)
+
+	out, err = runBaseline(filepath.Join(datadir, "moddir"))
+	rtest.Assert(t, err != nil, "expected error for changes in system directory")
+	rtest.Assert(t, regexp.MustCompile(`!M +.+modfile1 +\(system directory changed\)`).MatchString(out),
+		"expected alert for modfile1 in output:\n%v", out)
+	rtest.Assert(t, strings.Contains(out, "Unexpected changes compared to baseline: 9"),
+		"expected number of alerts in output:\n%v", out)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/restic/restic/internal/dump"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -31,6 +32,9 @@ To include the folder content at the root of the archive, you can use the
 "<snapshotID>:<subfolder>" syntax, where "subfolder" is a path within the
 snapshot.
 
+The options "--offset" and "--length" print only part of a single file. Only
+the data covering the requested range is downloaded from the repository.
+
 EXIT STATUS
 ===========
 
@@ -47,6 +51,8 @@ type DumpOptions struct {
 	restic.SnapshotFilter
 	Archive string
 	Target  string
+	Offset  string
+	Length  string
 }
 
 var dumpOptions DumpOptions
@@ -58,6 +64,36 @@ func init() {
 	initSingleSnapshotFilter(flags, &dumpOptions.SnapshotFilter)
 	flags.StringVarP(&dumpOptions.Archive, "archive", "a", "tar", "set archive `format` as \"tar\" or \"zip\"")
 	flags.StringVarP(&dumpOptions.Target, "target", "t", "", "write the output to target `path`")
+	flags.StringVar(&dumpOptions.Offset, "offset", "", "start dumping the file at `offset` bytes (allowed suffixes: k/K, m/M, g/G, t/T)")
+	flags.StringVar(&dumpOptions.Length, "length", "", "only dump `size` bytes of the file (allowed suffixes: k/K, m/M, g/G, t/T)")
+}
+
+// byteRange returns the part of a file selected by the --offset and --length
+// options or nil if the whole file should be dumped.
+func (opts DumpOptions) byteRange() (*restic.ByteRange, error) {
+	if opts.Offset == "" && opts.Length == "" {
+		return nil, nil
+	}
+
+	var r restic.ByteRange
+	if opts.Offset != "" {
+		offset, err := ui.ParseBytes(opts.Offset)
+		if err != nil {
+			return nil, errors.Fatalf("invalid --offset: %v", err)
+		}
+		r.Offset = uint64(offset)
+	}
+	if opts.Length != "" {
+		length, err := ui.ParseBytes(opts.Length)
+		if err != nil {
+			return nil, errors.Fatalf("invalid --length: %v", err)
+		}
+		if length == 0 {
+			return nil, errors.Fatal("--length must be larger than zero")
+		}
+		r.Length = uint64(length)
+	}
+	return &r, nil
 }
 
 func splitPath(p string) []string {
@@ -69,7 +105,7 @@ func splitPath(p string) []string {
 	return append(s, f)
 }
 
-func printFromTree(ctx context.Context, tree *restic.Tree, repo restic.BlobLoader, prefix string, pathComponents []string, d *dump.Dumper, canWriteArchiveFunc func() error) error {
+func printFromTree(ctx context.Context, tree *restic.Tree, repo restic.BlobLoader, prefix string, pathComponents []string, d *dump.Dumper, byteRange *restic.ByteRange, canWriteArchiveFunc func() error) error {
 	// If we print / we need to assume that there are multiple nodes at that
 	// level in the tree.
 	if pathComponents[0] == "" {
@@ -87,13 +123,16 @@ func printFromTree(ctx context.Context, tree *restic.Tree, repo restic.BlobLoade
 		if node.Name == pathComponents[0] {
 			switch {
 			case l == 1 && dump.IsFile(node):
+				if byteRange != nil {
+					return d.WriteNodeRange(ctx, node, *byteRange)
+				}
 				return d.WriteNode(ctx, node)
 			case l > 1 && dump.IsDir(node):
 				subtree, err := restic.LoadTree(ctx, repo, *node.Subtree)
 				if err != nil {
 					return errors.Wrapf(err, "cannot load subtree for %q", item)
 				}
-				return printFromTree(ctx, subtree, repo, item, pathComponents[1:], d, canWriteArchiveFunc)
+				return printFromTree(ctx, subtree, repo, item, pathComponents[1:], d, byteRange, canWriteArchiveFunc)
 			case dump.IsDir(node):
 				if err := canWriteArchiveFunc(); err != nil {
 					return err
@@ -124,6 +163,11 @@ func runDump(ctx context.Context, opts DumpOptions, gopts GlobalOptions, args []
 		return fmt.Errorf("unknown archive format %q", opts.Archive)
 	}
 
+	byteRange, err := opts.byteRange()
+	if err != nil {
+		return err
+	}
+
 	snapshotIDString := args[0]
 	pathToPrint := args[1]
 
@@ -145,6 +189,9 @@ func runDump(ctx context.Context, opts DumpOptions, gopts GlobalOptions, args []
 	if err != nil {
 		return errors.Fatalf("failed to find snapshot: %v", err)
 	}
+	if err := errMetadataOnly(sn); err != nil {
+		return err
+	}
 
 	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
 	err = repo.LoadIndex(ctx, bar)
@@ -177,9 +224,14 @@ func runDump(ctx context.Context, opts DumpOptions, gopts GlobalOptions, args []
 		outputFileWriter = file
 		canWriteArchiveFunc = func() error { return nil }
 	}
+	if byteRange != nil {
+		canWriteArchiveFunc = func() error {
+			return fmt.Errorf("--offset and --length can only be used to dump a single file")
+		}
+	}
 
 	d := dump.New(opts.Archive, repo, outputFileWriter)
-	err = printFromTree(ctx, tree, repo, "/", splittedPath, d, canWriteArchiveFunc)
+	err = printFromTree(ctx, tree, repo, "/", splittedPath, d, byteRange, canWriteArchiveFunc)
 	if err != nil {
 		return errors.Fatalf("cannot dump file: %v", err)
 	}
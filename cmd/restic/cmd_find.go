@@ -261,6 +261,11 @@ func (f *Finder) findInSnapshot(ctx context.Context, sn *restic.Snapshot) error
 	}
 
 	f.out.newsn = sn
+	if c := loadCatalog(ctx, f.repo, sn); c != nil {
+		debug.Log("using catalog %v", sn.Catalog.Str())
+		return c.Walk(ctx, f.matchNode)
+	}
+
 	return walker.Walk(ctx, f.repo, *sn.Tree, walker.WalkVisitor{ProcessNode: func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) error {
 		if err != nil {
 			debug.Log("Error loading tree %v: %v", parentTreeID, err)
@@ -273,62 +278,67 @@ func (f *Finder) findInSnapshot(ctx context.Context, sn *restic.Snapshot) error
 		if node == nil {
 			return nil
 		}
+		return f.matchNode(nodepath, node)
+	}})
+}
 
-		normalizedNodepath := nodepath
-		if f.pat.ignoreCase {
-			normalizedNodepath = strings.ToLower(nodepath)
-		}
+// matchNode prints the node if it matches the pattern. It returns
+// walker.ErrSkipNode for directories which cannot contain matches.
+func (f *Finder) matchNode(nodepath string, node *restic.Node) error {
+	normalizedNodepath := nodepath
+	if f.pat.ignoreCase {
+		normalizedNodepath = strings.ToLower(nodepath)
+	}
 
-		var foundMatch bool
+	var foundMatch bool
 
+	for _, pat := range f.pat.pattern {
+		found, err := filter.Match(pat, normalizedNodepath)
+		if err != nil {
+			return err
+		}
+		if found {
+			foundMatch = true
+			break
+		}
+	}
+
+	var errIfNoMatch error
+	if node.Type == "dir" {
+		var childMayMatch bool
 		for _, pat := range f.pat.pattern {
-			found, err := filter.Match(pat, normalizedNodepath)
+			mayMatch, err := filter.ChildMatch(pat, normalizedNodepath)
 			if err != nil {
 				return err
 			}
-			if found {
-				foundMatch = true
+			if mayMatch {
+				childMayMatch = true
 				break
 			}
 		}
 
-		var errIfNoMatch error
-		if node.Type == "dir" {
-			var childMayMatch bool
-			for _, pat := range f.pat.pattern {
-				mayMatch, err := filter.ChildMatch(pat, normalizedNodepath)
-				if err != nil {
-					return err
-				}
-				if mayMatch {
-					childMayMatch = true
-					break
-				}
-			}
-
-			if !childMayMatch {
-				errIfNoMatch = walker.ErrSkipNode
-			}
+		if !childMayMatch {
+			errIfNoMatch = walker.ErrSkipNode
 		}
+	}
 
-		if !foundMatch {
-			return errIfNoMatch
-		}
+	if !foundMatch {
+		return errIfNoMatch
+	}
 
-		if !f.pat.oldest.IsZero() && node.ModTime.Before(f.pat.oldest) {
-			debug.Log("    ModTime is older than %s\n", f.pat.oldest)
-			return errIfNoMatch
-		}
+	if !f.pat.oldest.IsZero() && node.ModTime.Before(f.pat.oldest) {
+		debug.Log("    ModTime is older than %s\n", f.pat.oldest)
+		return errIfNoMatch
+	}
 
-		if !f.pat.newest.IsZero() && node.ModTime.After(f.pat.newest) {
-			debug.Log("    ModTime is newer than %s\n", f.pat.newest)
-			return errIfNoMatch
-		}
+	if !f.pat.newest.IsZero() && node.ModTime.After(f.pat.newest) {
+		debug.Log("    ModTime is newer than %s\n", f.pat.newest)
+		return errIfNoMatch
+	}
 
-		debug.Log("    found match\n")
-		f.out.PrintPattern(nodepath, node)
-		return nil
-	}})
+	debug.Log("    found match\n")
+	f.out.PrintPattern(nodepath, node)
+	return nil
 }
 
 func (f *Finder) findIDs(ctx context.Context, sn *restic.Snapshot) error {
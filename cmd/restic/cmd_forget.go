@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/event"
+	"github.com/restic/restic/internal/ui/progress"
 	"github.com/restic/restic/internal/ui/termstatus"
 	"github.com/spf13/cobra"
 )
@@ -29,6 +33,15 @@ Please note that this command really only deletes the snapshot object in the
 repository, which is a reference to data stored there. In order to remove the
 unreferenced data after "forget" was run successfully, see the "prune" command.
 
+Snapshots created with "backup --expire-after" carry an expiry time. With
+--honor-expiry, snapshots whose expiry time has passed are removed regardless
+of the "--keep-*" options, and without any "--keep-*" option all other
+snapshots are kept.
+
+With --trash-period, the snapshots are moved to the trash instead. Until the
+trash period has expired, "prune" keeps their data and the "undelete" command
+can restore them.
+
 Please also read the documentation for "forget" to learn about some important
 security considerations.
 
@@ -95,8 +108,10 @@ type ForgetOptions struct {
 	WithinMonthly restic.Duration
 	WithinYearly  restic.Duration
 	KeepTags      restic.TagLists
+	HonorExpiry   bool
 
 	UnsafeAllowRemoveAll bool
+	TrashPeriod          restic.Duration
 
 	restic.SnapshotFilter
 	Compact bool
@@ -105,6 +120,9 @@ type ForgetOptions struct {
 	GroupBy restic.SnapshotGroupByOptions
 	DryRun  bool
 	Prune   bool
+
+	PreviewCalendar bool
+	PreviewInterval time.Duration
 }
 
 var forgetOptions ForgetOptions
@@ -127,7 +145,9 @@ func init() {
 	f.VarP(&forgetOptions.WithinMonthly, "keep-within-monthly", "", "keep monthly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
 	f.VarP(&forgetOptions.WithinYearly, "keep-within-yearly", "", "keep yearly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
 	f.Var(&forgetOptions.KeepTags, "keep-tag", "keep snapshots with this `taglist` (can be specified multiple times)")
+	f.BoolVar(&forgetOptions.HonorExpiry, "honor-expiry", false, "remove snapshots whose expiry time set by 'backup --expire-after' has passed, regardless of the keep options")
 	f.BoolVar(&forgetOptions.UnsafeAllowRemoveAll, "unsafe-allow-remove-all", false, "allow deleting all snapshots of a snapshot group")
+	f.VarP(&forgetOptions.TrashPeriod, "trash-period", "", "move snapshots to the trash and keep their data for `duration` (eg. 1y5m7d2h) instead of removing them")
 
 	initMultiSnapshotFilter(f, &forgetOptions.SnapshotFilter, false)
 	f.StringArrayVar(&forgetOptions.Hosts, "hostname", nil, "only consider snapshots with the given `hostname` (can be specified multiple times)")
@@ -142,6 +162,8 @@ func init() {
 	f.VarP(&forgetOptions.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma (disable grouping with '')")
 	f.BoolVarP(&forgetOptions.DryRun, "dry-run", "n", false, "do not delete anything, just print what would be done")
 	f.BoolVar(&forgetOptions.Prune, "prune", false, "automatically run the 'prune' command if snapshots have been removed")
+	f.BoolVar(&forgetOptions.PreviewCalendar, "preview-calendar", false, "do not delete anything, show which existing and future snapshots the policy keeps over the next year")
+	f.DurationVar(&forgetOptions.PreviewInterval, "preview-interval", 0, "`interval` between future snapshots for --preview-calendar (default: detected from existing snapshots)")
 
 	f.SortFlags = false
 	addPruneOptions(cmdForget, &forgetPruneOptions)
@@ -163,6 +185,25 @@ func verifyForgetOptions(opts *ForgetOptions) error {
 	return nil
 }
 
+func (opts *ForgetOptions) expirePolicy() restic.ExpirePolicy {
+	return restic.ExpirePolicy{
+		Last:          int(opts.Last),
+		Hourly:        int(opts.Hourly),
+		Daily:         int(opts.Daily),
+		Weekly:        int(opts.Weekly),
+		Monthly:       int(opts.Monthly),
+		Yearly:        int(opts.Yearly),
+		Within:        opts.Within,
+		WithinHourly:  opts.WithinHourly,
+		WithinDaily:   opts.WithinDaily,
+		WithinWeekly:  opts.WithinWeekly,
+		WithinMonthly: opts.WithinMonthly,
+		WithinYearly:  opts.WithinYearly,
+		Tags:          opts.KeepTags,
+		Expiry:        opts.HonorExpiry,
+	}
+}
+
 func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
 	err := verifyForgetOptions(&opts)
 	if err != nil {
@@ -174,6 +215,10 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 		return err
 	}
 
+	if opts.PreviewCalendar {
+		return runForgetPreview(ctx, opts, gopts, term, args)
+	}
+
 	if gopts.NoLock && !opts.DryRun {
 		return errors.Fatal("--no-lock is only applicable in combination with --dry-run for forget command")
 	}
@@ -184,16 +229,31 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 	}
 	defer unlock()
 
-	verbosity := gopts.verbosity
-	if gopts.JSON {
-		verbosity = 0
+	if !opts.TrashPeriod.Zero() {
+		if err := checkResticCompat(repo, "--trash-period"); err != nil {
+			return err
+		}
+	}
+
+	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	// stdout contains the ForgetGroups as a single JSON document, thus the
+	// events are printed to stderr
+	events := newEventPrinter("forget", gopts, stderrOutput{term})
+	if events != nil {
+		printer = events
 	}
-	printer := newTerminalProgressPrinter(verbosity, term)
+	summary := &forgetSummary{DryRun: opts.DryRun, Trash: !opts.TrashPeriod.Zero()}
 
 	var snapshots restic.Snapshots
 	removeSnIDs := restic.NewIDSet()
 
-	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
+	// the snapshot list is also used to update the stats cache
+	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+	if err != nil {
+		return err
+	}
+
+	for sn := range FindFilteredSnapshots(ctx, snapshotLister, repo, &opts.SnapshotFilter, args) {
 		snapshots = append(snapshots, sn)
 	}
 	if ctx.Err() != nil {
@@ -213,21 +273,7 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 			return err
 		}
 
-		policy := restic.ExpirePolicy{
-			Last:          int(opts.Last),
-			Hourly:        int(opts.Hourly),
-			Daily:         int(opts.Daily),
-			Weekly:        int(opts.Weekly),
-			Monthly:       int(opts.Monthly),
-			Yearly:        int(opts.Yearly),
-			Within:        opts.Within,
-			WithinHourly:  opts.WithinHourly,
-			WithinDaily:   opts.WithinDaily,
-			WithinWeekly:  opts.WithinWeekly,
-			WithinMonthly: opts.WithinMonthly,
-			WithinYearly:  opts.WithinYearly,
-			Tags:          opts.KeepTags,
-		}
+		policy := opts.expirePolicy()
 
 		if policy.Empty() {
 			if opts.UnsafeAllowRemoveAll {
@@ -262,19 +308,20 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 
 			keep, remove, reasons := restic.ApplyPolicy(snapshotGroup, policy)
 
-			if feature.Flag.Enabled(feature.SafeForgetKeepTags) && !policy.Empty() && len(keep) == 0 {
+			if feature.Flag.Enabled(feature.SafeForgetKeepTags) && !policy.Empty() && len(keep) == 0 && !(policy.Expiry && allExpired(remove)) {
 				return fmt.Errorf("refusing to delete last snapshot of snapshot group \"%v\"", key.String())
 			}
 			if len(keep) != 0 && !gopts.Quiet && !gopts.JSON {
 				printer.P("keep %d snapshots:\n", len(keep))
-				PrintSnapshots(globalOptions.stdout, keep, reasons, opts.Compact)
+				PrintSnapshots(globalOptions.stdout, keep, reasons, opts.Compact, false)
 				printer.P("\n")
 			}
 			fg.Keep = asJSONSnapshots(keep)
+			summary.KeptSnapshots += len(keep)
 
 			if len(remove) != 0 && !gopts.Quiet && !gopts.JSON {
 				printer.P("remove %d snapshots:\n", len(remove))
-				PrintSnapshots(globalOptions.stdout, remove, nil, opts.Compact)
+				PrintSnapshots(globalOptions.stdout, remove, nil, opts.Compact, false)
 				printer.P("\n")
 			}
 			fg.Remove = asJSONSnapshots(remove)
@@ -293,18 +340,39 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 		return ctx.Err()
 	}
 
-	if len(removeSnIDs) > 0 {
+	// the stats cache is updated if it matched the snapshots before forget
+	var cache *statsCache
+	if len(removeSnIDs) > 0 && !opts.DryRun && repo.Cache != nil {
+		ids, err := listIDs(ctx, snapshotLister, restic.SnapshotFile)
+		if err != nil {
+			return err
+		}
+		cache = loadMatchingStatsCache(repo, ids, nil)
+	}
+
+	var trashed restic.IDs
+	if len(removeSnIDs) > 0 && !opts.TrashPeriod.Zero() {
 		if !opts.DryRun {
+			trashed = trashSnapshots(ctx, repo, snapshots, removeSnIDs, opts.TrashPeriod, printer)
+			summary.RemovedSnapshots = len(trashed)
+		} else {
+			printer.P("Would have moved the following snapshots to the trash:\n%v\n\n", removeSnIDs)
+		}
+	} else if len(removeSnIDs) > 0 {
+		if !opts.DryRun {
+			var removed atomic.Int64
 			bar := printer.NewCounter("files deleted")
 			err := restic.ParallelRemove(ctx, repo, removeSnIDs, restic.SnapshotFile, func(id restic.ID, err error) error {
 				if err != nil {
 					printer.E("unable to remove %v/%v from the repository\n", restic.SnapshotFile, id)
 				} else {
 					printer.VV("removed %v/%v\n", restic.SnapshotFile, id)
+					removed.Add(1)
 				}
 				return nil
 			}, bar)
 			bar.Done()
+			summary.RemovedSnapshots = int(removed.Load())
 			if err != nil {
 				return err
 			}
@@ -313,12 +381,28 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 		}
 	}
 
+	if cache != nil {
+		var forgotten restic.Snapshots
+		for _, sn := range snapshots {
+			if removeSnIDs.Has(*sn.ID()) {
+				forgotten = append(forgotten, sn)
+			}
+		}
+		updateStatsCacheAfterForget(ctx, repo, cache, snapshotLister, forgotten, trashed)
+	}
+
 	if gopts.JSON && len(jsonGroups) > 0 {
 		err = printJSONForget(globalOptions.stdout, jsonGroups)
 		if err != nil {
 			return err
 		}
 	}
+	if events != nil {
+		if opts.DryRun {
+			summary.RemovedSnapshots = len(removeSnIDs)
+		}
+		events.Summary(summary)
+	}
 
 	if len(removeSnIDs) > 0 && opts.Prune {
 		if opts.DryRun {
@@ -333,6 +417,52 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 	return nil
 }
 
+// allExpired reports whether all snapshots have expired.
+func allExpired(snapshots restic.Snapshots) bool {
+	now := time.Now()
+	for _, sn := range snapshots {
+		if !sn.Expired(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// trashSnapshots moves the snapshots with the IDs in removeSnIDs to the trash
+// and returns the new IDs of the snapshots which were moved.
+func trashSnapshots(ctx context.Context, repo restic.SaverRemoverUnpacked, snapshots restic.Snapshots, removeSnIDs restic.IDSet, period restic.Duration, printer progress.Printer) restic.IDs {
+	bar := printer.NewCounter("snapshots moved to trash")
+	bar.SetMax(uint64(len(removeSnIDs)))
+	defer bar.Done()
+
+	var moved restic.IDs
+	now := time.Now()
+	for _, sn := range snapshots {
+		if !removeSnIDs.Has(*sn.ID()) {
+			continue
+		}
+		id, err := restic.TrashSnapshot(ctx, repo, sn, now, period)
+		if err != nil {
+			printer.E("unable to move %v/%v to the trash: %v\n", restic.SnapshotFile, sn.ID(), err)
+			continue
+		}
+		printer.VV("moved %v/%v to the trash as %v\n", restic.SnapshotFile, sn.ID(), id)
+		bar.Add(1)
+		moved = append(moved, id)
+	}
+	return moved
+}
+
+// forgetSummary is the summary event of the forget command. For a dry run,
+// RemovedSnapshots is the number of snapshots which would have been removed.
+type forgetSummary struct {
+	event.Header
+	DryRun           bool `json:"dry_run"`
+	Trash            bool `json:"trash"`
+	KeptSnapshots    int  `json:"kept_snapshots"`
+	RemovedSnapshots int  `json:"removed_snapshots"`
+}
+
 // ForgetGroup helps to print what is forgotten in JSON.
 type ForgetGroup struct {
 	Tags    []string     `json:"tags"`
@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -64,3 +66,68 @@ func TestRunForgetSafetyNet(t *testing.T) {
 	})
 	testListSnapshots(t, env.gopts, 0)
 }
+
+func TestForgetPreviewCalendar(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+
+	opts := ForgetOptions{
+		Last:            3,
+		PreviewCalendar: true,
+		PreviewInterval: 24 * time.Hour,
+		GroupBy:         restic.SnapshotGroupByOptions{Host: true, Path: true},
+	}
+	buf, err := withCaptureStdout(func() error {
+		gopts := env.gopts
+		gopts.JSON = true
+		return testRunForgetMayFail(gopts, opts)
+	})
+	rtest.OK(t, err)
+
+	var groups []ForgetPreviewGroup
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &groups))
+	rtest.Equals(t, 1, len(groups))
+	rtest.Equals(t, 24*time.Hour, groups[0].Interval)
+	rtest.Equals(t, 0, len(groups[0].Keep))
+	rtest.Equals(t, 3, len(groups[0].Future))
+
+	// the preview must not remove any snapshots
+	testListSnapshots(t, env.gopts, 2)
+
+	err = testRunForgetMayFail(env.gopts, ForgetOptions{PreviewCalendar: true})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "no policy was specified"), "wrong error %v", err)
+}
+
+func TestForgetHonorExpiry(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	dir := []string{filepath.Join(env.testdata, "0", "0", "9")}
+
+	expireAfter, err := restic.ParseDuration("1d")
+	rtest.OK(t, err)
+	testRunBackup(t, "", dir, BackupOptions{TimeStamp: "2020-01-01 00:00:00", ExpireAfter: expireAfter}, env.gopts)
+	testRunBackup(t, "", dir, BackupOptions{ExpireAfter: expireAfter}, env.gopts)
+	testRunBackup(t, "", dir, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 3)
+
+	_, snapmap := testRunSnapshots(t, env.gopts)
+	for _, sn := range snapmap {
+		if sn.Time.Year() == 2020 {
+			rtest.Assert(t, sn.Expires != nil && sn.Expires.Equal(sn.Time.AddDate(0, 0, 1)), "wrong expiry %v for snapshot from %v", sn.Expires, sn.Time)
+		}
+	}
+
+	// only the snapshot past its expiry is removed
+	testRunForget(t, env.gopts, ForgetOptions{HonorExpiry: true})
+	testListSnapshots(t, env.gopts, 2)
+	_, snapmap = testRunSnapshots(t, env.gopts)
+	for _, sn := range snapmap {
+		rtest.Assert(t, sn.Time.Year() != 2020, "expired snapshot %v was not removed", sn.ID)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/walker"
+)
+
+var cmdForgetPath = &cobra.Command{
+	Use:   "forget-path [flags] path [path ...]",
+	Short: "Remove paths from existing snapshots",
+	Long: `
+The "forget-path" command removes the given files or directories, including
+everything below them, from existing snapshots. This is intended for removing
+data which must not be retained, for example for legal reasons.
+
+Each modified snapshot is replaced by a new snapshot without the paths. The new
+snapshot references the replaced snapshot in its "original" field, all other
+metadata is preserved.
+
+The paths must be absolute and are matched exactly as shown by the "ls"
+command, no patterns are supported. By default, all snapshots are processed,
+including those in the trash. Use --snapshot, --host, --tag and --path to only
+process some snapshots.
+
+Please note that this only removes the paths from the snapshots and not the
+actual data stored in the repository. Run the "prune" command afterwards to
+remove the now unreferenced data.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runForgetPath(cmd.Context(), forgetPathOptions, globalOptions, args)
+	},
+}
+
+// ForgetPathOptions collects all options for the forget-path command.
+type ForgetPathOptions struct {
+	Snapshots []string
+	DryRun    bool
+
+	restic.SnapshotFilter
+}
+
+var forgetPathOptions ForgetPathOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdForgetPath)
+
+	f := cmdForgetPath.Flags()
+	f.StringArrayVar(&forgetPathOptions.Snapshots, "snapshot", nil, "only remove the paths from snapshot `ID` (can be specified multiple times)")
+	f.BoolVarP(&forgetPathOptions.DryRun, "dry-run", "n", false, "do not do anything, just print what would be done")
+
+	initMultiSnapshotFilter(f, &forgetPathOptions.SnapshotFilter, true)
+}
+
+// snapshotPath converts the path p into the form used within snapshots.
+func snapshotPath(p string) (string, error) {
+	if vol := filepath.VolumeName(p); vol != "" {
+		// paths like C:\dir are stored as /C/dir
+		p = "/" + strings.TrimSuffix(vol, ":") + p[len(vol):]
+	}
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, "/") {
+		return "", errors.Fatalf("path %q is not absolute", p)
+	}
+	return path.Clean(p), nil
+}
+
+// isPathOrBelow returns whether p equals one of the paths or is contained in
+// one of them.
+func isPathOrBelow(p string, paths []string) bool {
+	for _, prefix := range paths {
+		if p == prefix || prefix == "/" || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func forgetPathSnapshot(ctx context.Context, repo *repository.Repository, sn *restic.Snapshot, paths []string, dryRun bool) (bool, error) {
+	if sn.Tree == nil {
+		return false, errors.Errorf("snapshot %v has nil tree", sn.ID().Str())
+	}
+
+	rewriter := walker.NewTreeRewriter(walker.RewriteOpts{
+		RewriteNode: func(node *restic.Node, path string) *restic.Node {
+			if !isPathOrBelow(path, paths) {
+				return node
+			}
+			Verbosef("removing %s\n", path)
+			return nil
+		},
+		DisableNodeCache: true,
+	})
+
+	filter := func(ctx context.Context, sn *restic.Snapshot) (restic.ID, error) {
+		return rewriter.RewriteTree(ctx, repo, "/", *sn.Tree)
+	}
+
+	return filterAndReplaceSnapshot(ctx, repo, sn, filter, dryRun, true, nil, "")
+}
+
+func runForgetPath(ctx context.Context, opts ForgetPathOptions, gopts GlobalOptions, args []string) error {
+	if len(args) == 0 {
+		return errors.Fatal("no paths to remove specified")
+	}
+
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		p, err := snapshotPath(arg)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+	}
+
+	Verbosef("create exclusive lock for repository\n")
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, opts.DryRun)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+	if err != nil {
+		return err
+	}
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err = repo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	snapshots := restic.Snapshots{}
+	for sn := range FindFilteredSnapshots(ctx, snapshotLister, repo, &opts.SnapshotFilter, opts.Snapshots) {
+		snapshots = append(snapshots, sn)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(opts.Snapshots) == 0 {
+		// the data must also be removed from snapshots in the trash
+		err = restic.ForAllSnapshots(ctx, snapshotLister, repo, nil, func(_ restic.ID, sn *restic.Snapshot, err error) error {
+			if err != nil {
+				return err
+			}
+			if sn.InTrash() && sn.HasHostname(opts.Hosts) && sn.HasTagList(opts.Tags) && sn.HasPaths(opts.Paths) {
+				snapshots = append(snapshots, sn)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	changedCount := 0
+	for _, sn := range snapshots {
+		Verbosef("\n%v\n", sn)
+		changed, err := forgetPathSnapshot(ctx, repo, sn, paths, opts.DryRun)
+		if err != nil {
+			return errors.Fatalf("unable to remove paths from snapshot ID %q: %v", sn.ID().Str(), err)
+		}
+		if changed {
+			changedCount++
+		}
+	}
+
+	Verbosef("\n")
+	if changedCount == 0 {
+		if !opts.DryRun {
+			Verbosef("no snapshots were modified\n")
+		} else {
+			Verbosef("no snapshots would be modified\n")
+		}
+		return nil
+	}
+
+	if !opts.DryRun {
+		Verbosef("modified %v snapshots\n", changedCount)
+		Verbosef("run `restic prune` to remove the data from the repository\n")
+	} else {
+		Verbosef("would modify %v snapshots\n", changedCount)
+	}
+	return nil
+}
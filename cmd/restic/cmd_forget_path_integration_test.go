@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunForgetPath(t testing.TB, gopts GlobalOptions, opts ForgetPathOptions, paths ...string) {
+	rtest.OK(t, runForgetPath(context.TODO(), opts, gopts, paths))
+}
+
+func TestForgetPath(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	snapshotID := createBasicRewriteRepo(t, env)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+
+	removed := "/testdata/0/0/9"
+	containsRemoved := func(files []string) bool {
+		for _, file := range files {
+			if file == removed || strings.HasPrefix(file, removed+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	rtest.Assert(t, containsRemoved(testRunLs(t, env.gopts, snapshotID.String())), "snapshot does not contain %v", removed)
+
+	// a dry run must not modify anything
+	testRunForgetPath(t, env.gopts, ForgetPathOptions{DryRun: true}, removed)
+	rtest.Equals(t, 2, len(testListSnapshots(t, env.gopts, 2)))
+	rtest.Assert(t, containsRemoved(testRunLs(t, env.gopts, snapshotID.String())), "dry run removed %v", removed)
+
+	// only remove the path from the first snapshot
+	testRunForgetPath(t, env.gopts, ForgetPathOptions{Snapshots: []string{snapshotID.String()}}, removed)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	rtest.Assert(t, !restic.NewIDSet(snapshotIDs...).Has(snapshotID), "snapshot %v was not replaced", snapshotID.Str())
+
+	ctx, repo, unlock, err := openWithReadLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	snapshots, err := restic.TestLoadAllSnapshots(ctx, repo, nil)
+	unlock()
+	rtest.OK(t, err)
+
+	replaced := 0
+	for _, sn := range snapshots {
+		files := testRunLs(t, env.gopts, sn.ID().String())
+		if sn.Original != nil && *sn.Original == snapshotID {
+			replaced++
+			rtest.Assert(t, !containsRemoved(files), "snapshot %v still contains %v", sn.ID().Str(), removed)
+			rtest.Assert(t, len(sn.Tags) == 0, "unexpected tags %v", sn.Tags)
+		} else {
+			rtest.Assert(t, containsRemoved(files), "snapshot %v does not contain %v", sn.ID().Str(), removed)
+		}
+	}
+	rtest.Equals(t, 1, replaced)
+
+	// check forbids unused blobs, thus remove them first
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0"})
+	testRunCheck(t, env.gopts)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestSnapshotPath(t *testing.T) {
+	for _, test := range []struct {
+		path     string
+		expected string
+	}{
+		{"/", "/"},
+		{"/home/user/", "/home/user"},
+		{"/home/user/../other/file", "/home/other/file"},
+	} {
+		p, err := snapshotPath(test.path)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.expected, p)
+	}
+
+	if runtime.GOOS == "windows" {
+		p, err := snapshotPath(`C:\Users\user`)
+		rtest.OK(t, err)
+		rtest.Equals(t, "/C/Users/user", p)
+	}
+
+	_, err := snapshotPath("relative/path")
+	rtest.Assert(t, err != nil, "relative path was accepted")
+}
+
+func TestIsPathOrBelow(t *testing.T) {
+	paths := []string{"/home/user/secret", "/etc/passwd"}
+	for _, test := range []struct {
+		path     string
+		expected bool
+	}{
+		{"/home/user/secret", true},
+		{"/home/user/secret/file", true},
+		{"/home/user/secret-file", false},
+		{"/home/user", false},
+		{"/etc/passwd", true},
+		{"/etc", false},
+	} {
+		rtest.Equals(t, test.expected, isPathOrBelow(test.path, paths), test.path)
+	}
+	rtest.Assert(t, isPathOrBelow("/any/path", []string{"/"}), "root does not contain all paths")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -92,3 +93,40 @@ func TestForgetOptionValues(t *testing.T) {
 		}
 	}
 }
+
+func TestForgetPreviewPolicy(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	var list restic.Snapshots
+	for i := 0; i < 10; i++ {
+		list = append(list, &restic.Snapshot{
+			Time:     now.Add(-time.Duration(i) * 24 * time.Hour),
+			Hostname: "foo",
+			Paths:    []string{"/data"},
+		})
+	}
+
+	rtest.Equals(t, 24*time.Hour, previewInterval(list))
+
+	policy := restic.ExpirePolicy{Last: 3, Monthly: 12}
+	group := previewPolicy(list, policy, 0, now)
+	rtest.Equals(t, 24*time.Hour, group.Interval)
+
+	var existing, existingKept, future, futureKept int
+	for _, month := range group.Months {
+		existing += month.Existing
+		existingKept += month.ExistingKept
+		future += month.Future
+		futureKept += month.FutureKept
+	}
+	rtest.Equals(t, 10, existing)
+	rtest.Equals(t, 365, future)
+	// the future snapshots replace all existing ones
+	rtest.Equals(t, 0, existingKept)
+	rtest.Equals(t, len(group.Keep), existingKept)
+	rtest.Equals(t, len(group.Future), futureKept)
+	rtest.Equals(t, "2024-01", group.Months[0].Month)
+	rtest.Equals(t, "2025-01", group.Months[len(group.Months)-1].Month)
+
+	// the three last snapshots plus one for each of the eleven previous months
+	rtest.Equals(t, 14, futureKept)
+}
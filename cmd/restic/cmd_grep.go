@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"regexp"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/walker"
+)
+
+var cmdGrep = &cobra.Command{
+	Use:   "grep [flags] pattern snapshotID[:path]",
+	Short: "Search the contents of files in a snapshot",
+	Long: `
+The "grep" command searches the contents of the files in a snapshot for lines
+matching a regular expression, without restoring the snapshot. The file
+contents are loaded from the repository and searched by several workers in
+parallel. Each matching line is printed as "path:line number:line".
+
+The special snapshotID "latest" can be used to use the latest snapshot in the
+repository. Use the "<snapshotID>:<subfolder>" syntax to only search below a
+path within the snapshot.
+
+Use --include and --exclude to select the files to search, the patterns are
+matched against the full path of the files within the snapshot. Files larger
+than --max-size are skipped. Lines longer than 16 MiB cannot be searched, files
+containing such lines are reported and skipped.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGrep(cmd.Context(), grepOptions, globalOptions, args)
+	},
+}
+
+// GrepOptions collects all options for the grep command.
+type GrepOptions struct {
+	restic.SnapshotFilter
+	excludePatternOptions
+	includePatternOptions
+
+	IgnoreCase       bool
+	FixedStrings     bool
+	FilesWithMatches bool
+	MaxSize          string
+	Workers          uint
+}
+
+var grepOptions GrepOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdGrep)
+
+	f := cmdGrep.Flags()
+	initSingleSnapshotFilter(f, &grepOptions.SnapshotFilter)
+	initExcludePatternOptions(f, &grepOptions.excludePatternOptions)
+	initIncludePatternOptions(f, &grepOptions.includePatternOptions)
+	f.BoolVar(&grepOptions.IgnoreCase, "ignore-case", false, "ignore case distinctions in the pattern and the file contents")
+	f.BoolVarP(&grepOptions.FixedStrings, "fixed-strings", "F", false, "interpret the pattern as a fixed string instead of a regular expression")
+	f.BoolVarP(&grepOptions.FilesWithMatches, "files-with-matches", "l", false, "only print the paths of files containing a match")
+	f.StringVar(&grepOptions.MaxSize, "max-size", "", "skip files larger than `size` (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.UintVar(&grepOptions.Workers, "workers", 0, "search `n` files in parallel (default: number of backend connections)")
+}
+
+// grepMaxLineLength is the maximum length of a line which can be searched.
+const grepMaxLineLength = 16 * 1024 * 1024
+
+// grepMatch is a matching line of a file.
+type grepMatch struct {
+	Path       string `json:"path"`
+	LineNumber int    `json:"line_number,omitempty"`
+	Line       string `json:"line,omitempty"`
+	Binary     bool   `json:"binary,omitempty"`
+}
+
+// grepFile searches the content read from rd. It returns all matching lines,
+// or only the first one if firstOnly is set. For binary files, only a single
+// match without line is returned.
+func grepFile(rd io.Reader, item string, re *regexp.Regexp, firstOnly bool) ([]grepMatch, error) {
+	var matches []grepMatch
+
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 64*1024), grepMaxLineLength)
+	lineNumber := 0
+	for sc.Scan() {
+		lineNumber++
+		line := sc.Bytes()
+		if !re.Match(line) {
+			continue
+		}
+
+		if bytes.IndexByte(line, 0) >= 0 {
+			return []grepMatch{{Path: item, Binary: true}}, nil
+		}
+		matches = append(matches, grepMatch{Path: item, LineNumber: lineNumber, Line: string(line)})
+		if firstOnly {
+			break
+		}
+	}
+	return matches, sc.Err()
+}
+
+func (opts GrepOptions) compilePattern(pattern string) (*regexp.Regexp, error) {
+	if opts.FixedStrings {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Fatalf("invalid pattern: %v", err)
+	}
+	return re, nil
+}
+
+func runGrep(ctx context.Context, opts GrepOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 2 {
+		return errors.Fatal("specify the pattern and the snapshot ID")
+	}
+
+	re, err := opts.compilePattern(args[0])
+	if err != nil {
+		return err
+	}
+
+	var maxSize uint64
+	if opts.MaxSize != "" {
+		size, err := ui.ParseBytes(opts.MaxSize)
+		if err != nil {
+			return errors.Fatalf("invalid --max-size: %v", err)
+		}
+		maxSize = uint64(size)
+	}
+
+	excludePatternFns, err := opts.excludePatternOptions.CollectPatterns()
+	if err != nil {
+		return err
+	}
+	includePatternFns, err := opts.includePatternOptions.CollectPatterns()
+	if err != nil {
+		return err
+	}
+	if len(excludePatternFns) > 0 && len(includePatternFns) > 0 {
+		return errors.Fatal("exclude and include patterns are mutually exclusive")
+	}
+
+	// selectItem returns whether the file should be searched and whether the
+	// directory may contain files which should be searched
+	selectItem := func(item string) (selected bool, childMayBeSelected bool) {
+		for _, rejectFn := range excludePatternFns {
+			if rejectFn(item) {
+				return false, false
+			}
+		}
+		if len(includePatternFns) == 0 {
+			return true, true
+		}
+		for _, includeFn := range includePatternFns {
+			matched, childMayMatch := includeFn(item)
+			selected = selected || matched
+			childMayBeSelected = childMayBeSelected || matched || childMayMatch
+		}
+		return selected, childMayBeSelected
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sn, subfolder, err := (&restic.SnapshotFilter{
+		Hosts: opts.Hosts,
+		Paths: opts.Paths,
+		Tags:  opts.Tags,
+	}).FindLatest(ctx, repo, repo, args[1])
+	if err != nil {
+		return errors.Fatalf("failed to find snapshot: %v", err)
+	}
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err = repo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	tree, err := restic.FindTreeDirectory(ctx, repo, sn.Tree, subfolder)
+	if err != nil {
+		return err
+	}
+	prefix := path.Join("/", subfolder)
+
+	workers := opts.Workers
+	if workers == 0 {
+		workers = repo.Connections()
+	}
+
+	var outputLock sync.Mutex
+	enc := json.NewEncoder(globalOptions.stdout)
+	printMatches := func(matches []grepMatch) error {
+		outputLock.Lock()
+		defer outputLock.Unlock()
+
+		for _, m := range matches {
+			switch {
+			case gopts.JSON:
+				if opts.FilesWithMatches {
+					m = grepMatch{Path: m.Path}
+				}
+				if err := enc.Encode(m); err != nil {
+					return err
+				}
+			case opts.FilesWithMatches:
+				Printf("%s\n", m.Path)
+			case m.Binary:
+				Printf("binary file %s matches\n", m.Path)
+			default:
+				Printf("%s:%d:%s\n", m.Path, m.LineNumber, m.Line)
+			}
+		}
+		return nil
+	}
+
+	type grepJob struct {
+		item    string
+		content restic.IDs
+	}
+	jobs := make(chan grepJob)
+	wg, wgCtx := errgroup.WithContext(ctx)
+
+	wg.Go(func() error {
+		defer close(jobs)
+		return walker.Walk(wgCtx, repo, *tree, walker.WalkVisitor{
+			ProcessNode: func(_ restic.ID, nodepath string, node *restic.Node, err error) error {
+				if err != nil {
+					return err
+				}
+				if node == nil {
+					return nil
+				}
+
+				item := path.Join(prefix, nodepath)
+				selected, childMayBeSelected := selectItem(item)
+				if node.Type == "dir" {
+					if !childMayBeSelected {
+						return walker.ErrSkipNode
+					}
+					return nil
+				}
+				if !selected || node.Type != "file" || (maxSize > 0 && node.Size > maxSize) {
+					return nil
+				}
+
+				select {
+				case jobs <- grepJob{item: item, content: node.Content}:
+				case <-wgCtx.Done():
+					return wgCtx.Err()
+				}
+				return nil
+			},
+		})
+	})
+
+	var errorCount int
+	var errorLock sync.Mutex
+	for i := uint(0); i < workers; i++ {
+		wg.Go(func() error {
+			for job := range jobs {
+				rd := &blobReader{ctx: wgCtx, repo: repo, ids: job.content}
+				matches, err := grepFile(rd, job.item, re, opts.FilesWithMatches)
+				if err != nil {
+					if wgCtx.Err() != nil {
+						return wgCtx.Err()
+					}
+					debug.Log("searching %v failed: %v", job.item, err)
+					if errors.Is(err, bufio.ErrTooLong) {
+						err = errors.New("line too long")
+					}
+					Warnf("unable to search %v: %v\n", job.item, err)
+					errorLock.Lock()
+					errorCount++
+					errorLock.Unlock()
+					continue
+				}
+
+				if err := printMatches(matches); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+	if errorCount > 0 {
+		return errors.Fatalf("%d files could not be searched", errorCount)
+	}
+	return nil
+}
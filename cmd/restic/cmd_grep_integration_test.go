@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunGrep(t testing.TB, gopts GlobalOptions, opts GrepOptions, pattern, snapshotID string) []grepMatch {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runGrep(context.TODO(), opts, gopts, []string{pattern, snapshotID})
+	})
+	rtest.OK(t, err)
+
+	var matches []grepMatch
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		var m grepMatch
+		rtest.OK(t, json.Unmarshal(sc.Bytes(), &m))
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Path < matches[j].Path
+	})
+	return matches
+}
+
+func TestGrep(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	dir := filepath.Join(env.base, "data")
+	rtest.OK(t, os.MkdirAll(filepath.Join(dir, "sub"), 0700))
+	for name, content := range map[string]string{
+		"a.txt":     "hello\nthe secret is 42\nSecret\n",
+		"b.log":     "nothing to see\n",
+		"sub/c.txt": "another secret\n",
+		"binary":    "secret\x00\x01",
+	} {
+		rtest.OK(t, os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0600))
+	}
+	testRunBackup(t, "", []string{dir}, BackupOptions{}, env.gopts)
+
+	root, err := snapshotPath(dir)
+	rtest.OK(t, err)
+	matches := testRunGrep(t, env.gopts, GrepOptions{}, "secret", "latest")
+	rtest.Equals(t, []grepMatch{
+		{Path: root + "/a.txt", LineNumber: 2, Line: "the secret is 42"},
+		{Path: root + "/binary", Binary: true},
+		{Path: root + "/sub/c.txt", LineNumber: 1, Line: "another secret"},
+	}, matches)
+
+	opts := GrepOptions{IgnoreCase: true, FilesWithMatches: true}
+	opts.Includes = []string{"*.txt"}
+	matches = testRunGrep(t, env.gopts, opts, "SECRET", "latest")
+	rtest.Equals(t, []grepMatch{{Path: root + "/a.txt"}, {Path: root + "/sub/c.txt"}}, matches)
+
+	// search only below a subfolder
+	matches = testRunGrep(t, env.gopts, GrepOptions{FixedStrings: true}, "secret", "latest:"+root+"/sub")
+	rtest.Equals(t, []grepMatch{{Path: root + "/sub/c.txt", LineNumber: 1, Line: "another secret"}}, matches)
+
+	matches = testRunGrep(t, env.gopts, GrepOptions{MaxSize: "10"}, "secret", "latest")
+	rtest.Equals(t, 1, len(matches))
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/backup"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+var cmdIngest = &cobra.Command{
+	Use:   "ingest [flags] DIR",
+	Short: "Create snapshots from the history of a mounted foreign backup",
+	Long: `
+The "ingest" command reads a backup created by another program, which has been
+made available as a directory, for example by mounting Amanda vtapes or tar
+archives using FUSE, or an rsync mirror. It creates a snapshot for each backup
+generation, which carries the time of the original backup instead of the
+current time. The metadata of files and directories is preserved as far as the
+mounted file system provides it.
+
+By default, DIR contains a single generation whose time is given by --time or
+the modification time of DIR. With --generations, each subdirectory of DIR is a
+generation. Its time is parsed from the directory name using the Go time layout
+given by --time-format, or taken from the modification time of the directory.
+Generations are ingested from oldest to newest, each using the previous one as
+the parent snapshot.
+
+The generations are stored at the path given by --path, usually the path of the
+data on the original system. All snapshots are tagged with 'ingest'. Generations
+for which a snapshot with the same host, path and time exists are skipped, thus
+an interrupted ingest can be continued by running the command again.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was a fatal error.
+Exit status is 3 if some source data could not be read (incomplete snapshots created).
+`,
+	PreRun: func(_ *cobra.Command, _ []string) {
+		if ingestOptions.Host == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				debug.Log("os.Hostname() returned err: %v", err)
+				return
+			}
+			ingestOptions.Host = hostname
+		}
+	},
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		term, cancel := setupTermstatus()
+		defer cancel()
+		return runIngest(cmd.Context(), ingestOptions, globalOptions, term, args)
+	},
+}
+
+// IngestOptions bundles all options for the ingest command.
+type IngestOptions struct {
+	Generations bool
+	TimeFormat  string
+	TimeStamp   string
+	Path        string
+	Host        string
+	Tags        restic.TagLists
+	DryRun      bool
+}
+
+var ingestOptions IngestOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdIngest)
+
+	f := cmdIngest.Flags()
+	f.BoolVar(&ingestOptions.Generations, "generations", false, "treat each subdirectory of DIR as a separate backup generation")
+	f.StringVar(&ingestOptions.TimeFormat, "time-format", "", "parse the time of each generation from its directory name using the Go time `layout` (ex. '2006-01-02') (default: modification time of the directory)")
+	f.StringVar(&ingestOptions.TimeStamp, "time", "", "`time` of the backup without --generations (ex. '2012-11-01 22:08:41') (default: modification time of DIR)")
+	f.StringVar(&ingestOptions.Path, "path", "", "store the generations at `path` in the snapshots (default: DIR)")
+	f.StringVarP(&ingestOptions.Host, "host", "H", "", "set the `hostname` for the snapshots (default: $RESTIC_HOST)")
+	f.Var(&ingestOptions.Tags, "tag", "add `tags` for the new snapshots in the format `tag[,tag,...]` (can be specified multiple times)")
+	f.BoolVarP(&ingestOptions.DryRun, "dry-run", "n", false, "do not upload or write any data, just show what would be done")
+
+	// parse host from env, if not exists or empty the default value will be used
+	if host := os.Getenv("RESTIC_HOST"); host != "" {
+		ingestOptions.Host = host
+	}
+}
+
+// ingestGeneration is a single backup of a foreign backup program.
+type ingestGeneration struct {
+	Dir  string
+	Time time.Time
+}
+
+// listIngestGenerations returns the generations found in dir, oldest first.
+func listIngestGenerations(dir string, opts IngestOptions) ([]ingestGeneration, error) {
+	if !opts.Generations {
+		if opts.TimeStamp != "" {
+			t, err := time.ParseInLocation(TimeFormat, opts.TimeStamp, time.Local)
+			if err != nil {
+				return nil, errors.Fatalf("error in time option: %v\n", err)
+			}
+			return []ingestGeneration{{Dir: dir, Time: t}}, nil
+		}
+
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return nil, errors.Fatalf("unable to read %v: %v", dir, err)
+		}
+		return []ingestGeneration{{Dir: dir, Time: fi.ModTime()}}, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Fatalf("unable to list generations in %v: %v", dir, err)
+	}
+
+	var generations []ingestGeneration
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		gen := ingestGeneration{Dir: filepath.Join(dir, entry.Name())}
+		if opts.TimeFormat != "" {
+			gen.Time, err = time.ParseInLocation(opts.TimeFormat, entry.Name(), time.Local)
+			if err != nil {
+				Warnf("skipping %v: name does not match the time format\n", gen.Dir)
+				continue
+			}
+		} else {
+			fi, err := entry.Info()
+			if err != nil {
+				return nil, errors.Fatalf("unable to read %v: %v", gen.Dir, err)
+			}
+			gen.Time = fi.ModTime()
+		}
+		generations = append(generations, gen)
+	}
+
+	if len(generations) == 0 {
+		return nil, errors.Fatalf("no generations found in %v", dir)
+	}
+
+	sort.SliceStable(generations, func(i, j int) bool {
+		return generations[i].Time.Before(generations[j].Time)
+	})
+	return generations, nil
+}
+
+func runIngest(ctx context.Context, opts IngestOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("please specify exactly one directory to ingest")
+	}
+	if opts.TimeStamp != "" && opts.Generations {
+		return errors.Fatal("--time cannot be used together with --generations")
+	}
+	if opts.TimeFormat != "" && !opts.Generations {
+		return errors.Fatal("--time-format requires --generations")
+	}
+
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	target := dir
+	if opts.Path != "" {
+		target, err = filepath.Abs(opts.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	generations, err := listIngestGenerations(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, repo, unlock, err := openWithAppendLock(ctx, gopts, opts.DryRun)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// snapshots of generations which were already ingested, by time
+	ingested := make(map[int64]*restic.Snapshot)
+	f := restic.SnapshotFilter{Hosts: []string{opts.Host}, Paths: []string{target}}
+	err = f.FindAll(ctx, repo, repo, nil, func(_ string, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		ingested[sn.Time.UnixNano()] = sn
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	err = repo.LoadIndex(ctx, bar)
+	if err != nil {
+		return err
+	}
+
+	success := true
+	var parent *restic.Snapshot
+	for _, gen := range generations {
+		if sn, ok := ingested[gen.Time.UnixNano()]; ok {
+			Verbosef("skipping %v, already ingested as snapshot %v\n", gen.Dir, sn.ID().Str())
+			parent = sn
+			continue
+		}
+
+		sn, ok, err := ingestOne(ctx, repo, opts, gopts, term, gen, target, parent)
+		if err != nil {
+			return err
+		}
+		success = success && ok
+		// the trees of a dry run are not stored and cannot be used as parent
+		if !opts.DryRun && sn != nil {
+			parent = sn
+		}
+	}
+
+	if !success {
+		return ErrInvalidSourceData
+	}
+	return nil
+}
+
+// ingestOne creates a snapshot of gen with the contents stored at target. It
+// reports whether all files could be read.
+func ingestOne(ctx context.Context, repo *repository.Repository, opts IngestOptions, gopts GlobalOptions,
+	term *termstatus.Terminal, gen ingestGeneration, target string, parent *restic.Snapshot) (*restic.Snapshot, bool, error) {
+
+	var progressPrinter backup.ProgressPrinter
+	if gopts.JSON {
+		progressPrinter = backup.NewJSONProgress(term, gopts.verbosity)
+	} else {
+		progressPrinter = backup.NewTextProgress(term, gopts.verbosity)
+	}
+	progressReporter := backup.NewProgress(progressPrinter,
+		calculateProgressInterval(!gopts.Quiet, gopts.JSON))
+	defer progressReporter.Done()
+
+	if !gopts.JSON {
+		progressPrinter.P("ingesting %v from %v\n", gen.Dir, gen.Time.Format(TimeFormat))
+	}
+
+	var targetFS fs.FS = fs.Local{}
+	if gen.Dir != target {
+		targetFS = &fs.Remap{FS: targetFS, Source: gen.Dir, Target: target}
+	}
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	cancelCtx, cancel := context.WithCancel(wgCtx)
+	defer cancel()
+
+	sc := archiver.NewScanner(targetFS)
+	sc.Error = progressPrinter.ScannerError
+	sc.Result = progressReporter.ReportTotal
+	wg.Go(func() error { return sc.Scan(cancelCtx, []string{target}) })
+
+	arch := archiver.New(repo, targetFS, archiver.Options{})
+	success := true
+	arch.Error = func(item string, err error) error {
+		success = false
+		reterr := progressReporter.Error(item, err)
+		if reterr == nil && errors.IsFatal(err) {
+			reterr = err
+		}
+		return reterr
+	}
+	arch.CompleteItem = progressReporter.CompleteItem
+	arch.StartFile = progressReporter.StartFile
+	arch.CompleteBlob = progressReporter.CompleteBlob
+	// inode numbers and ctimes of FUSE mounts differ between generations and
+	// mounts, only the metadata of the original backup is meaningful
+	arch.ChangeIgnoreFlags |= archiver.ChangeIgnoreCtime | archiver.ChangeIgnoreInode
+
+	snapshotOpts := archiver.SnapshotOptions{
+		Tags:           append(opts.Tags.Flatten(), "ingest"),
+		BackupStart:    time.Now(),
+		Time:           gen.Time,
+		Hostname:       opts.Host,
+		ParentSnapshot: parent,
+		ProgramVersion: "restic " + version,
+	}
+	sn, id, summary, err := arch.Snapshot(ctx, []string{target}, snapshotOpts)
+
+	// cleanly shutdown the scanner
+	cancel()
+	werr := wg.Wait()
+
+	if err != nil {
+		return nil, false, errors.Fatalf("unable to save snapshot of %v: %v", gen.Dir, err)
+	}
+	if werr != nil {
+		return nil, false, werr
+	}
+
+	progressReporter.Finish(id, summary, opts.DryRun)
+	return sn, success, nil
+}
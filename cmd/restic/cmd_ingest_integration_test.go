@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+func testRunIngest(t testing.TB, dir string, opts IngestOptions, gopts GlobalOptions) {
+	err := withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runIngest(ctx, opts, gopts, term, []string{dir})
+	})
+	rtest.OK(t, err)
+}
+
+func TestIngestGenerations(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	testRunInit(t, env.gopts)
+
+	source := filepath.Join(env.base, "vtapes")
+	for name, content := range map[string]string{
+		"2021-03-01": "newer",
+		"2021-01-15": "older",
+	} {
+		rtest.OK(t, os.MkdirAll(filepath.Join(source, name), 0755))
+		rtest.OK(t, os.WriteFile(filepath.Join(source, name, "file"), []byte(content), 0644))
+	}
+	// directories which are no generations are skipped
+	rtest.OK(t, os.MkdirAll(filepath.Join(source, "lost+found"), 0755))
+
+	target := filepath.Join(env.base, "original", "data")
+	opts := IngestOptions{
+		Generations: true,
+		TimeFormat:  "2006-01-02",
+		Path:        target,
+		Host:        "legacy",
+	}
+	testRunIngest(t, source, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+
+	_, snapshots := testRunSnapshots(t, env.gopts)
+	var times []time.Time
+	for _, sn := range snapshots {
+		rtest.Equals(t, []string{target}, sn.Paths)
+		rtest.Equals(t, "legacy", sn.Hostname)
+		rtest.Assert(t, sn.HasTags([]string{"ingest"}), "snapshot %v is not tagged", sn.ID)
+		times = append(times, sn.Time)
+
+		found := false
+		for _, file := range testRunLs(t, env.gopts, sn.ID.String()) {
+			found = found || file == filepath.ToSlash(filepath.Join(target, "file"))
+		}
+		rtest.Assert(t, found, "file missing in snapshot %v", sn.ID)
+	}
+	for _, name := range []string{"2021-01-15", "2021-03-01"} {
+		expected, err := time.ParseInLocation("2006-01-02", name, time.Local)
+		rtest.OK(t, err)
+		found := false
+		for _, ts := range times {
+			found = found || ts.Equal(expected)
+		}
+		rtest.Assert(t, found, "no snapshot for generation %v in %v", name, times)
+	}
+
+	// generations which were already ingested are skipped
+	testRunIngest(t, source, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+	testRunCheck(t, env.gopts)
+}
@@ -36,6 +36,7 @@ type InitOptions struct {
 	secondaryRepoOptions
 	CopyChunkerParameters bool
 	RepositoryVersion     string
+	ResticCompat          bool
 }
 
 var initOptions InitOptions
@@ -47,6 +48,7 @@ func init() {
 	initSecondaryRepoOptions(f, &initOptions.secondaryRepoOptions, "secondary", "to copy chunker parameters from")
 	f.BoolVar(&initOptions.CopyChunkerParameters, "copy-chunker-params", false, "copy chunker parameters from the secondary repository (useful with the copy command)")
 	f.StringVar(&initOptions.RepositoryVersion, "repository-version", "stable", "repository format version to use, allowed values are a format version, 'latest' and 'stable'")
+	f.BoolVar(&initOptions.ResticCompat, "restic-compat", false, "restrict the repository to formats which can be read by upstream restic")
 }
 
 func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []string) error {
@@ -69,6 +71,9 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 	if version < restic.MinRepoVersion || version > restic.MaxRepoVersion {
 		return errors.Fatalf("only repository versions between %v and %v are allowed", restic.MinRepoVersion, restic.MaxRepoVersion)
 	}
+	if opts.ResticCompat && version > restic.ResticCompatRepoVersion {
+		return errors.Fatalf("--restic-compat requires a repository version up to %v", restic.ResticCompatRepoVersion)
+	}
 
 	chunkerPolynomial, err := maybeReadChunkerPolynomial(ctx, opts, gopts)
 	if err != nil {
@@ -104,6 +109,11 @@ func runInit(ctx context.Context, opts InitOptions, gopts GlobalOptions, args []
 	if err != nil {
 		return errors.Fatalf("create key in repository at %s failed: %v\n", location.StripPassword(gopts.backends, gopts.Repo), err)
 	}
+	if opts.ResticCompat {
+		if err := repository.EnableResticCompat(ctx, s); err != nil {
+			return errors.Fatalf("restricting repository to upstream restic formats failed: %v\n", err)
+		}
+	}
 
 	if !gopts.JSON {
 		Verbosef("created restic repository %v at %s", s.Config().ID[:10], location.StripPassword(gopts.backends, gopts.Repo))
@@ -10,12 +10,16 @@ import (
 )
 
 func testRunInit(t testing.TB, opts GlobalOptions) {
+	testRunInitWithOptions(t, opts, InitOptions{})
+}
+
+func testRunInitWithOptions(t testing.TB, gopts GlobalOptions, opts InitOptions) {
 	repository.TestUseLowSecurityKDFParameters(t)
 	restic.TestDisableCheckPolynomial(t)
 	restic.TestSetLockTimeout(t, 0)
 
-	rtest.OK(t, runInit(context.TODO(), InitOptions{}, opts, nil))
-	t.Logf("repository initialized at %v", opts.Repo)
+	rtest.OK(t, runInit(context.TODO(), opts, gopts, nil))
+	t.Logf("repository initialized at %v", gopts.Repo)
 }
 
 func TestInitCopyChunkerParams(t *testing.T) {
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/backend/cache"
+	"github.com/restic/restic/internal/jobs"
+)
+
+var cmdJobs = &cobra.Command{
+	Use:   "jobs",
+	Short: "Show backup jobs running on this host",
+	Long: `
+The "jobs" command shows the backup jobs which are running or queued on this
+host. A backup waits for other backups of the same paths to the same repository
+to finish before it starts.
+	`,
+}
+
+func init() {
+	cmdRoot.AddCommand(cmdJobs)
+}
+
+// openJobRegistry returns the registry of the backup jobs, which is stored in
+// the cache directory.
+func openJobRegistry(gopts GlobalOptions) (*jobs.Registry, error) {
+	dir := gopts.CacheDir
+	if dir == "" {
+		var err error
+		dir, err = cache.DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return jobs.New(filepath.Join(dir, "jobs"))
+}
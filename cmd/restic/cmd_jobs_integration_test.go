@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/jobs"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunJobsList(t testing.TB, gopts GlobalOptions) []jobs.Job {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runJobsList(gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var list []jobs.Job
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &list))
+	return list
+}
+
+func TestBackupJobQueue(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	target := filepath.Join(env.testdata, "0", "0", "9")
+
+	// simulate a backup of the same path which is still running
+	registry, err := openJobRegistry(env.gopts)
+	rtest.OK(t, err)
+	running, err := registry.Acquire(context.TODO(), jobs.Job{Repository: env.gopts.Repo, Paths: []string{target}}, nil)
+	rtest.OK(t, err)
+
+	list := testRunJobsList(t, env.gopts)
+	rtest.Equals(t, 1, len(list))
+	rtest.Equals(t, jobs.StateRunning, list[0].State)
+	rtest.Equals(t, []string{target}, list[0].Paths)
+
+	err = testRunBackupAssumeFailure(t, "", []string{target}, BackupOptions{QueueTimeout: 100 * time.Millisecond}, env.gopts)
+	rtest.Assert(t, err != nil, "backup did not wait for the running backup")
+	testListSnapshots(t, env.gopts, 0)
+
+	// backups of other paths are not affected
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+
+	rtest.OK(t, running.Release())
+	testRunBackup(t, "", []string{target}, BackupOptions{QueueTimeout: time.Minute}, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+	rtest.Equals(t, 0, len(testRunJobsList(t, env.gopts)))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/jobs"
+	"github.com/restic/restic/internal/ui/table"
+)
+
+var cmdJobsList = &cobra.Command{
+	Use:   "list",
+	Short: "List running and queued backup jobs",
+	Long: `
+The "list" sub-command lists the backup jobs on this host. Running jobs are
+listed first, followed by the queued jobs in the order in which they will start.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command is successful, and non-zero if there was any error.
+	`,
+	DisableAutoGenTag: true,
+	RunE: func(_ *cobra.Command, args []string) error {
+		return runJobsList(globalOptions, args)
+	},
+}
+
+func init() {
+	cmdJobs.AddCommand(cmdJobsList)
+}
+
+func runJobsList(gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the jobs list command expects no arguments, only options - please see `restic help jobs list` for usage and flags")
+	}
+
+	registry, err := openJobRegistry(gopts)
+	if err != nil {
+		return err
+	}
+	list, err := registry.List()
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		if list == nil {
+			list = []jobs.Job{}
+		}
+		return json.NewEncoder(globalOptions.stdout).Encode(list)
+	}
+
+	if len(list) == 0 {
+		Printf("no backup jobs found\n")
+		return nil
+	}
+
+	type data struct {
+		PID        int
+		State      string
+		Since      string
+		Repository string
+		Paths      string
+	}
+
+	tab := table.New()
+	tab.AddColumn("PID", "{{ .PID }}")
+	tab.AddColumn("State", "{{ .State }}")
+	tab.AddColumn("Since", "{{ .Since }}")
+	tab.AddColumn("Repository", "{{ .Repository }}")
+	tab.AddColumn("Paths", "{{ .Paths }}")
+	for _, job := range list {
+		since := job.Queued
+		if job.Started != nil {
+			since = *job.Started
+		}
+		tab.AddRow(data{
+			PID:        job.PID,
+			State:      job.State,
+			Since:      since.Format(TimeFormat),
+			Repository: job.Repository,
+			Paths:      strings.Join(job.Paths, ", "),
+		})
+	}
+	return tab.Write(globalOptions.stdout)
+}
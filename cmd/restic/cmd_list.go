@@ -4,7 +4,6 @@ import (
 	"context"
 
 	"github.com/restic/restic/internal/errors"
-	"github.com/restic/restic/internal/repository/index"
 	"github.com/restic/restic/internal/restic"
 
 	"github.com/spf13/cobra"
@@ -55,13 +54,12 @@ func runList(ctx context.Context, gopts GlobalOptions, args []string) error {
 	case "locks":
 		t = restic.LockFile
 	case "blobs":
-		return index.ForAllIndexes(ctx, repo, repo, func(_ restic.ID, idx *index.Index, _ bool, err error) error {
-			if err != nil {
-				return err
-			}
-			return idx.Each(ctx, func(blobs restic.PackedBlob) {
-				Printf("%v %v\n", blobs.Type, blobs.ID)
-			})
+		// load the full index to skip packs which were removed by delta indexes
+		if err = repo.LoadIndex(ctx, nil); err != nil {
+			return err
+		}
+		return repo.ListBlobs(ctx, func(blobs restic.PackedBlob) {
+			Printf("%v %v\n", blobs.Type, blobs.ID)
 		})
 	default:
 		return errors.Fatal("invalid type")
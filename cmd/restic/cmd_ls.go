@@ -11,6 +11,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/restic/restic/internal/catalog"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/restic"
@@ -352,6 +353,13 @@ func runLs(ctx context.Context, opts LsOptions, gopts GlobalOptions, args []stri
 		return err
 	}
 
+	// the catalog lists the paths of the whole snapshot, the ncdu output
+	// requires the LeaveDir callback of the walker
+	var c *catalog.Catalog
+	if subfolder == "" && !opts.Ncdu {
+		c = loadCatalog(ctx, repo, sn)
+	}
+
 	sn.Tree, err = restic.FindTreeDirectory(ctx, repo, sn.Tree, subfolder)
 	if err != nil {
 		return err
@@ -392,15 +400,21 @@ func runLs(ctx context.Context, opts LsOptions, gopts GlobalOptions, args []stri
 		return nil
 	}
 
-	err = walker.Walk(ctx, repo, *sn.Tree, walker.WalkVisitor{
-		ProcessNode: processNode,
-		LeaveDir: func(path string) {
-			// the root path `/` has no corresponding node and is thus also skipped by processNode
-			if withinDir(path) && path != "/" {
-				printer.LeaveDir(path)
-			}
-		},
-	})
+	if c != nil {
+		err = c.Walk(ctx, func(nodepath string, node *restic.Node) error {
+			return processNode(restic.ID{}, nodepath, node, nil)
+		})
+	} else {
+		err = walker.Walk(ctx, repo, *sn.Tree, walker.WalkVisitor{
+			ProcessNode: processNode,
+			LeaveDir: func(path string) {
+				// the root path `/` has no corresponding node and is thus also skipped by processNode
+				if withinDir(path) && path != "/" {
+					printer.LeaveDir(path)
+				}
+			},
+		})
+	}
 
 	if err != nil {
 		return err
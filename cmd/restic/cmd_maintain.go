@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/backend/cache"
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/restic/restic/internal/ui/table"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+var cmdMaintain = &cobra.Command{
+	Use:   "maintain [flags]",
+	Short: "Run all housekeeping tasks for the repository",
+	Long: `
+The "maintain" command runs the regular housekeeping tasks for a repository in
+a single invocation and prints a summary of the results. It is intended to be
+scheduled, for example once a week. The following tasks are run in order:
+
+  locks    remove stale locks left behind by crashed restic processes
+  repack   repack small pack files and remove unused pack files
+  index    merge small index files into as few index files as possible
+  check    read a sample of the pack files, see "check --read-data-budget"
+  cache    remove cache directories which have not been used recently
+
+Use --skip to disable individual tasks. With --max-duration, tasks which would
+start after the given duration has elapsed are skipped and reading data during
+the check task is stopped once the time is up. The check task resumes with the
+packs which were not verified for the longest time on the next run.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		term, cancel := setupTermstatus()
+		defer cancel()
+		return runMaintain(cmd.Context(), maintainOptions, globalOptions, args, term)
+	},
+}
+
+// MaintainOptions collects all options for the maintain command.
+type MaintainOptions struct {
+	MaxDuration   time.Duration
+	Skip          []string
+	CheckBudget   string
+	MaxRepackSize string
+	CacheMaxAge   uint
+}
+
+var maintainOptions MaintainOptions
+
+// maintainTasks lists the names of all tasks run by maintain in order.
+var maintainTasks = []string{"locks", "repack", "index", "check", "cache"}
+
+func init() {
+	cmdRoot.AddCommand(cmdMaintain)
+
+	f := cmdMaintain.Flags()
+	f.DurationVar(&maintainOptions.MaxDuration, "max-duration", 0, "do not start further tasks after `duration`, like 30m or 2h (default: no limit)")
+	f.StringSliceVar(&maintainOptions.Skip, "skip", nil, "skip the `task`s (locks, repack, index, check or cache), can be specified multiple times")
+	f.StringVar(&maintainOptions.CheckBudget, "check-budget", "1G", "read at most `size` bytes of data packs during the check task (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.StringVar(&maintainOptions.MaxRepackSize, "max-repack-size", "", "maximum `size` to repack (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.UintVar(&maintainOptions.CacheMaxAge, "cache-max-age", 30, "remove cache directories not used for `days`")
+}
+
+// maintainResult is the outcome of a single maintenance task.
+type maintainResult struct {
+	Task string `json:"task"`
+	// Status is one of "done", "failed" or "skipped".
+	Status   string        `json:"status"`
+	Message  string        `json:"message,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+func printMaintainSummary(stdout io.Writer, results []maintainResult, total time.Duration) error {
+	tab := table.New()
+	tab.AddColumn("Task", "{{ .Task }}")
+	tab.AddColumn("Status", "{{ .Status }}")
+	tab.AddColumn("Duration", "{{ .Duration }}")
+	tab.AddColumn("Details", "{{ .Message }}")
+
+	type row struct {
+		Task, Status, Duration, Message string
+	}
+	for _, r := range results {
+		tab.AddRow(row{Task: r.Task, Status: r.Status, Duration: ui.FormatDuration(r.Duration), Message: r.Message})
+	}
+	tab.AddFooter(fmt.Sprintf("maintenance finished in %s", ui.FormatDuration(total)))
+
+	return tab.Write(stdout)
+}
+
+func runMaintain(ctx context.Context, opts MaintainOptions, gopts GlobalOptions, args []string, term *termstatus.Terminal) error {
+	if len(args) != 0 {
+		return errors.Fatal("the maintain command expects no arguments, only options - please see `restic help maintain` for usage and flags")
+	}
+
+	skip := make(map[string]bool)
+	for _, task := range opts.Skip {
+		task = strings.TrimSpace(task)
+		known := false
+		for _, t := range maintainTasks {
+			known = known || t == task
+		}
+		if !known {
+			return errors.Fatalf("unknown task %q for --skip, must be one of %s", task, strings.Join(maintainTasks, ", "))
+		}
+		skip[task] = true
+	}
+
+	checkBudget, err := ui.ParseBytes(opts.CheckBudget)
+	if err != nil || checkBudget <= 0 {
+		return errors.Fatalf("invalid value %q for --check-budget", opts.CheckBudget)
+	}
+
+	pruneOpts := PruneOptions{
+		MaxUnused:     "unlimited",
+		MaxRepackSize: opts.MaxRepackSize,
+		RepackSmall:   true,
+	}
+	if err := verifyPruneOptions(&pruneOpts); err != nil {
+		return err
+	}
+
+	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	start := time.Now()
+	var deadline time.Time
+	if opts.MaxDuration > 0 {
+		deadline = start.Add(opts.MaxDuration)
+	}
+
+	// the check coverage is stored in the persistent cache directory
+	cacheDir := gopts.CacheDir
+	if cacheDir == "" && !gopts.NoCache {
+		cacheDir, err = cache.DefaultDir()
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var results []maintainResult
+	failed := 0
+	runTask := func(name string, fn func() (string, error)) {
+		result := maintainResult{Task: name, Status: "skipped"}
+		switch {
+		case skip[name]:
+			result.Message = "disabled using --skip"
+		case ctx.Err() != nil:
+			result.Message = "interrupted"
+		case !deadline.IsZero() && time.Now().After(deadline):
+			result.Message = "time budget exhausted"
+		default:
+			printer.P("running task %v\n", name)
+			taskStart := time.Now()
+			msg, err := fn()
+			result.Duration = time.Since(taskStart)
+			result.Status, result.Message = "done", msg
+			if err != nil {
+				printer.E("task %v failed: %v\n", name, err)
+				result.Status, result.Message = "failed", err.Error()
+				failed++
+			}
+		}
+		results = append(results, result)
+	}
+
+	runTask("locks", func() (string, error) {
+		removed, err := restic.RemoveStaleLocks(ctx, repo)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("removed %d stale locks", removed), nil
+	})
+
+	runTask("repack", func() (string, error) {
+		stats, err := pruneWithRepo(ctx, pruneOpts, gopts, repo, restic.NewIDSet(), term)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("repacked %d packs, removed %d packs, freed %s", stats.Packs.Repack,
+			stats.Packs.Remove+stats.Packs.Unref, ui.FormatBytes(stats.Size.Remove+stats.Size.Repackrm+stats.Size.Unref)), nil
+	})
+
+	runTask("index", func() (string, error) {
+		before, after, err := repository.CompactIndex(ctx, repo, printer)
+		if err != nil {
+			return "", err
+		}
+		if before == after {
+			return fmt.Sprintf("%d index files, nothing to compact", before), nil
+		}
+		return fmt.Sprintf("merged %d index files into %d", before, after), nil
+	})
+
+	runTask("check", func() (string, error) {
+		checkCtx := ctx
+		if !deadline.IsZero() {
+			var cancel context.CancelFunc
+			checkCtx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+		return maintainCheck(checkCtx, repo, cacheDir, checkBudget, printer, gopts, term)
+	})
+
+	runTask("cache", func() (string, error) {
+		if gopts.NoCache {
+			return "cache is disabled", nil
+		}
+		oldDirs, err := cache.OlderThan(cacheDir, time.Duration(opts.CacheMaxAge)*24*time.Hour)
+		if err != nil {
+			return "", err
+		}
+		removed := 0
+		for _, item := range oldDirs {
+			dir := filepath.Join(cacheDir, item.Name())
+			if err := fs.RemoveAll(dir); err != nil {
+				printer.E("unable to remove %v: %v\n", dir, err)
+				continue
+			}
+			removed++
+		}
+		return fmt.Sprintf("removed %d old cache directories", removed), nil
+	})
+
+	if gopts.JSON {
+		err = json.NewEncoder(globalOptions.stdout).Encode(results)
+	} else {
+		printer.P("\n")
+		err = printMaintainSummary(globalOptions.stdout, results, time.Since(start))
+	}
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if failed > 0 {
+		return errors.Fatalf("%d maintenance tasks failed", failed)
+	}
+	return nil
+}
+
+// maintainCheck reads data packs within the budget, preferring packs which were
+// not verified recently. It returns a description of the result and an error if
+// damaged packs were found.
+func maintainCheck(ctx context.Context, repo restic.Repository, cacheDir string, budget int64,
+	printer progress.Printer, gopts GlobalOptions, term *termstatus.Terminal) (string, error) {
+
+	chkr := checker.New(repo, false)
+	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	_, errs := chkr.LoadIndex(ctx, bar)
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if len(errs) > 0 {
+		for _, err := range errs {
+			printer.E("error: %v\n", err)
+		}
+		return "", errors.New("loading the index failed")
+	}
+
+	var read, damaged int
+	interrupted, failed := false, false
+	readData := func(packs map[restic.ID]int64) restic.IDs {
+		p := newTerminalProgressMax(!gopts.Quiet, uint64(len(packs)), "packs", term)
+		errChan := make(chan error)
+		go chkr.ReadPacks(ctx, packs, p, errChan)
+
+		damagedPacks := restic.NewIDSet()
+		unattributedErrors := false
+		for err := range errChan {
+			if ctx.Err() != nil {
+				interrupted = true
+				continue
+			}
+			printer.E("%v\n", err)
+			if err, ok := err.(*repository.ErrPackData); ok {
+				damagedPacks.Insert(err.PackID)
+			} else {
+				unattributedErrors = true
+			}
+		}
+		p.Done()
+		read = len(packs)
+		damaged = len(damagedPacks)
+
+		if unattributedErrors || ctx.Err() != nil {
+			interrupted = interrupted || ctx.Err() != nil
+			failed = unattributedErrors
+			return nil
+		}
+		var verified restic.IDs
+		for id := range packs {
+			if !damagedPacks.Has(id) {
+				verified = append(verified, id)
+			}
+		}
+		return verified
+	}
+
+	err := readDataWithBudget(ctx, repo.Config().ID, cacheDir, chkr.GetPacks(), budget, printer, readData)
+	if err != nil {
+		return "", err
+	}
+	if damaged > 0 {
+		return "", errors.Errorf("%d of %d read packs are damaged, run `restic check --read-data` for details", damaged, read)
+	}
+	if failed {
+		return "", errors.New("reading data packs failed")
+	}
+	if interrupted {
+		return fmt.Sprintf("read of %d packs stopped by time budget", read), nil
+	}
+	return fmt.Sprintf("verified %d packs", read), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+func testRunMaintain(t testing.TB, gopts GlobalOptions, opts MaintainOptions) []maintainResult {
+	gopts.JSON = true
+	// the tasks list the repository files independently of each other, just
+	// like running the corresponding commands one after another
+	gopts.backendTestHook = nil
+	buf, err := withCaptureStdout(func() error {
+		return withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+			return runMaintain(context.TODO(), opts, gopts, nil, term)
+		})
+	})
+	rtest.OK(t, err)
+
+	var results []maintainResult
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &results))
+	return results
+}
+
+func TestMaintain(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	dir := filepath.Join(env.base, "data")
+	rtest.OK(t, os.MkdirAll(dir, 0700))
+	for i := 0; i < 3; i++ {
+		rtest.OK(t, os.WriteFile(filepath.Join(dir, "file"), rtest.Random(i, 1024*1024), 0600))
+		testRunBackup(t, "", []string{dir}, BackupOptions{}, env.gopts)
+	}
+	rtest.Assert(t, len(testRunList(t, "index", env.gopts)) >= 3, "expected one index per backup")
+
+	results := testRunMaintain(t, env.gopts, MaintainOptions{CheckBudget: "100M", CacheMaxAge: 30})
+	rtest.Equals(t, len(maintainTasks), len(results))
+	for i, r := range results {
+		rtest.Equals(t, maintainTasks[i], r.Task)
+		rtest.Assert(t, r.Status == "done", "task %v has status %v: %v", r.Task, r.Status, r.Message)
+	}
+	rtest.Equals(t, 1, len(testRunList(t, "index", env.gopts)))
+	testRunCheck(t, env.gopts)
+
+	results = testRunMaintain(t, env.gopts, MaintainOptions{CheckBudget: "100M", Skip: []string{"repack", "check"}})
+	rtest.Equals(t, "skipped", results[1].Status)
+	rtest.Equals(t, "done", results[2].Status)
+	rtest.Equals(t, "skipped", results[3].Status)
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/migrations"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui/progress"
 	"github.com/restic/restic/internal/ui/termstatus"
@@ -19,6 +21,11 @@ The "migrate" command checks which migrations can be applied for a repository
 and prints a list with available migration names. If one or more migration
 names are specified, these migrations are applied.
 
+The option --restic-compat restricts the repository to formats which can be
+read by upstream restic. Afterwards, migrations to formats which upstream
+restic does not support are refused. Data saved before is not modified, use
+"restic compat check" to find data which upstream restic cannot read.
+
 EXIT STATUS
 ===========
 
@@ -34,7 +41,8 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 
 // MigrateOptions bundles all options for the 'check' command.
 type MigrateOptions struct {
-	Force bool
+	Force        bool
+	ResticCompat bool
 }
 
 var migrateOptions MigrateOptions
@@ -43,6 +51,7 @@ func init() {
 	cmdRoot.AddCommand(cmdMigrate)
 	f := cmdMigrate.Flags()
 	f.BoolVarP(&migrateOptions.Force, "force", "f", false, `apply a migration a second time`)
+	f.BoolVar(&migrateOptions.ResticCompat, "restic-compat", false, "restrict the repository to formats which can be read by upstream restic")
 }
 
 func checkMigrations(ctx context.Context, repo restic.Repository, printer progress.Printer) error {
@@ -130,6 +139,16 @@ func runMigrate(ctx context.Context, opts MigrateOptions, gopts GlobalOptions, a
 	}
 	defer unlock()
 
+	if opts.ResticCompat {
+		if err := repository.EnableResticCompat(ctx, repo); err != nil {
+			return errors.Fatalf("restricting repository to upstream restic formats failed: %v", err)
+		}
+		printer.P("repository is restricted to formats which can be read by upstream restic\n")
+		if len(args) == 0 {
+			return nil
+		}
+	}
+
 	if len(args) == 0 {
 		return checkMigrations(ctx, repo, printer)
 	}
@@ -14,6 +14,7 @@ import (
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
 
 	resticfs "github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/fuse"
@@ -61,6 +62,14 @@ The default path templates are:
     "hosts/%h/%T"
     "tags/%t/%T"
 
+Caching
+=======
+
+The decrypted contents of files are kept in memory, such that reading the
+same parts of files again, for example when searching or previewing them,
+does not download and decrypt the data again. Use --blob-cache-size to change
+the amount of memory used for this cache, the default is 64 MiB.
+
 EXIT STATUS
 ===========
 
@@ -80,6 +89,7 @@ type MountOptions struct {
 	restic.SnapshotFilter
 	TimeTemplate  string
 	PathTemplates []string
+	BlobCacheSize string
 }
 
 var mountOptions MountOptions
@@ -98,6 +108,8 @@ func init() {
 	mountFlags.StringVar(&mountOptions.TimeTemplate, "snapshot-template", time.RFC3339, "set `template` to use for snapshot dirs")
 	mountFlags.StringVar(&mountOptions.TimeTemplate, "time-template", time.RFC3339, "set `template` to use for times")
 	_ = mountFlags.MarkDeprecated("snapshot-template", "use --time-template")
+
+	mountFlags.StringVar(&mountOptions.BlobCacheSize, "blob-cache-size", "", "keep up to `size` of decrypted file contents in memory (default: 64M)")
 }
 
 func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args []string) error {
@@ -113,6 +125,18 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 		return errors.Fatal("wrong number of parameters")
 	}
 
+	var blobCacheSize int64
+	if opts.BlobCacheSize != "" {
+		size, err := ui.ParseBytes(opts.BlobCacheSize)
+		if err != nil {
+			return errors.Fatalf("invalid --blob-cache-size: %v", err)
+		}
+		if size < 1<<20 {
+			return errors.Fatal("--blob-cache-size must be at least 1M")
+		}
+		blobCacheSize = size
+	}
+
 	mountpoint := args[0]
 
 	// Check the existence of the mount point at the earliest stage to
@@ -166,6 +190,7 @@ func runMount(ctx context.Context, opts MountOptions, gopts GlobalOptions, args
 		Filter:        opts.SnapshotFilter,
 		TimeTemplate:  opts.TimeTemplate,
 		PathTemplates: opts.PathTemplates,
+		BlobCacheSize: int(blobCacheSize),
 	}
 	root := fuse.NewRoot(repo, cfg)
 
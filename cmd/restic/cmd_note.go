@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdNote = &cobra.Command{
+	Use:   "note",
+	Short: "Manage notes of snapshots",
+	Long: `
+The "note" command manages short notes which are stored in snapshots, for
+example to record the results of restore tests alongside the data which was
+tested. Use "snapshots --with-notes" to show the notes.
+`,
+	DisableAutoGenTag: true,
+}
+
+var cmdNoteAdd = &cobra.Command{
+	Use:   "add [flags] snapshotID text",
+	Short: "Add a note to a snapshot",
+	Long: `
+The "add" sub-command adds a note to a snapshot. The note records the current
+time and the author, which defaults to the current user.
+
+The snapshot is stored again with the note added, which changes its ID, like
+modifying its tags. The ID of the snapshot before the first modification is
+kept as the original ID. The snapshot ID "latest" selects the latest snapshot
+matching the host, tag and path filter criteria.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	Example:           `restic note add latest "restore tested 2024-06-01 OK"`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNoteAdd(cmd.Context(), noteAddOptions, globalOptions, args)
+	},
+}
+
+// NoteAddOptions bundles all options for the 'note add' command.
+type NoteAddOptions struct {
+	restic.SnapshotFilter
+	Author string
+}
+
+var noteAddOptions NoteAddOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdNote)
+	cmdNote.AddCommand(cmdNoteAdd)
+
+	f := cmdNoteAdd.Flags()
+	f.StringVar(&noteAddOptions.Author, "author", "", "record `name` as the author of the note (default: current user)")
+	initSingleSnapshotFilter(f, &noteAddOptions.SnapshotFilter)
+}
+
+// addSnapshotNote stores sn again with note added and removes the old
+// snapshot. It returns the ID of the new snapshot.
+func addSnapshotNote(ctx context.Context, repo *repository.Repository, sn *restic.Snapshot, note restic.SnapshotNote) (restic.ID, error) {
+	sn.AddNote(note)
+
+	// Retain the original snapshot id over all modifications.
+	if sn.Original == nil {
+		sn.Original = sn.ID()
+	}
+
+	id, err := restic.SaveSnapshot(ctx, repo, sn)
+	if err != nil {
+		return restic.ID{}, err
+	}
+	debug.Log("new snapshot saved as %v", id)
+
+	if err = repo.RemoveUnpacked(ctx, restic.SnapshotFile, *sn.ID()); err != nil {
+		return restic.ID{}, err
+	}
+	debug.Log("old snapshot %v removed", sn.ID())
+
+	return id, nil
+}
+
+func runNoteAdd(ctx context.Context, opts NoteAddOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 2 {
+		return errors.Fatal("please specify a snapshot ID and the text of the note")
+	}
+	text := strings.TrimSpace(args[1])
+	if text == "" {
+		return errors.Fatal("the text of the note is empty")
+	}
+
+	author := opts.Author
+	if author == "" {
+		if usr, err := user.Current(); err == nil {
+			author = usr.Username
+		} else {
+			debug.Log("unable to determine the current user: %v", err)
+		}
+	}
+
+	Verbosef("create exclusive lock for repository\n")
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sn, _, err := opts.SnapshotFilter.FindLatest(ctx, repo, repo, args[0])
+	if err != nil {
+		return errors.Fatalf("failed to find snapshot: %v", err)
+	}
+
+	id, err := addSnapshotNote(ctx, repo, sn, restic.SnapshotNote{
+		Time:   time.Now(),
+		Author: author,
+		Text:   text,
+	})
+	if err != nil {
+		return errors.Fatalf("unable to add the note to snapshot %v: %v", sn.ID().Str(), err)
+	}
+
+	Verbosef("added note to snapshot %v, saved as %v\n", sn.ID().Str(), id.Str())
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunNoteAdd(t testing.TB, opts NoteAddOptions, gopts GlobalOptions, args ...string) {
+	rtest.OK(t, runNoteAdd(context.TODO(), opts, gopts, args))
+}
+
+func TestNoteAdd(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	newest, _ := testRunSnapshots(t, env.gopts)
+	rtest.Assert(t, newest != nil, "expected a new backup, got nil")
+	originalID := *newest.ID
+
+	testRunNoteAdd(t, NoteAddOptions{Author: "alice"}, env.gopts, "latest", "restore tested 2024-06-01 OK")
+	testRunNoteAdd(t, NoteAddOptions{}, env.gopts, "latest", "second drill")
+	testRunCheck(t, env.gopts)
+
+	newest, snapmap := testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, 1, len(snapmap))
+	rtest.Assert(t, newest.Original != nil && *newest.Original == originalID,
+		"expected original ID %v, got %v", originalID, newest.Original)
+	rtest.Equals(t, 2, len(newest.Notes))
+	rtest.Equals(t, "alice", newest.Notes[0].Author)
+	rtest.Equals(t, "restore tested 2024-06-01 OK", newest.Notes[0].Text)
+	rtest.Equals(t, "second drill", newest.Notes[1].Text)
+
+	buf, err := withCaptureStdout(func() error {
+		return runSnapshots(context.TODO(), SnapshotOptions{WithNotes: true}, env.gopts, nil)
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(buf.String(), "alice: restore tested 2024-06-01 OK"),
+		"note missing in output: %v", buf.String())
+
+	err = runNoteAdd(context.TODO(), NoteAddOptions{}, env.gopts, []string{"latest", " "})
+	rtest.Assert(t, err != nil, "missing error for empty note")
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"math"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/restic/restic/internal/catalog"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/event"
+	"github.com/restic/restic/internal/ui/metrics"
 	"github.com/restic/restic/internal/ui/progress"
 	"github.com/restic/restic/internal/ui/termstatus"
 
@@ -162,18 +167,58 @@ func runPrune(ctx context.Context, opts PruneOptions, gopts GlobalOptions, term
 }
 
 func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo *repository.Repository, ignoreSnapshots restic.IDSet, term *termstatus.Terminal) error {
+	_, err := pruneWithRepo(ctx, opts, gopts, repo, ignoreSnapshots, term)
+	return err
+}
+
+// pruneWithRepo prunes the repository like runPruneWithRepo and returns the
+// statistics of the executed plan.
+func pruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOptions, repo *repository.Repository, ignoreSnapshots restic.IDSet, term *termstatus.Terminal) (repository.PruneStats, error) {
+	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	events := newEventPrinter("prune", gopts, term)
+	if events != nil {
+		printer = events
+	}
+
 	if repo.Cache == nil {
-		Print("warning: running prune without a cache, this may be very slow!\n")
+		if events != nil {
+			events.Warn("running prune without a cache, this may be very slow!")
+		} else {
+			Print("warning: running prune without a cache, this may be very slow!\n")
+		}
 	}
 
-	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	if gopts.MetricsListen != "" {
+		printer = metrics.NewPrinter(printer, "prune")
+	}
 
 	printer.P("loading indexes...\n")
 	// loading the index before the snapshots is ok, as we use an exclusive lock here
 	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	if events != nil {
+		bar = events.NewCounter("index files loaded")
+	}
 	err := repo.LoadIndex(ctx, bar)
 	if err != nil {
-		return err
+		return repository.PruneStats{}, err
+	}
+
+	// the snapshot list is also used to update the stats cache
+	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+	if err != nil {
+		return repository.PruneStats{}, err
+	}
+	snapshots, err := listIDs(ctx, snapshotLister, restic.SnapshotFile)
+	if err != nil {
+		return repository.PruneStats{}, err
+	}
+	for id := range ignoreSnapshots {
+		snapshots.Delete(id)
+	}
+	// the stats cache is updated if it matches the repository before prune
+	var cache *statsCache
+	if !opts.DryRun && !opts.unsafeRecovery {
+		cache = loadMatchingStatsCache(repo, snapshots, repo.IndexIDs())
 	}
 
 	popts := repository.PruneOptions{
@@ -188,29 +233,120 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts GlobalOption
 		RepackUncompressed: opts.RepackUncompressed,
 	}
 
+	expiredTrash := restic.NewIDSet()
+	var reclaim *reclaimStats
 	plan, err := repository.PlanPrune(ctx, popts, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
-		return getUsedBlobs(ctx, repo, usedBlobs, ignoreSnapshots, printer)
+		err := getUsedBlobs(ctx, repo, snapshotLister, usedBlobs, ignoreSnapshots, expiredTrash, printer)
+		if err != nil || !popts.DryRun {
+			return err
+		}
+		removed := ignoreSnapshots.Clone()
+		removed.Merge(expiredTrash)
+		reclaim, err = attributeReclaim(ctx, repo, usedBlobs, removed, printer)
+		return err
 	}, printer)
 	if err != nil {
-		return err
+		return repository.PruneStats{}, err
 	}
 	if ctx.Err() != nil {
-		return ctx.Err()
+		return repository.PruneStats{}, ctx.Err()
+	}
+
+	// the snapshots must be removed before their data
+	if len(expiredTrash) > 0 {
+		if popts.DryRun {
+			printer.P("would remove %d snapshots from the trash whose trash period has expired\n", len(expiredTrash))
+		} else {
+			printer.P("removing %d snapshots from the trash whose trash period has expired\n", len(expiredTrash))
+			bar := printer.NewCounter("files deleted")
+			err = restic.ParallelRemove(ctx, repo, expiredTrash, restic.SnapshotFile, nil, bar)
+			bar.Done()
+			if err != nil {
+				return repository.PruneStats{}, errors.Fatalf("unable to remove snapshots from the trash: %v", err)
+			}
+		}
 	}
 
 	if popts.DryRun {
 		printer.P("\nWould have made the following changes:")
 	}
 
-	err = printPruneStats(printer, plan.Stats())
+	stats := plan.Stats()
+	err = printPruneStats(printer, stats)
 	if err != nil {
-		return err
+		return stats, err
+	}
+	if reclaim != nil {
+		printReclaimStats(printer, reclaim)
 	}
 
 	// Trigger GC to reset garbage collection threshold
 	runtime.GC()
 
-	return plan.Execute(ctx, printer)
+	err = plan.Execute(ctx, printer)
+	if err != nil {
+		return stats, err
+	}
+	if events != nil {
+		events.Summary(newPruneSummary(stats, popts.DryRun, len(expiredTrash)))
+	}
+	if popts.DryRun {
+		return stats, nil
+	}
+
+	if cache != nil {
+		for id := range expiredTrash {
+			snapshots.Delete(id)
+		}
+		updateStatsCacheAfterPrune(ctx, repo, cache, snapshots, plan.IndexIDs())
+	} else {
+		// the index files have changed, the next stats run rebuilds the cache
+		saveStatsCache(repo, nil)
+	}
+	return stats, nil
+}
+
+// pruneSummary is the summary event of the prune command. For a dry run, it
+// contains the changes which would have been made.
+type pruneSummary struct {
+	event.Header
+	DryRun           bool   `json:"dry_run"`
+	RemovedSnapshots int    `json:"removed_snapshots"`
+	UsedBlobs        uint   `json:"used_blobs"`
+	UsedSize         uint64 `json:"used_size"`
+	DuplicateBlobs   uint   `json:"duplicate_blobs"`
+	DuplicateSize    uint64 `json:"duplicate_size"`
+	UnusedBlobs      uint   `json:"unused_blobs"`
+	UnusedSize       uint64 `json:"unused_size"`
+	UnreferencedSize uint64 `json:"unreferenced_size"`
+	RepackBlobs      uint   `json:"repack_blobs"`
+	RepackSize       uint64 `json:"repack_size"`
+	RemovedBlobs     uint   `json:"removed_blobs"`
+	RemovedSize      uint64 `json:"removed_size"`
+	KeptPacks        uint   `json:"kept_packs"`
+	RepackedPacks    uint   `json:"repacked_packs"`
+	RemovedPacks     uint   `json:"removed_packs"`
+}
+
+func newPruneSummary(stats repository.PruneStats, dryRun bool, removedSnapshots int) *pruneSummary {
+	return &pruneSummary{
+		DryRun:           dryRun,
+		RemovedSnapshots: removedSnapshots,
+		UsedBlobs:        stats.Blobs.Used,
+		UsedSize:         stats.Size.Used,
+		DuplicateBlobs:   stats.Blobs.Duplicate,
+		DuplicateSize:    stats.Size.Duplicate,
+		UnusedBlobs:      stats.Blobs.Unused,
+		UnusedSize:       stats.Size.Unused,
+		UnreferencedSize: stats.Size.Unref,
+		RepackBlobs:      stats.Blobs.Repack,
+		RepackSize:       stats.Size.Repack,
+		RemovedBlobs:     stats.Blobs.Remove + stats.Blobs.Repackrm,
+		RemovedSize:      stats.Size.Remove + stats.Size.Repackrm + stats.Size.Unref,
+		KeptPacks:        stats.Packs.Keep,
+		RepackedPacks:    stats.Packs.Repack,
+		RemovedPacks:     stats.Packs.Remove + stats.Packs.Unref,
+	}
 }
 
 // printPruneStats prints out the statistics
@@ -255,15 +391,33 @@ func printPruneStats(printer progress.Printer, stats repository.PruneStats) erro
 	return nil
 }
 
-func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet, ignoreSnapshots restic.IDSet, printer progress.Printer) error {
-	var snapshotTrees restic.IDs
+// getUsedBlobs collects the blobs referenced by all snapshots listed by
+// snapshotLister except those in ignoreSnapshots. Snapshots in the trash whose trash period has expired are
+// added to expiredTrash instead. For metadata-only snapshots, whose data is
+// stored in a different repository, only the tree blobs are collected.
+func getUsedBlobs(ctx context.Context, repo restic.Repository, snapshotLister restic.Lister, usedBlobs restic.FindBlobSet, ignoreSnapshots restic.IDSet, expiredTrash restic.IDSet, printer progress.Printer) error {
+	var snapshotTrees, metadataOnlyTrees, catalogs restic.IDs
+	now := time.Now()
 	printer.P("loading all snapshots...\n")
-	err := restic.ForAllSnapshots(ctx, repo, repo, ignoreSnapshots,
+	err := restic.ForAllSnapshots(ctx, snapshotLister, repo, ignoreSnapshots,
 		func(id restic.ID, sn *restic.Snapshot, err error) error {
 			if err != nil {
 				debug.Log("failed to load snapshot %v (error %v)", id, err)
 				return err
 			}
+			if sn.TrashExpired(now) {
+				debug.Log("trash period of snapshot %v has expired", id)
+				expiredTrash.Insert(id)
+				return nil
+			}
+			if sn.Catalog != nil {
+				catalogs = append(catalogs, *sn.Catalog)
+			}
+			if sn.MetadataOnly() {
+				debug.Log("add metadata-only snapshot %v (tree %v)", id, *sn.Tree)
+				metadataOnlyTrees = append(metadataOnlyTrees, *sn.Tree)
+				return nil
+			}
 			debug.Log("add snapshot %v (tree %v)", id, *sn.Tree)
 			snapshotTrees = append(snapshotTrees, *sn.Tree)
 			return nil
@@ -272,11 +426,145 @@ func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.
 		return errors.Fatalf("failed loading snapshot: %v", err)
 	}
 
-	printer.P("finding data that is still in use for %d snapshots\n", len(snapshotTrees))
+	printer.P("finding data that is still in use for %d snapshots\n", len(snapshotTrees)+len(metadataOnlyTrees))
+
+	bar := printer.NewCounter("snapshots")
+	bar.SetMax(uint64(len(snapshotTrees) + len(metadataOnlyTrees)))
+	defer bar.Done()
+
+	err = restic.FindUsedBlobs(ctx, repo, snapshotTrees, usedBlobs, bar)
+	if err != nil {
+		return err
+	}
+	for _, id := range catalogs {
+		if err := catalog.FindUsedBlobs(ctx, repo, id, usedBlobs); err != nil {
+			return err
+		}
+	}
+	// trees which are also used by regular snapshots were already visited
+	return restic.FindUsedTrees(ctx, repo, metadataOnlyTrees, usedBlobs, bar)
+}
+
+// reclaimStats attributes the data which is only referenced by removed
+// snapshots to these snapshots and their hosts.
+type reclaimStats struct {
+	snapshots []*restic.Snapshot
+	// bySnapshot and byHost contain the size of the data which is only
+	// referenced by a single snapshot or by snapshots of a single host.
+	bySnapshot map[restic.ID]uint64
+	byHost     map[string]uint64
+	// sharedSnapshots and sharedHosts contain the size of the data which is
+	// referenced by several snapshots or hosts.
+	sharedSnapshots uint64
+	sharedHosts     uint64
+}
 
+// reclaimOwner tracks which removed snapshots reference an unused blob.
+type reclaimOwner struct {
+	snapshot restic.ID
+	host     string
+	size     uint64
+
+	snapshots, hosts int
+}
+
+// attributeReclaim finds the blobs of the removed snapshots which are not in
+// usedBlobs and attributes their size to the snapshots and hosts.
+func attributeReclaim(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet, removed restic.IDSet, printer progress.Printer) (*reclaimStats, error) {
+	stats := &reclaimStats{
+		bySnapshot: make(map[restic.ID]uint64),
+		byHost:     make(map[string]uint64),
+	}
+	if len(removed) == 0 {
+		return stats, nil
+	}
+
+	printer.P("attributing unused data to %d removed snapshots\n", len(removed))
 	bar := printer.NewCounter("snapshots")
-	bar.SetMax(uint64(len(snapshotTrees)))
+	bar.SetMax(uint64(len(removed)))
 	defer bar.Done()
 
-	return restic.FindUsedBlobs(ctx, repo, snapshotTrees, usedBlobs, bar)
+	owners := make(map[restic.BlobHandle]*reclaimOwner)
+	for id := range removed {
+		sn, err := restic.LoadSnapshot(ctx, repo, id)
+		if err != nil {
+			return nil, errors.Fatalf("failed loading snapshot %v: %v", id.Str(), err)
+		}
+		stats.snapshots = append(stats.snapshots, sn)
+
+		blobs := restic.NewBlobSet()
+		err = restic.FindUsedBlobs(ctx, repo, restic.IDs{*sn.Tree}, blobs, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for bh := range blobs {
+			if usedBlobs.Has(bh) {
+				continue
+			}
+			owner, ok := owners[bh]
+			if !ok {
+				pbs := repo.LookupBlob(bh.Type, bh.ID)
+				if len(pbs) == 0 {
+					continue
+				}
+				owners[bh] = &reclaimOwner{snapshot: id, host: sn.Hostname, size: uint64(pbs[0].Length), snapshots: 1, hosts: 1}
+				continue
+			}
+			owner.snapshots++
+			if owner.host != sn.Hostname {
+				owner.hosts++
+			}
+		}
+		bar.Add(1)
+	}
+
+	for _, owner := range owners {
+		if owner.snapshots == 1 {
+			stats.bySnapshot[owner.snapshot] += owner.size
+		} else {
+			stats.sharedSnapshots += owner.size
+		}
+		if owner.hosts == 1 {
+			stats.byHost[owner.host] += owner.size
+		} else {
+			stats.sharedHosts += owner.size
+		}
+	}
+
+	sort.Slice(stats.snapshots, func(i, j int) bool {
+		return stats.snapshots[i].Time.Before(stats.snapshots[j].Time)
+	})
+	return stats, nil
+}
+
+// printReclaimStats prints the space which is freed by removing each snapshot
+// and the snapshots of each host.
+func printReclaimStats(printer progress.Printer, stats *reclaimStats) {
+	if len(stats.snapshots) == 0 {
+		return
+	}
+
+	printer.P("space only used by removed snapshots:\n")
+	for _, sn := range stats.snapshots {
+		printer.P("  %v  %v  %-20s %12s\n", sn.ID().Str(), sn.Time.Local().Format(TimeFormat), sn.Hostname, ui.FormatBytes(stats.bySnapshot[*sn.ID()]))
+	}
+	if stats.sharedSnapshots > 0 {
+		printer.P("  shared by several removed snapshots: %s\n", ui.FormatBytes(stats.sharedSnapshots))
+	}
+
+	hosts := make([]string, 0, len(stats.byHost))
+	for host := range stats.byHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	printer.P("\nspace only used by removed snapshots per host:\n")
+	for _, host := range hosts {
+		printer.P("  %-20s %12s\n", host, ui.FormatBytes(stats.byHost[host]))
+	}
+	if stats.sharedHosts > 0 {
+		printer.P("  shared by several hosts: %s\n", ui.FormatBytes(stats.sharedHosts))
+	}
+	printer.P("\nthis space is freed once the pack files containing the data are deleted or repacked\n\n")
 }
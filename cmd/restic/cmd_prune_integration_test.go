@@ -8,7 +8,9 @@ import (
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
 	"github.com/restic/restic/internal/ui/termstatus"
 )
 
@@ -236,3 +238,35 @@ func testEdgeCaseRepo(t *testing.T, tarfile string, optionsCheck CheckOptions, o
 			"prune should have reported an error")
 	}
 }
+
+func TestPruneReclaimAttribution(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	firstSnapshot := testListSnapshots(t, env.gopts, 1)[0]
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+
+	// prune loads the index before the snapshots
+	env.gopts.backendTestHook = nil
+	ctx, repo, unlock, err := openWithExclusiveLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	defer unlock()
+	rtest.OK(t, repo.LoadIndex(ctx, nil))
+
+	printer := &progress.NoopPrinter{}
+	removed := restic.NewIDSet(firstSnapshot)
+	usedBlobs := restic.NewBlobSet()
+	rtest.OK(t, getUsedBlobs(ctx, repo, repo, usedBlobs, removed, restic.NewIDSet(), printer))
+
+	stats, err := attributeReclaim(ctx, repo, usedBlobs, removed, printer)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(stats.snapshots))
+
+	size := stats.bySnapshot[firstSnapshot]
+	rtest.Assert(t, size > 0, "no space attributed to the removed snapshot")
+	rtest.Equals(t, map[string]uint64{stats.snapshots[0].Hostname: size}, stats.byHost)
+	rtest.Equals(t, uint64(0), stats.sharedSnapshots)
+}
@@ -2,11 +2,18 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"runtime"
+	"sync"
 	"time"
 
+	"github.com/restic/restic/internal/catalog"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/event"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/restic/restic/internal/ui/termstatus"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 )
@@ -26,7 +33,9 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		return runRecover(cmd.Context(), globalOptions)
+		term, cancel := setupTermstatus()
+		defer cancel()
+		return runRecover(cmd.Context(), globalOptions, term)
 	},
 }
 
@@ -34,7 +43,13 @@ func init() {
 	cmdRoot.AddCommand(cmdRecover)
 }
 
-func runRecover(ctx context.Context, gopts GlobalOptions) error {
+func runRecover(ctx context.Context, gopts GlobalOptions, term *termstatus.Terminal) error {
+	printer := newTerminalProgressPrinter(gopts.verbosity, term)
+	events := newEventPrinter("recover", gopts, term)
+	if events != nil {
+		printer = events
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		return err
@@ -51,67 +66,74 @@ func runRecover(ctx context.Context, gopts GlobalOptions) error {
 		return err
 	}
 
-	Verbosef("load index files\n")
-	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	printer.P("load index files\n")
+	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
+	if events != nil {
+		bar = events.NewCounter("index files loaded")
+	}
 	if err = repo.LoadIndex(ctx, bar); err != nil {
 		return err
 	}
 
-	// trees maps a tree ID to whether or not it is referenced by a different
-	// tree. If it is not referenced, we have a root tree.
-	trees := make(map[restic.ID]bool)
-
+	trees := restic.NewIDSet()
 	err = repo.ListBlobs(ctx, func(blob restic.PackedBlob) {
 		if blob.Type == restic.TreeBlob {
-			trees[blob.Blob.ID] = false
+			trees.Insert(blob.Blob.ID)
 		}
 	})
 	if err != nil {
 		return err
 	}
 
-	Verbosef("load %d trees\n", len(trees))
-	bar = newProgressMax(!gopts.Quiet, uint64(len(trees)), "trees loaded")
-	for id := range trees {
-		tree, err := restic.LoadTree(ctx, repo, id)
-		if ctx.Err() != nil {
-			return ctx.Err()
-		}
-		if err != nil {
-			Warnf("unable to load tree %v: %v\n", id.Str(), err)
-			continue
-		}
-
-		for _, node := range tree.Nodes {
-			if node.Type == "dir" && node.Subtree != nil {
-				trees[*node.Subtree] = true
-			}
-		}
-		bar.Add(1)
-	}
+	printer.P("load %d trees\n", len(trees))
+	bar = printer.NewCounter("trees loaded")
+	bar.SetMax(uint64(len(trees)))
+	referenced, err := loadReferencedTrees(ctx, repo, trees, bar, printer)
 	bar.Done()
+	if err != nil {
+		return err
+	}
 
-	Verbosef("load snapshots\n")
+	printer.P("load snapshots\n")
 	err = restic.ForAllSnapshots(ctx, snapshotLister, repo, nil, func(_ restic.ID, sn *restic.Snapshot, _ error) error {
-		trees[*sn.Tree] = true
+		referenced.Insert(*sn.Tree)
+		if sn.Catalog != nil {
+			// the catalog is stored as tree blobs which are no root trees
+			referenced.Insert(*sn.Catalog)
+			blobs := restic.NewBlobSet()
+			if err := catalog.FindUsedBlobs(ctx, repo, *sn.Catalog, blobs); err != nil {
+				printer.E("unable to load catalog of snapshot %v: %v\n", sn.ID().Str(), err)
+			}
+			for h := range blobs {
+				referenced.Insert(h.ID)
+			}
+		}
 		return nil
 	})
 	if err != nil {
 		return err
 	}
-	Verbosef("done\n")
+	printer.P("done\n")
 
+	// trees which are not referenced by a different tree or a snapshot are
+	// root trees
 	roots := restic.NewIDSet()
-	for id, seen := range trees {
-		if !seen {
-			Verboseff("found root tree %v\n", id.Str())
+	for id := range trees {
+		if !referenced.Has(id) {
+			printer.V("found root tree %v\n", id.Str())
 			roots.Insert(id)
 		}
 	}
-	Printf("\nfound %d unreferenced roots\n", len(roots))
+	summary := &recoverSummary{Trees: len(trees), UnreferencedRoots: len(roots)}
+	if events == nil {
+		term.Print(fmt.Sprintf("\nfound %d unreferenced roots\n", len(roots)))
+	}
 
 	if len(roots) == 0 {
-		Verbosef("no snapshot to write.\n")
+		printer.P("no snapshot to write.\n")
+		if events != nil {
+			events.Summary(summary)
+		}
 		return nil
 	}
 
@@ -155,23 +177,92 @@ func runRecover(ctx context.Context, gopts GlobalOptions) error {
 		return err
 	}
 
-	return createSnapshot(ctx, "/recover", hostname, []string{"recovered"}, repo, &treeID)
+	id, err := createSnapshot(ctx, "/recover", hostname, []string{"recovered"}, repo, &treeID)
+	if err != nil {
+		return err
+	}
+	if events != nil {
+		summary.SnapshotID = id.String()
+		events.Summary(summary)
+	} else {
+		term.Print(fmt.Sprintf("saved new snapshot %v\n", id.Str()))
+	}
+	return nil
+}
 
+// recoverSummary is the summary event of the recover command.
+type recoverSummary struct {
+	event.Header
+	Trees             int    `json:"trees"`
+	UnreferencedRoots int    `json:"unreferenced_roots"`
+	SnapshotID        string `json:"snapshot_id,omitempty"`
 }
 
-func createSnapshot(ctx context.Context, name, hostname string, tags []string, repo restic.SaverUnpacked, tree *restic.ID) error {
+// loadReferencedTrees loads the trees using several workers in parallel and
+// returns the IDs of their subtrees. Trees which cannot be loaded are reported
+// and skipped. Only the IDs of the trees are passed to the workers, such that
+// the memory usage does not depend on the number of trees waiting to be loaded.
+func loadReferencedTrees(ctx context.Context, repo restic.Repository, trees restic.IDSet, bar *progress.Counter, printer progress.Printer) (restic.IDSet, error) {
+	var mu sync.Mutex
+	referenced := restic.NewIDSet()
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	ch := make(chan restic.ID)
+	wg.Go(func() error {
+		defer close(ch)
+		for id := range trees {
+			select {
+			case <-wgCtx.Done():
+				return wgCtx.Err()
+			case ch <- id:
+			}
+		}
+		return nil
+	})
+
+	// decoding a tree can take quite some time such that this can be both
+	// CPU- or IO-bound
+	workerCount := int(repo.Connections()) + runtime.GOMAXPROCS(0)
+	for i := 0; i < workerCount; i++ {
+		wg.Go(func() error {
+			for id := range ch {
+				tree, err := restic.LoadTree(wgCtx, repo, id)
+				if wgCtx.Err() != nil {
+					return wgCtx.Err()
+				}
+				if err != nil {
+					printer.E("unable to load tree %v: %v\n", id.Str(), err)
+					bar.Add(1)
+					continue
+				}
+
+				mu.Lock()
+				for _, node := range tree.Nodes {
+					if node.Type == "dir" && node.Subtree != nil {
+						referenced.Insert(*node.Subtree)
+					}
+				}
+				mu.Unlock()
+				bar.Add(1)
+			}
+			return nil
+		})
+	}
+
+	return referenced, wg.Wait()
+}
+
+func createSnapshot(ctx context.Context, name, hostname string, tags []string, repo restic.SaverUnpacked, tree *restic.ID) (restic.ID, error) {
 	sn, err := restic.NewSnapshot([]string{name}, tags, hostname, time.Now())
 	if err != nil {
-		return errors.Fatalf("unable to save snapshot: %v", err)
+		return restic.ID{}, errors.Fatalf("unable to save snapshot: %v", err)
 	}
 
 	sn.Tree = tree
 
 	id, err := restic.SaveSnapshot(ctx, repo, sn)
 	if err != nil {
-		return errors.Fatalf("unable to save snapshot: %v", err)
+		return restic.ID{}, errors.Fatalf("unable to save snapshot: %v", err)
 	}
-
-	Printf("saved new snapshot %v\n", id.Str())
-	return nil
+	return id, nil
 }
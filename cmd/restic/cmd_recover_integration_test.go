@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+func testRunRecover(t testing.TB, gopts GlobalOptions) {
+	rtest.OK(t, withTermStatus(gopts, func(ctx context.Context, term *termstatus.Terminal) error {
+		return runRecover(ctx, gopts, term)
+	}))
+}
+
+func TestRecover(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+	ids := testListSnapshots(t, env.gopts, 1)
+
+	ctx, repo, unlock, err := openWithReadLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	sn, err := restic.LoadSnapshot(ctx, repo, ids[0])
+	unlock()
+	rtest.OK(t, err)
+
+	testRunForget(t, env.gopts, ForgetOptions{}, ids[0].String())
+	testListSnapshots(t, env.gopts, 0)
+
+	testRunRecover(t, env.gopts)
+	ids = testListSnapshots(t, env.gopts, 1)
+
+	ctx, repo, unlock, err = openWithReadLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	defer unlock()
+	rtest.OK(t, repo.LoadIndex(ctx, nil))
+	recovered, err := restic.LoadSnapshot(ctx, repo, ids[0])
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"recovered"}, recovered.Tags)
+
+	// the recovered snapshot contains the lost root tree as its only directory
+	tree, err := restic.LoadTree(ctx, repo, *recovered.Tree)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(tree.Nodes))
+	rtest.Equals(t, *sn.Tree, *tree.Nodes[0].Subtree)
+
+	// the trees are referenced again
+	testRunRecover(t, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+}
+
+func TestRecoverCatalog(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{Catalog: true}, env.gopts)
+
+	// the catalog blobs are no unreferenced root trees
+	buf := bytes.NewBuffer(nil)
+	gopts := env.gopts
+	gopts.stdout = buf
+	gopts.Quiet = false
+	gopts.verbosity = 1
+	testRunRecover(t, gopts)
+	rtest.Assert(t, strings.Contains(buf.String(), "no snapshot to write"), "unexpected output %v", buf.String())
+	testListSnapshots(t, env.gopts, 1)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var cmdRepackToTape = &cobra.Command{
+	Use:   "repack-to-tape --to tape:location",
+	Short: "Copy the repository into the container format for tapes",
+	Long: `
+The "repack-to-tape" command copies all files of the repository to a new
+repository location with the "tape:" prefix. The pack files are streamed
+sequentially into large container files, all other files are copied unchanged.
+The copy uses the same password, keys and repository ID as the original
+repository.
+
+The configuration file is copied last, thus an interrupted copy is not a valid
+repository. Remove the incomplete copy and run the command again.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	Example:           `restic -r /srv/restic-repo repack-to-tape --to tape:/mnt/ltfs/restic-repo`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRepackToTape(cmd.Context(), repackToTapeOptions, globalOptions, args)
+	},
+}
+
+// RepackToTapeOptions collects all options for the repack-to-tape command.
+type RepackToTapeOptions struct {
+	To string
+}
+
+var repackToTapeOptions RepackToTapeOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdRepackToTape)
+
+	f := cmdRepackToTape.Flags()
+	f.StringVar(&repackToTapeOptions.To, "to", "", "`repository` location with the tape: prefix to copy the repository to")
+}
+
+func runRepackToTape(ctx context.Context, opts RepackToTapeOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the repack-to-tape command expects no arguments, only options - please see `restic help repack-to-tape` for usage and flags")
+	}
+	if opts.To == "" {
+		return errors.Fatal("please specify the destination using --to")
+	}
+	if !strings.HasPrefix(opts.To, "tape:") {
+		return errors.Fatalf("the destination %v does not use the tape: prefix", location.StripPassword(gopts.backends, opts.To))
+	}
+
+	// the lock prevents prune from removing files during the copy
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	repoLocation, err := ReadRepo(gopts)
+	if err != nil {
+		return err
+	}
+	src, err := open(ctx, repoLocation, gopts, gopts.extended)
+	if err != nil {
+		return err
+	}
+	src = newRetryBackend(src)
+	defer func() {
+		_ = src.Close()
+	}()
+
+	dst, err := create(ctx, opts.To, gopts, gopts.extended)
+	if err != nil {
+		return err
+	}
+	dst = newRetryBackend(dst)
+
+	Verbosef("copying repository %v to %v\n", repo.Config().ID[:10], location.StripPassword(gopts.backends, opts.To))
+	if err := repackToTape(ctx, src, dst, gopts); err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	// writes the last container
+	return dst.Close()
+}
+
+// repackToTape copies all files except locks from src to dst. The config file
+// is copied last.
+func repackToTape(ctx context.Context, src, dst backend.Backend, gopts GlobalOptions) error {
+	for _, t := range []backend.FileType{backend.PackFile, backend.IndexFile, backend.SnapshotFile, backend.KeyFile} {
+		var files []backend.FileInfo
+		var size uint64
+		err := src.List(ctx, t, func(fi backend.FileInfo) error {
+			files = append(files, fi)
+			size += uint64(fi.Size)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		bar := newProgressMax(!gopts.Quiet, uint64(len(files)), t.String()+" files copied")
+		for _, fi := range files {
+			if err := copyRawFile(ctx, src, dst, backend.Handle{Type: t, Name: fi.Name}); err != nil {
+				bar.Done()
+				return err
+			}
+			bar.Add(1)
+		}
+		bar.Done()
+
+		Verbosef("copied %d %v files (%v)\n", len(files), t, ui.FormatBytes(size))
+	}
+
+	return copyRawFile(ctx, src, dst, backend.Handle{Type: backend.ConfigFile})
+}
+
+func copyRawFile(ctx context.Context, src, dst backend.Backend, h backend.Handle) error {
+	var buf []byte
+	err := src.Load(ctx, h, 0, 0, func(rd io.Reader) error {
+		var err error
+		buf, err = io.ReadAll(rd)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "load %v", h)
+	}
+
+	return errors.Wrapf(dst.Save(ctx, h, backend.NewByteReader(buf, dst.Hasher())), "save %v", h)
+}
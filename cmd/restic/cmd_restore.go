@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/restic/restic/internal/debug"
@@ -9,6 +11,7 @@ import (
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/restorer"
 	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/metrics"
 	restoreui "github.com/restic/restic/internal/ui/restore"
 	"github.com/restic/restic/internal/ui/termstatus"
 
@@ -28,6 +31,68 @@ repository.
 To only restore a specific subfolder, you can use the "<snapshotID>:<subfolder>"
 syntax, where "subfolder" is a path within the snapshot.
 
+The "--byte-range offset:length" option restores only the selected part of each
+file, for example "--byte-range 10G:2G". Only the data covering the range is
+downloaded from the repository. Usually, it is combined with "--include" to
+select a single large file.
+
+The "--since" option only restores files and directories which are missing or
+differ in the given other snapshot, for example to undo the changes made since
+an earlier snapshot by restoring the earlier snapshot with "--since" pointing to
+the later one.
+
+When a snapshot contains the root of a volume, like C:\, restic records the
+volume label, serial number, file system, cluster size, size and quota settings.
+The "--volume-report" option compares this information with the volume
+containing the target directory without restoring anything.
+
+On Windows, the target can be an SMB share in the form "smb://server/share/path",
+which is accessed without assigning a drive letter. Use "--smb-user" to connect
+with other credentials than those of the current user, the password is read
+from the environment variable RESTIC_SMB_PASSWORD or requested interactively.
+
+While restoring, restic records the completely restored files in a journal in
+the temporary directory. If a restore is interrupted, for example by a crash or
+a network outage, run the same command again with "--resume" to skip the files
+which were already restored instead of starting over. Partially restored files
+are checked and only their missing parts are downloaded.
+
+The "--preflight" option checks the target before restoring anything: restic
+compares the size of the selected files with the free space of the target and
+tests whether its file system supports extended attributes, alternate data
+streams, symlinks and sparse files and whether it accepts the longest file name
+and path. Problems which would make the restore fail abort it, the others are
+reported as warnings.
+
+The "--verify-security-descriptors" option, which is only available on Windows,
+re-reads the security descriptor of each restored file and reports an error if
+it differs from the one stored in the snapshot.
+
+The "--acl-inheritance" option, also only available on Windows, controls whether
+the restored permissions inherit from the directory they are restored into:
+"keep" restores the setting stored in the snapshot, "block" prevents inheriting
+any permissions and "merge" combines the explicit permissions of each file with
+those inherited from the target directory.
+
+The "--elevate" option, which is only available on Windows, starts a helper
+process with administrative privileges, which requires confirming a UAC prompt.
+The helper sets the security descriptors including owner and auditing
+information and creates symlinks, while all other data is restored by the
+unprivileged restore command.
+
+On Linux, the SELinux contexts and the capabilities of files are restored
+as well. Setting them requires root or, for SELinux contexts, the permission to
+relabel files. Failures are reported as errors. Use "--no-selinux" and
+"--no-caps" to skip them, for example to let the target system assign the
+default SELinux contexts.
+
+Restoring into directories of the operating system, like /etc, /usr or
+C:\Windows, or into the root of the boot volume is refused, as a mistyped or
+copy-pasted target could overwrite the running system. Pass
+"--allow-system-target" to restore there anyway. restic then prints the plan of
+the restore and asks to confirm it by passing the short ID of the snapshot to
+"--confirm".
+
 EXIT STATUS
 ===========
 
@@ -49,7 +114,32 @@ type RestoreOptions struct {
 	restic.SnapshotFilter
 	Sparse    bool
 	Verify    bool
+	AutoTune  bool
 	Overwrite restorer.OverwriteBehavior
+	Delta     bool
+	ByteRange string
+	SMBUser   string
+	Since     string
+	Resume    bool
+	Preflight bool
+
+	RewriteLinks bool
+
+	AllowSystemTarget bool
+	Confirm           string
+
+	VerifySecurityDescriptors bool
+	ACLInheritance            restorer.ACLInheritance
+	TranslatePermissions      bool
+	NoSELinux                 bool
+	NoCapabilities            bool
+	Elevate                   bool
+
+	VolumeReport bool
+
+	IncludeADSPatterns []string
+	ExcludeADSPatterns []string
+	Streams            restorer.StreamMode
 }
 
 var restoreOptions RestoreOptions
@@ -59,6 +149,7 @@ func init() {
 
 	flags := cmdRestore.Flags()
 	flags.StringVarP(&restoreOptions.Target, "target", "t", "", "directory to extract data to")
+	flags.StringVar(&restoreOptions.SMBUser, "smb-user", "", "connect to an smb://server/share/path target as `user` (password from $RESTIC_SMB_PASSWORD)")
 
 	initExcludePatternOptions(flags, &restoreOptions.excludePatternOptions)
 	initIncludePatternOptions(flags, &restoreOptions.includePatternOptions)
@@ -66,7 +157,55 @@ func init() {
 	initSingleSnapshotFilter(flags, &restoreOptions.SnapshotFilter)
 	flags.BoolVar(&restoreOptions.Sparse, "sparse", false, "restore files as sparse")
 	flags.BoolVar(&restoreOptions.Verify, "verify", false, "verify restored files content")
-	flags.Var(&restoreOptions.Overwrite, "overwrite", "overwrite behavior, one of (always|if-changed|if-newer|never) (default: always)")
+	flags.BoolVar(&restoreOptions.AutoTune, "auto-tune", false, "adjust the number of concurrent downloads to the backend latency and throughput")
+	flags.Var(&restoreOptions.Overwrite, "overwrite", "overwrite behavior, one of (always|if-changed|if-newer|never|fail) (default: always)")
+	flags.BoolVar(&restoreOptions.Delta, "delta", false, "reuse data of existing files which moved to a different offset instead of downloading it again")
+	flags.StringVar(&restoreOptions.ByteRange, "byte-range", "", "only restore the `offset:length` byte range of each file (allowed suffixes: k/K, m/M, g/G, t/T)")
+	flags.StringVar(&restoreOptions.Since, "since", "", "only restore files which were added or modified compared to `snapshot`")
+	flags.BoolVar(&restoreOptions.Resume, "resume", false, "continue an interrupted restore of the same snapshot to the same target")
+	flags.BoolVar(&restoreOptions.Preflight, "preflight", false, "check the free space and capabilities of the target before restoring and fail on blocking issues")
+	flags.BoolVar(&restoreOptions.RewriteLinks, "rewrite-links", false, "rewrite absolute symlink and junction targets within the restored paths to point into the target directory")
+	flags.BoolVar(&restoreOptions.AllowSystemTarget, "allow-system-target", false, "allow restoring into system directories like /etc, /usr, C:\\Windows or the root of the boot volume")
+	flags.StringVar(&restoreOptions.Confirm, "confirm", "", "confirm a restore into a system directory by passing the `id` of the snapshot")
+	if runtime.GOOS == "windows" {
+		flags.Var(&restoreOptions.ACLInheritance, "acl-inheritance", "inheritance of restored permissions from the target directory, one of (keep|block|merge) (default: keep)")
+		flags.BoolVar(&restoreOptions.VerifySecurityDescriptors, "verify-security-descriptors", false, "re-read the restored security descriptors and report differences as errors")
+		flags.BoolVar(&restoreOptions.Elevate, "elevate", false, "set security descriptors and create symlinks in a helper process with administrative privileges")
+		flags.StringArrayVar(&restoreOptions.IncludeADSPatterns, "include-ads-pattern", nil, "only restore alternate data streams whose name matches `pattern` (can be specified multiple times)")
+		flags.StringArrayVar(&restoreOptions.ExcludeADSPatterns, "exclude-ads-pattern", nil, "do not restore alternate data streams whose name matches `pattern`, e.g. Zone.Identifier (can be specified multiple times)")
+		flags.Var(&restoreOptions.Streams, "streams", "restore the alternate data streams of files, one of (all|only|skip) (default: all)")
+	}
+	if runtime.GOOS == "linux" {
+		flags.BoolVar(&restoreOptions.NoSELinux, "no-selinux", false, "do not restore the SELinux contexts of files")
+		flags.BoolVar(&restoreOptions.NoCapabilities, "no-caps", false, "do not restore the capabilities of files")
+	}
+	flags.BoolVar(&restoreOptions.TranslatePermissions, "translate-permissions", false, "synthesize approximate permissions for files backed up on a different operating system")
+	flags.BoolVar(&restoreOptions.VolumeReport, "volume-report", false, "only compare the volumes recorded in the snapshot with the target volume, do not restore anything")
+}
+
+// parseByteRange parses a byte range in the format "offset:length". The
+// length is optional, in which case the range extends to the end of the file.
+func parseByteRange(s string) (*restic.ByteRange, error) {
+	offsetStr, lengthStr, _ := strings.Cut(s, ":")
+	var r restic.ByteRange
+
+	offset, err := ui.ParseBytes(offsetStr)
+	if err != nil {
+		return nil, errors.Fatalf("invalid byte range %q: %v", s, err)
+	}
+	r.Offset = uint64(offset)
+
+	if lengthStr != "" {
+		length, err := ui.ParseBytes(lengthStr)
+		if err != nil {
+			return nil, errors.Fatalf("invalid byte range %q: %v", s, err)
+		}
+		if length == 0 {
+			return nil, errors.Fatalf("invalid byte range %q: length must be larger than zero", s)
+		}
+		r.Length = uint64(length)
+	}
+	return &r, nil
 }
 
 func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
@@ -100,6 +239,38 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		return errors.Fatal("exclude and include patterns are mutually exclusive")
 	}
 
+	var streams *restorer.StreamFilter
+	if len(opts.IncludeADSPatterns) > 0 || len(opts.ExcludeADSPatterns) > 0 {
+		if opts.Streams == restorer.StreamsSkip {
+			return errors.Fatal("--streams skip cannot be used together with --include-ads-pattern or --exclude-ads-pattern")
+		}
+		streams, err = restorer.NewStreamFilter(opts.IncludeADSPatterns, opts.ExcludeADSPatterns)
+		if err != nil {
+			return err
+		}
+	}
+
+	var byteRange *restic.ByteRange
+	if opts.ByteRange != "" {
+		if opts.Verify {
+			return errors.Fatal("--verify cannot be used together with --byte-range")
+		}
+		if opts.Resume {
+			return errors.Fatal("--resume cannot be used together with --byte-range")
+		}
+		byteRange, err = parseByteRange(opts.ByteRange)
+		if err != nil {
+			return err
+		}
+	}
+
+	target, disconnect, err := connectSMBTarget(ctx, opts.Target, opts.SMBUser)
+	if err != nil {
+		return err
+	}
+	defer disconnect()
+	opts.Target = target
+
 	snapshotIDString := args[0]
 
 	debug.Log("restore %v to %v", snapshotIDString, opts.Target)
@@ -118,6 +289,32 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 	if err != nil {
 		return errors.Fatalf("failed to find snapshot: %v", err)
 	}
+	if err := errMetadataOnly(sn); err != nil {
+		return err
+	}
+
+	var sinceSn *restic.Snapshot
+	var sinceSubfolder string
+	if opts.Since != "" {
+		sinceSn, sinceSubfolder, err = (&restic.SnapshotFilter{
+			Hosts: opts.Hosts,
+			Paths: opts.Paths,
+			Tags:  opts.Tags,
+		}).FindLatest(ctx, repo, repo, opts.Since)
+		if err != nil {
+			return errors.Fatalf("failed to find snapshot for --since: %v", err)
+		}
+		if sinceSubfolder == "" {
+			sinceSubfolder = subfolder
+		}
+	}
+
+	if opts.VolumeReport {
+		return printVolumeReport(gopts, sn, opts.Target)
+	}
+	if err := checkSystemTarget(opts, sn, subfolder); err != nil {
+		return err
+	}
 
 	bar := newIndexTerminalProgress(gopts.Quiet, gopts.JSON, term)
 	err = repo.LoadIndex(ctx, bar)
@@ -130,30 +327,93 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		return err
 	}
 
+	var since *sinceFilter
+	if sinceSn != nil {
+		sinceTree, err := restic.FindTreeDirectory(ctx, repo, sinceSn.Tree, sinceSubfolder)
+		if err != nil {
+			return err
+		}
+		since, err = newSinceFilter(ctx, repo, *sn.Tree, *sinceTree)
+		if err != nil {
+			return err
+		}
+	}
+
 	msg := ui.NewMessage(term, gopts.verbosity)
 	var printer restoreui.ProgressPrinter
 	if gopts.JSON {
-		printer = restoreui.NewJSONProgress(term)
+		printer = restoreui.NewJSONProgress(term, gopts.verbosity)
 	} else {
-		printer = restoreui.NewTextProgress(term)
+		printer = restoreui.NewTextProgress(term, gopts.verbosity)
+	}
+
+	var elevated *restorer.ElevatedHelper
+	if opts.Elevate {
+		elevated, err = startElevatedHelper(opts.Target)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = elevated.Close()
+		}()
+	}
+
+	var journal *restorer.Journal
+	if byteRange == nil {
+		journal, err = restorer.OpenJournal(opts.Target, *sn.Tree, opts.Resume)
+		if err != nil {
+			return errors.Fatalf("unable to open restore journal: %v", err)
+		}
+		defer func() {
+			if journal != nil {
+				_ = journal.Close()
+				Warnf("the restore can be continued using --resume\n")
+			}
+		}()
 	}
 
 	progress := restoreui.NewProgress(printer, calculateProgressInterval(!gopts.Quiet, gopts.JSON))
+	if gopts.MetricsListen != "" {
+		metrics.ObserveRestore(progress)
+	}
 	res := restorer.NewRestorer(repo, sn, restorer.Options{
 		Sparse:    opts.Sparse,
+		AutoTune:  opts.AutoTune,
 		Progress:  progress,
 		Overwrite: opts.Overwrite,
+		Delta:     opts.Delta,
+		ByteRange: byteRange,
+
+		VerifySecurityDescriptors: opts.VerifySecurityDescriptors,
+		ACLInheritance:            opts.ACLInheritance,
+		TranslatePermissions:      opts.TranslatePermissions,
+		SkipSELinux:               opts.NoSELinux,
+		SkipCapabilities:          opts.NoCapabilities,
+		Elevated:                  elevated,
+		Streams:                   streams,
+		StreamMode:                opts.Streams,
+		Journal:                   journal,
+		RewriteLinks:              opts.RewriteLinks,
+		Subfolder:                 subfolder,
 	})
 
 	totalErrors := 0
 	res.Error = func(location string, err error) error {
 		msg.E("ignoring error for %s: %s\n", location, err)
 		totalErrors++
+		metrics.CountError("restore")
 		return nil
 	}
 	res.Warn = func(message string) {
 		msg.E("Warning: %s\n", message)
 	}
+	res.ReportLink = func(location, oldTarget, newTarget string) {
+		if oldTarget == newTarget {
+			msg.VV("left link %s pointing to %s outside of the restored paths\n", location, oldTarget)
+		} else {
+			msg.VV("rewrote link %s from %s to %s\n", location, oldTarget, newTarget)
+		}
+	}
 
 	selectExcludeFilter := func(item string, _ string, node *restic.Node) (selectedForRestore bool, childMayBeSelected bool) {
 		matched := false
@@ -200,6 +460,30 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 		res.SelectFilter = selectIncludeFilter
 	}
 
+	if since != nil {
+		selectFilter := res.SelectFilter
+		res.SelectFilter = func(item string, dstpath string, node *restic.Node) (bool, bool) {
+			selected, childMayBeSelected := selectFilter(item, dstpath, node)
+			changed, mayContainChanges := since.Select(item)
+			return selected && changed, childMayBeSelected && mayContainChanges
+		}
+		if !gopts.JSON {
+			msg.P("found %d files and directories which differ from snapshot %s\n", len(since.changed), sinceSn.ID().Str())
+		}
+	}
+
+	if opts.Preflight {
+		err = runRestorePreflight(ctx, res, opts.Target, gopts, term, msg)
+		if err != nil {
+			if journal != nil {
+				// nothing was restored, there is nothing to resume
+				_ = journal.Close()
+				journal = nil
+			}
+			return err
+		}
+	}
+
 	if !gopts.JSON {
 		msg.P("restoring %s to %s\n", res.Snapshot(), opts.Target)
 	}
@@ -211,10 +495,23 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts GlobalOptions,
 
 	progress.Finish()
 
+	if opts.RewriteLinks && !gopts.JSON {
+		links := res.LinkReport()
+		msg.P("rewrote %d link targets, left %d link targets outside of the restored paths unchanged\n",
+			links.Rewritten, links.Unchanged)
+	}
+
 	if totalErrors > 0 {
 		return errors.Fatalf("There were %d errors\n", totalErrors)
 	}
 
+	if journal != nil {
+		if err := journal.Remove(); err != nil {
+			Warnf("unable to remove restore journal: %v\n", err)
+		}
+		journal = nil
+	}
+
 	if opts.Verify {
 		if !gopts.JSON {
 			msg.P("verifying files in %s\n", opts.Target)
@@ -436,3 +436,78 @@ func TestRestoreLocalLayout(t *testing.T) {
 		rtest.RemoveAll(t, target)
 	}
 }
+
+func TestRestoreSince(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	datadir := filepath.Join(env.base, "data")
+	for name, content := range map[string]string{
+		"a":     "unchanged",
+		"b":     "original",
+		"sub/c": "unchanged",
+		"sub/d": "removed later",
+	} {
+		p := filepath.Join(datadir, filepath.FromSlash(name))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, os.WriteFile(p, []byte(content), 0644))
+	}
+
+	opts := BackupOptions{}
+	testRunBackup(t, env.base, []string{"data"}, opts, env.gopts)
+	first := testListSnapshots(t, env.gopts, 1)[0]
+
+	rtest.OK(t, os.WriteFile(filepath.Join(datadir, "b"), []byte("modified"), 0644))
+	rtest.OK(t, os.Remove(filepath.Join(datadir, "sub", "d")))
+	rtest.OK(t, os.WriteFile(filepath.Join(datadir, "sub", "e"), []byte("added"), 0644))
+	testRunBackup(t, env.base, []string{"data"}, opts, env.gopts)
+	var second restic.ID
+	for _, id := range testListSnapshots(t, env.gopts, 2) {
+		if !id.Equal(first) {
+			second = id
+		}
+	}
+
+	restoredFiles := func(dir string) map[string]string {
+		files := make(map[string]string)
+		rtest.OK(t, filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			files[filepath.ToSlash(rel)] = string(data)
+			return err
+		}))
+		return files
+	}
+
+	for _, test := range []struct {
+		snapshot, since restic.ID
+		files           map[string]string
+	}{
+		{second, first, map[string]string{
+			"data/b":     "modified",
+			"data/sub/e": "added",
+		}},
+		{first, second, map[string]string{
+			"data/b":     "original",
+			"data/sub/d": "removed later",
+		}},
+		{first, first, map[string]string{}},
+	} {
+		target := filepath.Join(env.base, "restore-"+test.snapshot.Str()+"-"+test.since.Str())
+		rtest.OK(t, os.MkdirAll(target, 0755))
+		opts := RestoreOptions{
+			Target: target,
+			Since:  test.since.String(),
+		}
+		rtest.OK(t, testRunRestoreAssumeFailure(test.snapshot.String(), opts, env.gopts))
+		rtest.Equals(t, test.files, restoredFiles(target))
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseByteRange(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected restic.ByteRange
+	}{
+		{"0", restic.ByteRange{}},
+		{"100:", restic.ByteRange{Offset: 100}},
+		{"1k:2k", restic.ByteRange{Offset: 1024, Length: 2048}},
+		{"10G:1M", restic.ByteRange{Offset: 10 << 30, Length: 1 << 20}},
+	} {
+		r, err := parseByteRange(test.input)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.expected, *r)
+	}
+
+	for _, input := range []string{"", ":10", "abc", "10:x", "10:0", "-1:10"} {
+		_, err := parseByteRange(input)
+		rtest.Assert(t, err != nil, "missing error for %q", input)
+	}
+}
+
+func TestCompareVolumes(t *testing.T) {
+	source := fs.VolumeInfo{
+		Path:        `C:\`,
+		FileSystem:  "NTFS",
+		ClusterSize: 4096,
+		TotalSize:   1000 << 20,
+		UsedSize:    400 << 20,
+		Quota:       &fs.VolumeQuota{Enforced: true, DefaultThreshold: -1, DefaultLimit: 100 << 20},
+	}
+
+	target := source
+	target.Path = `D:\`
+	target.UsedSize = 0
+	rtest.Equals(t, 0, len(compareVolumes(source, target)))
+
+	for _, test := range []struct {
+		modify  func(v *fs.VolumeInfo)
+		warning string
+	}{
+		{func(v *fs.VolumeInfo) { v.TotalSize = 300 << 20 }, "smaller"},
+		{func(v *fs.VolumeInfo) { v.UsedSize = 700 << 20 }, "less free space"},
+		{func(v *fs.VolumeInfo) { v.FileSystem = "ReFS" }, "file system differs"},
+		{func(v *fs.VolumeInfo) { v.ClusterSize = 65536 }, "cluster size differs"},
+		{func(v *fs.VolumeInfo) { v.Quota = nil }, "quota settings of the target volume are unknown"},
+		{func(v *fs.VolumeInfo) { v.Quota = &fs.VolumeQuota{DefaultThreshold: -1, DefaultLimit: -1} }, "quota settings differ"},
+	} {
+		modified := target
+		test.modify(&modified)
+		warnings := compareVolumes(source, modified)
+		rtest.Equals(t, 1, len(warnings), test.warning)
+		rtest.Assert(t, strings.Contains(warnings[0], test.warning), "unexpected warning %q, expected %q", warnings[0], test.warning)
+	}
+}
+
+func TestParseSMBTarget(t *testing.T) {
+	for _, test := range []struct {
+		target, unc, share string
+		ok                 bool
+	}{
+		{"/srv/restore", "", "", false},
+		{`C:\restore`, "", "", false},
+		{"smb://server/share", `\\server\share`, `\\server\share`, true},
+		{"smb://server/share/", `\\server\share`, `\\server\share`, true},
+		{"SMB://server.example.com/share/dir/sub", `\\server.example.com\share\dir\sub`, `\\server.example.com\share`, true},
+	} {
+		unc, share, ok, err := parseSMBTarget(test.target)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.ok, ok, test.target)
+		rtest.Equals(t, test.unc, unc, test.target)
+		rtest.Equals(t, test.share, share, test.target)
+	}
+
+	for _, target := range []string{
+		"smb://server",
+		"smb://server/",
+		"smb:///share",
+		"smb://user@server/share",
+		"smb://server:445/share",
+	} {
+		_, _, _, err := parseSMBTarget(target)
+		rtest.Assert(t, err != nil, "missing error for %v", target)
+	}
+}
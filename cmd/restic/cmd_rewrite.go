@@ -216,6 +216,10 @@ func filterAndReplaceSnapshot(ctx context.Context, repo restic.Repository, sn *r
 	// Always set the original snapshot id as this essentially a new snapshot.
 	sn.Original = sn.ID()
 	sn.Tree = &filteredTree
+	// changes of removed files were recorded before the rewrite
+	sn.ChangeJournals = nil
+	// the catalog lists the paths of the original tree
+	sn.Catalog = nil
 
 	if !forget {
 		sn.AddTags([]string{addTag})
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/filter"
+)
+
+var cmdRules = &cobra.Command{
+	Use:   "rules",
+	Short: "Validate, test and export include and exclude rules",
+	Long: `
+The "rules" command helps to find out why a file is or is not backed up or
+restored. Its sub-commands accept the same include and exclude options as the
+"backup" and "restore" commands.
+
+Exclude rules can also be stored as named rule sets in the repository, which
+all backups of the repository can use via --rules-from-repo.
+	`,
+}
+
+var cmdRulesLint = &cobra.Command{
+	Use:   "lint [flags]",
+	Short: "Check include and exclude rules for mistakes",
+	Long: `
+The "rules lint" command checks the given include and exclude rules. Invalid
+patterns are reported as errors, patterns which are most likely a mistake, for
+example duplicates or negated patterns without effect, as warnings.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if no invalid rules were found, and non-zero otherwise.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRulesLint(cmd.Context(), rulesOptions, globalOptions, args)
+	},
+}
+
+var cmdRulesTest = &cobra.Command{
+	Use:   "test [flags] PATH [PATH...]",
+	Short: "Show which rule matches a path",
+	Long: `
+The "rules test" command shows whether the given paths are excluded or included
+by the rules, and which rule made the decision. A path is also excluded if one
+of its parent directories is excluded, as a backup does not descend into
+excluded directories. Relative paths are converted to absolute paths.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRulesTest(cmd.Context(), rulesOptions, globalOptions, args)
+	},
+}
+
+var cmdRulesExport = &cobra.Command{
+	Use:   "export [flags]",
+	Short: "Export the effective rules as JSON",
+	Long: `
+The "rules export" command prints all include and exclude rules in the order in
+which they are evaluated as JSON, including the rules read from files and
+presets and the location where each rule was specified.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRulesExport(cmd.Context(), rulesOptions, globalOptions, args)
+	},
+}
+
+// RulesOptions collects the include and exclude rules for the rules commands.
+type RulesOptions struct {
+	excludePatternOptions
+	includePatternOptions
+	ExcludePresets []string
+	RulesFromRepo  []string
+}
+
+var rulesOptions RulesOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdRules)
+	cmdRules.AddCommand(cmdRulesLint)
+	cmdRules.AddCommand(cmdRulesTest)
+	cmdRules.AddCommand(cmdRulesExport)
+
+	f := cmdRules.PersistentFlags()
+	initExcludePatternOptions(f, &rulesOptions.excludePatternOptions)
+	initIncludePatternOptions(f, &rulesOptions.includePatternOptions)
+	f.StringSliceVar(&rulesOptions.ExcludePresets, "exclude-preset", nil, "exclude the built-in `preset`s of well-known junk paths ("+strings.Join(filter.PresetNames(), ", ")+"), can be specified multiple times")
+	f.StringArrayVar(&rulesOptions.RulesFromRepo, "rules-from-repo", nil, "use the rule set `name[@version]` stored in the repository (can be specified multiple times)")
+}
+
+// rule is a single include or exclude pattern and the location where it was
+// specified.
+type rule struct {
+	Type            string `json:"type"`
+	Pattern         string `json:"pattern"`
+	CaseInsensitive bool   `json:"case_insensitive,omitempty"`
+	// List names the patterns which are evaluated together. A negated
+	// pattern only affects the previous patterns of the same list.
+	List   string `json:"list"`
+	Source string `json:"source"`
+	Line   int    `json:"line,omitempty"`
+}
+
+func (r rule) location() string {
+	if r.Line > 0 {
+		return fmt.Sprintf("%s:%d", r.Source, r.Line)
+	}
+	return r.Source
+}
+
+func (r rule) String() string {
+	return fmt.Sprintf("%s pattern %q (%s)", r.Type, r.Pattern, r.location())
+}
+
+// collectRules returns the rules in the order in which backup and restore
+// evaluate them.
+func collectRules(opts RulesOptions, ruleSets []repoRuleSet) ([]rule, error) {
+	var rules []rule
+	addPatterns := func(typ, list, flag string, insensitive bool, patterns []string) {
+		for _, pattern := range patterns {
+			if pattern == "" {
+				continue
+			}
+			rules = append(rules, rule{Type: typ, Pattern: pattern, CaseInsensitive: insensitive, List: list, Source: flag})
+		}
+	}
+	addFiles := func(typ, list string, insensitive bool, files []string) error {
+		for _, filename := range files {
+			lines, err := readPatternFile(filename)
+			if err != nil {
+				return err
+			}
+			for _, line := range lines {
+				rules = append(rules, rule{Type: typ, Pattern: line.Pattern, CaseInsensitive: insensitive,
+					List: list, Source: filename, Line: line.Line})
+			}
+		}
+		return nil
+	}
+
+	addPatterns("exclude", "exclude", "--exclude", false, opts.Excludes)
+	if err := addFiles("exclude", "exclude", false, opts.ExcludeFiles); err != nil {
+		return nil, err
+	}
+	addPatterns("exclude", "iexclude", "--iexclude", true, opts.InsensitiveExcludes)
+	if err := addFiles("exclude", "iexclude", true, opts.InsensitiveExcludeFiles); err != nil {
+		return nil, err
+	}
+
+	for _, rs := range ruleSets {
+		source := "--rules-from-repo " + rs.String()
+		addPatterns("exclude", "rule set "+rs.String(), source, false, rs.Excludes)
+		addPatterns("exclude", "rule set "+rs.String()+" iexclude", source, true, rs.InsensitiveExcludes)
+	}
+
+	presets, err := filter.LookupPresets(addRuleSetPresets(opts.ExcludePresets, ruleSets))
+	if err != nil {
+		return nil, errors.Fatalf("--exclude-preset: %v", err)
+	}
+	for _, preset := range presets {
+		addPatterns("exclude", "preset "+preset.Name, "--exclude-preset "+preset.Name, preset.CaseInsensitive, preset.Patterns)
+	}
+
+	addPatterns("include", "include", "--include", false, opts.Includes)
+	if err := addFiles("include", "include", false, opts.IncludeFiles); err != nil {
+		return nil, err
+	}
+	addPatterns("include", "iinclude", "--iinclude", true, opts.InsensitiveIncludes)
+	if err := addFiles("include", "iinclude", true, opts.InsensitiveIncludeFiles); err != nil {
+		return nil, err
+	}
+
+	if len(rules) == 0 {
+		return nil, errors.Fatal("no include or exclude rules specified")
+	}
+	return rules, nil
+}
+
+// loadRulesFromRepo loads the rule sets referenced by --rules-from-repo. The
+// repository is only opened if rule sets are referenced.
+func loadRulesFromRepo(ctx context.Context, opts RulesOptions, gopts GlobalOptions) ([]repoRuleSet, error) {
+	if len(opts.RulesFromRepo) == 0 {
+		return nil, nil
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return loadRepoRuleSets(ctx, repo, opts.RulesFromRepo)
+}
+
+// ruleList is a list of rules which are evaluated together.
+type ruleList struct {
+	typ         string
+	insensitive bool
+	rules       []rule
+	patterns    []filter.Pattern
+}
+
+// groupRules returns the lists of rules in the order of their first rule.
+func groupRules(rules []rule) []*ruleList {
+	var lists []*ruleList
+	byName := make(map[string]*ruleList)
+	for _, r := range rules {
+		l, ok := byName[r.List]
+		if !ok {
+			l = &ruleList{typ: r.Type, insensitive: r.CaseInsensitive}
+			byName[r.List] = l
+			lists = append(lists, l)
+		}
+		l.rules = append(l.rules, r)
+	}
+
+	for _, l := range lists {
+		var patterns []string
+		for _, r := range l.rules {
+			pattern := r.Pattern
+			if l.insensitive {
+				pattern = strings.ToLower(pattern)
+			}
+			patterns = append(patterns, pattern)
+		}
+		l.patterns = filter.ParsePatterns(patterns)
+	}
+	return lists
+}
+
+// explain returns whether path matches the list and the rule which decided
+// this. The rule is nil if no rule matched.
+func (l *ruleList) explain(path string) (bool, *rule, error) {
+	if l.insensitive {
+		path = strings.ToLower(path)
+	}
+	matched, index, err := filter.Explain(l.patterns, path)
+	if err != nil || index < 0 {
+		return matched, nil, err
+	}
+	return matched, &l.rules[index], nil
+}
+
+// ruleProblem is a problem found by lintRules.
+type ruleProblem struct {
+	Rule     rule   `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// lintRules checks the rules for invalid patterns and common mistakes.
+func lintRules(rules []rule) []ruleProblem {
+	var problems []ruleProblem
+	report := func(r rule, severity, msg string, args ...interface{}) {
+		problems = append(problems, ruleProblem{Rule: r, Severity: severity, Message: fmt.Sprintf(msg, args...)})
+	}
+
+	for _, l := range groupRules(rules) {
+		seen := make(map[string]rule)
+		positive := false
+		for _, r := range l.rules {
+			pattern := r.Pattern
+			if l.insensitive {
+				pattern = strings.ToLower(pattern)
+			}
+			negated := strings.HasPrefix(pattern, "!")
+
+			if pattern == "!" {
+				report(r, "error", "negation without a pattern")
+				continue
+			}
+			if err := filter.ValidatePatterns([]string{pattern}); err != nil {
+				report(r, "error", "invalid pattern")
+				continue
+			}
+
+			if prev, ok := seen[pattern]; ok {
+				report(r, "warning", "duplicate of the pattern at %s", prev.location())
+			} else {
+				seen[pattern] = r
+			}
+			if negated && !positive {
+				report(r, "warning", "negated pattern has no effect, as no previous %s pattern of the list matches anything", l.typ)
+			}
+			if len(pattern) > 1 && strings.HasSuffix(pattern, "/") {
+				report(r, "warning", "trailing slash is ignored, the pattern also matches files")
+			}
+			positive = positive || !negated
+		}
+	}
+	return problems
+}
+
+func runRulesLint(ctx context.Context, opts RulesOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the lint command expects no arguments, only options - please see `restic help rules lint` for usage and flags")
+	}
+
+	ruleSets, err := loadRulesFromRepo(ctx, opts, gopts)
+	if err != nil {
+		return err
+	}
+	rules, err := collectRules(opts, ruleSets)
+	if err != nil {
+		return err
+	}
+	problems := lintRules(rules)
+
+	invalid := 0
+	for _, p := range problems {
+		if p.Severity == "error" {
+			invalid++
+		}
+	}
+
+	if gopts.JSON {
+		if problems == nil {
+			problems = []ruleProblem{}
+		}
+		if err := json.NewEncoder(globalOptions.stdout).Encode(problems); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range problems {
+			Printf("%s: %s: %q: %s\n", p.Rule.location(), p.Severity, p.Rule.Pattern, p.Message)
+		}
+		if len(problems) == 0 {
+			Printf("checked %d rules, no problems found\n", len(rules))
+		}
+	}
+
+	if invalid > 0 {
+		return errors.Fatalf("found %d invalid rules", invalid)
+	}
+	return nil
+}
+
+// ruleMatch describes which rules match a path.
+type ruleMatch struct {
+	Path     string `json:"path"`
+	Excluded bool   `json:"excluded"`
+	// ExcludedParent is the parent directory which is excluded by ExcludeRule.
+	ExcludedParent string `json:"excluded_parent,omitempty"`
+	// ExcludeRule excluded the path, or included it again if it is negated.
+	ExcludeRule *rule `json:"exclude_rule,omitempty"`
+	Included    bool  `json:"included"`
+	IncludeRule *rule `json:"include_rule,omitempty"`
+}
+
+// matchRules evaluates the rules for path, which must be absolute.
+func matchRules(lists []*ruleList, path string) (ruleMatch, error) {
+	m := ruleMatch{Path: path, Included: true}
+
+	// the parent directories are checked first, a backup does not descend
+	// into excluded directories
+	var dirs []string
+	for dir := path; filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+
+	hasIncludes := false
+	for _, l := range lists {
+		if l.typ != "exclude" {
+			hasIncludes = true
+			continue
+		}
+		for _, dir := range dirs {
+			matched, r, err := l.explain(dir)
+			if err != nil {
+				return ruleMatch{}, err
+			}
+			if matched {
+				m.Excluded = true
+				m.Included = false
+				m.ExcludeRule = r
+				if dir != path {
+					m.ExcludedParent = dir
+				}
+				return m, nil
+			}
+			if r != nil && dir == path && m.ExcludeRule == nil {
+				m.ExcludeRule = r
+			}
+		}
+	}
+
+	if !hasIncludes {
+		return m, nil
+	}
+	m.Included = false
+	for _, l := range lists {
+		if l.typ != "include" {
+			continue
+		}
+		matched, r, err := l.explain(path)
+		if err != nil {
+			return ruleMatch{}, err
+		}
+		if matched {
+			m.Included = true
+			m.IncludeRule = r
+			break
+		}
+	}
+	return m, nil
+}
+
+func (m ruleMatch) String() string {
+	var s string
+	switch {
+	case m.Excluded && m.ExcludedParent != "":
+		s = fmt.Sprintf("excluded, as parent %v is excluded by %v", m.ExcludedParent, m.ExcludeRule)
+	case m.Excluded:
+		s = fmt.Sprintf("excluded by %v", m.ExcludeRule)
+	case m.ExcludeRule != nil:
+		s = fmt.Sprintf("not excluded, included again by %v", m.ExcludeRule)
+	default:
+		s = "not excluded"
+	}
+
+	if !m.Excluded {
+		switch {
+		case m.IncludeRule != nil:
+			s += fmt.Sprintf(", included by %v", m.IncludeRule)
+		case !m.Included:
+			s += ", not matched by any include pattern"
+		}
+	}
+	return s
+}
+
+func runRulesTest(ctx context.Context, opts RulesOptions, gopts GlobalOptions, args []string) error {
+	if len(args) == 0 {
+		return errors.Fatal("no path specified")
+	}
+
+	ruleSets, err := loadRulesFromRepo(ctx, opts, gopts)
+	if err != nil {
+		return err
+	}
+	rules, err := collectRules(opts, ruleSets)
+	if err != nil {
+		return err
+	}
+	for _, p := range lintRules(rules) {
+		if p.Severity == "error" {
+			return errors.Fatalf("%s: %q: %s", p.Rule.location(), p.Rule.Pattern, p.Message)
+		}
+	}
+	lists := groupRules(rules)
+
+	var matches []ruleMatch
+	for _, arg := range args {
+		path, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		m, err := matchRules(lists, path)
+		if err != nil {
+			return err
+		}
+		matches = append(matches, m)
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(matches)
+	}
+	for _, m := range matches {
+		Printf("%v: %v\n", m.Path, m)
+	}
+	return nil
+}
+
+func runRulesExport(ctx context.Context, opts RulesOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the export command expects no arguments, only options - please see `restic help rules export` for usage and flags")
+	}
+
+	ruleSets, err := loadRulesFromRepo(ctx, opts, gopts)
+	if err != nil {
+		return err
+	}
+	rules, err := collectRules(opts, ruleSets)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(globalOptions.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rules)
+}
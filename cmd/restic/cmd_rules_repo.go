@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/table"
+)
+
+var cmdRulesSave = &cobra.Command{
+	Use:   "save [flags] NAME",
+	Short: "Store exclude rules as a rule set in the repository",
+	Long: `
+The "rules save" command stores the given exclude patterns and presets as a new
+version of the rule set NAME in the repository. Each version is stored as a
+separate encrypted file. Patterns from exclude files are read when the rule set
+is saved, environment variables in them are expanded on the local host. Rule
+sets with invalid patterns are not saved. Rule sets cannot be saved in
+repositories which are restricted to formats readable by upstream restic.
+
+If the rules are identical to the current version of the rule set, no new
+version is created. Previous versions are kept and can be used by specifying
+NAME@VERSION.
+
+Backups use a rule set via "restic backup --rules-from-repo NAME". The patterns
+of a rule set are evaluated separately from other exclude patterns, thus a
+negated pattern only affects the patterns of the same rule set.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRulesSave(cmd.Context(), rulesOptions, globalOptions, args)
+	},
+}
+
+var cmdRulesList = &cobra.Command{
+	Use:   "list [NAME]",
+	Short: "List the rule sets stored in the repository",
+	Long: `
+The "rules list" command lists the current version of all rule sets stored in
+the repository. If NAME is given, all versions of the rule set are listed. Use
+"restic rules export --rules-from-repo NAME[@VERSION]" to show the patterns of a
+rule set.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRulesList(cmd.Context(), globalOptions, args)
+	},
+}
+
+var cmdRulesRemove = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "Remove a rule set from the repository",
+	Long: `
+The "rules remove" command removes all versions of the rule set NAME from the
+repository. Backups which still reference the rule set fail afterwards.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRulesRemove(cmd.Context(), globalOptions, args)
+	},
+}
+
+func init() {
+	cmdRules.AddCommand(cmdRulesSave)
+	cmdRules.AddCommand(cmdRulesList)
+	cmdRules.AddCommand(cmdRulesRemove)
+}
+
+// rulesNeedRepository returns whether the rules sub-command c accesses the
+// repository.
+func rulesNeedRepository(c *cobra.Command) bool {
+	switch c {
+	case cmdRulesSave, cmdRulesList, cmdRulesRemove:
+		return true
+	}
+	return len(rulesOptions.RulesFromRepo) > 0
+}
+
+// newRuleSet returns a rule set containing the exclude rules. Include rules
+// cannot be stored in a rule set.
+func newRuleSet(opts RulesOptions, rules []rule) (restic.RuleSet, error) {
+	var rs restic.RuleSet
+	for _, r := range rules {
+		switch {
+		case r.Type != "exclude":
+			return restic.RuleSet{}, errors.Fatalf("%s: include rules cannot be stored in a rule set", r.location())
+		case r.List == "exclude":
+			rs.Excludes = append(rs.Excludes, r.Pattern)
+		case r.List == "iexclude":
+			rs.InsensitiveExcludes = append(rs.InsensitiveExcludes, r.Pattern)
+		}
+	}
+	for _, name := range opts.ExcludePresets {
+		rs.ExcludePresets = append(rs.ExcludePresets, strings.TrimSpace(name))
+	}
+	return rs, nil
+}
+
+func runRulesSave(ctx context.Context, opts RulesOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("please specify the name of the rule set")
+	}
+	name := args[0]
+	if err := restic.ValidateRuleSetName(name); err != nil {
+		return errors.Fatalf("%v", err)
+	}
+	if len(opts.RulesFromRepo) > 0 {
+		return errors.Fatal("--rules-from-repo cannot be used when saving a rule set")
+	}
+
+	rules, err := collectRules(opts, nil)
+	if err != nil {
+		return err
+	}
+	invalid := 0
+	for _, p := range lintRules(rules) {
+		Warnf("%s: %s: %q: %s\n", p.Rule.location(), p.Severity, p.Rule.Pattern, p.Message)
+		if p.Severity == "error" {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return errors.Fatalf("found %d invalid rules, rule set was not saved", invalid)
+	}
+	rs, err := newRuleSet(opts, rules)
+	if err != nil {
+		return err
+	}
+
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := checkResticCompat(repo, "rules save"); err != nil {
+		return err
+	}
+
+	saved, changed, err := repository.SaveRuleSet(ctx, repo, name, rs)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		Printf("rule set %v is unchanged, current version is %d\n", name, saved.Version)
+		return nil
+	}
+	Printf("saved version %d of rule set %v\n", saved.Version, name)
+	return nil
+}
+
+func runRulesList(ctx context.Context, gopts GlobalOptions, args []string) error {
+	if len(args) > 1 {
+		return errors.Fatal("the list command expects at most one rule set name")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ruleSets, err := restic.LoadRuleSets(ctx, repo)
+	if err != nil {
+		return err
+	}
+	list := []restic.RuleSet{}
+	if len(args) == 1 {
+		rs, err := ruleSets.Lookup(args[0])
+		if err != nil {
+			return errors.Fatalf("%v", err)
+		}
+		list = append(list, ruleSets[rs.Name]...)
+	} else {
+		for _, name := range ruleSets.Names() {
+			versions := ruleSets[name]
+			list = append(list, versions[len(versions)-1])
+		}
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(list)
+	}
+
+	tab := table.New()
+	tab.AddColumn("Name", "{{ .Name }}")
+	tab.AddColumn("Version", "{{ .Version }}")
+	tab.AddColumn("Time", "{{ .Time }}")
+	tab.AddColumn("Patterns", "{{ .Patterns }}")
+	tab.AddColumn("Presets", "{{ .Presets }}")
+	for _, rs := range list {
+		tab.AddRow(struct {
+			Name, Time, Presets string
+			Version, Patterns   int
+		}{
+			Name:     rs.Name,
+			Version:  rs.Version,
+			Time:     rs.Time.Local().Format(TimeFormat),
+			Patterns: len(rs.Excludes) + len(rs.InsensitiveExcludes),
+			Presets:  strings.Join(rs.ExcludePresets, ","),
+		})
+	}
+	return tab.Write(globalOptions.stdout)
+}
+
+func runRulesRemove(ctx context.Context, gopts GlobalOptions, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("please specify the name of the rule set")
+	}
+
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := repository.RemoveRuleSet(ctx, repo, args[0]); err != nil {
+		return errors.Fatalf("%v", err)
+	}
+	Printf("removed rule set %v\n", args[0])
+	return nil
+}
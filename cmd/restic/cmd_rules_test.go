@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/filter"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestCollectRulesLocation(t *testing.T) {
+	tempDir := rtest.TempDir(t)
+	excludes := filepath.Join(tempDir, "excludes")
+	rtest.OK(t, os.WriteFile(excludes, []byte("# comment\n\n*.tmp\n!keep.tmp\n"), 0644))
+
+	rules, err := collectRules(RulesOptions{
+		excludePatternOptions: excludePatternOptions{
+			Excludes:     []string{"/cache"},
+			ExcludeFiles: []string{excludes},
+		},
+		includePatternOptions: includePatternOptions{
+			InsensitiveIncludes: []string{"/Data"},
+		},
+	}, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, []rule{
+		{Type: "exclude", Pattern: "/cache", List: "exclude", Source: "--exclude"},
+		{Type: "exclude", Pattern: "*.tmp", List: "exclude", Source: excludes, Line: 3},
+		{Type: "exclude", Pattern: "!keep.tmp", List: "exclude", Source: excludes, Line: 4},
+		{Type: "include", Pattern: "/Data", CaseInsensitive: true, List: "iinclude", Source: "--iinclude"},
+	}, rules)
+
+	_, err = collectRules(RulesOptions{}, nil)
+	rtest.Assert(t, err != nil, "missing error for empty rules")
+}
+
+func TestLintRules(t *testing.T) {
+	rules := []rule{
+		{Type: "exclude", Pattern: "!/keep", List: "exclude", Source: "--exclude"},
+		{Type: "exclude", Pattern: "*.tmp", List: "exclude", Source: "--exclude"},
+		{Type: "exclude", Pattern: "[a", List: "exclude", Source: "--exclude"},
+		{Type: "exclude", Pattern: "*.TMP", CaseInsensitive: true, List: "iexclude", Source: "--iexclude"},
+		{Type: "exclude", Pattern: "*.tmp", CaseInsensitive: true, List: "iexclude", Source: "--iexclude"},
+		{Type: "exclude", Pattern: "cache/", List: "exclude", Source: "--exclude"},
+		{Type: "exclude", Pattern: "!", List: "exclude", Source: "--exclude"},
+	}
+
+	var messages []string
+	for _, p := range lintRules(rules) {
+		messages = append(messages, p.Severity+" "+p.Rule.Pattern)
+	}
+	rtest.Equals(t, []string{
+		"warning !/keep",
+		"error [a",
+		"warning cache/",
+		"error !",
+		"warning *.tmp",
+	}, messages)
+}
+
+func TestMatchRules(t *testing.T) {
+	lists := groupRules([]rule{
+		{Type: "exclude", Pattern: "/home/*/.cache", List: "exclude", Source: "--exclude"},
+		{Type: "exclude", Pattern: "*.log", List: "exclude", Source: "--exclude"},
+		{Type: "exclude", Pattern: "!/srv/keep.log", List: "exclude", Source: "--exclude"},
+		{Type: "include", Pattern: "/SRV", CaseInsensitive: true, List: "iinclude", Source: "--iinclude"},
+	})
+
+	for _, test := range []struct {
+		path           string
+		excluded       bool
+		excludedParent string
+		excludePattern string
+		included       bool
+	}{
+		{"/home/user/.cache/chrome/data", true, "/home/user/.cache", "/home/*/.cache", false},
+		{"/srv/app.log", true, "", "*.log", false},
+		{"/srv/keep.log", false, "", "!/srv/keep.log", true},
+		{"/srv/data", false, "", "", true},
+		{"/etc/hosts", false, "", "", false},
+	} {
+		m, err := matchRules(lists, filepath.FromSlash(test.path))
+		rtest.OK(t, err)
+		rtest.Equals(t, test.excluded, m.Excluded)
+		rtest.Equals(t, filepath.FromSlash(test.excludedParent), m.ExcludedParent)
+		if test.excludePattern == "" {
+			rtest.Assert(t, m.ExcludeRule == nil, "unexpected exclude rule %v for %v", m.ExcludeRule, test.path)
+		} else {
+			rtest.Assert(t, m.ExcludeRule != nil, "missing exclude rule for %v", test.path)
+			rtest.Equals(t, test.excludePattern, m.ExcludeRule.Pattern)
+		}
+		rtest.Equals(t, test.included, m.Included)
+	}
+}
+
+func TestCollectRulesFromRepo(t *testing.T) {
+	ruleSets := []repoRuleSet{{RuleSet: restic.RuleSet{
+		Name:                "corp",
+		Version:             2,
+		Excludes:            []string{"*.tmp"},
+		InsensitiveExcludes: []string{"/Cache"},
+		ExcludePresets:      []string{"browser-caches"},
+	}}}
+	rules, err := collectRules(RulesOptions{
+		excludePatternOptions: excludePatternOptions{Excludes: []string{"/data"}},
+		ExcludePresets:        []string{"browser-caches"},
+	}, ruleSets)
+	rtest.OK(t, err)
+
+	var presets int
+	for _, r := range rules {
+		if r.List == "preset browser-caches" {
+			presets++
+		}
+	}
+	rtest.Assert(t, presets > 0, "preset rules missing")
+	rules = rules[:len(rules)-presets]
+	rtest.Equals(t, []rule{
+		{Type: "exclude", Pattern: "/data", List: "exclude", Source: "--exclude"},
+		{Type: "exclude", Pattern: "*.tmp", List: "rule set corp@2", Source: "--rules-from-repo corp@2"},
+		{Type: "exclude", Pattern: "/Cache", CaseInsensitive: true, List: "rule set corp@2 iexclude", Source: "--rules-from-repo corp@2"},
+	}, rules)
+	// the preset is only used once
+	preset, err := filter.LookupPresets([]string{"browser-caches"})
+	rtest.OK(t, err)
+	rtest.Equals(t, len(preset[0].Patterns), presets)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/schedule"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var cmdSchedule = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run restic regularly using the task scheduler of the system",
+	Long: `
+The "schedule" command generates definitions for the task scheduler of the
+operating system, which run restic with the given arguments regularly.
+	`,
+}
+
+// ScheduleOptions collects the options for the schedule export commands.
+type ScheduleOptions struct {
+	Name          string
+	Description   string
+	Binary        string
+	At            string
+	Days          []string
+	User          string
+	Retries       int
+	RetryInterval time.Duration
+}
+
+func init() {
+	cmdRoot.AddCommand(cmdSchedule)
+}
+
+func initScheduleFlags(f *pflag.FlagSet, opts *ScheduleOptions) {
+	f.StringVar(&opts.Name, "name", "restic-backup", "`name` of the task")
+	f.StringVar(&opts.Description, "description", "", "`description` of the task")
+	f.StringVar(&opts.Binary, "restic-binary", "", "`path` of the restic binary to run (default: the running binary)")
+	f.StringVar(&opts.At, "at", "02:00", "run the task at this `time` of day (HH:MM)")
+	f.StringSliceVar(&opts.Days, "days", nil, "run the task only on these `days` (mon,tue,wed,thu,fri,sat,sun, default: every day)")
+	f.StringVar(&opts.User, "user", "", "run the task as `account`")
+	f.IntVar(&opts.Retries, "retries", 3, "restart a failed task up to `n` times")
+	f.DurationVar(&opts.RetryInterval, "retry-interval", 15*time.Minute, "wait `duration` before restarting a failed task")
+}
+
+// task returns the task which runs restic with args.
+func (opts ScheduleOptions) task(args []string) (schedule.Task, error) {
+	if len(args) == 0 {
+		return schedule.Task{}, errors.Fatal("no arguments for restic specified, pass them after --, for example -- -r /srv/repo backup /home")
+	}
+
+	binary := opts.Binary
+	if binary == "" {
+		var err error
+		binary, err = os.Executable()
+		if err != nil {
+			return schedule.Task{}, errors.Wrap(err, "unable to find executable")
+		}
+	}
+	if !filepath.IsAbs(binary) {
+		return schedule.Task{}, errors.Fatalf("the path of the restic binary %q is not absolute", binary)
+	}
+
+	hour, minute, err := schedule.ParseTime(opts.At)
+	if err != nil {
+		return schedule.Task{}, errors.Fatalf("invalid --at: %v", err)
+	}
+	days, err := schedule.ParseWeekdays(opts.Days)
+	if err != nil {
+		return schedule.Task{}, errors.Fatalf("invalid --days: %v", err)
+	}
+
+	t := schedule.Task{
+		Name:          opts.Name,
+		Description:   opts.Description,
+		Command:       binary,
+		Args:          args,
+		Hour:          hour,
+		Minute:        minute,
+		Weekdays:      days,
+		User:          opts.User,
+		Retries:       opts.Retries,
+		RetryInterval: opts.RetryInterval,
+	}
+	if err := t.Validate(); err != nil {
+		return schedule.Task{}, errors.Fatal(err.Error())
+	}
+	return t, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/schedule"
+	"github.com/spf13/cobra"
+)
+
+var cmdScheduleExportTaskXML = &cobra.Command{
+	Use:   "export-taskxml [flags] -- [restic arguments]",
+	Short: "Generate a Windows Task Scheduler definition",
+	Long: `
+The "schedule export-taskxml" command generates a task definition for the
+Windows Task Scheduler, which runs restic with the arguments given after "--".
+The definition can be imported using the Task Scheduler or using
+"schtasks /create /tn restic-backup /xml restic-backup.xml".
+
+By default, the task runs as SYSTEM. For other accounts than the built-in
+service accounts, the password is requested when the task is imported. As the
+task cannot ask for the repository password, pass it using --password-file or
+--password-command.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	Example:           `restic schedule export-taskxml --at 01:30 --output backup.xml -- -r D:\repo --password-file C:\restic\password.txt backup C:\Users`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runScheduleExportTaskXML(scheduleExportTaskXMLOptions, globalOptions, args)
+	},
+}
+
+var cmdScheduleExportSystemd = &cobra.Command{
+	Use:   "export-systemd [flags] -- [restic arguments]",
+	Short: "Generate a systemd service and timer",
+	Long: `
+The "schedule export-systemd" command generates a systemd service and timer
+unit, which run restic with the arguments given after "--". The units are named
+after the task, for example restic-backup.service and restic-backup.timer. Copy
+them to /etc/systemd/system and enable the timer using
+"systemctl enable --now restic-backup.timer".
+
+By default, the service runs as root. As the service cannot ask for the
+repository password, pass it using --password-file or --password-command.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	Example:           `restic schedule export-systemd --at 01:30 --output-dir /etc/systemd/system -- -r /srv/repo --password-file /etc/restic/password backup /home`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runScheduleExportSystemd(scheduleExportSystemdOptions, globalOptions, args)
+	},
+}
+
+// ScheduleExportTaskXMLOptions collects all options for the schedule
+// export-taskxml command.
+type ScheduleExportTaskXMLOptions struct {
+	ScheduleOptions
+	Output string
+}
+
+// ScheduleExportSystemdOptions collects all options for the schedule
+// export-systemd command.
+type ScheduleExportSystemdOptions struct {
+	ScheduleOptions
+	OutputDir string
+}
+
+var scheduleExportTaskXMLOptions ScheduleExportTaskXMLOptions
+var scheduleExportSystemdOptions ScheduleExportSystemdOptions
+
+func init() {
+	cmdSchedule.AddCommand(cmdScheduleExportTaskXML)
+	cmdSchedule.AddCommand(cmdScheduleExportSystemd)
+
+	f := cmdScheduleExportTaskXML.Flags()
+	initScheduleFlags(f, &scheduleExportTaskXMLOptions.ScheduleOptions)
+	f.StringVar(&scheduleExportTaskXMLOptions.Output, "output", "", "write the task definition to `file` (default: stdout)")
+
+	f = cmdScheduleExportSystemd.Flags()
+	initScheduleFlags(f, &scheduleExportSystemdOptions.ScheduleOptions)
+	f.StringVar(&scheduleExportSystemdOptions.OutputDir, "output-dir", "", "write the units to `directory` (default: print them to stdout)")
+}
+
+func runScheduleExportTaskXML(opts ScheduleExportTaskXMLOptions, gopts GlobalOptions, args []string) error {
+	t, err := opts.task(args)
+	if err != nil {
+		return err
+	}
+
+	doc, err := schedule.TaskXML(t, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "" {
+		_, err = gopts.stdout.Write(doc)
+		return err
+	}
+	if err := os.WriteFile(opts.Output, doc, 0644); err != nil {
+		return errors.Fatalf("unable to write task definition: %v", err)
+	}
+	Verbosef("wrote task definition to %v\n", opts.Output)
+	return nil
+}
+
+func runScheduleExportSystemd(opts ScheduleExportSystemdOptions, gopts GlobalOptions, args []string) error {
+	t, err := opts.task(args)
+	if err != nil {
+		return err
+	}
+
+	service, timer, err := schedule.SystemdUnits(t)
+	if err != nil {
+		return errors.Fatal(err.Error())
+	}
+
+	units := []struct {
+		name, content string
+	}{
+		{t.Name + ".service", service},
+		{t.Name + ".timer", timer},
+	}
+
+	for i, unit := range units {
+		if opts.OutputDir == "" {
+			if i > 0 {
+				fmt.Fprintln(gopts.stdout)
+			}
+			fmt.Fprintf(gopts.stdout, "# %v\n%v", unit.name, unit.content)
+			continue
+		}
+
+		filename := filepath.Join(opts.OutputDir, unit.name)
+		if err := os.WriteFile(filename, []byte(unit.content), 0644); err != nil {
+			return errors.Fatalf("unable to write unit: %v", err)
+		}
+		Verbosef("wrote %v\n", filename)
+	}
+	return nil
+}
@@ -34,10 +34,11 @@ Exit status is 0 if the command was successful, and non-zero if there was any er
 // SnapshotOptions bundles all options for the snapshots command.
 type SnapshotOptions struct {
 	restic.SnapshotFilter
-	Compact bool
-	Last    bool // This option should be removed in favour of Latest.
-	Latest  int
-	GroupBy restic.SnapshotGroupByOptions
+	Compact   bool
+	Last      bool // This option should be removed in favour of Latest.
+	Latest    int
+	GroupBy   restic.SnapshotGroupByOptions
+	WithNotes bool
 }
 
 var snapshotOptions SnapshotOptions
@@ -56,6 +57,7 @@ func init() {
 	}
 	f.IntVar(&snapshotOptions.Latest, "latest", 0, "only show the last `n` snapshots for each host and path")
 	f.VarP(&snapshotOptions.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma")
+	f.BoolVar(&snapshotOptions.WithNotes, "with-notes", false, "show the notes of the snapshots")
 }
 
 func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts GlobalOptions, args []string) error {
@@ -105,7 +107,7 @@ func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts GlobalOptions
 				return nil
 			}
 		}
-		PrintSnapshots(globalOptions.stdout, list, nil, opts.Compact)
+		PrintSnapshots(globalOptions.stdout, list, nil, opts.Compact, opts.WithNotes)
 	}
 
 	return nil
@@ -148,8 +150,10 @@ func FilterLastestSnapshots(list restic.Snapshots, limit int) restic.Snapshots {
 	return results
 }
 
-// PrintSnapshots prints a text table of the snapshots in list to stdout.
-func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.KeepReason, compact bool) {
+// PrintSnapshots prints a text table of the snapshots in list to stdout. If
+// withNotes is set, the notes of the snapshots are shown in an additional
+// column.
+func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.KeepReason, compact bool, withNotes bool) {
 	// keep the reasons a snasphot is being kept in a map, so that it doesn't
 	// get lost when the list of snapshots is sorted
 	keepReasons := make(map[restic.ID]restic.KeepReason, len(reasons))
@@ -203,6 +207,9 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 			tab.AddColumn("Size", `{{ .Size }}`)
 		}
 	}
+	if withNotes {
+		tab.AddColumn("Notes", `{{ join .Notes "\n" }}`)
+	}
 
 	type snapshot struct {
 		ID        string
@@ -212,6 +219,7 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 		Reasons   []string
 		Paths     []string
 		Size      string
+		Notes     []string
 	}
 
 	var multiline bool
@@ -237,6 +245,15 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 			data.Size = ui.FormatBytes(sn.Summary.TotalBytesProcessed)
 		}
 
+		if withNotes {
+			for _, note := range sn.Notes {
+				data.Notes = append(data.Notes, formatSnapshotNote(note))
+			}
+			if len(sn.Notes) > 1 {
+				multiline = true
+			}
+		}
+
 		tab.AddRow(data)
 	}
 
@@ -264,6 +281,15 @@ func PrintSnapshots(stdout io.Writer, list restic.Snapshots, reasons []restic.Ke
 	}
 }
 
+// formatSnapshotNote returns a single line describing note.
+func formatSnapshotNote(note restic.SnapshotNote) string {
+	s := note.Time.Local().Format(TimeFormat)
+	if note.Author != "" {
+		s += " " + note.Author
+	}
+	return s + ": " + note.Text
+}
+
 // PrintSnapshotGroupHeader prints which group of the group-by option the
 // following snapshots belong to.
 // Prints nothing, if we did not group at all.
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/spf13/cobra"
+)
+
+var cmdSnapshotsExport = &cobra.Command{
+	Use:   "export [flags] [snapshotID ...]",
+	Short: "Export the snapshot inventory in a machine-readable format",
+	Long: `
+The "export" sub-command prints one record for each snapshot, which contains
+the IDs, host, user, paths, tags, the duration of the backup, the sizes and
+the number of files which could not be read. It is intended to feed inventory
+systems like a CMDB with the backup coverage of all hosts.
+
+The supported formats are "csv", "json" and "ndjson" (one JSON object per
+line). In CSV records, multiple paths and tags are separated by semicolons.
+The snapshots are written as soon as they are loaded, so the export of large
+repositories starts immediately and does not keep all snapshots in memory.
+As a consequence, the snapshots are not sorted.
+
+Fields which are only recorded by newer versions of restic, like the duration
+and the sizes, are empty for older snapshots.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSnapshotsExport(cmd.Context(), snapshotsExportOptions, globalOptions, args)
+	},
+}
+
+// SnapshotsExportOptions bundles all options for the snapshots export command.
+type SnapshotsExportOptions struct {
+	restic.SnapshotFilter
+	Format string
+}
+
+var snapshotsExportOptions SnapshotsExportOptions
+
+func init() {
+	cmdSnapshots.AddCommand(cmdSnapshotsExport)
+
+	f := cmdSnapshotsExport.Flags()
+	initMultiSnapshotFilter(f, &snapshotsExportOptions.SnapshotFilter, true)
+	f.StringVar(&snapshotsExportOptions.Format, "format", "json", "output `format`, one of (csv|json|ndjson)")
+}
+
+func runSnapshotsExport(ctx context.Context, opts SnapshotsExportOptions, gopts GlobalOptions, args []string) error {
+	// check the format before opening the repository
+	exporter, err := newSnapshotExporter(gopts.stdout, opts.Format)
+	if err != nil {
+		return err
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, args) {
+		if err := exporter.Write(newSnapshotRecord(sn)); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return exporter.Close()
+}
+
+// snapshotRecord is the exported inventory record of a snapshot. The fields
+// of the summary are nil for snapshots created by older versions of restic.
+type snapshotRecord struct {
+	ID             string     `json:"id"`
+	ShortID        string     `json:"short_id"`
+	Time           time.Time  `json:"time"`
+	Hostname       string     `json:"hostname"`
+	Username       string     `json:"username"`
+	Paths          []string   `json:"paths"`
+	Tags           []string   `json:"tags"`
+	Parent         string     `json:"parent"`
+	Original       string     `json:"original"`
+	Tree           string     `json:"tree"`
+	ProgramVersion string     `json:"program_version"`
+	Expires        *time.Time `json:"expires"`
+
+	BackupStart         *time.Time `json:"backup_start"`
+	BackupEnd           *time.Time `json:"backup_end"`
+	DurationSeconds     *float64   `json:"duration_seconds"`
+	FilesNew            *uint      `json:"files_new"`
+	FilesChanged        *uint      `json:"files_changed"`
+	FilesUnmodified     *uint      `json:"files_unmodified"`
+	DirsNew             *uint      `json:"dirs_new"`
+	DirsChanged         *uint      `json:"dirs_changed"`
+	DirsUnmodified      *uint      `json:"dirs_unmodified"`
+	TotalFilesProcessed *uint      `json:"total_files_processed"`
+	TotalBytesProcessed *uint64    `json:"total_bytes_processed"`
+	DataAdded           *uint64    `json:"data_added"`
+	DataAddedPacked     *uint64    `json:"data_added_packed"`
+	Errors              *uint      `json:"errors"`
+}
+
+func newSnapshotRecord(sn *restic.Snapshot) snapshotRecord {
+	r := snapshotRecord{
+		Time:           sn.Time,
+		Hostname:       sn.Hostname,
+		Username:       sn.Username,
+		Paths:          sn.Paths,
+		Tags:           sn.Tags,
+		ProgramVersion: sn.ProgramVersion,
+		Expires:        sn.Expires,
+	}
+	if r.Paths == nil {
+		r.Paths = []string{}
+	}
+	if r.Tags == nil {
+		r.Tags = []string{}
+	}
+	if id := sn.ID(); id != nil {
+		r.ID = id.String()
+		r.ShortID = id.Str()
+	}
+	if sn.Parent != nil {
+		r.Parent = sn.Parent.String()
+	}
+	if sn.Original != nil {
+		r.Original = sn.Original.String()
+	}
+	if sn.Tree != nil {
+		r.Tree = sn.Tree.String()
+	}
+
+	if s := sn.Summary; s != nil {
+		duration := s.BackupEnd.Sub(s.BackupStart).Seconds()
+		r.BackupStart = &s.BackupStart
+		r.BackupEnd = &s.BackupEnd
+		r.DurationSeconds = &duration
+		r.FilesNew = &s.FilesNew
+		r.FilesChanged = &s.FilesChanged
+		r.FilesUnmodified = &s.FilesUnmodified
+		r.DirsNew = &s.DirsNew
+		r.DirsChanged = &s.DirsChanged
+		r.DirsUnmodified = &s.DirsUnmodified
+		r.TotalFilesProcessed = &s.TotalFilesProcessed
+		r.TotalBytesProcessed = &s.TotalBytesProcessed
+		r.DataAdded = &s.DataAdded
+		r.DataAddedPacked = &s.DataAddedPacked
+		r.Errors = &s.Errors
+	}
+	return r
+}
+
+// snapshotExporter writes snapshot records to an output stream.
+type snapshotExporter interface {
+	Write(r snapshotRecord) error
+	// Close finishes the output, but does not close the underlying writer.
+	Close() error
+}
+
+func newSnapshotExporter(w io.Writer, format string) (snapshotExporter, error) {
+	switch format {
+	case "csv":
+		return newCSVSnapshotExporter(w)
+	case "json":
+		return &jsonSnapshotExporter{w: w}, nil
+	case "ndjson":
+		return &ndjsonSnapshotExporter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, errors.Fatalf("unknown format %q, must be one of (csv|json|ndjson)", format)
+	}
+}
+
+// csvColumns are the columns of the CSV export, in the same order as the
+// fields of snapshotRecord.
+var csvColumns = []string{
+	"id", "short_id", "time", "hostname", "username", "paths", "tags",
+	"parent", "original", "tree", "program_version", "expires",
+	"backup_start", "backup_end", "duration_seconds",
+	"files_new", "files_changed", "files_unmodified",
+	"dirs_new", "dirs_changed", "dirs_unmodified",
+	"total_files_processed", "total_bytes_processed",
+	"data_added", "data_added_packed", "errors",
+}
+
+type csvSnapshotExporter struct {
+	w *csv.Writer
+}
+
+func newCSVSnapshotExporter(w io.Writer) (*csvSnapshotExporter, error) {
+	e := &csvSnapshotExporter{w: csv.NewWriter(w)}
+	if err := e.w.Write(csvColumns); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func formatCSVUint[T uint | uint64](v *T) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*v), 10)
+}
+
+func (e *csvSnapshotExporter) Write(r snapshotRecord) error {
+	duration := ""
+	if r.DurationSeconds != nil {
+		duration = strconv.FormatFloat(*r.DurationSeconds, 'f', 3, 64)
+	}
+
+	err := e.w.Write([]string{
+		r.ID, r.ShortID, formatCSVTime(&r.Time), r.Hostname, r.Username,
+		strings.Join(r.Paths, ";"), strings.Join(r.Tags, ";"),
+		r.Parent, r.Original, r.Tree, r.ProgramVersion, formatCSVTime(r.Expires),
+		formatCSVTime(r.BackupStart), formatCSVTime(r.BackupEnd), duration,
+		formatCSVUint(r.FilesNew), formatCSVUint(r.FilesChanged), formatCSVUint(r.FilesUnmodified),
+		formatCSVUint(r.DirsNew), formatCSVUint(r.DirsChanged), formatCSVUint(r.DirsUnmodified),
+		formatCSVUint(r.TotalFilesProcessed), formatCSVUint(r.TotalBytesProcessed),
+		formatCSVUint(r.DataAdded), formatCSVUint(r.DataAddedPacked), formatCSVUint(r.Errors),
+	})
+	if err != nil {
+		return err
+	}
+	// write each record immediately, such that consumers can process them
+	// while the remaining snapshots are loaded
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvSnapshotExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonSnapshotExporter writes a JSON array, one element at a time.
+type jsonSnapshotExporter struct {
+	w       io.Writer
+	started bool
+}
+
+func (e *jsonSnapshotExporter) Write(r snapshotRecord) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	prefix := ",\n"
+	if !e.started {
+		prefix = "[\n"
+		e.started = true
+	}
+	_, err = fmt.Fprintf(e.w, "%s%s", prefix, buf)
+	return err
+}
+
+func (e *jsonSnapshotExporter) Close() error {
+	end := "\n]\n"
+	if !e.started {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+type ndjsonSnapshotExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonSnapshotExporter) Write(r snapshotRecord) error {
+	return e.enc.Encode(r)
+}
+
+func (e *ndjsonSnapshotExporter) Close() error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testExportSnapshots(t *testing.T) []*restic.Snapshot {
+	start := time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+	old := &restic.Snapshot{Time: start.Add(-24 * time.Hour), Hostname: "old", Paths: []string{"/srv"}}
+	restic.TestSetSnapshotID(t, old, restic.NewRandomID())
+
+	sn := &restic.Snapshot{
+		Time:     start,
+		Hostname: "host",
+		Paths:    []string{"/home", "/etc"},
+		Tags:     []string{"daily"},
+		Summary: &restic.SnapshotSummary{
+			BackupStart:         start,
+			BackupEnd:           start.Add(90 * time.Second),
+			TotalFilesProcessed: 12,
+			TotalBytesProcessed: 4096,
+			Errors:              2,
+		},
+	}
+	restic.TestSetSnapshotID(t, sn, restic.NewRandomID())
+	return []*restic.Snapshot{old, sn}
+}
+
+func exportSnapshots(t *testing.T, format string, snapshots []*restic.Snapshot) string {
+	var buf strings.Builder
+	e, err := newSnapshotExporter(&buf, format)
+	rtest.OK(t, err)
+	for _, sn := range snapshots {
+		rtest.OK(t, e.Write(newSnapshotRecord(sn)))
+	}
+	rtest.OK(t, e.Close())
+	return buf.String()
+}
+
+func TestSnapshotsExportCSV(t *testing.T) {
+	snapshots := testExportSnapshots(t)
+	records, err := csv.NewReader(strings.NewReader(exportSnapshots(t, "csv", snapshots))).ReadAll()
+	rtest.OK(t, err)
+	rtest.Equals(t, 3, len(records))
+	rtest.Equals(t, csvColumns, records[0])
+
+	column := func(record []string, name string) string {
+		for i, c := range csvColumns {
+			if c == name {
+				return record[i]
+			}
+		}
+		t.Fatalf("unknown column %v", name)
+		return ""
+	}
+	rtest.Equals(t, snapshots[0].ID().String(), column(records[1], "id"))
+	rtest.Equals(t, "", column(records[1], "duration_seconds"))
+	rtest.Equals(t, "/home;/etc", column(records[2], "paths"))
+	rtest.Equals(t, "90.000", column(records[2], "duration_seconds"))
+	rtest.Equals(t, "4096", column(records[2], "total_bytes_processed"))
+	rtest.Equals(t, "2", column(records[2], "errors"))
+}
+
+func TestSnapshotsExportJSON(t *testing.T) {
+	snapshots := testExportSnapshots(t)
+
+	var records []snapshotRecord
+	rtest.OK(t, json.Unmarshal([]byte(exportSnapshots(t, "json", snapshots)), &records))
+	rtest.Equals(t, 2, len(records))
+	rtest.Equals(t, newSnapshotRecord(snapshots[1]).ID, records[1].ID)
+	rtest.Assert(t, records[0].Errors == nil, "old snapshot has an error count")
+	rtest.Equals(t, uint(2), *records[1].Errors)
+	rtest.Equals(t, 90.0, *records[1].DurationSeconds)
+
+	rtest.Equals(t, "[]\n", exportSnapshots(t, "json", nil))
+}
+
+func TestSnapshotsExportNDJSON(t *testing.T) {
+	snapshots := testExportSnapshots(t)
+
+	sc := bufio.NewScanner(strings.NewReader(exportSnapshots(t, "ndjson", snapshots)))
+	var ids []string
+	for sc.Scan() {
+		var r snapshotRecord
+		rtest.OK(t, json.Unmarshal(sc.Bytes(), &r))
+		ids = append(ids, r.ID)
+	}
+	rtest.OK(t, sc.Err())
+	rtest.Equals(t, []string{snapshots[0].ID().String(), snapshots[1].ID().String()}, ids)
+
+	_, err := newSnapshotExporter(&strings.Builder{}, "xml")
+	rtest.Assert(t, err != nil, "unknown format was accepted")
+}
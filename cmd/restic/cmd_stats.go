@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/restic/chunker"
-	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/restorer"
@@ -90,8 +89,22 @@ func runStats(ctx context.Context, opts StatsOptions, gopts GlobalOptions, args
 	if err != nil {
 		return err
 	}
+
+	// the raw-data statistics of all snapshots can be served from the cache
+	useStatsCache := opts.countMode == countModeRawData && len(args) == 0 && opts.SnapshotFilter.Empty() && repo.Cache != nil
+	var indexLister restic.Lister = repo
+	if useStatsCache {
+		indexLister, err = restic.MemorizeList(ctx, repo, restic.IndexFile)
+		if err != nil {
+			return err
+		}
+		if stats := statsFromCache(ctx, repo, snapshotLister, indexLister); stats != nil {
+			return printStats(opts, gopts, stats)
+		}
+	}
+
 	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
-	if err = repo.LoadIndex(ctx, bar); err != nil {
+	if err = repo.LoadIndexFromList(ctx, indexLister, bar); err != nil {
 		return err
 	}
 
@@ -123,32 +136,57 @@ func runStats(ctx context.Context, opts StatsOptions, gopts GlobalOptions, args
 
 	if opts.countMode == countModeRawData {
 		// the blob handles have been collected, but not yet counted
+		var raw rawDataStats
 		for blobHandle := range stats.blobs {
 			pbs := repo.LookupBlob(blobHandle.Type, blobHandle.ID)
 			if len(pbs) == 0 {
 				return fmt.Errorf("blob %v not found", blobHandle)
 			}
-			stats.TotalSize += uint64(pbs[0].Length)
-			if repo.Config().Version >= 2 {
-				stats.TotalUncompressedSize += uint64(crypto.CiphertextLength(int(pbs[0].DataLength())))
-				if pbs[0].IsCompressed() {
-					stats.TotalCompressedBlobsSize += uint64(pbs[0].Length)
-					stats.TotalCompressedBlobsUncompressedSize += uint64(crypto.CiphertextLength(int(pbs[0].DataLength())))
-				}
-			}
-			stats.TotalBlobCount++
-		}
-		if stats.TotalCompressedBlobsSize > 0 {
-			stats.CompressionRatio = float64(stats.TotalCompressedBlobsUncompressedSize) / float64(stats.TotalCompressedBlobsSize)
+			raw.add(pbs[0], repo.Config().Version)
 		}
-		if stats.TotalUncompressedSize > 0 {
-			stats.CompressionProgress = float64(stats.TotalCompressedBlobsUncompressedSize) / float64(stats.TotalUncompressedSize) * 100
-			stats.CompressionSpaceSaving = (1 - float64(stats.TotalSize)/float64(stats.TotalUncompressedSize)) * 100
+		stats.setRawData(raw)
+
+		if useStatsCache {
+			// also includes snapshots in the trash, whose blobs are not counted
+			snapshots, err := listIDs(ctx, snapshotLister, restic.SnapshotFile)
+			if err != nil {
+				return err
+			}
+			c, err := buildStatsCache(ctx, repo, snapshots, stats.SnapshotsCount, stats.blobs.Has)
+			if err != nil {
+				return err
+			}
+			saveStatsCache(repo, c)
 		}
 	}
 
+	return printStats(opts, gopts, stats)
+}
+
+// statsFromCache returns the raw-data statistics of all snapshots from the
+// stats cache, or nil if the cache does not match the repository.
+func statsFromCache(ctx context.Context, repo *repository.Repository, snapshotLister, indexLister restic.Lister) *statsContainer {
+	snapshots, err := listIDs(ctx, snapshotLister, restic.SnapshotFile)
+	if err != nil {
+		return nil
+	}
+	indexes, err := listIDs(ctx, indexLister, restic.IndexFile)
+	if err != nil {
+		return nil
+	}
+	c := loadMatchingStatsCache(repo, snapshots, indexes)
+	if c == nil {
+		return nil
+	}
+
+	stats := &statsContainer{SnapshotsCount: c.SnapshotsCount}
+	stats.setRawData(c.RawData)
+	return stats
+}
+
+func printStats(opts StatsOptions, gopts GlobalOptions, stats *statsContainer) error {
 	if gopts.JSON {
-		err = json.NewEncoder(globalOptions.stdout).Encode(stats)
+		err := json.NewEncoder(globalOptions.stdout).Encode(stats)
 		if err != nil {
 			return fmt.Errorf("encoding output: %v", err)
 		}
@@ -330,6 +368,23 @@ type statsContainer struct {
 	blobs restic.BlobSet
 }
 
+// setRawData sets the blob statistics and derives the compression statistics.
+func (s *statsContainer) setRawData(raw rawDataStats) {
+	s.TotalSize = raw.TotalSize
+	s.TotalUncompressedSize = raw.TotalUncompressedSize
+	s.TotalCompressedBlobsSize = raw.TotalCompressedBlobsSize
+	s.TotalCompressedBlobsUncompressedSize = raw.TotalCompressedBlobsUncompressedSize
+	s.TotalBlobCount = raw.TotalBlobCount
+
+	if s.TotalCompressedBlobsSize > 0 {
+		s.CompressionRatio = float64(s.TotalCompressedBlobsUncompressedSize) / float64(s.TotalCompressedBlobsSize)
+	}
+	if s.TotalUncompressedSize > 0 {
+		s.CompressionProgress = float64(s.TotalCompressedBlobsUncompressedSize) / float64(s.TotalUncompressedSize) * 100
+		s.CompressionSpaceSaving = (1 - float64(s.TotalSize)/float64(s.TotalUncompressedSize)) * 100
+	}
+}
+
 // fileID is a 256-bit hash that distinguishes unique files.
 type fileID [32]byte
 
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunStatsRawData(t testing.TB, gopts GlobalOptions) statsContainer {
+	gopts.JSON = true
+	buf, err := withCaptureStdout(func() error {
+		return runStats(context.TODO(), StatsOptions{countMode: countModeRawData}, gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var stats statsContainer
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &stats))
+	return stats
+}
+
+func hasStatsCache(t testing.TB, env *testEnvironment) bool {
+	files, err := filepath.Glob(filepath.Join(env.cache, "*", statsCacheFile))
+	rtest.OK(t, err)
+	return len(files) > 0
+}
+
+// statsCacheValid reports whether the stats cache matches the repository.
+func statsCacheValid(t testing.TB, gopts GlobalOptions) bool {
+	ctx, repo, unlock, err := openWithReadLock(context.TODO(), gopts, false)
+	rtest.OK(t, err)
+	defer unlock()
+
+	snapshots, err := listIDs(ctx, repo, restic.SnapshotFile)
+	rtest.OK(t, err)
+	indexes, err := listIDs(ctx, repo, restic.IndexFile)
+	rtest.OK(t, err)
+	return loadMatchingStatsCache(repo, snapshots, indexes) != nil
+}
+
+func TestStatsCache(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	noCache := env.gopts
+	noCache.NoCache = true
+
+	checkStats := func() {
+		t.Helper()
+		expected := testRunStatsRawData(t, noCache)
+		rtest.Equals(t, expected, testRunStatsRawData(t, env.gopts))
+		rtest.Assert(t, hasStatsCache(t, env), "stats cache is missing")
+		// the second run uses the cache
+		rtest.Equals(t, expected, testRunStatsRawData(t, env.gopts))
+	}
+
+	// the cache must have been updated instead of being rebuilt by stats
+	checkUpdatedStats := func() {
+		t.Helper()
+		rtest.Assert(t, statsCacheValid(t, env.gopts), "stats cache does not match the repository")
+		checkStats()
+	}
+
+	first := filepath.Join(env.testdata, "0", "0", "9", "2")
+	second := filepath.Join(env.testdata, "0", "0", "9", "3")
+
+	testRunBackup(t, "", []string{first}, BackupOptions{}, env.gopts)
+	firstID := testListSnapshots(t, env.gopts, 1)[0]
+	rtest.Assert(t, !hasStatsCache(t, env), "stats cache exists before running stats")
+	checkStats()
+
+	// backup updates the cache
+	testRunBackup(t, "", []string{second}, BackupOptions{}, env.gopts)
+	checkUpdatedStats()
+
+	// forget moves the blobs of the removed snapshot to the unreferenced
+	// blobs, they are referenced again by the next backup
+	testRunForget(t, env.gopts, ForgetOptions{}, firstID.String())
+	checkUpdatedStats()
+	secondID := testListSnapshots(t, env.gopts, 1)[0]
+	testRunBackup(t, "", []string{first}, BackupOptions{}, env.gopts)
+	checkUpdatedStats()
+
+	// snapshots in the trash are not counted
+	var thirdID restic.ID
+	for _, id := range testListSnapshots(t, env.gopts, 2) {
+		if id != secondID {
+			thirdID = id
+		}
+	}
+	testRunForget(t, env.gopts, ForgetOptions{TrashPeriod: restic.Duration{Days: 7}}, secondID.String())
+	checkUpdatedStats()
+	rtest.Equals(t, 1, testRunStatsRawData(t, env.gopts).SnapshotsCount)
+
+	// prune removes the blobs which are only referenced by the forgotten
+	// snapshot and repacks the remaining ones
+	testRunBackup(t, "", []string{second}, BackupOptions{}, env.gopts)
+	testRunForget(t, env.gopts, ForgetOptions{}, thirdID.String())
+	checkUpdatedStats()
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0"})
+	checkUpdatedStats()
+	rtest.Equals(t, 1, testRunStatsRawData(t, env.gopts).SnapshotsCount)
+}
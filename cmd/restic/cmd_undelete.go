@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/table"
+)
+
+var cmdUndelete = &cobra.Command{
+	Use:   "undelete [flags] [snapshot ID] [...]",
+	Short: "Restore snapshots from the trash",
+	Long: `
+The "undelete" command restores snapshots which were moved to the trash by
+"forget --trash-period". Without arguments, it lists the snapshots in the trash.
+
+Snapshots are specified using the ID they had before they were moved to the
+trash. Restored snapshots get a new ID, all other metadata is preserved. Use
+--all to restore all snapshots in the trash.
+
+Snapshots can be restored until "prune" is run after their trash period has
+expired.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUndelete(cmd.Context(), undeleteOptions, globalOptions, args)
+	},
+}
+
+// UndeleteOptions collects all options for the undelete command.
+type UndeleteOptions struct {
+	All bool
+}
+
+var undeleteOptions UndeleteOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdUndelete)
+
+	f := cmdUndelete.Flags()
+	f.BoolVar(&undeleteOptions.All, "all", false, "restore all snapshots in the trash")
+}
+
+// findTrashedSnapshot returns the snapshot in trash whose previous ID or
+// current ID starts with prefix.
+func findTrashedSnapshot(trash restic.Snapshots, prefix string) (*restic.Snapshot, error) {
+	var match *restic.Snapshot
+	for _, sn := range trash {
+		if !strings.HasPrefix(sn.Trashed.ID.String(), prefix) && !strings.HasPrefix(sn.ID().String(), prefix) {
+			continue
+		}
+		if match != nil {
+			return nil, errors.Fatalf("snapshot ID %q is ambiguous", prefix)
+		}
+		match = sn
+	}
+	if match == nil {
+		return nil, errors.Fatalf("no snapshot with ID %q found in the trash", prefix)
+	}
+	return match, nil
+}
+
+func runUndelete(ctx context.Context, opts UndeleteOptions, gopts GlobalOptions, args []string) error {
+	if opts.All && len(args) > 0 {
+		return errors.Fatal("--all and snapshot IDs are mutually exclusive")
+	}
+	listOnly := !opts.All && len(args) == 0
+
+	var (
+		repo   *repository.Repository
+		unlock func()
+		err    error
+	)
+	if listOnly {
+		ctx, repo, unlock, err = openWithReadLock(ctx, gopts, gopts.NoLock)
+	} else {
+		ctx, repo, unlock, err = openWithExclusiveLock(ctx, gopts, false)
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var trash restic.Snapshots
+	err = restic.ForAllSnapshots(ctx, repo, repo, nil, func(_ restic.ID, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		if sn.InTrash() {
+			trash = append(trash, sn)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Sort(trash)
+
+	if listOnly {
+		return printTrash(gopts, trash)
+	}
+
+	selected := trash
+	if !opts.All {
+		selected = nil
+		for _, arg := range args {
+			sn, err := findTrashedSnapshot(trash, arg)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, sn)
+		}
+	}
+
+	for _, sn := range selected {
+		id, err := restic.UndeleteSnapshot(ctx, repo, sn)
+		if err != nil {
+			return errors.Fatalf("unable to restore snapshot %v: %v", sn.Trashed.ID.Str(), err)
+		}
+		Verbosef("restored snapshot %v as %v\n", sn.Trashed.ID.Str(), id.Str())
+	}
+	if len(selected) == 0 {
+		Verbosef("the trash is empty\n")
+	}
+	return nil
+}
+
+func printTrash(gopts GlobalOptions, trash restic.Snapshots) error {
+	type trashInfo struct {
+		ID       restic.ID `json:"id"`
+		ShortID  string    `json:"short_id"`
+		Time     string    `json:"time"`
+		Hostname string    `json:"hostname"`
+		Paths    []string  `json:"paths"`
+		Trashed  string    `json:"trashed"`
+		Expires  string    `json:"expires"`
+	}
+
+	list := make([]trashInfo, 0, len(trash))
+	for _, sn := range trash {
+		list = append(list, trashInfo{
+			ID:       sn.Trashed.ID,
+			ShortID:  sn.Trashed.ID.Str(),
+			Time:     sn.Time.Format(TimeFormat),
+			Hostname: sn.Hostname,
+			Paths:    sn.Paths,
+			Trashed:  sn.Trashed.Time.Format(TimeFormat),
+			Expires:  sn.Trashed.Expires.Format(TimeFormat),
+		})
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(list)
+	}
+
+	tab := table.New()
+	tab.AddColumn("ID", "{{ .ShortID }}")
+	tab.AddColumn("Time", "{{ .Time }}")
+	tab.AddColumn("Host", "{{ .Hostname }}")
+	tab.AddColumn("Trashed", "{{ .Trashed }}")
+	tab.AddColumn("Expires", "{{ .Expires }}")
+	tab.AddColumn("Paths", `{{ join .Paths ", " }}`)
+	for _, t := range list {
+		tab.AddRow(t)
+	}
+	return tab.Write(globalOptions.stdout)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunUndelete(t testing.TB, gopts GlobalOptions, opts UndeleteOptions, args ...string) {
+	rtest.OK(t, runUndelete(context.TODO(), opts, gopts, args))
+}
+
+func testListTrash(t testing.TB, gopts GlobalOptions) []restic.ID {
+	gopts.JSON = true
+	buf, err := withCaptureStdout(func() error {
+		return runUndelete(context.TODO(), UndeleteOptions{}, gopts, nil)
+	})
+	rtest.OK(t, err)
+
+	var trash []struct {
+		ID restic.ID `json:"id"`
+	}
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &trash))
+	ids := make([]restic.ID, 0, len(trash))
+	for _, sn := range trash {
+		ids = append(ids, sn.ID)
+	}
+	return ids
+}
+
+func TestForgetTrashUndelete(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, BackupOptions{}, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "3")}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	packs := listPacks(env.gopts, t)
+
+	testRunForget(t, env.gopts, ForgetOptions{TrashPeriod: restic.Duration{Days: 7}}, snapshotIDs[0].String())
+	_, snapmap := testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, 1, len(snapmap))
+	rtest.Equals(t, []restic.ID{snapshotIDs[0]}, testListTrash(t, env.gopts))
+
+	// prune must keep the data of snapshots in the trash
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0"})
+	rtest.Equals(t, packs, listPacks(env.gopts, t))
+	testRunCheck(t, env.gopts)
+
+	testRunUndelete(t, env.gopts, UndeleteOptions{}, snapshotIDs[0].Str())
+	_, snapmap = testRunSnapshots(t, env.gopts)
+	rtest.Equals(t, 2, len(snapmap))
+	testListSnapshots(t, env.gopts, 2)
+	rtest.Equals(t, 0, len(testListTrash(t, env.gopts)))
+}
+
+func TestPruneExpiredTrash(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, BackupOptions{}, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "3")}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	packs := listPacks(env.gopts, t)
+
+	// move the snapshot to the trash as if that happened two days ago
+	ctx, repo, unlock, err := openWithExclusiveLock(context.TODO(), env.gopts, false)
+	rtest.OK(t, err)
+	sn, err := restic.LoadSnapshot(ctx, repo, snapshotIDs[0])
+	rtest.OK(t, err)
+	_, err = restic.TrashSnapshot(ctx, repo, sn, time.Now().Add(-48*time.Hour), restic.Duration{Days: 1})
+	unlock()
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(testListTrash(t, env.gopts)))
+
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0"})
+	rtest.Equals(t, 0, len(testListTrash(t, env.gopts)))
+	testListSnapshots(t, env.gopts, 1)
+	rtest.Assert(t, !packs.Equals(listPacks(env.gopts, t)), "prune did not remove the data of the expired snapshot")
+	testRunCheck(t, env.gopts)
+}
+
+func TestForgetPathTrash(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	snapshotID := createBasicRewriteRepo(t, env)
+
+	testRunForget(t, env.gopts, ForgetOptions{TrashPeriod: restic.Duration{Days: 7}}, snapshotID.String())
+	testRunForgetPath(t, env.gopts, ForgetPathOptions{}, "/testdata/0/0/9")
+	rtest.Equals(t, []restic.ID{snapshotID}, testListTrash(t, env.gopts))
+
+	// the data of the removed path must be unused
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0"})
+	testRunCheck(t, env.gopts)
+	testRunUndelete(t, env.gopts, UndeleteOptions{All: true})
+	for _, file := range testRunLs(t, env.gopts, "latest") {
+		rtest.Assert(t, !strings.HasPrefix(file, "/testdata/0/0/9"), "restored snapshot contains %v", file)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/catalog"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/table"
+)
+
+var cmdVersions = &cobra.Command{
+	Use:   "versions [flags] path",
+	Short: "List the versions of a file in all snapshots",
+	Long: `
+The "versions" command lists every snapshot in which the content of the given
+file differs from the previous snapshot, starting with the oldest one. For each
+version, the size and modification time of the file are shown, along with the
+percentage of the data which is shared with the previous version. Snapshots in
+which the file was removed are listed as well.
+
+This helps to find the last good version of a file before it was damaged, which
+can then be restored using "restic restore <ID> --include <path>" or printed
+using "restic dump <ID> <path>".
+
+The path is relative to the current directory unless it is absolute. Use
+--host, --tag and --path to only consider some snapshots.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVersions(cmd.Context(), versionsOptions, globalOptions, args)
+	},
+}
+
+// VersionsOptions collects all options for the versions command.
+type VersionsOptions struct {
+	restic.SnapshotFilter
+}
+
+var versionsOptions VersionsOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdVersions)
+
+	f := cmdVersions.Flags()
+	initMultiSnapshotFilter(f, &versionsOptions.SnapshotFilter, true)
+}
+
+// fileVersion describes a change of the file in a snapshot.
+type fileVersion struct {
+	SnapshotID string    `json:"snapshot_id"`
+	Time       time.Time `json:"time"`
+	// Status is one of "new", "modified" or "deleted".
+	Status     string     `json:"status"`
+	Size       uint64     `json:"size,omitempty"`
+	ModTime    *time.Time `json:"mtime,omitempty"`
+	Similarity *float64   `json:"similarity,omitempty"`
+}
+
+// findFileNode returns the node at the absolute path p within the tree, or
+// nil if the path does not exist.
+func findFileNode(ctx context.Context, repo restic.BlobLoader, tree restic.ID, p string) (*restic.Node, error) {
+	components := strings.Split(strings.Trim(p, "/"), "/")
+	for i, name := range components {
+		t, err := restic.LoadTree(ctx, repo, tree)
+		if err != nil {
+			return nil, err
+		}
+		node := t.Find(name)
+		if node == nil {
+			return nil, nil
+		}
+		if i == len(components)-1 {
+			return node, nil
+		}
+		if node.Type != "dir" || node.Subtree == nil {
+			return nil, nil
+		}
+		tree = *node.Subtree
+	}
+	return nil, nil
+}
+
+// versionNode returns the node at the absolute path p within the snapshot, or
+// nil if the path does not exist. If the snapshot has a catalog, unchanged is
+// set without loading any tree if the content of the file equals previous.
+func versionNode(ctx context.Context, repo restic.BlobLoader, sn *restic.Snapshot, p string, previous *restic.Node) (node *restic.Node, unchanged bool, err error) {
+	if c := loadCatalog(ctx, repo, sn); c != nil {
+		entry, err := c.Find(ctx, p)
+		if err != nil {
+			return nil, false, err
+		}
+		if entry == nil {
+			return nil, false, nil
+		}
+		if entry.Node.Type != "file" {
+			return entry.Node, false, nil
+		}
+		if previous != nil && entry.ContentHash != nil && entry.ContentHash.Equal(catalog.ContentHash(previous)) {
+			return nil, true, nil
+		}
+	}
+
+	node, err = findFileNode(ctx, repo, *sn.Tree, p)
+	return node, false, err
+}
+
+// sameFileContent returns whether both nodes reference the same data blobs.
+func sameFileContent(a, b *restic.Node) bool {
+	if a.Size != b.Size || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if a.Content[i] != b.Content[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// blobSimilarity returns the percentage of the data of content which is
+// stored in blobs which are also part of previous.
+func blobSimilarity(repo restic.Repository, previous, content restic.IDs) float64 {
+	known := restic.NewIDSet(previous...)
+
+	var total, shared uint64
+	for _, id := range content {
+		size, _ := repo.LookupBlobSize(restic.DataBlob, id)
+		total += uint64(size)
+		if known.Has(id) {
+			shared += uint64(size)
+		}
+	}
+	if total == 0 {
+		if len(previous) == 0 {
+			return 100
+		}
+		return 0
+	}
+	return float64(shared) * 100 / float64(total)
+}
+
+// fileVersions returns the snapshots in which the file at p differs from the
+// previous snapshot. The snapshots must be sorted by time.
+func fileVersions(ctx context.Context, repo restic.Repository, snapshots restic.Snapshots, p string) ([]fileVersion, error) {
+	var versions []fileVersion
+	var previous *restic.Node
+
+	for _, sn := range snapshots {
+		if sn.Tree == nil {
+			return nil, errors.Errorf("snapshot %v has nil tree", sn.ID().Str())
+		}
+		node, unchanged, err := versionNode(ctx, repo, sn, p, previous)
+		if err != nil {
+			return nil, errors.Fatalf("unable to load snapshot %v: %v", sn.ID().Str(), err)
+		}
+		if unchanged {
+			continue
+		}
+		if node != nil && node.Type != "file" {
+			return nil, errors.Fatalf("%v is a %v in snapshot %v, not a file", p, node.Type, sn.ID().Str())
+		}
+
+		v := fileVersion{SnapshotID: sn.ID().Str(), Time: sn.Time}
+		switch {
+		case node == nil && previous == nil:
+			continue
+		case node == nil:
+			v.Status = "deleted"
+		case previous == nil:
+			v.Status = "new"
+		case sameFileContent(previous, node):
+			continue
+		default:
+			v.Status = "modified"
+			similarity := blobSimilarity(repo, previous.Content, node.Content)
+			v.Similarity = &similarity
+		}
+
+		if node != nil {
+			modTime := node.ModTime
+			v.Size = node.Size
+			v.ModTime = &modTime
+		}
+		versions = append(versions, v)
+		previous = node
+	}
+	return versions, nil
+}
+
+// printFileVersions prints a text table of the versions to stdout.
+func printFileVersions(stdout io.Writer, versions []fileVersion) error {
+	tab := table.New()
+	tab.AddColumn("ID", "{{ .ID }}")
+	tab.AddColumn("Time", "{{ .Time }}")
+	tab.AddColumn("Status", "{{ .Status }}")
+	tab.AddColumn("Size", "{{ .Size }}")
+	tab.AddColumn("Modified", "{{ .Modified }}")
+	tab.AddColumn("Similarity", "{{ .Similarity }}")
+
+	type row struct {
+		ID, Time, Status, Size, Modified, Similarity string
+	}
+	for _, v := range versions {
+		r := row{ID: v.SnapshotID, Time: v.Time.Local().Format(TimeFormat), Status: v.Status}
+		if v.ModTime != nil {
+			r.Size = ui.FormatBytes(v.Size)
+			r.Modified = v.ModTime.Local().Format(TimeFormat)
+		}
+		if v.Similarity != nil {
+			r.Similarity = fmt.Sprintf("%.1f%%", *v.Similarity)
+		}
+		tab.AddRow(r)
+	}
+	tab.AddFooter(fmt.Sprintf("%d versions", len(versions)))
+
+	return tab.Write(stdout)
+}
+
+func runVersions(ctx context.Context, opts VersionsOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("specify exactly one file")
+	}
+
+	abs, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	p, err := snapshotPath(abs)
+	if err != nil {
+		return err
+	}
+	if p == "/" {
+		return errors.Fatal("the root directory is not a file")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var snapshots restic.Snapshots
+	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, nil) {
+		snapshots = append(snapshots, sn)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err = repo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+
+	versions, err := fileVersions(ctx, repo, snapshots, p)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		if versions == nil {
+			versions = []fileVersion{}
+		}
+		return json.NewEncoder(globalOptions.stdout).Encode(versions)
+	}
+
+	if len(versions) == 0 {
+		Printf("%v was not found in any of the %d snapshots\n", p, len(snapshots))
+		return nil
+	}
+	return printFileVersions(globalOptions.stdout, versions)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunVersions(t testing.TB, gopts GlobalOptions, file string) []fileVersion {
+	buf, err := withCaptureStdout(func() error {
+		gopts.JSON = true
+		return runVersions(context.TODO(), VersionsOptions{}, gopts, []string{file})
+	})
+	rtest.OK(t, err)
+
+	var versions []fileVersion
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &versions))
+	return versions
+}
+
+func TestVersions(t *testing.T) {
+	t.Run("trees", func(t *testing.T) {
+		testVersions(t, BackupOptions{})
+	})
+	t.Run("catalog", func(t *testing.T) {
+		testVersions(t, BackupOptions{Catalog: true})
+	})
+}
+
+func testVersions(t *testing.T, opts BackupOptions) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	dir := filepath.Join(env.base, "data")
+	file := filepath.Join(dir, "file")
+	rtest.OK(t, os.MkdirAll(dir, 0700))
+	rtest.OK(t, os.WriteFile(filepath.Join(dir, "other"), []byte("other"), 0600))
+
+	backup := func() {
+		testRunBackup(t, "", []string{dir}, opts, env.gopts)
+	}
+
+	// not yet part of the snapshots
+	backup()
+	rtest.OK(t, os.WriteFile(file, []byte("version 1"), 0600))
+	backup()
+	// unchanged
+	backup()
+	rtest.OK(t, os.WriteFile(file, []byte("version 2"), 0600))
+	backup()
+	rtest.OK(t, os.Remove(file))
+	backup()
+
+	versions := testRunVersions(t, env.gopts, file)
+	rtest.Equals(t, 3, len(versions))
+	rtest.Equals(t, "new", versions[0].Status)
+	rtest.Equals(t, uint64(9), versions[0].Size)
+	rtest.Equals(t, "modified", versions[1].Status)
+	rtest.Assert(t, versions[1].Similarity != nil && *versions[1].Similarity == 0,
+		"unexpected similarity %v", versions[1].Similarity)
+	rtest.Equals(t, "deleted", versions[2].Status)
+	rtest.Assert(t, versions[2].ModTime == nil, "deleted version has a modification time")
+
+	rtest.Equals(t, 0, len(testRunVersions(t, env.gopts, filepath.Join(dir, "missing"))))
+}
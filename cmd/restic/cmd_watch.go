@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fswatch"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+var cmdWatch = &cobra.Command{
+	Use:   "watch [flags] [FILE/DIR] ...",
+	Short: "Watch files and directories and back up changes continuously",
+	Long: `
+The "watch" command backs up the given files and directories and then keeps
+running, watching them for changes. Every interval, a new snapshot is created
+if anything has changed. Only the directories which contain changes are read
+again, the contents of all other directories are taken from the previous
+snapshot without scanning them.
+
+Changes are detected using inotify on Linux, kqueue on macOS and BSD and
+ReadDirectoryChangesW on Windows. Every directory below the targets is watched
+separately, on Linux the limit fs.inotify.max_user_watches may have to be
+raised for large directory trees. If watching fails, for example as the limit
+was reached, all files are read again for each snapshot.
+
+The repository is only locked while a snapshot is created, other commands like
+"forget" and "prune" can run in between.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was stopped using Ctrl-C or a signal, and 1 if
+the first snapshot could not be created. Errors while creating later snapshots
+are printed as warnings and the snapshot is retried after the interval.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		term, cancel := setupTermstatus()
+		defer cancel()
+		return runWatch(cmd.Context(), watchOptions, globalOptions, term, args)
+	},
+}
+
+// WatchOptions bundles all options for the watch command.
+type WatchOptions struct {
+	BackupOptions
+	Interval time.Duration
+}
+
+var watchOptions WatchOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdWatch)
+
+	f := cmdWatch.Flags()
+	f.DurationVar(&watchOptions.Interval, "interval", 5*time.Minute, "create a snapshot at most every `duration` if something has changed")
+
+	opts := &watchOptions.BackupOptions
+	opts.GroupBy = restic.SnapshotGroupByOptions{Host: true, Path: true}
+	initExcludePatternOptions(f, &opts.excludePatternOptions)
+	f.BoolVarP(&opts.ExcludeOtherFS, "one-file-system", "x", false, "exclude other file systems, don't cross filesystem boundaries and subvolumes")
+	f.StringArrayVar(&opts.ExcludeIfPresent, "exclude-if-present", nil, "takes `filename[:header]`, exclude contents of directories containing filename (except filename itself) if header of that file is as provided (can be specified multiple times)")
+	f.BoolVar(&opts.ExcludeCaches, "exclude-caches", false, `excludes cache directories that are marked with a CACHEDIR.TAG file. See https://bford.info/cachedir/ for the Cache Directory Tagging Standard`)
+	f.StringVar(&opts.ExcludeLargerThan, "exclude-larger-than", "", "max `size` of the files to be backed up (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.Var(&opts.Tags, "tag", "add `tags` for the new snapshots in the format `tag[,tag,...]` (can be specified multiple times)")
+	f.StringVarP(&opts.Host, "host", "H", "", "set the `hostname` for the snapshots manually (default: $RESTIC_HOST)")
+	f.BoolVar(&opts.WithAtime, "with-atime", false, "store the atime for all files and directories")
+	f.BoolVar(&opts.SkipIfUnchanged, "skip-if-unchanged", false, "skip snapshot creation if identical to the previous snapshot")
+
+	if host := os.Getenv("RESTIC_HOST"); host != "" {
+		opts.Host = host
+	}
+}
+
+func runWatch(ctx context.Context, opts WatchOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+	if opts.Interval <= 0 {
+		return errors.Fatal("--interval must be positive")
+	}
+	if len(args) == 0 {
+		return errors.Fatal("nothing to watch, please specify the files and directories to back up")
+	}
+
+	// the changes are reported for absolute paths
+	var targets []string
+	for _, arg := range args {
+		target, err := filepath.Abs(arg)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		targets = append(targets, target)
+	}
+	targets, err := filterExisting(targets)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fswatch.New(targets, func(err error) {
+		Warnf("unable to watch for changes, all files are read again for each snapshot: %v\n", err)
+	})
+	if err != nil {
+		return errors.Fatalf("unable to watch for changes: %v", err)
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
+	backupOpts := opts.BackupOptions
+	// the scanner would read all directories
+	backupOpts.NoScan = true
+	backupOpts.snapshotSaved = func(id restic.ID) {
+		// only changes since this snapshot are known, so it must be used as
+		// the parent even if other snapshots are created in between
+		backupOpts.Parent = id.String()
+	}
+
+	// the first snapshot reads all directories, using the latest snapshot
+	// of the targets as parent
+	changes := fswatch.AllChanged()
+	first := true
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if !changes.Empty() {
+			if changes.All() {
+				Verbosef("creating snapshot of %v\n", targets)
+			} else {
+				Verbosef("creating snapshot of %v, %d paths changed\n", targets, changes.Len())
+			}
+			backupOpts.skipUnchangedDir = changes.Unchanged
+
+			err := runBackup(ctx, backupOpts, gopts, term, targets)
+			switch {
+			case ctx.Err() != nil:
+				return ErrOK
+			case errors.Is(err, ErrInvalidSourceData):
+				// read the directories with errors again next time
+				debug.Log("snapshot is incomplete, keeping %d changes", changes.Len())
+			case err != nil && first:
+				// most likely the repository or the options are invalid
+				return err
+			case err != nil:
+				Warnf("unable to create snapshot, retrying in %v: %v\n", opts.Interval, err)
+				// the previous snapshot may have been removed
+				backupOpts.Parent = ""
+				changes = fswatch.AllChanged()
+			default:
+				changes = fswatch.NewChanges()
+			}
+			first = false
+		}
+
+		select {
+		case <-ctx.Done():
+			// stopping the command is not an error
+			return ErrOK
+		case <-ticker.C:
+		}
+		changes.Merge(watcher.Changes())
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"github.com/restic/chunker"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// rechunker copies trees from one repository to another and splits the file
+// contents again using the chunker polynomial of the destination repository.
+type rechunker struct {
+	srcRepo    restic.Repository
+	dstRepo    restic.Repository
+	pol        chunker.Pol
+	recompress bool
+
+	chunker *chunker.Chunker
+	buf     []byte
+
+	// blobs which were already stored again for recompression
+	recompressed restic.BlobSet
+
+	// trees maps source to destination tree IDs
+	trees map[restic.ID]restic.ID
+	// contents maps the source content of a file to the rechunked content
+	contents map[string]restic.IDs
+}
+
+func newRechunker(srcRepo, dstRepo restic.Repository, recompress bool) *rechunker {
+	pol := dstRepo.Config().ChunkerPolynomial
+	return &rechunker{
+		srcRepo:    srcRepo,
+		dstRepo:    dstRepo,
+		pol:        pol,
+		recompress: recompress,
+		chunker:    chunker.New(nil, pol),
+		buf:        make([]byte, chunker.MaxSize),
+		trees:      make(map[restic.ID]restic.ID),
+		contents:   make(map[string]restic.IDs),
+
+		recompressed: restic.NewBlobSet(),
+	}
+}
+
+// RewriteTree stores a copy of the tree in the destination repository in
+// which the content of all files is rechunked. It returns the ID of the new
+// tree. The pack uploader of the destination repository must be running.
+func (r *rechunker) RewriteTree(ctx context.Context, treeID restic.ID, p *progress.Counter) (restic.ID, error) {
+	if newID, ok := r.trees[treeID]; ok {
+		return newID, nil
+	}
+
+	tree, err := restic.LoadTree(ctx, r.srcRepo, treeID)
+	if err != nil {
+		return restic.ID{}, errors.Wrapf(err, "LoadTree(%v)", treeID.Str())
+	}
+
+	tb := restic.NewTreeJSONBuilder()
+	for _, node := range tree.Nodes {
+		switch {
+		case node.Type == "dir" && node.Subtree != nil:
+			subtree, err := r.RewriteTree(ctx, *node.Subtree, p)
+			if err != nil {
+				return restic.ID{}, err
+			}
+			node.Subtree = &subtree
+		case node.Type == "file" && len(node.Content) > 0:
+			content, err := r.rechunkFile(ctx, node.Content)
+			if err != nil {
+				return restic.ID{}, errors.Wrapf(err, "rechunk %v", node.Name)
+			}
+			node.Content = content
+			p.Add(1)
+		}
+
+		if err := tb.AddNode(node); err != nil {
+			return restic.ID{}, err
+		}
+	}
+
+	buf, err := tb.Finalize()
+	if err != nil {
+		return restic.ID{}, err
+	}
+	newID := restic.Hash(buf)
+	_, _, _, err = r.dstRepo.SaveBlob(ctx, restic.TreeBlob, buf, newID, r.storeDuplicate(restic.TreeBlob, newID))
+	if err != nil {
+		return restic.ID{}, err
+	}
+	r.trees[treeID] = newID
+	return newID, nil
+}
+
+func (r *rechunker) rechunkFile(ctx context.Context, content restic.IDs) (restic.IDs, error) {
+	key := contentKey(content)
+	if ids, ok := r.contents[key]; ok {
+		return ids, nil
+	}
+
+	r.chunker.Reset(&blobReader{ctx: ctx, repo: r.srcRepo, ids: content}, r.pol)
+	ids := make(restic.IDs, 0, len(content))
+	for {
+		chunk, err := r.chunker.Next(r.buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		id := restic.Hash(chunk.Data)
+		_, _, _, err = r.dstRepo.SaveBlob(ctx, restic.DataBlob, chunk.Data, id, r.storeDuplicate(restic.DataBlob, id))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		r.buf = chunk.Data
+	}
+
+	debug.Log("rechunked %d blobs into %d blobs", len(content), len(ids))
+	r.contents[key] = ids
+	return ids, nil
+}
+
+// storeDuplicate returns true if the blob must be stored again to recompress it.
+func (r *rechunker) storeDuplicate(t restic.BlobType, id restic.ID) bool {
+	h := restic.BlobHandle{Type: t, ID: id}
+	if !r.recompress || r.recompressed.Has(h) || !storedUncompressed(r.dstRepo, h) {
+		return false
+	}
+	r.recompressed.Insert(h)
+	return true
+}
+
+func contentKey(content restic.IDs) string {
+	key := make([]byte, 0, len(content)*len(restic.ID{}))
+	for _, id := range content {
+		key = append(key, id[:]...)
+	}
+	return string(key)
+}
+
+// blobReader returns the concatenated content of a list of data blobs.
+type blobReader struct {
+	ctx  context.Context
+	repo restic.BlobLoader
+	ids  restic.IDs
+
+	buf  []byte
+	rest []byte
+}
+
+func (rd *blobReader) Read(p []byte) (int, error) {
+	for len(rd.rest) == 0 {
+		if len(rd.ids) == 0 {
+			return 0, io.EOF
+		}
+
+		var err error
+		rd.buf, err = rd.repo.LoadBlob(rd.ctx, restic.DataBlob, rd.ids[0], rd.buf)
+		if err != nil {
+			return 0, err
+		}
+		rd.rest = rd.buf
+		rd.ids = rd.ids[1:]
+	}
+
+	n := copy(p, rd.rest)
+	rd.rest = rd.rest[n:]
+	return n, nil
+}
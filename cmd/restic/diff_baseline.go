@@ -0,0 +1,105 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// defaultSystemDirs are the directories in which any change compared to the
+// baseline snapshot is unexpected. Windows drives are stored as /C/... in
+// snapshots.
+var defaultSystemDirs = []string{
+	"/bin",
+	"/boot",
+	"/etc",
+	"/lib",
+	"/lib64",
+	"/sbin",
+	"/usr/bin",
+	"/usr/lib",
+	"/usr/sbin",
+	"/C/Windows",
+	"/C/Program Files",
+	"/C/Program Files (x86)",
+}
+
+// executableExtensions are the file name extensions of programs and scripts
+// which are executable on Windows without an executable bit.
+var executableExtensions = map[string]struct{}{
+	".bat": {},
+	".cmd": {},
+	".com": {},
+	".dll": {},
+	".exe": {},
+	".msi": {},
+	".ps1": {},
+	".scr": {},
+	".sys": {},
+	".vbs": {},
+}
+
+// baselineChecker flags changes compared to a baseline snapshot which are
+// unexpected on a system with a known good state.
+type baselineChecker struct {
+	systemDirs []string
+	alerts     int
+}
+
+func newBaselineChecker(systemDirs []string) *baselineChecker {
+	if len(systemDirs) == 0 {
+		systemDirs = defaultSystemDirs
+	}
+
+	dirs := make([]string, 0, len(systemDirs))
+	for _, dir := range systemDirs {
+		dir = strings.ReplaceAll(dir, "\\", "/")
+		// Windows paths are stored as /C/... in snapshots
+		if len(dir) >= 2 && dir[1] == ':' {
+			dir = dir[:1] + dir[2:]
+		}
+		dirs = append(dirs, path.Clean("/"+dir))
+	}
+	return &baselineChecker{systemDirs: dirs}
+}
+
+// isExecutable returns whether node is a file which can be executed.
+func isExecutable(node *restic.Node) bool {
+	if node == nil || node.Type != "file" {
+		return false
+	}
+	if node.Mode&0111 != 0 {
+		return true
+	}
+	_, ok := executableExtensions[strings.ToLower(path.Ext(node.Name))]
+	return ok
+}
+
+// inSystemDir returns whether the item at p is a system directory or below one.
+func (b *baselineChecker) inSystemDir(p string) bool {
+	p = strings.TrimSuffix(p, "/")
+	for _, dir := range b.systemDirs {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns why the change of node is unexpected, or an empty string if
+// it is not.
+func (b *baselineChecker) Check(change *Change, node *restic.Node) string {
+	var alert string
+	switch {
+	case strings.Contains(change.Modifier, "+") && isExecutable(node):
+		alert = "executable added"
+	case b.inSystemDir(change.Path):
+		alert = "system directory changed"
+	}
+
+	if alert != "" {
+		b.alerts++
+	}
+	return alert
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestBaselineChecker(t *testing.T) {
+	b := newBaselineChecker([]string{"/etc", `C:\Windows`})
+
+	for _, test := range []struct {
+		change *Change
+		node   *restic.Node
+		alert  string
+	}{
+		{NewChange("/home/user/file", "+"), &restic.Node{Name: "file", Type: "file", Mode: 0644}, ""},
+		{NewChange("/home/user/script", "+"), &restic.Node{Name: "script", Type: "file", Mode: 0755}, "executable added"},
+		{NewChange("/home/user/setup.EXE", "+"), &restic.Node{Name: "setup.EXE", Type: "file", Mode: 0666}, "executable added"},
+		{NewChange("/home/user/bin/", "+"), &restic.Node{Name: "bin", Type: "dir", Mode: 0755}, ""},
+		{NewChange("/home/user/script", "M"), &restic.Node{Name: "script", Type: "file", Mode: 0755}, ""},
+		{NewChange("/etc/passwd", "M"), &restic.Node{Name: "passwd", Type: "file", Mode: 0644}, "system directory changed"},
+		{NewChange("/etc/", "U"), &restic.Node{Name: "etc", Type: "dir", Mode: 0755}, "system directory changed"},
+		{NewChange("/etcetera/file", "-"), &restic.Node{Name: "file", Type: "file", Mode: 0644}, ""},
+		{NewChange("/C/Windows/System32/drivers/", "+"), &restic.Node{Name: "drivers", Type: "dir", Mode: 0755}, "system directory changed"},
+	} {
+		rtest.Equals(t, test.alert, b.Check(test.change, test.node), test.change.Path)
+	}
+	rtest.Equals(t, 5, b.alerts)
+}
+
+func TestBaselineCheckerDefaults(t *testing.T) {
+	b := newBaselineChecker(nil)
+	rtest.Assert(t, b.inSystemDir("/usr/bin/ls"), "/usr/bin not a system directory")
+	rtest.Assert(t, !b.inSystemDir("/home/user"), "/home is a system directory")
+}
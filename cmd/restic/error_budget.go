@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/errors"
+)
+
+// ErrErrorBudgetExceeded is returned when a backup was aborted because too
+// many source files could not be read.
+var ErrErrorBudgetExceeded = errors.New("backup aborted, too many source files could not be read")
+
+// errorBudget decides whether a backup should be aborted because too many
+// errors occurred while reading the source files.
+type errorBudget struct {
+	// maxErrors is the number of errors which is tolerated, zero disables
+	// the limit.
+	maxErrors uint
+	// maxPercent is the percentage of failed items which is tolerated, zero
+	// disables the limit.
+	maxPercent float64
+
+	m         sync.Mutex
+	errors    uint
+	processed uint
+	total     uint
+	exceeded  bool
+}
+
+func newErrorBudget(maxErrors uint, maxPercent float64) *errorBudget {
+	return &errorBudget{maxErrors: maxErrors, maxPercent: maxPercent}
+}
+
+func (b *errorBudget) enabled() bool {
+	return b.maxErrors > 0 || b.maxPercent > 0
+}
+
+// ReportTotal records the number of items found by the scanner so far.
+func (b *errorBudget) ReportTotal(_ string, s archiver.ScanStats) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.total = s.Files + s.Dirs + s.Others
+}
+
+// CompleteItem records an item which was backed up.
+func (b *errorBudget) CompleteItem() {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.processed++
+}
+
+// Error records a failed item and returns ErrErrorBudgetExceeded once the
+// number or the percentage of errors is larger than allowed.
+func (b *errorBudget) Error() error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.errors++
+	if b.exceeded {
+		return ErrErrorBudgetExceeded
+	}
+
+	if b.maxErrors > 0 && b.errors > b.maxErrors {
+		b.exceeded = true
+	}
+
+	if b.maxPercent > 0 {
+		// the scan may not have found all items yet
+		items := b.total
+		if items < b.processed+b.errors {
+			items = b.processed + b.errors
+		}
+		if float64(b.errors)*100/float64(items) > b.maxPercent {
+			b.exceeded = true
+		}
+	}
+
+	if b.exceeded {
+		return ErrErrorBudgetExceeded
+	}
+	return nil
+}
+
+// Exceeded returns whether the error budget was exceeded.
+func (b *errorBudget) Exceeded() bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.exceeded
+}
+
+// String returns a description of the errors and the limits.
+func (b *errorBudget) String() string {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	s := fmt.Sprintf("%d errors", b.errors)
+	if b.maxErrors > 0 {
+		s += fmt.Sprintf(", at most %d allowed", b.maxErrors)
+	}
+	if b.maxPercent > 0 {
+		s += fmt.Sprintf(", at most %g%% of the files and directories allowed", b.maxPercent)
+	}
+	return s
+}
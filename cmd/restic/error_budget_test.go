@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/archiver"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestErrorBudgetMaxErrors(t *testing.T) {
+	b := newErrorBudget(2, 0)
+	rtest.Assert(t, b.enabled(), "budget should be enabled")
+
+	rtest.OK(t, b.Error())
+	rtest.OK(t, b.Error())
+	rtest.Assert(t, !b.Exceeded(), "budget exceeded too early")
+	rtest.Equals(t, ErrErrorBudgetExceeded, b.Error())
+	rtest.Assert(t, b.Exceeded(), "budget not exceeded")
+}
+
+func TestErrorBudgetMaxPercent(t *testing.T) {
+	b := newErrorBudget(0, 10)
+	b.ReportTotal("", archiver.ScanStats{Files: 18, Dirs: 2})
+
+	rtest.OK(t, b.Error())
+	rtest.OK(t, b.Error())
+	rtest.Equals(t, ErrErrorBudgetExceeded, b.Error())
+}
+
+func TestErrorBudgetIncompleteScan(t *testing.T) {
+	b := newErrorBudget(0, 50)
+	b.ReportTotal("/foo", archiver.ScanStats{Files: 1})
+
+	// the processed items count once the scan falls behind
+	for i := 0; i < 2; i++ {
+		b.CompleteItem()
+	}
+	rtest.OK(t, b.Error())
+	rtest.OK(t, b.Error())
+	rtest.Equals(t, ErrErrorBudgetExceeded, b.Error())
+}
+
+func TestErrorBudgetDisabled(t *testing.T) {
+	b := newErrorBudget(0, 0)
+	rtest.Assert(t, !b.enabled(), "budget should be disabled")
+}
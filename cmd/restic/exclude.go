@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/textfile"
 	"github.com/restic/restic/internal/ui"
 	"github.com/spf13/pflag"
@@ -105,6 +107,61 @@ func rejectByInsensitivePattern(patterns []string) RejectByNameFunc {
 	}
 }
 
+// presetExclusions records the items excluded by each exclude preset. As the
+// scanner and the archiver both check all items, the paths are deduplicated.
+type presetExclusions struct {
+	mu    sync.Mutex
+	items map[string]map[string]struct{}
+}
+
+func (e *presetExclusions) add(preset, item string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.items == nil {
+		e.items = make(map[string]map[string]struct{})
+	}
+	if e.items[preset] == nil {
+		e.items[preset] = make(map[string]struct{})
+	}
+	if _, ok := e.items[preset][item]; ok {
+		return false
+	}
+	e.items[preset][item] = struct{}{}
+	return true
+}
+
+// Count returns the number of items excluded by the preset. The contents of
+// excluded directories are not counted.
+func (e *presetExclusions) Count(preset string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.items[preset])
+}
+
+// rejectByPreset returns a RejectByNameFunc which rejects files matching one of
+// the patterns of the preset and records them in excluded. Each newly excluded
+// item is passed to report.
+func rejectByPreset(preset filter.Preset, excluded *presetExclusions, report func(item string)) RejectByNameFunc {
+	patterns := append([]string(nil), preset.Patterns...)
+	var reject RejectByNameFunc
+	if preset.CaseInsensitive {
+		reject = rejectByInsensitivePattern(patterns)
+	} else {
+		reject = rejectByPattern(patterns)
+	}
+
+	return func(item string) bool {
+		if !reject(item) {
+			return false
+		}
+		if excluded.add(preset.Name, item) && report != nil {
+			report(item)
+		}
+		return true
+	}
+}
+
 // rejectIfPresent returns a RejectByNameFunc which itself returns whether a path
 // should be excluded. The RejectByNameFunc considers a file to be excluded when
 // it resides in a directory with an exclusion file, that is specified by
@@ -385,12 +442,47 @@ func rejectBySize(maxSizeStr string) (RejectFunc, error) {
 	}, nil
 }
 
+// rejectNoDump rejects files and directories with the nodump flag, which
+// marks items that should not be included in backups.
+func rejectNoDump(item string, fi os.FileInfo) bool {
+	if !fi.Mode().IsRegular() && !fi.IsDir() {
+		return false
+	}
+	if fs.NoDump(item) {
+		debug.Log("rejecting %v, nodump flag is set", item)
+		return true
+	}
+	return false
+}
+
 // readPatternsFromFiles reads all files and returns the list of
 // patterns. For each line, leading and trailing white space is removed
 // and comment lines are ignored. For each remaining pattern, environment
 // variables are resolved. For adding a literal dollar sign ($), write $$ to
 // the file.
 func readPatternsFromFiles(files []string) ([]string, error) {
+	var patterns []string
+	for _, filename := range files {
+		lines, err := readPatternFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			patterns = append(patterns, line.Pattern)
+		}
+	}
+	return patterns, nil
+}
+
+// patternLine is a pattern read from a file, together with its line number.
+type patternLine struct {
+	Line    int
+	Pattern string
+}
+
+// readPatternFile reads the patterns from filename. Empty lines and comments
+// are skipped, environment variables are expanded.
+func readPatternFile(filename string) ([]patternLine, error) {
 	getenvOrDollar := func(s string) string {
 		if s == "$" {
 			return "$"
@@ -398,36 +490,34 @@ func readPatternsFromFiles(files []string) ([]string, error) {
 		return os.Getenv(s)
 	}
 
-	var patterns []string
-	for _, filename := range files {
-		err := func() (err error) {
-			data, err := textfile.Read(filename)
-			if err != nil {
-				return err
-			}
-
-			scanner := bufio.NewScanner(bytes.NewReader(data))
-			for scanner.Scan() {
-				line := strings.TrimSpace(scanner.Text())
+	var patterns []patternLine
+	err := func() (err error) {
+		data, err := textfile.Read(filename)
+		if err != nil {
+			return err
+		}
 
-				// ignore empty lines
-				if line == "" {
-					continue
-				}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for lineNo := 1; scanner.Scan(); lineNo++ {
+			line := strings.TrimSpace(scanner.Text())
 
-				// strip comments
-				if strings.HasPrefix(line, "#") {
-					continue
-				}
+			// ignore empty lines
+			if line == "" {
+				continue
+			}
 
-				line = os.Expand(line, getenvOrDollar)
-				patterns = append(patterns, line)
+			// strip comments
+			if strings.HasPrefix(line, "#") {
+				continue
 			}
-			return scanner.Err()
-		}()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read patterns from file %q: %w", filename, err)
+
+			line = os.Expand(line, getenvOrDollar)
+			patterns = append(patterns, patternLine{Line: lineNo, Pattern: line})
 		}
+		return scanner.Err()
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patterns from file %q: %w", filename, err)
 	}
 	return patterns, nil
 }
@@ -496,3 +586,74 @@ func (opts excludePatternOptions) CollectPatterns() ([]RejectByNameFunc, error)
 	}
 	return fs, nil
 }
+
+// repoRuleSet is a version of a rule set stored in the repository.
+type repoRuleSet struct {
+	restic.RuleSet
+}
+
+func (rs repoRuleSet) String() string {
+	return fmt.Sprintf("%s@%d", rs.Name, rs.Version)
+}
+
+// loadRepoRuleSets loads the rule sets referenced by --rules-from-repo.
+func loadRepoRuleSets(ctx context.Context, repo restic.ListerLoaderUnpacked, specs []string) ([]repoRuleSet, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	all, err := restic.LoadRuleSets(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	var ruleSets []repoRuleSet
+	for _, spec := range specs {
+		rs, err := all.Lookup(spec)
+		if err != nil {
+			return nil, errors.Fatalf("--rules-from-repo: %v", err)
+		}
+		ruleSets = append(ruleSets, repoRuleSet{RuleSet: rs})
+	}
+	return ruleSets, nil
+}
+
+// CollectPatterns returns the functions rejecting the items excluded by the
+// patterns of the rule set. The patterns are evaluated separately from the
+// patterns passed via other options. The presets of the rule set are not
+// included.
+func (rs repoRuleSet) CollectPatterns() ([]RejectByNameFunc, error) {
+	var fs []RejectByNameFunc
+	if len(rs.InsensitiveExcludes) > 0 {
+		if err := filter.ValidatePatterns(rs.InsensitiveExcludes); err != nil {
+			return nil, errors.Fatalf("rule set %v: %s", rs, err)
+		}
+		fs = append(fs, rejectByInsensitivePattern(append([]string(nil), rs.InsensitiveExcludes...)))
+	}
+	if len(rs.Excludes) > 0 {
+		if err := filter.ValidatePatterns(rs.Excludes); err != nil {
+			return nil, errors.Fatalf("rule set %v: %s", rs, err)
+		}
+		fs = append(fs, rejectByPattern(rs.Excludes))
+	}
+	return fs, nil
+}
+
+// addRuleSetPresets adds the presets used by the rule sets to presets, unless
+// they are already part of it.
+func addRuleSetPresets(presets []string, ruleSets []repoRuleSet) []string {
+	for _, rs := range ruleSets {
+		for _, name := range rs.ExcludePresets {
+			found := false
+			for _, p := range presets {
+				if strings.TrimSpace(p) == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				presets = append(presets, name)
+			}
+		}
+	}
+	return presets
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/test"
 )
 
@@ -62,6 +63,42 @@ func TestRejectByInsensitivePattern(t *testing.T) {
 	}
 }
 
+func TestRejectByPreset(t *testing.T) {
+	preset := filter.Preset{
+		Name:            "test",
+		Patterns:        []string{"AppData/Local/Temp", "*.tmp"},
+		CaseInsensitive: true,
+	}
+	excluded := &presetExclusions{}
+	var reported []string
+	reject := rejectByPreset(preset, excluded, func(item string) {
+		reported = append(reported, item)
+	})
+
+	var tests = []struct {
+		filename string
+		reject   bool
+	}{
+		{filename: "/c/Users/user/appdata/local/temp", reject: true},
+		{filename: "/c/Users/user/AppData/Local/Temp", reject: true},
+		{filename: "/c/Users/user/Documents/report.TMP", reject: true},
+		{filename: "/c/Users/user/Documents/report.doc", reject: false},
+		// items checked twice, by the scanner and the archiver, are counted once
+		{filename: "/c/Users/user/Documents/report.TMP", reject: true},
+	}
+
+	for _, tc := range tests {
+		res := reject(tc.filename)
+		if res != tc.reject {
+			t.Fatalf("wrong result for filename %v: want %v, got %v",
+				tc.filename, tc.reject, res)
+		}
+	}
+	test.Equals(t, 3, excluded.Count("test"))
+	test.Equals(t, 3, len(reported))
+	test.Equals(t, []string{"AppData/Local/Temp", "*.tmp"}, preset.Patterns)
+}
+
 func TestIsExcludedByFile(t *testing.T) {
 	const (
 		tagFilename = "CACHEDIR.TAG"
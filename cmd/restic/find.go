@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 	"github.com/spf13/pflag"
 )
@@ -67,3 +68,16 @@ func FindFilteredSnapshots(ctx context.Context, be restic.Lister, loader restic.
 	}()
 	return out
 }
+
+// errMetadataOnly returns an error if the file contents of sn are stored in a
+// different repository, as the snapshot was copied using "copy --trees-only".
+func errMetadataOnly(sn *restic.Snapshot) error {
+	if !sn.MetadataOnly() {
+		return nil
+	}
+	source := sn.Remote.Repository
+	if sn.Remote.Location != "" {
+		source += " at " + sn.Remote.Location
+	}
+	return errors.Fatalf("snapshot %v only contains metadata, the file contents are stored in repository %v", sn.ID().Str(), source)
+}
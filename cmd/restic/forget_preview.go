@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/table"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+// previewHorizon is the time span for which future snapshots are simulated.
+const previewHorizon = 365 * 24 * time.Hour
+
+// ForgetPreviewGroup describes how the policy affects a snapshot group in JSON.
+type ForgetPreviewGroup struct {
+	Tags     []string              `json:"tags"`
+	Host     string                `json:"host"`
+	Paths    []string              `json:"paths"`
+	Interval time.Duration         `json:"interval"`
+	Months   []ForgetPreviewMonth  `json:"months"`
+	Keep     []Snapshot            `json:"keep"`
+	Future   []ForgetPreviewFuture `json:"future_keep"`
+}
+
+// ForgetPreviewMonth contains the number of snapshots in a month and how many
+// of them are kept.
+type ForgetPreviewMonth struct {
+	Month        string `json:"month"`
+	Existing     int    `json:"existing"`
+	ExistingKept int    `json:"existing_kept"`
+	Future       int    `json:"future"`
+	FutureKept   int    `json:"future_kept"`
+}
+
+// ForgetPreviewFuture is a hypothetical future snapshot which is kept.
+type ForgetPreviewFuture struct {
+	Time    time.Time `json:"time"`
+	Matches []string  `json:"matches"`
+}
+
+func runForgetPreview(ctx context.Context, opts ForgetOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("--preview-calendar cannot be used together with snapshot IDs")
+	}
+	if opts.Prune {
+		return errors.Fatal("--preview-calendar cannot be used together with --prune")
+	}
+	if opts.PreviewInterval < 0 {
+		return errors.Fatal("--preview-interval must not be negative")
+	}
+
+	policy := opts.expirePolicy()
+	if policy.Empty() {
+		return errors.Fatal("no policy was specified")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var snapshots restic.Snapshots
+	for sn := range FindFilteredSnapshots(ctx, repo, repo, &opts.SnapshotFilter, nil) {
+		snapshots = append(snapshots, sn)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	snapshotGroups, _, err := restic.GroupSnapshots(snapshots, opts.GroupBy)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(snapshotGroups))
+	for k := range snapshotGroups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	now := time.Now()
+	var jsonGroups []*ForgetPreviewGroup
+	for _, k := range keys {
+		var key restic.SnapshotGroupKey
+		if err := json.Unmarshal([]byte(k), &key); err != nil {
+			return err
+		}
+
+		group := previewPolicy(snapshotGroups[k], policy, opts.PreviewInterval, now)
+		group.Tags = key.Tags
+		group.Host = key.Hostname
+		group.Paths = key.Paths
+
+		if gopts.JSON {
+			jsonGroups = append(jsonGroups, group)
+			continue
+		}
+
+		if err := PrintSnapshotGroupHeader(globalOptions.stdout, k); err != nil {
+			return err
+		}
+		Printf("Applying Policy: %v\n", policy)
+		Printf("simulating a snapshot every %v until %v\n\n", group.Interval, now.Add(previewHorizon).Format(time.DateOnly))
+		if err := printPreviewCalendar(group); err != nil {
+			return err
+		}
+		Printf("\n")
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(jsonGroups)
+	}
+	return nil
+}
+
+// previewInterval returns the median time between the snapshots, or one day
+// if it cannot be determined.
+func previewInterval(list restic.Snapshots) time.Duration {
+	var gaps []time.Duration
+	for i := 1; i < len(list); i++ {
+		gap := list[i].Time.Sub(list[i-1].Time)
+		if gap < 0 {
+			gap = -gap
+		}
+		gaps = append(gaps, gap)
+	}
+	if len(gaps) == 0 {
+		return 24 * time.Hour
+	}
+
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	interval := gaps[len(gaps)/2].Round(time.Minute)
+	if interval < time.Minute {
+		return 24 * time.Hour
+	}
+	return interval
+}
+
+// previewPolicy adds hypothetical snapshots every interval until one year
+// after now and applies the policy to the resulting list.
+func previewPolicy(list restic.Snapshots, policy restic.ExpirePolicy, interval time.Duration, now time.Time) *ForgetPreviewGroup {
+	existing := make(restic.Snapshots, len(list))
+	copy(existing, list)
+	sort.Sort(sort.Reverse(existing))
+	if interval == 0 {
+		interval = previewInterval(existing)
+	}
+
+	combined := make(restic.Snapshots, len(existing))
+	copy(combined, existing)
+	future := make(map[*restic.Snapshot]struct{})
+
+	start := now
+	if len(existing) > 0 && existing[len(existing)-1].Time.After(start) {
+		start = existing[len(existing)-1].Time
+	}
+	template := restic.Snapshot{}
+	if len(existing) > 0 {
+		template = *existing[len(existing)-1]
+	}
+	for t := start.Add(interval); !t.After(now.Add(previewHorizon)); t = t.Add(interval) {
+		sn := &restic.Snapshot{
+			Time:     t,
+			Hostname: template.Hostname,
+			Paths:    template.Paths,
+			Tags:     template.Tags,
+		}
+		future[sn] = struct{}{}
+		combined = append(combined, sn)
+	}
+
+	keep, _, reasons := restic.ApplyPolicy(combined, policy)
+	kept := make(map[*restic.Snapshot][]string, len(keep))
+	for _, reason := range reasons {
+		kept[reason.Snapshot] = reason.Matches
+	}
+
+	group := &ForgetPreviewGroup{Interval: interval}
+	months := make(map[string]*ForgetPreviewMonth)
+	var monthOrder []string
+	// ApplyPolicy sorts combined by time, newest first
+	for i := len(combined) - 1; i >= 0; i-- {
+		sn := combined[i]
+		name := sn.Time.Format("2006-01")
+		month, ok := months[name]
+		if !ok {
+			month = &ForgetPreviewMonth{Month: name}
+			months[name] = month
+			monthOrder = append(monthOrder, name)
+		}
+
+		matches, isKept := kept[sn]
+		if _, isFuture := future[sn]; isFuture {
+			month.Future++
+			if isKept {
+				month.FutureKept++
+				group.Future = append(group.Future, ForgetPreviewFuture{Time: sn.Time, Matches: matches})
+			}
+		} else {
+			month.Existing++
+			if isKept {
+				month.ExistingKept++
+				group.Keep = append(group.Keep, asJSONSnapshots(restic.Snapshots{sn})...)
+			}
+		}
+	}
+
+	for _, name := range monthOrder {
+		group.Months = append(group.Months, *months[name])
+	}
+	return group
+}
+
+func printPreviewCalendar(group *ForgetPreviewGroup) error {
+	tab := table.New()
+	tab.AddColumn("Month", "{{ .Month }}")
+	tab.AddColumn("Existing", "{{ .Existing }}")
+	tab.AddColumn("Kept", "{{ .ExistingKept }}")
+	tab.AddColumn("Future", "{{ .Future }}")
+	tab.AddColumn("Kept", "{{ .FutureKept }}")
+
+	var existingKept, futureKept int
+	for _, month := range group.Months {
+		tab.AddRow(month)
+		existingKept += month.ExistingKept
+		futureKept += month.FutureKept
+	}
+	tab.AddFooter(fmt.Sprintf("keeps %d existing and %d future snapshots", existingKept, futureKept))
+	return tab.Write(globalOptions.stdout)
+}
@@ -21,19 +21,25 @@ import (
 	"github.com/restic/restic/internal/backend/local"
 	"github.com/restic/restic/internal/backend/location"
 	"github.com/restic/restic/internal/backend/logger"
+	"github.com/restic/restic/internal/backend/nfs"
 	"github.com/restic/restic/internal/backend/rclone"
+	"github.com/restic/restic/internal/backend/readonly"
 	"github.com/restic/restic/internal/backend/rest"
 	"github.com/restic/restic/internal/backend/retry"
 	"github.com/restic/restic/internal/backend/s3"
 	"github.com/restic/restic/internal/backend/sema"
 	"github.com/restic/restic/internal/backend/sftp"
+	"github.com/restic/restic/internal/backend/smb"
 	"github.com/restic/restic/internal/backend/swift"
+	"github.com/restic/restic/internal/backend/tape"
+	"github.com/restic/restic/internal/backend/webdav"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/options"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/textfile"
+	"github.com/restic/restic/internal/ui/metrics"
 	"github.com/restic/restic/internal/ui/termstatus"
 
 	"github.com/restic/restic/internal/errors"
@@ -61,14 +67,25 @@ type GlobalOptions struct {
 	Verbose            int
 	NoLock             bool
 	RetryLock          time.Duration
+	OpenTimeout        time.Duration
 	JSON               bool
 	CacheDir           string
+	CacheNamespace     string
 	NoCache            bool
 	CleanupCache       bool
 	Compression        repository.CompressionMode
+	CompressionSkip    float64
 	PackSize           uint
 	NoExtraVerify      bool
 	InsecureNoPassword bool
+	BackendSync        string
+	LowPriority        bool
+	ReadOnly           bool
+	CPUAffinity        string
+	ClusterResource    string
+	MetricsListen      string
+	LimitFile          string
+	LimitSocket        string
 
 	backend.TransportOptions
 	limiter.Limits
@@ -90,6 +107,10 @@ type GlobalOptions struct {
 	Options []string
 
 	extended options.Options
+
+	// compressionLevel is set by the backup command to override the level
+	// selected by Compression.
+	compressionLevel repository.CompressionLevel
 }
 
 var globalOptions = GlobalOptions{
@@ -103,11 +124,15 @@ func init() {
 	backends.Register(b2.NewFactory())
 	backends.Register(gs.NewFactory())
 	backends.Register(local.NewFactory())
+	backends.Register(nfs.NewFactory())
 	backends.Register(rclone.NewFactory())
 	backends.Register(rest.NewFactory())
 	backends.Register(s3.NewFactory())
 	backends.Register(sftp.NewFactory())
+	backends.Register(smb.NewFactory())
 	backends.Register(swift.NewFactory())
+	backends.Register(tape.NewFactory(backends))
+	backends.Register(webdav.NewFactory())
 	globalOptions.backends = backends
 
 	f := cmdRoot.PersistentFlags()
@@ -120,9 +145,12 @@ func init() {
 	// use empty parameter name as `-v, --verbose n` instead of the correct `--verbose=n` is confusing
 	f.CountVarP(&globalOptions.Verbose, "verbose", "v", "be verbose (specify multiple times or a level using --verbose=n``, max level/times is 2)")
 	f.BoolVar(&globalOptions.NoLock, "no-lock", false, "do not lock the repository, this allows some operations on read-only repositories")
+	f.BoolVar(&globalOptions.ReadOnly, "read-only", false, "reject all modifications of the repository, including the creation of lock files")
 	f.DurationVar(&globalOptions.RetryLock, "retry-lock", 0, "retry to lock the repository if it is already locked, takes a value like 5m or 2h (default: no retries)")
+	f.DurationVar(&globalOptions.OpenTimeout, "open-timeout", 0, "fail if the repository cannot be opened within `duration`, takes a value like 30s or 2m (default: $RESTIC_OPEN_TIMEOUT or no timeout)")
 	f.BoolVarP(&globalOptions.JSON, "json", "", false, "set output mode to JSON for commands that support it")
 	f.StringVar(&globalOptions.CacheDir, "cache-dir", "", "set the cache `directory`. (default: use system default cache directory)")
+	f.StringVar(&globalOptions.CacheNamespace, "cache-namespace", "", "keep the index and snapshot cache separate from concurrent jobs using a different `name`, pack files are still shared (default: $RESTIC_CACHE_NAMESPACE)")
 	f.BoolVar(&globalOptions.NoCache, "no-cache", false, "do not use a local cache")
 	f.StringSliceVar(&globalOptions.RootCertFilenames, "cacert", nil, "`file` to load root certificates from (default: use system certificates or $RESTIC_CACERT)")
 	f.StringVar(&globalOptions.TLSClientCertKeyFilename, "tls-client-cert", "", "path to a `file` containing PEM encoded TLS client certificate and private key (default: $RESTIC_TLS_CLIENT_CERT)")
@@ -130,12 +158,20 @@ func init() {
 	f.BoolVar(&globalOptions.InsecureTLS, "insecure-tls", false, "skip TLS certificate verification when connecting to the repository (insecure)")
 	f.BoolVar(&globalOptions.CleanupCache, "cleanup-cache", false, "auto remove old cache directories")
 	f.Var(&globalOptions.Compression, "compression", "compression mode (only available for repository format version 2), one of (auto|off|max) (default: $RESTIC_COMPRESSION)")
+	f.Float64Var(&globalOptions.CompressionSkip, "compression-skip-entropy", 0, "store data with an estimated entropy of at least `bits` per byte (0-8, e.g. 7.5) uncompressed, 0 disables the check (default: $RESTIC_COMPRESSION_SKIP_ENTROPY)")
 	f.BoolVar(&globalOptions.NoExtraVerify, "no-extra-verify", false, "skip additional verification of data before upload (see documentation)")
 	f.IntVar(&globalOptions.Limits.UploadKb, "limit-upload", 0, "limits uploads to a maximum `rate` in KiB/s. (default: unlimited)")
 	f.IntVar(&globalOptions.Limits.DownloadKb, "limit-download", 0, "limits downloads to a maximum `rate` in KiB/s. (default: unlimited)")
+	f.StringVar(&globalOptions.LimitFile, "limit-file", "", "read upload and download limits from `file`, which is reloaded on SIGHUP (default: $RESTIC_LIMIT_FILE)")
+	f.StringVar(&globalOptions.LimitSocket, "limit-socket", "", "change upload and download limits at runtime using the unix socket at `path` (default: $RESTIC_LIMIT_SOCKET)")
 	f.UintVar(&globalOptions.PackSize, "pack-size", 0, "set target pack `size` in MiB, created pack files may be larger (default: $RESTIC_PACK_SIZE)")
 	f.StringSliceVarP(&globalOptions.Options, "option", "o", []string{}, "set extended option (`key=value`, can be specified multiple times)")
 	f.StringVar(&globalOptions.HTTPUserAgent, "http-user-agent", "", "set a http user agent for outgoing http requests")
+	f.StringVar(&globalOptions.BackendSync, "backend-sync", "", "fsync `policy` for the local backend, one of (always|dir-only|never), weaker policies risk data loss on power failure (default: $RESTIC_BACKEND_SYNC or always)")
+	f.BoolVar(&globalOptions.LowPriority, "low-priority", false, "run with lowered CPU and IO priority (nice on Unix, background mode on Windows)")
+	f.StringVar(&globalOptions.CPUAffinity, "cpu-affinity", "", "restrict restic to the `cpus` in the list, for example 0-3,6 (Linux and Windows only)")
+	f.StringVar(&globalOptions.MetricsListen, "metrics-listen", "", "serve Prometheus metrics of the running operation at `address`, for example :9090 (default: $RESTIC_METRICS_LISTEN)")
+	f.StringVar(&globalOptions.ClusterResource, "cluster-resource", "", "mark locks as owned by the failover cluster resource `name` and take over its locks held by failed cluster nodes (Windows only) (default: $RESTIC_CLUSTER_RESOURCE)")
 	// Use our "generate" command instead of the cobra provided "completion" command
 	cmdRoot.CompletionOptions.DisableDefaultCmd = true
 
@@ -153,13 +189,47 @@ func init() {
 		// ignore error as there's no good way to handle it
 		_ = globalOptions.Compression.Set(comp)
 	}
+	// parse the entropy threshold from env, on error compression is never skipped
+	compressionSkip, _ := strconv.ParseFloat(os.Getenv("RESTIC_COMPRESSION_SKIP_ENTROPY"), 64)
+	globalOptions.CompressionSkip = compressionSkip
+
 	// parse target pack size from env, on error the default value will be used
 	targetPackSize, _ := strconv.ParseUint(os.Getenv("RESTIC_PACK_SIZE"), 10, 32)
 	globalOptions.PackSize = uint(targetPackSize)
 
+	// parse open timeout from env, on error no timeout is used
+	openTimeout, _ := time.ParseDuration(os.Getenv("RESTIC_OPEN_TIMEOUT"))
+	globalOptions.OpenTimeout = openTimeout
+
 	if os.Getenv("RESTIC_HTTP_USER_AGENT") != "" {
 		globalOptions.HTTPUserAgent = os.Getenv("RESTIC_HTTP_USER_AGENT")
 	}
+
+	globalOptions.BackendSync = os.Getenv("RESTIC_BACKEND_SYNC")
+	globalOptions.CacheNamespace = os.Getenv("RESTIC_CACHE_NAMESPACE")
+	globalOptions.ClusterResource = os.Getenv("RESTIC_CLUSTER_RESOURCE")
+	globalOptions.MetricsListen = os.Getenv("RESTIC_METRICS_LISTEN")
+	globalOptions.LimitFile = os.Getenv("RESTIC_LIMIT_FILE")
+	globalOptions.LimitSocket = os.Getenv("RESTIC_LIMIT_SOCKET")
+}
+
+// applyBackendSync passes the fsync policy to the backends which support it,
+// unless an extended option overrides it.
+func applyBackendSync(gopts GlobalOptions, opts options.Options) error {
+	if gopts.BackendSync == "" {
+		return nil
+	}
+	if err := local.ValidateSyncMode(gopts.BackendSync); err != nil {
+		return errors.Fatalf("--backend-sync: %v", err)
+	}
+
+	if _, ok := opts["local.sync"]; !ok {
+		opts["local.sync"] = gopts.BackendSync
+	}
+	if gopts.BackendSync != local.SyncAlways {
+		Warnf("warning: --backend-sync=%v, a crash or power failure can corrupt the repository\n", gopts.BackendSync)
+	}
+	return nil
 }
 
 func stdinIsTerminal() bool {
@@ -423,6 +493,22 @@ func ReadRepo(opts GlobalOptions) (string, error) {
 
 const maxKeys = 20
 
+// newRetryBackend wraps be such that failed operations are retried for up to
+// 15 minutes.
+func newRetryBackend(be backend.Backend) backend.Backend {
+	report := func(msg string, err error, d time.Duration) {
+		if d >= 0 {
+			Warnf("%v returned error, retrying after %v: %v\n", msg, d, err)
+		} else {
+			Warnf("%v failed: %v\n", msg, err)
+		}
+	}
+	success := func(msg string, retries int) {
+		Warnf("%v operation successful after %d retries\n", msg, retries)
+	}
+	return retry.New(be, 15*time.Minute, report, success)
+}
+
 // OpenRepository reads the password and opens the repository.
 func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Repository, error) {
 	repo, err := ReadRepo(opts)
@@ -430,22 +516,24 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 		return nil, err
 	}
 
-	be, err := open(ctx, repo, opts, opts.extended)
+	var be backend.Backend
+	err = withOpenTimeout(ctx, opts.OpenTimeout, func() error {
+		var err error
+		be, err = open(ctx, repo, opts, opts.extended)
+		return err
+	})
+	if errors.Is(err, errOpenTimeout) {
+		return nil, openTimeoutError(opts, repo, "connecting to the repository")
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	report := func(msg string, err error, d time.Duration) {
-		if d >= 0 {
-			Warnf("%v returned error, retrying after %v: %v\n", msg, d, err)
-		} else {
-			Warnf("%v failed: %v\n", msg, err)
-		}
-	}
-	success := func(msg string, retries int) {
-		Warnf("%v operation successful after %d retries\n", msg, retries)
+	if opts.ReadOnly {
+		be = readonly.New(be)
 	}
-	be = retry.New(be, 15*time.Minute, report, success)
+
+	be = newRetryBackend(be)
 
 	// wrap backend if a test specified a hook
 	if opts.backendTestHook != nil {
@@ -456,9 +544,11 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 	}
 
 	s, err := repository.New(be, repository.Options{
-		Compression:   opts.Compression,
-		PackSize:      opts.PackSize * 1024 * 1024,
-		NoExtraVerify: opts.NoExtraVerify,
+		Compression:            opts.Compression,
+		PackSize:               opts.PackSize * 1024 * 1024,
+		NoExtraVerify:          opts.NoExtraVerify,
+		CompressionSkipEntropy: opts.CompressionSkip,
+		CompressionLevel:       opts.compressionLevel,
 	})
 	if err != nil {
 		return nil, errors.Fatal(err.Error())
@@ -482,7 +572,12 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 			continue
 		}
 
-		err = s.SearchKey(ctx, opts.password, maxKeys, opts.KeyHint)
+		err = withOpenTimeout(ctx, opts.OpenTimeout, func() error {
+			return s.SearchKey(ctx, opts.password, maxKeys, opts.KeyHint)
+		})
+		if errors.Is(err, errOpenTimeout) {
+			return nil, openTimeoutError(opts, repo, "loading the repository keys")
+		}
 		if err != nil && passwordTriesLeft > 1 {
 			opts.password = ""
 			fmt.Fprintf(os.Stderr, "%s. Try again\n", err)
@@ -504,6 +599,9 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 			extra := ""
 			if s.Config().Version >= 2 {
 				extra = ", compression level " + opts.Compression.String()
+				if opts.compressionLevel != repository.CompressionLevelUnset {
+					extra += "/" + opts.compressionLevel.String()
+				}
 			}
 			Verbosef("repository %v opened (version %v%s)\n", id, s.Config().Version, extra)
 		}
@@ -513,7 +611,7 @@ func OpenRepository(ctx context.Context, opts GlobalOptions) (*repository.Reposi
 		return s, nil
 	}
 
-	c, err := cache.New(s.Config().ID, opts.CacheDir)
+	c, err := cache.NewNamespace(s.Config().ID, opts.CacheDir, opts.CacheNamespace)
 	if err != nil {
 		Warnf("unable to open cache: %v\n", err)
 		return s, nil
@@ -564,8 +662,11 @@ func parseConfig(loc location.Location, opts options.Options) (interface{}, erro
 		cfg.ApplyEnvironment("")
 	}
 
-	// only apply options for a particular backend here
+	// only apply options for a particular backend here, the bandwidth
+	// limits are handled separately by backendLimits
 	opts = opts.Extract(loc.Scheme)
+	delete(opts, "limit-upload")
+	delete(opts, "limit-download")
 	if err := opts.Apply(loc.Scheme, cfg); err != nil {
 		return nil, err
 	}
@@ -574,7 +675,7 @@ func parseConfig(loc location.Location, opts options.Options) (interface{}, erro
 	return cfg, nil
 }
 
-func innerOpen(ctx context.Context, s string, gopts GlobalOptions, opts options.Options, create bool) (backend.Backend, error) {
+func innerOpen(ctx context.Context, s string, gopts GlobalOptions, opts options.Options, create bool, clock *backend.ClockRecorder) (backend.Backend, error) {
 	debug.Log("parsing location %v", location.StripPassword(gopts.backends, s))
 	loc, err := location.Parse(gopts.backends, s)
 	if err != nil {
@@ -586,13 +687,38 @@ func innerOpen(ctx context.Context, s string, gopts GlobalOptions, opts options.
 		return nil, err
 	}
 
+	// the tape backend stores its containers in another backend
+	if cfg, ok := cfg.(*tape.Config); ok {
+		be, err := innerOpen(ctx, cfg.Location, gopts, opts, create, clock)
+		if err != nil {
+			return nil, err
+		}
+		tbe, err := tape.New(*cfg, be)
+		if err != nil {
+			_ = be.Close()
+			return nil, err
+		}
+		return tbe, nil
+	}
+
 	rt, err := backend.Transport(globalOptions.TransportOptions)
 	if err != nil {
 		return nil, errors.Fatal(err.Error())
 	}
+	if clock != nil {
+		rt = clock.Transport(rt)
+	}
+
+	limits, err := backendLimits(gopts.Limits, loc.Scheme, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	// wrap the transport so that the throughput via HTTP is limited
-	lim := limiter.NewStaticLimiter(gopts.Limits)
+	lim := limiter.NewDynamicLimiter(limits)
+	if gopts.LimitFile != "" || gopts.LimitSocket != "" {
+		limitControl.Register(lim)
+	}
 	rt = lim.Transport(rt)
 
 	factory := gopts.backends.Lookup(loc.Scheme)
@@ -611,6 +737,11 @@ func innerOpen(ctx context.Context, s string, gopts GlobalOptions, opts options.
 		return nil, errors.Fatalf("unable to open repository at %v: %v", location.StripPassword(gopts.backends, s), err)
 	}
 
+	// record the latency of the individual requests
+	if gopts.MetricsListen != "" {
+		be = metrics.NewBackend(be)
+	}
+
 	// wrap with debug logging and connection limiting
 	be = logger.New(sema.NewBackend(be))
 
@@ -627,8 +758,8 @@ func innerOpen(ctx context.Context, s string, gopts GlobalOptions, opts options.
 
 // Open the backend specified by a location config.
 func open(ctx context.Context, s string, gopts GlobalOptions, opts options.Options) (backend.Backend, error) {
-
-	be, err := innerOpen(ctx, s, gopts, opts, false)
+	clock := backend.NewClockRecorder()
+	be, err := innerOpen(ctx, s, gopts, opts, false, clock)
 	if err != nil {
 		return nil, err
 	}
@@ -643,10 +774,18 @@ func open(ctx context.Context, s string, gopts GlobalOptions, opts options.Optio
 		return nil, errors.New("config file has zero size, invalid repository?")
 	}
 
+	err = checkBackendHealth(ctx, be, clock, gopts, s)
+	if err != nil {
+		return nil, err
+	}
+
 	return be, nil
 }
 
 // Create the backend specified by URI.
 func create(ctx context.Context, s string, gopts GlobalOptions, opts options.Options) (backend.Backend, error) {
-	return innerOpen(ctx, s, gopts, opts, true)
+	if gopts.ReadOnly {
+		return nil, errReadOnlyRepository
+	}
+	return innerOpen(ctx, s, gopts, opts, true, nil)
 }
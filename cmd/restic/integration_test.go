@@ -38,6 +38,26 @@ func TestCheckRestoreNoLock(t *testing.T) {
 	testRunRestore(t, env.gopts, filepath.Join(env.base, "restore"), snapshotIDs[0])
 }
 
+func TestReadOnlyRepository(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	env.gopts.ReadOnly = true
+	testRunCheck(t, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+	testRunRestore(t, env.gopts, filepath.Join(env.base, "restore"), snapshotIDs[0])
+
+	err := testRunBackupAssumeFailure(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	rtest.Assert(t, err != nil, "backup in read-only mode did not fail")
+
+	env.gopts.ReadOnly = false
+	testListSnapshots(t, env.gopts, 1)
+	rtest.Equals(t, 0, len(testRunList(t, "locks", env.gopts)))
+}
+
 // a listOnceBackend only allows listing once per filetype
 // listing filetypes more than once may cause problems with eventually consistent
 // backends (like e.g. Amazon S3) as the second listing may be inconsistent to what
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// limitControl changes the bandwidth limits of all backends at runtime, if
+// --limit-file or --limit-socket is used.
+var limitControl = limiter.NewController()
+
+// stopLimitControl stops listening on the socket opened by startLimitControl.
+var stopLimitControl = func() {}
+
+// backendLimits returns the bandwidth limits for a backend. The limits set
+// via --limit-upload and --limit-download can be overridden for each backend
+// using the extended options <scheme>.limit-upload and <scheme>.limit-download.
+func backendLimits(limits limiter.Limits, scheme string, opts options.Options) (limiter.Limits, error) {
+	for key, rate := range map[string]*int{
+		"limit-upload":   &limits.UploadKb,
+		"limit-download": &limits.DownloadKb,
+	} {
+		value, ok := opts[scheme+"."+key]
+		if !ok {
+			continue
+		}
+		kb, err := strconv.Atoi(value)
+		if err != nil || kb < 0 {
+			return limits, errors.Fatalf("invalid rate %q for option %v.%v", value, scheme, key)
+		}
+		*rate = kb
+	}
+	return limits, nil
+}
+
+// loadLimitFile applies the limits listed in the file.
+func loadLimitFile(filename string) error {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return limitControl.Apply(string(buf))
+}
+
+// startLimitControl applies the limits of --limit-file, which is reloaded on
+// SIGHUP, and accepts changes of the limits on --limit-socket.
+func startLimitControl(gopts GlobalOptions) error {
+	if gopts.LimitFile != "" {
+		if err := loadLimitFile(gopts.LimitFile); err != nil {
+			return errors.Fatalf("--limit-file: %v", err)
+		}
+		reloadLimitFileOnSIGHUP(gopts.LimitFile)
+	}
+
+	if gopts.LimitSocket != "" {
+		// remove the socket left behind by a previous run which was killed
+		if fi, err := os.Lstat(gopts.LimitSocket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(gopts.LimitSocket)
+		}
+		l, err := net.Listen("unix", gopts.LimitSocket)
+		if err != nil {
+			return errors.Fatalf("--limit-socket: %v", err)
+		}
+		debug.Log("accepting limit changes at %v", gopts.LimitSocket)
+		go limitControl.Serve(l)
+		stopLimitControl = func() {
+			// closing the listener also removes the socket
+			_ = l.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/options"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestBackendLimits(t *testing.T) {
+	global := limiter.Limits{UploadKb: 100, DownloadKb: 200}
+	opts := options.Options{
+		"s3.limit-upload":    "50",
+		"local.limit-upload": "10",
+		"s3.connections":     "5",
+	}
+
+	limits, err := backendLimits(global, "s3", opts)
+	rtest.OK(t, err)
+	rtest.Equals(t, limiter.Limits{UploadKb: 50, DownloadKb: 200}, limits)
+
+	limits, err = backendLimits(global, "sftp", opts)
+	rtest.OK(t, err)
+	rtest.Equals(t, global, limits)
+
+	_, err = backendLimits(global, "s3", options.Options{"s3.limit-download": "fast"})
+	rtest.Assert(t, err != nil, "expected error for invalid rate")
+}
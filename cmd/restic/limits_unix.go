@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/restic/restic/internal/debug"
+)
+
+// reloadLimitFileOnSIGHUP applies the limits of the file again whenever
+// SIGHUP is received.
+func reloadLimitFileOnSIGHUP(filename string) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			if err := loadLimitFile(filename); err != nil {
+				Warnf("reloading --limit-file failed: %v\n", err)
+				continue
+			}
+			debug.Log("reloaded limits from %v", filename)
+		}
+	}()
+}
@@ -0,0 +1,5 @@
+package main
+
+// reloadLimitFileOnSIGHUP does nothing, as Windows has no SIGHUP. Use
+// --limit-socket to change the limits at runtime instead.
+func reloadLimitFileOnSIGHUP(_ string) {}
@@ -3,9 +3,13 @@ package main
 import (
 	"context"
 
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
 )
 
+var errReadOnlyRepository = errors.Fatal("this command modifies the repository and cannot be used with --read-only")
+
 func internalOpenWithLocked(ctx context.Context, gopts GlobalOptions, dryRun bool, exclusive bool) (context.Context, *repository.Repository, func(), error) {
 	repo, err := OpenRepository(ctx, gopts)
 	if err != nil {
@@ -15,8 +19,15 @@ func internalOpenWithLocked(ctx context.Context, gopts GlobalOptions, dryRun boo
 	unlock := func() {}
 	if !dryRun {
 		var lock *repository.Unlocker
+		var owner *restic.LockOwner
+		if gopts.ClusterResource != "" {
+			owner, err = restic.NewClusterLockOwner(gopts.ClusterResource)
+			if err != nil {
+				return nil, nil, nil, errors.Fatalf("--cluster-resource: %v", err)
+			}
+		}
 
-		lock, ctx, err = repository.Lock(ctx, repo, exclusive, gopts.RetryLock, func(msg string) {
+		lock, ctx, err = repository.Lock(ctx, repo, exclusive, owner, gopts.RetryLock, func(msg string) {
 			if !gopts.JSON {
 				Verbosef("%s", msg)
 			}
@@ -34,15 +45,25 @@ func internalOpenWithLocked(ctx context.Context, gopts GlobalOptions, dryRun boo
 }
 
 func openWithReadLock(ctx context.Context, gopts GlobalOptions, noLock bool) (context.Context, *repository.Repository, func(), error) {
+	// creating a lock file is impossible for a read-only repository
+	if gopts.ReadOnly {
+		noLock = true
+	}
 	// TODO enfore read-only operations once the locking code has moved to the repository
 	return internalOpenWithLocked(ctx, gopts, noLock, false)
 }
 
 func openWithAppendLock(ctx context.Context, gopts GlobalOptions, dryRun bool) (context.Context, *repository.Repository, func(), error) {
+	if gopts.ReadOnly && !dryRun {
+		return nil, nil, nil, errReadOnlyRepository
+	}
 	// TODO enfore non-exclusive operations once the locking code has moved to the repository
 	return internalOpenWithLocked(ctx, gopts, dryRun, false)
 }
 
 func openWithExclusiveLock(ctx context.Context, gopts GlobalOptions, dryRun bool) (context.Context, *repository.Repository, func(), error) {
+	if gopts.ReadOnly && !dryRun {
+		return nil, nil, nil, errReadOnlyRepository
+	}
 	return internalOpenWithLocked(ctx, gopts, dryRun, true)
 }
@@ -63,7 +63,20 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 			return err
 		}
 		globalOptions.extended = opts
-		if !needsPassword(c.Name()) {
+		if err := applyBackendSync(globalOptions, opts); err != nil {
+			return err
+		}
+		if err := applyProcessPriority(globalOptions); err != nil {
+			return err
+		}
+		if err := startMetrics(globalOptions); err != nil {
+			return err
+		}
+		if err := startLimitControl(globalOptions); err != nil {
+			return err
+		}
+		// the jobs and most rules sub-commands do not access the repository
+		if !needsPassword(c.Name()) || c.Parent() == cmdJobs || (c.Parent() == cmdRules && !rulesNeedRepository(c)) {
 			return nil
 		}
 		pwd, err := resolvePassword(globalOptions, "RESTIC_PASSWORD")
@@ -78,6 +91,8 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 		return runDebug()
 	},
 	PersistentPostRun: func(_ *cobra.Command, _ []string) {
+		stopMetrics()
+		stopLimitControl()
 		stopDebug()
 	},
 }
@@ -87,7 +102,7 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 // user for authentication).
 func needsPassword(cmd string) bool {
 	switch cmd {
-	case "cache", "generate", "help", "options", "self-update", "version", "__complete":
+	case "cache", "generate", "help", "options", "restore-helper", "self-update", "version", "__complete":
 		return false
 	default:
 		return true
@@ -134,8 +149,10 @@ func main() {
 	switch {
 	case restic.IsAlreadyLocked(err):
 		fmt.Fprintf(os.Stderr, "%v\nthe `unlock` command can be used to remove stale locks\n", err)
-	case err == ErrInvalidSourceData:
+	case err == ErrInvalidSourceData || err == ErrPartialSnapshot:
 		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	case err == ErrErrorBudgetExceeded:
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 	case errors.IsFatal(err):
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 	case err != nil:
@@ -154,8 +171,10 @@ func main() {
 	switch {
 	case err == nil:
 		exitCode = 0
-	case err == ErrInvalidSourceData:
+	case err == ErrInvalidSourceData || err == ErrPartialSnapshot:
 		exitCode = 3
+	case err == ErrErrorBudgetExceeded:
+		exitCode = 4
 	case errors.Is(err, context.Canceled):
 		exitCode = 130
 	default:
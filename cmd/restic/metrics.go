@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/ui/metrics"
+)
+
+// stopMetrics stops the metrics server started by startMetrics.
+var stopMetrics = func() {}
+
+// startMetrics serves the metrics of the running operation if requested by
+// the global options.
+func startMetrics(gopts GlobalOptions) error {
+	if gopts.MetricsListen == "" {
+		return nil
+	}
+
+	stop, err := metrics.Serve(gopts.MetricsListen, metrics.Default)
+	if err != nil {
+		return errors.Fatalf("--metrics-listen: %v", err)
+	}
+	debug.Log("serving metrics at %v", gopts.MetricsListen)
+	stopMetrics = func() {
+		if err := stop(); err != nil {
+			debug.Log("stopping metrics server failed: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"path"
+
+	"github.com/restic/restic/internal/debug"
+)
+
+// networkInfo describes the network connection used for the backup.
+type networkInfo struct {
+	// Interface is the name of the network interface of the default route.
+	Interface string
+	// SSID is the name of the Wi-Fi network, it is empty for wired connections.
+	SSID string
+	// Metered is set if the connection is marked as metered.
+	Metered bool
+}
+
+// networkPolicy restricts the networks over which a backup may run.
+type networkPolicy struct {
+	AllowedSSIDs      []string
+	AllowedInterfaces []string
+	SkipMetered       bool
+}
+
+func (p networkPolicy) enabled() bool {
+	return len(p.AllowedSSIDs) > 0 || len(p.AllowedInterfaces) > 0 || p.SkipMetered
+}
+
+// check returns the reason why the network is not allowed by the policy, or
+// an empty string if it is allowed. Interface names and SSIDs may be patterns.
+func (p networkPolicy) check(info networkInfo) string {
+	matchAny := func(patterns []string, name string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(p.AllowedInterfaces) > 0 && !matchAny(p.AllowedInterfaces, info.Interface) {
+		if info.Interface == "" {
+			return "the network interface could not be determined"
+		}
+		return fmt.Sprintf("network interface %q is not allowed", info.Interface)
+	}
+	if len(p.AllowedSSIDs) > 0 && info.SSID != "" && !matchAny(p.AllowedSSIDs, info.SSID) {
+		return fmt.Sprintf("Wi-Fi network %q is not allowed", info.SSID)
+	}
+	if p.SkipMetered && info.Metered {
+		return "the network connection is metered"
+	}
+	return ""
+}
+
+// readNetworkInfo determines the interface of the default route and its
+// properties. Properties which cannot be determined are left empty.
+func readNetworkInfo() networkInfo {
+	var info networkInfo
+	info.Interface = defaultRouteInterface()
+	if info.Interface == "" {
+		return info
+	}
+
+	ssid, err := readSSID(info.Interface)
+	if err != nil {
+		debug.Log("unable to read SSID of %v: %v", info.Interface, err)
+	}
+	info.SSID = ssid
+
+	metered, err := isMeteredConnection(info.Interface)
+	if err != nil {
+		debug.Log("unable to check whether %v is metered: %v", info.Interface, err)
+	}
+	info.Metered = metered
+	return info
+}
+
+// defaultRouteInterface returns the name of the network interface which is
+// used to reach the internet. No packets are sent to determine it.
+func defaultRouteInterface() string {
+	// 192.0.2.1 is reserved for documentation and routed via the default route
+	conn, err := net.Dial("udp", "192.0.2.1:9")
+	if err != nil {
+		debug.Log("unable to determine default route: %v", err)
+		return ""
+	}
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	_ = conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		debug.Log("unable to list network interfaces: %v", err)
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(localIP) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// readSSID returns the SSID of the Wi-Fi network the interface is connected
+// to using iwgetid, or using NetworkManager if iwgetid is not available. An
+// empty SSID is returned for wired interfaces.
+func readSSID(iface string) (string, error) {
+	out, err := exec.Command("iwgetid", iface, "--raw").Output()
+	if err == nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	out, err = exec.Command("nmcli", "--terse", "--fields", "ACTIVE,SSID,DEVICE", "device", "wifi", "list", "ifname", iface).Output()
+	if err != nil {
+		return "", errors.New("neither iwgetid nor nmcli are available")
+	}
+	return parseNmcliSSID(string(out)), nil
+}
+
+// parseNmcliSSID returns the SSID of the active network from the terse output
+// of "nmcli device wifi list". Colons within the SSID are escaped with a
+// backslash.
+func parseNmcliSSID(out string) string {
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		active, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok || active != "yes" {
+			continue
+		}
+
+		var ssid strings.Builder
+		for i := 0; i < len(rest); i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+			} else if rest[i] == ':' {
+				break
+			}
+			ssid.WriteByte(rest[i])
+		}
+		return ssid.String()
+	}
+	return ""
+}
+
+// isMeteredConnection asks NetworkManager whether the connection of the
+// interface is metered.
+func isMeteredConnection(iface string) (bool, error) {
+	out, err := exec.Command("nmcli", "--terse", "--get-values", "GENERAL.METERED", "device", "show", iface).Output()
+	if err != nil {
+		return false, err
+	}
+	// the value is "yes", "no" or either of them with " (guessed)"
+	return strings.HasPrefix(strings.TrimSpace(string(out)), "yes"), nil
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseNmcliSSID(t *testing.T) {
+	rtest.Equals(t, "office", parseNmcliSSID("no:guest:wlan0\nyes:office:wlan0\n"))
+	rtest.Equals(t, "a:b", parseNmcliSSID(`yes:a\:b:wlan0`))
+	rtest.Equals(t, "", parseNmcliSSID("no:guest:wlan0\n"))
+}
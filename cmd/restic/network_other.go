@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package main
+
+import "github.com/restic/restic/internal/errors"
+
+func readSSID(_ string) (string, error) {
+	return "", errors.New("not supported on this platform")
+}
+
+func isMeteredConnection(_ string) (bool, error) {
+	return false, errors.New("not supported on this platform")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestNetworkPolicyCheck(t *testing.T) {
+	wifi := networkInfo{Interface: "wlan0", SSID: "office"}
+	hotspot := networkInfo{Interface: "wlan0", SSID: "phone", Metered: true}
+	wired := networkInfo{Interface: "eth0"}
+	vpn := networkInfo{Interface: "wg0"}
+
+	for _, test := range []struct {
+		policy  networkPolicy
+		info    networkInfo
+		allowed bool
+	}{
+		{networkPolicy{AllowedSSIDs: []string{"office", "home"}}, wifi, true},
+		{networkPolicy{AllowedSSIDs: []string{"office", "home"}}, hotspot, false},
+		{networkPolicy{AllowedSSIDs: []string{"office", "home"}}, wired, true},
+		{networkPolicy{AllowedInterfaces: []string{"eth*", "wlan0"}}, wired, true},
+		{networkPolicy{AllowedInterfaces: []string{"eth*", "wlan0"}}, wifi, true},
+		{networkPolicy{AllowedInterfaces: []string{"eth*", "wlan0"}}, vpn, false},
+		{networkPolicy{AllowedInterfaces: []string{"eth*"}}, networkInfo{}, false},
+		{networkPolicy{SkipMetered: true}, wifi, true},
+		{networkPolicy{SkipMetered: true}, hotspot, false},
+	} {
+		reason := test.policy.check(test.info)
+		rtest.Assert(t, (reason == "") == test.allowed, "unexpected result %q for policy %+v and network %+v", reason, test.policy, test.info)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+)
+
+// readSSID returns the SSID of the Wi-Fi network the interface is connected
+// to. An empty SSID is returned for wired interfaces.
+func readSSID(iface string) (string, error) {
+	out, err := exec.Command("netsh", "wlan", "show", "interfaces").Output()
+	if err != nil {
+		// netsh fails if the WLAN service is not running, i.e. there is no Wi-Fi
+		return "", nil
+	}
+	return parseNetshSSID(string(out), iface), nil
+}
+
+// parseNetshSSID returns the SSID of the interface from the output of "netsh
+// wlan show interfaces".
+func parseNetshSSID(out string, iface string) string {
+	current := ""
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch key {
+		case "Name":
+			current = value
+		case "SSID":
+			if strings.EqualFold(current, iface) {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// meteredConnectionScript prints the cost type of the internet connection
+// profile, which is only available using the Windows Runtime API.
+const meteredConnectionScript = `[void][Windows.Networking.Connectivity.NetworkInformation, Windows.Networking.Connectivity, ContentType=WindowsRuntime]
+$connection = [Windows.Networking.Connectivity.NetworkInformation]::GetInternetConnectionProfile()
+if ($connection) { $connection.GetConnectionCost().NetworkCostType }`
+
+// isMeteredConnection returns whether Windows considers the internet
+// connection as metered. Windows only provides this information for the
+// connection used to access the internet, iface is ignored.
+func isMeteredConnection(_ string) (bool, error) {
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", meteredConnectionScript).Output()
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.TrimSpace(string(out)) {
+	case "Fixed", "Variable":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseNetshSSID(t *testing.T) {
+	out := `
+There are 2 interfaces on the system:
+
+    Name                   : Wi-Fi
+    Description            : Intel(R) Wi-Fi 6 AX201 160MHz
+    State                  : connected
+    SSID                   : office
+    BSSID                  : 00:11:22:33:44:55
+
+    Name                   : Wi-Fi 2
+    State                  : disconnected
+`
+	rtest.Equals(t, "office", parseNetshSSID(out, "Wi-Fi"))
+	rtest.Equals(t, "", parseNetshSSID(out, "Wi-Fi 2"))
+	rtest.Equals(t, "", parseNetshSSID(out, "Ethernet"))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// maxClockSkew is the difference between the local clock and the clock of
+// the storage server above which a warning is printed. Many storage services
+// reject requests if the difference exceeds 15 minutes.
+const maxClockSkew = 5 * time.Minute
+
+// errOpenTimeout is returned by withOpenTimeout if the timeout has expired.
+var errOpenTimeout = errors.New("open timeout expired")
+
+// withOpenTimeout runs fn and stops waiting for it once timeout has expired.
+// fn is not cancelled, as the context passed to some backends when opening
+// them must remain valid for their whole lifetime. A timeout of zero waits
+// until fn returns.
+func withOpenTimeout(ctx context.Context, timeout time.Duration, fn func() error) error {
+	if timeout <= 0 {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return errOpenTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// openTimeoutError returns an error explaining that the step did not finish
+// within the open timeout.
+func openTimeoutError(gopts GlobalOptions, repo string, step string) error {
+	return errors.Fatalf("%v did not finish within %v (--open-timeout)\nCheck that the repository at %v is reachable and responding",
+		step, gopts.OpenTimeout, location.StripPassword(gopts.backends, repo))
+}
+
+// checkBackendHealth checks that the config file of the repository can be read
+// and warns if the local clock differs from the clock of the storage server.
+func checkBackendHealth(ctx context.Context, be backend.Backend, clock *backend.ClockRecorder, gopts GlobalOptions, repo string) error {
+	err := be.Load(ctx, backend.Handle{Type: restic.ConfigFile}, 0, 0, func(rd io.Reader) error {
+		_, err := io.Copy(io.Discard, rd)
+		return err
+	})
+	if err != nil {
+		return errors.Fatalf("unable to read config file: %v\nCheck that the credentials for the repository at %v allow reading files",
+			err, location.StripPassword(gopts.backends, repo))
+	}
+
+	if skew, ok := clock.Skew(); ok && (skew > maxClockSkew || skew < -maxClockSkew) {
+		Warnf("the local clock differs from the clock of the storage server by %v\n"+
+			"This can cause authentication errors and locks which are wrongly considered stale, synchronize the system clock\n",
+			skew.Round(time.Second))
+	}
+	return nil
+}
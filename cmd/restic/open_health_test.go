@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestWithOpenTimeout(t *testing.T) {
+	ctx := context.Background()
+	testErr := errors.New("test error")
+
+	err := withOpenTimeout(ctx, 0, func() error { return testErr })
+	rtest.Equals(t, testErr, err)
+
+	err = withOpenTimeout(ctx, time.Minute, func() error { return nil })
+	rtest.OK(t, err)
+
+	block := make(chan struct{})
+	defer close(block)
+	err = withOpenTimeout(ctx, 10*time.Millisecond, func() error {
+		<-block
+		return nil
+	})
+	rtest.Equals(t, errOpenTimeout, err)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/ui"
+	"golang.org/x/time/rate"
+)
+
+// powerPollInterval is the interval in which the power state is checked while
+// a backup is running.
+const powerPollInterval = 30 * time.Second
+
+// powerState describes the power source and mode of the system.
+type powerState struct {
+	OnBattery  bool
+	PowerSaver bool
+}
+
+// lowPower returns whether the system runs on battery or in power saver mode.
+func (s powerState) lowPower() bool {
+	return s.OnBattery || s.PowerSaver
+}
+
+// powerMonitor pauses or slows down reading files depending on the power
+// state of the system, as requested by --pause-on-battery and
+// --low-power-throttle.
+type powerMonitor struct {
+	ctx     context.Context
+	pause   bool
+	limiter *rate.Limiter
+	read    func() (powerState, error)
+	notify  func(msg string)
+
+	m       sync.Mutex
+	state   powerState
+	changed chan struct{}
+}
+
+// newPowerMonitor reads the current power state using read. Reading files is
+// paused while the system runs on battery if pause is set, and limited to
+// throttleKiB KiB/s in low power mode if throttleKiB is larger than zero.
+// Changes of the power state which affect the backup are reported to notify.
+func newPowerMonitor(ctx context.Context, pause bool, throttleKiB int, read func() (powerState, error), notify func(msg string)) (*powerMonitor, error) {
+	state, err := read()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &powerMonitor{
+		ctx:     ctx,
+		pause:   pause,
+		read:    read,
+		notify:  notify,
+		state:   state,
+		changed: make(chan struct{}),
+	}
+	if throttleKiB > 0 {
+		bytes := throttleKiB * 1024
+		m.limiter = rate.NewLimiter(rate.Limit(bytes), bytes)
+	}
+	return m, nil
+}
+
+// Run checks the power state every interval until the context is cancelled.
+func (m *powerMonitor) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.update()
+		}
+	}
+}
+
+func (m *powerMonitor) update() {
+	state, err := m.read()
+	if err != nil {
+		debug.Log("unable to read power state: %v", err)
+		return
+	}
+
+	m.m.Lock()
+	old := m.state
+	if state != old {
+		m.state = state
+		close(m.changed)
+		m.changed = make(chan struct{})
+	}
+	m.m.Unlock()
+
+	if m.pause && state.OnBattery != old.OnBattery {
+		if state.OnBattery {
+			m.notify("running on battery power, pausing backup until AC power is connected")
+		} else {
+			m.notify("AC power connected, resuming backup")
+		}
+	}
+	if m.limiter != nil && state.lowPower() != old.lowPower() {
+		if state.lowPower() {
+			m.notify("low power mode, limiting reading files to " + ui.FormatBytes(uint64(m.limiter.Burst())) + "/s")
+		} else {
+			m.notify("left low power mode, no longer limiting reading files")
+		}
+	}
+}
+
+func (m *powerMonitor) current() (powerState, <-chan struct{}) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return m.state, m.changed
+}
+
+// WaitForAC blocks while the system runs on battery if pausing was requested.
+func (m *powerMonitor) WaitForAC() error {
+	for {
+		state, changed := m.current()
+		if !m.pause || !state.OnBattery {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-m.ctx.Done():
+			return m.ctx.Err()
+		}
+	}
+}
+
+// Wait is called before n bytes are read from a file. It blocks while the
+// backup is paused and limits the rate in low power mode.
+func (m *powerMonitor) Wait(n int) error {
+	if err := m.WaitForAC(); err != nil {
+		return err
+	}
+
+	state, _ := m.current()
+	if m.limiter == nil || !state.lowPower() {
+		return nil
+	}
+	for n > 0 {
+		tokens := n
+		if tokens > m.limiter.Burst() {
+			tokens = m.limiter.Burst()
+		}
+		if err := m.limiter.WaitN(m.ctx, tokens); err != nil {
+			return err
+		}
+		n -= tokens
+	}
+	return nil
+}
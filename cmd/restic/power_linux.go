@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// powerSupplyPath is the sysfs directory which lists the power supplies.
+const powerSupplyPath = "/sys/class/power_supply"
+
+// readPowerState asks UPower whether the system runs on battery and falls back
+// to the power supply information in sysfs if UPower is not available. The
+// power saver mode is read from power-profiles-daemon.
+func readPowerState() (powerState, error) {
+	var state powerState
+
+	out, err := exec.Command("upower", "--dump").Output()
+	if err == nil {
+		state.OnBattery, err = parseUpowerOnBattery(string(out))
+	}
+	if err != nil {
+		state.OnBattery, err = sysfsOnBattery(powerSupplyPath)
+		if err != nil {
+			return powerState{}, err
+		}
+	}
+
+	out, err = exec.Command("powerprofilesctl", "get").Output()
+	if err == nil {
+		state.PowerSaver = strings.TrimSpace(string(out)) == "power-saver"
+	}
+	return state, nil
+}
+
+// parseUpowerOnBattery extracts the on-battery property of the UPower daemon
+// from the output of "upower --dump".
+func parseUpowerOnBattery(out string) (bool, error) {
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if ok && key == "on-battery" {
+			return strings.TrimSpace(value) == "yes", nil
+		}
+	}
+	return false, errors.New("on-battery property not found in upower output")
+}
+
+// sysfsOnBattery returns whether a battery is discharging while no mains power
+// supply is online.
+func sysfsOnBattery(root string) (bool, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return false, err
+	}
+
+	read := func(supply, name string) string {
+		buf, err := os.ReadFile(filepath.Join(root, supply, name))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(buf))
+	}
+
+	discharging := false
+	for _, entry := range entries {
+		switch read(entry.Name(), "type") {
+		case "Mains":
+			if read(entry.Name(), "online") == "1" {
+				return false, nil
+			}
+		case "Battery":
+			if read(entry.Name(), "status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging, nil
+}
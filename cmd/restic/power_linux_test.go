@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseUpowerOnBattery(t *testing.T) {
+	onBattery, err := parseUpowerOnBattery(`Device: /org/freedesktop/UPower/devices/battery_BAT0
+  native-path:          BAT0
+  power supply:         yes
+
+Daemon:
+  daemon-version:  0.99.20
+  on-battery:      yes
+  lid-is-closed:   no
+`)
+	rtest.OK(t, err)
+	rtest.Assert(t, onBattery, "expected to run on battery")
+
+	onBattery, err = parseUpowerOnBattery("Daemon:\n  on-battery:      no\n")
+	rtest.OK(t, err)
+	rtest.Assert(t, !onBattery, "expected to run on AC power")
+
+	_, err = parseUpowerOnBattery("Daemon:\n")
+	rtest.Assert(t, err != nil, "missing error for output without on-battery property")
+}
+
+func TestSysfsOnBattery(t *testing.T) {
+	root := t.TempDir()
+	writeSupply := func(name string, values map[string]string) {
+		rtest.OK(t, os.MkdirAll(filepath.Join(root, name), 0700))
+		for k, v := range values {
+			rtest.OK(t, os.WriteFile(filepath.Join(root, name, k), []byte(v+"\n"), 0600))
+		}
+	}
+
+	writeSupply("BAT0", map[string]string{"type": "Battery", "status": "Discharging"})
+	writeSupply("AC", map[string]string{"type": "Mains", "online": "0"})
+	onBattery, err := sysfsOnBattery(root)
+	rtest.OK(t, err)
+	rtest.Assert(t, onBattery, "expected to run on battery")
+
+	writeSupply("AC", map[string]string{"type": "Mains", "online": "1"})
+	onBattery, err = sysfsOnBattery(root)
+	rtest.OK(t, err)
+	rtest.Assert(t, !onBattery, "expected to run on AC power")
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package main
+
+import "github.com/restic/restic/internal/errors"
+
+func readPowerState() (powerState, error) {
+	return powerState{}, errors.New("not supported on this platform")
+}
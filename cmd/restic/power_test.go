@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestPowerMonitorPause(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var m sync.Mutex
+	state := powerState{OnBattery: true}
+	read := func() (powerState, error) {
+		m.Lock()
+		defer m.Unlock()
+		return state, nil
+	}
+	var messages []string
+	notify := func(msg string) {
+		messages = append(messages, msg)
+	}
+
+	power, err := newPowerMonitor(ctx, true, 0, read, notify)
+	rtest.OK(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- power.Wait(1024)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("reading was not paused while on battery")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	m.Lock()
+	state.OnBattery = false
+	m.Unlock()
+	power.update()
+	rtest.OK(t, <-done)
+	rtest.Equals(t, []string{"AC power connected, resuming backup"}, messages)
+
+	// waiting must be aborted once the context is cancelled
+	m.Lock()
+	state.OnBattery = true
+	m.Unlock()
+	power.update()
+	cancel()
+	rtest.Equals(t, context.Canceled, power.Wait(1))
+}
+
+func TestPowerMonitorThrottle(t *testing.T) {
+	state := powerState{PowerSaver: true}
+	read := func() (powerState, error) {
+		return state, nil
+	}
+
+	power, err := newPowerMonitor(context.Background(), false, 1, read, func(string) {})
+	rtest.OK(t, err)
+
+	// the burst of 1 KiB is available immediately, the next KiB takes a second
+	start := time.Now()
+	rtest.OK(t, power.Wait(1024))
+	rtest.OK(t, power.Wait(512))
+	rtest.Assert(t, time.Since(start) >= 400*time.Millisecond, "reading was not throttled in power saver mode")
+
+	state.PowerSaver = false
+	power.update()
+	start = time.Now()
+	rtest.OK(t, power.Wait(1024*1024))
+	rtest.Assert(t, time.Since(start) < 400*time.Millisecond, "reading was throttled without low power mode")
+}
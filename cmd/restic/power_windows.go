@@ -0,0 +1,39 @@
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetSystemPowerStatus = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetSystemPowerStatus")
+
+// systemPowerStatus is the SYSTEM_POWER_STATUS structure.
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+const (
+	// acLineOffline is the ACLineStatus while running on battery.
+	acLineOffline = 0
+	// batterySaverOn is the SystemStatusFlag while the battery saver is active.
+	batterySaverOn = 1
+)
+
+func readPowerState() (powerState, error) {
+	var status systemPowerStatus
+	r, _, err := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&status)))
+	if r == 0 {
+		return powerState{}, err
+	}
+
+	return powerState{
+		OnBattery:  status.ACLineStatus == acLineOffline,
+		PowerSaver: status.SystemStatusFlag == batterySaverOn,
+	}, nil
+}
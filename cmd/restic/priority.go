@@ -0,0 +1,79 @@
+package main
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// applyProcessPriority lowers the scheduling priority of the process and
+// restricts it to a set of CPUs as requested by the global options.
+func applyProcessPriority(gopts GlobalOptions) error {
+	if gopts.LowPriority {
+		if err := lowerProcessPriority(); err != nil {
+			Warnf("unable to lower process priority: %v\n", err)
+		}
+	}
+
+	if gopts.CPUAffinity == "" {
+		return nil
+	}
+
+	cpus, err := parseCPUList(gopts.CPUAffinity)
+	if err != nil {
+		return errors.Fatalf("--cpu-affinity: %v", err)
+	}
+	if err := setCPUAffinity(cpus); err != nil {
+		return errors.Fatalf("unable to set CPU affinity: %v", err)
+	}
+	// don't start more threads than CPUs are usable
+	if len(cpus) < runtime.GOMAXPROCS(0) {
+		runtime.GOMAXPROCS(len(cpus))
+	}
+	return nil
+}
+
+// parseCPUList parses a comma-separated list of CPU numbers and ranges, for
+// example "0-3,6".
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	seen := make(map[int]struct{})
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.ParseUint(first, 10, 16)
+		if err != nil {
+			return nil, errors.Errorf("invalid CPU number %q", first)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.ParseUint(last, 10, 16)
+			if err != nil {
+				return nil, errors.Errorf("invalid CPU number %q", last)
+			}
+			if end < start {
+				return nil, errors.Errorf("invalid CPU range %q", part)
+			}
+		}
+
+		for cpu := int(start); cpu <= int(end); cpu++ {
+			if _, ok := seen[cpu]; ok {
+				continue
+			}
+			seen[cpu] = struct{}{}
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	if len(cpus) == 0 {
+		return nil, errors.New("no CPUs specified")
+	}
+	return cpus, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// lowNiceValue is the nice value used for --low-priority.
+const lowNiceValue = 10
+
+// forEachThread calls fn for every thread of the current process. On Linux,
+// the scheduling priority and the CPU affinity are properties of a thread
+// rather than the process. Threads created later inherit them from the thread
+// which created them.
+func forEachThread(fn func(tid int) error) error {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		// fall back to the current thread only
+		return fn(0)
+	}
+
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		err = fn(tid)
+		// the thread may have exited in the meantime
+		if err != nil && err != unix.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+func lowerProcessPriority() error {
+	return forEachThread(func(tid int) error {
+		return unix.Setpriority(unix.PRIO_PROCESS, tid, lowNiceValue)
+	})
+}
+
+func setCPUAffinity(cpus []int) error {
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+
+	return forEachThread(func(tid int) error {
+		return unix.SchedSetaffinity(tid, &set)
+	})
+}
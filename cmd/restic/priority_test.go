@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseCPUList(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		cpus  []int
+		err   bool
+	}{
+		{"0", []int{0}, false},
+		{"0-3", []int{0, 1, 2, 3}, false},
+		{"0-1,4, 6", []int{0, 1, 4, 6}, false},
+		{"2,0-2", []int{2, 0, 1}, false},
+		{"", nil, true},
+		{"a", nil, true},
+		{"3-1", nil, true},
+		{"-1", nil, true},
+	} {
+		t.Run(test.input, func(t *testing.T) {
+			cpus, err := parseCPUList(test.input)
+			if test.err {
+				rtest.Assert(t, err != nil, "missing error for %q", test.input)
+				return
+			}
+			rtest.OK(t, err)
+			rtest.Equals(t, test.cpus, cpus)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package main
+
+import (
+	"github.com/restic/restic/internal/errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// lowNiceValue is the nice value used for --low-priority.
+const lowNiceValue = 10
+
+func lowerProcessPriority() error {
+	return unix.Setpriority(unix.PRIO_PROCESS, 0, lowNiceValue)
+}
+
+func setCPUAffinity(_ []int) error {
+	return errors.New("not supported on this platform")
+}
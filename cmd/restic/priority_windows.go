@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math/bits"
+
+	"github.com/restic/restic/internal/errors"
+
+	"golang.org/x/sys/windows"
+)
+
+var procSetProcessAffinityMask = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetProcessAffinityMask")
+
+// lowerProcessPriority switches the process into background processing mode,
+// which lowers both the CPU and the IO priority.
+func lowerProcessPriority() error {
+	return windows.SetPriorityClass(windows.CurrentProcess(), windows.PROCESS_MODE_BACKGROUND_BEGIN)
+}
+
+func setCPUAffinity(cpus []int) error {
+	var mask uintptr
+	for _, cpu := range cpus {
+		if cpu >= bits.UintSize {
+			return errors.Errorf("CPU %d is not supported, only the first %d CPUs can be selected", cpu, bits.UintSize)
+		}
+		mask |= 1 << uint(cpu)
+	}
+
+	r, _, err := procSetProcessAffinityMask.Call(uintptr(windows.CurrentProcess()), mask)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/event"
 	"github.com/restic/restic/internal/ui/progress"
 	"github.com/restic/restic/internal/ui/termstatus"
 )
@@ -127,3 +128,26 @@ func newTerminalProgressPrinter(verbosity uint, term *termstatus.Terminal) progr
 		show:    verbosity > 0,
 	}
 }
+
+// newEventPrinter returns a printer which emits the events of the command as
+// JSON lines, or nil if the --json flag is not set.
+func newEventPrinter(command string, gopts GlobalOptions, out event.Output) *event.Printer {
+	if !gopts.JSON {
+		return nil
+	}
+	return event.NewPrinter(out, command, gopts.verbosity, calculateProgressInterval(!gopts.Quiet, true))
+}
+
+// stderrOutput prints all events to stderr. It is used by commands which
+// print a JSON document to stdout.
+type stderrOutput struct {
+	term *termstatus.Terminal
+}
+
+func (o stderrOutput) Print(line string) {
+	o.term.Error(line)
+}
+
+func (o stderrOutput) Error(line string) {
+	o.term.Error(line)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restorer"
+)
+
+var cmdRestoreHelper = &cobra.Command{
+	Use:   "restore-helper --pipe name --target dir",
+	Short: "Apply privileged metadata operations for restore --elevate",
+	Long: `
+The "restore-helper" command is started with administrative privileges by
+"restore --elevate". It sets the security descriptors and creates the symlinks
+requested by the restore command via a named pipe. It only modifies files
+below the target directory.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful, and non-zero if there was any error.
+`,
+	Hidden:            true,
+	DisableAutoGenTag: true,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return runRestoreHelper(restoreHelperOptions)
+	},
+}
+
+// RestoreHelperOptions collects all options for the restore-helper command.
+type RestoreHelperOptions struct {
+	Pipe   string
+	Target string
+}
+
+var restoreHelperOptions RestoreHelperOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdRestoreHelper)
+
+	f := cmdRestoreHelper.Flags()
+	f.StringVar(&restoreHelperOptions.Pipe, "pipe", "", "`name` of the pipe to read requests from")
+	f.StringVar(&restoreHelperOptions.Target, "target", "", "`directory` below which files may be modified")
+}
+
+func runRestoreHelper(opts RestoreHelperOptions) error {
+	if opts.Pipe == "" || opts.Target == "" {
+		return errors.Fatal("--pipe and --target are required")
+	}
+	pipe, err := os.OpenFile(opts.Pipe, os.O_RDWR, 0)
+	if err != nil {
+		return errors.Fatalf("unable to connect to restore: %v", err)
+	}
+	defer func() {
+		_ = pipe.Close()
+	}()
+	return restorer.ServeElevatedHelper(pipe, opts.Target)
+}
+
+// startElevatedHelper starts the restore-helper command with administrative
+// privileges for the restore to target.
+func startElevatedHelper(target string) (*restorer.ElevatedHelper, error) {
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return nil, err
+	}
+	rwc, err := fs.RunElevated(func(pipe string) []string {
+		return []string{"restore-helper", "--pipe", pipe, "--target", target}
+	})
+	if err != nil {
+		return nil, errors.Fatalf("unable to start elevated helper: %v", err)
+	}
+	return restorer.NewElevatedHelper(rwc), nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// systemDirs returns the directories containing the operating system. A
+// restore into one of them can render the system unusable.
+func systemDirs() []string {
+	switch runtime.GOOS {
+	case "windows":
+		var dirs []string
+		for _, name := range []string{"SystemRoot", "ProgramFiles", "ProgramFiles(x86)", "ProgramData"} {
+			if dir := os.Getenv(name); dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+		if len(dirs) == 0 {
+			dirs = append(dirs, `C:\Windows`)
+		}
+		return dirs
+	case "darwin":
+		return []string{"/System", "/bin", "/etc", "/sbin", "/usr", "/private/etc"}
+	default:
+		return []string{"/bin", "/boot", "/etc", "/lib", "/lib32", "/lib64", "/sbin", "/usr"}
+	}
+}
+
+// bootVolumeRoot returns the root directory of the volume the system was
+// started from.
+func bootVolumeRoot() string {
+	if runtime.GOOS == "windows" {
+		drive := os.Getenv("SystemDrive")
+		if drive == "" {
+			drive = "C:"
+		}
+		return drive + `\`
+	}
+	return "/"
+}
+
+// resolvePath returns the absolute path of p with all symlinks in its
+// existing part resolved. On Windows, the result is converted to lower case.
+func resolvePath(p string) string {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+
+	rest := ""
+	for dir := p; ; {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			p = filepath.Join(resolved, rest)
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+
+	if runtime.GOOS == "windows" {
+		p = strings.ToLower(p)
+	}
+	return p
+}
+
+// systemTargetOverlap returns the system directory which the restore target
+// is located in or contains, if any.
+func systemTargetOverlap(target string, systemDirs []string, bootRoot string) (string, bool) {
+	resolved := resolvePath(target)
+	if resolved == resolvePath(bootRoot) {
+		return bootRoot, true
+	}
+	for _, dir := range systemDirs {
+		sys := resolvePath(dir)
+		if fs.HasPathPrefix(sys, resolved) || fs.HasPathPrefix(resolved, sys) {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// checkSystemTarget refuses restores into system directories, unless they
+// were explicitly allowed using --allow-system-target and the plan printed by
+// restic was confirmed by passing the ID of the snapshot to --confirm.
+func checkSystemTarget(opts RestoreOptions, sn *restic.Snapshot, subfolder string) error {
+	dir, ok := systemTargetOverlap(opts.Target, systemDirs(), bootVolumeRoot())
+	if !ok {
+		return nil
+	}
+
+	if !opts.AllowSystemTarget {
+		return errors.Fatalf("the target %v overlaps the system directory %v, pass --allow-system-target to restore there anyway", opts.Target, dir)
+	}
+
+	id := sn.ID().Str()
+	if opts.Confirm == id || opts.Confirm == sn.ID().String() {
+		return nil
+	}
+
+	Warnf("restoring into the system directory %v will overwrite files of the running system:\n", dir)
+	Warnf("  snapshot:  %v of %v at %v from %v\n", id, sn.Paths, sn.Time.Format(TimeFormat), sn.Hostname)
+	if subfolder != "" {
+		Warnf("  subfolder: %v\n", subfolder)
+	}
+	Warnf("  target:    %v\n", opts.Target)
+	Warnf("  overwrite: %v\n", opts.Overwrite.String())
+	return errors.Fatalf("pass --confirm %s to confirm this restore", id)
+}
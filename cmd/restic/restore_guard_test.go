@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestSystemTargetOverlap(t *testing.T) {
+	root := rtest.TempDir(t)
+	system := filepath.Join(root, "system")
+	rtest.OK(t, os.MkdirAll(filepath.Join(system, "config"), 0o700))
+	rtest.OK(t, os.MkdirAll(filepath.Join(root, "data"), 0o700))
+	boot := filepath.Join(root, "boot")
+	rtest.OK(t, os.Mkdir(boot, 0o700))
+
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(system, link); err != nil {
+		t.Logf("unable to create symlink: %v", err)
+		link = ""
+	}
+
+	for _, test := range []struct {
+		target  string
+		overlap string
+	}{
+		{filepath.Join(root, "data"), ""},
+		{filepath.Join(root, "data", "missing"), ""},
+		{filepath.Join(root, "systemd"), ""},
+		{system, system},
+		{filepath.Join(system, "config", "missing"), system},
+		{filepath.Join(system, "config", ".."), system},
+		{root, system},
+		{boot, boot},
+		{link, system},
+		{filepath.Join(link, "config"), system},
+	} {
+		if test.target == "" {
+			continue
+		}
+		dir, ok := systemTargetOverlap(test.target, []string{system}, boot)
+		rtest.Equals(t, test.overlap != "", ok, "target %v", test.target)
+		rtest.Equals(t, test.overlap, dir, "target %v", test.target)
+	}
+}
+
+func TestCheckSystemTarget(t *testing.T) {
+	sn := &restic.Snapshot{Paths: []string{"/"}}
+	id := restic.NewRandomID()
+	restic.TestSetSnapshotID(t, sn, id)
+
+	opts := RestoreOptions{Target: rtest.TempDir(t)}
+	rtest.OK(t, checkSystemTarget(opts, sn, ""))
+
+	opts.Target = bootVolumeRoot()
+	rtest.Assert(t, checkSystemTarget(opts, sn, "") != nil, "restore to the boot volume was allowed")
+	opts.AllowSystemTarget = true
+	rtest.Assert(t, checkSystemTarget(opts, sn, "") != nil, "restore without confirmation was allowed")
+	other := restic.NewRandomID()
+	opts.Confirm = other.Str()
+	rtest.Assert(t, checkSystemTarget(opts, sn, "") != nil, "restore with wrong confirmation was allowed")
+	opts.Confirm = id.Str()
+	rtest.OK(t, checkSystemTarget(opts, sn, ""))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restorer"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+// runRestorePreflight checks whether the files selected for restore can be
+// restored to target and prints the result. An error is returned if there are
+// blocking issues.
+func runRestorePreflight(ctx context.Context, res *restorer.Restorer, target string, gopts GlobalOptions, term *termstatus.Terminal, msg *ui.Message) error {
+	result, err := res.Preflight(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		term.Print(ui.ToJSONString(struct {
+			MessageType string `json:"message_type"` // "preflight"
+			*restorer.PreflightResult
+		}{"preflight", result}))
+	} else {
+		msg.P("preflight: %d files, %d directories, %d symlinks and %d streams, %s to restore\n",
+			result.Files, result.Dirs, result.Symlinks, result.Streams, ui.FormatBytes(result.RestoreSize))
+		if result.Target.FreeSpace != nil {
+			msg.P("preflight: %s available on the target\n", ui.FormatBytes(*result.Target.FreeSpace))
+		}
+		for _, issue := range result.Issues {
+			if issue.Blocking {
+				msg.E("preflight error: %s\n", issue.Message)
+			} else {
+				msg.E("preflight warning: %s\n", issue.Message)
+			}
+		}
+	}
+
+	if result.Blocking() {
+		return errors.Fatal("preflight check failed, nothing was restored")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// sinceFilter selects the nodes of a snapshot which differ from the node at
+// the same path in another snapshot. Paths use the same format as the
+// locations passed to the restorer's SelectFilter.
+type sinceFilter struct {
+	// changed contains the paths of all added or modified nodes
+	changed map[string]struct{}
+	// dirs contains the paths of all directories which contain changed nodes
+	dirs map[string]struct{}
+}
+
+// nodeChanged returns whether the node differs from the node base in its type,
+// content or basic metadata.
+func nodeChanged(node, base *restic.Node) bool {
+	return node.Type != base.Type ||
+		node.Mode != base.Mode ||
+		!node.ModTime.Equal(base.ModTime) ||
+		node.Size != base.Size ||
+		node.LinkTarget != base.LinkTarget ||
+		(node.Type == "file" && !reflect.DeepEqual(node.Content, base.Content))
+}
+
+// newSinceFilter compares the tree id with the tree base. Subtrees which are
+// identical in both trees are skipped.
+func newSinceFilter(ctx context.Context, repo restic.BlobLoader, id, base restic.ID) (*sinceFilter, error) {
+	f := &sinceFilter{
+		changed: make(map[string]struct{}),
+		dirs:    make(map[string]struct{}),
+	}
+	_, err := f.compareTree(ctx, repo, string(filepath.Separator), id, &base)
+	return f, err
+}
+
+// compareTree records the changes in tree id compared to tree base, which is
+// nil if it does not exist. It returns whether any changes were found.
+func (f *sinceFilter) compareTree(ctx context.Context, repo restic.BlobLoader, location string, id restic.ID, base *restic.ID) (bool, error) {
+	tree, err := restic.LoadTree(ctx, repo, id)
+	if err != nil {
+		return false, err
+	}
+	baseNodes := make(map[string]*restic.Node)
+	if base != nil {
+		baseTree, err := restic.LoadTree(ctx, repo, *base)
+		if err != nil {
+			return false, err
+		}
+		for _, node := range baseTree.Nodes {
+			baseNodes[node.Name] = node
+		}
+	}
+
+	hasChanges := false
+	for _, node := range tree.Nodes {
+		nodeLocation := filepath.Join(location, node.Name)
+		baseNode, ok := baseNodes[node.Name]
+		if ok && baseNode.Type != node.Type {
+			ok = false
+		}
+
+		if node.Type == "dir" && node.Subtree != nil {
+			var baseSubtree *restic.ID
+			if ok {
+				if baseNode.Subtree != nil && baseNode.Subtree.Equal(*node.Subtree) {
+					continue
+				}
+				baseSubtree = baseNode.Subtree
+			}
+
+			childChanged, err := f.compareTree(ctx, repo, nodeLocation, *node.Subtree, baseSubtree)
+			if err != nil {
+				return false, err
+			}
+			if childChanged {
+				f.dirs[nodeLocation] = struct{}{}
+				hasChanges = true
+			}
+			if !ok {
+				f.changed[nodeLocation] = struct{}{}
+				hasChanges = true
+			}
+			continue
+		}
+
+		if !ok || nodeChanged(node, baseNode) {
+			f.changed[nodeLocation] = struct{}{}
+			hasChanges = true
+		}
+	}
+	return hasChanges, nil
+}
+
+// Select reports whether the node at item has changed and whether changed
+// nodes may exist below it.
+func (f *sinceFilter) Select(item string) (selectedForRestore bool, childMayBeSelected bool) {
+	_, changed := f.changed[item]
+	_, dir := f.dirs[item]
+	return changed, changed || dir
+}
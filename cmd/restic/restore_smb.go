@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// parseSMBTarget converts a restore target in the form smb://server/share/path
+// into the UNC path \\server\share\path and the share \\server\share. For
+// other targets, ok is false.
+func parseSMBTarget(target string) (unc, share string, ok bool, err error) {
+	if !strings.HasPrefix(strings.ToLower(target), "smb://") {
+		return "", "", false, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", "", false, errors.Fatalf("invalid SMB target %q: %v", target, err)
+	}
+	if u.User != nil {
+		return "", "", false, errors.Fatalf("invalid SMB target %q: use --smb-user to specify the user", target)
+	}
+	if u.Port() != "" {
+		return "", "", false, errors.Fatalf("invalid SMB target %q: ports are not supported", target)
+	}
+
+	elems := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+	if u.Hostname() == "" || len(elems) == 0 {
+		return "", "", false, errors.Fatalf("invalid SMB target %q: expected smb://server/share/path", target)
+	}
+
+	share = `\\` + u.Hostname() + `\` + elems[0]
+	unc = strings.Join(append([]string{share}, elems[1:]...), `\`)
+	return unc, share, true, nil
+}
+
+// connectSMBTarget connects to the share of an SMB restore target and returns
+// the UNC path of the target. For other targets, the target is returned
+// unchanged. The password for user is read from $RESTIC_SMB_PASSWORD or
+// requested from the user.
+func connectSMBTarget(ctx context.Context, target, user string) (string, func(), error) {
+	unc, share, ok, err := parseSMBTarget(target)
+	if err != nil {
+		return "", nil, err
+	}
+	if !ok {
+		if user != "" {
+			return "", nil, errors.Fatal("--smb-user requires a target in the form smb://server/share/path")
+		}
+		return target, func() {}, nil
+	}
+
+	var password string
+	if user != "" {
+		password = os.Getenv("RESTIC_SMB_PASSWORD")
+		if password == "" {
+			if !stdinIsTerminal() {
+				return "", nil, errors.Fatal("the SMB password must be specified using $RESTIC_SMB_PASSWORD")
+			}
+			password, err = readPasswordTerminal(ctx, os.Stdin, os.Stderr, "enter password for SMB user "+user+": ")
+			if err != nil {
+				return "", nil, errors.Wrap(err, "unable to read password")
+			}
+		}
+	}
+
+	disconnect, err := fs.ConnectSMBShare(share, user, password)
+	if err != nil {
+		return "", nil, errors.Fatalf("%v", err)
+	}
+	return unc, func() {
+		if err := disconnect(); err != nil {
+			Warnf("%v\n", err)
+		}
+	}, nil
+}
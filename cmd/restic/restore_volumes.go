@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+)
+
+// formatVolume returns a description of the volume v.
+func formatVolume(v fs.VolumeInfo) []string {
+	var details []string
+	if v.Label != "" {
+		details = append(details, fmt.Sprintf("label %q", v.Label))
+	}
+	if v.FileSystem != "" {
+		details = append(details, v.FileSystem)
+	}
+	if v.Serial != 0 {
+		details = append(details, fmt.Sprintf("serial %04X-%04X", v.Serial>>16, v.Serial&0xffff))
+	}
+
+	header := v.Path
+	if len(details) > 0 {
+		header += " (" + strings.Join(details, ", ") + ")"
+	}
+	lines := []string{
+		header,
+		fmt.Sprintf("  cluster size: %v", ui.FormatBytes(v.ClusterSize)),
+		fmt.Sprintf("  size:         %v, %v used", ui.FormatBytes(v.TotalSize), ui.FormatBytes(v.UsedSize)),
+	}
+	if v.Quota != nil {
+		lines = append(lines, "  quota:        "+formatVolumeQuota(*v.Quota))
+	}
+	return lines
+}
+
+func formatVolumeQuota(q fs.VolumeQuota) string {
+	if !q.Tracked && !q.Enforced {
+		return "disabled"
+	}
+
+	state := "tracked"
+	if q.Enforced {
+		state = "enforced"
+	}
+	formatLimit := func(limit int64) string {
+		if limit < 0 {
+			return "none"
+		}
+		return ui.FormatBytes(uint64(limit))
+	}
+	return fmt.Sprintf("%v, default limit %v, default warning level %v", state,
+		formatLimit(q.DefaultLimit), formatLimit(q.DefaultThreshold))
+}
+
+// compareVolumes returns the differences between the volume source recorded
+// in a snapshot and the restore target volume which should be considered
+// before restoring.
+func compareVolumes(source, target fs.VolumeInfo) []string {
+	var warnings []string
+	if target.TotalSize < source.UsedSize {
+		warnings = append(warnings, fmt.Sprintf("the target volume is smaller (%v) than the used space of %v (%v)",
+			ui.FormatBytes(target.TotalSize), source.Path, ui.FormatBytes(source.UsedSize)))
+	} else if target.TotalSize-target.UsedSize < source.UsedSize {
+		warnings = append(warnings, fmt.Sprintf("the target volume has less free space (%v) than the used space of %v (%v)",
+			ui.FormatBytes(target.TotalSize-target.UsedSize), source.Path, ui.FormatBytes(source.UsedSize)))
+	}
+	if source.FileSystem != "" && target.FileSystem != "" && source.FileSystem != target.FileSystem {
+		warnings = append(warnings, fmt.Sprintf("the file system differs: %v on %v, %v on the target volume",
+			source.FileSystem, source.Path, target.FileSystem))
+	}
+	if source.ClusterSize != 0 && target.ClusterSize != 0 && source.ClusterSize != target.ClusterSize {
+		warnings = append(warnings, fmt.Sprintf("the cluster size differs: %v on %v, %v on the target volume",
+			ui.FormatBytes(source.ClusterSize), source.Path, ui.FormatBytes(target.ClusterSize)))
+	}
+	if source.Quota != nil && (source.Quota.Tracked || source.Quota.Enforced) {
+		if target.Quota == nil {
+			warnings = append(warnings, fmt.Sprintf("quotas were %v on %v, the quota settings of the target volume are unknown",
+				formatVolumeQuota(*source.Quota), source.Path))
+		} else if *source.Quota != *target.Quota {
+			warnings = append(warnings, fmt.Sprintf("the quota settings differ: %v on %v, %v on the target volume",
+				formatVolumeQuota(*source.Quota), source.Path, formatVolumeQuota(*target.Quota)))
+		}
+	}
+	return warnings
+}
+
+// printVolumeReport compares the volumes recorded in the snapshot sn with the
+// volume containing the restore target.
+func printVolumeReport(gopts GlobalOptions, sn *restic.Snapshot, target string) error {
+	targetVolume, err := fs.GetVolumeInfo(target)
+	if err != nil {
+		return fmt.Errorf("unable to read volume information of %v: %w", target, err)
+	}
+
+	type volumeReport struct {
+		Volume   fs.VolumeInfo `json:"volume"`
+		Warnings []string      `json:"warnings"`
+	}
+	reports := make([]volumeReport, 0, len(sn.Volumes))
+	for _, v := range sn.Volumes {
+		var warnings []string
+		if targetVolume != nil {
+			warnings = compareVolumes(v, *targetVolume)
+		}
+		reports = append(reports, volumeReport{Volume: v, Warnings: warnings})
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(globalOptions.stdout).Encode(struct {
+			Volumes []volumeReport `json:"volumes"`
+			Target  *fs.VolumeInfo `json:"target"`
+		}{reports, targetVolume})
+	}
+
+	if len(sn.Volumes) == 0 {
+		Printf("snapshot %v contains no volume information, only backups of a volume root record it\n", sn.ID().Str())
+	}
+	for _, r := range reports {
+		Printf("snapshot volume %v\n", strings.Join(formatVolume(r.Volume), "\n"))
+	}
+	if targetVolume == nil {
+		Printf("volume information of the target is not available on this platform\n")
+		return nil
+	}
+	Printf("target volume %v\n", strings.Join(formatVolume(*targetVolume), "\n"))
+
+	for _, r := range reports {
+		for _, w := range r.Warnings {
+			Printf("warning: %v\n", w)
+		}
+	}
+	return nil
+}
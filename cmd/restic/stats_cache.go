@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/repository/pack"
+	"github.com/restic/restic/internal/restic"
+)
+
+// statsCacheFile is the name of the file in the local cache which contains
+// the raw-data statistics for all snapshots of the repository.
+const statsCacheFile = "stats.json"
+
+// maxStatsCacheUnreferenced is the maximum number of indexed blobs that are
+// not referenced by any snapshot, for which the stats cache is still stored.
+const maxStatsCacheUnreferenced = 100000
+
+// rawDataStats accumulates the size of blobs for the raw-data mode.
+type rawDataStats struct {
+	TotalSize                            uint64 `json:"total_size"`
+	TotalUncompressedSize                uint64 `json:"total_uncompressed_size"`
+	TotalCompressedBlobsSize             uint64 `json:"total_compressed_blobs_size"`
+	TotalCompressedBlobsUncompressedSize uint64 `json:"total_compressed_blobs_uncompressed_size"`
+	TotalBlobCount                       uint64 `json:"total_blob_count"`
+}
+
+// add counts the blob pb, version is the repository version.
+func (s *rawDataStats) add(pb restic.PackedBlob, version uint) {
+	s.TotalSize += uint64(pb.Length)
+	if version >= 2 {
+		s.TotalUncompressedSize += uint64(crypto.CiphertextLength(int(pb.DataLength())))
+		if pb.IsCompressed() {
+			s.TotalCompressedBlobsSize += uint64(pb.Length)
+			s.TotalCompressedBlobsUncompressedSize += uint64(crypto.CiphertextLength(int(pb.DataLength())))
+		}
+	}
+	s.TotalBlobCount++
+}
+
+// sub removes the blob pb which was counted by add, version is the
+// repository version.
+func (s *rawDataStats) sub(pb restic.PackedBlob, version uint) {
+	s.TotalSize -= uint64(pb.Length)
+	if version >= 2 {
+		s.TotalUncompressedSize -= uint64(crypto.CiphertextLength(int(pb.DataLength())))
+		if pb.IsCompressed() {
+			s.TotalCompressedBlobsSize -= uint64(pb.Length)
+			s.TotalCompressedBlobsUncompressedSize -= uint64(crypto.CiphertextLength(int(pb.DataLength())))
+		}
+	}
+	s.TotalBlobCount--
+}
+
+// addNew counts count blobs added by a backup with a total plaintext size of
+// size and a total size of sizeInRepo bytes in the repository, which includes
+// the pack header entries.
+func (s *rawDataStats) addNew(count int, size, sizeInRepo uint64, compressed bool, version uint) {
+	compressed = compressed && version >= 2
+	entry := restic.Blob{}
+	if compressed {
+		entry.UncompressedLength = 1
+	}
+	sizeInRepo -= uint64(count * pack.CalculateEntrySize(entry))
+
+	s.TotalSize += sizeInRepo
+	if version >= 2 {
+		uncompressed := size + uint64(count*crypto.CiphertextLength(0))
+		s.TotalUncompressedSize += uncompressed
+		if compressed {
+			s.TotalCompressedBlobsSize += sizeInRepo
+			s.TotalCompressedBlobsUncompressedSize += uncompressed
+		}
+	}
+	s.TotalBlobCount += uint64(count)
+}
+
+// statsCache stores the raw-data statistics of all snapshots. It is only
+// valid as long as the repository contains exactly the listed snapshots and
+// index files. Blobs which are indexed but not referenced by any snapshot are
+// tracked, such that the statistics can be updated by backup, forget and
+// prune. Other modifications of the repository are detected by the next stats
+// run, which then rebuilds the cache.
+type statsCache struct {
+	Snapshots restic.IDs `json:"snapshots"`
+	// SnapshotsCount is the number of snapshots whose blobs are counted,
+	// Snapshots also includes the snapshots in the trash.
+	SnapshotsCount int                `json:"snapshots_count"`
+	Indexes        restic.IDs         `json:"indexes"`
+	Unreferenced   restic.BlobHandles `json:"unreferenced,omitempty"`
+	RawData        rawDataStats       `json:"raw_data"`
+}
+
+// loadStatsCache returns the stats cache of the repository, or nil if it does
+// not exist or cannot be loaded.
+func loadStatsCache(repo *repository.Repository) *statsCache {
+	if repo.Cache == nil {
+		return nil
+	}
+
+	var c statsCache
+	err := repo.LoadCacheJSON(statsCacheFile, &c)
+	if err != nil {
+		debug.Log("unable to load stats cache: %v", err)
+		return nil
+	}
+	return &c
+}
+
+// saveStatsCache stores c or removes the stats cache if c is nil.
+func saveStatsCache(repo *repository.Repository, c *statsCache) {
+	if repo.Cache == nil {
+		return
+	}
+
+	var err error
+	if c == nil {
+		err = repo.Cache.RemoveFile(statsCacheFile)
+	} else {
+		err = repo.SaveCacheJSON(statsCacheFile, c)
+	}
+	if err != nil {
+		debug.Log("unable to update stats cache: %v", err)
+	}
+}
+
+// loadMatchingStatsCache returns the stats cache of the repository if it
+// matches the given snapshots, or nil otherwise. Unless indexes is nil, the
+// cache must also match these index files.
+func loadMatchingStatsCache(repo *repository.Repository, snapshots, indexes restic.IDSet) *statsCache {
+	c := loadStatsCache(repo)
+	if c == nil {
+		return nil
+	}
+
+	if !restic.NewIDSet(c.Snapshots...).Equals(snapshots) ||
+		(indexes != nil && !restic.NewIDSet(c.Indexes...).Equals(indexes)) {
+		debug.Log("stats cache does not match the repository")
+		return nil
+	}
+	return c
+}
+
+// listIDs returns the IDs of all files of type t.
+func listIDs(ctx context.Context, repo restic.Lister, t restic.FileType) (restic.IDSet, error) {
+	ids := restic.NewIDSet()
+	err := repo.List(ctx, t, func(id restic.ID, _ int64) error {
+		ids.Insert(id)
+		return nil
+	})
+	return ids, err
+}
+
+// idLister lists the files with the given IDs without accessing the
+// repository. It is used to load the index files recorded in the stats cache,
+// as listing a file type twice may return inconsistent results.
+type idLister restic.IDs
+
+func (l idLister) List(ctx context.Context, _ restic.FileType, fn func(restic.ID, int64) error) error {
+	for _, id := range l {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := fn(id, 0); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// buildStatsCache computes the stats cache for the given snapshots from the
+// loaded index. The function used reports whether a blob is referenced by any
+// of the counted snapshots. If too many blobs are not referenced, nil is
+// returned.
+func buildStatsCache(ctx context.Context, repo *repository.Repository, snapshots restic.IDSet, counted int, used func(restic.BlobHandle) bool) (*statsCache, error) {
+	c := &statsCache{
+		Snapshots:      snapshots.List(),
+		SnapshotsCount: counted,
+		Indexes:        repo.IndexIDs().List(),
+	}
+	version := repo.Config().Version
+
+	tooMany := false
+	err := repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+		// only count the first copy of duplicate blobs
+		pbs := repo.LookupBlob(pb.Type, pb.ID)
+		if len(pbs) == 0 || pbs[0].PackID != pb.PackID || pbs[0].Offset != pb.Offset {
+			return
+		}
+
+		if used(pb.BlobHandle) {
+			c.RawData.add(pb, version)
+			return
+		}
+		if len(c.Unreferenced) >= maxStatsCacheUnreferenced {
+			tooMany = true
+			return
+		}
+		c.Unreferenced = append(c.Unreferenced, pb.BlobHandle)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tooMany {
+		debug.Log("too many unreferenced blobs, not creating stats cache")
+		return nil, nil
+	}
+	return c, nil
+}
+
+// updateStatsCacheAfterBackup adds the blobs of the new snapshot id to the
+// stats cache. The cache must match the index files oldIndexes which were
+// loaded before the backup. Otherwise, the cache is removed.
+func updateStatsCacheAfterBackup(ctx context.Context, repo *repository.Repository, oldIndexes restic.IDSet, id restic.ID, sn *restic.Snapshot, summary *archiver.Summary, compression repository.CompressionMode) {
+	c := loadStatsCache(repo)
+	if c == nil {
+		return
+	}
+
+	// snapshots added or removed by other clients are detected by the stats
+	// command, as the cache would not contain them
+	if !restic.NewIDSet(c.Indexes...).Equals(oldIndexes) {
+		debug.Log("stats cache does not match the index, removing it")
+		saveStatsCache(repo, nil)
+		return
+	}
+
+	version := repo.Config().Version
+	c.RawData.addNew(summary.DataBlobs, summary.DataSize, summary.DataSizeInRepo, compression != repository.CompressionOff, version)
+	c.RawData.addNew(summary.TreeBlobs, summary.TreeSize, summary.TreeSizeInRepo, true, version)
+
+	// the backup can reference blobs which already existed, but were not
+	// referenced by any snapshot
+	if len(c.Unreferenced) > 0 {
+		blobs := restic.NewBlobSet()
+		err := restic.FindUsedBlobs(ctx, repo, restic.IDs{*sn.Tree}, blobs, nil)
+		if err != nil {
+			debug.Log("unable to find blobs of snapshot %v: %v", id, err)
+			saveStatsCache(repo, nil)
+			return
+		}
+
+		var unreferenced restic.BlobHandles
+		for _, h := range c.Unreferenced {
+			if !blobs.Has(h) {
+				unreferenced = append(unreferenced, h)
+				continue
+			}
+			pbs := repo.LookupBlob(h.Type, h.ID)
+			if len(pbs) > 0 {
+				c.RawData.add(pbs[0], version)
+			}
+		}
+		c.Unreferenced = unreferenced
+	}
+
+	c.Snapshots = append(c.Snapshots, id)
+	c.SnapshotsCount++
+	c.Indexes = repo.IndexIDs().List()
+	saveStatsCache(repo, c)
+}
+
+// updateStatsCacheAfterForget updates the stats cache c after forget removed
+// the snapshots forgotten or moved them to the trash as the snapshots trashed.
+// The cache must have matched the snapshots listed by snapshotLister before.
+// Blobs which are no longer referenced by any remaining snapshot are moved to
+// the unreferenced blobs. This requires walking the trees of all snapshots,
+// but not reading any data.
+func updateStatsCacheAfterForget(ctx context.Context, repo *repository.Repository, c *statsCache, snapshotLister restic.Lister, forgotten restic.Snapshots, trashed restic.IDs) {
+	removed := restic.NewIDSet()
+	var removedTrees restic.IDs
+	for _, sn := range forgotten {
+		removed.Insert(*sn.ID())
+		removedTrees = append(removedTrees, *sn.Tree)
+	}
+
+	snapshots := restic.NewIDSet(trashed...)
+	var trees restic.IDs
+	err := restic.ForAllSnapshots(ctx, snapshotLister, repo, removed, func(id restic.ID, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		snapshots.Insert(id)
+		if !sn.InTrash() {
+			trees = append(trees, *sn.Tree)
+		}
+		return nil
+	})
+	if err == nil {
+		// the index files are not listed again, if they do not match the
+		// cache, the next stats run rebuilds it
+		err = repo.LoadIndexFromList(ctx, idLister(c.Indexes), nil)
+	}
+	if err != nil {
+		debug.Log("unable to update stats cache: %v", err)
+		saveStatsCache(repo, nil)
+		return
+	}
+
+	removedBlobs := restic.NewBlobSet()
+	usedBlobs := restic.NewBlobSet()
+	err = restic.FindUsedBlobs(ctx, repo, removedTrees, removedBlobs, nil)
+	if err == nil {
+		err = restic.FindUsedBlobs(ctx, repo, trees, usedBlobs, nil)
+	}
+	if err != nil {
+		debug.Log("unable to find blobs of snapshots: %v", err)
+		saveStatsCache(repo, nil)
+		return
+	}
+
+	version := repo.Config().Version
+	for h := range removedBlobs {
+		if usedBlobs.Has(h) {
+			continue
+		}
+		pbs := repo.LookupBlob(h.Type, h.ID)
+		if len(pbs) == 0 {
+			continue
+		}
+		c.RawData.sub(pbs[0], version)
+		c.Unreferenced = append(c.Unreferenced, h)
+	}
+	if len(c.Unreferenced) > maxStatsCacheUnreferenced {
+		debug.Log("too many unreferenced blobs, removing stats cache")
+		saveStatsCache(repo, nil)
+		return
+	}
+
+	c.Snapshots = snapshots.List()
+	c.SnapshotsCount = len(trees)
+	saveStatsCache(repo, c)
+}
+
+// updateStatsCacheAfterPrune updates the stats cache c after prune. The cache
+// must have matched the repository before. snapshots and indexes contain the
+// snapshots and index files after prune. Prune only removes blobs which are
+// not counted and repacks the counted ones, thus the statistics are computed
+// from the new index without walking any snapshots.
+func updateStatsCacheAfterPrune(ctx context.Context, repo *repository.Repository, c *statsCache, snapshots, indexes restic.IDSet) {
+	err := repo.LoadIndexFromList(ctx, idLister(indexes.List()), nil)
+	if err != nil {
+		debug.Log("unable to update stats cache: %v", err)
+		saveStatsCache(repo, nil)
+		return
+	}
+
+	unreferenced := restic.NewBlobSet(c.Unreferenced...)
+	c, err = buildStatsCache(ctx, repo, snapshots, c.SnapshotsCount, func(h restic.BlobHandle) bool {
+		return !unreferenced.Has(h)
+	})
+	if err != nil {
+		debug.Log("unable to update stats cache: %v", err)
+		c = nil
+	}
+	saveStatsCache(repo, c)
+}
@@ -0,0 +1,69 @@
+#!/usr/bin/env python3
+"""Example source plugin for "restic backup --source-plugin".
+
+The plugin provides each table of an SQLite database as a CSV file in a
+directory named after the database:
+
+    restic backup --source-plugin python3 sqlite-tables.py /srv/app/data.db
+
+creates a snapshot containing the files /data/<table>.csv. See the backup
+section of the documentation for a description of the plugin protocol.
+"""
+
+import csv
+import io
+import json
+import os
+import sqlite3
+import sys
+
+
+def send(out, msg):
+    out.write(json.dumps(msg).encode() + b"\n")
+    out.flush()
+
+
+def table_csv(db, table):
+    buf = io.StringIO()
+    writer = csv.writer(buf)
+    cursor = db.execute('SELECT * FROM "%s"' % table.replace('"', '""'))
+    writer.writerow([column[0] for column in cursor.description])
+    writer.writerows(cursor)
+    return buf.getvalue().encode()
+
+
+def main():
+    if len(sys.argv) != 2:
+        sys.exit("usage: sqlite-tables.py database")
+
+    path = sys.argv[1]
+    name = os.path.splitext(os.path.basename(path))[0]
+    db = sqlite3.connect("file:%s?mode=ro" % path, uri=True)
+    tables = [row[0] for row in db.execute(
+        "SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name")]
+    files = {"/%s/%s.csv" % (name, table): table for table in tables}
+
+    out = sys.stdout.buffer
+    for line in sys.stdin:
+        request = json.loads(line)
+        if request["op"] == "list":
+            send(out, {"path": "/" + name, "type": "dir"})
+            for file in files:
+                # without a modification time, the tables are always read
+                send(out, {"path": file, "type": "file"})
+            send(out, {"end": True})
+        elif request["op"] == "open":
+            table = files.get(request["path"])
+            if table is None:
+                send(out, {"error": "unknown file " + request["path"]})
+                continue
+            data = table_csv(db, table)
+            send(out, {"size": len(data)})
+            out.write(data)
+            out.flush()
+        else:
+            send(out, {"error": "unknown request " + request["op"]})
+
+
+if __name__ == "__main__":
+    main()
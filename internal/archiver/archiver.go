@@ -11,11 +11,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/restic/restic/internal/catalog"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/vfs"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -40,6 +42,14 @@ type ItemStats struct {
 	TreeBlobs      int    // number of new tree blobs added for this item
 	TreeSize       uint64 // sum of the sizes of all new tree blobs
 	TreeSizeInRepo uint64 // sum of the bytes added to the repo (including compression and crypto overhead)
+
+	// The data of an item which is already present in the repository is
+	// attributed to the previous version of the item in the parent snapshot,
+	// to other items of the current backup or to other data in the
+	// repository, for example snapshots of other hosts.
+	DataSizeFromParent uint64
+	DataSizeFromBackup uint64
+	DataSizeFromRepo   uint64
 }
 
 type ChangeStats struct {
@@ -51,6 +61,15 @@ type ChangeStats struct {
 type Summary struct {
 	Files, Dirs    ChangeStats
 	ProcessedBytes uint64
+	// Streams counts the alternate data streams, which are not included in
+	// Files. StreamBytes is the part of ProcessedBytes read from them.
+	Streams     ChangeStats
+	StreamBytes uint64
+	// Errors is the number of items which could not be read and were skipped.
+	Errors uint
+	// Interrupted is set if the backup was interrupted, the snapshot then
+	// only contains the items saved so far.
+	Interrupted bool
 	ItemStats
 }
 
@@ -62,6 +81,9 @@ func (s *ItemStats) Add(other ItemStats) {
 	s.TreeBlobs += other.TreeBlobs
 	s.TreeSize += other.TreeSize
 	s.TreeSizeInRepo += other.TreeSizeInRepo
+	s.DataSizeFromParent += other.DataSizeFromParent
+	s.DataSizeFromBackup += other.DataSizeFromBackup
+	s.DataSizeFromRepo += other.DataSizeFromRepo
 }
 
 type archiverRepo interface {
@@ -74,12 +96,18 @@ type archiverRepo interface {
 	Flush(ctx context.Context) error
 }
 
+// packRecorder is implemented by repositories which record the pack files
+// they uploaded.
+type packRecorder interface {
+	SavedPacks() []restic.ManifestPack
+}
+
 // Archiver saves a directory structure to the repo.
 type Archiver struct {
 	Repo         archiverRepo
 	SelectByName SelectByNameFunc
 	Select       SelectFunc
-	FS           fs.FS
+	FS           vfs.FS
 	Options      Options
 
 	blobSaver *BlobSaver
@@ -114,10 +142,58 @@ type Archiver struct {
 	// default.
 	WithAtime bool
 
+	// WithEFSMetadata configures if the certificates which can decrypt
+	// EFS-encrypted files should be saved. This is only supported on Windows.
+	WithEFSMetadata bool
+
+	// EFSRaw configures if EFS-encrypted files are saved as the raw encrypted
+	// backup stream, which does not require their key. This is only supported
+	// on Windows.
+	EFSRaw bool
+
+	// OfflinePolicy controls whether offline files are recalled, saved
+	// without their content or skipped. Files are only detected as offline
+	// on Windows.
+	OfflinePolicy OfflinePolicy
+
+	// OfflineRecalls limits the number of offline files which are recalled
+	// concurrently. Zero means no limit.
+	OfflineRecalls uint
+	recalls        chan struct{}
+
+	// hardlinks contains the files with several hardlinks saved during the
+	// current run.
+	hardlinks *hardlinkCache
+
+	// SkipUnchangedDir is called with the absolute path of directories which
+	// are contained in the parent snapshot. If it returns true, the directory
+	// is not read and the subtree of the parent snapshot is used instead.
+	SkipUnchangedDir func(path string) bool
+
 	// Flags controlling change detection. See doc/040_backup.rst for details.
 	ChangeIgnoreFlags uint
+
+	// ChunkMaps stores the chunk maps of files with at least ChunkMapMinSize
+	// bytes. If it is set, such files are only read in the regions which
+	// were modified since the parent snapshot, if the file system records
+	// this information. It is not used if nil.
+	ChunkMaps       *ChunkMaps
+	ChunkMapMinSize uint64
+
+	// Interrupt stops the backup once it is closed. No further files and
+	// directories are read and files which are currently being read are left
+	// out. The snapshot contains the items saved so far and is tagged with
+	// PartialTag.
+	Interrupt <-chan struct{}
 }
 
+// PartialTag is added to snapshots of interrupted backups.
+const PartialTag = "partial"
+
+// errInterrupted is returned for files which were left out because the backup
+// was interrupted.
+var errInterrupted = errors.New("backup was interrupted")
+
 // Flags for the ChangeIgnoreFlags bitfield.
 const (
 	ChangeIgnoreCtime = 1 << iota
@@ -169,13 +245,14 @@ func (o Options) ApplyDefaults() Options {
 	return o
 }
 
-// New initializes a new archiver.
-func New(repo archiverRepo, fs fs.FS, opts Options) *Archiver {
+// New initializes a new archiver. Unless filesystem implements vfs.FS, the
+// metadata of its entries is read from the local file system.
+func New(repo archiverRepo, filesystem fs.FS, opts Options) *Archiver {
 	arch := &Archiver{
 		Repo:         repo,
 		SelectByName: func(_ string) bool { return true },
 		Select:       func(_ string, _ os.FileInfo) bool { return true },
-		FS:           fs,
+		FS:           vfs.Wrap(filesystem),
 		Options:      opts.ApplyDefaults(),
 
 		CompleteItem: func(string, *restic.Node, *restic.Node, ItemStats, time.Duration) {},
@@ -186,6 +263,16 @@ func New(repo archiverRepo, fs fs.FS, opts Options) *Archiver {
 	return arch
 }
 
+// interrupted returns whether arch.Interrupt has been closed.
+func (arch *Archiver) interrupted() bool {
+	select {
+	case <-arch.Interrupt:
+		return true
+	default:
+		return false
+	}
+}
+
 // error calls arch.Error if it is set and the error is different from context.Canceled.
 func (arch *Archiver) error(item string, err error) error {
 	if arch.Error == nil || err == nil {
@@ -205,6 +292,13 @@ func (arch *Archiver) error(item string, err error) error {
 	if err != errf {
 		debug.Log("item %v: error was filtered by handler, before: %q, after: %v", item, err, errf)
 	}
+	if errf == nil {
+		arch.mu.Lock()
+		if arch.summary != nil {
+			arch.summary.Errors++
+		}
+		arch.mu.Unlock()
+	}
 	return errf
 }
 
@@ -235,20 +329,35 @@ func (arch *Archiver) trackItem(item string, previous, current *restic.Node, s I
 		}
 
 	case "file":
+		stats := &arch.summary.Files
+		if restic.ClassifyNode(current.Name) == restic.StreamNode {
+			stats = &arch.summary.Streams
+			arch.summary.StreamBytes += current.Size
+		}
 		switch {
 		case previous == nil:
-			arch.summary.Files.New++
+			stats.New++
 		case previous.Equals(*current):
-			arch.summary.Files.Unchanged++
+			stats.Unchanged++
 		default:
-			arch.summary.Files.Changed++
+			stats.Changed++
 		}
 	}
 }
 
 // nodeFromFileInfo returns the restic node from an os.FileInfo.
 func (arch *Archiver) nodeFromFileInfo(snPath, filename string, fi os.FileInfo, ignoreXattrListError bool) (*restic.Node, error) {
-	node, err := restic.NodeFromFileInfo(filename, fi, ignoreXattrListError)
+	node, err := arch.FS.NodeFromFileInfo(filename, fi, ignoreXattrListError)
+	if local, ok := arch.FS.(vfs.Local); ok {
+		// EFS metadata is only available on the local file system
+		filename = local.RealPath(filename)
+		if err == nil && arch.WithEFSMetadata {
+			err = node.AddEFSMetadata(filename)
+		}
+		if err == nil && node.Type == "file" && arch.efsRaw(fi) {
+			err = node.MarkEFSRaw()
+		}
+	}
 	if !arch.WithAtime {
 		node.AccessTime = node.ModTime
 	}
@@ -304,25 +413,29 @@ func (arch *Archiver) saveDir(ctx context.Context, snPath string, dir string, fi
 		return FutureNode{}, err
 	}
 
-	names, err := readdirnames(arch.FS, dir, fs.O_NOFOLLOW)
+	entries, err := readdir(arch.FS, dir)
 	if err != nil {
 		return FutureNode{}, err
 	}
-	sort.Strings(names)
 
-	nodes := make([]FutureNode, 0, len(names))
+	nodes := make([]FutureNode, 0, len(entries))
 
-	for _, name := range names {
+	for _, fi := range entries {
 		// test if context has been cancelled
 		if ctx.Err() != nil {
 			debug.Log("context has been cancelled, aborting")
 			return FutureNode{}, ctx.Err()
 		}
+		if arch.interrupted() {
+			debug.Log("backup was interrupted, saving %v partially", snPath)
+			break
+		}
 
+		name := fi.Name()
 		pathname := arch.FS.Join(dir, name)
 		oldNode := previous.Find(name)
 		snItem := join(snPath, name)
-		fn, excluded, err := arch.save(ctx, snItem, pathname, oldNode)
+		fn, excluded, err := arch.save(ctx, snItem, pathname, fi, oldNode)
 
 		// return error early if possible
 		if err != nil {
@@ -413,8 +526,9 @@ func (arch *Archiver) allBlobsPresent(previous *restic.Node) bool {
 //
 // Errors and completion needs to be handled by the caller.
 //
-// snPath is the path within the current snapshot.
-func (arch *Archiver) save(ctx context.Context, snPath, target string, previous *restic.Node) (fn FutureNode, excluded bool, err error) {
+// snPath is the path within the current snapshot. fi is the file info of
+// target as returned by Lstat, it is read if fi is nil.
+func (arch *Archiver) save(ctx context.Context, snPath, target string, fi os.FileInfo, previous *restic.Node) (fn FutureNode, excluded bool, err error) {
 	start := time.Now()
 
 	debug.Log("%v target %q, previous %v", snPath, target, previous)
@@ -430,14 +544,16 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 	}
 
 	// get file info and run remaining select functions that require file information
-	fi, err := arch.FS.Lstat(target)
-	if err != nil {
-		debug.Log("lstat() for %v returned error: %v", target, err)
-		err = arch.error(abstarget, err)
+	if fi == nil {
+		fi, err = arch.FS.Lstat(target)
 		if err != nil {
-			return FutureNode{}, false, errors.WithStack(err)
+			debug.Log("lstat() for %v returned error: %v", target, err)
+			err = arch.error(abstarget, err)
+			if err != nil {
+				return FutureNode{}, false, errors.WithStack(err)
+			}
+			return FutureNode{}, true, nil
 		}
-		return FutureNode{}, true, nil
 	}
 	if !arch.Select(abstarget, fi) {
 		debug.Log("%v is excluded", target)
@@ -448,20 +564,57 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 	case fs.IsRegularFile(fi):
 		debug.Log("  %v regular file", target)
 
+		// further links of a file saved during this run reuse its node
+		hardlink, firstLink := arch.hardlinks.lookup(fi)
+		if hardlink != nil && !firstLink {
+			if saved := hardlink.result(); saved != nil && !fileChanged(fi, saved, arch.ChangeIgnoreFlags) &&
+				arch.efsRaw(fi) == (saved.EFSRaw() != nil) {
+				debug.Log("%v is a hardlink of a file saved before, reusing its node", target)
+				node := *saved
+				node.Name = path.Base(snPath)
+				node.Path = target
+				arch.trackItem(snPath, previous, &node, ItemStats{}, time.Since(start))
+				arch.CompleteBlob(node.Size)
+
+				fn = newFutureNodeWithResult(futureNodeResult{
+					snPath: snPath,
+					target: target,
+					node:   &node,
+				})
+				return fn, false, nil
+			}
+		}
+
 		// check if the file has not changed before performing a fopen operation (more expensive, specially
 		// in network filesystems)
-		if previous != nil && !fileChanged(fi, previous, arch.ChangeIgnoreFlags) {
+		// the content of offline stubs is saved once the policy allows
+		// recalling them
+		if previous != nil && !fileChanged(fi, previous, arch.ChangeIgnoreFlags) &&
+			arch.efsRaw(fi) == (previous.EFSRaw() != nil) &&
+			(previous.OfflineStub() == nil || arch.offlineStub(fi)) {
 			if arch.allBlobsPresent(previous) {
 				debug.Log("%v hasn't changed, using old list of blobs", target)
-				arch.trackItem(snPath, previous, previous, ItemStats{}, time.Since(start))
+				arch.trackItem(snPath, previous, previous, ItemStats{DataSizeFromParent: previous.Size}, time.Since(start))
 				arch.CompleteBlob(previous.Size)
 				node, err := arch.nodeFromFileInfo(snPath, target, fi, false)
 				if err != nil {
 					return FutureNode{}, false, err
 				}
+				if previous.OfflineStub() != nil {
+					if err := node.MarkOfflineStub(); err != nil {
+						return FutureNode{}, false, err
+					}
+				}
 
 				// copy list of blobs
 				node.Content = previous.Content
+				node.ContentSHA256 = previous.ContentSHA256
+				// differs from the size of the file for raw EFS streams and
+				// offline stubs
+				node.Size = previous.Size
+				if firstLink {
+					hardlink.finish(node)
+				}
 
 				fn = newFutureNodeWithResult(futureNodeResult{
 					snPath: snPath,
@@ -480,18 +633,56 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 			}
 		}
 
+		release := func() {}
+		if fs.IsOffline(fi) {
+			switch arch.OfflinePolicy {
+			case OfflineSkip:
+				debug.Log("%v is offline, skipping", target)
+				return FutureNode{}, true, nil
+
+			case OfflineMetadata:
+				debug.Log("%v is offline, saving metadata only", target)
+				node, err := arch.nodeFromFileInfo(snPath, target, fi, false)
+				if err != nil {
+					return FutureNode{}, false, err
+				}
+				if err := node.MarkOfflineStub(); err != nil {
+					return FutureNode{}, false, err
+				}
+				arch.trackItem(snPath, previous, node, ItemStats{}, time.Since(start))
+				fn = newFutureNodeWithResult(futureNodeResult{
+					snPath: snPath,
+					target: target,
+					node:   node,
+				})
+				return fn, false, nil
+
+			default:
+				release, err = arch.startRecall(ctx)
+				if err != nil {
+					return FutureNode{}, false, err
+				}
+			}
+		}
+
 		// reopen file and do an fstat() on the open file to check it is still
 		// a file (and has not been exchanged for e.g. a symlink)
 		file, err := arch.FS.OpenFile(target, fs.O_RDONLY|fs.O_NOFOLLOW, 0)
 		if err != nil {
+			release()
 			debug.Log("Openfile() for %v returned error: %v", target, err)
-			err = arch.error(abstarget, err)
+			err = arch.error(abstarget, fs.WithLockOwners(abstarget, err))
 			if err != nil {
 				return FutureNode{}, false, errors.WithStack(err)
 			}
 			return FutureNode{}, true, nil
 		}
 
+		if fs.IsOffline(fi) {
+			// the file is closed once it was read
+			file = &recallFile{File: file, release: release}
+		}
+
 		fi, err = file.Stat()
 		if err != nil {
 			debug.Log("stat() on opened file %v returned error: %v", target, err)
@@ -514,19 +705,63 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 			return FutureNode{}, true, nil
 		}
 
+		if arch.efsRaw(fi) {
+			raw, err := fs.OpenEncryptedRaw(file)
+			if err != nil {
+				debug.Log("OpenEncryptedRaw() for %v returned error: %v", target, err)
+				_ = file.Close()
+				err = arch.error(abstarget, err)
+				if err != nil {
+					return FutureNode{}, false, errors.WithStack(err)
+				}
+				return FutureNode{}, true, nil
+			}
+			file = raw
+		}
+
+		// large files are only read in the regions which were modified
+		// since the parent snapshot
+		var cm *chunkMapJob
+		if arch.ChunkMaps != nil && uint64(fi.Size()) >= arch.ChunkMapMinSize &&
+			!arch.efsRaw(fi) && !fs.IsOffline(fi) {
+			cm = &chunkMapJob{path: abstarget}
+			cm.previous, cm.base = arch.chunkMapBase(abstarget, fi, previous)
+		}
+
 		// Save will close the file, we don't need to do that
-		fn = arch.fileSaver.Save(ctx, snPath, target, file, fi, func() {
+		fn = arch.fileSaver.saveWithChunkMap(ctx, snPath, target, file, fi, previous, cm, func() {
 			arch.StartFile(snPath)
 		}, func() {
 			arch.trackItem(snPath, nil, nil, ItemStats{}, 0)
 		}, func(node *restic.Node, stats ItemStats) {
+			if firstLink {
+				hardlink.finish(node)
+			}
 			arch.trackItem(snPath, previous, node, stats, time.Since(start))
 		})
 
-	case fi.IsDir():
+	case fi.IsDir() && !fs.IsMountPoint(target, fi):
 		debug.Log("  %v dir", target)
 
 		snItem := snPath + "/"
+		if previous != nil && previous.Type == "dir" && previous.Subtree != nil &&
+			arch.SkipUnchangedDir != nil && arch.SkipUnchangedDir(abstarget) {
+			debug.Log("%v is unchanged, using old subtree", target)
+			node, err := arch.nodeFromFileInfo(snPath, target, fi, false)
+			if err != nil {
+				return FutureNode{}, false, err
+			}
+			node.Subtree = previous.Subtree
+			arch.trackItem(snItem, previous, node, ItemStats{}, time.Since(start))
+
+			fn = newFutureNodeWithResult(futureNodeResult{
+				snPath: snPath,
+				target: target,
+				node:   node,
+			})
+			return fn, false, nil
+		}
+
 		oldSubtree, err := arch.loadSubtree(ctx, previous)
 		if err != nil {
 			err = arch.error(abstarget, err)
@@ -567,6 +802,49 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 	return fn, false, nil
 }
 
+// efsRaw returns whether the file described by fi is saved as the raw
+// encrypted backup stream.
+func (arch *Archiver) efsRaw(fi os.FileInfo) bool {
+	if _, ok := arch.FS.(vfs.Local); !ok {
+		return false
+	}
+	return arch.EFSRaw && fs.IsEncrypted(fi)
+}
+
+// fileSize returns the size of the file described by node, which differs from
+// the size of its content for raw EFS streams and offline stubs.
+func fileSize(node *restic.Node) uint64 {
+	if info := node.EFSRaw(); info != nil {
+		return info.Size
+	}
+	if info := node.OfflineStub(); info != nil {
+		return info.Size
+	}
+	return node.Size
+}
+
+// chunkMapBase returns the previous version of the file at abstarget together
+// with its chunk map, if the chunks of the previous version can be reused.
+func (arch *Archiver) chunkMapBase(abstarget string, fi os.FileInfo, previous *restic.Node) (*restic.Node, *ChunkMap) {
+	if previous == nil || previous.Type != "file" || previous.EFSRaw() != nil || previous.OfflineStub() != nil {
+		return nil, nil
+	}
+
+	base := arch.ChunkMaps.Get(abstarget)
+	if base == nil || !base.matches(previous) {
+		return nil, nil
+	}
+	// the block maps of different files cannot be compared
+	deviceID, inode, ok := fileID(fi)
+	if !ok || base.DeviceID != deviceID || base.Inode != inode {
+		return nil, nil
+	}
+	if !arch.allBlobsPresent(previous) {
+		return nil, nil
+	}
+	return previous, base
+}
+
 // fileChanged tries to detect whether a file's content has changed compared
 // to the contents of node, which describes the same path in the parent backup.
 // It should only be run for regular files.
@@ -577,7 +855,7 @@ func fileChanged(fi os.FileInfo, node *restic.Node, ignoreFlags uint) bool {
 	case node.Type != "file":
 		// We're only called for regular files, so this is a type change.
 		return true
-	case uint64(fi.Size()) != node.Size:
+	case uint64(fi.Size()) != fileSize(node):
 		return true
 	case !fi.ModTime().Equal(node.ModTime):
 		return true
@@ -586,11 +864,21 @@ func fileChanged(fi os.FileInfo, node *restic.Node, ignoreFlags uint) bool {
 	checkCtime := ignoreFlags&ChangeIgnoreCtime == 0
 	checkInode := ignoreFlags&ChangeIgnoreInode == 0
 
-	extFI := fs.ExtendedStat(fi)
+	var changeTime time.Time
+	var inode uint64
+	if current, ok := fi.Sys().(*restic.Node); ok {
+		// the file info was returned by a vfs.FS
+		changeTime, inode = current.ChangeTime, current.Inode
+	} else {
+		extFI := fs.ExtendedStat(fi)
+		changeTime, inode = extFI.ChangeTime, extFI.Inode
+	}
+
+	// the inode is unknown if the file info was returned by Lstat on Windows
 	switch {
-	case checkCtime && !extFI.ChangeTime.Equal(node.ChangeTime):
+	case checkCtime && !changeTime.Equal(node.ChangeTime):
 		return true
-	case checkInode && node.Inode != extFI.Inode:
+	case checkInode && inode != 0 && node.Inode != inode:
 		return true
 	}
 
@@ -657,10 +945,14 @@ func (arch *Archiver) saveTree(ctx context.Context, snPath string, atree *Tree,
 		if ctx.Err() != nil {
 			return FutureNode{}, 0, ctx.Err()
 		}
+		if arch.interrupted() {
+			debug.Log("backup was interrupted, saving %v partially", snPath)
+			break
+		}
 
 		// this is a leaf node
 		if subatree.Leaf() {
-			fn, excluded, err := arch.save(ctx, join(snPath, name), subatree.Path, previous.Find(name))
+			fn, excluded, err := arch.save(ctx, join(snPath, name), subatree.Path, nil, previous.Find(name))
 
 			if err != nil {
 				err = arch.error(subatree.Path, err)
@@ -728,10 +1020,45 @@ func readdirnames(filesystem fs.FS, dir string, flags int) ([]string, error) {
 	return entries, nil
 }
 
+// readdir returns the entries of the directory dir together with the
+// alternate data streams of its files, which are named "file:stream". The
+// entries are sorted by name.
+func readdir(filesystem vfs.FS, dir string) ([]os.FileInfo, error) {
+	entries, err := filesystem.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := len(entries)
+	for _, fi := range entries[:files] {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		streams, err := filesystem.Streams(filesystem.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, stream := range streams {
+			streamFI, err := filesystem.Lstat(filesystem.Join(dir, fi.Name()+":"+stream))
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, streamFI)
+		}
+	}
+
+	if len(entries) > files {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+	}
+	return entries, nil
+}
+
 // resolveRelativeTargets replaces targets that only contain relative
 // directories ("." or "../../") with the contents of the directory. Each
 // element of target is processed with fs.Clean().
-func resolveRelativeTargets(filesys fs.FS, targets []string) ([]string, error) {
+func resolveRelativeTargets(filesys vfs.FS, targets []string) ([]string, error) {
 	debug.Log("targets before resolving: %v", targets)
 	result := make([]string, 0, len(targets))
 	for _, target := range targets {
@@ -743,14 +1070,13 @@ func resolveRelativeTargets(filesys fs.FS, targets []string) ([]string, error) {
 		}
 
 		debug.Log("replacing %q with readdir(%q)", target, target)
-		entries, err := readdirnames(filesys, target, fs.O_NOFOLLOW)
+		entries, err := filesys.ReadDir(target)
 		if err != nil {
 			return nil, err
 		}
-		sort.Strings(entries)
 
-		for _, name := range entries {
-			result = append(result, filesys.Join(target, name))
+		for _, fi := range entries {
+			result = append(result, filesys.Join(target, fi.Name()))
 		}
 	}
 
@@ -769,6 +1095,17 @@ type SnapshotOptions struct {
 	ProgramVersion string
 	// SkipIfUnchanged omits the snapshot creation if it is identical to the parent snapshot.
 	SkipIfUnchanged bool
+	// Volumes contains the metadata of volumes whose root is backed up.
+	Volumes []fs.VolumeInfo
+	// ExpireAfter sets the expiry time of the snapshot relative to its time,
+	// if not zero.
+	ExpireAfter restic.Duration
+	// ChangeJournals is called after all files were saved and returns the
+	// change journal positions to store in the snapshot.
+	ChangeJournals func() []fs.ChangeJournal
+	// Catalog stores a catalog of all paths of the snapshot, which speeds up
+	// queries for huge snapshots.
+	Catalog bool
 }
 
 // loadParentTree loads a tree referenced by snapshot id. If id is null, nil is returned.
@@ -802,8 +1139,22 @@ func (arch *Archiver) runWorkers(ctx context.Context, wg *errgroup.Group) {
 		arch.Options.ReadConcurrency, arch.Options.SaveBlobConcurrency)
 	arch.fileSaver.CompleteBlob = arch.CompleteBlob
 	arch.fileSaver.NodeFromFileInfo = arch.nodeFromFileInfo
+	arch.fileSaver.ChunkMaps = arch.ChunkMaps
+	arch.fileSaver.Interrupt = arch.Interrupt
 
 	arch.treeSaver = NewTreeSaver(ctx, wg, arch.Options.SaveTreeConcurrency, arch.blobSaver.Save, arch.Error)
+	arch.treeSaver.ResticCompat = arch.Repo.Config().ResticCompat
+
+	arch.recalls = nil
+	if arch.OfflinePolicy == OfflineRecall && arch.OfflineRecalls > 0 {
+		arch.recalls = make(chan struct{}, arch.OfflineRecalls)
+	}
+
+	// inodes cannot identify files if they are not stable
+	arch.hardlinks = nil
+	if arch.ChangeIgnoreFlags&ChangeIgnoreInode == 0 {
+		arch.hardlinks = newHardlinkCache()
+	}
 }
 
 func (arch *Archiver) stopWorkers() {
@@ -881,6 +1232,7 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	if err != nil {
 		return nil, restic.ID{}, nil, err
 	}
+	arch.summary.Interrupted = arch.interrupted()
 
 	if opts.ParentSnapshot != nil && opts.SkipIfUnchanged {
 		ps := opts.ParentSnapshot
@@ -889,17 +1241,43 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 		}
 	}
 
-	sn, err := restic.NewSnapshot(targets, opts.Tags, opts.Hostname, opts.Time)
+	var catalogID *restic.ID
+	if opts.Catalog {
+		id, err := arch.saveCatalog(ctx, rootTreeID)
+		if err != nil {
+			return nil, restic.ID{}, nil, err
+		}
+		catalogID = &id
+	}
+
+	tags := opts.Tags
+	if arch.summary.Interrupted {
+		tags = append(append(restic.TagList{}, tags...), PartialTag)
+	}
+
+	sn, err := restic.NewSnapshot(targets, tags, opts.Hostname, opts.Time)
 	if err != nil {
 		return nil, restic.ID{}, nil, err
 	}
 
 	sn.ProgramVersion = opts.ProgramVersion
 	sn.Excludes = opts.Excludes
+	sn.Volumes = opts.Volumes
+	if opts.ChangeJournals != nil {
+		sn.ChangeJournals = opts.ChangeJournals()
+	}
+	if !opts.ExpireAfter.Zero() {
+		expires := opts.ExpireAfter.AddTo(sn.Time)
+		sn.Expires = &expires
+	}
 	if opts.ParentSnapshot != nil {
 		sn.Parent = opts.ParentSnapshot.ID()
 	}
 	sn.Tree = &rootTreeID
+	sn.Catalog = catalogID
+	if r, ok := arch.Repo.(packRecorder); ok {
+		sn.Manifest = r.SavedPacks()
+	}
 	sn.Summary = &restic.SnapshotSummary{
 		BackupStart: opts.BackupStart,
 		BackupEnd:   time.Now(),
@@ -916,6 +1294,10 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 		DataAddedPacked:     arch.summary.ItemStats.DataSizeInRepo + arch.summary.ItemStats.TreeSizeInRepo,
 		TotalFilesProcessed: arch.summary.Files.New + arch.summary.Files.Changed + arch.summary.Files.Unchanged,
 		TotalBytesProcessed: arch.summary.ProcessedBytes,
+		DataFromParent:      arch.summary.ItemStats.DataSizeFromParent,
+		DataFromBackup:      arch.summary.ItemStats.DataSizeFromBackup,
+		DataFromRepo:        arch.summary.ItemStats.DataSizeFromRepo,
+		Errors:              arch.summary.Errors,
 	}
 
 	id, err := restic.SaveSnapshot(ctx, arch.Repo, sn)
@@ -925,3 +1307,20 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 
 	return sn, id, arch.summary, nil
 }
+
+// saveCatalog builds and saves the catalog of the tree, see package catalog.
+func (arch *Archiver) saveCatalog(ctx context.Context, tree restic.ID) (restic.ID, error) {
+	var id restic.ID
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	arch.Repo.StartPackUploader(wgCtx, wg)
+	wg.Go(func() error {
+		var err error
+		id, err = catalog.Build(wgCtx, arch.Repo, tree)
+		if err != nil {
+			return errors.Wrap(err, "catalog")
+		}
+		return arch.Repo.Flush(wgCtx)
+	})
+	return id, wg.Wait()
+}
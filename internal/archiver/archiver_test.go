@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -229,7 +230,7 @@ func TestArchiverSave(t *testing.T) {
 			arch.runWorkers(ctx, wg)
 			arch.summary = &Summary{}
 
-			node, excluded, err := arch.save(ctx, "/", filepath.Join(tempdir, "file"), nil)
+			node, excluded, err := arch.save(ctx, "/", filepath.Join(tempdir, "file"), nil, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -307,7 +308,7 @@ func TestArchiverSaveReaderFS(t *testing.T) {
 			arch.runWorkers(ctx, wg)
 			arch.summary = &Summary{}
 
-			node, excluded, err := arch.save(ctx, "/", filename, nil)
+			node, excluded, err := arch.save(ctx, "/", filename, nil, nil)
 			t.Logf("Save returned %v %v", node, err)
 			if err != nil {
 				t.Fatal(err)
@@ -733,6 +734,28 @@ func TestFilChangedSpecialCases(t *testing.T) {
 			t.Fatal("node with changed type detected as unchanged")
 		}
 	})
+
+	t.Run("efs-raw", func(t *testing.T) {
+		fi := lstat(t, filename)
+		node := nodeFromFI(t, filename, fi)
+		rtest.OK(t, node.MarkEFSRaw())
+		// the raw stream is larger than the file
+		node.Size += 1024
+		if fileChanged(fi, node, 0) {
+			t.Fatal("unchanged raw EFS file detected as changed")
+		}
+	})
+
+	t.Run("offline-stub", func(t *testing.T) {
+		fi := lstat(t, filename)
+		node := nodeFromFI(t, filename, fi)
+		rtest.OK(t, node.MarkOfflineStub())
+		rtest.Equals(t, uint64(0), node.Size)
+		rtest.Equals(t, uint64(len(content)), node.OfflineStub().Size)
+		if fileChanged(fi, node, 0) {
+			t.Fatal("unchanged offline stub detected as changed")
+		}
+	})
 }
 
 func TestArchiverSaveDir(t *testing.T) {
@@ -1020,7 +1043,7 @@ func TestArchiverSaveTree(t *testing.T) {
 				"targetfile": TestFile{Content: string("foobar")},
 			},
 			stat: Summary{
-				ItemStats:      ItemStats{1, 6, 32 + 6, 0, 0, 0},
+				ItemStats:      ItemStats{1, 6, 32 + 6, 0, 0, 0, 0, 0, 0},
 				ProcessedBytes: 6,
 				Files:          ChangeStats{1, 0, 0},
 				Dirs:           ChangeStats{0, 0, 0},
@@ -1037,7 +1060,7 @@ func TestArchiverSaveTree(t *testing.T) {
 				"filesymlink": TestSymlink{Target: "targetfile"},
 			},
 			stat: Summary{
-				ItemStats:      ItemStats{1, 6, 32 + 6, 0, 0, 0},
+				ItemStats:      ItemStats{1, 6, 32 + 6, 0, 0, 0, 0, 0, 0},
 				ProcessedBytes: 6,
 				Files:          ChangeStats{1, 0, 0},
 				Dirs:           ChangeStats{0, 0, 0},
@@ -1062,7 +1085,7 @@ func TestArchiverSaveTree(t *testing.T) {
 				},
 			},
 			stat: Summary{
-				ItemStats:      ItemStats{0, 0, 0, 1, 0x154, 0x16a},
+				ItemStats:      ItemStats{0, 0, 0, 1, 0x154, 0x16a, 0, 0, 0},
 				ProcessedBytes: 0,
 				Files:          ChangeStats{0, 0, 0},
 				Dirs:           ChangeStats{1, 0, 0},
@@ -1091,7 +1114,7 @@ func TestArchiverSaveTree(t *testing.T) {
 				},
 			},
 			stat: Summary{
-				ItemStats:      ItemStats{1, 6, 32 + 6, 3, 0x47f, 0x4c1},
+				ItemStats:      ItemStats{1, 6, 32 + 6, 3, 0x47f, 0x4c1, 0, 0, 0},
 				ProcessedBytes: 6,
 				Files:          ChangeStats{1, 0, 0},
 				Dirs:           ChangeStats{3, 0, 0},
@@ -1668,7 +1691,7 @@ func TestArchiverParent(t *testing.T) {
 				Files:          ChangeStats{1, 0, 0},
 				Dirs:           ChangeStats{0, 0, 0},
 				ProcessedBytes: 2102152,
-				ItemStats:      ItemStats{3, 0x201593, 0x201632, 1, 0, 0},
+				ItemStats:      ItemStats{3, 0x201593, 0x201632, 1, 0, 0, 0, 0, 0},
 			},
 			statSecond: Summary{
 				Files:          ChangeStats{0, 0, 1},
@@ -1687,7 +1710,7 @@ func TestArchiverParent(t *testing.T) {
 				Files:          ChangeStats{2, 0, 0},
 				Dirs:           ChangeStats{1, 0, 0},
 				ProcessedBytes: 2469,
-				ItemStats:      ItemStats{2, 0xe1c, 0xcd9, 2, 0, 0},
+				ItemStats:      ItemStats{2, 0xe1c, 0xcd9, 2, 0, 0, 0, 0, 0},
 			},
 			statSecond: Summary{
 				Files:          ChangeStats{0, 0, 2},
@@ -1710,13 +1733,13 @@ func TestArchiverParent(t *testing.T) {
 				Files:          ChangeStats{2, 0, 0},
 				Dirs:           ChangeStats{1, 0, 0},
 				ProcessedBytes: 2469,
-				ItemStats:      ItemStats{2, 0xe13, 0xcf8, 2, 0, 0},
+				ItemStats:      ItemStats{2, 0xe13, 0xcf8, 2, 0, 0, 0, 0, 0},
 			},
 			statSecond: Summary{
 				Files:          ChangeStats{0, 1, 0},
 				Dirs:           ChangeStats{0, 1, 0},
 				ProcessedBytes: 6,
-				ItemStats:      ItemStats{1, 0x305, 0x233, 2, 0, 0},
+				ItemStats:      ItemStats{1, 0x305, 0x233, 2, 0, 0, 0, 0, 0},
 			},
 		},
 	}
@@ -1799,6 +1822,83 @@ func TestArchiverParent(t *testing.T) {
 	}
 }
 
+func TestArchiverSkipUnchangedDir(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tempdir, repo := prepareTempdirRepoSrc(t, TestDir{
+		"unchanged": TestDir{"file": TestFile{Content: "foo"}},
+		"changed":   TestDir{"file": TestFile{Content: "bar"}},
+	})
+
+	testFS := &MockFS{
+		FS:        fs.Track{FS: fs.Local{}},
+		bytesRead: make(map[string]int),
+	}
+	arch := New(repo, testFS, Options{})
+
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	firstSnapshot, _, _, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+
+	// both files are modified, but only the change in "changed" is known
+	save(t, filepath.Join(tempdir, "unchanged", "file"), []byte("modified"))
+	save(t, filepath.Join(tempdir, "changed", "file"), []byte("modified"))
+	arch.SkipUnchangedDir = func(path string) bool {
+		return filepath.Base(path) == "unchanged"
+	}
+
+	journals := []fs.ChangeJournal{{Volume: "C:\\", ID: 1, USN: 42}}
+	testFS.bytesRead = map[string]int{}
+	sn, _, summary, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{
+		Time:           time.Now(),
+		ParentSnapshot: firstSnapshot,
+		ChangeJournals: func() []fs.ChangeJournal { return journals },
+	})
+	rtest.OK(t, err)
+
+	rtest.Equals(t, journals, sn.ChangeJournals)
+	rtest.Equals(t, map[string]int{filepath.Join("changed", "file"): 8}, testFS.bytesRead)
+	rtest.Equals(t, ChangeStats{0, 1, 0}, summary.Files)
+	rtest.Equals(t, ChangeStats{0, 1, 1}, summary.Dirs)
+	checker.TestCheckRepo(t, repo, false)
+}
+
+func TestArchiverDedupStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	content := string(rtest.Random(23, 5000))
+	tempdir, repo := prepareTempdirRepoSrc(t, TestDir{
+		"a": TestFile{Content: content},
+		"b": TestFile{Content: content},
+	})
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	// a single blob saver reports the blobs in order
+	arch := New(repo, fs.Track{FS: fs.Local{}}, Options{SaveBlobConcurrency: 1})
+	sn, _, summary, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(0), summary.DataSizeFromParent)
+	rtest.Equals(t, uint64(len(content)), summary.DataSizeFromBackup)
+	rtest.Equals(t, uint64(0), summary.DataSizeFromRepo)
+
+	// a is unchanged, b was modified and c is a copy of a
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "b"), []byte("modified"), 0644))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "c"), []byte(content), 0644))
+
+	arch = New(repo, fs.Track{FS: fs.Local{}}, Options{SaveBlobConcurrency: 1})
+	sn, _, summary, err = arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), ParentSnapshot: sn})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(len(content)), summary.DataSizeFromParent)
+	rtest.Equals(t, uint64(0), summary.DataSizeFromBackup)
+	rtest.Equals(t, uint64(len(content)), summary.DataSizeFromRepo)
+	rtest.Equals(t, uint64(len(content)), sn.Summary.DataFromRepo)
+}
+
 func TestArchiverErrorReporting(t *testing.T) {
 	ignoreErrorForBasename := func(basename string) ErrorFunc {
 		return func(item string, err error) error {
@@ -1990,6 +2090,41 @@ func TestArchiverContextCanceled(t *testing.T) {
 	t.Fatalf("expected error not returned by archiver")
 }
 
+func TestArchiverInterrupt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tempdir, repo := prepareTempdirRepoSrc(t, TestDir{
+		"a": TestFile{Content: "foo"},
+		"b": TestFile{Content: "bar"},
+		"c": TestFile{Content: "baz"},
+	})
+
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	// read one file at a time, such that "a" is complete once "b" is started
+	arch := New(repo, fs.Track{FS: fs.Local{}}, Options{ReadConcurrency: 1})
+	interrupt := make(chan struct{})
+	var once sync.Once
+	arch.StartFile = func(item string) {
+		if path.Base(item) == "b" {
+			once.Do(func() { close(interrupt) })
+		}
+	}
+	arch.Interrupt = interrupt
+
+	sn, snapshotID, summary, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), Tags: restic.TagList{"nightly"}})
+	rtest.OK(t, err)
+
+	rtest.Assert(t, summary.Interrupted, "summary does not report the interruption")
+	rtest.Equals(t, []string{"nightly", PartialTag}, sn.Tags)
+	TestEnsureSnapshot(t, repo, snapshotID, TestDir{
+		"a": TestFile{Content: "foo"},
+	})
+	checker.TestCheckRepo(t, repo, false)
+}
+
 // TrackFS keeps track which files are opened. For some files, an error is injected.
 type TrackFS struct {
 	fs.FS
@@ -2247,6 +2382,8 @@ func TestMetadataChanged(t *testing.T) {
 	// set some values so we can then compare the nodes
 	want.DeviceID = 0
 	want.Content = node2.Content
+	contentHash := restic.Hash([]byte("foo bar test file"))
+	want.ContentSHA256 = &contentHash
 	want.Path = ""
 	if len(want.ExtendedAttributes) == 0 {
 		want.ExtendedAttributes = nil
@@ -2329,7 +2466,7 @@ func TestRacyFileSwap(t *testing.T) {
 	arch.runWorkers(ctx, wg)
 
 	// fs.Track will panic if the file was not closed
-	_, excluded, err := arch.save(ctx, "/", tempfile, nil)
+	_, excluded, err := arch.save(ctx, "/", tempfile, nil, nil)
 	if err == nil {
 		t.Errorf("Save() should have failed")
 	}
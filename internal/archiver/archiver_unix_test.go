@@ -4,7 +4,9 @@
 package archiver
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"syscall"
 	"testing"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
 )
 
 type wrappedFileInfo struct {
@@ -87,3 +90,48 @@ func TestHardlinkMetadata(t *testing.T) {
 	_, node = statAndSnapshot(t, repo, "testdir")
 	rtest.Assert(t, node.DeviceID == 0, "device id mismatch for testdir expected %v got %v", 0, node.DeviceID)
 }
+
+func TestArchiverHardlinkReuse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	files := TestDir{
+		"a": TestDir{
+			"file": TestFile{Content: "hardlinked file content"},
+		},
+		"b": TestDir{
+			"link": TestHardlink{Target: "../a/file"},
+		},
+	}
+	tempdir, repo := prepareTempdirRepoSrc(t, files)
+
+	wg, ctx := errgroup.WithContext(ctx)
+	repo.StartPackUploader(ctx, wg)
+
+	testFS := &TrackFS{FS: fs.Local{}, opened: make(map[string]uint)}
+	arch := New(repo, testFS, Options{})
+	arch.runWorkers(ctx, wg)
+	arch.summary = &Summary{}
+
+	save := func(name string) *restic.Node {
+		fn, excluded, err := arch.save(ctx, "/"+name, filepath.Join(tempdir, filepath.FromSlash(name)), nil, nil)
+		rtest.OK(t, err)
+		rtest.Assert(t, !excluded, "%v was excluded", name)
+		fnr := fn.take(ctx)
+		rtest.OK(t, fnr.err)
+		return fnr.node
+	}
+
+	file := save("a/file")
+	link := save("b/link")
+
+	arch.stopWorkers()
+	rtest.OK(t, repo.Flush(ctx))
+
+	rtest.Equals(t, uint(1), testFS.opened[filepath.Join(tempdir, "a", "file")])
+	rtest.Equals(t, uint(0), testFS.opened[filepath.Join(tempdir, "b", "link")])
+	rtest.Equals(t, "link", link.Name)
+	rtest.Equals(t, file.Inode, link.Inode)
+	rtest.Equals(t, file.Content, link.Content)
+	TestEnsureFileContent(ctx, t, repo, "b/link", link, files["a"].(TestDir)["file"].(TestFile))
+}
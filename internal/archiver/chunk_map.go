@@ -0,0 +1,158 @@
+package archiver
+
+import (
+	"crypto/sha256"
+	"os"
+	"sync"
+
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ChunkMap records how a large file was split into chunks, together with the
+// block map of the file at the time it was read. The next backup compares the
+// block maps to find the modified regions of the file and only reads and
+// chunks these regions again.
+type ChunkMap struct {
+	Size     uint64 `json:"size"`
+	DeviceID uint64 `json:"device_id"`
+	Inode    uint64 `json:"inode"`
+	// Content is the hash of the list of blobs of the file, it identifies
+	// the node in the snapshot the chunk map belongs to.
+	Content restic.ID `json:"content"`
+	// Lengths contains the length of each blob in the content of the file.
+	Lengths []uint32     `json:"lengths"`
+	Blocks  *fs.BlockMap `json:"blocks"`
+}
+
+// fileID returns the device and inode of the file described by fi. It
+// returns false if fi does not describe a file of the local file system.
+func fileID(fi os.FileInfo) (deviceID, inode uint64, ok bool) {
+	switch fi.Sys().(type) {
+	case nil, *restic.Node:
+		return 0, 0, false
+	}
+	extFI := fs.ExtendedStat(fi)
+	return extFI.DeviceID, extFI.Inode, true
+}
+
+// contentHash returns the hash identifying the list of blobs content.
+func contentHash(content restic.IDs) restic.ID {
+	h := sha256.New()
+	for _, id := range content {
+		_, _ = h.Write(id[:])
+	}
+	return restic.IDFromHash(h.Sum(nil))
+}
+
+// matches returns true if the chunk map describes the content of node.
+func (m *ChunkMap) matches(node *restic.Node) bool {
+	if m.Blocks == nil || m.Size != node.Size || len(m.Lengths) != len(node.Content) {
+		return false
+	}
+	var size uint64
+	for _, length := range m.Lengths {
+		size += uint64(length)
+	}
+	return size == m.Size && m.Content.Equal(contentHash(node.Content))
+}
+
+// ChunkMaps stores the chunk maps of files, indexed by their absolute path.
+// It is safe for concurrent use.
+type ChunkMaps struct {
+	mu    sync.Mutex
+	files map[string]*ChunkMap
+}
+
+// NewChunkMaps returns a ChunkMaps containing files, which may be nil.
+func NewChunkMaps(files map[string]*ChunkMap) *ChunkMaps {
+	if files == nil {
+		files = make(map[string]*ChunkMap)
+	}
+	return &ChunkMaps{files: files}
+}
+
+// Get returns the chunk map of the file at path, or nil.
+func (m *ChunkMaps) Get(path string) *ChunkMap {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.files[path]
+}
+
+// Put stores the chunk map of the file at path. A nil chunk map removes the
+// stored one.
+func (m *ChunkMaps) Put(path string, cm *ChunkMap) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cm == nil {
+		delete(m.files, path)
+		return
+	}
+	m.files[path] = cm
+}
+
+// Files returns a copy of all stored chunk maps.
+func (m *ChunkMaps) Files() map[string]*ChunkMap {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make(map[string]*ChunkMap, len(m.files))
+	for path, cm := range m.files {
+		files[path] = cm
+	}
+	return files
+}
+
+// chunkPlan decides which chunks of the previous version of a file can be
+// reused while saving its current version.
+type chunkPlan struct {
+	content restic.IDs
+	// starts contains the offset of each chunk and the size of the previous
+	// version of the file as last element.
+	starts   []uint64
+	reusable []bool
+	next     int
+}
+
+// newChunkPlan returns the plan for a file of size bytes, whose regions
+// changed have been modified since the chunk map base was recorded for the
+// previous version with the given content.
+//
+// A chunk is reused if it is not modified and ends within the file. The last
+// chunk of the previous version is only reused if the size did not change, as
+// it ends at the end of the file instead of at a chunk boundary.
+func newChunkPlan(base *ChunkMap, content restic.IDs, changed []fs.Range, size uint64) *chunkPlan {
+	p := &chunkPlan{
+		content:  content,
+		starts:   make([]uint64, len(content)+1),
+		reusable: make([]bool, len(content)),
+	}
+
+	var pos uint64
+	for i, length := range base.Lengths {
+		start, end := pos, pos+uint64(length)
+		p.starts[i] = start
+		pos = end
+
+		for len(changed) > 0 && changed[0].End <= start {
+			changed = changed[1:]
+		}
+		modified := len(changed) > 0 && changed[0].Start < end
+		last := i == len(base.Lengths)-1
+		p.reusable[i] = !modified && end <= size && (!last || base.Size == size)
+	}
+	p.starts[len(content)] = pos
+	return p
+}
+
+// reuse returns the blob of the previous version which starts at offset and
+// can be reused for the current version.
+func (p *chunkPlan) reuse(offset uint64) (restic.ID, uint32, bool) {
+	for p.next < len(p.content) && p.starts[p.next] < offset {
+		p.next++
+	}
+	if p.next == len(p.content) || p.starts[p.next] != offset || !p.reusable[p.next] {
+		return restic.ID{}, 0, false
+	}
+	i := p.next
+	return p.content[i], uint32(p.starts[i+1] - p.starts[i]), true
+}
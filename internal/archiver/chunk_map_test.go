@@ -0,0 +1,146 @@
+package archiver
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// countingFile counts the bytes read from a file.
+type countingFile struct {
+	fs.File
+	read *int64
+}
+
+func (f countingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	atomic.AddInt64(f.read, int64(n))
+	return n, err
+}
+
+func saveFileWithChunkMap(ctx context.Context, t *testing.T, s *FileSaver, filename string, cm *chunkMapJob) (*restic.Node, int64) {
+	f, err := fs.Local{}.Open(filename)
+	rtest.OK(t, err)
+	fi, err := f.Stat()
+	rtest.OK(t, err)
+
+	var read int64
+	fn := s.saveWithChunkMap(ctx, "/file", filename, countingFile{f, &read}, fi, nil, cm, func() {}, func() {}, func(*restic.Node, ItemStats) {})
+	fnr := fn.take(ctx)
+	rtest.OK(t, fnr.err)
+	return fnr.node, read
+}
+
+// extentMap returns a block map with one extent per MiB of a file of the given
+// size. Extents starting at the offsets in modified have generation 2.
+func extentMap(size int64, modified ...int64) *fs.BlockMap {
+	m := &fs.BlockMap{}
+	for offset := int64(0); offset < size; offset += 1 << 20 {
+		e := fs.Extent{Offset: uint64(offset), Length: 1 << 20, Physical: uint64(offset), Generation: 1}
+		for _, mod := range modified {
+			if mod == offset {
+				e.Generation = 2
+			}
+		}
+		m.Extents = append(m.Extents, e)
+	}
+	return m
+}
+
+func TestFileSaverChunkMap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, ctx, wg := startFileSaver(ctx, t)
+	s.ChunkMaps = NewChunkMaps(nil)
+	var blocks *fs.BlockMap
+	s.ReadBlockMap = func(fs.File, os.FileInfo) (*fs.BlockMap, error) {
+		if blocks == nil {
+			return nil, fs.ErrBlockMapUnavailable
+		}
+		return blocks, nil
+	}
+
+	const size = 24 << 20
+	data := make([]byte, size)
+	rnd := rand.New(rand.NewSource(23))
+	_, _ = rnd.Read(data)
+	filename := filepath.Join(rtest.TempDir(t), "file")
+	rtest.OK(t, os.WriteFile(filename, data, 0o600))
+
+	// the first backup records the chunk map
+	blocks = extentMap(size)
+	node, read := saveFileWithChunkMap(ctx, t, s, filename, &chunkMapJob{path: filename})
+	rtest.Equals(t, int64(size), read)
+	base := s.ChunkMaps.Get(filename)
+	rtest.Assert(t, base != nil, "chunk map was not stored")
+	rtest.Assert(t, base.matches(node), "chunk map does not match node")
+
+	// modify the file within the extent at 10 MiB and append data
+	_, _ = rnd.Read(data[10<<20+1234 : 10<<20+5678])
+	data = append(data, make([]byte, 1<<20)...)
+	rtest.OK(t, os.WriteFile(filename, data, 0o600))
+	blocks = extentMap(int64(len(data)), 10<<20, 24<<20)
+
+	changed, read := saveFileWithChunkMap(ctx, t, s, filename, &chunkMapJob{path: filename, previous: node, base: base})
+	rtest.Assert(t, read < int64(len(data))/2, "read %d of %d bytes", read, len(data))
+	rtest.Assert(t, changed.ContentSHA256 == nil, "content hash of a partially read file")
+
+	// the content must be identical to that of a full backup
+	full, read := saveFileWithChunkMap(ctx, t, s, filename, nil)
+	rtest.Equals(t, int64(len(data)), read)
+	rtest.Equals(t, full.Content, changed.Content)
+	rtest.Equals(t, full.Size, changed.Size)
+	rtest.Assert(t, s.ChunkMaps.Get(filename).matches(changed), "chunk map was not updated")
+
+	// without block map, the file is read completely and the chunk map is
+	// removed
+	blocks = nil
+	_, read = saveFileWithChunkMap(ctx, t, s, filename, &chunkMapJob{path: filename, previous: changed, base: s.ChunkMaps.Get(filename)})
+	rtest.Equals(t, int64(len(data)), read)
+	rtest.Assert(t, s.ChunkMaps.Get(filename) == nil, "outdated chunk map was not removed")
+
+	s.TriggerShutdown()
+	rtest.OK(t, wg.Wait())
+}
+
+func TestChunkPlan(t *testing.T) {
+	content := restic.IDs{restic.NewRandomID(), restic.NewRandomID(), restic.NewRandomID()}
+	base := &ChunkMap{Size: 300, Lengths: []uint32{100, 100, 100}}
+
+	var tests = []struct {
+		name     string
+		changed  []fs.Range
+		size     uint64
+		reusable []bool
+	}{
+		{"unchanged", nil, 300, []bool{true, true, true}},
+		{"modified", []fs.Range{{Start: 150, End: 160}}, 300, []bool{true, false, true}},
+		{"boundary", []fs.Range{{Start: 100, End: 200}}, 300, []bool{true, false, true}},
+		{"appended", []fs.Range{{Start: 300, End: 400}}, 400, []bool{true, true, false}},
+		{"truncated", nil, 250, []bool{true, true, false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newChunkPlan(base, content, test.changed, test.size)
+			for i, want := range test.reusable {
+				id, length, ok := p.reuse(uint64(i * 100))
+				rtest.Equals(t, want, ok)
+				if ok {
+					rtest.Equals(t, content[i], id)
+					rtest.Equals(t, uint32(100), length)
+				}
+			}
+			_, _, ok := p.reuse(300)
+			rtest.Assert(t, !ok, "chunk beyond the previous version reused")
+		})
+	}
+}
@@ -2,6 +2,7 @@ package archiver
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
@@ -30,6 +31,22 @@ type FileSaver struct {
 	CompleteBlob func(bytes uint64)
 
 	NodeFromFileInfo func(snPath, filename string, fi os.FileInfo, ignoreXattrListError bool) (*restic.Node, error)
+
+	// ChunkMaps stores the chunk maps recorded for files saved with a
+	// chunkMapJob.
+	ChunkMaps *ChunkMaps
+
+	// ReadBlockMap returns the block map of an open file.
+	ReadBlockMap func(f fs.File, fi os.FileInfo) (*fs.BlockMap, error)
+
+	// Interrupt aborts reading files once it is closed, see
+	// Archiver.Interrupt.
+	Interrupt <-chan struct{}
+
+	// savedBlobs contains the data blobs added to the repository, their
+	// data is attributed to the current backup if it is found again.
+	savedMu    sync.Mutex
+	savedBlobs restic.IDSet
 }
 
 // NewFileSaver returns a new file saver. A worker pool with fileWorkers is
@@ -46,8 +63,10 @@ func NewFileSaver(ctx context.Context, wg *errgroup.Group, save SaveBlobFn, pol
 		saveFilePool: NewBufferPool(int(poolSize), chunker.MaxSize),
 		pol:          pol,
 		ch:           ch,
+		savedBlobs:   restic.NewIDSet(),
 
 		CompleteBlob: func(uint64) {},
+		ReadBlockMap: fs.ReadBlockMap,
 	}
 
 	for i := uint(0); i < fileWorkers; i++ {
@@ -72,13 +91,49 @@ type CompleteFunc func(*restic.Node, ItemStats)
 // successfully. complete is always called. If completeReading is called, then
 // this will always happen before calling complete.
 func (s *FileSaver) Save(ctx context.Context, snPath string, target string, file fs.File, fi os.FileInfo, start func(), completeReading func(), complete CompleteFunc) FutureNode {
+	return s.saveWithChunkMap(ctx, snPath, target, file, fi, nil, nil, start, completeReading, complete)
+}
+
+// attributeBlob records a new blob or attributes the data of a known blob to
+// the previous version of the file, the current backup or the repository.
+func (s *FileSaver) attributeBlob(stats *ItemStats, sbr SaveBlobResponse, parentBlobs restic.IDSet) {
+	s.savedMu.Lock()
+	defer s.savedMu.Unlock()
+
+	switch {
+	case !sbr.known:
+		s.savedBlobs.Insert(sbr.id)
+	case parentBlobs.Has(sbr.id):
+		stats.DataSizeFromParent += uint64(sbr.length)
+	case s.savedBlobs.Has(sbr.id):
+		stats.DataSizeFromBackup += uint64(sbr.length)
+	default:
+		stats.DataSizeFromRepo += uint64(sbr.length)
+	}
+}
+
+// chunkMapJob requests that the chunk map of a file is recorded. base is the
+// chunk map of the previous version of the file, it is nil if unavailable.
+type chunkMapJob struct {
+	path     string
+	previous *restic.Node
+	base     *ChunkMap
+}
+
+// saveWithChunkMap works like Save. previous is the version of the file in
+// the parent snapshot, if any. If cm is not nil, the chunk map of the file is
+// recorded and the chunks of the previous version are reused for the regions
+// of the file which were not modified.
+func (s *FileSaver) saveWithChunkMap(ctx context.Context, snPath string, target string, file fs.File, fi os.FileInfo, previous *restic.Node, cm *chunkMapJob, start func(), completeReading func(), complete CompleteFunc) FutureNode {
 	fn, ch := newFutureNode()
 	job := saveFileJob{
-		snPath: snPath,
-		target: target,
-		file:   file,
-		fi:     fi,
-		ch:     ch,
+		snPath:   snPath,
+		target:   target,
+		file:     file,
+		fi:       fi,
+		previous: previous,
+		chunkMap: cm,
+		ch:       ch,
 
 		start:           start,
 		completeReading: completeReading,
@@ -97,11 +152,13 @@ func (s *FileSaver) Save(ctx context.Context, snPath string, target string, file
 }
 
 type saveFileJob struct {
-	snPath string
-	target string
-	file   fs.File
-	fi     os.FileInfo
-	ch     chan<- futureNodeResult
+	snPath   string
+	target   string
+	file     fs.File
+	fi       os.FileInfo
+	previous *restic.Node
+	chunkMap *chunkMapJob
+	ch       chan<- futureNodeResult
 
 	start           func()
 	completeReading func()
@@ -109,7 +166,7 @@ type saveFileJob struct {
 }
 
 // saveFile stores the file f in the repo, then closes it.
-func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPath string, target string, f fs.File, fi os.FileInfo, start func(), finishReading func(), finish func(res futureNodeResult)) {
+func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPath string, target string, f fs.File, fi os.FileInfo, previous *restic.Node, cm *chunkMapJob, start func(), finishReading func(), finish func(res futureNodeResult)) {
 	start()
 
 	fnr := futureNodeResult{
@@ -120,6 +177,9 @@ func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 	remaining := 0
 	isCompleted := false
 
+	var blocks *fs.BlockMap
+	var lengths []uint32
+
 	completeBlob := func() {
 		lock.Lock()
 		defer lock.Unlock()
@@ -135,6 +195,7 @@ func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 				}
 			}
 			isCompleted = true
+			s.storeChunkMap(cm, fi, blocks, fnr.node, lengths)
 			finish(fnr)
 		}
 	}
@@ -169,13 +230,66 @@ func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 		return
 	}
 
+	// the regions of the file which were not modified since the chunk map
+	// of the previous version was recorded are not read again
+	var plan *chunkPlan
+	if cm != nil {
+		blocks, err = s.ReadBlockMap(f, fi)
+		if err != nil {
+			debug.Log("no block map for %v: %v", target, err)
+			blocks = nil
+		}
+		if blocks != nil && cm.base != nil {
+			changed := fs.ChangedRanges(cm.base.Blocks, blocks)
+			plan = newChunkPlan(cm.base, cm.previous.Content, changed, uint64(fi.Size()))
+		}
+	}
+
+	var parentBlobs restic.IDSet
+	if previous != nil && previous.Type == "file" {
+		parentBlobs = restic.NewIDSet(previous.Content...)
+	}
+
 	// reuse the chunker
 	chnker.Reset(f, s.pol)
 
 	node.Content = []restic.ID{}
 	node.Size = 0
-	var idx int
+	// the hash of the whole file is computed alongside the chunk hashes, the
+	// data is already in memory at this point
+	hash := sha256.New()
+	reused := false
+	seek := false
+	var idx, pending int
 	for {
+		if plan != nil {
+			if id, length, ok := plan.reuse(node.Size); ok {
+				lock.Lock()
+				node.Content = append(node.Content, id)
+				fnr.stats.DataSizeFromParent += uint64(length)
+				lock.Unlock()
+				lengths = append(lengths, length)
+				node.Size += uint64(length)
+				idx++
+				reused = true
+				seek = true
+				s.CompleteBlob(uint64(length))
+				continue
+			}
+
+			if seek {
+				// chunk the file starting at the end of the reused chunk,
+				// which is a chunk boundary
+				if _, err := f.Seek(int64(node.Size), io.SeekStart); err != nil {
+					_ = f.Close()
+					completeError(err)
+					return
+				}
+				chnker.Reset(f, s.pol)
+				seek = false
+			}
+		}
+
 		buf := s.saveFilePool.Get()
 		chunk, err := chnker.Next(buf.Data)
 		if err == io.EOF {
@@ -188,15 +302,25 @@ func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 
 		if err != nil {
 			_ = f.Close()
-			completeError(err)
+			completeError(fs.WithLockOwners(target, err))
 			return
 		}
+		_, _ = hash.Write(chunk.Data)
+		lengths = append(lengths, uint32(chunk.Length))
 		// test if the context has been cancelled, return the error
 		if ctx.Err() != nil {
 			_ = f.Close()
 			completeError(ctx.Err())
 			return
 		}
+		select {
+		case <-s.Interrupt:
+			buf.Release()
+			_ = f.Close()
+			completeError(errInterrupted)
+			return
+		default:
+		}
 
 		// add a place to store the saveBlob result
 		pos := idx
@@ -212,6 +336,7 @@ func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 				fnr.stats.DataSize += uint64(sbr.length)
 				fnr.stats.DataSizeInRepo += uint64(sbr.sizeInRepo)
 			}
+			s.attributeBlob(&fnr.stats, sbr, parentBlobs)
 
 			node.Content[pos] = sbr.id
 			lock.Unlock()
@@ -219,6 +344,7 @@ func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 			completeBlob()
 		})
 		idx++
+		pending++
 
 		// test if the context has been cancelled, return the error
 		if ctx.Err() != nil {
@@ -236,11 +362,17 @@ func (s *FileSaver) saveFile(ctx context.Context, chnker *chunker.Chunker, snPat
 		return
 	}
 
+	// the hash is unknown if parts of the file were not read
+	if !reused {
+		contentHash := restic.IDFromHash(hash.Sum(nil))
+		node.ContentSHA256 = &contentHash
+	}
+
 	fnr.node = node
 	lock.Lock()
 	// require one additional completeFuture() call to ensure that the future only completes
 	// after reaching the end of this method
-	remaining += idx + 1
+	remaining += pending + 1
 	lock.Unlock()
 	finishReading()
 	completeBlob()
@@ -262,7 +394,7 @@ func (s *FileSaver) worker(ctx context.Context, jobs <-chan saveFileJob) {
 			}
 		}
 
-		s.saveFile(ctx, chnker, job.snPath, job.target, job.file, job.fi, job.start, func() {
+		s.saveFile(ctx, chnker, job.snPath, job.target, job.file, job.fi, job.previous, job.chunkMap, job.start, func() {
 			if job.completeReading != nil {
 				job.completeReading()
 			}
@@ -275,3 +407,25 @@ func (s *FileSaver) worker(ctx context.Context, jobs <-chan saveFileJob) {
 		})
 	}
 }
+
+// storeChunkMap records the chunk map of the saved file node. The outdated
+// chunk map is removed if the block map of the file is not available.
+func (s *FileSaver) storeChunkMap(cm *chunkMapJob, fi os.FileInfo, blocks *fs.BlockMap, node *restic.Node, lengths []uint32) {
+	if cm == nil {
+		return
+	}
+	deviceID, inode, ok := fileID(fi)
+	if blocks == nil || !ok {
+		s.ChunkMaps.Put(cm.path, nil)
+		return
+	}
+
+	s.ChunkMaps.Put(cm.path, &ChunkMap{
+		Size:     node.Size,
+		DeviceID: deviceID,
+		Inode:    inode,
+		Content:  contentHash(node.Content),
+		Lengths:  lengths,
+		Blocks:   blocks,
+	})
+}
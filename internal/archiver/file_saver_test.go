@@ -86,10 +86,16 @@ func TestFileSaver(t *testing.T) {
 		results = append(results, ff)
 	}
 
-	for _, file := range results {
+	for i, file := range results {
 		fnr := file.take(ctx)
 		if fnr.err != nil {
 			t.Errorf("unable to save file: %v", fnr.err)
+			continue
+		}
+
+		want := restic.Hash([]byte(filepath.Base(files[i])))
+		if fnr.node.ContentSHA256 == nil || !fnr.node.ContentSHA256.Equal(want) {
+			t.Errorf("wrong content hash for %v: want %v, got %v", files[i], want, fnr.node.ContentSHA256)
 		}
 	}
 
@@ -0,0 +1,105 @@
+package archiver
+
+import (
+	"os"
+	"sync"
+
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// hardlinkKey identifies a file which has more than one hardlink.
+type hardlinkKey struct {
+	deviceID, inode uint64
+}
+
+// hardlinkEntry is created when the first link of a file is saved. Once done
+// is closed, node contains the saved node or nil if saving it failed.
+type hardlinkEntry struct {
+	done chan struct{}
+	node *restic.Node
+	once sync.Once
+
+	// remaining is the number of links which have not been seen yet.
+	remaining uint64
+}
+
+// finish stores the node saved for the first link and wakes up all other
+// links waiting for it. Only the first call has an effect.
+func (e *hardlinkEntry) finish(node *restic.Node) {
+	e.once.Do(func() {
+		e.node = node
+		close(e.done)
+	})
+}
+
+// hardlinkCache remembers the nodes of files with several hardlinks during a
+// single backup run, so that the content and metadata of every inode is only
+// read once. This makes backups of hardlink farms as created by rsnapshot or
+// BackupPC much faster.
+type hardlinkCache struct {
+	mu      sync.Mutex
+	entries map[hardlinkKey]*hardlinkEntry
+}
+
+func newHardlinkCache() *hardlinkCache {
+	return &hardlinkCache{entries: make(map[hardlinkKey]*hardlinkEntry)}
+}
+
+// hardlinkKeyOf returns the key of the file described by fi and its number of
+// links. ok is false if the file has only one link or its inode is unknown.
+func hardlinkKeyOf(fi os.FileInfo) (key hardlinkKey, links uint64, ok bool) {
+	switch fi.Sys().(type) {
+	case nil, *restic.Node:
+		// the file info is not provided by the local file system
+		return hardlinkKey{}, 0, false
+	}
+
+	extFI := fs.ExtendedStat(fi)
+	if extFI.Links <= 1 || extFI.Inode == 0 {
+		return hardlinkKey{}, 0, false
+	}
+	return hardlinkKey{deviceID: extFI.DeviceID, inode: extFI.Inode}, extFI.Links, true
+}
+
+// lookup returns the entry for the file described by fi. If the file was not
+// seen before, a new entry is returned with first set to true, and the caller
+// must call finish on it once the file was saved. For all later links, the
+// entry is removed from the cache once all links have been seen.
+func (c *hardlinkCache) lookup(fi os.FileInfo) (entry *hardlinkEntry, first bool) {
+	if c == nil {
+		return nil, false
+	}
+	key, links, ok := hardlinkKeyOf(fi)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok = c.entries[key]
+	if !ok {
+		entry = &hardlinkEntry{done: make(chan struct{}), remaining: links - 1}
+		c.entries[key] = entry
+		return entry, true
+	}
+
+	entry.remaining--
+	if entry.remaining == 0 {
+		delete(c.entries, key)
+	}
+	return entry, false
+}
+
+// result returns the node saved for the first link. It is nil if the first
+// link is still being read, so that other files are not held up by it, or if
+// saving it failed.
+func (e *hardlinkEntry) result() *restic.Node {
+	select {
+	case <-e.done:
+		return e.node
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package archiver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/restic/restic/internal/fs"
+)
+
+// OfflinePolicy controls how offline files are saved. Reading the content of
+// an offline file recalls it from a hierarchical storage management system,
+// for example from tape, or from a cloud sync provider.
+type OfflinePolicy int
+
+// Constants for the different offline policies
+const (
+	// OfflineRecall reads offline files like all other files.
+	OfflineRecall OfflinePolicy = iota
+	// OfflineMetadata only saves the metadata of offline files.
+	OfflineMetadata
+	// OfflineSkip does not save offline files at all.
+	OfflineSkip
+	OfflineInvalid
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (p *OfflinePolicy) Set(s string) error {
+	switch s {
+	case "recall":
+		*p = OfflineRecall
+	case "metadata":
+		*p = OfflineMetadata
+	case "skip":
+		*p = OfflineSkip
+	default:
+		*p = OfflineInvalid
+		return fmt.Errorf("invalid offline policy %q, must be one of (recall|metadata|skip)", s)
+	}
+
+	return nil
+}
+
+func (p *OfflinePolicy) String() string {
+	switch *p {
+	case OfflineRecall:
+		return "recall"
+	case OfflineMetadata:
+		return "metadata"
+	case OfflineSkip:
+		return "skip"
+	default:
+		return "invalid"
+	}
+}
+
+func (p *OfflinePolicy) Type() string {
+	return "policy"
+}
+
+// offlineStub returns whether only the metadata of the file described by fi
+// is saved.
+func (arch *Archiver) offlineStub(fi os.FileInfo) bool {
+	return arch.OfflinePolicy == OfflineMetadata && fs.IsOffline(fi)
+}
+
+// startRecall waits until fewer than OfflineRecalls offline files are being
+// recalled. The returned function must be called once the file was read.
+func (arch *Archiver) startRecall(ctx context.Context) (release func(), err error) {
+	if arch.recalls == nil {
+		return func() {}, nil
+	}
+	select {
+	case arch.recalls <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return func() { <-arch.recalls }, nil
+}
+
+// recallFile is an offline file which is being recalled. Closing it allows
+// recalling the next offline file.
+type recallFile struct {
+	fs.File
+	once    sync.Once
+	release func()
+}
+
+func (f *recallFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(f.release)
+	return err
+}
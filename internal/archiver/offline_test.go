@@ -0,0 +1,38 @@
+package archiver
+
+import (
+	"context"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestOfflinePolicy(t *testing.T) {
+	for _, s := range []string{"recall", "metadata", "skip"} {
+		var p OfflinePolicy
+		rtest.OK(t, p.Set(s))
+		rtest.Equals(t, s, p.String())
+	}
+
+	var p OfflinePolicy
+	rtest.Assert(t, p.Set("foo") != nil, "missing error for invalid policy")
+	rtest.Equals(t, OfflineInvalid, p)
+}
+
+func TestStartRecall(t *testing.T) {
+	arch := &Archiver{recalls: make(chan struct{}, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	release, err := arch.startRecall(ctx)
+	rtest.OK(t, err)
+
+	// the limit is reached, waiting is aborted by the context
+	cancel()
+	_, err = arch.startRecall(ctx)
+	rtest.Assert(t, err == context.Canceled, "unexpected error %v", err)
+
+	release()
+	release, err = arch.startRecall(context.Background())
+	rtest.OK(t, err)
+	release()
+}
@@ -4,27 +4,29 @@ import (
 	"context"
 	"os"
 	"path/filepath"
-	"sort"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/vfs"
 )
 
 // Scanner  traverses the targets and calls the function Result with cumulated
 // stats concerning the files and folders found. Select is used to decide which
 // items should be included. Error is called when an error occurs.
 type Scanner struct {
-	FS           fs.FS
+	FS           vfs.FS
 	SelectByName SelectByNameFunc
 	Select       SelectFunc
 	Error        ErrorFunc
 	Result       func(item string, s ScanStats)
 }
 
-// NewScanner initializes a new Scanner.
-func NewScanner(fs fs.FS) *Scanner {
+// NewScanner initializes a new Scanner. Unless filesystem implements vfs.FS,
+// it is read like the local file system.
+func NewScanner(filesystem fs.FS) *Scanner {
 	return &Scanner{
-		FS:           fs,
+		FS:           vfs.Wrap(filesystem),
 		SelectByName: func(_ string) bool { return true },
 		Select:       func(_ string, _ os.FileInfo) bool { return true },
 		Error:        func(_ string, err error) error { return err },
@@ -36,6 +38,9 @@ func NewScanner(fs fs.FS) *Scanner {
 type ScanStats struct {
 	Files, Dirs, Others uint
 	Bytes               uint64
+	// Streams counts the alternate data streams, which are not included in
+	// Files. Their size is included in Bytes.
+	Streams uint
 }
 
 func (s *Scanner) scanTree(ctx context.Context, stats ScanStats, tree Tree) (ScanStats, error) {
@@ -46,7 +51,7 @@ func (s *Scanner) scanTree(ctx context.Context, stats ScanStats, tree Tree) (Sca
 			return ScanStats{}, err
 		}
 
-		stats, err = s.scan(ctx, stats, abstarget)
+		stats, err = s.scan(ctx, stats, abstarget, nil)
 		if err != nil {
 			return ScanStats{}, err
 		}
@@ -98,7 +103,9 @@ func (s *Scanner) Scan(ctx context.Context, targets []string) error {
 	return nil
 }
 
-func (s *Scanner) scan(ctx context.Context, stats ScanStats, target string) (ScanStats, error) {
+// scan traverses target, for which fi was returned by Lstat. fi is read if it
+// is nil.
+func (s *Scanner) scan(ctx context.Context, stats ScanStats, target string, fi os.FileInfo) (ScanStats, error) {
 	if ctx.Err() != nil {
 		return stats, nil
 	}
@@ -109,9 +116,12 @@ func (s *Scanner) scan(ctx context.Context, stats ScanStats, target string) (Sca
 	}
 
 	// get file information
-	fi, err := s.FS.Lstat(target)
-	if err != nil {
-		return stats, s.Error(target, err)
+	if fi == nil {
+		var err error
+		fi, err = s.FS.Lstat(target)
+		if err != nil {
+			return stats, s.Error(target, err)
+		}
 	}
 
 	// run remaining select functions that require file information
@@ -121,17 +131,20 @@ func (s *Scanner) scan(ctx context.Context, stats ScanStats, target string) (Sca
 
 	switch {
 	case fi.Mode().IsRegular():
-		stats.Files++
+		if restic.IsMainFile(filepath.Base(target)) {
+			stats.Files++
+		} else {
+			stats.Streams++
+		}
 		stats.Bytes += uint64(fi.Size())
 	case fi.Mode().IsDir():
-		names, err := readdirnames(s.FS, target, fs.O_NOFOLLOW)
+		entries, err := readdir(s.FS, target)
 		if err != nil {
 			return stats, s.Error(target, err)
 		}
-		sort.Strings(names)
 
-		for _, name := range names {
-			stats, err = s.scan(ctx, stats, filepath.Join(target, name))
+		for _, entry := range entries {
+			stats, err = s.scan(ctx, stats, filepath.Join(target, entry.Name()), entry)
 			if err != nil {
 				return stats, err
 			}
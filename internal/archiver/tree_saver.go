@@ -14,6 +14,10 @@ type TreeSaver struct {
 	saveBlob SaveBlobFn
 	errFn    ErrorFunc
 
+	// ResticCompat restricts the saved trees to data structures which can be
+	// read by upstream restic, see restic.Node.ResticCompatible.
+	ResticCompat bool
+
 	ch chan<- saveTreeJob
 }
 
@@ -93,6 +97,10 @@ func (s *TreeSaver) save(ctx context.Context, job *saveTreeJob) (*restic.Node, I
 			if fnr.err == context.Canceled {
 				return nil, stats, fnr.err
 			}
+			if errors.Is(fnr.err, errInterrupted) {
+				// the file is left out of the partial snapshot
+				continue
+			}
 
 			fnr.err = s.errFn(fnr.target, fnr.err)
 			if fnr.err == nil {
@@ -109,6 +117,17 @@ func (s *TreeSaver) save(ctx context.Context, job *saveTreeJob) (*restic.Node, I
 			continue
 		}
 
+		if s.ResticCompat {
+			compat, err := fnr.node.ResticCompatible()
+			if err != nil {
+				if err = s.errFn(fnr.target, err); err != nil {
+					return nil, stats, err
+				}
+				continue
+			}
+			fnr.node = compat
+		}
+
 		err := builder.AddNode(fnr.node)
 		if err != nil && errors.Is(err, restic.ErrTreeNotOrdered) && lastNode != nil && fnr.node.Equals(*lastNode) {
 			debug.Log("insert %v failed: %v", fnr.node.Name, err)
@@ -2,6 +2,7 @@ package archiver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"runtime"
 	"testing"
@@ -156,3 +157,45 @@ func TestTreeSaverDuplicates(t *testing.T) {
 		})
 	}
 }
+
+func TestTreeSaverResticCompat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wg, ctx := errgroup.WithContext(ctx)
+
+	var buf []byte
+	saveBlob := func(ctx context.Context, tpe restic.BlobType, b *Buffer, target string, cb func(res SaveBlobResponse)) {
+		buf = b.Data
+		treeSaveHelper(ctx, tpe, b, target, cb)
+	}
+	errFn := func(snPath string, err error) error {
+		return err
+	}
+	s := NewTreeSaver(ctx, wg, 1, saveBlob, errFn)
+	s.ResticCompat = true
+
+	hash := restic.NewRandomID()
+	child := &restic.Node{
+		Name:          "child",
+		Type:          "file",
+		ContentSHA256: &hash,
+		GenericAttributes: map[restic.GenericAttributeType]json.RawMessage{
+			restic.TypeInodeFlags:     json.RawMessage(`16`),
+			restic.TypeFileAttributes: json.RawMessage(`32`),
+		},
+	}
+	nodes := []FutureNode{newFutureNodeWithResult(futureNodeResult{node: child})}
+	fb := s.Save(ctx, "/dir", "dir", &restic.Node{Name: "dir", Type: "dir"}, nodes, nil)
+	fb.take(ctx)
+
+	s.TriggerShutdown()
+	test.OK(t, wg.Wait())
+
+	tree := restic.NewTree(1)
+	test.OK(t, json.Unmarshal(buf, tree))
+	test.Equals(t, 1, len(tree.Nodes))
+	test.Assert(t, tree.Nodes[0].ContentSHA256 == nil, "content hash was saved")
+	test.Equals(t, map[restic.GenericAttributeType]json.RawMessage{
+		restic.TypeFileAttributes: json.RawMessage(`32`),
+	}, tree.Nodes[0].GenericAttributes)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"hash"
@@ -18,6 +19,7 @@ import (
 	"github.com/restic/restic/internal/backend/util"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
@@ -37,6 +39,11 @@ type Backend struct {
 	prefix       string
 	listMaxItems int
 	layout.Layout
+
+	// cpk is the customer-provided key used for all requests, cpkPrevious
+	// is only used as a fallback when reading blobs.
+	cpk         *blob.CPKInfo
+	cpkPrevious *blob.CPKInfo
 }
 
 const saveLargeSize = 256 * 1024 * 1024
@@ -135,9 +142,49 @@ func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 		listMaxItems: defaultListMaxItems,
 	}
 
+	be.cpk, err = newCPKInfo(cfg.EncryptionKey, "encryption-key")
+	if err != nil {
+		return nil, err
+	}
+	be.cpkPrevious, err = newCPKInfo(cfg.EncryptionKeyPrevious, "encryption-key-previous")
+	if err != nil {
+		return nil, err
+	}
+	if be.cpkPrevious != nil && be.cpk == nil {
+		return nil, errors.Fatal("unable to open Azure backend: encryption-key-previous requires encryption-key to be set")
+	}
+
 	return be, nil
 }
 
+// newCPKInfo validates a base64 encoded 256 bit customer-provided key and
+// returns the corresponding request parameters. It returns nil if no key is
+// configured.
+func newCPKInfo(key options.SecretString, name string) (*blob.CPKInfo, error) {
+	if key.Unwrap() == "" {
+		return nil, nil
+	}
+
+	buf, err := base64.StdEncoding.DecodeString(key.Unwrap())
+	if err != nil {
+		return nil, errors.Fatalf("unable to open Azure backend: %v is not valid base64: %v", name, err)
+	}
+	if len(buf) != 32 {
+		return nil, errors.Fatalf("unable to open Azure backend: %v must be 256 bits long, got %d bits", name, len(buf)*8)
+	}
+
+	encodedKey := key.Unwrap()
+	sum := sha256.Sum256(buf)
+	keyHash := base64.StdEncoding.EncodeToString(sum[:])
+	algorithm := blob.EncryptionAlgorithmTypeAES256
+
+	return &blob.CPKInfo{
+		EncryptionKey:       &encodedKey,
+		EncryptionKeySHA256: &keyHash,
+		EncryptionAlgorithm: &algorithm,
+	}, nil
+}
+
 // Open opens the Azure backend at specified container.
 func Open(_ context.Context, cfg Config, rt http.RoundTripper) (*Backend, error) {
 	return open(cfg, rt)
@@ -248,13 +295,16 @@ func (be *Backend) saveSmall(ctx context.Context, objName string, rd backend.Rew
 	reader := bytes.NewReader(buf)
 	_, err = blockBlobClient.StageBlock(ctx, id, streaming.NopCloser(reader), &blockblob.StageBlockOptions{
 		TransactionalValidation: blob.TransferValidationTypeMD5(rd.Hash()),
+		CPKInfo:                 be.cpk,
 	})
 	if err != nil {
 		return errors.Wrap(err, "StageBlock")
 	}
 
 	blocks := []string{id}
-	_, err = blockBlobClient.CommitBlockList(ctx, blocks, &blockblob.CommitBlockListOptions{})
+	_, err = blockBlobClient.CommitBlockList(ctx, blocks, &blockblob.CommitBlockListOptions{
+		CPKInfo: be.cpk,
+	})
 	return errors.Wrap(err, "CommitBlockList")
 }
 
@@ -291,6 +341,7 @@ func (be *Backend) saveLarge(ctx context.Context, objName string, rd backend.Rew
 		debug.Log("StageBlock %v with %d bytes", id, len(buf))
 		_, err = blockBlobClient.StageBlock(ctx, id, streaming.NopCloser(reader), &blockblob.StageBlockOptions{
 			TransactionalValidation: blob.TransferValidationTypeMD5(h[:]),
+			CPKInfo:                 be.cpk,
 		})
 
 		if err != nil {
@@ -305,7 +356,9 @@ func (be *Backend) saveLarge(ctx context.Context, objName string, rd backend.Rew
 		return errors.Errorf("wrote %d bytes instead of the expected %d bytes", uploadedBytes, rd.Length())
 	}
 
-	_, err := blockBlobClient.CommitBlockList(ctx, blocks, &blockblob.CommitBlockListOptions{})
+	_, err := blockBlobClient.CommitBlockList(ctx, blocks, &blockblob.CommitBlockListOptions{
+		CPKInfo: be.cpk,
+	})
 
 	debug.Log("uploaded %d parts: %v", len(blocks), blocks)
 	return errors.Wrap(err, "CommitBlockList")
@@ -318,6 +371,15 @@ func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offse
 }
 
 func (be *Backend) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
+	rd, err := be.openReaderWithKey(ctx, h, length, offset, be.cpk)
+	if err != nil && be.cpkPrevious != nil && !be.IsNotExist(err) {
+		debug.Log("reading %v with current encryption key failed, retrying with previous key: %v", h, err)
+		rd, err = be.openReaderWithKey(ctx, h, length, offset, be.cpkPrevious)
+	}
+	return rd, err
+}
+
+func (be *Backend) openReaderWithKey(ctx context.Context, h backend.Handle, length int, offset int64, cpk *blob.CPKInfo) (io.ReadCloser, error) {
 	objName := be.Filename(h)
 	blockBlobClient := be.container.NewBlobClient(objName)
 
@@ -326,6 +388,7 @@ func (be *Backend) openReader(ctx context.Context, h backend.Handle, length int,
 			Offset: offset,
 			Count:  int64(length),
 		},
+		CPKInfo: cpk,
 	})
 
 	if err != nil {
@@ -345,7 +408,11 @@ func (be *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo
 	objName := be.Filename(h)
 	blobClient := be.container.NewBlobClient(objName)
 
-	props, err := blobClient.GetProperties(ctx, nil)
+	props, err := blobClient.GetProperties(ctx, &blob.GetPropertiesOptions{CPKInfo: be.cpk})
+	if err != nil && be.cpkPrevious != nil && !be.IsNotExist(err) {
+		debug.Log("stat of %v with current encryption key failed, retrying with previous key: %v", h, err)
+		props, err = blobClient.GetProperties(ctx, &blob.GetPropertiesOptions{CPKInfo: be.cpkPrevious})
+	}
 
 	if err != nil {
 		return backend.FileInfo{}, errors.Wrap(err, "blob.GetProperties")
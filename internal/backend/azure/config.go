@@ -23,6 +23,9 @@ type Config struct {
 	Prefix             string
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+
+	EncryptionKey         options.SecretString `option:"encryption-key" help:"base64 encoded 256 bit customer-provided key (CPK) used to encrypt blobs at rest"`
+	EncryptionKeyPrevious options.SecretString `option:"encryption-key-previous" help:"previous customer-provided key, used to read blobs written before a key rotation"`
 }
 
 // NewConfig returns a new Config with the default values filled in.
@@ -83,4 +86,12 @@ func (cfg *Config) ApplyEnvironment(prefix string) {
 	if cfg.EndpointSuffix == "" {
 		cfg.EndpointSuffix = os.Getenv(prefix + "AZURE_ENDPOINT_SUFFIX")
 	}
+
+	if cfg.EncryptionKey.String() == "" {
+		cfg.EncryptionKey = options.NewSecretString(os.Getenv(prefix + "RESTIC_AZURE_ENCRYPTION_KEY"))
+	}
+
+	if cfg.EncryptionKeyPrevious.String() == "" {
+		cfg.EncryptionKeyPrevious = options.NewSecretString(os.Getenv(prefix + "RESTIC_AZURE_ENCRYPTION_KEY_PREVIOUS"))
+	}
 }
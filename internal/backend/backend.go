@@ -104,6 +104,24 @@ type FreezeBackend interface {
 	Unfreeze()
 }
 
+// SpaceInfo describes the storage space of a backend in bytes.
+type SpaceInfo struct {
+	// Total is the size of the underlying storage.
+	Total uint64
+	// Free is the unused space of the underlying storage.
+	Free uint64
+	// Available is the space which can still be used by the backend, it is
+	// smaller than Free if a quota applies.
+	Available uint64
+}
+
+// SpaceBackend is implemented by backends which can report their free space.
+type SpaceBackend interface {
+	Backend
+	// Space returns the storage space of the backend.
+	Space(ctx context.Context) (SpaceInfo, error)
+}
+
 // FileInfo is contains information about a file in the backend.
 type FileInfo struct {
 	Size int64
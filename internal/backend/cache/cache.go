@@ -18,7 +18,10 @@ import (
 
 // Cache manages a local cache.
 type Cache struct {
-	path    string
+	path string
+	// scratch contains the index and snapshot files and the files stored
+	// using SaveFile. It differs from path if a namespace is used.
+	scratch string
 	Base    string
 	Created bool
 
@@ -50,6 +53,12 @@ var cacheLayoutPaths = map[restic.FileType]string{
 	restic.IndexFile:    "index",
 }
 
+// namespacesDir is the directory in the cache directory of a repository which
+// contains the namespaces.
+const namespacesDir = "namespaces"
+
+var validNamespace = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]*$`)
+
 const cachedirTagSignature = "Signature: 8a477f597d28d172789f06886806bc55\n"
 
 func writeCachedirTag(dir string) error {
@@ -78,6 +87,19 @@ func writeCachedirTag(dir string) error {
 // For partial files, the complete file is loaded and stored in the cache when
 // performReadahead returns true.
 func New(id string, basedir string) (c *Cache, err error) {
+	return NewNamespace(id, basedir, "")
+}
+
+// NewNamespace returns a new cache like New. If namespace is not empty, only
+// the cached pack files are shared with other processes using the same
+// repository. The index and snapshot files and the files stored using SaveFile
+// are kept separately for each namespace, so that concurrent processes cannot
+// remove files the other one relies on.
+func NewNamespace(id string, basedir string, namespace string) (c *Cache, err error) {
+	if namespace != "" && !validNamespace.MatchString(namespace) {
+		return nil, errors.Errorf("invalid cache namespace %q, only letters, digits, '.', '_' and '-' are allowed and it must not start with '.'", namespace)
+	}
+
 	if basedir == "" {
 		basedir, err = DefaultDir()
 		if err != nil {
@@ -133,18 +155,25 @@ func New(id string, basedir string) (c *Cache, err error) {
 		}
 	}
 
-	for _, p := range cacheLayoutPaths {
-		if err = fs.MkdirAll(filepath.Join(cachedir, p), dirMode); err != nil {
-			return nil, errors.WithStack(err)
-		}
+	scratch := cachedir
+	if namespace != "" {
+		scratch = filepath.Join(cachedir, namespacesDir, namespace)
+		debug.Log("using cache namespace %v", scratch)
 	}
 
 	c = &Cache{
 		path:    cachedir,
+		scratch: scratch,
 		Base:    basedir,
 		Created: created,
 	}
 
+	for t := range cacheLayoutPaths {
+		if err = fs.MkdirAll(c.dir(t), dirMode); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
 	return c, nil
 }
 
@@ -204,7 +233,8 @@ func All(basedir string) (dirs []os.FileInfo, err error) {
 	return listCacheDirs(basedir)
 }
 
-// OlderThan returns the list of cache directories older than max.
+// OlderThan returns the list of cache directories older than max. Directories
+// containing pinned snapshots are never returned.
 func OlderThan(basedir string, max time.Duration) ([]os.FileInfo, error) {
 	entries, err := listCacheDirs(basedir)
 	if err != nil {
@@ -213,7 +243,7 @@ func OlderThan(basedir string, max time.Duration) ([]os.FileInfo, error) {
 
 	var oldCacheDirs []os.FileInfo
 	for _, fi := range entries {
-		if !IsOld(fi.ModTime(), max) {
+		if !IsOld(fi.ModTime(), max) || Pinned(filepath.Join(basedir, fi.Name())) {
 			continue
 		}
 
@@ -246,3 +276,41 @@ func (c *Cache) Wrap(be backend.Backend) backend.Backend {
 func (c *Cache) BaseDir() string {
 	return c.Base
 }
+
+// SaveFile stores data in the file name in the cache directory of the
+// repository. Such files are not related to a file in the repository.
+func (c *Cache) SaveFile(name string, data []byte) error {
+	// write to a temporary file first, concurrent restic processes may
+	// use the same cache directory
+	f, err := os.CreateTemp(c.scratch, "tmp-")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = fs.Rename(f.Name(), filepath.Join(c.scratch, name))
+	}
+	if err != nil {
+		_ = fs.Remove(f.Name())
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// LoadFile returns the content of the file name stored using SaveFile.
+func (c *Cache) LoadFile(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(c.scratch, name))
+}
+
+// RemoveFile removes the file name stored using SaveFile.
+func (c *Cache) RemoveFile(name string) error {
+	err := fs.Remove(filepath.Join(c.scratch, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
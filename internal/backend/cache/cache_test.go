@@ -1,9 +1,11 @@
 package cache
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -44,3 +46,82 @@ func TestNew(t *testing.T) {
 		}
 	}
 }
+
+func TestSaveLoadFile(t *testing.T) {
+	c, err := New(restic.NewRandomID().String(), rtest.TempDir(t))
+	rtest.OK(t, err)
+
+	_, err = c.LoadFile("stats.json")
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "unexpected error %v", err)
+
+	rtest.OK(t, c.SaveFile("stats.json", []byte("foo")))
+	rtest.OK(t, c.SaveFile("stats.json", []byte("foobar")))
+	buf, err := c.LoadFile("stats.json")
+	rtest.OK(t, err)
+	rtest.Equals(t, []byte("foobar"), buf)
+
+	rtest.OK(t, c.RemoveFile("stats.json"))
+	rtest.OK(t, c.RemoveFile("stats.json"))
+	_, err = c.LoadFile("stats.json")
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "file was not removed")
+}
+
+func TestOlderThanPinned(t *testing.T) {
+	basedir := rtest.TempDir(t)
+	var caches []*Cache
+	for i := 0; i < 2; i++ {
+		c, err := New(restic.NewRandomID().String(), basedir)
+		rtest.OK(t, err)
+		caches = append(caches, c)
+	}
+	rtest.OK(t, caches[0].SaveFile(PinFile, []byte("{}")))
+
+	timestamp := time.Now().Add(-48 * time.Hour)
+	for _, c := range caches {
+		rtest.OK(t, os.Chtimes(c.path, timestamp, timestamp))
+	}
+
+	old, err := OlderThan(basedir, 24*time.Hour)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(old))
+	rtest.Equals(t, filepath.Base(caches[1].path), old[0].Name())
+}
+
+func TestNamespace(t *testing.T) {
+	basedir := rtest.TempDir(t)
+	id := restic.NewRandomID().String()
+
+	var caches []*Cache
+	for _, namespace := range []string{"backup", "mount"} {
+		c, err := NewNamespace(id, basedir, namespace)
+		rtest.OK(t, err)
+		caches = append(caches, c)
+	}
+
+	packs := generateRandomFiles(t, restic.PackFile, caches[0])
+	rtest.Equals(t, packs, listFiles(t, caches[1], restic.PackFile))
+
+	indexes := generateRandomFiles(t, restic.IndexFile, caches[0])
+	rtest.Equals(t, 0, len(listFiles(t, caches[1], restic.IndexFile)))
+
+	// clearing the cache of one namespace must not affect the other one
+	clearFiles(t, caches[1], restic.IndexFile, restic.NewIDSet())
+	clearFiles(t, caches[1], restic.PackFile, restic.NewIDSet())
+	rtest.Equals(t, indexes, listFiles(t, caches[0], restic.IndexFile))
+	rtest.Equals(t, packs, listFiles(t, caches[0], restic.PackFile))
+
+	rtest.OK(t, caches[0].SaveFile("stats.json", []byte("foo")))
+	_, err := caches[1].LoadFile("stats.json")
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "unexpected error %v", err)
+
+	rtest.Assert(t, !Pinned(caches[0].path), "cache is pinned")
+	rtest.OK(t, caches[1].SaveFile(PinFile, []byte("{}")))
+	rtest.Assert(t, Pinned(caches[0].path), "pin of namespace not found")
+}
+
+func TestNamespaceInvalid(t *testing.T) {
+	for _, namespace := range []string{".", "..", "a/b", `a\b`, "a b"} {
+		_, err := NewNamespace(restic.NewRandomID().String(), rtest.TempDir(t), namespace)
+		rtest.Assert(t, err != nil, "namespace %q was accepted", namespace)
+	}
+}
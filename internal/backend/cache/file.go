@@ -21,7 +21,16 @@ func (c *Cache) filename(h backend.Handle) string {
 		panic("Name is empty or too short")
 	}
 	subdir := h.Name[:2]
-	return filepath.Join(c.path, cacheLayoutPaths[h.Type], subdir, h.Name)
+	return filepath.Join(c.dir(h.Type), subdir, h.Name)
+}
+
+// dir returns the directory containing the cached files of type t. Only pack
+// files are shared between namespaces.
+func (c *Cache) dir(t backend.FileType) string {
+	if t == restic.PackFile {
+		return filepath.Join(c.path, cacheLayoutPaths[t])
+	}
+	return filepath.Join(c.scratch, cacheLayoutPaths[t])
 }
 
 func (c *Cache) canBeCached(t backend.FileType) bool {
@@ -177,6 +186,12 @@ func (c *Cache) Clear(t restic.FileType, valid restic.IDSet) error {
 	if !c.canBeCached(t) {
 		return nil
 	}
+	if t == restic.PackFile && c.scratch != c.path {
+		// the pack files are shared with processes using other namespaces,
+		// which may still need packs that are not in valid
+		debug.Log("not clearing shared pack files from namespace")
+		return nil
+	}
 
 	list, err := c.list(t)
 	if err != nil {
@@ -208,7 +223,7 @@ func (c *Cache) list(t restic.FileType) (restic.IDSet, error) {
 	}
 
 	list := restic.NewIDSet()
-	dir := filepath.Join(c.path, cacheLayoutPaths[t])
+	dir := c.dir(t)
 	err := filepath.Walk(dir, func(name string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return errors.Wrap(err, "Walk")
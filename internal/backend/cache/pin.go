@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/backend"
+)
+
+// PinFile is the name of the file in the cache directory of a repository which
+// records the pinned snapshots. Cache directories containing this file are
+// never considered old.
+const PinFile = "pinned"
+
+// Pinned returns whether the cache directory dir or one of its namespaces
+// contains pinned snapshots.
+func Pinned(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, PinFile)); err == nil {
+		return true
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, namespacesDir, "*", PinFile))
+	return len(matches) > 0
+}
+
+// Prefetch downloads the file to the cache unless it is already cached. Later
+// reads of the file are served from the cache, regardless of its type.
+func (b *Backend) Prefetch(ctx context.Context, h backend.Handle) error {
+	if b.Cache.Has(h) {
+		return nil
+	}
+	return b.cacheFile(ctx, h)
+}
+
+// Discard removes the file from the cache. When the file is not cached, no
+// error is returned.
+func (c *Cache) Discard(h backend.Handle) error {
+	_, err := c.remove(h)
+	return err
+}
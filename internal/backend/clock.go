@@ -0,0 +1,60 @@
+package backend
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ClockRecorder records the difference between the local clock and the time
+// reported by HTTP based backends in the Date header of their responses.
+type ClockRecorder struct {
+	m     sync.Mutex
+	skew  time.Duration
+	known bool
+}
+
+// NewClockRecorder returns a new ClockRecorder.
+func NewClockRecorder() *ClockRecorder {
+	return &ClockRecorder{}
+}
+
+// Transport returns a http.RoundTripper which records the clock skew of all
+// responses returned by rt.
+func (c *ClockRecorder) Transport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		res, err := rt.RoundTrip(req)
+		if err != nil {
+			return res, err
+		}
+		date, err := http.ParseTime(res.Header.Get("Date"))
+		if err == nil {
+			// the server created the response while the request was in flight
+			elapsed := time.Since(start)
+			c.record(date.Sub(start.Add(elapsed / 2)))
+		}
+		return res, nil
+	})
+}
+
+func (c *ClockRecorder) record(skew time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.skew, c.known = skew, true
+}
+
+// Skew returns how far the clock of the server is ahead of the local clock,
+// as recorded by the latest response. The Date header has a resolution of one
+// second. ok is false if no response contained a Date header.
+func (c *ClockRecorder) Skew() (skew time.Duration, ok bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.skew, c.known
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClockRecorder(t *testing.T) {
+	serverTime := time.Now().Add(-time.Hour)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/nodate" {
+			w.Header()["Date"] = nil
+		} else {
+			w.Header().Set("Date", serverTime.UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	clock := NewClockRecorder()
+	client := &http.Client{Transport: clock.Transport(http.DefaultTransport)}
+
+	get := func(path string) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	get("/nodate")
+	if _, ok := clock.Skew(); ok {
+		t.Fatalf("skew known without Date header")
+	}
+
+	get("/")
+	skew, ok := clock.Skew()
+	if !ok {
+		t.Fatalf("skew unknown")
+	}
+	if skew > -59*time.Minute || skew < -61*time.Minute {
+		t.Errorf("wrong skew, want about -1h, got %v", skew)
+	}
+}
@@ -18,6 +18,7 @@ var s3LayoutPaths = map[backend.FileType]string{
 	backend.IndexFile:    "index",
 	backend.LockFile:     "lock",
 	backend.KeyFile:      "key",
+	backend.RuleSetFile:  "ruleset",
 }
 
 func (l *S3LegacyLayout) String() string {
@@ -0,0 +1,149 @@
+package limiter
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// Controller changes the limits of all registered limiters at runtime. It is
+// controlled using commands of the form "upload=<rate>" and
+// "download=<rate>", with the rate in KiB/s and zero meaning unlimited.
+type Controller struct {
+	mu       sync.Mutex
+	limiters []*DynamicLimiter
+
+	// limits set by commands, nil if unchanged
+	upload, download *int
+}
+
+// NewController returns a controller without limiters.
+func NewController() *Controller {
+	return &Controller{}
+}
+
+// Register adds the limiter to the controller. The limits which were changed
+// by previous commands are applied to it.
+func (c *Controller) Register(l *DynamicLimiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.limiters = append(c.limiters, l)
+	l.SetLimits(c.apply(l.Limits()))
+}
+
+func (c *Controller) apply(l Limits) Limits {
+	if c.upload != nil {
+		l.UploadKb = *c.upload
+	}
+	if c.download != nil {
+		l.DownloadKb = *c.download
+	}
+	return l
+}
+
+// Apply runs the commands in text, which are separated by whitespace or line
+// breaks. Lines starting with # are ignored. Either all or none of the
+// commands are applied.
+func (c *Controller) Apply(text string) error {
+	var upload, download *int
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, cmd := range strings.Fields(line) {
+			key, value, ok := strings.Cut(cmd, "=")
+			if !ok {
+				return errors.Errorf("invalid command %q, expected key=value", cmd)
+			}
+			rate, err := strconv.Atoi(value)
+			if err != nil || rate < 0 {
+				return errors.Errorf("invalid rate %q for %v", value, key)
+			}
+
+			switch key {
+			case "upload":
+				upload = &rate
+			case "download":
+				download = &rate
+			default:
+				return errors.Errorf("unknown limit %q", key)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if upload != nil {
+		c.upload = upload
+	}
+	if download != nil {
+		c.download = download
+	}
+	for _, l := range c.limiters {
+		l.SetLimits(c.apply(l.Limits()))
+	}
+	debug.Log("changed limits of %d limiters: %v", len(c.limiters), strings.Join(strings.Fields(text), " "))
+	return nil
+}
+
+// Status returns the limits of all registered limiters, one line each.
+func (c *Controller) Status() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var sb strings.Builder
+	for _, l := range c.limiters {
+		limits := l.Limits()
+		fmt.Fprintf(&sb, "upload=%d download=%d\n", limits.UploadKb, limits.DownloadKb)
+	}
+	return sb.String()
+}
+
+// Serve accepts connections on l until it is closed. Each line received on a
+// connection is applied as commands and answered with "ok" or a line starting
+// with "error:". The line "status" is answered with the current limits of each
+// limiter followed by "ok".
+func (c *Controller) Serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			debug.Log("limit control at %v stopped: %v", l.Addr(), err)
+			return
+		}
+		go c.handle(conn)
+	}
+}
+
+func (c *Controller) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		var response string
+		switch line := strings.TrimSpace(sc.Text()); line {
+		case "status":
+			response = c.Status() + "ok\n"
+		default:
+			if err := c.Apply(line); err != nil {
+				response = fmt.Sprintf("error: %v\n", err)
+			} else {
+				response = "ok\n"
+			}
+		}
+		if _, err := conn.Write([]byte(response)); err != nil {
+			debug.Log("writing response failed: %v", err)
+			return
+		}
+	}
+}
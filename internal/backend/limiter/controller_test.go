@@ -0,0 +1,87 @@
+package limiter
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/test"
+)
+
+func TestControllerApply(t *testing.T) {
+	c := NewController()
+	l1 := NewDynamicLimiter(Limits{UploadKb: 10, DownloadKb: 20})
+	c.Register(l1)
+
+	test.OK(t, c.Apply("# comment\nupload=100\n"))
+	test.Equals(t, Limits{UploadKb: 100, DownloadKb: 20}, l1.Limits())
+
+	// limiters registered later use the changed limits
+	l2 := NewDynamicLimiter(Limits{UploadKb: 1, DownloadKb: 2})
+	c.Register(l2)
+	test.Equals(t, Limits{UploadKb: 100, DownloadKb: 2}, l2.Limits())
+
+	test.OK(t, c.Apply("upload=0 download=50"))
+	test.Equals(t, Limits{UploadKb: 0, DownloadKb: 50}, l1.Limits())
+	test.Equals(t, Limits{UploadKb: 0, DownloadKb: 50}, l2.Limits())
+	test.Equals(t, "upload=0 download=50\nupload=0 download=50\n", c.Status())
+}
+
+func TestControllerApplyInvalid(t *testing.T) {
+	c := NewController()
+	l := NewDynamicLimiter(Limits{UploadKb: 10})
+	c.Register(l)
+
+	for _, text := range []string{
+		"upload",
+		"upload=fast",
+		"upload=-1",
+		"sideways=10",
+		"download=5 upload=x",
+	} {
+		test.Assert(t, c.Apply(text) != nil, "expected error for %q", text)
+	}
+	// none of the invalid commands may have been applied
+	test.Equals(t, Limits{UploadKb: 10}, l.Limits())
+}
+
+func TestControllerServe(t *testing.T) {
+	c := NewController()
+	l := NewDynamicLimiter(Limits{})
+	c.Register(l)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	test.OK(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+	go c.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	test.OK(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	rd := bufio.NewReader(conn)
+	request := func(line string) string {
+		_, err := conn.Write([]byte(line + "\n"))
+		test.OK(t, err)
+		// read until the final line of the response
+		var response string
+		for {
+			line, err := rd.ReadString('\n')
+			test.OK(t, err)
+			response += line
+			if line == "ok\n" || strings.HasPrefix(line, "error:") {
+				return response
+			}
+		}
+	}
+
+	test.Equals(t, "ok\n", request("download=64"))
+	test.Equals(t, Limits{DownloadKb: 64}, l.Limits())
+	test.Equals(t, "upload=0 download=64\nok\n", request("status"))
+	test.Equals(t, "ok\n", request(""))
+	test.Equals(t, "error: unknown limit \"both\"\n", request("both=1"))
+}
@@ -0,0 +1,112 @@
+package limiter
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+// DynamicLimiter is a Limiter whose limits can be changed while it is in use.
+// Like for the static limiter, all readers and writers share the same token
+// buckets, such that the limits apply to the sum of all connections.
+type DynamicLimiter struct {
+	mu     sync.Mutex
+	limits Limits
+
+	upstream   atomic.Pointer[rate.Limiter]
+	downstream atomic.Pointer[rate.Limiter]
+}
+
+var _ Limiter = &DynamicLimiter{}
+
+// NewDynamicLimiter returns a limiter which starts with the limits l.
+func NewDynamicLimiter(l Limits) *DynamicLimiter {
+	d := &DynamicLimiter{}
+	d.SetLimits(l)
+	return d
+}
+
+// Limits returns the current limits.
+func (l *DynamicLimiter) Limits() Limits {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits
+}
+
+// SetLimits changes the limits, zero means unlimited. The new limits apply to
+// all subsequent reads and writes, including those of readers and writers
+// which were created before.
+func (l *DynamicLimiter) SetLimits(limits Limits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// a bucket is never modified but replaced, as the tokens consumed at once
+	// must not exceed the burst of the bucket
+	if limits.UploadKb != l.limits.UploadKb || l.upstream.Load() == nil {
+		l.upstream.Store(newBucket(limits.UploadKb))
+	}
+	if limits.DownloadKb != l.limits.DownloadKb || l.downstream.Load() == nil {
+		l.downstream.Store(newBucket(limits.DownloadKb))
+	}
+	l.limits = limits
+}
+
+func newBucket(kb int) *rate.Limiter {
+	if kb <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(toByteRate(kb)), int(toByteRate(kb)))
+}
+
+func (l *DynamicLimiter) Upstream(r io.Reader) io.Reader {
+	return &dynamicReader{r, &l.upstream}
+}
+
+func (l *DynamicLimiter) UpstreamWriter(w io.Writer) io.Writer {
+	return &dynamicWriter{w, &l.upstream}
+}
+
+func (l *DynamicLimiter) Downstream(r io.Reader) io.Reader {
+	return &dynamicReader{r, &l.downstream}
+}
+
+func (l *DynamicLimiter) DownstreamWriter(w io.Writer) io.Writer {
+	return &dynamicWriter{w, &l.downstream}
+}
+
+// Transport returns an HTTP transport limited with the limiter l.
+func (l *DynamicLimiter) Transport(rt http.RoundTripper) http.RoundTripper {
+	return limitTransport(l, rt)
+}
+
+type dynamicReader struct {
+	reader io.Reader
+	bucket *atomic.Pointer[rate.Limiter]
+}
+
+func (r *dynamicReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if b := r.bucket.Load(); b != nil {
+		if err := consumeTokens(n, b); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+type dynamicWriter struct {
+	writer io.Writer
+	bucket *atomic.Pointer[rate.Limiter]
+}
+
+func (w *dynamicWriter) Write(buf []byte) (int, error) {
+	if b := w.bucket.Load(); b != nil {
+		if err := consumeTokens(len(buf), b); err != nil {
+			return 0, err
+		}
+	}
+	return w.writer.Write(buf)
+}
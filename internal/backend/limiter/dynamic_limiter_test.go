@@ -0,0 +1,47 @@
+package limiter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/test"
+)
+
+func TestDynamicLimiterSetLimits(t *testing.T) {
+	l := NewDynamicLimiter(Limits{})
+	test.Assert(t, l.upstream.Load() == nil && l.downstream.Load() == nil, "expected no buckets without limits")
+
+	l.SetLimits(Limits{UploadKb: 42})
+	test.Equals(t, Limits{UploadKb: 42}, l.Limits())
+	up := l.upstream.Load()
+	test.Assert(t, up != nil, "missing upstream bucket")
+	test.Assert(t, l.downstream.Load() == nil, "unexpected downstream bucket")
+
+	// unchanged limits keep their bucket
+	l.SetLimits(Limits{UploadKb: 42, DownloadKb: 1})
+	test.Assert(t, l.upstream.Load() == up, "upstream bucket was replaced")
+	test.Assert(t, l.downstream.Load() != nil, "missing downstream bucket")
+
+	l.SetLimits(Limits{})
+	test.Assert(t, l.upstream.Load() == nil && l.downstream.Load() == nil, "expected no buckets without limits")
+}
+
+func TestDynamicLimiterExistingReader(t *testing.T) {
+	l := NewDynamicLimiter(Limits{})
+	data := make([]byte, 64*1024)
+	rd := l.Downstream(bytes.NewReader(data))
+
+	buf := make([]byte, 32*1024)
+	_, err := io.ReadFull(rd, buf)
+	test.OK(t, err)
+
+	// the new limit also applies to the existing reader, the first 16 KiB
+	// are covered by the burst of the bucket
+	l.SetLimits(Limits{DownloadKb: 16})
+	start := time.Now()
+	_, err = io.ReadFull(rd, buf)
+	test.OK(t, err)
+	test.Assert(t, time.Since(start) > 500*time.Millisecond, "read was not limited, took %v", time.Since(start))
+}
@@ -37,12 +37,24 @@ type rateLimitedBackend struct {
 }
 
 func (r rateLimitedBackend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
-	limited := limitedRewindReader{
-		RewindReader: rd,
-		limited:      r.limiter.Upstream(rd),
+	upstream := r.limiter.Upstream(rd)
+	limited := false
+	switch upstream := upstream.(type) {
+	case *rateLimitedReader:
+		limited = true
+	case *dynamicReader:
+		// the limit only applies to this file if it is set before the upload
+		limited = upstream.bucket.Load() != nil
+	}
+	if !limited {
+		// pass on the original reader to allow backends to optimize copying
+		return r.Backend.Save(ctx, h, rd)
 	}
 
-	return r.Backend.Save(ctx, h, limited)
+	return r.Backend.Save(ctx, h, limitedRewindReader{
+		RewindReader: rd,
+		limited:      upstream,
+	})
 }
 
 type limitedRewindReader struct {
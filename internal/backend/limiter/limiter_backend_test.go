@@ -44,6 +44,26 @@ func TestLimitBackendSave(t *testing.T) {
 	rtest.OK(t, err)
 }
 
+func TestLimitBackendSaveUnlimited(t *testing.T) {
+	testHandle := backend.Handle{Type: backend.PackFile, Name: "test"}
+	rd := backend.NewByteReader(randomBytes(t, 1234), nil)
+
+	be := mock.NewBackend()
+	be.SaveFn = func(ctx context.Context, h backend.Handle, saveRd backend.RewindReader) error {
+		if saveRd != backend.RewindReader(rd) {
+			return fmt.Errorf("reader was wrapped without upload limit")
+		}
+		return nil
+	}
+	for _, limiter := range []Limiter{
+		NewStaticLimiter(Limits{DownloadKb: 42}),
+		NewDynamicLimiter(Limits{DownloadKb: 42}),
+	} {
+		limbe := LimitBackend(be, limiter)
+		rtest.OK(t, limbe.Save(context.TODO(), testHandle, rd))
+	}
+}
+
 type tracedReadWriteToCloser struct {
 	io.Reader
 	io.WriterTo
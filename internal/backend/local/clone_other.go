@@ -0,0 +1,15 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package local
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// cloneFile is not supported on this platform.
+func cloneFile(_, _ *os.File, _ int64) error {
+	return errors.New("cloning files is not supported on this platform")
+}
@@ -0,0 +1,83 @@
+package local
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/windows"
+)
+
+// fsctlGetIntegrityInformation is not defined in x/sys/windows.
+const fsctlGetIntegrityInformation = 0x9027c
+
+// see FSCTL_GET_INTEGRITY_INFORMATION_BUFFER
+type integrityInformation struct {
+	ChecksumAlgorithm        uint16
+	Reserved                 uint16
+	Flags                    uint32
+	ChecksumChunkSizeInBytes uint32
+	ClusterSizeInBytes       uint32
+}
+
+// see DUPLICATE_EXTENTS_DATA
+type duplicateExtentsData struct {
+	FileHandle       windows.Handle
+	SourceFileOffset int64
+	TargetFileOffset int64
+	ByteCount        int64
+}
+
+// cloneFile makes dst share the clusters of src using block cloning. This is
+// only supported on ReFS if both files are stored on the same volume. dst
+// must be empty and is truncated again if cloning fails.
+func cloneFile(dst, src *os.File, size int64) (err error) {
+	srcHandle := windows.Handle(src.Fd())
+	dstHandle := windows.Handle(dst.Fd())
+
+	// the cloned region must be aligned to the cluster size
+	var info integrityInformation
+	var n uint32
+	err = windows.DeviceIoControl(srcHandle, fsctlGetIntegrityInformation, nil, 0,
+		(*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), &n, nil)
+	if err != nil {
+		return errors.Wrap(err, "get integrity information")
+	}
+	if info.ClusterSizeInBytes == 0 {
+		return errors.New("unknown cluster size")
+	}
+	clusterSize := int64(info.ClusterSizeInBytes)
+
+	if err := dst.Truncate(size); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = dst.Truncate(0)
+		}
+	}()
+
+	// a single request must stay below 4 GiB
+	const maxChunk = 1 << 31
+	for offset := int64(0); offset < size; offset += maxChunk {
+		count := size - offset
+		if count > maxChunk {
+			count = maxChunk
+		}
+		// the final region may extend beyond the end of file
+		count = (count + clusterSize - 1) / clusterSize * clusterSize
+
+		data := duplicateExtentsData{
+			FileHandle:       srcHandle,
+			SourceFileOffset: offset,
+			TargetFileOffset: offset,
+			ByteCount:        count,
+		}
+		err = windows.DeviceIoControl(dstHandle, windows.FSCTL_DUPLICATE_EXTENTS_TO_FILE,
+			(*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), nil, 0, &n, nil)
+		if err != nil {
+			return errors.Wrap(err, "duplicate extents")
+		}
+	}
+	return nil
+}
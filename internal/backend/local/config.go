@@ -12,7 +12,30 @@ type Config struct {
 	Path   string
 	Layout string `option:"layout" help:"use this backend directory layout (default: auto-detect) (deprecated)"`
 
-	Connections uint `option:"connections" help:"set a limit for the number of concurrent operations (default: 2)"`
+	Connections uint   `option:"connections" help:"set a limit for the number of concurrent operations (default: 2)"`
+	Sync        string `option:"sync" help:"fsync policy for saved files: always, dir-only or never (default: always)"`
+}
+
+// Supported values for Config.Sync. An empty value is equivalent to SyncAlways.
+const (
+	// SyncAlways flushes each saved file and its directory to disk.
+	SyncAlways = "always"
+	// SyncDirOnly only flushes the directory after renaming a file. A crash
+	// can leave files with missing content behind.
+	SyncDirOnly = "dir-only"
+	// SyncNever leaves flushing data to the operating system. A crash can
+	// leave missing or incomplete files behind.
+	SyncNever = "never"
+)
+
+// ValidateSyncMode returns an error if mode is not a supported fsync policy.
+func ValidateSyncMode(mode string) error {
+	switch mode {
+	case "", SyncAlways, SyncDirOnly, SyncNever:
+		return nil
+	default:
+		return errors.Errorf("invalid sync mode %q, must be one of always, dir-only or never", mode)
+	}
 }
 
 // NewConfig returns a new config with default options applied.
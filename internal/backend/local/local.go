@@ -40,6 +40,10 @@ func NewFactory() location.Factory {
 const defaultLayout = "default"
 
 func open(ctx context.Context, cfg Config) (*Local, error) {
+	if err := ValidateSyncMode(cfg.Sync); err != nil {
+		return nil, errors.Fatal(err.Error())
+	}
+
 	l, err := layout.ParseLayout(ctx, &layout.LocalFilesystem{}, cfg.Layout, defaultLayout, cfg.Path)
 	if err != nil {
 		return nil, err
@@ -156,28 +160,36 @@ func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReade
 		}
 	}(f)
 
-	// preallocate disk space
-	if size := rd.Length(); size > 0 {
-		if err := fs.PreallocateFile(f, size); err != nil {
-			debug.Log("Failed to preallocate %v with size %v: %v", finalname, size, err)
+	// save data, then sync
+	if !cloneFrom(f, rd) {
+		// preallocate disk space
+		if size := rd.Length(); size > 0 {
+			if err := fs.PreallocateFile(f, size); err != nil {
+				debug.Log("Failed to preallocate %v with size %v: %v", finalname, size, err)
+			}
 		}
-	}
 
-	// save data, then sync
-	wbytes, err := io.Copy(f, rd)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-	// sanity check
-	if wbytes != rd.Length() {
-		return errors.Errorf("wrote %d bytes instead of the expected %d bytes", wbytes, rd.Length())
+		wbytes, err := io.Copy(f, rd)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// sanity check
+		if wbytes != rd.Length() {
+			return errors.Errorf("wrote %d bytes instead of the expected %d bytes", wbytes, rd.Length())
+		}
 	}
 
+	syncFile := b.Sync == "" || b.Sync == SyncAlways
+	syncDirectory := syncFile || b.Sync == SyncDirOnly
+
 	// Ignore error if filesystem does not support fsync.
-	err = f.Sync()
-	syncNotSup := err != nil && (errors.Is(err, syscall.ENOTSUP) || isMacENOTTY(err))
-	if err != nil && !syncNotSup {
-		return errors.WithStack(err)
+	syncNotSup := false
+	if syncFile {
+		err = f.Sync()
+		syncNotSup = err != nil && (errors.Is(err, syscall.ENOTSUP) || isMacENOTTY(err))
+		if err != nil && !syncNotSup {
+			return errors.WithStack(err)
+		}
 	}
 
 	// Close, then rename. Windows doesn't like the reverse order.
@@ -189,7 +201,7 @@ func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReade
 	}
 
 	// Now sync the directory to commit the Rename.
-	if !syncNotSup {
+	if syncDirectory && !syncNotSup {
 		err = fsyncDir(dir)
 		if err != nil {
 			return errors.WithStack(err)
@@ -209,6 +221,36 @@ func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReade
 
 var tempFile = os.CreateTemp // Overridden by test.
 
+// cloneFrom tries to store the content of rd in the empty file f by cloning
+// the data blocks of the file rd reads from. This avoids copying data on
+// copy-on-write file systems. It returns false if the data must be copied.
+func cloneFrom(f *os.File, rd backend.RewindReader) bool {
+	fr, ok := rd.(*backend.FileReader)
+	if !ok {
+		return false
+	}
+	src, ok := fr.ReadSeeker.(*os.File)
+	if !ok || fr.Length() == 0 {
+		return false
+	}
+
+	err := cloneFile(f, src, fr.Length())
+	if err != nil {
+		debug.Log("cloning %v failed, falling back to copy: %v", src.Name(), err)
+		return false
+	}
+
+	// sanity check
+	fi, err := f.Stat()
+	if err != nil || fi.Size() != fr.Length() {
+		debug.Log("cloned file %v has unexpected size, falling back to copy", f.Name())
+		_ = f.Truncate(0)
+		return false
+	}
+	debug.Log("cloned %v", src.Name())
+	return true
+}
+
 // Load runs fn with a reader that yields the contents of the file at h at the
 // given offset.
 func (b *Local) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
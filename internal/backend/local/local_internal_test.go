@@ -1,6 +1,7 @@
 package local
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -40,3 +41,64 @@ func TestNoSpacePermanent(t *testing.T) {
 	rtest.Assert(t, errors.Is(err, syscall.ENOSPC),
 		"could not recover original ENOSPC error")
 }
+
+func TestSyncModes(t *testing.T) {
+	for _, mode := range []string{"", SyncAlways, SyncDirOnly, SyncNever} {
+		t.Run(mode, func(t *testing.T) {
+			dir := rtest.TempDir(t)
+
+			be, err := Create(context.Background(), Config{Path: dir, Connections: 2, Sync: mode})
+			rtest.OK(t, err)
+			defer func() {
+				rtest.OK(t, be.Close())
+			}()
+
+			data := []byte("foobar")
+			h := backend.Handle{Type: backend.ConfigFile}
+			rtest.OK(t, be.Save(context.Background(), h, backend.NewByteReader(data, nil)))
+
+			buf, err := os.ReadFile(be.Filename(h))
+			rtest.OK(t, err)
+			rtest.Equals(t, data, buf)
+		})
+	}
+
+	_, err := Open(context.Background(), Config{Path: rtest.TempDir(t), Sync: "sometimes"})
+	rtest.Assert(t, err != nil, "invalid sync mode was accepted")
+}
+
+func TestSaveFromFile(t *testing.T) {
+	dir := rtest.TempDir(t)
+	be, err := Create(context.Background(), Config{Path: dir, Connections: 2})
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, be.Close())
+	}()
+
+	data := rtest.Random(23, 3*1024*1024+17)
+	src, err := os.CreateTemp(dir, "src-")
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, src.Close())
+	}()
+	_, err = src.Write(data)
+	rtest.OK(t, err)
+
+	rd, err := backend.NewFileReader(src, nil)
+	rtest.OK(t, err)
+
+	// the content must be stored regardless of whether cloning is supported
+	h := backend.Handle{Type: backend.PackFile, Name: "0123456789abcdef"}
+	rtest.OK(t, be.Save(context.Background(), h, rd))
+
+	buf, err := os.ReadFile(be.Filename(h))
+	rtest.OK(t, err)
+	rtest.Assert(t, bytes.Equal(data, buf), "saved data does not match")
+
+	f, err := os.CreateTemp(dir, "dst-")
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, f.Close())
+	}()
+	rtest.Assert(t, !cloneFrom(f, backend.NewByteReader(data, nil)), "cloned data from a byte slice")
+}
@@ -0,0 +1,77 @@
+package nfs
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// Config contains all information required to access a directory on an NFS
+// server.
+type Config struct {
+	Host, Port string
+	Path       string
+
+	MountPort string `option:"mount-port" help:"port of the mount service (default: query the portmapper)"`
+
+	UID int `option:"uid" help:"user ID sent to the NFS server (default: current user)"`
+	GID int `option:"gid" help:"group ID sent to the NFS server (default: current group)"`
+
+	Connections uint          `option:"connections"  help:"set a limit for the number of concurrent operations (default: 5)"`
+	Timeout     time.Duration `option:"timeout"      help:"set a timeout for each request to the server (default: 1m)"`
+	IdleTimeout time.Duration `option:"idle-timeout" help:"close connections which were idle for longer than this (default: 1m)"`
+}
+
+// NewConfig returns a new config with default options applied.
+func NewConfig() Config {
+	return Config{
+		UID:         -1,
+		GID:         -1,
+		Connections: 5,
+		Timeout:     time.Minute,
+		IdleTimeout: time.Minute,
+	}
+}
+
+func init() {
+	options.Register("nfs", Config{})
+}
+
+// ParseConfig parses the string s and extracts the NFS config. The supported
+// configuration format is nfs://host[:port]/path, where path is the absolute
+// path of the repository on the server. Without port, the port of the NFS
+// service is queried from the portmapper of the server.
+func ParseConfig(s string) (*Config, error) {
+	if !strings.HasPrefix(s, "nfs://") {
+		return nil, errors.New(`invalid format, does not start with "nfs://"`)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if u.User != nil {
+		return nil, errors.Errorf("invalid backend %q, NFS does not use a user name, set the user ID using -o nfs.uid", s)
+	}
+
+	if u.Hostname() == "" {
+		return nil, errors.Errorf("invalid backend %q, no host specified", s)
+	}
+
+	dir := path.Clean("/" + u.Path)
+	if dir == "/" {
+		return nil, errors.Errorf("invalid backend %q, no path specified", s)
+	}
+
+	cfg := NewConfig()
+	cfg.Host = u.Hostname()
+	cfg.Port = u.Port()
+	cfg.Path = dir
+
+	return &cfg, nil
+}
@@ -0,0 +1,67 @@
+package nfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend/test"
+)
+
+var configTests = []test.ConfigTestData[Config]{
+	{
+		S: "nfs://server/srv/backup/restic",
+		Cfg: Config{
+			Host:        "server",
+			Path:        "/srv/backup/restic",
+			UID:         -1,
+			GID:         -1,
+			Connections: 5,
+			Timeout:     time.Minute,
+			IdleTimeout: time.Minute,
+		},
+	},
+	{
+		S: "nfs://server:2049/export//hosts/web01/",
+		Cfg: Config{
+			Host:        "server",
+			Port:        "2049",
+			Path:        "/export/hosts/web01",
+			UID:         -1,
+			GID:         -1,
+			Connections: 5,
+			Timeout:     time.Minute,
+			IdleTimeout: time.Minute,
+		},
+	},
+	{
+		S: "nfs://[::1]/export",
+		Cfg: Config{
+			Host:        "::1",
+			Path:        "/export",
+			UID:         -1,
+			GID:         -1,
+			Connections: 5,
+			Timeout:     time.Minute,
+			IdleTimeout: time.Minute,
+		},
+	},
+}
+
+func TestParseConfig(t *testing.T) {
+	test.ParseConfigTester(t, ParseConfig, configTests)
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	for _, s := range []string{
+		"smb://server/export",
+		"nfs:server:/export",
+		"nfs://server",
+		"nfs://server/",
+		"nfs:///export/path",
+		"nfs://user@server/export",
+	} {
+		if _, err := ParseConfig(s); err == nil {
+			t.Errorf("ParseConfig(%q) did not return an error", s)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+// Package nfs implements repository storage in a directory exported by an
+// NFSv3 server. The server is accessed using a small NFS client on top of ONC
+// RPC over TCP, so the export does not need to be mounted.
+package nfs
@@ -0,0 +1,117 @@
+package nfs
+
+import (
+	"context"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// constants of the portmapper (RFC 1833) and MOUNT protocol (RFC 1813)
+const (
+	portmapProgram     = 100000
+	portmapVersion     = 2
+	portmapProcGetPort = 3
+	portmapPort        = "111"
+
+	protoTCP = 6
+
+	mountProgram    = 100005
+	mountVersion    = 3
+	mountProcMnt    = 1
+	mountProcUmnt   = 3
+	mountProcExport = 5
+)
+
+// lookupPort asks the portmapper of host for the TCP port of the program.
+func lookupPort(ctx context.Context, host string, prog, vers uint32, timeout time.Duration) (string, error) {
+	c := newRPCClient(net.JoinHostPort(host, portmapPort), portmapProgram, portmapVersion, noCred, timeout, 0)
+	defer func() {
+		_ = c.Close()
+	}()
+
+	var args xdrWriter
+	args.uint32(prog)
+	args.uint32(vers)
+	args.uint32(protoTCP)
+	args.uint32(0)
+
+	r, err := c.call(ctx, portmapProcGetPort, args.buf)
+	if err != nil {
+		return "", errors.Wrap(err, "portmapper")
+	}
+	port := r.uint32()
+	if err := r.err(); err != nil {
+		return "", errors.Wrap(err, "portmapper")
+	}
+	if port == 0 {
+		return "", errors.Errorf("program %d version %d is not registered at the portmapper of %v", prog, vers, host)
+	}
+	return strconv.Itoa(int(port)), nil
+}
+
+// listExports returns the directories exported by the server.
+func listExports(ctx context.Context, c *rpcClient) ([]string, error) {
+	r, err := c.call(ctx, mountProcExport, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var exports []string
+	for r.bool() {
+		exports = append(exports, r.string(1024))
+		// groups allowed to mount the export
+		for r.bool() {
+			r.string(255)
+		}
+	}
+	return exports, r.err()
+}
+
+// findExport returns the export which contains dir. If several exports
+// contain dir, the longest one is used.
+func findExport(exports []string, dir string) (string, bool) {
+	var found string
+	ok := false
+	for _, export := range exports {
+		export = path.Clean(export)
+		if export == "/" || dir == export || strings.HasPrefix(dir, export+"/") {
+			if !ok || len(export) > len(found) {
+				found, ok = export, true
+			}
+		}
+	}
+	return found, ok
+}
+
+// mount returns the file handle of the exported directory dir.
+func mount(ctx context.Context, c *rpcClient, dir string) ([]byte, error) {
+	var args xdrWriter
+	args.string(dir)
+
+	r, err := c.call(ctx, mountProcMnt, args.buf)
+	if err != nil {
+		return nil, err
+	}
+	if status := r.uint32(); status != 0 {
+		if err := r.err(); err != nil {
+			return nil, err
+		}
+		return nil, &statusError{Op: "Mount " + dir, Status: status}
+	}
+	fh := r.opaque(fhSize)
+	return fh, r.err()
+}
+
+// unmount informs the server that dir is no longer mounted.
+func unmount(ctx context.Context, c *rpcClient, dir string) error {
+	var args xdrWriter
+	args.string(dir)
+
+	_, err := c.call(ctx, mountProcUmnt, args.buf)
+	return err
+}
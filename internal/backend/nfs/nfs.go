@@ -0,0 +1,658 @@
+package nfs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/layout"
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/util"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// NFS is a backend in a directory exported by an NFS server.
+type NFS struct {
+	mnt    *rpcClient
+	client client
+
+	// export is the mounted directory, root its file handle
+	export string
+	root   []byte
+	// repoDir is the directory of the repository relative to the export
+	repoDir string
+
+	rsize, wsize uint32
+
+	// handles of directories by their path relative to the export
+	mu   sync.Mutex
+	dirs map[string][]byte
+
+	layout.Layout
+	Config
+}
+
+var _ backend.SpaceBackend = &NFS{}
+
+var errTooShort = fmt.Errorf("file is too short")
+
+func NewFactory() location.Factory {
+	return location.NewLimitedBackendFactory("nfs", ParseConfig, location.NoPassword, limiter.WrapBackendConstructor(Create), limiter.WrapBackendConstructor(Open))
+}
+
+// dirMode and fileMode are used for new directories and files.
+const (
+	dirMode  = 0700
+	fileMode = 0600
+)
+
+// defaultPort is used if the portmapper does not know the NFS service.
+const defaultPort = "2049"
+
+// connect mounts the export containing the repository and connects to the
+// NFS service of the server.
+func connect(ctx context.Context, cfg Config) (*NFS, error) {
+	uid, gid := cfg.UID, cfg.GID
+	if uid < 0 {
+		uid = os.Getuid()
+	}
+	if gid < 0 {
+		gid = os.Getgid()
+	}
+	// there are no user IDs on Windows, use the user "nobody"
+	if uid < 0 {
+		uid = 65534
+	}
+	if gid < 0 {
+		gid = 65534
+	}
+
+	machine, err := os.Hostname()
+	if err != nil {
+		machine = "restic"
+	}
+	cred := authSysCred(machine, uint32(uid), uint32(gid))
+	debug.Log("connect to %v as uid %d gid %d", cfg.Host, uid, gid)
+
+	mountPort := cfg.MountPort
+	if mountPort == "" {
+		mountPort, err = lookupPort(ctx, cfg.Host, mountProgram, mountVersion, cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+	mnt := newRPCClient(net.JoinHostPort(cfg.Host, mountPort), mountProgram, mountVersion, cred, cfg.Timeout, cfg.IdleTimeout)
+
+	// the repository may be located in a subdirectory of the export
+	export := cfg.Path
+	exports, err := listExports(ctx, mnt)
+	if err != nil {
+		debug.Log("listing exports failed, mounting %v: %v", cfg.Path, err)
+	} else if e, ok := findExport(exports, cfg.Path); ok {
+		export = e
+	}
+
+	root, err := mount(ctx, mnt, export)
+	if err != nil {
+		_ = mnt.Close()
+		return nil, err
+	}
+
+	port := cfg.Port
+	if port == "" {
+		port, err = lookupPort(ctx, cfg.Host, nfsProgram, nfsVersion, cfg.Timeout)
+		if err != nil {
+			debug.Log("using default port: %v", err)
+			port = defaultPort
+		}
+	}
+
+	repoDir := strings.TrimPrefix(strings.TrimPrefix(cfg.Path, export), "/")
+	be := &NFS{
+		mnt:     mnt,
+		client:  client{newRPCClient(net.JoinHostPort(cfg.Host, port), nfsProgram, nfsVersion, cred, cfg.Timeout, cfg.IdleTimeout)},
+		export:  export,
+		root:    root,
+		repoDir: repoDir,
+		dirs:    make(map[string][]byte),
+		Layout:  &layout.DefaultLayout{Path: repoDir, Join: path.Join},
+		Config:  cfg,
+	}
+
+	be.rsize, be.wsize, err = be.client.fsInfo(ctx, root)
+	if err != nil {
+		_ = be.Close()
+		return nil, err
+	}
+	debug.Log("mounted %v, read size %d, write size %d", export, be.rsize, be.wsize)
+
+	return be, nil
+}
+
+// Open opens the NFS backend as described by the config.
+func Open(ctx context.Context, cfg Config) (*NFS, error) {
+	debug.Log("open backend with config %#v", cfg)
+	return connect(ctx, cfg)
+}
+
+// Create creates all the necessary directories for a new repository on the
+// NFS server. Afterwards a new config blob should be created.
+func Create(ctx context.Context, cfg Config) (*NFS, error) {
+	be, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// test if config file already exists
+	_, _, err = be.lookupFile(ctx, be.Filename(backend.Handle{Type: backend.ConfigFile}))
+	if err == nil {
+		_ = be.Close()
+		return nil, errors.New("config file already exists")
+	}
+
+	if err = be.mkdirAllDataSubdirs(ctx, cfg.Connections); err != nil {
+		_ = be.Close()
+		return nil, err
+	}
+
+	return be, nil
+}
+
+func (r *NFS) mkdirAllDataSubdirs(ctx context.Context, nconn uint) error {
+	// Run multiple mkdirAll calls concurrently, each of them needs several
+	// round trips to the server.
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(int(nconn))
+
+	for _, d := range r.Paths() {
+		d := d
+		g.Go(func() error {
+			_, err := r.mkdirAll(gCtx, d)
+			return err
+		})
+	}
+
+	return g.Wait()
+}
+
+// dirHandle returns the handle of the directory dir, which is relative to
+// the export. The handles are cached.
+func (r *NFS) dirHandle(ctx context.Context, dir string) ([]byte, error) {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	if dir == "" {
+		return r.root, nil
+	}
+
+	r.mu.Lock()
+	fh, ok := r.dirs[dir]
+	r.mu.Unlock()
+	if ok {
+		return fh, nil
+	}
+
+	parentDir, name := path.Split(dir)
+	parent, err := r.dirHandle(ctx, parentDir)
+	if err != nil {
+		return nil, err
+	}
+	fh, a, err := r.client.lookup(ctx, parent, name)
+	if err != nil {
+		return nil, err
+	}
+	if a.Type != typeDirectory {
+		return nil, &statusError{Op: "Lookup " + dir, Status: statusNotDir}
+	}
+
+	r.mu.Lock()
+	r.dirs[dir] = fh
+	r.mu.Unlock()
+	return fh, nil
+}
+
+// mkdirAll creates the directory dir and all its parents if they do not
+// exist and returns its handle.
+func (r *NFS) mkdirAll(ctx context.Context, dir string) ([]byte, error) {
+	fh, err := r.dirHandle(ctx, dir)
+	if !r.IsNotExist(err) {
+		return fh, err
+	}
+
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	parentDir, name := path.Split(dir)
+	parent, err := r.mkdirAll(ctx, parentDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fh, err = r.client.mkdir(ctx, parent, name, dirMode)
+	if errors.Is(err, os.ErrExist) {
+		// created concurrently
+		return r.dirHandle(ctx, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.dirs[dir] = fh
+	r.mu.Unlock()
+	return fh, nil
+}
+
+// lookupFile returns the handle and the attributes of the file name.
+func (r *NFS) lookupFile(ctx context.Context, name string) ([]byte, attr, error) {
+	dirname, base := path.Split(name)
+	dir, err := r.dirHandle(ctx, dirname)
+	if err != nil {
+		return nil, attr{}, err
+	}
+	return r.client.lookup(ctx, dir, base)
+}
+
+func (r *NFS) Connections() uint {
+	return r.Config.Connections
+}
+
+// Hasher may return a hash function for calculating a content hash for the backend
+func (r *NFS) Hasher() hash.Hash {
+	return nil
+}
+
+// HasAtomicReplace returns whether Save() can atomically replace files
+func (r *NFS) HasAtomicReplace() bool {
+	return true
+}
+
+// IsNotExist returns true if the error is caused by a not existing file.
+func (r *NFS) IsNotExist(err error) bool {
+	return errors.Is(err, os.ErrNotExist)
+}
+
+func (r *NFS) IsPermanentError(err error) bool {
+	return r.IsNotExist(err) || errors.Is(err, errTooShort) || errors.Is(err, os.ErrPermission)
+}
+
+// tempSuffix generates a random string suffix that should be sufficiently long
+// to avoid accidental conflicts
+func tempSuffix() string {
+	var nonce [16]byte
+	_, err := rand.Read(nonce[:])
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(nonce[:])
+}
+
+// Save stores data in the backend at the handle.
+func (r *NFS) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) (err error) {
+	dirname, name := path.Split(r.Filename(h))
+	tmpName := name + "-restic-temp-" + tempSuffix()
+
+	dir, err := r.dirHandle(ctx, dirname)
+	if r.IsNotExist(err) {
+		// the directory is missing, try to create it
+		dir, err = r.mkdirAll(ctx, dirname)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	fh, err := r.client.create(ctx, dir, tmpName, fileMode)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// Try not to leave a partial file behind.
+		rmErr := r.client.remove(ctx, dir, tmpName)
+		if rmErr != nil {
+			debug.Log("nfs: failed to remove broken file %v: %v", tmpName, rmErr)
+		}
+	}()
+
+	if err = r.writeFile(ctx, fh, rd); err != nil {
+		return err
+	}
+
+	err = r.client.rename(ctx, dir, tmpName, dir, name)
+	return errors.WithStack(err)
+}
+
+// writeFile writes the data of rd to the file and commits it to stable
+// storage on the server.
+func (r *NFS) writeFile(ctx context.Context, fh []byte, rd backend.RewindReader) error {
+	buf := make([]byte, r.wsize)
+	var offset uint64
+	var verf [8]byte
+
+	for {
+		n, rerr := io.ReadFull(rd, buf)
+		data := buf[:n]
+		for len(data) > 0 {
+			written, wverf, err := r.client.write(ctx, fh, offset, data)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if written == 0 {
+				return errors.New("server did not accept written data")
+			}
+			if offset == 0 {
+				verf = wverf
+			} else if wverf != verf {
+				return errors.New("server restarted during the upload")
+			}
+			offset += uint64(written)
+			data = data[written:]
+		}
+
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return errors.Wrap(rerr, "Read")
+		}
+	}
+
+	// sanity check
+	if offset != uint64(rd.Length()) {
+		return errors.Errorf("wrote %d bytes instead of the expected %d bytes", offset, rd.Length())
+	}
+
+	cverf, err := r.client.commit(ctx, fh)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if offset > 0 && cverf != verf {
+		return errors.New("server restarted during the upload")
+	}
+	return nil
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset.
+func (r *NFS) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	return util.DefaultLoad(ctx, h, length, offset, r.openReader, func(rd io.Reader) error {
+		if length == 0 || !feature.Flag.Enabled(feature.BackendErrorRedesign) {
+			return fn(rd)
+		}
+
+		// rd is a LimitedReader which can be used to track the number of bytes read
+		err := fn(rd)
+
+		// check the underlying reader to be agnostic to however fn() handles the returned error
+		_, rderr := rd.Read([]byte{0})
+		if rderr == io.EOF && rd.(*util.LimitedReadCloser).N != 0 {
+			// file is too short
+			return fmt.Errorf("%w: %v", errTooShort, err)
+		}
+
+		return err
+	})
+}
+
+func (r *NFS) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
+	fh, _, err := r.lookupFile(ctx, r.Filename(h))
+	if err != nil {
+		return nil, err
+	}
+
+	rd := &fileReader{
+		ctx:       ctx,
+		client:    r.client,
+		fh:        fh,
+		offset:    uint64(offset),
+		remaining: -1,
+		size:      r.rsize,
+	}
+
+	if length > 0 {
+		rd.remaining = int64(length)
+		return util.LimitReadCloser(rd, int64(length)), nil
+	}
+
+	return rd, nil
+}
+
+// fileReader reads a file using READ calls of the given size.
+type fileReader struct {
+	ctx    context.Context
+	client client
+	fh     []byte
+	offset uint64
+	// remaining is the number of bytes left to read, or -1 to read the whole file
+	remaining int64
+	size      uint32
+
+	buf []byte
+	eof bool
+}
+
+func (f *fileReader) Read(p []byte) (int, error) {
+	for len(f.buf) == 0 {
+		if f.eof || f.remaining == 0 {
+			return 0, io.EOF
+		}
+
+		count := f.size
+		if f.remaining > 0 && f.remaining < int64(count) {
+			count = uint32(f.remaining)
+		}
+		data, eof, err := f.client.read(f.ctx, f.fh, f.offset, count)
+		if err != nil {
+			return 0, err
+		}
+
+		f.offset += uint64(len(data))
+		if f.remaining > 0 {
+			f.remaining -= int64(len(data))
+		}
+		f.buf = data
+		f.eof = eof || len(data) == 0
+	}
+
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+func (f *fileReader) Close() error {
+	return nil
+}
+
+// Stat returns information about a blob.
+func (r *NFS) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	_, a, err := r.lookupFile(ctx, r.Filename(h))
+	if err != nil {
+		return backend.FileInfo{}, errors.WithStack(err)
+	}
+
+	return backend.FileInfo{Size: int64(a.Size), Name: h.Name}, nil
+}
+
+// Space returns the size and free space of the exported file system as seen
+// by the user, which includes the quota configured on the server.
+func (r *NFS) Space(ctx context.Context) (backend.SpaceInfo, error) {
+	return r.client.fsStat(ctx, r.root)
+}
+
+// Remove removes the content stored at name.
+func (r *NFS) Remove(ctx context.Context, h backend.Handle) error {
+	dirname, name := path.Split(r.Filename(h))
+	dir, err := r.dirHandle(ctx, dirname)
+	if err != nil {
+		return err
+	}
+	return r.client.remove(ctx, dir, name)
+}
+
+// entryAttr returns the attributes of a directory entry, which are looked up
+// if the server did not include them.
+func (r *NFS) entryAttr(ctx context.Context, dir []byte, e dirEntry) (attr, error) {
+	if e.HasAttr {
+		return e.Attr, nil
+	}
+	_, a, err := r.client.lookup(ctx, dir, e.Name)
+	return a, err
+}
+
+// List runs fn for each file in the backend which has the type t. When an
+// error occurs (or fn returns an error), List stops and returns it.
+func (r *NFS) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	basedir, subdirs := r.Basedir(t)
+
+	dirs := []string{basedir}
+	if subdirs {
+		base, err := r.dirHandle(ctx, basedir)
+		if r.IsNotExist(err) {
+			debug.Log("ignoring non-existing directory")
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "ReadDir(%v)", basedir)
+		}
+
+		dirs = dirs[:0]
+		err = r.client.readDir(ctx, base, func(e dirEntry) error {
+			a, err := r.entryAttr(ctx, base, e)
+			if err != nil {
+				return err
+			}
+			if a.Type == typeDirectory {
+				dirs = append(dirs, path.Join(basedir, e.Name))
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "ReadDir(%v)", basedir)
+		}
+	}
+
+	for _, dir := range dirs {
+		fh, err := r.dirHandle(ctx, dir)
+		if r.IsNotExist(err) {
+			debug.Log("ignoring non-existing directory")
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "ReadDir(%v)", dir)
+		}
+
+		// collect the entries first, fn may take a long time
+		var files []backend.FileInfo
+		err = r.client.readDir(ctx, fh, func(e dirEntry) error {
+			a, err := r.entryAttr(ctx, fh, e)
+			if err != nil {
+				return err
+			}
+			if a.Type == typeRegular {
+				files = append(files, backend.FileInfo{Name: e.Name, Size: int64(a.Size)})
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "ReadDir(%v)", dir)
+		}
+
+		for _, fi := range files {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			err := fn(fi)
+			if err != nil {
+				return err
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Close unmounts the export and closes the connections to the server.
+func (r *NFS) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	err := unmount(context.Background(), r.mnt, r.export)
+	debug.Log("unmount returned error %v", err)
+	if merr := r.mnt.Close(); merr != nil {
+		debug.Log("closing mount client returned error %v", merr)
+	}
+	return r.client.Close()
+}
+
+func (r *NFS) deleteRecursive(ctx context.Context, dir []byte) error {
+	var entries []dirEntry
+	err := r.client.readDir(ctx, dir, func(e dirEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "ReadDir")
+	}
+
+	for _, e := range entries {
+		a, err := r.entryAttr(ctx, dir, e)
+		if err != nil {
+			return err
+		}
+
+		if a.Type == typeDirectory {
+			fh := e.Handle
+			if fh == nil {
+				fh, _, err = r.client.lookup(ctx, dir, e.Name)
+				if err != nil {
+					return err
+				}
+			}
+			if err := r.deleteRecursive(ctx, fh); err != nil {
+				return err
+			}
+			err = r.client.rmdir(ctx, dir, e.Name)
+		} else {
+			err = r.client.remove(ctx, dir, e.Name)
+		}
+		if err != nil {
+			return errors.Wrap(err, "Remove")
+		}
+	}
+
+	return nil
+}
+
+// Delete removes all data in the backend.
+func (r *NFS) Delete(ctx context.Context) error {
+	dir, err := r.dirHandle(ctx, r.repoDir)
+	if err != nil {
+		return err
+	}
+	err = r.deleteRecursive(ctx, dir)
+
+	r.mu.Lock()
+	r.dirs = make(map[string][]byte)
+	r.mu.Unlock()
+	return err
+}
@@ -0,0 +1,467 @@
+package nfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/restic/restic/internal/backend"
+)
+
+// constants of the NFSv3 protocol (RFC 1813)
+const (
+	nfsProgram = 100003
+	nfsVersion = 3
+
+	nfsProcGetAttr     = 1
+	nfsProcLookup      = 3
+	nfsProcRead        = 6
+	nfsProcWrite       = 7
+	nfsProcCreate      = 8
+	nfsProcMkdir       = 9
+	nfsProcRemove      = 12
+	nfsProcRmdir       = 13
+	nfsProcRename      = 14
+	nfsProcReadDirPlus = 17
+	nfsProcFsStat      = 18
+	nfsProcFsInfo      = 19
+	nfsProcCommit      = 21
+
+	// fhSize is the maximum size of a file handle.
+	fhSize = 64
+
+	typeRegular   = 1
+	typeDirectory = 2
+
+	stableUnstable = 0
+
+	createGuarded = 1
+)
+
+// status codes returned by the server, the MOUNT protocol uses the same
+// values for the errors it shares with NFS
+const (
+	statusPerm        = 1
+	statusNoEnt       = 2
+	statusIO          = 5
+	statusAccess      = 13
+	statusExist       = 17
+	statusNotDir      = 20
+	statusIsDir       = 21
+	statusInval       = 22
+	statusNoSpace     = 28
+	statusReadOnly    = 30
+	statusNameTooLong = 63
+	statusNotEmpty    = 66
+	statusQuota       = 69
+	statusStale       = 70
+	statusBadHandle   = 10001
+	statusNotSupp     = 10004
+	statusServerFault = 10006
+	statusJukebox     = 10008
+)
+
+var statusText = map[uint32]string{
+	statusPerm:        "operation not permitted",
+	statusNoEnt:       "no such file or directory",
+	statusIO:          "I/O error",
+	statusAccess:      "permission denied",
+	statusExist:       "file exists",
+	statusNotDir:      "not a directory",
+	statusIsDir:       "is a directory",
+	statusInval:       "invalid argument",
+	statusNoSpace:     "no space left on device",
+	statusReadOnly:    "read-only file system",
+	statusNameTooLong: "file name too long",
+	statusNotEmpty:    "directory not empty",
+	statusQuota:       "disk quota exceeded",
+	statusStale:       "stale file handle",
+	statusBadHandle:   "invalid file handle",
+	statusNotSupp:     "operation not supported",
+	statusServerFault: "server fault",
+	statusJukebox:     "server busy, try again later",
+}
+
+// statusError is an error status returned by the server.
+type statusError struct {
+	Op     string
+	Status uint32
+}
+
+func (e *statusError) Error() string {
+	text, ok := statusText[e.Status]
+	if !ok {
+		text = fmt.Sprintf("status %d", e.Status)
+	}
+	return fmt.Sprintf("%v: %v", e.Op, text)
+}
+
+// Is allows comparing the error with os.ErrNotExist, os.ErrExist and
+// os.ErrPermission using errors.Is.
+func (e *statusError) Is(target error) bool {
+	switch target {
+	case os.ErrNotExist:
+		return e.Status == statusNoEnt
+	case os.ErrExist:
+		return e.Status == statusExist
+	case os.ErrPermission:
+		return e.Status == statusPerm || e.Status == statusAccess
+	}
+	return false
+}
+
+// attr contains the attributes of a file which are used by the backend.
+type attr struct {
+	Type uint32
+	Mode uint32
+	Size uint64
+}
+
+func (r *xdrReader) fattr() attr {
+	var a attr
+	a.Type = r.uint32()
+	a.Mode = r.uint32()
+	// nlink, uid, gid
+	r.take(3 * 4)
+	a.Size = r.uint64()
+	// used, rdev, fsid, fileid, atime, mtime, ctime
+	r.take(8 + 8 + 8 + 8 + 3*8)
+	return a
+}
+
+// postOpAttr decodes optional attributes.
+func (r *xdrReader) postOpAttr() (attr, bool) {
+	if !r.bool() {
+		return attr{}, false
+	}
+	return r.fattr(), true
+}
+
+// wccData skips the attributes of an object before and after an operation.
+func (r *xdrReader) wccData() {
+	if r.bool() {
+		// size, mtime, ctime
+		r.take(8 + 8 + 8)
+	}
+	r.postOpAttr()
+}
+
+// postOpFH decodes an optional file handle.
+func (r *xdrReader) postOpFH() ([]byte, bool) {
+	if !r.bool() {
+		return nil, false
+	}
+	return r.opaque(fhSize), true
+}
+
+// client implements the NFSv3 procedures used by the backend.
+type client struct {
+	*rpcClient
+}
+
+// nfsCall runs the procedure and checks the status of the result. For
+// failed calls, the error is returned together with the reader for the
+// remaining results.
+func (c *client) nfsCall(ctx context.Context, op string, proc uint32, args *xdrWriter) (*xdrReader, error) {
+	r, err := c.call(ctx, proc, args.buf)
+	if err != nil {
+		return nil, err
+	}
+	status := r.uint32()
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	if status != 0 {
+		return r, &statusError{Op: op, Status: status}
+	}
+	return r, nil
+}
+
+func writeDirOp(w *xdrWriter, dir []byte, name string) {
+	w.opaque(dir)
+	w.string(name)
+}
+
+// writeSetAttr encodes attributes which set only the mode.
+func writeSetAttr(w *xdrWriter, mode uint32) {
+	w.bool(true)
+	w.uint32(mode)
+	// uid, gid, size
+	w.bool(false)
+	w.bool(false)
+	w.bool(false)
+	// atime and mtime are not changed
+	w.uint32(0)
+	w.uint32(0)
+}
+
+func (c *client) getAttr(ctx context.Context, fh []byte) (attr, error) {
+	var args xdrWriter
+	args.opaque(fh)
+
+	r, err := c.nfsCall(ctx, "GetAttr", nfsProcGetAttr, &args)
+	if err != nil {
+		return attr{}, err
+	}
+	a := r.fattr()
+	return a, r.err()
+}
+
+// lookup returns the handle and the attributes of the file name in dir.
+func (c *client) lookup(ctx context.Context, dir []byte, name string) ([]byte, attr, error) {
+	var args xdrWriter
+	writeDirOp(&args, dir, name)
+
+	r, err := c.nfsCall(ctx, "Lookup "+name, nfsProcLookup, &args)
+	if err != nil {
+		return nil, attr{}, err
+	}
+	fh := r.opaque(fhSize)
+	a, ok := r.postOpAttr()
+	if err := r.err(); err != nil {
+		return nil, attr{}, err
+	}
+	if !ok {
+		a, err = c.getAttr(ctx, fh)
+	}
+	return fh, a, err
+}
+
+// read reads up to count bytes at offset.
+func (c *client) read(ctx context.Context, fh []byte, offset uint64, count uint32) (data []byte, eof bool, err error) {
+	var args xdrWriter
+	args.opaque(fh)
+	args.uint64(offset)
+	args.uint32(count)
+
+	r, err := c.nfsCall(ctx, "Read", nfsProcRead, &args)
+	if err != nil {
+		return nil, false, err
+	}
+	r.postOpAttr()
+	r.uint32()
+	eof = r.bool()
+	data = r.opaque(count)
+	return data, eof, r.err()
+}
+
+// write writes data at offset without requiring the server to store it on
+// stable storage. It returns the number of bytes written and the write
+// verifier, which changes if the server restarts and loses unstable data.
+func (c *client) write(ctx context.Context, fh []byte, offset uint64, data []byte) (uint32, [8]byte, error) {
+	var args xdrWriter
+	args.opaque(fh)
+	args.uint64(offset)
+	args.uint32(uint32(len(data)))
+	args.uint32(stableUnstable)
+	args.opaque(data)
+
+	var verf [8]byte
+	r, err := c.nfsCall(ctx, "Write", nfsProcWrite, &args)
+	if err != nil {
+		return 0, verf, err
+	}
+	r.wccData()
+	n := r.uint32()
+	r.uint32()
+	copy(verf[:], r.fixed(8))
+	return n, verf, r.err()
+}
+
+// commit requests the server to store all written data on stable storage and
+// returns the write verifier.
+func (c *client) commit(ctx context.Context, fh []byte) ([8]byte, error) {
+	var args xdrWriter
+	args.opaque(fh)
+	args.uint64(0)
+	args.uint32(0)
+
+	var verf [8]byte
+	r, err := c.nfsCall(ctx, "Commit", nfsProcCommit, &args)
+	if err != nil {
+		return verf, err
+	}
+	r.wccData()
+	copy(verf[:], r.fixed(8))
+	return verf, r.err()
+}
+
+// newObject decodes the result of CREATE and MKDIR and returns the handle of
+// the new object, which is looked up if the server did not return it.
+func (c *client) newObject(ctx context.Context, r *xdrReader, dir []byte, name string) ([]byte, error) {
+	fh, ok := r.postOpFH()
+	if err := r.err(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		fh, _, err := c.lookup(ctx, dir, name)
+		return fh, err
+	}
+	return fh, nil
+}
+
+// create creates the file name in dir, it fails if the file already exists.
+func (c *client) create(ctx context.Context, dir []byte, name string, mode uint32) ([]byte, error) {
+	var args xdrWriter
+	writeDirOp(&args, dir, name)
+	args.uint32(createGuarded)
+	writeSetAttr(&args, mode)
+
+	r, err := c.nfsCall(ctx, "Create "+name, nfsProcCreate, &args)
+	if err != nil {
+		return nil, err
+	}
+	return c.newObject(ctx, r, dir, name)
+}
+
+func (c *client) mkdir(ctx context.Context, dir []byte, name string, mode uint32) ([]byte, error) {
+	var args xdrWriter
+	writeDirOp(&args, dir, name)
+	writeSetAttr(&args, mode)
+
+	r, err := c.nfsCall(ctx, "Mkdir "+name, nfsProcMkdir, &args)
+	if err != nil {
+		return nil, err
+	}
+	return c.newObject(ctx, r, dir, name)
+}
+
+func (c *client) remove(ctx context.Context, dir []byte, name string) error {
+	var args xdrWriter
+	writeDirOp(&args, dir, name)
+
+	_, err := c.nfsCall(ctx, "Remove "+name, nfsProcRemove, &args)
+	return err
+}
+
+func (c *client) rmdir(ctx context.Context, dir []byte, name string) error {
+	var args xdrWriter
+	writeDirOp(&args, dir, name)
+
+	_, err := c.nfsCall(ctx, "Rmdir "+name, nfsProcRmdir, &args)
+	return err
+}
+
+// rename renames a file, an existing file at the target is replaced.
+func (c *client) rename(ctx context.Context, fromDir []byte, fromName string, toDir []byte, toName string) error {
+	var args xdrWriter
+	writeDirOp(&args, fromDir, fromName)
+	writeDirOp(&args, toDir, toName)
+
+	_, err := c.nfsCall(ctx, "Rename "+fromName, nfsProcRename, &args)
+	return err
+}
+
+// dirEntry is an entry of a directory.
+type dirEntry struct {
+	Name    string
+	Handle  []byte
+	Attr    attr
+	HasAttr bool
+}
+
+// readDir calls fn for all entries of the directory except "." and "..".
+func (c *client) readDir(ctx context.Context, dir []byte, fn func(dirEntry) error) error {
+	var cookie uint64
+	verf := make([]byte, 8)
+
+	for {
+		var args xdrWriter
+		args.opaque(dir)
+		args.uint64(cookie)
+		args.fixed(verf)
+		// maximum size of the names and of the complete reply
+		args.uint32(32 << 10)
+		args.uint32(256 << 10)
+
+		r, err := c.nfsCall(ctx, "ReadDirPlus", nfsProcReadDirPlus, &args)
+		if err != nil {
+			return err
+		}
+		r.postOpAttr()
+		verf = r.fixed(8)
+
+		for r.bool() {
+			r.uint64()
+			var e dirEntry
+			e.Name = r.string(255)
+			cookie = r.uint64()
+			e.Attr, e.HasAttr = r.postOpAttr()
+			e.Handle, _ = r.postOpFH()
+			if err := r.err(); err != nil {
+				return err
+			}
+
+			if e.Name == "." || e.Name == ".." {
+				continue
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+
+		eof := r.bool()
+		if err := r.err(); err != nil {
+			return err
+		}
+		if eof {
+			return nil
+		}
+	}
+}
+
+// fsStat returns the size and the free space of the file system.
+func (c *client) fsStat(ctx context.Context, fh []byte) (backend.SpaceInfo, error) {
+	var args xdrWriter
+	args.opaque(fh)
+
+	r, err := c.nfsCall(ctx, "FsStat", nfsProcFsStat, &args)
+	if err != nil {
+		return backend.SpaceInfo{}, err
+	}
+	r.postOpAttr()
+	info := backend.SpaceInfo{
+		Total:     r.uint64(),
+		Free:      r.uint64(),
+		Available: r.uint64(),
+	}
+	return info, r.err()
+}
+
+// fsInfo returns the preferred sizes of read and write requests.
+func (c *client) fsInfo(ctx context.Context, fh []byte) (rsize, wsize uint32, err error) {
+	var args xdrWriter
+	args.opaque(fh)
+
+	r, err := c.nfsCall(ctx, "FsInfo", nfsProcFsInfo, &args)
+	if err != nil {
+		return 0, 0, err
+	}
+	r.postOpAttr()
+	// rtmax, rtpref, rtmult, wtmax, wtpref
+	rtmax := r.uint32()
+	rsize = r.uint32()
+	r.uint32()
+	wtmax := r.uint32()
+	wsize = r.uint32()
+	if err := r.err(); err != nil {
+		return 0, 0, err
+	}
+	return transferSize(rsize, rtmax), transferSize(wsize, wtmax), nil
+}
+
+// maxTransferSize limits the size of read and write requests.
+const maxTransferSize = 1 << 20
+
+// transferSize returns the size for requests, which is the preferred size
+// but at most the maximum size.
+func transferSize(pref, max uint32) uint32 {
+	size := pref
+	if size == 0 || (max > 0 && size > max) {
+		size = max
+	}
+	if size == 0 || size > maxTransferSize {
+		size = maxTransferSize
+	}
+	return size
+}
@@ -0,0 +1,96 @@
+package nfs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/test"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func newTestServerConfig(srv *testServer) (*Config, error) {
+	cfg, err := ParseConfig(fmt.Sprintf("nfs://127.0.0.1:%v%v/test-%d", srv.port(), srv.export, time.Now().UnixNano()))
+	if err != nil {
+		return nil, err
+	}
+	cfg.MountPort = cfg.Port
+	return cfg, nil
+}
+
+func TestBackendTestServer(t *testing.T) {
+	srv := newTestServer(t)
+	suite := &test.Suite[Config]{
+		NewConfig: func() (*Config, error) {
+			return newTestServerConfig(srv)
+		},
+		Factory: NewFactory(),
+	}
+	suite.RunTests(t)
+}
+
+func TestSpace(t *testing.T) {
+	srv := newTestServer(t)
+	cfg, err := newTestServerConfig(srv)
+	rtest.OK(t, err)
+
+	be, err := Create(context.TODO(), *cfg)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, be.Close())
+	}()
+
+	space, err := be.Space(context.TODO())
+	rtest.OK(t, err)
+	rtest.Equals(t, backend.SpaceInfo{Total: 1000, Free: 600, Available: 100}, space)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	srv := newTestServer(t)
+	cfg, err := newTestServerConfig(srv)
+	rtest.OK(t, err)
+	cfg.IdleTimeout = 50 * time.Millisecond
+
+	be, err := Create(context.TODO(), *cfg)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, be.Close())
+	}()
+
+	h := backend.Handle{Type: backend.ConfigFile}
+	stat := func() {
+		_, err := be.Stat(context.TODO(), h)
+		rtest.Assert(t, be.IsNotExist(err), "unexpected error %v", err)
+	}
+
+	stat()
+	conns := srv.connections()
+	stat()
+	rtest.Equals(t, conns, srv.connections())
+
+	// the idle connection must be replaced
+	time.Sleep(2 * cfg.IdleTimeout)
+	stat()
+	rtest.Equals(t, conns+1, srv.connections())
+}
+
+func TestFindExport(t *testing.T) {
+	exports := []string{"/srv", "/srv/backup", "/srv/backup-old", "/data/"}
+	for _, test := range []struct {
+		dir    string
+		export string
+		ok     bool
+	}{
+		{"/srv/backup/repo", "/srv/backup", true},
+		{"/srv/backup", "/srv/backup", true},
+		{"/srv/backup-older/repo", "/srv", true},
+		{"/data/repo", "/data", true},
+		{"/home/repo", "", false},
+	} {
+		export, ok := findExport(exports, test.dir)
+		rtest.Equals(t, test.ok, ok)
+		rtest.Equals(t, test.export, export)
+	}
+}
@@ -0,0 +1,51 @@
+package nfs_test
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend/nfs"
+	"github.com/restic/restic/internal/backend/test"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func newNFSTestSuite() *test.Suite[nfs.Config] {
+	return &test.Suite[nfs.Config]{
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (*nfs.Config, error) {
+			cfg, err := nfs.ParseConfig(os.Getenv("RESTIC_TEST_NFS_REPOSITORY"))
+			if err != nil {
+				return nil, err
+			}
+
+			cfg.Path = path.Join(cfg.Path, fmt.Sprintf("test-%d", time.Now().UnixNano()))
+			return cfg, nil
+		},
+
+		Factory: nfs.NewFactory(),
+	}
+}
+
+func TestBackendNFS(t *testing.T) {
+	defer func() {
+		if t.Skipped() {
+			rtest.SkipDisallowed(t, "restic/backend/nfs.TestBackendNFS")
+		}
+	}()
+
+	if os.Getenv("RESTIC_TEST_NFS_REPOSITORY") == "" {
+		t.Skip("environment variable RESTIC_TEST_NFS_REPOSITORY not set")
+	}
+	newNFSTestSuite().RunTests(t)
+}
+
+func BenchmarkBackendNFS(t *testing.B) {
+	if os.Getenv("RESTIC_TEST_NFS_REPOSITORY") == "" {
+		t.Skip("environment variable RESTIC_TEST_NFS_REPOSITORY not set")
+	}
+	newNFSTestSuite().RunBenchmarks(t)
+}
@@ -0,0 +1,323 @@
+package nfs
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// constants of the ONC RPC protocol (RFC 5531)
+const (
+	rpcVersion = 2
+
+	msgCall  = 0
+	msgReply = 1
+
+	replyAccepted = 0
+	replyDenied   = 1
+
+	acceptSuccess = 0
+
+	authNone = 0
+	authSys  = 1
+)
+
+// lastFragment is set in the record mark of the last fragment of a message.
+const lastFragment = 1 << 31
+
+// maxReplySize limits the size of a reply, the largest replies contain the
+// data of a READ call.
+const maxReplySize = 16 << 20
+
+var acceptErrors = map[uint32]string{
+	1: "program unavailable",
+	2: "program version mismatch",
+	3: "procedure unavailable",
+	4: "garbage arguments",
+	5: "system error",
+}
+
+// rpcClient calls the procedures of a program on the server using ONC RPC
+// over TCP. Each connection is used for one call at a time. Connections are
+// reused for subsequent calls unless they were idle for longer than
+// idleTimeout, servers usually close idle connections after some minutes.
+type rpcClient struct {
+	addr        string
+	prog, vers  uint32
+	cred        []byte
+	timeout     time.Duration
+	idleTimeout time.Duration
+
+	xid uint32
+
+	mu     sync.Mutex
+	idle   []*rpcConn
+	closed bool
+}
+
+type rpcConn struct {
+	net.Conn
+	rd       *bufio.Reader
+	lastUsed time.Time
+}
+
+func newRPCClient(addr string, prog, vers uint32, cred []byte, timeout, idleTimeout time.Duration) *rpcClient {
+	return &rpcClient{
+		addr:        addr,
+		prog:        prog,
+		vers:        vers,
+		cred:        cred,
+		timeout:     timeout,
+		idleTimeout: idleTimeout,
+		xid:         uint32(time.Now().UnixNano()),
+	}
+}
+
+// noCred is the encoded AUTH_NONE credential, which is also used as verifier.
+var noCred = []byte{0, 0, 0, 0, 0, 0, 0, 0}
+
+// authSysCred returns the AUTH_SYS credential for uid and gid.
+func authSysCred(machine string, uid, gid uint32) []byte {
+	var body xdrWriter
+	body.uint32(uint32(time.Now().Unix()))
+	if len(machine) > 255 {
+		machine = machine[:255]
+	}
+	body.string(machine)
+	body.uint32(uid)
+	body.uint32(gid)
+	// no supplementary groups
+	body.uint32(0)
+
+	var w xdrWriter
+	w.uint32(authSys)
+	w.opaque(body.buf)
+	return w.buf
+}
+
+// get returns an idle connection or establishes a new one.
+func (c *rpcClient) get(ctx context.Context) (*rpcConn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errors.New("client is closed")
+	}
+	var conn *rpcConn
+	for len(c.idle) > 0 && conn == nil {
+		conn = c.idle[len(c.idle)-1]
+		c.idle = c.idle[:len(c.idle)-1]
+		if c.idleTimeout > 0 && time.Since(conn.lastUsed) > c.idleTimeout {
+			debug.Log("closing connection to %v idle since %v", c.addr, conn.lastUsed)
+			_ = conn.Close()
+			conn = nil
+		}
+	}
+	c.mu.Unlock()
+
+	if conn != nil {
+		return conn, nil
+	}
+
+	nc, err := dial(ctx, c.addr, c.timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "Dial")
+	}
+	return &rpcConn{Conn: nc, rd: bufio.NewReader(nc)}, nil
+}
+
+// put returns the connection for reuse.
+func (c *rpcClient) put(conn *rpcConn) {
+	conn.lastUsed = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		_ = conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+// dial connects to addr. Many servers only accept connections from reserved
+// ports by default, so a reserved port is used if the process may bind one.
+func dial(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	d := net.Dialer{Timeout: timeout}
+	if os.Geteuid() != 0 {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+
+	for port := 1023; port >= 512; port-- {
+		d.LocalAddr = &net.TCPAddr{Port: port}
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL) {
+			continue
+		}
+		return conn, err
+	}
+
+	d.LocalAddr = nil
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// call runs the procedure proc with the encoded arguments and returns a
+// reader for the results.
+func (c *rpcClient) call(ctx context.Context, proc uint32, args []byte) (*xdrReader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	xid := atomic.AddUint32(&c.xid, 1)
+	reply, err := c.roundTrip(ctx, conn, xid, proc, args)
+	if err != nil {
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	c.put(conn)
+
+	return parseReply(reply)
+}
+
+func (c *rpcClient) roundTrip(ctx context.Context, conn *rpcConn, xid, proc uint32, args []byte) ([]byte, error) {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// abort the call when the context is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	var w xdrWriter
+	w.uint32(0) // record mark
+	w.uint32(xid)
+	w.uint32(msgCall)
+	w.uint32(rpcVersion)
+	w.uint32(c.prog)
+	w.uint32(c.vers)
+	w.uint32(proc)
+	w.buf = append(w.buf, c.cred...)
+	w.buf = append(w.buf, noCred...)
+	w.buf = append(w.buf, args...)
+	binary.BigEndian.PutUint32(w.buf, lastFragment|uint32(len(w.buf)-4))
+
+	if _, err := conn.Write(w.buf); err != nil {
+		return nil, errors.Wrap(err, "Write")
+	}
+
+	for {
+		reply, err := readRecord(conn.rd)
+		if err != nil {
+			return nil, errors.Wrap(err, "Read")
+		}
+		if len(reply) >= 4 && binary.BigEndian.Uint32(reply) == xid {
+			return reply[4:], nil
+		}
+		debug.Log("ignoring reply with unexpected xid from %v", c.addr)
+	}
+}
+
+// readRecord reads all fragments of a record.
+func readRecord(rd io.Reader) ([]byte, error) {
+	var record []byte
+	for {
+		var mark [4]byte
+		if _, err := io.ReadFull(rd, mark[:]); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(mark[:])
+		size := int(n &^ lastFragment)
+		if len(record)+size > maxReplySize {
+			return nil, errors.Errorf("reply exceeds %d bytes", maxReplySize)
+		}
+
+		start := len(record)
+		record = append(record, make([]byte, size)...)
+		if _, err := io.ReadFull(rd, record[start:]); err != nil {
+			return nil, err
+		}
+		if n&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+// parseReply checks the header of a reply without the xid and returns a
+// reader for the results.
+func parseReply(reply []byte) (*xdrReader, error) {
+	r := &xdrReader{buf: reply}
+	if r.uint32() != msgReply {
+		return nil, errors.New("invalid reply message type")
+	}
+
+	switch r.uint32() {
+	case replyAccepted:
+		// verifier
+		r.uint32()
+		r.opaque(400)
+		stat := r.uint32()
+		if err := r.err(); err != nil {
+			return nil, err
+		}
+		if stat != acceptSuccess {
+			msg, ok := acceptErrors[stat]
+			if !ok {
+				msg = fmt.Sprintf("status %d", stat)
+			}
+			return nil, errors.Errorf("call failed: %v", msg)
+		}
+		return r, nil
+	case replyDenied:
+		if r.uint32() == 0 {
+			return nil, errors.New("call denied: RPC version mismatch")
+		}
+		return nil, errors.Errorf("call denied: authentication failed with status %d", r.uint32())
+	default:
+		return nil, errors.New("invalid reply status")
+	}
+}
+
+// Close closes all idle connections, connections in use are closed when
+// their call is done.
+func (c *rpcClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	var err error
+	for _, conn := range c.idle {
+		if cerr := conn.Close(); err == nil {
+			err = cerr
+		}
+	}
+	c.idle = nil
+	return err
+}
@@ -0,0 +1,387 @@
+package nfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// testServer is a minimal NFSv3 and MOUNT server which exports a local
+// directory, both programs are served on the same port.
+type testServer struct {
+	dir    string
+	export string
+	l      net.Listener
+
+	mu      sync.Mutex
+	handles map[string][]byte
+	paths   map[string]string
+
+	conns int32
+}
+
+func newTestServer(t testing.TB) *testServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	rtest.OK(t, err)
+
+	srv := &testServer{
+		dir:     rtest.TempDir(t),
+		export:  "/export",
+		l:       l,
+		handles: make(map[string][]byte),
+		paths:   make(map[string]string),
+	}
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	go srv.serve()
+	return srv
+}
+
+func (s *testServer) port() string {
+	return strconv.Itoa(s.l.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.conns, 1)
+		go s.handle(conn)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	for {
+		call, err := readRecord(conn)
+		if err != nil {
+			return
+		}
+
+		r := &xdrReader{buf: call}
+		xid := r.uint32()
+		// message type, RPC version
+		r.uint32()
+		r.uint32()
+		prog := r.uint32()
+		r.uint32()
+		proc := r.uint32()
+		// credential and verifier
+		r.uint32()
+		r.opaque(400)
+		r.uint32()
+		r.opaque(400)
+
+		var w xdrWriter
+		w.uint32(0)
+		w.uint32(xid)
+		w.uint32(msgReply)
+		w.uint32(replyAccepted)
+		w.buf = append(w.buf, noCred...)
+		w.uint32(acceptSuccess)
+
+		if prog == mountProgram {
+			s.mountProc(proc, r, &w)
+		} else {
+			s.nfsProc(proc, r, &w)
+		}
+		binary.BigEndian.PutUint32(w.buf, lastFragment|uint32(len(w.buf)-4))
+
+		if _, err := conn.Write(w.buf); err != nil {
+			return
+		}
+	}
+}
+
+// handleFor returns the file handle for the path relative to the export.
+func (s *testServer) handleFor(p string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fh, ok := s.handles[p]
+	if !ok {
+		fh = binary.BigEndian.AppendUint64(nil, uint64(len(s.handles)+1))
+		s.handles[p] = fh
+		s.paths[string(fh)] = p
+	}
+	return fh
+}
+
+func (s *testServer) path(fh []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paths[string(fh)]
+}
+
+func (s *testServer) local(p string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(p))
+}
+
+func (s *testServer) mountProc(proc uint32, r *xdrReader, w *xdrWriter) {
+	switch proc {
+	case mountProcMnt:
+		if r.string(1024) != s.export {
+			w.uint32(statusNoEnt)
+			return
+		}
+		w.uint32(0)
+		w.opaque(s.handleFor(""))
+		w.uint32(1)
+		w.uint32(authSys)
+	case mountProcExport:
+		w.bool(true)
+		w.string(s.export)
+		w.bool(false)
+		w.bool(false)
+	}
+}
+
+func status(err error) uint32 {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, os.ErrNotExist):
+		return statusNoEnt
+	case errors.Is(err, os.ErrExist):
+		return statusExist
+	case errors.Is(err, syscall.ENOTEMPTY):
+		return statusNotEmpty
+	default:
+		return statusIO
+	}
+}
+
+func writeAttr(w *xdrWriter, fi os.FileInfo) {
+	if fi.IsDir() {
+		w.uint32(typeDirectory)
+	} else {
+		w.uint32(typeRegular)
+	}
+	w.uint32(uint32(fi.Mode().Perm()))
+	// nlink, uid, gid
+	w.uint32(1)
+	w.uint32(0)
+	w.uint32(0)
+	w.uint64(uint64(fi.Size()))
+	// used, rdev, fsid, fileid, atime, mtime, ctime
+	w.buf = append(w.buf, make([]byte, 8+8+8+8+3*8)...)
+}
+
+// readSetAttr skips the attributes passed to CREATE and MKDIR.
+func readSetAttr(r *xdrReader) {
+	for i := 0; i < 3; i++ {
+		if r.bool() {
+			r.uint32()
+		}
+	}
+	if r.bool() {
+		r.uint64()
+	}
+	for i := 0; i < 2; i++ {
+		if r.uint32() == 2 {
+			r.uint64()
+		}
+	}
+}
+
+var testVerf = []byte("verifier")
+
+func (s *testServer) nfsProc(proc uint32, r *xdrReader, w *xdrWriter) {
+	fh := r.opaque(fhSize)
+	p := s.path(fh)
+
+	switch proc {
+	case nfsProcGetAttr:
+		fi, err := os.Stat(s.local(p))
+		if err != nil {
+			w.uint32(status(err))
+			return
+		}
+		w.uint32(0)
+		writeAttr(w, fi)
+
+	case nfsProcLookup:
+		name := path.Join(p, r.string(255))
+		fi, err := os.Stat(s.local(name))
+		if err != nil {
+			w.uint32(status(err))
+			w.bool(false)
+			return
+		}
+		w.uint32(0)
+		w.opaque(s.handleFor(name))
+		w.bool(true)
+		writeAttr(w, fi)
+		w.bool(false)
+
+	case nfsProcRead:
+		offset := r.uint64()
+		buf := make([]byte, r.uint32())
+		f, err := os.Open(s.local(p))
+		if err != nil {
+			w.uint32(status(err))
+			w.bool(false)
+			return
+		}
+		n, err := f.ReadAt(buf, int64(offset))
+		_ = f.Close()
+		if err != nil && err != io.EOF {
+			w.uint32(status(err))
+			w.bool(false)
+			return
+		}
+		w.uint32(0)
+		w.bool(false)
+		w.uint32(uint32(n))
+		w.bool(err == io.EOF)
+		w.opaque(buf[:n])
+
+	case nfsProcWrite:
+		offset := r.uint64()
+		r.uint32()
+		r.uint32()
+		data := r.opaque(1 << 20)
+		f, err := os.OpenFile(s.local(p), os.O_WRONLY, 0)
+		if err == nil {
+			_, err = f.WriteAt(data, int64(offset))
+			_ = f.Close()
+		}
+		if err != nil {
+			w.uint32(status(err))
+			w.bool(false)
+			w.bool(false)
+			return
+		}
+		w.uint32(0)
+		w.bool(false)
+		w.bool(false)
+		w.uint32(uint32(len(data)))
+		w.uint32(stableUnstable)
+		w.fixed(testVerf)
+
+	case nfsProcCreate, nfsProcMkdir:
+		name := path.Join(p, r.string(255))
+		var err error
+		if proc == nfsProcCreate {
+			r.uint32()
+			readSetAttr(r)
+			var f *os.File
+			f, err = os.OpenFile(s.local(name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+			if err == nil {
+				err = f.Close()
+			}
+		} else {
+			readSetAttr(r)
+			err = os.Mkdir(s.local(name), 0700)
+		}
+		if err != nil {
+			w.uint32(status(err))
+			w.bool(false)
+			w.bool(false)
+			return
+		}
+		w.uint32(0)
+		w.bool(true)
+		w.opaque(s.handleFor(name))
+		w.bool(false)
+		w.bool(false)
+		w.bool(false)
+
+	case nfsProcRemove, nfsProcRmdir:
+		err := os.Remove(s.local(path.Join(p, r.string(255))))
+		w.uint32(status(err))
+		w.bool(false)
+		w.bool(false)
+
+	case nfsProcRename:
+		from := path.Join(p, r.string(255))
+		to := path.Join(s.path(r.opaque(fhSize)), r.string(255))
+		err := os.Rename(s.local(from), s.local(to))
+		w.uint32(status(err))
+		w.buf = append(w.buf, make([]byte, 4*4)...)
+
+	case nfsProcReadDirPlus:
+		cookie := r.uint64()
+		entries, err := os.ReadDir(s.local(p))
+		if err != nil {
+			w.uint32(status(err))
+			w.bool(false)
+			return
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+		w.uint32(0)
+		w.bool(false)
+		w.fixed(testVerf)
+		// return a few entries at a time to test continuing the listing
+		end := int(cookie) + 7
+		if end > len(entries) {
+			end = len(entries)
+		}
+		for i := int(cookie); i < end; i++ {
+			fi, err := entries[i].Info()
+			if err != nil {
+				panic(err)
+			}
+			name := path.Join(p, entries[i].Name())
+			w.bool(true)
+			w.uint64(uint64(i))
+			w.string(entries[i].Name())
+			w.uint64(uint64(i + 1))
+			w.bool(true)
+			writeAttr(w, fi)
+			w.bool(true)
+			w.opaque(s.handleFor(name))
+		}
+		w.bool(false)
+		w.bool(end == len(entries))
+
+	case nfsProcFsStat:
+		w.uint32(0)
+		w.bool(false)
+		for _, v := range []uint64{1000, 600, 100, 10, 6, 1} {
+			w.uint64(v)
+		}
+		w.uint32(0)
+
+	case nfsProcFsInfo:
+		w.uint32(0)
+		w.bool(false)
+		// small transfer sizes to test splitting reads and writes
+		for _, v := range []uint32{64 << 10, 16 << 10, 1, 64 << 10, 16 << 10, 1, 4096} {
+			w.uint32(v)
+		}
+		w.uint64(1 << 40)
+		w.uint64(0)
+		w.uint32(0)
+
+	case nfsProcCommit:
+		w.uint32(0)
+		w.bool(false)
+		w.bool(false)
+		w.fixed(testVerf)
+	}
+}
+
+func (s *testServer) connections() int {
+	return int(atomic.LoadInt32(&s.conns))
+}
@@ -0,0 +1,121 @@
+package nfs
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// xdrWriter encodes values using the external data representation (RFC 4506).
+type xdrWriter struct {
+	buf []byte
+}
+
+func (w *xdrWriter) uint32(v uint32) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+}
+
+func (w *xdrWriter) uint64(v uint64) {
+	w.buf = binary.BigEndian.AppendUint64(w.buf, v)
+}
+
+func (w *xdrWriter) bool(v bool) {
+	if v {
+		w.uint32(1)
+	} else {
+		w.uint32(0)
+	}
+}
+
+// fixed appends data without length, padded to a multiple of four bytes.
+func (w *xdrWriter) fixed(data []byte) {
+	w.buf = append(w.buf, data...)
+	w.pad(len(data))
+}
+
+// opaque appends variable length data.
+func (w *xdrWriter) opaque(data []byte) {
+	w.uint32(uint32(len(data)))
+	w.buf = append(w.buf, data...)
+	w.pad(len(data))
+}
+
+func (w *xdrWriter) string(s string) {
+	w.uint32(uint32(len(s)))
+	w.buf = append(w.buf, s...)
+	w.pad(len(s))
+}
+
+func (w *xdrWriter) pad(n int) {
+	for i := n; i%4 != 0; i++ {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// errShortReply is returned when a reply ends before all values are decoded.
+var errShortReply = fmt.Errorf("reply too short")
+
+// xdrReader decodes values using the external data representation. The first
+// error is kept and returned by err, all subsequent reads return zero values.
+type xdrReader struct {
+	buf []byte
+	e   error
+}
+
+func (r *xdrReader) take(n int) []byte {
+	if r.e != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf) < n {
+		r.e = errShortReply
+		r.buf = nil
+		return nil
+	}
+	data := r.buf[:n]
+	r.buf = r.buf[n:]
+	return data
+}
+
+func (r *xdrReader) uint32() uint32 {
+	data := r.take(4)
+	if data == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(data)
+}
+
+func (r *xdrReader) uint64() uint64 {
+	data := r.take(8)
+	if data == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+func (r *xdrReader) bool() bool {
+	return r.uint32() != 0
+}
+
+// fixed returns n bytes and skips the padding.
+func (r *xdrReader) fixed(n int) []byte {
+	data := r.take(n)
+	r.take((4 - n%4) % 4)
+	return data
+}
+
+// opaque returns variable length data of at most max bytes.
+func (r *xdrReader) opaque(max uint32) []byte {
+	n := r.uint32()
+	if r.e == nil && n > max {
+		r.e = fmt.Errorf("opaque data of %d bytes exceeds the maximum of %d bytes", n, max)
+		return nil
+	}
+	return r.fixed(int(n))
+}
+
+func (r *xdrReader) string(max uint32) string {
+	return string(r.opaque(max))
+}
+
+func (r *xdrReader) err() error {
+	return r.e
+}
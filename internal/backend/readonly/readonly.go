@@ -0,0 +1,88 @@
+package readonly
+
+import (
+	"context"
+	"hash"
+	"io"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// ErrReadOnly is returned for all operations which would modify the backend.
+var ErrReadOnly = errors.New("repository is opened in read-only mode")
+
+// Backend passes reads through to an underlying layer and rejects all
+// operations which would modify the repository, including the creation of
+// lock files. This is used for `--read-only`.
+type Backend struct {
+	b backend.Backend
+}
+
+// statically ensure that Backend implements backend.Backend.
+var _ backend.Backend = &Backend{}
+
+func New(be backend.Backend) *Backend {
+	debug.Log("created new read-only backend")
+	return &Backend{b: be}
+}
+
+// Save rejects storing data in the backend.
+func (be *Backend) Save(_ context.Context, h backend.Handle, _ backend.RewindReader) error {
+	debug.Log("rejected Save(%v)", h)
+	return errors.Wrapf(ErrReadOnly, "save %v", h)
+}
+
+// Remove rejects removing a file from the backend.
+func (be *Backend) Remove(_ context.Context, h backend.Handle) error {
+	debug.Log("rejected Remove(%v)", h)
+	return errors.Wrapf(ErrReadOnly, "remove %v", h)
+}
+
+// Delete rejects removing all data in the backend.
+func (be *Backend) Delete(_ context.Context) error {
+	return errors.Wrap(ErrReadOnly, "delete")
+}
+
+func (be *Backend) Connections() uint {
+	return be.b.Connections()
+}
+
+func (be *Backend) Close() error {
+	return be.b.Close()
+}
+
+func (be *Backend) Hasher() hash.Hash {
+	return be.b.Hasher()
+}
+
+func (be *Backend) HasAtomicReplace() bool {
+	return be.b.HasAtomicReplace()
+}
+
+func (be *Backend) IsNotExist(err error) bool {
+	return be.b.IsNotExist(err)
+}
+
+// IsPermanentError returns true for rejected modifications, as retrying them
+// cannot succeed.
+func (be *Backend) IsPermanentError(err error) bool {
+	return errors.Is(err, ErrReadOnly) || be.b.IsPermanentError(err)
+}
+
+func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	return be.b.List(ctx, t, fn)
+}
+
+func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(io.Reader) error) error {
+	return be.b.Load(ctx, h, length, offset, fn)
+}
+
+func (be *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	return be.b.Stat(ctx, h)
+}
+
+func (be *Backend) Unwrap() backend.Backend {
+	return be.b
+}
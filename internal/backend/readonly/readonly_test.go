@@ -0,0 +1,55 @@
+package readonly_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/backend/readonly"
+	"github.com/restic/restic/internal/errors"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.TODO()
+	m := mem.New()
+	ro := readonly.New(m)
+
+	data := []byte("foobar")
+	existing := backend.Handle{Type: backend.PackFile, Name: "existing"}
+	rtest.OK(t, m.Save(ctx, existing, backend.NewByteReader(data, m.Hasher())))
+
+	// reads pass through
+	fi, err := ro.Stat(ctx, existing)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len(data)), fi.Size)
+
+	err = ro.Load(ctx, existing, 0, 0, func(rd io.Reader) error {
+		buf, err := io.ReadAll(rd)
+		if err != nil {
+			return err
+		}
+		rtest.Assert(t, bytes.Equal(data, buf), "unexpected content %q", buf)
+		return nil
+	})
+	rtest.OK(t, err)
+
+	// writes are rejected
+	lock := backend.Handle{Type: backend.LockFile, Name: "lock"}
+	err = ro.Save(ctx, lock, backend.NewByteReader(data, nil))
+	rtest.Assert(t, errors.Is(err, readonly.ErrReadOnly), "unexpected error %v", err)
+	rtest.Assert(t, ro.IsPermanentError(err), "rejected write is not a permanent error")
+	_, err = m.Stat(ctx, lock)
+	rtest.Assert(t, m.IsNotExist(err), "lock file was created")
+
+	err = ro.Remove(ctx, existing)
+	rtest.Assert(t, errors.Is(err, readonly.ErrReadOnly), "unexpected error %v", err)
+	err = ro.Delete(ctx)
+	rtest.Assert(t, errors.Is(err, readonly.ErrReadOnly), "unexpected error %v", err)
+
+	_, err = m.Stat(ctx, existing)
+	rtest.OK(t, err)
+}
@@ -28,6 +28,9 @@ type Config struct {
 	Region        string `option:"region" help:"set region"`
 	BucketLookup  string `option:"bucket-lookup" help:"bucket lookup style: 'auto', 'dns', or 'path'"`
 	ListObjectsV1 bool   `option:"list-objects-v1" help:"use deprecated V1 api for ListObjects calls"`
+
+	SSECustomerKey         options.SecretString `option:"sse-c-key" help:"base64 encoded 256 bit key for server-side encryption with a customer-provided key (SSE-C)"`
+	SSECustomerKeyPrevious options.SecretString `option:"sse-c-key-previous" help:"previous SSE-C key, used to read objects written before a key rotation"`
 }
 
 // NewConfig returns a new Config with the default values filled in.
@@ -107,4 +110,10 @@ func (cfg *Config) ApplyEnvironment(prefix string) {
 	if cfg.Region == "" {
 		cfg.Region = os.Getenv(prefix + "AWS_DEFAULT_REGION")
 	}
+	if cfg.SSECustomerKey.String() == "" {
+		cfg.SSECustomerKey = options.NewSecretString(os.Getenv(prefix + "RESTIC_S3_SSE_C_KEY"))
+	}
+	if cfg.SSECustomerKeyPrevious.String() == "" {
+		cfg.SSECustomerKeyPrevious = options.NewSecretString(os.Getenv(prefix + "RESTIC_S3_SSE_C_KEY_PREVIOUS"))
+	}
 }
@@ -2,6 +2,7 @@ package s3
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"hash"
 	"io"
@@ -18,9 +19,11 @@ import (
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/options"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
 // Backend stores data on an S3 endpoint.
@@ -28,6 +31,11 @@ type Backend struct {
 	client *minio.Client
 	cfg    Config
 	layout.Layout
+
+	// sse is the customer-provided key used for all requests, ssePrevious
+	// is only used as a fallback when reading objects.
+	sse         encrypt.ServerSide
+	ssePrevious encrypt.ServerSide
 }
 
 // make sure that *Backend implements backend.Backend
@@ -85,6 +93,21 @@ func open(ctx context.Context, cfg Config, rt http.RoundTripper) (*Backend, erro
 		cfg:    cfg,
 	}
 
+	be.sse, err = newSSECustomerKey(cfg.SSECustomerKey, "sse-c-key")
+	if err != nil {
+		return nil, err
+	}
+	be.ssePrevious, err = newSSECustomerKey(cfg.SSECustomerKeyPrevious, "sse-c-key-previous")
+	if err != nil {
+		return nil, err
+	}
+	if be.ssePrevious != nil && be.sse == nil {
+		return nil, errors.Fatal("unable to open S3 backend: sse-c-key-previous requires sse-c-key to be set")
+	}
+	if be.sse != nil && cfg.UseHTTP {
+		return nil, errors.Fatal("unable to open S3 backend: SSE-C requires an https endpoint")
+	}
+
 	l, err := layout.ParseLayout(ctx, be, cfg.Layout, defaultLayout, cfg.Prefix)
 	if err != nil {
 		return nil, err
@@ -95,6 +118,25 @@ func open(ctx context.Context, cfg Config, rt http.RoundTripper) (*Backend, erro
 	return be, nil
 }
 
+// newSSECustomerKey decodes a base64 encoded 256 bit key for use with SSE-C.
+// It returns nil if no key is configured.
+func newSSECustomerKey(key options.SecretString, name string) (encrypt.ServerSide, error) {
+	if key.Unwrap() == "" {
+		return nil, nil
+	}
+
+	buf, err := base64.StdEncoding.DecodeString(key.Unwrap())
+	if err != nil {
+		return nil, errors.Fatalf("unable to open S3 backend: %v is not valid base64: %v", name, err)
+	}
+
+	sse, err := encrypt.NewSSEC(buf)
+	if err != nil {
+		return nil, errors.Fatalf("unable to open S3 backend: invalid %v: %v", name, err)
+	}
+	return sse, nil
+}
+
 // getCredentials -- runs through the various credential types and returns the first one that works.
 // additionally if the user has specified a role to assume, it will do that as well.
 func getCredentials(cfg Config) (*credentials.Credentials, error) {
@@ -359,6 +401,7 @@ func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.Rewind
 	if be.useStorageClass(h) {
 		opts.StorageClass = be.cfg.StorageClass
 	}
+	opts.ServerSideEncryption = be.sse
 
 	info, err := be.client.PutObject(ctx, be.cfg.Bucket, objName, io.NopCloser(rd), int64(rd.Length()), opts)
 
@@ -380,8 +423,17 @@ func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offse
 }
 
 func (be *Backend) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
+	rd, err := be.openReaderWithKey(ctx, h, length, offset, be.sse)
+	if err != nil && be.ssePrevious != nil && !be.IsNotExist(err) {
+		debug.Log("reading %v with current SSE-C key failed, retrying with previous key: %v", h, err)
+		rd, err = be.openReaderWithKey(ctx, h, length, offset, be.ssePrevious)
+	}
+	return rd, err
+}
+
+func (be *Backend) openReaderWithKey(ctx context.Context, h backend.Handle, length int, offset int64, sse encrypt.ServerSide) (io.ReadCloser, error) {
 	objName := be.Filename(h)
-	opts := minio.GetObjectOptions{}
+	opts := minio.GetObjectOptions{ServerSideEncryption: sse}
 
 	var err error
 	if length > 0 {
@@ -412,10 +464,19 @@ func (be *Backend) openReader(ctx context.Context, h backend.Handle, length int,
 
 // Stat returns information about a blob.
 func (be *Backend) Stat(ctx context.Context, h backend.Handle) (bi backend.FileInfo, err error) {
+	bi, err = be.statWithKey(ctx, h, be.sse)
+	if err != nil && be.ssePrevious != nil && !be.IsNotExist(err) {
+		debug.Log("stat of %v with current SSE-C key failed, retrying with previous key: %v", h, err)
+		bi, err = be.statWithKey(ctx, h, be.ssePrevious)
+	}
+	return bi, err
+}
+
+func (be *Backend) statWithKey(ctx context.Context, h backend.Handle, sse encrypt.ServerSide) (bi backend.FileInfo, err error) {
 	objName := be.Filename(h)
 	var obj *minio.Object
 
-	opts := minio.GetObjectOptions{}
+	opts := minio.GetObjectOptions{ServerSideEncryption: sse}
 
 	obj, err = be.client.GetObject(ctx, be.cfg.Bucket, objName, opts)
 	if err != nil {
@@ -529,13 +590,15 @@ func (be *Backend) Rename(ctx context.Context, h backend.Handle, l layout.Layout
 	debug.Log("  %v -> %v", oldname, newname)
 
 	src := minio.CopySrcOptions{
-		Bucket: be.cfg.Bucket,
-		Object: oldname,
+		Bucket:     be.cfg.Bucket,
+		Object:     oldname,
+		Encryption: be.sse,
 	}
 
 	dst := minio.CopyDestOptions{
-		Bucket: be.cfg.Bucket,
-		Object: newname,
+		Bucket:     be.cfg.Bucket,
+		Object:     newname,
+		Encryption: be.sse,
 	}
 
 	_, err := be.client.CopyObject(ctx, dst, src)
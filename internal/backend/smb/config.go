@@ -0,0 +1,98 @@
+package smb
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// Config contains all information required to connect to an SMB share.
+type Config struct {
+	Host, Port  string
+	Share, Path string
+
+	User     string `option:"user"   help:"user name for the SMB server (default: $RESTIC_SMB_USER)"`
+	Domain   string `option:"domain" help:"domain of the user (default: $RESTIC_SMB_DOMAIN)"`
+	Password options.SecretString
+	Kerberos bool `option:"kerberos" help:"authenticate using Kerberos instead of NTLMv2"`
+
+	Connections uint `option:"connections" help:"set a limit for the number of concurrent operations (default: 5)"`
+}
+
+// NewConfig returns a new config with default options applied.
+func NewConfig() Config {
+	return Config{
+		Port:        "445",
+		Connections: 5,
+	}
+}
+
+func init() {
+	options.Register("smb", Config{})
+}
+
+// ParseConfig parses the string s and extracts the SMB config. The supported
+// configuration format is smb://[user@]host[:port]/share[/path], where path
+// is relative to the root of the share.
+func ParseConfig(s string) (*Config, error) {
+	if !strings.HasPrefix(s, "smb://") {
+		return nil, errors.New(`invalid format, does not start with "smb://"`)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if _, set := u.User.Password(); set {
+		return nil, errors.Errorf("invalid backend %q, specify the password using $RESTIC_SMB_PASSWORD", s)
+	}
+
+	if u.Hostname() == "" {
+		return nil, errors.Errorf("invalid backend %q, no host specified", s)
+	}
+
+	share, dir, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if share == "" {
+		return nil, errors.Errorf("invalid backend %q, no share specified", s)
+	}
+
+	dir = path.Clean(dir)
+	if dir == "." {
+		dir = ""
+	}
+	if strings.HasPrefix(dir, "..") {
+		return nil, errors.Errorf("invalid backend %q, path is outside of the share", s)
+	}
+
+	cfg := NewConfig()
+	cfg.Host = u.Hostname()
+	if u.Port() != "" {
+		cfg.Port = u.Port()
+	}
+	cfg.User = u.User.Username()
+	cfg.Share = share
+	cfg.Path = dir
+
+	return &cfg, nil
+}
+
+var _ backend.ApplyEnvironmenter = &Config{}
+
+// ApplyEnvironment saves values from the environment to the config.
+func (cfg *Config) ApplyEnvironment(prefix string) {
+	if cfg.User == "" {
+		cfg.User = os.Getenv(prefix + "RESTIC_SMB_USER")
+	}
+	if cfg.Domain == "" {
+		cfg.Domain = os.Getenv(prefix + "RESTIC_SMB_DOMAIN")
+	}
+	if cfg.Password.Unwrap() == "" {
+		cfg.Password = options.NewSecretString(os.Getenv(prefix + "RESTIC_SMB_PASSWORD"))
+	}
+}
@@ -0,0 +1,4 @@
+// Package smb implements repository storage in a directory on an SMB2/3
+// share. The share is accessed directly using a pure Go client, so it does
+// not need to be mounted.
+package smb
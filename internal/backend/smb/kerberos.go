@@ -0,0 +1,94 @@
+package smb
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+
+	"github.com/cloudsoda/go-smb2"
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+)
+
+// krb5ConfigPath returns the path of the Kerberos configuration file, which
+// is taken from $KRB5_CONFIG like for the MIT Kerberos tools.
+func krb5ConfigPath() string {
+	if p := os.Getenv("KRB5_CONFIG"); p != "" {
+		// the variable may contain a list of files, only the first one is used
+		p, _, _ = strings.Cut(p, string(os.PathListSeparator))
+		return p
+	}
+	return "/etc/krb5.conf"
+}
+
+// ccachePath returns the path of the Kerberos credential cache, which is
+// taken from $KRB5CCNAME like for kinit. Only file based caches are supported.
+func ccachePath() (string, error) {
+	name := os.Getenv("KRB5CCNAME")
+	if name == "" {
+		return "/tmp/krb5cc_" + strconv.Itoa(os.Getuid()), nil
+	}
+
+	typ, p, found := strings.Cut(name, ":")
+	if !found || len(typ) == 1 {
+		// no type prefix, or a drive letter on Windows
+		return name, nil
+	}
+	if typ != "FILE" {
+		return "", errors.Errorf("credential cache %q of type %v is not supported, use a FILE cache", name, typ)
+	}
+	return p, nil
+}
+
+// kerberosInitiator returns an initiator which authenticates to the server
+// using Kerberos. If a password is configured, a ticket is requested for the
+// user in the realm given by the domain. Otherwise the tickets in the
+// credential cache are used, which can be obtained using kinit.
+func kerberosInitiator(cfg Config) (smb2.Initiator, error) {
+	krb5conf, err := config.Load(krb5ConfigPath())
+	if err != nil {
+		return nil, errors.Wrap(err, "load Kerberos configuration")
+	}
+
+	var cl *client.Client
+	if cfg.Password.Unwrap() != "" {
+		if cfg.User == "" {
+			return nil, errors.Fatal("no user for the SMB server specified, use smb://user@host/share or $RESTIC_SMB_USER")
+		}
+
+		realm := strings.ToUpper(cfg.Domain)
+		if realm == "" {
+			realm = krb5conf.LibDefaults.DefaultRealm
+		}
+		debug.Log("request Kerberos ticket for %v@%v", cfg.User, realm)
+
+		cl = client.NewWithPassword(cfg.User, realm, cfg.Password.Unwrap(), krb5conf, client.DisablePAFXFAST(true))
+		if err := cl.Login(); err != nil {
+			return nil, errors.Wrap(err, "Kerberos login")
+		}
+	} else {
+		p, err := ccachePath()
+		if err != nil {
+			return nil, err
+		}
+		debug.Log("use Kerberos credential cache %v", p)
+
+		ccache, err := credentials.LoadCCache(p)
+		if err != nil {
+			return nil, errors.Wrap(err, "load Kerberos credential cache")
+		}
+		cl, err = client.NewFromCCache(ccache, krb5conf, client.DisablePAFXFAST(true))
+		if err != nil {
+			return nil, errors.Wrap(err, "load Kerberos credential cache")
+		}
+	}
+
+	return &smb2.Krb5Initiator{
+		Client:    cl,
+		TargetSPN: "cifs/" + cfg.Host,
+	}, nil
+}
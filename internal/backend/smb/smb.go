@@ -0,0 +1,434 @@
+package smb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"os"
+	"path"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/layout"
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/util"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
+
+	"github.com/cloudsoda/go-smb2"
+	"golang.org/x/sync/errgroup"
+)
+
+// SMB is a backend in a directory on an SMB share.
+type SMB struct {
+	conn    net.Conn
+	session *smb2.Session
+	share   *smb2.Share
+
+	layout.Layout
+	Config
+}
+
+var _ backend.SpaceBackend = &SMB{}
+
+var errTooShort = fmt.Errorf("file is too short")
+
+func NewFactory() location.Factory {
+	return location.NewLimitedBackendFactory("smb", ParseConfig, location.NoPassword, limiter.WrapBackendConstructor(Create), limiter.WrapBackendConstructor(Open))
+}
+
+// dirMode and fileMode are passed to the server for new directories and
+// files. Most servers ignore them and apply the permissions of the share.
+const (
+	dirMode  = 0700
+	fileMode = 0600
+)
+
+// newInitiator returns the initiator which authenticates to the server, this
+// uses NTLMv2 unless Kerberos is enabled.
+func newInitiator(cfg Config) (smb2.Initiator, error) {
+	if cfg.Kerberos {
+		return kerberosInitiator(cfg)
+	}
+
+	if cfg.User == "" {
+		return nil, errors.Fatal("no user for the SMB server specified, use smb://user@host/share or $RESTIC_SMB_USER")
+	}
+	return &smb2.NTLMInitiator{
+		User:     cfg.User,
+		Password: cfg.Password.Unwrap(),
+		Domain:   cfg.Domain,
+	}, nil
+}
+
+// connect establishes the connection to the server, authenticates and
+// connects to the share. Encryption is negotiated with the server and used
+// whenever the server requires it for the session or the share.
+func connect(ctx context.Context, cfg Config) (*SMB, error) {
+	initiator, err := newInitiator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	debug.Log("connect to %v as %v (domain %q, kerberos %v)", addr, cfg.User, cfg.Domain, cfg.Kerberos)
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "Dial")
+	}
+
+	dialer := &smb2.Dialer{
+		Initiator: initiator,
+	}
+
+	// the host name is used in the UNC path of the share
+	session, err := dialer.DialConn(ctx, conn, cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "session setup")
+	}
+
+	share, err := session.Mount(cfg.Share)
+	if err != nil {
+		_ = session.Logoff()
+		_ = conn.Close()
+		return nil, errors.Wrapf(err, "Mount %v", cfg.Share)
+	}
+
+	return &SMB{
+		conn:    conn,
+		session: session,
+		share:   share,
+		Layout:  &layout.DefaultLayout{Path: cfg.Path, Join: path.Join},
+		Config:  cfg,
+	}, nil
+}
+
+// Open opens the SMB backend as described by the config.
+func Open(ctx context.Context, cfg Config) (*SMB, error) {
+	debug.Log("open backend with config %#v", cfg)
+	return connect(ctx, cfg)
+}
+
+// Create creates all the necessary directories for a new repository on the
+// SMB share. Afterwards a new config blob should be created.
+func Create(ctx context.Context, cfg Config) (*SMB, error) {
+	be, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// test if config file already exists
+	_, err = be.share.Stat(be.Filename(backend.Handle{Type: backend.ConfigFile}))
+	if err == nil {
+		_ = be.Close()
+		return nil, errors.New("config file already exists")
+	}
+
+	if err = be.mkdirAllDataSubdirs(ctx, cfg.Connections); err != nil {
+		_ = be.Close()
+		return nil, err
+	}
+
+	return be, nil
+}
+
+func (r *SMB) mkdirAllDataSubdirs(ctx context.Context, nconn uint) error {
+	// Run multiple MkdirAll calls concurrently, each of them needs several
+	// round trips to the server.
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(int(nconn))
+
+	share := r.share.WithContext(gCtx)
+	for _, d := range r.Paths() {
+		d := d
+		g.Go(func() error {
+			if err := share.Mkdir(d, dirMode); err == nil {
+				return nil
+			}
+			return share.MkdirAll(d, dirMode)
+		})
+	}
+
+	return g.Wait()
+}
+
+func (r *SMB) Connections() uint {
+	return r.Config.Connections
+}
+
+// Hasher may return a hash function for calculating a content hash for the backend
+func (r *SMB) Hasher() hash.Hash {
+	return nil
+}
+
+// HasAtomicReplace returns whether Save() can atomically replace files
+func (r *SMB) HasAtomicReplace() bool {
+	// renaming a file fails if the target already exists
+	return false
+}
+
+// IsNotExist returns true if the error is caused by a not existing file.
+func (r *SMB) IsNotExist(err error) bool {
+	return errors.Is(err, os.ErrNotExist)
+}
+
+func (r *SMB) IsPermanentError(err error) bool {
+	return r.IsNotExist(err) || errors.Is(err, errTooShort) || errors.Is(err, os.ErrPermission)
+}
+
+// tempSuffix generates a random string suffix that should be sufficiently long
+// to avoid accidental conflicts
+func tempSuffix() string {
+	var nonce [16]byte
+	_, err := rand.Read(nonce[:])
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(nonce[:])
+}
+
+// Save stores data in the backend at the handle.
+func (r *SMB) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) (err error) {
+	share := r.share.WithContext(ctx)
+
+	filename := r.Filename(h)
+	tmpFilename := filename + "-restic-temp-" + tempSuffix()
+
+	// create new file
+	f, err := share.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fileMode)
+
+	if r.IsNotExist(err) {
+		// error is caused by a missing directory, try to create it
+		mkdirErr := share.MkdirAll(r.Dirname(h), dirMode)
+		if mkdirErr != nil {
+			debug.Log("error creating dir %v: %v", r.Dirname(h), mkdirErr)
+		} else {
+			// try again
+			f, err = share.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fileMode)
+		}
+	}
+	if err != nil {
+		return errors.Wrap(err, "OpenFile")
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// Try not to leave a partial file behind.
+		rmErr := share.Remove(tmpFilename)
+		if rmErr != nil {
+			debug.Log("smb: failed to remove broken file %v: %v", tmpFilename, rmErr)
+		}
+	}()
+
+	wbytes, err := f.ReadFrom(rd)
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "Write")
+	}
+
+	// sanity check
+	if wbytes != rd.Length() {
+		_ = f.Close()
+		return errors.Errorf("wrote %d bytes instead of the expected %d bytes", wbytes, rd.Length())
+	}
+
+	err = f.Close()
+	if err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	err = share.Rename(tmpFilename, filename)
+	return errors.Wrap(err, "Rename")
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset.
+func (r *SMB) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	return util.DefaultLoad(ctx, h, length, offset, r.openReader, func(rd io.Reader) error {
+		if length == 0 || !feature.Flag.Enabled(feature.BackendErrorRedesign) {
+			return fn(rd)
+		}
+
+		// rd is a LimitedReader which can be used to track the number of bytes read
+		err := fn(rd)
+
+		// check the underlying reader to be agnostic to however fn() handles the returned error
+		_, rderr := rd.Read([]byte{0})
+		if rderr == io.EOF && rd.(*util.LimitedReadCloser).N != 0 {
+			// file is too short
+			return fmt.Errorf("%w: %v", errTooShort, err)
+		}
+
+		return err
+	})
+}
+
+func (r *SMB) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
+	f, err := r.share.WithContext(ctx).Open(r.Filename(h))
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		_, err = f.Seek(offset, io.SeekStart)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+
+	if length > 0 {
+		return util.LimitReadCloser(f, int64(length)), nil
+	}
+
+	return f, nil
+}
+
+// Stat returns information about a blob.
+func (r *SMB) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	fi, err := r.share.WithContext(ctx).Stat(r.Filename(h))
+	if err != nil {
+		return backend.FileInfo{}, errors.Wrap(err, "Stat")
+	}
+
+	return backend.FileInfo{Size: fi.Size(), Name: h.Name}, nil
+}
+
+// Space returns the size and free space of the share as seen by the user,
+// which includes the quota configured on the server.
+func (r *SMB) Space(ctx context.Context) (backend.SpaceInfo, error) {
+	fi, err := r.share.WithContext(ctx).Statfs(r.Path)
+	if err != nil {
+		return backend.SpaceInfo{}, errors.Wrap(err, "Statfs")
+	}
+	return spaceInfo(fi), nil
+}
+
+func spaceInfo(fi smb2.FileFsInfo) backend.SpaceInfo {
+	unit := fi.BlockSize() * fi.FragmentSize()
+	return backend.SpaceInfo{
+		Total:     fi.TotalBlockCount() * unit,
+		Free:      fi.FreeBlockCount() * unit,
+		Available: fi.AvailableBlockCount() * unit,
+	}
+}
+
+// Remove removes the content stored at name.
+func (r *SMB) Remove(ctx context.Context, h backend.Handle) error {
+	return r.share.WithContext(ctx).Remove(r.Filename(h))
+}
+
+// List runs fn for each file in the backend which has the type t. When an
+// error occurs (or fn returns an error), List stops and returns it.
+func (r *SMB) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	share := r.share.WithContext(ctx)
+	basedir, subdirs := r.Basedir(t)
+
+	dirs := []string{basedir}
+	if subdirs {
+		entries, err := share.ReadDir(basedir)
+		if r.IsNotExist(err) {
+			debug.Log("ignoring non-existing directory")
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "ReadDir(%v)", basedir)
+		}
+
+		dirs = dirs[:0]
+		for _, fi := range entries {
+			if fi.IsDir() {
+				dirs = append(dirs, path.Join(basedir, fi.Name()))
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		entries, err := share.ReadDir(dir)
+		if r.IsNotExist(err) {
+			debug.Log("ignoring non-existing directory")
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "ReadDir(%v)", dir)
+		}
+
+		for _, fi := range entries {
+			if !fi.Mode().IsRegular() {
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			err := fn(backend.FileInfo{Name: fi.Name(), Size: fi.Size()})
+			if err != nil {
+				return err
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Close disconnects from the share and closes the connection to the server.
+func (r *SMB) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	err := r.share.Umount()
+	debug.Log("Umount returned error %v", err)
+	if lerr := r.session.Logoff(); lerr != nil {
+		debug.Log("Logoff returned error %v", lerr)
+	}
+	if cerr := r.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (r *SMB) deleteRecursive(share *smb2.Share, name string) error {
+	entries, err := share.ReadDir(name)
+	if err != nil {
+		return errors.Wrap(err, "ReadDir")
+	}
+
+	for _, fi := range entries {
+		itemName := path.Join(name, fi.Name())
+		if fi.IsDir() {
+			err := r.deleteRecursive(share, itemName)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := share.Remove(itemName)
+		if err != nil {
+			return errors.Wrap(err, "Remove")
+		}
+	}
+
+	return nil
+}
+
+// Delete removes all data in the backend.
+func (r *SMB) Delete(ctx context.Context) error {
+	return r.deleteRecursive(r.share.WithContext(ctx), r.Path)
+}
@@ -0,0 +1,62 @@
+package smb
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	rtest "github.com/restic/restic/internal/test"
+
+	"github.com/cloudsoda/go-smb2"
+)
+
+type fsInfo struct {
+	total, free, available uint64
+}
+
+func (fi fsInfo) BlockSize() uint64           { return 512 }
+func (fi fsInfo) FragmentSize() uint64        { return 8 }
+func (fi fsInfo) TotalBlockCount() uint64     { return fi.total }
+func (fi fsInfo) FreeBlockCount() uint64      { return fi.free }
+func (fi fsInfo) AvailableBlockCount() uint64 { return fi.available }
+
+func TestSpaceInfo(t *testing.T) {
+	rtest.Equals(t, backend.SpaceInfo{
+		Total:     1000 * 4096,
+		Free:      600 * 4096,
+		Available: 100 * 4096,
+	}, spaceInfo(fsInfo{total: 1000, free: 600, available: 100}))
+}
+
+func TestCCachePath(t *testing.T) {
+	for _, test := range []struct {
+		env, path string
+		valid     bool
+	}{
+		{"FILE:/tmp/krb5cc_test", "/tmp/krb5cc_test", true},
+		{"/tmp/krb5cc_test", "/tmp/krb5cc_test", true},
+		{`C:\Users\test\krb5cc`, `C:\Users\test\krb5cc`, true},
+		{"KEYRING:persistent:1000", "", false},
+		{"KCM:", "", false},
+	} {
+		t.Run(test.env, func(t *testing.T) {
+			t.Setenv("KRB5CCNAME", test.env)
+			p, err := ccachePath()
+			if !test.valid {
+				rtest.Assert(t, err != nil, "expected an error for %q", test.env)
+				return
+			}
+			rtest.OK(t, err)
+			rtest.Equals(t, test.path, p)
+		})
+	}
+}
+
+func TestNewInitiator(t *testing.T) {
+	_, err := newInitiator(Config{})
+	rtest.Assert(t, err != nil, "expected an error without user")
+
+	initiator, err := newInitiator(Config{User: "user", Domain: "EXAMPLE"})
+	rtest.OK(t, err)
+	_, ok := initiator.(*smb2.NTLMInitiator)
+	rtest.Assert(t, ok, "expected an NTLM initiator, got %T", initiator)
+}
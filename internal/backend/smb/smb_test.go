@@ -0,0 +1,62 @@
+package smb_test
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend/smb"
+	"github.com/restic/restic/internal/backend/test"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func newSMBTestSuite() *test.Suite[smb.Config] {
+	return &test.Suite[smb.Config]{
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (*smb.Config, error) {
+			cfg, err := smb.ParseConfig(os.Getenv("RESTIC_TEST_SMB_REPOSITORY"))
+			if err != nil {
+				return nil, err
+			}
+
+			cfg.ApplyEnvironment("")
+			cfg.Path = path.Join(cfg.Path, fmt.Sprintf("test-%d", time.Now().UnixNano()))
+			return cfg, nil
+		},
+
+		Factory: smb.NewFactory(),
+	}
+}
+
+func testVars(t testing.TB) {
+	vars := []string{
+		"RESTIC_TEST_SMB_REPOSITORY",
+		"RESTIC_SMB_PASSWORD",
+	}
+
+	for _, v := range vars {
+		if os.Getenv(v) == "" {
+			t.Skipf("environment variable %v not set", v)
+			return
+		}
+	}
+}
+
+func TestBackendSMB(t *testing.T) {
+	defer func() {
+		if t.Skipped() {
+			rtest.SkipDisallowed(t, "restic/backend/smb.TestBackendSMB")
+		}
+	}()
+
+	testVars(t)
+	newSMBTestSuite().RunTests(t)
+}
+
+func BenchmarkBackendSMB(t *testing.B) {
+	testVars(t)
+	newSMBTestSuite().RunBenchmarks(t)
+}
@@ -0,0 +1,47 @@
+package tape
+
+import (
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// Config contains the location of the repository which stores the containers
+// and the options for assembling them.
+type Config struct {
+	// Location is the repository location of the underlying backend.
+	Location string
+
+	ContainerSize uint   `option:"container-size" help:"maximum size of container files in MiB (default: 4096)"`
+	SpoolDir      string `option:"spool-dir" help:"directory in which containers are assembled before they are written (default: $TMPDIR)"`
+}
+
+// NewConfig returns a new config with default options applied.
+func NewConfig() Config {
+	return Config{
+		ContainerSize: 4096,
+	}
+}
+
+func init() {
+	options.Register("tape", Config{})
+}
+
+// ParseConfig parses the string s and extracts the tape config. The supported
+// configuration format is tape:location, where location is the repository
+// location of the backend which stores the containers, for example
+// tape:/mnt/ltfs/repo.
+func ParseConfig(s string) (*Config, error) {
+	if !strings.HasPrefix(s, "tape:") {
+		return nil, errors.New(`invalid format, prefix "tape" not found`)
+	}
+	inner := strings.TrimPrefix(s, "tape:")
+	if inner == "" || strings.HasPrefix(inner, "tape:") {
+		return nil, errors.Errorf("invalid backend %q, no repository location specified", s)
+	}
+
+	cfg := NewConfig()
+	cfg.Location = inner
+	return &cfg, nil
+}
@@ -0,0 +1,34 @@
+package tape
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/backend/test"
+)
+
+var configTests = []test.ConfigTestData[Config]{
+	{
+		S:   "tape:/mnt/ltfs/repo",
+		Cfg: Config{Location: "/mnt/ltfs/repo", ContainerSize: 4096},
+	},
+	{
+		S:   "tape:sftp:user@host:/srv/repo",
+		Cfg: Config{Location: "sftp:user@host:/srv/repo", ContainerSize: 4096},
+	},
+}
+
+func TestParseConfig(t *testing.T) {
+	test.ParseConfigTester(t, ParseConfig, configTests)
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	for _, s := range []string{
+		"/mnt/ltfs/repo",
+		"tape:",
+		"tape:tape:/mnt/ltfs/repo",
+	} {
+		if _, err := ParseConfig(s); err == nil {
+			t.Errorf("ParseConfig(%q) did not return an error", s)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package tape
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// A container consists of a header, the packs stored back to back, the index
+// and a footer:
+//
+//	header: magic (8 bytes) | version (uint32)
+//	index:  count * (pack ID (32 bytes) | offset (uint64) | length (uint64))
+//	footer: index offset (uint64) | count (uint32) | SHA-256 of the index (32 bytes) | magic (8 bytes)
+//
+// All integers are stored in little endian byte order. The footer is located
+// at the end of the container, such that a container can be written in a
+// single pass and its index is found without reading the packs.
+const (
+	headerMagic = "RSTCTAPE"
+	footerMagic = "RSTCTIDX"
+	version     = 1
+
+	headerSize = 8 + 4
+	entrySize  = 32 + 8 + 8
+	footerSize = 8 + 4 + sha256.Size + 8
+)
+
+// entry describes the location of a pack within a container.
+type entry struct {
+	name           string
+	offset, length int64
+}
+
+// packID returns the binary pack ID of name, ok is false if name is not the
+// name of a pack file.
+func packID(name string) (id [32]byte, ok bool) {
+	if len(name) != 2*len(id) {
+		return id, false
+	}
+	_, err := hex.Decode(id[:], []byte(name))
+	return id, err == nil
+}
+
+func containerHeader() []byte {
+	buf := make([]byte, 0, headerSize)
+	buf = append(buf, headerMagic...)
+	return binary.LittleEndian.AppendUint32(buf, version)
+}
+
+// containerTrailer returns the index and the footer of a container whose
+// index starts at indexOffset.
+func containerTrailer(entries []entry, indexOffset int64) []byte {
+	buf := make([]byte, 0, len(entries)*entrySize+footerSize)
+	for _, e := range entries {
+		id, _ := packID(e.name)
+		buf = append(buf, id[:]...)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(e.offset))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(e.length))
+	}
+	sum := sha256.Sum256(buf)
+
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(indexOffset))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(entries)))
+	buf = append(buf, sum[:]...)
+	return append(buf, footerMagic...)
+}
+
+// footer is the parsed footer of a container.
+type footer struct {
+	indexOffset int64
+	count       int
+	sum         [sha256.Size]byte
+}
+
+// parseFooter parses the footer of a container of the given size. ok is false
+// if buf is not a container footer.
+func parseFooter(buf []byte, size int64) (f footer, ok bool, err error) {
+	if len(buf) != footerSize || !bytes.Equal(buf[footerSize-8:], []byte(footerMagic)) {
+		return footer{}, false, nil
+	}
+
+	f.indexOffset = int64(binary.LittleEndian.Uint64(buf))
+	f.count = int(binary.LittleEndian.Uint32(buf[8:]))
+	copy(f.sum[:], buf[12:])
+
+	if f.indexOffset < headerSize || f.indexOffset+int64(f.count)*entrySize+footerSize != size {
+		return footer{}, true, errors.New("invalid container footer")
+	}
+	return f, true, nil
+}
+
+// parseIndex parses and verifies the index of a container.
+func parseIndex(buf []byte, f footer) ([]entry, error) {
+	if len(buf) != f.count*entrySize || sha256.Sum256(buf) != f.sum {
+		return nil, errors.New("container index is damaged")
+	}
+
+	entries := make([]entry, 0, f.count)
+	for ; len(buf) > 0; buf = buf[entrySize:] {
+		e := entry{
+			name:   hex.EncodeToString(buf[:32]),
+			offset: int64(binary.LittleEndian.Uint64(buf[32:])),
+			length: int64(binary.LittleEndian.Uint64(buf[40:])),
+		}
+		if e.offset < headerSize || e.length < 0 || e.offset+e.length > f.indexOffset {
+			return nil, errors.Errorf("container index contains invalid location of pack %v", e.name)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
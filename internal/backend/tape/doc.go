@@ -0,0 +1,6 @@
+// Package tape stores the pack files of a repository in large container
+// files, which are written sequentially and end with an index of the
+// contained packs. This suits LTO tapes formatted with LTFS and other
+// append-only media, on which many small files or random writes are slow or
+// impossible. All other files of the repository are stored unchanged.
+package tape
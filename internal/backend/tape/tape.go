@@ -0,0 +1,569 @@
+package tape
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// errAppendOnly is returned when removing a pack which is stored in a
+// container.
+var errAppendOnly = errors.New("packs stored in containers cannot be removed, the tape backend is append-only")
+
+// errRange is returned when reading beyond the end of a pack.
+var errRange = errors.New("requested range exceeds the pack")
+
+// Backend stores pack files in containers in the underlying backend. Packs are
+// first appended to a container in the spool directory, which is written to
+// the underlying backend once it is full or before any file other than a pack
+// or a lock is saved. Thereby packs are stored before the index files and
+// snapshots which reference them. Pack files which are not stored in a
+// container, for example those of a repository written without the tape
+// backend, can still be read and removed.
+type Backend struct {
+	be  backend.Backend
+	cfg Config
+
+	mu sync.Mutex
+	// packs contains the location of all packs in the containers which
+	// were read so far and in the spooled container.
+	packs map[string]packLocation
+	// containers contains the names of the containers which were read.
+	containers map[string]struct{}
+	// plain contains the sizes of pack files which are not containers.
+	plain map[string]int64
+	spool *spool
+}
+
+// packLocation is the location of a pack within a container.
+type packLocation struct {
+	container      string
+	offset, length int64
+}
+
+// spool is the container which is being assembled.
+type spool struct {
+	name    string
+	f       *os.File
+	size    int64
+	entries []entry
+}
+
+// statically ensure that Backend implements backend.Backend.
+var _ backend.Backend = &Backend{}
+
+// New returns a backend which stores packs in containers in be.
+func New(cfg Config, be backend.Backend) (*Backend, error) {
+	if cfg.ContainerSize == 0 {
+		return nil, errors.Fatal("the container size must be larger than zero")
+	}
+	if cfg.SpoolDir != "" {
+		fi, err := os.Stat(cfg.SpoolDir)
+		if err != nil {
+			return nil, errors.Fatalf("invalid spool directory: %v", err)
+		}
+		if !fi.IsDir() {
+			return nil, errors.Fatalf("invalid spool directory: %v is not a directory", cfg.SpoolDir)
+		}
+	}
+
+	debug.Log("new tape backend with containers of %d MiB", cfg.ContainerSize)
+	return &Backend{
+		be:         be,
+		cfg:        cfg,
+		packs:      make(map[string]packLocation),
+		containers: make(map[string]struct{}),
+		plain:      make(map[string]int64),
+	}, nil
+}
+
+// Unwrap returns the underlying backend.
+func (b *Backend) Unwrap() backend.Backend {
+	return b.be
+}
+
+func (b *Backend) Connections() uint {
+	return b.be.Connections()
+}
+
+func (b *Backend) Hasher() hash.Hash {
+	return b.be.Hasher()
+}
+
+func (b *Backend) HasAtomicReplace() bool {
+	return b.be.HasAtomicReplace()
+}
+
+func (b *Backend) IsNotExist(err error) bool {
+	return b.be.IsNotExist(err)
+}
+
+func (b *Backend) IsPermanentError(err error) bool {
+	return errors.Is(err, errAppendOnly) || errors.Is(err, errRange) || b.be.IsPermanentError(err)
+}
+
+// maxSize returns the maximum size of a container in bytes.
+func (b *Backend) maxSize() int64 {
+	return int64(b.cfg.ContainerSize) * 1024 * 1024
+}
+
+// Save appends packs to the spooled container and stores all other files in
+// the underlying backend. Except for lock files, the spooled container is
+// written first.
+func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	if _, ok := packID(h.Name); h.Type != backend.PackFile || !ok {
+		if h.Type != backend.LockFile {
+			if err := b.Flush(ctx); err != nil {
+				return err
+			}
+		}
+		return b.be.Save(ctx, h, rd)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the index is not included in the container size, a single pack larger
+	// than the container size gets a container on its own
+	if b.spool != nil && len(b.spool.entries) > 0 && b.spool.size+rd.Length() > b.maxSize() {
+		if err := b.flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	if b.spool == nil {
+		s, err := b.newSpool()
+		if err != nil {
+			return err
+		}
+		b.spool = s
+	}
+
+	s := b.spool
+	n, err := io.Copy(s.f, rd)
+	if err == nil && n != rd.Length() {
+		err = errors.Errorf("wrote %d bytes instead of the expected %d bytes", n, rd.Length())
+	}
+	if err != nil {
+		// drop the partially written pack
+		if terr := s.truncate(s.size); terr != nil {
+			debug.Log("truncating %v failed: %v", s.f.Name(), terr)
+		}
+		return errors.Wrap(err, "spool pack")
+	}
+
+	debug.Log("spooled %v at offset %d of container %v", h.Name, s.size, s.name)
+	s.entries = append(s.entries, entry{name: h.Name, offset: s.size, length: n})
+	b.packs[h.Name] = packLocation{container: s.name, offset: s.size, length: n}
+	s.size += n
+	return nil
+}
+
+// newSpool creates a new container in the spool directory.
+func (b *Backend) newSpool() (*spool, error) {
+	var id [32]byte
+	if _, err := io.ReadFull(rand.Reader, id[:]); err != nil {
+		return nil, errors.Wrap(err, "container name")
+	}
+
+	f, err := os.CreateTemp(b.cfg.SpoolDir, "restic-tape-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create spool file")
+	}
+
+	header := containerHeader()
+	if _, err := f.Write(header); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, errors.Wrap(err, "write spool file")
+	}
+
+	return &spool{
+		name: hex.EncodeToString(id[:]),
+		f:    f,
+		size: int64(len(header)),
+	}, nil
+}
+
+// truncate truncates the spool file to size bytes and continues writing at
+// its end.
+func (s *spool) truncate(size int64) error {
+	if err := s.f.Truncate(size); err != nil {
+		return err
+	}
+	_, err := s.f.Seek(size, io.SeekStart)
+	return err
+}
+
+// remove closes and removes the spool file.
+func (s *spool) remove() error {
+	err := s.f.Close()
+	if rerr := os.Remove(s.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Flush writes the spooled container to the underlying backend.
+func (b *Backend) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flush(ctx)
+}
+
+func (b *Backend) flush(ctx context.Context) error {
+	s := b.spool
+	if s == nil || len(s.entries) == 0 {
+		return nil
+	}
+
+	if _, err := s.f.Write(containerTrailer(s.entries, s.size)); err != nil {
+		_ = s.truncate(s.size)
+		return errors.Wrap(err, "write spool file")
+	}
+
+	var sum []byte
+	if hasher := b.be.Hasher(); hasher != nil {
+		if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+			_ = s.truncate(s.size)
+			return errors.Wrap(err, "seek spool file")
+		}
+		if _, err := io.Copy(hasher, s.f); err != nil {
+			_ = s.truncate(s.size)
+			return errors.Wrap(err, "read spool file")
+		}
+		sum = hasher.Sum(nil)
+	}
+
+	rd, err := backend.NewFileReader(s.f, sum)
+	if err == nil {
+		debug.Log("writing container %v with %d packs", s.name, len(s.entries))
+		err = b.be.Save(ctx, backend.Handle{Type: backend.PackFile, Name: s.name}, rd)
+	}
+	if err != nil {
+		// allow retrying the flush and appending further packs
+		if terr := s.truncate(s.size); terr != nil {
+			debug.Log("truncating %v failed: %v", s.f.Name(), terr)
+		}
+		return err
+	}
+
+	b.containers[s.name] = struct{}{}
+	b.spool = nil
+	if err := s.remove(); err != nil {
+		debug.Log("removing spool file failed: %v", err)
+	}
+	return nil
+}
+
+// readContainer reads the index of the pack file name with the given size.
+// ok is false if the file is not a container.
+func (b *Backend) readContainer(ctx context.Context, name string, size int64) (entries []entry, ok bool, err error) {
+	if size < headerSize+footerSize {
+		return nil, false, nil
+	}
+
+	h := backend.Handle{Type: backend.PackFile, Name: name}
+	buf := make([]byte, footerSize)
+	err = b.be.Load(ctx, h, footerSize, size-footerSize, func(rd io.Reader) error {
+		_, err := io.ReadFull(rd, buf)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	f, ok, err := parseFooter(buf, size)
+	if !ok || err != nil {
+		return nil, ok, errors.Wrapf(err, "container %v", name)
+	}
+
+	buf = make([]byte, f.count*entrySize)
+	if len(buf) > 0 {
+		err = b.be.Load(ctx, h, len(buf), f.indexOffset, func(rd io.Reader) error {
+			_, err := io.ReadFull(rd, buf)
+			return err
+		})
+		if err != nil {
+			return nil, true, err
+		}
+	}
+
+	entries, err = parseIndex(buf, f)
+	return entries, true, errors.Wrapf(err, "container %v", name)
+}
+
+// refresh reads the index of all containers which were not read yet.
+func (b *Backend) refresh(ctx context.Context) error {
+	type file struct {
+		name string
+		size int64
+	}
+	var unknown []file
+
+	err := b.be.List(ctx, backend.PackFile, func(fi backend.FileInfo) error {
+		b.mu.Lock()
+		_, isContainer := b.containers[fi.Name]
+		_, isPlain := b.plain[fi.Name]
+		b.mu.Unlock()
+
+		if !isContainer && !isPlain {
+			unknown = append(unknown, file{fi.Name, fi.Size})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range unknown {
+		entries, ok, err := b.readContainer(ctx, fi.name, fi.size)
+		if err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		if !ok {
+			b.plain[fi.name] = fi.size
+		} else {
+			debug.Log("read index of container %v with %d packs", fi.name, len(entries))
+			b.containers[fi.name] = struct{}{}
+			for _, e := range entries {
+				b.packs[e.name] = packLocation{container: fi.name, offset: e.offset, length: e.length}
+			}
+		}
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// lookup returns the location of the pack name. ok is false if the pack is
+// not stored in a container.
+func (b *Backend) lookup(ctx context.Context, name string) (loc packLocation, ok bool, err error) {
+	b.mu.Lock()
+	loc, ok = b.packs[name]
+	_, isPlain := b.plain[name]
+	b.mu.Unlock()
+	if ok || isPlain {
+		return loc, ok, nil
+	}
+
+	if err := b.refresh(ctx); err != nil {
+		return packLocation{}, false, err
+	}
+
+	b.mu.Lock()
+	loc, ok = b.packs[name]
+	b.mu.Unlock()
+	return loc, ok, nil
+}
+
+// Load reads packs from their container and all other files from the
+// underlying backend.
+func (b *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	if h.Type != backend.PackFile {
+		return b.be.Load(ctx, h, length, offset, fn)
+	}
+
+	loc, ok, err := b.lookup(ctx, h.Name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return b.be.Load(ctx, h, length, offset, fn)
+	}
+
+	if offset < 0 || offset > loc.length || int64(length) > loc.length-offset {
+		return errors.Wrapf(errRange, "load %v at offset %d with length %d", h, offset, length)
+	}
+	if length == 0 {
+		length = int(loc.length - offset)
+	}
+
+	b.mu.Lock()
+	s := b.spool
+	if s != nil && s.name == loc.container {
+		// the pack was not written to the underlying backend yet
+		buf := make([]byte, length)
+		_, err := s.f.ReadAt(buf, loc.offset+offset)
+		b.mu.Unlock()
+		if err != nil {
+			return errors.Wrap(err, "read spool file")
+		}
+		return fn(bytes.NewReader(buf))
+	}
+	b.mu.Unlock()
+
+	return b.be.Load(ctx, backend.Handle{Type: backend.PackFile, Name: loc.container}, length, loc.offset+offset, fn)
+}
+
+// Stat returns information about a file, the size of packs is read from the
+// index of their container.
+func (b *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	if h.Type != backend.PackFile {
+		return b.be.Stat(ctx, h)
+	}
+
+	loc, ok, err := b.lookup(ctx, h.Name)
+	if err != nil {
+		return backend.FileInfo{}, err
+	}
+	if !ok {
+		return b.be.Stat(ctx, h)
+	}
+	return backend.FileInfo{Name: h.Name, Size: loc.length}, nil
+}
+
+// List lists the packs in all containers instead of the containers.
+func (b *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	if t != backend.PackFile {
+		return b.be.List(ctx, t, fn)
+	}
+
+	if err := b.refresh(ctx); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	files := make([]backend.FileInfo, 0, len(b.packs)+len(b.plain))
+	for name, loc := range b.packs {
+		files = append(files, backend.FileInfo{Name: name, Size: loc.length})
+	}
+	for name, size := range b.plain {
+		files = append(files, backend.FileInfo{Name: name, Size: size})
+	}
+	b.mu.Unlock()
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	for _, fi := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := fn(fi); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// Remove removes a file. Packs can only be removed if they are not stored in
+// a container yet.
+func (b *Backend) Remove(ctx context.Context, h backend.Handle) error {
+	if h.Type != backend.PackFile {
+		return b.be.Remove(ctx, h)
+	}
+
+	loc, ok, err := b.lookup(ctx, h.Name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		err := b.be.Remove(ctx, h)
+		if err == nil {
+			b.mu.Lock()
+			delete(b.plain, h.Name)
+			b.mu.Unlock()
+		}
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.spool
+	if s == nil || s.name != loc.container {
+		return errors.Wrapf(errAppendOnly, "remove %v", h)
+	}
+
+	// the data remains in the container, but is not included in its index
+	for i, e := range s.entries {
+		if e.name == h.Name {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			break
+		}
+	}
+	delete(b.packs, h.Name)
+	return nil
+}
+
+// Close writes the spooled container and closes the underlying backend.
+func (b *Backend) Close() error {
+	b.mu.Lock()
+	err := b.flush(context.Background())
+	if b.spool != nil {
+		if rerr := b.spool.remove(); err == nil {
+			err = rerr
+		}
+		b.spool = nil
+	}
+	b.mu.Unlock()
+
+	if cerr := b.be.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Delete removes all data in the underlying backend.
+func (b *Backend) Delete(ctx context.Context) error {
+	b.mu.Lock()
+	if b.spool != nil {
+		_ = b.spool.remove()
+		b.spool = nil
+	}
+	b.packs = make(map[string]packLocation)
+	b.containers = make(map[string]struct{})
+	b.plain = make(map[string]int64)
+	b.mu.Unlock()
+
+	return b.be.Delete(ctx)
+}
+
+type factory struct {
+	registry *location.Registry
+}
+
+// NewFactory returns the factory for tape locations. The location of the
+// underlying backend is parsed using registry. As the underlying backend must
+// be opened first, the factory cannot open the backend itself, use New
+// instead.
+func NewFactory(registry *location.Registry) location.Factory {
+	return &factory{registry: registry}
+}
+
+func (f *factory) Scheme() string {
+	return "tape"
+}
+
+func (f *factory) ParseConfig(s string) (interface{}, error) {
+	return ParseConfig(s)
+}
+
+func (f *factory) StripPassword(s string) string {
+	cfg, err := ParseConfig(s)
+	if err != nil {
+		return s
+	}
+	return "tape:" + location.StripPassword(f.registry, cfg.Location)
+}
+
+func (f *factory) Create(_ context.Context, _ interface{}, _ http.RoundTripper, _ limiter.Limiter) (backend.Backend, error) {
+	return nil, errors.New("the tape backend must be created on top of another backend")
+}
+
+func (f *factory) Open(_ context.Context, _ interface{}, _ http.RoundTripper, _ limiter.Limiter) (backend.Backend, error) {
+	return nil, errors.New("the tape backend must be opened on top of another backend")
+}
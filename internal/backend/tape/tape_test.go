@@ -0,0 +1,195 @@
+package tape
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func newTestBackend(t testing.TB, inner backend.Backend, containerSize uint) *Backend {
+	cfg := NewConfig()
+	cfg.ContainerSize = containerSize
+	cfg.SpoolDir = rtest.TempDir(t)
+
+	be, err := New(cfg, inner)
+	rtest.OK(t, err)
+	return be
+}
+
+// savePack saves a pack with size bytes of random data and returns its name
+// and content.
+func savePack(t testing.TB, be backend.Backend, size int) (string, []byte) {
+	data := rtest.Random(rand.Int(), size)
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:])
+
+	h := backend.Handle{Type: backend.PackFile, Name: name}
+	rtest.OK(t, be.Save(context.TODO(), h, backend.NewByteReader(data, be.Hasher())))
+	return name, data
+}
+
+func load(t testing.TB, be backend.Backend, h backend.Handle, length int, offset int64) ([]byte, error) {
+	var buf []byte
+	err := be.Load(context.TODO(), h, length, offset, func(rd io.Reader) error {
+		var err error
+		buf, err = io.ReadAll(rd)
+		return err
+	})
+	return buf, err
+}
+
+func list(t testing.TB, be backend.Backend, tpe backend.FileType) map[string]int64 {
+	files := make(map[string]int64)
+	rtest.OK(t, be.List(context.TODO(), tpe, func(fi backend.FileInfo) error {
+		files[fi.Name] = fi.Size
+		return nil
+	}))
+	return files
+}
+
+func testLoad(t testing.TB, be backend.Backend, name string, data []byte) {
+	h := backend.Handle{Type: backend.PackFile, Name: name}
+
+	buf, err := load(t, be, h, 0, 0)
+	rtest.OK(t, err)
+	rtest.Assert(t, bytes.Equal(data, buf), "wrong content of %v", name)
+
+	buf, err = load(t, be, h, 100, 1000)
+	rtest.OK(t, err)
+	rtest.Assert(t, bytes.Equal(data[1000:1100], buf), "wrong partial content of %v", name)
+
+	buf, err = load(t, be, h, 0, int64(len(data)-10))
+	rtest.OK(t, err)
+	rtest.Assert(t, bytes.Equal(data[len(data)-10:], buf), "wrong tail of %v", name)
+
+	_, err = load(t, be, h, 20, int64(len(data)-10))
+	rtest.Assert(t, err != nil && be.IsPermanentError(err), "expected permanent error for reading beyond the pack, got %v", err)
+
+	fi, err := be.Stat(context.TODO(), h)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len(data)), fi.Size)
+}
+
+func TestContainer(t *testing.T) {
+	inner := mem.New()
+	be := newTestBackend(t, inner, 4096)
+
+	packs := make(map[string][]byte)
+	for i := 0; i < 3; i++ {
+		name, data := savePack(t, be, 5000+i*1000)
+		packs[name] = data
+	}
+
+	// the packs are read from the spooled container
+	for name, data := range packs {
+		testLoad(t, be, name, data)
+	}
+	rtest.Equals(t, 0, len(list(t, inner, backend.PackFile)))
+
+	// saving an index writes the container first
+	idx := backend.Handle{Type: backend.IndexFile, Name: "idx"}
+	rtest.OK(t, be.Save(context.TODO(), idx, backend.NewByteReader([]byte("index"), be.Hasher())))
+	rtest.Equals(t, 1, len(list(t, inner, backend.PackFile)))
+
+	want := make(map[string]int64)
+	for name, data := range packs {
+		want[name] = int64(len(data))
+		testLoad(t, be, name, data)
+	}
+	rtest.Equals(t, want, list(t, be, backend.PackFile))
+
+	var name string
+	for name = range packs {
+		break
+	}
+	err := be.Remove(context.TODO(), backend.Handle{Type: backend.PackFile, Name: name})
+	rtest.Assert(t, err != nil && be.IsPermanentError(err), "expected permanent error for removing a pack in a container, got %v", err)
+
+	// a new backend reads the index of the container
+	be = newTestBackend(t, inner, 4096)
+	rtest.Equals(t, want, list(t, be, backend.PackFile))
+	for name, data := range packs {
+		testLoad(t, be, name, data)
+	}
+
+	_, err = be.Stat(context.TODO(), backend.Handle{Type: backend.PackFile, Name: hex.EncodeToString(make([]byte, 32))})
+	rtest.Assert(t, be.IsNotExist(err), "expected not found error, got %v", err)
+}
+
+func TestContainerSize(t *testing.T) {
+	inner := mem.New()
+	be := newTestBackend(t, inner, 1)
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		name, _ := savePack(t, be, 600*1024)
+		names = append(names, name)
+	}
+	// the third pack is still spooled
+	rtest.Equals(t, 2, len(list(t, inner, backend.PackFile)))
+
+	rtest.OK(t, be.Close())
+	rtest.Equals(t, 3, len(list(t, inner, backend.PackFile)))
+
+	be = newTestBackend(t, inner, 1)
+	var listed []string
+	for name := range list(t, be, backend.PackFile) {
+		listed = append(listed, name)
+	}
+	sort.Strings(names)
+	sort.Strings(listed)
+	rtest.Equals(t, names, listed)
+}
+
+func TestRemove(t *testing.T) {
+	inner := mem.New()
+	be := newTestBackend(t, inner, 4096)
+
+	// spooled packs are left out of the container
+	removed, _ := savePack(t, be, 3000)
+	kept, data := savePack(t, be, 4000)
+	rtest.OK(t, be.Remove(context.TODO(), backend.Handle{Type: backend.PackFile, Name: removed}))
+	rtest.OK(t, be.Flush(context.TODO()))
+
+	be = newTestBackend(t, inner, 4096)
+	rtest.Equals(t, map[string]int64{kept: int64(len(data))}, list(t, be, backend.PackFile))
+
+	// packs which are not stored in a container are passed through
+	plain, data := savePack(t, inner, 2000)
+	rtest.Equals(t, map[string]int64{kept: 4000, plain: int64(len(data))}, list(t, be, backend.PackFile))
+	testLoad(t, be, plain, data)
+	rtest.OK(t, be.Remove(context.TODO(), backend.Handle{Type: backend.PackFile, Name: plain}))
+	rtest.Equals(t, map[string]int64{kept: 4000}, list(t, be, backend.PackFile))
+}
+
+func TestDamagedIndex(t *testing.T) {
+	inner := mem.New()
+	be := newTestBackend(t, inner, 4096)
+	savePack(t, be, 3000)
+	rtest.OK(t, be.Flush(context.TODO()))
+
+	var container string
+	for container = range list(t, inner, backend.PackFile) {
+		break
+	}
+	h := backend.Handle{Type: backend.PackFile, Name: container}
+	buf, err := load(t, inner, h, 0, 0)
+	rtest.OK(t, err)
+	// modify the offset of the pack within the index
+	buf[len(buf)-footerSize-10] ^= 1
+	rtest.OK(t, inner.Remove(context.TODO(), h))
+	rtest.OK(t, inner.Save(context.TODO(), h, backend.NewByteReader(buf, inner.Hasher())))
+
+	be = newTestBackend(t, inner, 4096)
+	err = be.List(context.TODO(), backend.PackFile, func(backend.FileInfo) error { return nil })
+	rtest.Assert(t, err != nil, "expected error for damaged index")
+}
@@ -0,0 +1,521 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/layout"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/util"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// make sure the WebDAV backend implements backend.Backend
+var _ backend.Backend = &Backend{}
+
+// Backend stores data on a WebDAV server, for example a Nextcloud share. The
+// repository uses the same directory structure as the local backend.
+type Backend struct {
+	url         *url.URL
+	path        string
+	connections uint
+	client      http.Client
+	layout.Layout
+}
+
+// webdavError is returned whenever the server returns a non-successful HTTP status.
+type webdavError struct {
+	backend.Handle
+	StatusCode int
+	Status     string
+}
+
+func (e *webdavError) Error() string {
+	if e.StatusCode == http.StatusNotFound && e.Handle.Type.String() != "invalid" {
+		return fmt.Sprintf("%v does not exist", e.Handle)
+	}
+	return fmt.Sprintf("unexpected HTTP response (%v): %v", e.StatusCode, e.Status)
+}
+
+func NewFactory() location.Factory {
+	return location.NewHTTPBackendFactory("webdav", ParseConfig, StripPassword, Create, Open)
+}
+
+// WebDAV methods which are not defined by net/http.
+const (
+	methodPropfind = "PROPFIND"
+	methodMkcol    = "MKCOL"
+)
+
+// Open opens the WebDAV backend with the given config. The HTTP connections
+// to the server are pooled by the round tripper rt.
+func Open(_ context.Context, cfg Config, rt http.RoundTripper) (*Backend, error) {
+	p := strings.TrimSuffix(cfg.URL.Path, "/")
+	be := &Backend{
+		url:         cfg.URL,
+		path:        p,
+		client:      http.Client{Transport: rt},
+		Layout:      &layout.DefaultLayout{Path: p, Join: path.Join},
+		connections: cfg.Connections,
+	}
+
+	return be, nil
+}
+
+func drainAndClose(resp *http.Response) error {
+	_, err := io.Copy(io.Discard, resp.Body)
+	cerr := resp.Body.Close()
+
+	// return first error
+	if err != nil {
+		return errors.Errorf("drain: %w", err)
+	}
+	return cerr
+}
+
+// Create creates the directories for a new repository on the WebDAV server
+// configured in config.
+func Create(ctx context.Context, cfg Config, rt http.RoundTripper) (*Backend, error) {
+	be, err := Open(ctx, cfg, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = be.Stat(ctx, backend.Handle{Type: backend.ConfigFile})
+	if err == nil {
+		return nil, errors.New("config file already exists")
+	}
+
+	if err := be.mkcolAll(ctx, be.path); err != nil {
+		return nil, err
+	}
+
+	// the data subdirs can only be created once the top level directories exist
+	datadir, _ := be.Basedir(backend.PackFile)
+	var subdirs []string
+	for _, d := range be.Paths() {
+		if path.Dir(d) == datadir {
+			subdirs = append(subdirs, d)
+			continue
+		}
+		if err := be.mkcol(ctx, d); err != nil {
+			return nil, err
+		}
+	}
+
+	// Run multiple MKCOL requests concurrently, creating all subdirs one by
+	// one is slow on high-latency links.
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(int(cfg.Connections))
+	for _, d := range subdirs {
+		d := d
+		g.Go(func() error {
+			return be.mkcol(gCtx, d)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	return be, nil
+}
+
+// do sends a request with the given method to the server for the path p.
+func (b *Backend) do(ctx context.Context, method, p string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.urlFor(p), body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "client.Do")
+	}
+	return resp, nil
+}
+
+// mkcol creates the directory p. It succeeds if the directory already exists.
+func (b *Backend) mkcol(ctx context.Context, p string) error {
+	resp, err := b.do(ctx, methodMkcol, p, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := drainAndClose(resp); err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+		return nil
+	case http.StatusMethodNotAllowed:
+		// MKCOL is not allowed on existing resources
+		return nil
+	}
+	return &webdavError{backend.Handle{}, resp.StatusCode, resp.Status}
+}
+
+// mkcolAll creates the directory p and all missing parent directories.
+func (b *Backend) mkcolAll(ctx context.Context, p string) error {
+	if p == "" || p == "/" {
+		return nil
+	}
+
+	err := b.mkcol(ctx, p)
+	var werr *webdavError
+	if errors.As(err, &werr) && werr.StatusCode == http.StatusConflict {
+		// a parent directory is missing
+		if err := b.mkcolAll(ctx, path.Dir(p)); err != nil {
+			return err
+		}
+		err = b.mkcol(ctx, p)
+	}
+	return err
+}
+
+func (b *Backend) Connections() uint {
+	return b.connections
+}
+
+// Hasher may return a hash function for calculating a content hash for the backend
+func (b *Backend) Hasher() hash.Hash {
+	return nil
+}
+
+// HasAtomicReplace returns whether Save() can atomically replace files
+func (b *Backend) HasAtomicReplace() bool {
+	// a PUT request can leave a partially written file on many servers
+	return false
+}
+
+// Save stores data in the backend at the handle.
+func (b *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	filename := b.Filename(h)
+
+	// make sure that client.Do() cannot close the reader by wrapping it
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.urlFor(filename), io.NopCloser(rd))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// explicitly set the content length, this prevents chunked encoding and
+	// let's the server know what's coming.
+	req.ContentLength = rd.Length()
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		b.removePartial(filename)
+		return errors.WithStack(err)
+	}
+
+	if err := drainAndClose(resp); err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	}
+
+	b.removePartial(filename)
+	return &webdavError{h, resp.StatusCode, resp.Status}
+}
+
+// removePartial removes a file which may have been written partially by a
+// failed upload. Errors are ignored, the file may not exist.
+func (b *Backend) removePartial(filename string) {
+	resp, err := b.do(context.Background(), http.MethodDelete, filename, nil, nil)
+	if err != nil {
+		debug.Log("removing partial file %v failed: %v", filename, err)
+		return
+	}
+	_ = drainAndClose(resp)
+}
+
+// urlFor returns the URL of the path p on the server.
+func (b *Backend) urlFor(p string) string {
+	u := *b.url
+	u.Path = p
+	u.RawPath = ""
+	u.RawQuery = ""
+	return u.String()
+}
+
+// IsNotExist returns true if the error was caused by a non-existing file.
+func (b *Backend) IsNotExist(err error) bool {
+	var e *webdavError
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// IsPermanentError returns true if retrying the request cannot succeed, for
+// example because the credentials are wrong or the server is out of space.
+// Other errors, including rate limiting and server errors, are retried.
+func (b *Backend) IsPermanentError(err error) bool {
+	if b.IsNotExist(err) {
+		return true
+	}
+
+	var werr *webdavError
+	if errors.As(err, &werr) {
+		switch werr.StatusCode {
+		case http.StatusRequestedRangeNotSatisfiable, http.StatusUnauthorized,
+			http.StatusForbidden, http.StatusInsufficientStorage:
+			return true
+		}
+	}
+
+	return false
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset.
+func (b *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	return util.DefaultLoad(ctx, h, length, offset, b.openReader, fn)
+}
+
+func (b *Backend) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+int64(length)-1)
+	}
+
+	resp, err := b.do(ctx, http.MethodGet, b.Filename(h), nil, http.Header{"Range": {byteRange}})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		_ = drainAndClose(resp)
+		return nil, &webdavError{h, resp.StatusCode, resp.Status}
+	}
+
+	// servers may ignore the range header and return the whole file
+	if resp.StatusCode == http.StatusOK && offset > 0 {
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			_ = resp.Body.Close()
+			return nil, errors.Wrap(err, "skip to offset")
+		}
+	}
+
+	if feature.Flag.Enabled(feature.BackendErrorRedesign) && length > 0 && resp.StatusCode == http.StatusPartialContent && resp.ContentLength != int64(length) {
+		_ = resp.Body.Close()
+		return nil, &webdavError{h, http.StatusRequestedRangeNotSatisfiable, "partial out of bounds read"}
+	}
+
+	return resp.Body, nil
+}
+
+// Stat returns information about a blob.
+func (b *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	resp, err := b.do(ctx, http.MethodHead, b.Filename(h), nil, nil)
+	if err != nil {
+		return backend.FileInfo{}, err
+	}
+
+	if err = drainAndClose(resp); err != nil {
+		return backend.FileInfo{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return backend.FileInfo{}, &webdavError{h, resp.StatusCode, resp.Status}
+	}
+
+	if resp.ContentLength < 0 {
+		return backend.FileInfo{}, errors.New("negative content length")
+	}
+
+	bi := backend.FileInfo{
+		Size: resp.ContentLength,
+		Name: h.Name,
+	}
+
+	return bi, nil
+}
+
+// Remove removes the blob with the given name and type.
+func (b *Backend) Remove(ctx context.Context, h backend.Handle) error {
+	resp, err := b.do(ctx, http.MethodDelete, b.Filename(h), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = drainAndClose(resp); err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return &webdavError{h, resp.StatusCode, resp.Status}
+	}
+
+	return nil
+}
+
+// propfindRequest asks for the properties needed to list a directory.
+const propfindRequest = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/></d:prop></d:propfind>`
+
+// multistatus is the response to a PROPFIND request, see RFC 4918.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// davEntry is an entry of a directory on the server.
+type davEntry struct {
+	Name  string
+	Size  int64
+	IsDir bool
+}
+
+// readDir returns the entries of the directory dir. A missing directory is
+// reported as an error for which IsNotExist returns true.
+func (b *Backend) readDir(ctx context.Context, dir string) ([]davEntry, error) {
+	dir = strings.TrimSuffix(dir, "/") + "/"
+	resp, err := b.do(ctx, methodPropfind, dir, strings.NewReader(propfindRequest), http.Header{
+		"Depth":        {"1"},
+		"Content-Type": {"application/xml; charset=utf-8"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		_ = drainAndClose(resp)
+		return nil, &webdavError{backend.Handle{}, resp.StatusCode, resp.Status}
+	}
+
+	var ms multistatus
+	err = xml.NewDecoder(resp.Body).Decode(&ms)
+	if cerr := drainAndClose(resp); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Decode")
+	}
+
+	var entries []davEntry
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid href")
+		}
+		p := href.Path
+		// skip the directory itself
+		if strings.TrimSuffix(p, "/") == strings.TrimSuffix(dir, "/") {
+			continue
+		}
+
+		entry := davEntry{Name: path.Base(p)}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			entry.IsDir = ps.Prop.ResourceType.Collection != nil
+			if ps.Prop.ContentLength != "" {
+				entry.Size, err = strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid size of %v", entry.Name)
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// List runs fn for each file in the backend which has the type t. When an
+// error occurs (or fn returns an error), List stops and returns it.
+func (b *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	basedir, subdirs := b.Basedir(t)
+
+	dirs := []string{basedir}
+	if subdirs {
+		entries, err := b.readDir(ctx, basedir)
+		if b.IsNotExist(err) {
+			// ignore missing directories
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		dirs = dirs[:0]
+		for _, entry := range entries {
+			if entry.IsDir {
+				dirs = append(dirs, path.Join(basedir, entry.Name))
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		entries, err := b.readDir(ctx, dir)
+		if b.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir {
+				continue
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			err := fn(backend.FileInfo{Name: entry.Name, Size: entry.Size})
+			if err != nil {
+				return err
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Close closes all open files.
+func (b *Backend) Close() error {
+	// this does not need to do anything, all open files are closed within the
+	// same function.
+	return nil
+}
+
+// Delete removes all data in the backend.
+func (b *Backend) Delete(ctx context.Context) error {
+	return util.DefaultDelete(ctx, b)
+}
@@ -0,0 +1,66 @@
+package webdav_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/restic/restic/internal/backend/test"
+	backendwebdav "github.com/restic/restic/internal/backend/webdav"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func runWebDAVServer(t testing.TB) *url.URL {
+	srv := httptest.NewServer(&webdav.Handler{
+		FileSystem: webdav.Dir(rtest.TempDir(t)),
+		LockSystem: webdav.NewMemLS(),
+	})
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL + "/remote.php/dav/files/restic-test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func newTestSuite(url *url.URL, minimalData bool) *test.Suite[backendwebdav.Config] {
+	return &test.Suite[backendwebdav.Config]{
+		MinimalData: minimalData,
+
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (*backendwebdav.Config, error) {
+			cfg := backendwebdav.NewConfig()
+			cfg.URL = url
+			return &cfg, nil
+		},
+
+		Factory: backendwebdav.NewFactory(),
+	}
+}
+
+func TestBackendWebDAV(t *testing.T) {
+	newTestSuite(runWebDAVServer(t), false).RunTests(t)
+}
+
+func TestBackendWebDAVExternalServer(t *testing.T) {
+	repostr := os.Getenv("RESTIC_TEST_WEBDAV_REPOSITORY")
+	if repostr == "" {
+		t.Skipf("environment variable %v not set", "RESTIC_TEST_WEBDAV_REPOSITORY")
+	}
+
+	cfg, err := backendwebdav.ParseConfig(repostr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ApplyEnvironment("")
+
+	newTestSuite(cfg.URL, true).RunTests(t)
+}
+
+func BenchmarkBackendWebDAV(t *testing.B) {
+	newTestSuite(runWebDAVServer(t), false).RunBenchmarks(t)
+}
@@ -0,0 +1,264 @@
+// Package catalog implements snapshot catalogs. A catalog lists the paths of
+// all nodes of a snapshot, sorted in the order of the trees, together with a
+// summary of each node. The entries are split into pages, which are stored as
+// tree blobs. For snapshots with hundreds of millions of files, loading a few
+// large pages is much faster than loading every tree of the snapshot.
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/walker"
+)
+
+// pageEntries is the number of entries stored in each page.
+var pageEntries = 4096
+
+// Entry describes a node of the snapshot.
+type Entry struct {
+	// Path is the slash-separated path of the node within the snapshot.
+	Path string `json:"path"`
+	// Node contains the metadata of the node, without its subtree, content
+	// and extended or generic attributes.
+	Node *restic.Node `json:"node"`
+	// ContentHash is the hash of the content of files, see ContentHash.
+	ContentHash *restic.ID `json:"content_hash,omitempty"`
+}
+
+// pageRef references a page of the catalog.
+type pageRef struct {
+	// First is the path of the first entry of the page.
+	First string    `json:"first"`
+	ID    restic.ID `json:"id"`
+}
+
+type page struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Catalog is the root object of a catalog.
+type Catalog struct {
+	// Tree is the root tree of the snapshot the catalog was built for.
+	Tree    restic.ID `json:"tree"`
+	Entries uint64    `json:"entries"`
+	Pages   []pageRef `json:"pages"`
+
+	repo restic.BlobLoader
+}
+
+// Repository is the part of the repository used to build catalogs.
+type Repository interface {
+	restic.BlobLoader
+	restic.BlobSaver
+}
+
+// ContentHash returns the hash of the IDs of the data blobs of the node. Two
+// files have the same content if their content hashes are equal.
+func ContentHash(node *restic.Node) restic.ID {
+	buf := make([]byte, 0, len(node.Content)*len(restic.ID{}))
+	for _, id := range node.Content {
+		buf = append(buf, id[:]...)
+	}
+	return restic.Hash(buf)
+}
+
+// pathKey returns a key for p whose byte order matches the order of the
+// entries, which sorts all nodes of a directory directly after it.
+func pathKey(p string) string {
+	return strings.ReplaceAll(p, "/", "\x00")
+}
+
+// summary returns a copy of node without the fields which are not stored in
+// the catalog.
+func summary(node *restic.Node) *restic.Node {
+	n := *node
+	n.Subtree = nil
+	n.Content = nil
+	n.ExtendedAttributes = nil
+	n.GenericAttributes = nil
+	return &n
+}
+
+func saveJSON(ctx context.Context, repo restic.BlobSaver, item interface{}) (restic.ID, error) {
+	buf, err := json.Marshal(item)
+	if err != nil {
+		return restic.ID{}, errors.Wrap(err, "MarshalJSON")
+	}
+	id, _, _, err := repo.SaveBlob(ctx, restic.TreeBlob, buf, restic.ID{}, false)
+	return id, err
+}
+
+func loadJSON(ctx context.Context, repo restic.BlobLoader, id restic.ID, item interface{}) error {
+	buf, err := repo.LoadBlob(ctx, restic.TreeBlob, id, nil)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(json.Unmarshal(buf, item), "Unmarshal")
+}
+
+// Build creates the catalog of the tree and returns the ID of its root
+// object. It loads all trees of the snapshot, the blobs of the catalog are
+// saved using repo, the caller must flush the repository afterwards.
+func Build(ctx context.Context, repo Repository, tree restic.ID) (restic.ID, error) {
+	c := &Catalog{Tree: tree}
+	var entries []Entry
+
+	savePage := func() error {
+		id, err := saveJSON(ctx, repo, page{Entries: entries})
+		if err != nil {
+			return err
+		}
+		c.Pages = append(c.Pages, pageRef{First: entries[0].Path, ID: id})
+		entries = entries[:0]
+		return nil
+	}
+
+	err := walker.Walk(ctx, repo, tree, walker.WalkVisitor{ProcessNode: func(_ restic.ID, nodepath string, node *restic.Node, err error) error {
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			return nil
+		}
+
+		entry := Entry{Path: nodepath, Node: summary(node)}
+		if node.Type == "file" {
+			hash := ContentHash(node)
+			entry.ContentHash = &hash
+		}
+		entries = append(entries, entry)
+		c.Entries++
+
+		if len(entries) == pageEntries {
+			return savePage()
+		}
+		return nil
+	}})
+	if err != nil {
+		return restic.ID{}, err
+	}
+	if len(entries) > 0 {
+		if err := savePage(); err != nil {
+			return restic.ID{}, err
+		}
+	}
+
+	debug.Log("catalog of tree %v has %d entries in %d pages", tree.Str(), c.Entries, len(c.Pages))
+	return saveJSON(ctx, repo, c)
+}
+
+// Load loads the catalog of the snapshot. It returns nil if the snapshot has
+// no catalog or if the catalog was built for a different tree, for example
+// because the snapshot was rewritten.
+func Load(ctx context.Context, repo restic.BlobLoader, sn *restic.Snapshot) (*Catalog, error) {
+	if sn.Catalog == nil || sn.Tree == nil {
+		return nil, nil
+	}
+
+	c := &Catalog{}
+	if err := loadJSON(ctx, repo, *sn.Catalog, c); err != nil {
+		return nil, errors.Wrapf(err, "loading catalog %v", sn.Catalog.Str())
+	}
+	if !c.Tree.Equal(*sn.Tree) {
+		debug.Log("catalog %v belongs to tree %v instead of %v", sn.Catalog.Str(), c.Tree.Str(), sn.Tree.Str())
+		return nil, nil
+	}
+	c.repo = repo
+	return c, nil
+}
+
+func (c *Catalog) loadPage(ctx context.Context, ref pageRef) (*page, error) {
+	p := &page{}
+	if err := loadJSON(ctx, c.repo, ref.ID, p); err != nil {
+		return nil, errors.Wrapf(err, "loading catalog page %v", ref.ID.Str())
+	}
+	return p, nil
+}
+
+// Find returns the entry for the slash-separated path p, or nil if the
+// snapshot does not contain p. Only a single page is loaded.
+func (c *Catalog) Find(ctx context.Context, p string) (*Entry, error) {
+	key := pathKey(p)
+	i := sort.Search(len(c.Pages), func(i int) bool {
+		return pathKey(c.Pages[i].First) > key
+	}) - 1
+	if i < 0 {
+		return nil, nil
+	}
+
+	pg, err := c.loadPage(ctx, c.Pages[i])
+	if err != nil {
+		return nil, err
+	}
+	j := sort.Search(len(pg.Entries), func(j int) bool {
+		return pathKey(pg.Entries[j].Path) >= key
+	})
+	if j == len(pg.Entries) || pg.Entries[j].Path != p {
+		return nil, nil
+	}
+	return &pg.Entries[j], nil
+}
+
+// Walk calls fn for all entries in the order of the trees. Like for
+// walker.Walk, the entries within a directory are skipped if fn returns
+// walker.ErrSkipNode for it. Pages which only contain skipped entries are not
+// loaded. For other nodes, returning walker.ErrSkipNode has no effect.
+func (c *Catalog) Walk(ctx context.Context, fn func(nodepath string, node *restic.Node) error) error {
+	// skip is the key prefix of the entries within the skipped directory
+	var skip string
+
+	for i, ref := range c.Pages {
+		if skip != "" && i+1 < len(c.Pages) && strings.HasPrefix(pathKey(c.Pages[i+1].First), skip) {
+			debug.Log("skipping catalog page %v", ref.ID.Str())
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		pg, err := c.loadPage(ctx, ref)
+		if err != nil {
+			return err
+		}
+		for _, entry := range pg.Entries {
+			key := pathKey(entry.Path)
+			if skip != "" {
+				if strings.HasPrefix(key, skip) {
+					continue
+				}
+				skip = ""
+			}
+
+			err := fn(entry.Path, entry.Node)
+			if err == walker.ErrSkipNode {
+				if entry.Node.Type == "dir" {
+					skip = key + "\x00"
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FindUsedBlobs adds the blobs of the catalog with the given ID to blobs.
+func FindUsedBlobs(ctx context.Context, repo restic.BlobLoader, id restic.ID, blobs restic.FindBlobSet) error {
+	c := &Catalog{}
+	if err := loadJSON(ctx, repo, id, c); err != nil {
+		return errors.Wrapf(err, "loading catalog %v", id.Str())
+	}
+	blobs.Insert(restic.BlobHandle{Type: restic.TreeBlob, ID: id})
+	for _, ref := range c.Pages {
+		blobs.Insert(restic.BlobHandle{Type: restic.TreeBlob, ID: ref.ID})
+	}
+	return nil
+}
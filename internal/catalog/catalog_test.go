@@ -0,0 +1,160 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/walker"
+	"golang.org/x/sync/errgroup"
+)
+
+type visit struct {
+	path string
+	node *restic.Node
+}
+
+// saveTree saves a tree with files and, up to the given depth,
+// subdirectories. The names are chosen such that the order of the paths
+// differs from their byte order, for example "/dir.a" < "/dir/file".
+func saveTree(t *testing.T, repo restic.Repository, depth int) restic.ID {
+	tree := restic.NewTree(0)
+	for i, name := range []string{"dir", "dir.a", "dir-b", "dir0"} {
+		if depth == 0 {
+			break
+		}
+		id := saveTree(t, repo, depth-1)
+		rtest.OK(t, tree.Insert(&restic.Node{Name: name, Type: "dir", Mode: 0755, Subtree: &id}))
+
+		data := []byte(fmt.Sprintf("%v %d", name, depth))
+		blob, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, data, restic.ID{}, false)
+		rtest.OK(t, err)
+		rtest.OK(t, tree.Insert(&restic.Node{Name: fmt.Sprintf("file%d", i), Type: "file", Mode: 0644,
+			Size: uint64(len(data)), Content: restic.IDs{blob}}))
+	}
+	rtest.OK(t, tree.Insert(&restic.Node{Name: "link", Type: "symlink", LinkTarget: "dir"}))
+
+	id, err := restic.SaveTree(context.TODO(), repo, tree)
+	rtest.OK(t, err)
+	return id
+}
+
+func buildCatalog(t *testing.T) (restic.Repository, *restic.Snapshot, *Catalog) {
+	defer func(n int) { pageEntries = n }(pageEntries)
+	pageEntries = 7
+
+	repo := repository.TestRepository(t)
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+	tree := saveTree(t, repo, 3)
+	rtest.OK(t, repo.Flush(context.TODO()))
+
+	repo.StartPackUploader(context.TODO(), &wg)
+	id, err := Build(context.TODO(), repo, tree)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.TODO()))
+
+	sn := &restic.Snapshot{Tree: &tree, Catalog: &id}
+	c, err := Load(context.TODO(), repo, sn)
+	rtest.OK(t, err)
+	rtest.Assert(t, c != nil, "catalog was not loaded")
+	rtest.Assert(t, len(c.Pages) > 3, "expected several pages, got %d", len(c.Pages))
+	return repo, sn, c
+}
+
+// walk returns the nodes visited by walker.Walk and Catalog.Walk. fn decides
+// which directories are skipped.
+func walk(t *testing.T, repo restic.Repository, sn *restic.Snapshot, c *Catalog, skip func(path string) bool) (fromTrees, fromCatalog []visit) {
+	err := walker.Walk(context.TODO(), repo, *sn.Tree, walker.WalkVisitor{ProcessNode: func(_ restic.ID, nodepath string, node *restic.Node, err error) error {
+		rtest.OK(t, err)
+		if node == nil {
+			return nil
+		}
+		fromTrees = append(fromTrees, visit{nodepath, summary(node)})
+		if node.Type == "dir" && skip(nodepath) {
+			return walker.ErrSkipNode
+		}
+		return nil
+	}})
+	rtest.OK(t, err)
+
+	err = c.Walk(context.TODO(), func(nodepath string, node *restic.Node) error {
+		fromCatalog = append(fromCatalog, visit{nodepath, node})
+		if node.Type == "dir" && skip(nodepath) {
+			return walker.ErrSkipNode
+		}
+		return nil
+	})
+	rtest.OK(t, err)
+	return fromTrees, fromCatalog
+}
+
+func TestCatalogWalk(t *testing.T) {
+	repo, sn, c := buildCatalog(t)
+
+	fromTrees, fromCatalog := walk(t, repo, sn, c, func(string) bool { return false })
+	rtest.Equals(t, uint64(len(fromTrees)), c.Entries)
+	rtest.Equals(t, len(fromTrees), len(fromCatalog))
+	for i := range fromTrees {
+		rtest.Equals(t, fromTrees[i].path, fromCatalog[i].path)
+		rtest.Assert(t, fromTrees[i].node.Equals(*fromCatalog[i].node), "node %v differs", fromTrees[i].path)
+	}
+
+	// skip all directories named "dir" and "dir-b"
+	fromTrees, fromCatalog = walk(t, repo, sn, c, func(path string) bool {
+		return strings.HasSuffix(path, "/dir") || strings.HasSuffix(path, "/dir-b")
+	})
+	rtest.Assert(t, uint64(len(fromTrees)) < c.Entries, "no directory was skipped")
+	rtest.Equals(t, len(fromTrees), len(fromCatalog))
+	for i := range fromTrees {
+		rtest.Equals(t, fromTrees[i].path, fromCatalog[i].path)
+	}
+}
+
+func TestCatalogFind(t *testing.T) {
+	repo, sn, c := buildCatalog(t)
+
+	err := walker.Walk(context.TODO(), repo, *sn.Tree, walker.WalkVisitor{ProcessNode: func(_ restic.ID, nodepath string, node *restic.Node, err error) error {
+		rtest.OK(t, err)
+		if node == nil {
+			return nil
+		}
+
+		entry, err := c.Find(context.TODO(), nodepath)
+		rtest.OK(t, err)
+		rtest.Assert(t, entry != nil, "%v not found", nodepath)
+		rtest.Equals(t, nodepath, entry.Path)
+		if node.Type == "file" {
+			rtest.Equals(t, ContentHash(node), *entry.ContentHash)
+		}
+
+		entry, err = c.Find(context.TODO(), nodepath+"-missing")
+		rtest.OK(t, err)
+		rtest.Assert(t, entry == nil, "found missing path %v", entry)
+		return nil
+	}})
+	rtest.OK(t, err)
+
+	entry, err := c.Find(context.TODO(), "/")
+	rtest.OK(t, err)
+	rtest.Assert(t, entry == nil, "found root directory %v", entry)
+}
+
+func TestCatalogStale(t *testing.T) {
+	repo, sn, _ := buildCatalog(t)
+
+	// a rewritten snapshot references a different tree
+	other := restic.NewRandomID()
+	sn.Tree = &other
+	c, err := Load(context.TODO(), repo, sn)
+	rtest.OK(t, err)
+	rtest.Assert(t, c == nil, "stale catalog was loaded")
+
+	blobs := restic.NewBlobSet()
+	rtest.OK(t, FindUsedBlobs(context.TODO(), repo, *sn.Catalog, blobs))
+	rtest.Assert(t, len(blobs) > 4, "expected catalog and page blobs, got %v", blobs)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/klauspost/compress/zstd"
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/s3"
+	"github.com/restic/restic/internal/catalog"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
@@ -290,7 +291,9 @@ func (e *TreeError) Error() string {
 }
 
 // checkTreeWorker checks the trees received and sends out errors to errChan.
-func (c *Checker) checkTreeWorker(ctx context.Context, trees <-chan restic.TreeItem, out chan<- error) {
+// If metadataOnly is set, data blobs which are missing in the index are not
+// reported.
+func (c *Checker) checkTreeWorker(ctx context.Context, trees <-chan restic.TreeItem, out chan<- error, metadataOnly bool) {
 	for job := range trees {
 		debug.Log("check tree %v (tree %v, err %v)", job.ID, job.Tree, job.Error)
 
@@ -298,7 +301,7 @@ func (c *Checker) checkTreeWorker(ctx context.Context, trees <-chan restic.TreeI
 		if job.Error != nil {
 			errs = append(errs, job.Error)
 		} else {
-			errs = c.checkTree(job.ID, job.Tree)
+			errs = c.checkTree(job.ID, job.Tree, metadataOnly)
 		}
 
 		if len(errs) == 0 {
@@ -314,7 +317,11 @@ func (c *Checker) checkTreeWorker(ctx context.Context, trees <-chan restic.TreeI
 	}
 }
 
-func loadSnapshotTreeIDs(ctx context.Context, lister restic.Lister, repo restic.LoaderUnpacked) (ids restic.IDs, errs []error) {
+// loadSnapshotTreeIDs returns the root trees of all snapshots. The trees of
+// metadata-only snapshots, whose data blobs are stored in a different
+// repository, are returned separately, as are the catalogs of the snapshots.
+func loadSnapshotTreeIDs(ctx context.Context, lister restic.Lister, repo restic.LoaderUnpacked) (ids restic.IDs, metadataOnlyIDs restic.IDs, catalogs restic.IDSet, errs []error) {
+	catalogs = restic.NewIDSet()
 	err := restic.ForAllSnapshots(ctx, lister, repo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
 		if err != nil {
 			errs = append(errs, err)
@@ -322,23 +329,33 @@ func loadSnapshotTreeIDs(ctx context.Context, lister restic.Lister, repo restic.
 		}
 		treeID := *sn.Tree
 		debug.Log("snapshot %v has tree %v", id, treeID)
-		ids = append(ids, treeID)
+		if sn.Catalog != nil {
+			catalogs.Insert(*sn.Catalog)
+		}
+		if sn.MetadataOnly() {
+			metadataOnlyIDs = append(metadataOnlyIDs, treeID)
+		} else {
+			ids = append(ids, treeID)
+		}
 		return nil
 	})
 	if err != nil {
 		errs = append(errs, err)
 	}
 
-	return ids, errs
+	return ids, metadataOnlyIDs, catalogs, errs
 }
 
 // Structure checks that for all snapshots all referenced data blobs and
-// subtrees are available in the index. errChan is closed after all trees have
-// been traversed.
+// subtrees are available in the index. For metadata-only snapshots, only the
+// subtrees must be available. errChan is closed after all trees have been
+// traversed.
 func (c *Checker) Structure(ctx context.Context, p *progress.Counter, errChan chan<- error) {
-	trees, errs := loadSnapshotTreeIDs(ctx, c.snapshots, c.repo)
-	p.SetMax(uint64(len(trees)))
-	debug.Log("need to check %d trees from snapshots, %d errs returned", len(trees), len(errs))
+	defer close(errChan)
+
+	trees, metadataOnlyTrees, catalogs, errs := loadSnapshotTreeIDs(ctx, c.snapshots, c.repo)
+	p.SetMax(uint64(len(trees) + len(metadataOnlyTrees)))
+	debug.Log("need to check %d trees from snapshots, %d metadata-only trees, %d errs returned", len(trees), len(metadataOnlyTrees), len(errs))
 
 	for _, err := range errs {
 		select {
@@ -348,6 +365,46 @@ func (c *Checker) Structure(ctx context.Context, p *progress.Counter, errChan ch
 		}
 	}
 
+	// Check the trees of regular snapshots first, such that trees which are
+	// also referenced by metadata-only snapshots are checked completely.
+	c.checkTrees(ctx, trees, false, p, errChan)
+	c.checkTrees(ctx, metadataOnlyTrees, true, p, errChan)
+	c.checkCatalogs(ctx, catalogs, errChan)
+}
+
+// checkCatalogs checks that all blobs of the catalogs are contained in the
+// index, see package catalog.
+func (c *Checker) checkCatalogs(ctx context.Context, catalogs restic.IDSet, errChan chan<- error) {
+	for id := range catalogs {
+		blobs := restic.NewBlobSet()
+		err := catalog.FindUsedBlobs(ctx, c.repo, id, blobs)
+		for h := range blobs {
+			if err != nil {
+				break
+			}
+			if _, found := c.repo.LookupBlobSize(h.Type, h.ID); !found {
+				err = errors.Errorf("blob %v not found in index", h.ID.Str())
+			}
+		}
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case errChan <- errors.Errorf("catalog %v: %v", id.Str(), err):
+			}
+		}
+
+		c.blobRefs.Lock()
+		for h := range blobs {
+			c.blobRefs.M.Insert(h)
+		}
+		c.blobRefs.Unlock()
+	}
+}
+
+// checkTrees checks the trees and all their subtrees which were not checked
+// before.
+func (c *Checker) checkTrees(ctx context.Context, trees restic.IDs, metadataOnly bool, p *progress.Counter, errChan chan<- error) {
 	wg, ctx := errgroup.WithContext(ctx)
 	treeStream := restic.StreamTrees(ctx, wg, c.repo, trees, func(treeID restic.ID) bool {
 		// blobRefs may be accessed in parallel by checkTree
@@ -360,12 +417,11 @@ func (c *Checker) Structure(ctx context.Context, p *progress.Counter, errChan ch
 		return blobReferenced
 	}, p)
 
-	defer close(errChan)
 	// The checkTree worker only processes already decoded trees and is thus CPU-bound
 	workerCount := runtime.GOMAXPROCS(0)
 	for i := 0; i < workerCount; i++ {
 		wg.Go(func() error {
-			c.checkTreeWorker(ctx, treeStream, errChan)
+			c.checkTreeWorker(ctx, treeStream, errChan, metadataOnly)
 			return nil
 		})
 	}
@@ -378,7 +434,7 @@ func (c *Checker) Structure(ctx context.Context, p *progress.Counter, errChan ch
 	}
 }
 
-func (c *Checker) checkTree(id restic.ID, tree *restic.Tree) (errs []error) {
+func (c *Checker) checkTree(id restic.ID, tree *restic.Tree, metadataOnly bool) (errs []error) {
 	debug.Log("checking tree %v", id)
 
 	for _, node := range tree.Nodes {
@@ -399,7 +455,7 @@ func (c *Checker) checkTree(id restic.ID, tree *restic.Tree) (errs []error) {
 				// by users, so we omit this check, see #1887
 
 				_, found := c.repo.LookupBlobSize(restic.DataBlob, blobID)
-				if !found {
+				if !found && !metadataOnly {
 					debug.Log("tree %v references blob %v which isn't contained in index", id, blobID)
 					errs = append(errs, &Error{TreeID: id, Err: errors.Errorf("file %q blob %v not found in index", node.Name, blobID)})
 				}
@@ -503,7 +559,7 @@ func (c *Checker) ReadPacks(ctx context.Context, packs map[restic.ID]int64, p *p
 	for i := 0; i < workerCount; i++ {
 		g.Go(func() error {
 			bufRd := bufio.NewReaderSize(nil, maxStreamBufferSize)
-			dec, err := zstd.NewReader(nil)
+			dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(c.repo.Config().CompressionDictionaries...))
 			if err != nil {
 				panic(dec)
 			}
@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Coverage records when the content of each pack file was last read and
+// verified completely. It is used to spread reading the repository data over
+// several runs of check.
+type Coverage struct {
+	Packs map[string]time.Time `json:"packs"`
+}
+
+// NewCoverage returns an empty Coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{Packs: make(map[string]time.Time)}
+}
+
+// LoadCoverage reads the coverage state from filename. A missing file
+// results in an empty Coverage.
+func LoadCoverage(filename string) (*Coverage, error) {
+	buf, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewCoverage(), nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ReadFile")
+	}
+
+	c := NewCoverage()
+	err = json.Unmarshal(buf, c)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshal")
+	}
+	if c.Packs == nil {
+		c.Packs = make(map[string]time.Time)
+	}
+	return c, nil
+}
+
+// Save atomically writes the coverage state to filename.
+func (c *Coverage) Save(filename string) error {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return errors.Wrap(err, "Marshal")
+	}
+
+	err = fs.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmpname := filename + ".tmp"
+	err = os.WriteFile(tmpname, buf, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(fs.Rename(tmpname, filename))
+}
+
+// LastVerified returns the time at which the pack was last verified, or the
+// zero time if it was never verified.
+func (c *Coverage) LastVerified(id restic.ID) time.Time {
+	return c.Packs[id.String()]
+}
+
+// Record marks the packs as verified at time t.
+func (c *Coverage) Record(packs restic.IDs, t time.Time) {
+	for _, id := range packs {
+		c.Packs[id.String()] = t
+	}
+}
+
+// Forget removes all packs not contained in allPacks from the coverage state.
+func (c *Coverage) Forget(allPacks map[restic.ID]int64) {
+	for name := range c.Packs {
+		id, err := restic.ParseID(name)
+		if err != nil {
+			delete(c.Packs, name)
+			continue
+		}
+		if _, ok := allPacks[id]; !ok {
+			delete(c.Packs, name)
+		}
+	}
+}
+
+// SelectPacksByBudget selects packs whose total size does not exceed budget.
+// Packs that were never verified are preferred, followed by the packs with
+// the oldest verification time. Packs with the same verification time are
+// selected randomly.
+func SelectPacksByBudget(allPacks map[restic.ID]int64, cov *Coverage, budget int64) map[restic.ID]int64 {
+	ids := make(restic.IDs, 0, len(allPacks))
+	for id := range allPacks {
+		ids = append(ids, id)
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(ids), func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+
+	sort.SliceStable(ids, func(i, j int) bool {
+		return cov.LastVerified(ids[i]).Before(cov.LastVerified(ids[j]))
+	})
+
+	packs := make(map[restic.ID]int64)
+	var total int64
+	for _, id := range ids {
+		size := allPacks[id]
+		if total+size > budget {
+			continue
+		}
+		packs[id] = size
+		total += size
+	}
+	return packs
+}
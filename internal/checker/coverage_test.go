@@ -0,0 +1,58 @@
+package checker_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestSelectPacksByBudget(t *testing.T) {
+	allPacks := make(map[restic.ID]int64)
+	var ids restic.IDs
+	for i := 0; i < 10; i++ {
+		id := restic.NewRandomID()
+		allPacks[id] = 100
+		ids = append(ids, id)
+	}
+
+	cov := checker.NewCoverage()
+	// all but the first two packs were already verified
+	cov.Record(ids[2:], time.Now())
+
+	packs := checker.SelectPacksByBudget(allPacks, cov, 250)
+	rtest.Equals(t, 2, len(packs))
+	for _, id := range ids[:2] {
+		_, ok := packs[id]
+		rtest.Assert(t, ok, "never verified pack %v was not selected", id.Str())
+	}
+
+	packs = checker.SelectPacksByBudget(allPacks, cov, 50)
+	rtest.Equals(t, 0, len(packs))
+
+	packs = checker.SelectPacksByBudget(allPacks, cov, 10000)
+	rtest.Equals(t, len(allPacks), len(packs))
+}
+
+func TestCoverageSaveLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "coverage.json")
+
+	cov, err := checker.LoadCoverage(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(cov.Packs))
+
+	keep, drop := restic.NewRandomID(), restic.NewRandomID()
+	now := time.Now().Truncate(time.Second)
+	cov.Record(restic.IDs{keep, drop}, now)
+	cov.Forget(map[restic.ID]int64{keep: 1})
+	rtest.OK(t, cov.Save(filename))
+
+	cov, err = checker.LoadCoverage(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(cov.Packs))
+	rtest.Assert(t, cov.LastVerified(keep).Equal(now), "unexpected verification time %v", cov.LastVerified(keep))
+	rtest.Assert(t, cov.LastVerified(drop).IsZero(), "removed pack is still recorded")
+}
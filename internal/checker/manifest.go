@@ -0,0 +1,92 @@
+package checker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ManifestError describes a pack file listed in the manifest of a snapshot
+// which is missing or has an unexpected size.
+type ManifestError struct {
+	Snapshot restic.ID
+	Pack     restic.ID
+	Err      error
+}
+
+func (e *ManifestError) Error() string {
+	return "snapshot " + e.Snapshot.Str() + ": pack " + e.Pack.String() + ": " + e.Err.Error()
+}
+
+// Manifests checks that the pack files listed in the manifests of all
+// snapshots still exist in the repository and have the recorded size. Only the
+// list of pack files is requested from the backend, no data is read. Packs
+// which are no longer contained in the index were removed by prune and are
+// skipped. LoadSnapshots and LoadIndex must be called before. errChan is
+// closed after all manifests have been checked.
+func (c *Checker) Manifests(ctx context.Context, errChan chan<- error) {
+	defer close(errChan)
+
+	var m sync.Mutex
+	manifests := make(map[restic.ID][]restic.ManifestPack)
+	err := restic.ForAllSnapshots(ctx, c.snapshots, c.repo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		if len(sn.Manifest) > 0 {
+			m.Lock()
+			manifests[id] = sn.Manifest
+			m.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		select {
+		case <-ctx.Done():
+		case errChan <- err:
+		}
+		return
+	}
+
+	debug.Log("checking manifests of %d snapshots", len(manifests))
+
+	repoPacks := make(map[restic.ID]int64)
+	err = c.repo.List(ctx, restic.PackFile, func(id restic.ID, size int64) error {
+		repoPacks[id] = size
+		return nil
+	})
+	if err != nil {
+		select {
+		case <-ctx.Done():
+		case errChan <- err:
+		}
+		return
+	}
+
+	for snID, manifest := range manifests {
+		for _, pack := range manifest {
+			var err error
+			if _, ok := c.packs[pack.ID]; !ok {
+				// removed by prune
+				continue
+			}
+			if size, ok := repoPacks[pack.ID]; !ok {
+				err = errors.New("does not exist")
+			} else if size != pack.Size {
+				err = errors.Errorf("unexpected file size: got %d, expected %d", size, pack.Size)
+			}
+			if err == nil {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case errChan <- &ManifestError{Snapshot: snID, Pack: pack.ID, Err: err}:
+			}
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 
@@ -86,6 +88,30 @@ func poly1305Verify(msg []byte, nonce []byte, key *MACKey, mac []byte) bool {
 	return poly1305.Verify(&m, msg, &k)
 }
 
+// poly1305MACWithAD computes the MAC over msg followed by the additional data
+// and its length. Without additional data, the MAC equals poly1305MAC(msg).
+func poly1305MACWithAD(msg, additionalData []byte, nonce []byte, key *MACKey) []byte {
+	k := poly1305PrepareKey(nonce, key)
+
+	m := poly1305.New(&k)
+	_, _ = m.Write(msg)
+	if len(additionalData) > 0 {
+		var l [8]byte
+		binary.LittleEndian.PutUint64(l[:], uint64(len(additionalData)))
+		_, _ = m.Write(additionalData)
+		_, _ = m.Write(l[:])
+	}
+
+	return m.Sum(nil)
+}
+
+func poly1305VerifyWithAD(msg, additionalData []byte, nonce []byte, key *MACKey, mac []byte) bool {
+	if len(additionalData) == 0 {
+		return poly1305Verify(msg, nonce, key, mac)
+	}
+	return subtle.ConstantTimeCompare(poly1305MACWithAD(msg, additionalData, nonce, key), mac) == 1
+}
+
 // NewRandomKey returns new encryption and message authentication keys.
 func NewRandomKey() *Key {
 	k := &Key{}
@@ -245,10 +271,6 @@ func (k *Key) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 		panic("key is invalid")
 	}
 
-	if len(additionalData) > 0 {
-		panic("additional data is not supported")
-	}
-
 	if len(nonce) != ivSize {
 		panic("incorrect nonce length")
 	}
@@ -266,7 +288,7 @@ func (k *Key) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 	e := cipher.NewCTR(c, nonce)
 	e.XORKeyStream(out, plaintext)
 
-	mac := poly1305MAC(out[:len(plaintext)], nonce, &k.MACKey)
+	mac := poly1305MACWithAD(out[:len(plaintext)], additionalData, nonce, &k.MACKey)
 	copy(out[len(plaintext):], mac)
 
 	return ret
@@ -283,7 +305,7 @@ func (k *Key) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 //
 // Even if the function fails, the contents of dst, up to its capacity,
 // may be overwritten.
-func (k *Key) Open(dst, nonce, ciphertext, _ []byte) ([]byte, error) {
+func (k *Key) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
 	if !k.Valid() {
 		return nil, errors.New("invalid key")
 	}
@@ -306,7 +328,7 @@ func (k *Key) Open(dst, nonce, ciphertext, _ []byte) ([]byte, error) {
 	ct, mac := ciphertext[:l], ciphertext[l:]
 
 	// verify mac
-	if !poly1305Verify(ct, nonce, &k.MACKey, mac) {
+	if !poly1305VerifyWithAD(ct, additionalData, nonce, &k.MACKey, mac) {
 		return nil, ErrUnauthenticated
 	}
 
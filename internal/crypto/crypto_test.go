@@ -43,6 +43,32 @@ func TestEncryptDecrypt(t *testing.T) {
 	}
 }
 
+func TestAdditionalData(t *testing.T) {
+	k := crypto.NewRandomKey()
+	data := rtest.Random(23, 1024)
+	ad := []byte("additional data")
+
+	nonce := crypto.NewRandomNonce()
+	ciphertext := k.Seal(nil, nonce, data, ad)
+
+	plaintext, err := k.Open(nil, nonce, ciphertext, ad)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, plaintext)
+
+	for _, other := range [][]byte{nil, []byte("other data"), ad[:len(ad)-1]} {
+		_, err = k.Open(nil, nonce, ciphertext, other)
+		rtest.Assert(t, err == crypto.ErrUnauthenticated, "expected ErrUnauthenticated for additional data %q, got %v", other, err)
+	}
+
+	// data sealed without additional data can only be opened without it
+	ciphertext = k.Seal(nil, nonce, data, nil)
+	_, err = k.Open(nil, nonce, ciphertext, ad)
+	rtest.Assert(t, err == crypto.ErrUnauthenticated, "expected ErrUnauthenticated, got %v", err)
+	plaintext, err = k.Open(nil, nonce, ciphertext, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, plaintext)
+}
+
 func TestSmallBuffer(t *testing.T) {
 	k := crypto.NewRandomKey()
 
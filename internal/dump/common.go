@@ -103,7 +103,21 @@ func (d *Dumper) WriteNode(ctx context.Context, node *restic.Node) error {
 	return d.writeNode(ctx, d.w, node)
 }
 
+// WriteNodeRange writes the part of a file node's contents selected by r
+// directly to d's Writer. Only the blobs covering the range are loaded.
+func (d *Dumper) WriteNodeRange(ctx context.Context, node *restic.Node, r restic.ByteRange) error {
+	blobs, skip, length, err := r.SelectBlobs(node.Content, d.repo.LookupBlobSize)
+	if err != nil {
+		return err
+	}
+	return d.writeBlobs(ctx, &rangeWriter{w: d.w, skip: skip, remaining: length}, blobs)
+}
+
 func (d *Dumper) writeNode(ctx context.Context, w io.Writer, node *restic.Node) error {
+	return d.writeBlobs(ctx, w, node.Content)
+}
+
+func (d *Dumper) writeBlobs(ctx context.Context, w io.Writer, content restic.IDs) error {
 	type loadTask struct {
 		id  restic.ID
 		out chan<- []byte
@@ -121,7 +135,7 @@ func (d *Dumper) writeNode(ctx context.Context, w io.Writer, node *restic.Node)
 	wg.Go(func() error {
 		defer close(loaderCh)
 		defer close(writerCh)
-		for _, id := range node.Content {
+		for _, id := range content {
 			// non-blocking blob handover to allow the loader to load the next blob
 			// while the old one is still written
 			ch := make(chan []byte, 1)
@@ -177,6 +191,36 @@ func (d *Dumper) writeNode(ctx context.Context, w io.Writer, node *restic.Node)
 	return wg.Wait()
 }
 
+// rangeWriter discards the first skip bytes and everything after the
+// following remaining bytes.
+type rangeWriter struct {
+	w         io.Writer
+	skip      uint64
+	remaining uint64
+}
+
+func (w *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.skip >= uint64(len(p)) {
+		w.skip -= uint64(len(p))
+		return n, nil
+	}
+	p = p[w.skip:]
+	w.skip = 0
+
+	if uint64(len(p)) > w.remaining {
+		p = p[:w.remaining]
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+	if _, err := w.w.Write(p); err != nil {
+		return 0, err
+	}
+	w.remaining -= uint64(len(p))
+	return n, nil
+}
+
 // IsDir checks if the given node is a directory.
 func IsDir(node *restic.Node) bool {
 	return node.Type == "dir"
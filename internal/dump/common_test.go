@@ -95,3 +95,14 @@ func WriteTest(t *testing.T, format string, cd CheckDump) {
 		})
 	}
 }
+
+func TestRangeWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &rangeWriter{w: &buf, skip: 3, remaining: 6}
+	for _, s := range []string{"ab", "cdef", "ghij", "kl"} {
+		n, err := w.Write([]byte(s))
+		rtest.OK(t, err)
+		rtest.Equals(t, len(s), n)
+	}
+	rtest.Equals(t, "defghi", buf.String())
+}
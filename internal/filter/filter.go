@@ -272,6 +272,42 @@ func List(patterns []Pattern, str string) (matched bool, err error) {
 	return matched, err
 }
 
+// Explain is like List, but additionally returns the index of the pattern
+// which determined the result. This is the last pattern which changed the
+// result while going through the list, either by matching str or, for a
+// negated pattern, by including it again. The index is -1 if the result was
+// not changed by any pattern.
+func Explain(patterns []Pattern, str string) (matched bool, index int, err error) {
+	index = -1
+	if len(patterns) == 0 {
+		return false, index, nil
+	}
+
+	strs, err := prepareStr(str)
+	if err != nil {
+		return false, -1, err
+	}
+
+	for i, pat := range patterns {
+		m, err := match(pat, strs)
+		if err != nil {
+			return false, -1, err
+		}
+
+		if m && matched == pat.isNegated {
+			matched = !pat.isNegated
+			index = i
+		}
+	}
+
+	return matched, index, nil
+}
+
+// String returns the pattern as it was passed to ParsePatterns.
+func (p Pattern) String() string {
+	return p.original
+}
+
 // ListWithChild returns true if str matches one of the patterns. Empty patterns are ignored.
 func ListWithChild(patterns []Pattern, str string) (matched bool, childMayMatch bool, err error) {
 	return list(patterns, true, str)
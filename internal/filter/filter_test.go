@@ -303,6 +303,45 @@ func TestList(t *testing.T) {
 			t.Errorf("test %d: filter.ListWithChild(%q, %q): expected %v, %v, got %v, %v",
 				i, test.patterns, test.path, test.match, test.childMatch, match, childMatch)
 		}
+
+		match, _, err = filter.Explain(patterns, test.path)
+		if err != nil {
+			t.Errorf("test %d failed: expected no error for patterns %q, but error returned: %v",
+				i, test.patterns, err)
+			continue
+		}
+
+		if match != test.match {
+			t.Errorf("test %d: filter.Explain(%q, %q): expected %v, got %v",
+				i, test.patterns, test.path, test.match, match)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	var tests = []struct {
+		patterns []string
+		path     string
+		match    bool
+		index    int
+	}{
+		{[]string{"*.go"}, "/foo/bar.c", false, -1},
+		{[]string{"*.c", "*.go"}, "/foo/bar.go", true, 1},
+		{[]string{"/foo", "*.go"}, "/foo/bar.go", true, 0},
+		{[]string{"/foo", "!/foo/bar.go"}, "/foo/bar.go", false, 1},
+		{[]string{"/foo", "!/foo/bar.go", "*.go"}, "/foo/bar.go", true, 2},
+		{[]string{"!*.go", "*.go"}, "/foo/bar.go", true, 1},
+	}
+
+	for i, test := range tests {
+		match, index, err := filter.Explain(filter.ParsePatterns(test.patterns), test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if match != test.match || index != test.index {
+			t.Errorf("test %d: filter.Explain(%q, %q): expected %v, %d, got %v, %d",
+				i, test.patterns, test.path, test.match, test.index, match, index)
+		}
 	}
 }
 
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Preset is a named, built-in set of exclude patterns for files which are
+// usually not worth backing up, like caches and temporary files.
+type Preset struct {
+	Name        string
+	Description string
+	Patterns    []string
+	// CaseInsensitive is set for presets matching paths on case-insensitive
+	// filesystems.
+	CaseInsensitive bool
+}
+
+var presets = []Preset{
+	{
+		Name:        "windows-system",
+		Description: "page and hibernation files, recycle bins, temporary files and update downloads of Windows",
+		Patterns: []string{
+			"pagefile.sys",
+			"hiberfil.sys",
+			"swapfile.sys",
+			"$Recycle.Bin",
+			"System Volume Information",
+			"Windows/Temp",
+			"Windows/Prefetch",
+			"Windows/SoftwareDistribution/Download",
+			"AppData/Local/Temp",
+			"AppData/Local/CrashDumps",
+			"AppData/Local/Microsoft/Windows/INetCache",
+			"AppData/Local/Microsoft/Windows/Explorer/thumbcache_*.db",
+		},
+		CaseInsensitive: true,
+	},
+	{
+		Name:        "browser-caches",
+		Description: "caches of Chrome, Chromium, Edge, Brave, Firefox and Safari",
+		Patterns: []string{
+			".cache/google-chrome",
+			".cache/chromium",
+			".cache/microsoft-edge",
+			".cache/BraveSoftware",
+			".cache/mozilla",
+			"AppData/Local/Google/Chrome/User Data/*/Cache",
+			"AppData/Local/Google/Chrome/User Data/*/Code Cache",
+			"AppData/Local/Microsoft/Edge/User Data/*/Cache",
+			"AppData/Local/Microsoft/Edge/User Data/*/Code Cache",
+			"AppData/Local/BraveSoftware/Brave-Browser/User Data/*/Cache",
+			"AppData/Local/Mozilla/Firefox/Profiles/*/cache2",
+			"Library/Caches/Google/Chrome",
+			"Library/Caches/Firefox",
+			"Library/Caches/com.apple.Safari",
+		},
+		CaseInsensitive: true,
+	},
+	{
+		Name:        "linux-var-cache",
+		Description: "package manager caches, crash dumps and temporary files below /var",
+		Patterns: []string{
+			"/var/cache",
+			"/var/tmp",
+			"/var/crash",
+			"/var/lib/apt/lists",
+			"/var/lib/systemd/coredump",
+		},
+	},
+}
+
+// Presets returns all built-in presets sorted by name.
+func Presets() []Preset {
+	list := append([]Preset(nil), presets...)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// PresetNames returns the names of all built-in presets.
+func PresetNames() []string {
+	var names []string
+	for _, p := range Presets() {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// LookupPresets returns the presets with the given names. An error is
+// returned for unknown names.
+func LookupPresets(names []string) ([]Preset, error) {
+	var result []Preset
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, p := range presets {
+			if p.Name == name {
+				result = append(result, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown exclude preset %q, available presets are %s", name, strings.Join(PresetNames(), ", "))
+		}
+	}
+	return result, nil
+}
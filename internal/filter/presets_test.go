@@ -0,0 +1,70 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/filter"
+)
+
+func TestPresetPatterns(t *testing.T) {
+	for _, p := range filter.Presets() {
+		if err := filter.ValidatePatterns(p.Patterns); err != nil {
+			t.Errorf("preset %v: %v", p.Name, err)
+		}
+	}
+}
+
+func TestLookupPresets(t *testing.T) {
+	list, err := filter.LookupPresets([]string{"linux-var-cache", " browser-caches"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "linux-var-cache" || list[1].Name != "browser-caches" {
+		t.Fatalf("unexpected presets %v", list)
+	}
+
+	_, err = filter.LookupPresets([]string{"no-such-preset"})
+	if err == nil {
+		t.Fatal("expected error for unknown preset")
+	}
+}
+
+func TestPresetMatch(t *testing.T) {
+	var tests = []struct {
+		preset string
+		path   string
+		match  bool
+	}{
+		{"linux-var-cache", "/var/cache/apt/archives/foo.deb", true},
+		{"linux-var-cache", "/home/user/var/cache", false},
+		{"browser-caches", "/home/user/.cache/mozilla/firefox", true},
+		{"browser-caches", "/Users/user/AppData/Local/Google/Chrome/User Data/Default/Cache", true},
+		{"browser-caches", "/Users/user/AppData/Local/Google/Chrome/User Data/Default/Bookmarks", false},
+		{"windows-system", "/C/pagefile.sys", true},
+		{"windows-system", "/C/Users/user/AppData/Local/Temp", true},
+		{"windows-system", "/C/Users/user/Documents", false},
+	}
+
+	for _, test := range tests {
+		list, err := filter.LookupPresets([]string{test.preset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		matched := false
+		for _, pattern := range list[0].Patterns {
+			m, err := filter.Match(pattern, test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// excluding a directory also excludes its contents
+			c, err := filter.Match(pattern+"/**", test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matched = matched || m || c
+		}
+		if matched != test.match {
+			t.Errorf("preset %v, path %v: want match %v, got %v", test.preset, test.path, test.match, matched)
+		}
+	}
+}
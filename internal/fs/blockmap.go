@@ -0,0 +1,93 @@
+package fs
+
+import (
+	"errors"
+	"sort"
+)
+
+// BlockMap lists the extents of a file together with the information the
+// file system keeps about when they were written. Comparing two block maps of
+// the same file yields the regions which were modified in between, without
+// reading the file.
+type BlockMap struct {
+	Extents []Extent `json:"extents"`
+}
+
+// Extent is a contiguous region of a file. Holes are not listed.
+type Extent struct {
+	Offset uint64 `json:"offset"`
+	Length uint64 `json:"length"`
+	// Physical and PhysicalLength describe the location of the data on disk,
+	// the extent starts at PhysicalOffset within the data.
+	Physical       uint64 `json:"physical,omitempty"`
+	PhysicalLength uint64 `json:"physical_length,omitempty"`
+	PhysicalOffset uint64 `json:"physical_offset,omitempty"`
+	// Generation is the transaction in which the extent was written.
+	Generation uint64 `json:"generation"`
+	// Flags describe the extent type and encoding.
+	Flags uint32 `json:"flags,omitempty"`
+	// Volatile is set for extents which may be modified in place, their
+	// region is always considered changed.
+	Volatile bool `json:"volatile,omitempty"`
+}
+
+// Range is the region of a file from Start up to, but not including, End.
+type Range struct {
+	Start, End uint64
+}
+
+// ErrBlockMapUnavailable is returned if the block map of a file cannot be
+// read or does not reliably reflect modifications of the file.
+var ErrBlockMapUnavailable = errors.New("block map is not available")
+
+// ChangedRanges returns the sorted and merged regions of the file in which the
+// block maps old and current differ. Extents contained in both maps are
+// unchanged, as are holes in both maps.
+func ChangedRanges(old, current *BlockMap) []Range {
+	unchanged := make(map[Extent]struct{}, len(old.Extents))
+	for _, e := range old.Extents {
+		if !e.Volatile {
+			unchanged[e] = struct{}{}
+		}
+	}
+
+	var ranges []Range
+	seen := make(map[Extent]struct{}, len(current.Extents))
+	for _, e := range current.Extents {
+		if _, ok := unchanged[e]; ok {
+			seen[e] = struct{}{}
+			continue
+		}
+		ranges = append(ranges, Range{e.Offset, e.Offset + e.Length})
+	}
+	// extents which have been removed, for example by punching a hole
+	for _, e := range old.Extents {
+		if _, ok := seen[e]; !ok {
+			ranges = append(ranges, Range{e.Offset, e.Offset + e.Length})
+		}
+	}
+
+	return mergeRanges(ranges)
+}
+
+func mergeRanges(ranges []Range) []Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start <= last.End {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
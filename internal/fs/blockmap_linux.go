@@ -0,0 +1,209 @@
+package fs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/restic/restic/internal/debug"
+	"golang.org/x/sys/unix"
+)
+
+// Definitions for BTRFS_IOC_TREE_SEARCH_V2, see include/uapi/linux/btrfs.h
+// and include/uapi/linux/btrfs_tree.h.
+const (
+	btrfsIocTreeSearchV2 = 0xc0709411
+	btrfsSearchBufSize   = 64 * 1024
+	btrfsSearchHdrSize   = 32
+
+	btrfsExtentDataKey = 108
+
+	btrfsFileExtentInline   = 0
+	btrfsFileExtentPrealloc = 2
+	btrfsFileExtentItemSize = 53
+	btrfsFileExtentHdrSize  = 21
+)
+
+type btrfsSearchKey struct {
+	TreeID      uint64
+	MinObjectID uint64
+	MaxObjectID uint64
+	MinOffset   uint64
+	MaxOffset   uint64
+	MinTransID  uint64
+	MaxTransID  uint64
+	MinType     uint32
+	MaxType     uint32
+	NrItems     uint32
+	_           uint32
+	_           [4]uint64
+}
+
+type btrfsSearchArgs struct {
+	Key     btrfsSearchKey
+	BufSize uint64
+	Buf     [btrfsSearchBufSize]byte
+}
+
+type btrfsSearchHeader struct {
+	TransID  uint64
+	ObjectID uint64
+	Offset   uint64
+	Type     uint32
+	Len      uint32
+}
+
+// genericIoctlEncoding returns false for architectures which encode the
+// direction and size of ioctl numbers differently.
+func genericIoctlEncoding() bool {
+	switch runtime.GOARCH {
+	case "mips", "mipsle", "mips64", "mips64le", "ppc", "ppc64", "ppc64le", "sparc64":
+		return false
+	}
+	return true
+}
+
+// ReadBlockMap returns the block map of the open file f described by fi. It
+// is only available for files on btrfs which are not marked as nocow: btrfs
+// writes modified data to new extents and records the generation in which
+// each extent was written. Reading the extents requires CAP_SYS_ADMIN. For
+// all other files, ErrBlockMapUnavailable is returned.
+func ReadBlockMap(f File, fi os.FileInfo) (*BlockMap, error) {
+	if !genericIoctlEncoding() {
+		return nil, ErrBlockMapUnavailable
+	}
+
+	// make sure f is the file described by fi, files which are not backed
+	// by the local file system return a fake file descriptor
+	if _, ok := fi.Sys().(*syscall.Stat_t); !ok {
+		return nil, ErrBlockMapUnavailable
+	}
+	fd := int(f.Fd())
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return nil, ErrBlockMapUnavailable
+	}
+	extFI := ExtendedStat(fi)
+	if uint64(st.Ino) != extFI.Inode || uint64(st.Dev) != extFI.DeviceID {
+		return nil, ErrBlockMapUnavailable
+	}
+
+	var stfs unix.Statfs_t
+	if err := unix.Fstatfs(fd, &stfs); err != nil || uint32(stfs.Type) != unix.BTRFS_SUPER_MAGIC {
+		return nil, ErrBlockMapUnavailable
+	}
+	// nocow files are modified in place
+	flags, err := unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+	if err != nil || flags&InodeFlagNoCOW != 0 {
+		return nil, ErrBlockMapUnavailable
+	}
+
+	// extents are only allocated once dirty pages are written back
+	if err := unix.Fsync(fd); err != nil {
+		debug.Log("fsync of %v failed: %v", f.Name(), err)
+		return nil, ErrBlockMapUnavailable
+	}
+
+	return searchBtrfsExtents(fd, uint64(st.Ino))
+}
+
+// searchBtrfsExtents returns the file extent items of the inode ino in the
+// subvolume containing fd.
+func searchBtrfsExtents(fd int, ino uint64) (*BlockMap, error) {
+	args := &btrfsSearchArgs{BufSize: btrfsSearchBufSize}
+	// tree 0 is the subvolume containing fd
+	args.Key = btrfsSearchKey{
+		MinObjectID: ino,
+		MaxObjectID: ino,
+		MaxOffset:   math.MaxUint64,
+		MaxTransID:  math.MaxUint64,
+		MinType:     btrfsExtentDataKey,
+		MaxType:     btrfsExtentDataKey,
+	}
+
+	m := &BlockMap{}
+	for {
+		args.Key.NrItems = math.MaxUint32
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), btrfsIocTreeSearchV2, uintptr(unsafe.Pointer(args)))
+		if errno != 0 {
+			debug.Log("BTRFS_IOC_TREE_SEARCH_V2 failed: %v", errno)
+			return nil, ErrBlockMapUnavailable
+		}
+		if args.Key.NrItems == 0 {
+			return m, nil
+		}
+
+		var hdr btrfsSearchHeader
+		pos := 0
+		for i := uint32(0); i < args.Key.NrItems; i++ {
+			// items are not aligned
+			copy((*[btrfsSearchHdrSize]byte)(unsafe.Pointer(&hdr))[:], args.Buf[pos:])
+			pos += btrfsSearchHdrSize
+			if pos+int(hdr.Len) > len(args.Buf) {
+				return nil, ErrBlockMapUnavailable
+			}
+			item := args.Buf[pos : pos+int(hdr.Len)]
+			pos += int(hdr.Len)
+
+			if hdr.ObjectID != ino || hdr.Type != btrfsExtentDataKey {
+				continue
+			}
+			e, ok, err := parseBtrfsFileExtent(hdr.Offset, item)
+			if err != nil {
+				debug.Log("%v", err)
+				return nil, ErrBlockMapUnavailable
+			}
+			if ok {
+				m.Extents = append(m.Extents, e)
+			}
+		}
+
+		if hdr.Offset == math.MaxUint64 {
+			return m, nil
+		}
+		args.Key.MinOffset = hdr.Offset + 1
+	}
+}
+
+// parseBtrfsFileExtent decodes a struct btrfs_file_extent_item at the file
+// offset. It returns false for holes.
+func parseBtrfsFileExtent(offset uint64, item []byte) (Extent, bool, error) {
+	if len(item) < btrfsFileExtentHdrSize {
+		return Extent{}, false, fmt.Errorf("file extent item at offset %d is truncated", offset)
+	}
+
+	le := binary.LittleEndian
+	typ := item[20]
+	e := Extent{
+		Offset:     offset,
+		Generation: le.Uint64(item[0:]),
+		// type, compression and encryption
+		Flags: uint32(typ) | uint32(item[16])<<8 | uint32(item[17])<<16,
+		// other encodings are reserved and not used by Linux
+		Volatile: le.Uint16(item[18:]) != 0,
+	}
+
+	if typ == btrfsFileExtentInline {
+		e.Length = le.Uint64(item[8:])
+		return e, true, nil
+	}
+	if len(item) < btrfsFileExtentItemSize {
+		return Extent{}, false, fmt.Errorf("file extent item at offset %d is truncated", offset)
+	}
+
+	e.Physical = le.Uint64(item[21:])
+	e.PhysicalLength = le.Uint64(item[29:])
+	e.PhysicalOffset = le.Uint64(item[37:])
+	e.Length = le.Uint64(item[45:])
+	if e.Physical == 0 {
+		// explicit hole
+		return Extent{}, false, nil
+	}
+	// data written to preallocated extents is stored in place
+	e.Volatile = e.Volatile || typ == btrfsFileExtentPrealloc
+	return e, true, nil
+}
@@ -0,0 +1,88 @@
+package fs
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseBtrfsFileExtent(t *testing.T) {
+	item := make([]byte, btrfsFileExtentItemSize)
+	le := binary.LittleEndian
+	le.PutUint64(item[0:], 42)     // generation
+	le.PutUint64(item[8:], 8192)   // ram_bytes
+	item[16] = 3                   // zstd
+	item[20] = 1                   // regular
+	le.PutUint64(item[21:], 1<<20) // disk_bytenr
+	le.PutUint64(item[29:], 4096)  // disk_num_bytes
+	le.PutUint64(item[37:], 0)     // offset
+	le.PutUint64(item[45:], 8192)  // num_bytes
+
+	e, ok, err := parseBtrfsFileExtent(65536, item)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "extent not returned")
+	rtest.Equals(t, Extent{
+		Offset:         65536,
+		Length:         8192,
+		Physical:       1 << 20,
+		PhysicalLength: 4096,
+		Generation:     42,
+		Flags:          1 | 3<<8,
+	}, e)
+
+	item[20] = btrfsFileExtentPrealloc
+	e, ok, err = parseBtrfsFileExtent(65536, item)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok && e.Volatile, "preallocated extent is not volatile")
+
+	// holes
+	item[20] = 1
+	le.PutUint64(item[21:], 0)
+	_, ok, err = parseBtrfsFileExtent(65536, item)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "hole returned as extent")
+
+	_, _, err = parseBtrfsFileExtent(65536, item[:30])
+	rtest.Assert(t, err != nil, "truncated item accepted")
+
+	// inline extents only consist of the header and the data
+	item[20] = btrfsFileExtentInline
+	e, ok, err = parseBtrfsFileExtent(0, item[:btrfsFileExtentHdrSize+10])
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "inline extent not returned")
+	rtest.Equals(t, uint64(8192), e.Length)
+}
+
+func TestReadBlockMap(t *testing.T) {
+	filename := filepath.Join(rtest.TempDir(t), "file")
+	rtest.OK(t, os.WriteFile(filename, make([]byte, 100000), 0o600))
+
+	f, err := os.Open(filename)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, f.Close())
+	}()
+	fi, err := f.Stat()
+	rtest.OK(t, err)
+
+	m, err := ReadBlockMap(f, fi)
+	if err == ErrBlockMapUnavailable {
+		t.Skip("block maps are not available for the temporary directory")
+	}
+	rtest.OK(t, err)
+	rtest.Assert(t, len(m.Extents) > 0, "no extents returned")
+
+	// an identical block map does not report changes
+	again, err := ReadBlockMap(f, fi)
+	rtest.OK(t, err)
+	rtest.Equals(t, []Range(nil), ChangedRanges(m, again))
+
+	// the block map must belong to the file
+	other, err := os.Stat(filepath.Dir(filename))
+	rtest.OK(t, err)
+	_, err = ReadBlockMap(f, other)
+	rtest.Equals(t, ErrBlockMapUnavailable, err)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package fs
+
+import "os"
+
+// ReadBlockMap is only supported on Linux.
+func ReadBlockMap(_ File, _ os.FileInfo) (*BlockMap, error) {
+	return nil, ErrBlockMapUnavailable
+}
@@ -0,0 +1,71 @@
+package fs
+
+import (
+	"fmt"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestChangedRanges(t *testing.T) {
+	a := Extent{Offset: 0, Length: 100, Physical: 1000, PhysicalLength: 100, Generation: 5}
+	b := Extent{Offset: 100, Length: 50, Physical: 2000, PhysicalLength: 50, Generation: 6}
+	c := Extent{Offset: 200, Length: 100, Physical: 3000, PhysicalLength: 100, Generation: 7}
+
+	rewritten := b
+	rewritten.Generation = 9
+	moved := c
+	moved.Physical = 4000
+	prealloc := a
+	prealloc.Volatile = true
+
+	var tests = []struct {
+		old, current []Extent
+		changed      []Range
+	}{
+		{
+			old:     []Extent{a, b, c},
+			current: []Extent{a, b, c},
+		},
+		{
+			old:     []Extent{a, b, c},
+			current: []Extent{a, rewritten, c},
+			changed: []Range{{100, 150}},
+		},
+		{
+			old:     []Extent{a, b, c},
+			current: []Extent{a, b, moved},
+			changed: []Range{{200, 300}},
+		},
+		{
+			// adjacent changes are merged
+			old:     []Extent{a, b, c},
+			current: []Extent{a, rewritten, {Offset: 150, Length: 50, Physical: 5000, Generation: 9}, c},
+			changed: []Range{{100, 200}},
+		},
+		{
+			// a hole was punched
+			old:     []Extent{a, b, c},
+			current: []Extent{a, c},
+			changed: []Range{{100, 150}},
+		},
+		{
+			// the file was appended to
+			old:     []Extent{a, b},
+			current: []Extent{a, b, c},
+			changed: []Range{{200, 300}},
+		},
+		{
+			old:     []Extent{prealloc, b},
+			current: []Extent{prealloc, b},
+			changed: []Range{{0, 100}},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			changed := ChangedRanges(&BlockMap{Extents: test.old}, &BlockMap{Extents: test.current})
+			rtest.Equals(t, test.changed, changed)
+		})
+	}
+}
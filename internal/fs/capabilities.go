@@ -0,0 +1,65 @@
+package fs
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/debug"
+)
+
+// Capabilities describes the features supported by the file system
+// containing a directory. Features which could not be determined are nil.
+type Capabilities struct {
+	// FreeSpace is the space available to the current user in bytes.
+	FreeSpace *uint64 `json:"free_space,omitempty"`
+
+	ExtendedAttributes   *bool `json:"extended_attributes,omitempty"`
+	AlternateDataStreams *bool `json:"alternate_data_streams,omitempty"`
+	Symlinks             *bool `json:"symlinks,omitempty"`
+	SparseFiles          *bool `json:"sparse_files,omitempty"`
+
+	// MaxNameLength and MaxPathLength are measured in bytes, on Windows in
+	// UTF-16 code units. They are zero if unknown.
+	MaxNameLength int `json:"max_name_length,omitempty"`
+	MaxPathLength int `json:"max_path_length,omitempty"`
+}
+
+// ProbeCapabilities determines the capabilities of the file system containing
+// the existing directory dir. Symlinks and extended attributes are tested by
+// creating a temporary file in dir, which is removed afterwards.
+func ProbeCapabilities(dir string) (*Capabilities, error) {
+	c := &Capabilities{}
+	if err := probeVolume(dir, c); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(dir, ".restic-probe-")
+	if err != nil {
+		return nil, err
+	}
+	name := f.Name()
+	defer func() {
+		_ = os.Remove(name)
+	}()
+
+	c.ExtendedAttributes = probeExtendedAttributes(f)
+	c.AlternateDataStreams = probeAlternateDataStreams(name)
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	link := name + "-symlink"
+	err = Symlink(name, link)
+	supported := err == nil
+	if supported {
+		_ = os.Remove(link)
+	} else {
+		debug.Log("creating symlink in %v failed: %v", dir, err)
+	}
+	c.Symlinks = &supported
+
+	return c, nil
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,18 @@
+//go:build !darwin && !freebsd && !linux && !windows
+// +build !darwin,!freebsd,!linux,!windows
+
+package fs
+
+import "os"
+
+func probeVolume(_ string, _ *Capabilities) error {
+	return nil
+}
+
+func probeExtendedAttributes(_ *os.File) *bool {
+	return nil
+}
+
+func probeAlternateDataStreams(_ string) *bool {
+	return nil
+}
@@ -0,0 +1,24 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestProbeCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	c, err := ProbeCapabilities(dir)
+	rtest.OK(t, err)
+	rtest.Assert(t, c.Symlinks != nil, "symlink support not determined")
+
+	// the probe must not leave any files behind
+	entries, err := os.ReadDir(dir)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(entries))
+
+	_, err = ProbeCapabilities(filepath.Join(dir, "missing"))
+	rtest.Assert(t, err != nil, "missing error for nonexistent directory")
+}
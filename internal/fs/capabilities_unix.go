@@ -0,0 +1,50 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/pkg/xattr"
+	"golang.org/x/sys/unix"
+
+	"github.com/restic/restic/internal/debug"
+)
+
+// maxNameLength is NAME_MAX, which is the same for all common file systems.
+const maxNameLength = 255
+
+func probeVolume(dir string, c *Capabilities) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return &os.PathError{Op: "statfs", Path: dir, Err: err}
+	}
+	free := uint64(st.Bavail) * uint64(st.Bsize)
+	c.FreeSpace = &free
+	c.MaxNameLength = maxNameLength
+	c.MaxPathLength = unix.PathMax
+	return nil
+}
+
+func probeExtendedAttributes(f *os.File) *bool {
+	// the user namespace is required on Linux and allowed on all other systems
+	err := xattr.FSet(f, "user.restic.probe", []byte("restic"))
+	var xerr *xattr.Error
+	if errors.As(err, &xerr) && (errors.Is(xerr.Err, syscall.ENOTSUP) || errors.Is(xerr.Err, syscall.EOPNOTSUPP)) {
+		return boolPtr(false)
+	}
+	if err != nil {
+		debug.Log("setting extended attribute on %v failed: %v", f.Name(), err)
+		return nil
+	}
+	return boolPtr(true)
+}
+
+// probeAlternateDataStreams returns nil, as streams are restored as regular
+// files.
+func probeAlternateDataStreams(_ string) *bool {
+	return nil
+}
@@ -0,0 +1,61 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/restic/restic/internal/debug"
+	"golang.org/x/sys/windows"
+)
+
+// maxPathLength is the maximum length of the extended-length paths used by
+// restic.
+const maxPathLength = 32767
+
+func probeVolume(dir string, c *Capabilities) error {
+	pathPtr, err := windows.UTF16PtrFromString(fixpath(dir))
+	if err != nil {
+		return err
+	}
+	rootBuf := make([]uint16, windows.MAX_LONG_PATH)
+	err = windows.GetVolumePathName(pathPtr, &rootBuf[0], uint32(len(rootBuf)))
+	if err != nil {
+		return fmt.Errorf("GetVolumePathName: %w", err)
+	}
+
+	var maxComponentLength, flags uint32
+	err = windows.GetVolumeInformation(&rootBuf[0], nil, 0, nil, &maxComponentLength, &flags, nil, 0)
+	if err != nil {
+		return fmt.Errorf("GetVolumeInformation: %w", err)
+	}
+	c.MaxNameLength = int(maxComponentLength)
+	c.MaxPathLength = maxPathLength
+	c.SparseFiles = boolPtr(flags&windows.FILE_SUPPORTS_SPARSE_FILES != 0)
+
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	err = windows.GetDiskFreeSpaceEx(pathPtr, &freeBytesAvailable, &totalBytes, &totalFreeBytes)
+	if err != nil {
+		return fmt.Errorf("GetDiskFreeSpaceEx: %w", err)
+	}
+	c.FreeSpace = &freeBytesAvailable
+	return nil
+}
+
+func probeExtendedAttributes(f *os.File) *bool {
+	err := SetFileEA(windows.Handle(f.Fd()), []ExtendedAttribute{{Name: "RESTIC.PROBE", Value: []byte("restic")}})
+	if err != nil {
+		debug.Log("setting extended attribute on %v failed: %v", f.Name(), err)
+		return boolPtr(false)
+	}
+	return boolPtr(true)
+}
+
+func probeAlternateDataStreams(name string) *bool {
+	f, err := os.OpenFile(fixpath(name+":restic.probe"), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		debug.Log("creating stream of %v failed: %v", name, err)
+		return boolPtr(false)
+	}
+	_ = f.Close()
+	return boolPtr(true)
+}
@@ -0,0 +1,33 @@
+package fs
+
+import "errors"
+
+// ChangeJournal is a position in the change journal of a volume, which
+// records all changes of files and directories on the volume.
+type ChangeJournal struct {
+	// Volume is the root of the volume, for example C:\.
+	Volume string `json:"volume"`
+	// ID identifies the journal instance. It changes when the journal is
+	// deleted and created again, which loses all recorded changes.
+	ID uint64 `json:"id"`
+	// USN is the update sequence number of the next change.
+	USN int64 `json:"usn"`
+}
+
+// ChangeJournalEntry describes a change read from a change journal.
+type ChangeJournalEntry struct {
+	Path  string
+	IsDir bool
+	// Added is set if the file or directory was created at Path or moved
+	// there.
+	Added bool
+}
+
+var (
+	// ErrChangeJournalUnavailable is returned if the volume has no active
+	// change journal or the file system does not support change journals.
+	ErrChangeJournalUnavailable = errors.New("change journal is not available")
+	// ErrChangeJournalIncomplete is returned if not all changes since a
+	// position are still contained in the change journal.
+	ErrChangeJournalIncomplete = errors.New("change journal does not contain all changes")
+)
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import "context"
+
+// QueryChangeJournal is only supported on Windows.
+func QueryChangeJournal(_ string) (*ChangeJournal, error) {
+	return nil, ErrChangeJournalUnavailable
+}
+
+// ReadChangeJournal is only supported on Windows.
+func ReadChangeJournal(_ context.Context, _ ChangeJournal, _ func(ChangeJournalEntry)) error {
+	return ErrChangeJournalUnavailable
+}
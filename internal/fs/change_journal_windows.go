@@ -0,0 +1,457 @@
+package fs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/restic/restic/internal/debug"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	fsctlQueryUSNJournal = 0x000900f4
+	fsctlReadUSNJournal  = 0x000900bb
+
+	usnReasonFileCreate    = 0x00000100
+	usnReasonRenameNewName = 0x00002000
+
+	// usnRecordHeaderSize is the size of a USN_RECORD_V2 without the file name.
+	usnRecordHeaderSize = 60
+
+	// usnReadBufferSize is the size of the buffer used to read records from
+	// the change journal.
+	usnReadBufferSize = 64 * 1024
+)
+
+// usnJournalData is the USN_JOURNAL_DATA_V2 structure returned by
+// FSCTL_QUERY_USN_JOURNAL.
+type usnJournalData struct {
+	UsnJournalID                uint64
+	FirstUsn                    int64
+	NextUsn                     int64
+	LowestValidUsn              int64
+	MaxUsn                      int64
+	MaximumSize                 uint64
+	AllocationDelta             uint64
+	MinSupportedMajorVersion    uint16
+	MaxSupportedMajorVersion    uint16
+	Flags                       uint32
+	RangeTrackChunkSize         uint64
+	RangeTrackFileSizeThreshold int64
+}
+
+// readUSNJournalData is the READ_USN_JOURNAL_DATA_V0 structure passed to
+// FSCTL_READ_USN_JOURNAL, which returns USN_RECORD_V2 records.
+type readUSNJournalData struct {
+	StartUsn          int64
+	ReasonMask        uint32
+	ReturnOnlyOnClose uint32
+	Timeout           uint64
+	BytesToWaitFor    uint64
+	UsnJournalID      uint64
+}
+
+// fileIDDescriptor is the FILE_ID_DESCRIPTOR structure for a 64 bit file
+// reference number, padded to the size of the union.
+type fileIDDescriptor struct {
+	Size   uint32
+	Type   uint32
+	FileID uint64
+	_      uint64
+}
+
+var (
+	procOpenFileByID       = modkernel32.NewProc("OpenFileById")
+	procFindFirstFileNameW = modkernel32.NewProc("FindFirstFileNameW")
+	procFindNextFileNameW  = modkernel32.NewProc("FindNextFileNameW")
+)
+
+// QueryChangeJournal returns the current position in the change journal of
+// the volume which contains path. ErrChangeJournalUnavailable is returned if
+// the volume has no active change journal. Reading the change journal
+// requires administrative privileges.
+//
+// The changes are reported using the final paths of files, thus path must not
+// contain short names, symbolic links or junctions.
+func QueryChangeJournal(path string) (*ChangeJournal, error) {
+	final, err := finalPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(final, filepath.Clean(path)) {
+		return nil, fmt.Errorf("%v is the final path of %v, changes would not be detected", final, path)
+	}
+
+	volume, err := volumeRoot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := openVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	data, err := queryUSNJournal(h)
+	if err != nil {
+		return nil, err
+	}
+	return &ChangeJournal{Volume: volume, ID: data.UsnJournalID, USN: data.NextUsn}, nil
+}
+
+// ReadChangeJournal calls fn for all changes recorded in the change journal
+// since the position j. Each change of a file with several hard links is
+// reported for all of its paths. ErrChangeJournalIncomplete is returned if the
+// journal was recreated or the changes since j were already purged from it.
+func ReadChangeJournal(ctx context.Context, j ChangeJournal, fn func(ChangeJournalEntry)) error {
+	h, err := openVolume(j.Volume)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	data, err := queryUSNJournal(h)
+	if err != nil {
+		return err
+	}
+	if data.UsnJournalID != j.ID || j.USN < data.FirstUsn {
+		debug.Log("journal of %v: want id %x, usn %v, have id %x, first usn %v", j.Volume, j.ID, j.USN, data.UsnJournalID, data.FirstUsn)
+		return ErrChangeJournalIncomplete
+	}
+
+	r := &journalReader{
+		volume: j.Volume,
+		handle: h,
+		dirs:   make(map[uint64]string),
+		seen:   make(map[string]struct{}),
+		links:  make(map[uint64]struct{}),
+		report: fn,
+	}
+
+	in := readUSNJournalData{
+		StartUsn:     j.USN,
+		ReasonMask:   0xffffffff,
+		UsnJournalID: j.ID,
+	}
+	buf := make([]byte, usnReadBufferSize)
+	for in.StartUsn < data.NextUsn {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var n uint32
+		err := windows.DeviceIoControl(h, fsctlReadUSNJournal, (*byte)(unsafe.Pointer(&in)), uint32(unsafe.Sizeof(in)),
+			&buf[0], uint32(len(buf)), &n, nil)
+		if errors.Is(err, windows.ERROR_JOURNAL_ENTRY_DELETED) {
+			return ErrChangeJournalIncomplete
+		}
+		if err != nil {
+			return fmt.Errorf("FSCTL_READ_USN_JOURNAL: %w", err)
+		}
+		if n < 8 {
+			break
+		}
+
+		next := int64(binary.LittleEndian.Uint64(buf))
+		for rec := buf[8:n]; len(rec) >= usnRecordHeaderSize; {
+			length := binary.LittleEndian.Uint32(rec)
+			if length < usnRecordHeaderSize || int(length) > len(rec) {
+				return fmt.Errorf("invalid USN record length %d", length)
+			}
+			if err := r.record(rec[:length]); err != nil {
+				return err
+			}
+			rec = rec[length:]
+		}
+
+		if next <= in.StartUsn {
+			break
+		}
+		in.StartUsn = next
+	}
+	return nil
+}
+
+// journalReader resolves the records of a change journal to paths.
+type journalReader struct {
+	volume string
+	handle windows.Handle
+	// dirs caches the paths of directories by file reference number
+	dirs map[uint64]string
+	// seen contains the paths which were already reported
+	seen map[string]struct{}
+	// links contains the files whose hard links were already reported
+	links  map[uint64]struct{}
+	report func(ChangeJournalEntry)
+}
+
+func (r *journalReader) record(rec []byte) error {
+	major := binary.LittleEndian.Uint16(rec[4:])
+	if major != 2 {
+		return fmt.Errorf("unsupported USN record version %d", major)
+	}
+	frn := binary.LittleEndian.Uint64(rec[8:])
+	parentFRN := binary.LittleEndian.Uint64(rec[16:])
+	reason := binary.LittleEndian.Uint32(rec[40:])
+	attrs := binary.LittleEndian.Uint32(rec[52:])
+	nameLength := int(binary.LittleEndian.Uint16(rec[56:]))
+	nameOffset := int(binary.LittleEndian.Uint16(rec[58:]))
+	if nameOffset+nameLength > len(rec) {
+		return fmt.Errorf("invalid USN record name at %d", nameOffset)
+	}
+	name := make([]uint16, nameLength/2)
+	for i := range name {
+		name[i] = binary.LittleEndian.Uint16(rec[nameOffset+2*i:])
+	}
+
+	parent, err := r.resolve(parentFRN)
+	if err != nil {
+		return err
+	}
+	if parent == "" {
+		// the parent directory was deleted, which is recorded separately
+		return nil
+	}
+
+	entry := ChangeJournalEntry{
+		Path:  filepath.Join(parent, windows.UTF16ToString(name)),
+		IsDir: attrs&windows.FILE_ATTRIBUTE_DIRECTORY != 0,
+		Added: reason&(usnReasonFileCreate|usnReasonRenameNewName) != 0,
+	}
+	r.emit(entry)
+
+	if !entry.IsDir {
+		return r.emitLinks(frn)
+	}
+	return nil
+}
+
+func (r *journalReader) emit(entry ChangeJournalEntry) {
+	key := entry.Path
+	if entry.Added {
+		key = "+" + key
+	}
+	if _, ok := r.seen[key]; ok {
+		return
+	}
+	r.seen[key] = struct{}{}
+	r.report(entry)
+}
+
+// resolve returns the current path of the directory with the file reference
+// number frn, or an empty string if it does not exist anymore.
+func (r *journalReader) resolve(frn uint64) (string, error) {
+	if path, ok := r.dirs[frn]; ok {
+		return path, nil
+	}
+
+	h, err := r.openByID(frn)
+	if err != nil {
+		return "", err
+	}
+	if h == windows.InvalidHandle {
+		r.dirs[frn] = ""
+		return "", nil
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	path, err := handlePath(h)
+	if err != nil {
+		return "", err
+	}
+	r.dirs[frn] = path
+	return path, nil
+}
+
+// emitLinks reports all paths of the file with the file reference number frn
+// if it has more than one hard link. The change of such a file is only
+// recorded for one of them.
+func (r *journalReader) emitLinks(frn uint64) error {
+	if _, ok := r.links[frn]; ok {
+		return nil
+	}
+	r.links[frn] = struct{}{}
+
+	h, err := r.openByID(frn)
+	if err != nil || h == windows.InvalidHandle {
+		return err
+	}
+	var info windows.ByHandleFileInformation
+	err = windows.GetFileInformationByHandle(h, &info)
+	if err != nil {
+		_ = windows.CloseHandle(h)
+		return fmt.Errorf("GetFileInformationByHandle: %w", err)
+	}
+	if info.NumberOfLinks < 2 {
+		_ = windows.CloseHandle(h)
+		return nil
+	}
+
+	path, err := handlePath(h)
+	_ = windows.CloseHandle(h)
+	if err != nil {
+		return err
+	}
+
+	links, err := hardLinks(path)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		// the names are relative to the root of the volume
+		r.emit(ChangeJournalEntry{Path: filepath.Join(r.volume, link)})
+	}
+	return nil
+}
+
+// openByID opens the file or directory with the file reference number frn
+// without requesting any access rights. If it does not exist anymore,
+// windows.InvalidHandle is returned.
+func (r *journalReader) openByID(frn uint64) (windows.Handle, error) {
+	desc := fileIDDescriptor{Type: 0, FileID: frn}
+	desc.Size = uint32(unsafe.Sizeof(desc))
+	h, _, err := syscall.SyscallN(procOpenFileByID.Addr(), uintptr(r.handle), uintptr(unsafe.Pointer(&desc)), 0,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, 0,
+		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OPEN_REPARSE_POINT)
+	if windows.Handle(h) != windows.InvalidHandle {
+		return windows.Handle(h), nil
+	}
+	if errors.Is(err, windows.ERROR_INVALID_PARAMETER) || errors.Is(err, windows.ERROR_FILE_NOT_FOUND) ||
+		errors.Is(err, windows.ERROR_PATH_NOT_FOUND) {
+		return windows.InvalidHandle, nil
+	}
+	return windows.InvalidHandle, fmt.Errorf("OpenFileById: %w", err)
+}
+
+// hardLinks returns the names of all hard links of the file at path, relative
+// to the root of its volume.
+func hardLinks(path string) ([]string, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	h, _, err := syscall.SyscallN(procFindFirstFileNameW.Addr(), uintptr(unsafe.Pointer(pathPtr)), 0,
+		uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&buf[0])))
+	if windows.Handle(h) == windows.InvalidHandle {
+		return nil, fmt.Errorf("FindFirstFileNameW: %w", err)
+	}
+	defer func() {
+		_ = windows.FindClose(windows.Handle(h))
+	}()
+
+	var links []string
+	for {
+		links = append(links, windows.UTF16ToString(buf[:size]))
+
+		size = uint32(len(buf))
+		r1, _, err := syscall.SyscallN(procFindNextFileNameW.Addr(), h,
+			uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&buf[0])))
+		if r1 == 0 {
+			if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+				return links, nil
+			}
+			return nil, fmt.Errorf("FindNextFileNameW: %w", err)
+		}
+	}
+}
+
+// finalPath returns the path of the file or directory at path after resolving
+// all short names, symbolic links and junctions.
+func finalPath(path string) (string, error) {
+	pathPtr, err := windows.UTF16PtrFromString(fixpath(path))
+	if err != nil {
+		return "", err
+	}
+	h, err := windows.CreateFile(pathPtr, 0,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return "", fmt.Errorf("CreateFile: %w", err)
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+	return handlePath(h)
+}
+
+// handlePath returns the final path of the open file h.
+func handlePath(h windows.Handle) (string, error) {
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	n, err := windows.GetFinalPathNameByHandle(h, &buf[0], uint32(len(buf)), 0)
+	if err != nil {
+		return "", fmt.Errorf("GetFinalPathNameByHandle: %w", err)
+	}
+	return strings.TrimPrefix(windows.UTF16ToString(buf[:n]), `\\?\`), nil
+}
+
+// volumeRoot returns the root of the volume containing path, for example C:\.
+func volumeRoot(path string) (string, error) {
+	pathPtr, err := windows.UTF16PtrFromString(fixpath(path))
+	if err != nil {
+		return "", err
+	}
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	err = windows.GetVolumePathName(pathPtr, &buf[0], uint32(len(buf)))
+	if err != nil {
+		return "", fmt.Errorf("GetVolumePathName: %w", err)
+	}
+	return strings.TrimPrefix(windows.UTF16ToString(buf), `\\?\`), nil
+}
+
+// openVolume opens the volume mounted at root, for example C:\.
+func openVolume(root string) (windows.Handle, error) {
+	rootPtr, err := windows.UTF16PtrFromString(root)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	buf := make([]uint16, windows.MAX_PATH)
+	err = windows.GetVolumeNameForVolumeMountPoint(rootPtr, &buf[0], uint32(len(buf)))
+	if err != nil {
+		return windows.InvalidHandle, fmt.Errorf("GetVolumeNameForVolumeMountPoint: %w", err)
+	}
+
+	// the trailing backslash would open the root directory instead
+	name := strings.TrimSuffix(windows.UTF16ToString(buf), `\`)
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	h, err := windows.CreateFile(namePtr, windows.GENERIC_READ,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return windows.InvalidHandle, fmt.Errorf("CreateFile %v: %w", name, err)
+	}
+	return h, nil
+}
+
+func queryUSNJournal(h windows.Handle) (*usnJournalData, error) {
+	var data usnJournalData
+	var n uint32
+	err := windows.DeviceIoControl(h, fsctlQueryUSNJournal, nil, 0,
+		(*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), &n, nil)
+	switch {
+	case errors.Is(err, windows.ERROR_JOURNAL_NOT_ACTIVE),
+		errors.Is(err, windows.ERROR_JOURNAL_DELETE_IN_PROGRESS),
+		errors.Is(err, windows.ERROR_INVALID_FUNCTION):
+		return nil, ErrChangeJournalUnavailable
+	case err != nil:
+		return nil, fmt.Errorf("FSCTL_QUERY_USN_JOURNAL: %w", err)
+	}
+	return &data, nil
+}
@@ -0,0 +1,51 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sys/windows"
+)
+
+func TestChangeJournal(t *testing.T) {
+	// the temporary directory may contain short names
+	dir, err := finalPath(t.TempDir())
+	rtest.OK(t, err)
+	j, err := QueryChangeJournal(dir)
+	if errors.Is(err, ErrChangeJournalUnavailable) || errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		t.Skipf("change journal not available: %v", err)
+	}
+	rtest.OK(t, err)
+
+	sub := filepath.Join(dir, "sub")
+	rtest.OK(t, os.Mkdir(sub, 0700))
+	file := filepath.Join(sub, "file")
+	rtest.OK(t, os.WriteFile(file, []byte("foo"), 0600))
+	link := filepath.Join(dir, "link")
+	rtest.OK(t, os.Link(file, link))
+	// modify the file using the other path
+	rtest.OK(t, os.WriteFile(link, []byte("bar"), 0600))
+
+	entries := make(map[string]ChangeJournalEntry)
+	rtest.OK(t, ReadChangeJournal(context.TODO(), *j, func(entry ChangeJournalEntry) {
+		entries[strings.ToLower(entry.Path)] = entry
+	}))
+
+	entry, ok := entries[strings.ToLower(sub)]
+	rtest.Assert(t, ok, "creation of %v not reported, entries %v", sub, entries)
+	rtest.Assert(t, entry.IsDir && entry.Added, "wrong entry for directory: %+v", entry)
+	for _, path := range []string{file, link} {
+		_, ok := entries[strings.ToLower(path)]
+		rtest.Assert(t, ok, "change of %v not reported, entries %v", path, entries)
+	}
+
+	// a journal with a different ID cannot be used
+	j.ID++
+	err = ReadChangeJournal(context.TODO(), *j, func(ChangeJournalEntry) {})
+	rtest.Assert(t, errors.Is(err, ErrChangeJournalIncomplete), "unexpected error %v", err)
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import "github.com/restic/restic/internal/errors"
+
+var errNoCluster = errors.New("failover clusters are only supported on Windows")
+
+// LocalCluster returns the name of the failover cluster the local computer is
+// a member of. This is only supported on Windows.
+func LocalCluster() (cluster, node string, err error) {
+	return "", "", errNoCluster
+}
+
+// ClusterNodeDown reports whether the node of the local failover cluster is
+// down. This is only supported on Windows.
+func ClusterNodeDown(_ string) (bool, error) {
+	return false, errNoCluster
+}
+
+// ClusterResourceOwner returns the node of the local failover cluster which
+// currently owns the cluster resource. This is only supported on Windows.
+func ClusterResourceOwner(_ string) (string, error) {
+	return "", errNoCluster
+}
@@ -0,0 +1,124 @@
+//go:build windows
+// +build windows
+
+package fs
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	clusterNodeDown = 1
+
+	clusterResourceStateUnknown = ^uintptr(0)
+)
+
+var (
+	modclusapi                  = windows.NewLazySystemDLL("clusapi.dll")
+	procOpenCluster             = modclusapi.NewProc("OpenCluster")
+	procCloseCluster            = modclusapi.NewProc("CloseCluster")
+	procGetClusterInformation   = modclusapi.NewProc("GetClusterInformation")
+	procOpenClusterNode         = modclusapi.NewProc("OpenClusterNode")
+	procCloseClusterNode        = modclusapi.NewProc("CloseClusterNode")
+	procGetClusterNodeState     = modclusapi.NewProc("GetClusterNodeState")
+	procOpenClusterResource     = modclusapi.NewProc("OpenClusterResource")
+	procCloseClusterResource    = modclusapi.NewProc("CloseClusterResource")
+	procGetClusterResourceState = modclusapi.NewProc("GetClusterResourceState")
+)
+
+// openLocalCluster opens the failover cluster the local computer is a member
+// of. The returned handle must be closed using CloseCluster.
+func openLocalCluster() (uintptr, error) {
+	if err := procOpenCluster.Find(); err != nil {
+		return 0, fmt.Errorf("failover clustering is not available: %w", err)
+	}
+	h, _, err := syscall.SyscallN(procOpenCluster.Addr(), 0)
+	if h == 0 {
+		return 0, fmt.Errorf("opening the local failover cluster failed: %w", err)
+	}
+	return h, nil
+}
+
+// LocalCluster returns the name of the failover cluster the local computer is
+// a member of, and the name of the local cluster node.
+func LocalCluster() (cluster, node string, err error) {
+	h, err := openLocalCluster()
+	if err != nil {
+		return "", "", err
+	}
+	defer syscall.SyscallN(procCloseCluster.Addr(), h)
+
+	buf := make([]uint16, 256)
+	size := uint32(len(buf))
+	r1, _, _ := syscall.SyscallN(procGetClusterInformation.Addr(), h, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0)
+	if r1 == uintptr(windows.ERROR_MORE_DATA) {
+		buf = make([]uint16, size+1)
+		size = uint32(len(buf))
+		r1, _, _ = syscall.SyscallN(procGetClusterInformation.Addr(), h, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0)
+	}
+	if r1 != 0 {
+		return "", "", fmt.Errorf("querying the cluster name failed: %w", syscall.Errno(r1))
+	}
+
+	// cluster nodes are identified by their NetBIOS name
+	node, err = windows.ComputerName()
+	if err != nil {
+		return "", "", err
+	}
+	return windows.UTF16ToString(buf), node, nil
+}
+
+// ClusterNodeDown reports whether the node of the local failover cluster is
+// down. Nodes which are paused or joining the cluster are not down.
+func ClusterNodeDown(node string) (bool, error) {
+	h, err := openLocalCluster()
+	if err != nil {
+		return false, err
+	}
+	defer syscall.SyscallN(procCloseCluster.Addr(), h)
+
+	name, err := windows.UTF16PtrFromString(node)
+	if err != nil {
+		return false, err
+	}
+	hNode, _, err := syscall.SyscallN(procOpenClusterNode.Addr(), h, uintptr(unsafe.Pointer(name)))
+	if hNode == 0 {
+		return false, fmt.Errorf("opening cluster node %v failed: %w", node, err)
+	}
+	defer syscall.SyscallN(procCloseClusterNode.Addr(), hNode)
+
+	state, _, _ := syscall.SyscallN(procGetClusterNodeState.Addr(), hNode)
+	return state == clusterNodeDown, nil
+}
+
+// ClusterResourceOwner returns the node of the local failover cluster which
+// currently owns the cluster resource.
+func ClusterResourceOwner(resource string) (string, error) {
+	h, err := openLocalCluster()
+	if err != nil {
+		return "", err
+	}
+	defer syscall.SyscallN(procCloseCluster.Addr(), h)
+
+	name, err := windows.UTF16PtrFromString(resource)
+	if err != nil {
+		return "", err
+	}
+	hResource, _, err := syscall.SyscallN(procOpenClusterResource.Addr(), h, uintptr(unsafe.Pointer(name)))
+	if hResource == 0 {
+		return "", fmt.Errorf("opening cluster resource %v failed: %w", resource, err)
+	}
+	defer syscall.SyscallN(procCloseClusterResource.Addr(), hResource)
+
+	buf := make([]uint16, 256)
+	size := uint32(len(buf))
+	state, _, err := syscall.SyscallN(procGetClusterResourceState.Addr(), hResource, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0, 0)
+	if state == clusterResourceStateUnknown {
+		return "", fmt.Errorf("querying the state of cluster resource %v failed: %w", resource, err)
+	}
+	return windows.UTF16ToString(buf), nil
+}
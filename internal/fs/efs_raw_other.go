@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import (
+	"io"
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// IsEncrypted reports whether the file described by fi is encrypted using EFS,
+// which only exists on Windows.
+func IsEncrypted(_ os.FileInfo) bool {
+	return false
+}
+
+// OpenEncryptedRaw is only supported on Windows.
+func OpenEncryptedRaw(_ File) (File, error) {
+	return nil, errors.New("raw EFS streams are only supported on Windows")
+}
+
+// WriteEncryptedRaw is only supported on Windows.
+func WriteEncryptedRaw(_ string, _ io.Reader) error {
+	return errors.New("raw EFS streams are only supported on Windows")
+}
@@ -0,0 +1,203 @@
+//go:build windows
+// +build windows
+
+package fs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// createForImport is the CREATE_FOR_IMPORT flag of OpenEncryptedFileRaw.
+const createForImport = 0x1
+
+var (
+	procOpenEncryptedFileRawW = modAdvapi32.NewProc("OpenEncryptedFileRawW")
+	procReadEncryptedFileRaw  = modAdvapi32.NewProc("ReadEncryptedFileRaw")
+	procWriteEncryptedFileRaw = modAdvapi32.NewProc("WriteEncryptedFileRaw")
+	procCloseEncryptedFileRaw = modAdvapi32.NewProc("CloseEncryptedFileRaw")
+)
+
+// The callbacks for ReadEncryptedFileRaw and WriteEncryptedFileRaw cannot be
+// released once created, so there is only one callback for each direction. The
+// callback context passed to them is the key of the stream in rawStreams.
+var (
+	exportRawCallback = windows.NewCallback(exportRaw)
+	importRawCallback = windows.NewCallback(importRaw)
+
+	rawStreamsMu sync.Mutex
+	rawStreams   = make(map[uintptr]*rawStream)
+	rawStreamID  uintptr
+)
+
+// rawStream is the source or destination of a raw encrypted backup stream.
+type rawStream struct {
+	w   io.Writer
+	r   io.Reader
+	err error
+}
+
+func registerRawStream(s *rawStream) uintptr {
+	rawStreamsMu.Lock()
+	defer rawStreamsMu.Unlock()
+	rawStreamID++
+	rawStreams[rawStreamID] = s
+	return rawStreamID
+}
+
+func unregisterRawStream(id uintptr) {
+	rawStreamsMu.Lock()
+	defer rawStreamsMu.Unlock()
+	delete(rawStreams, id)
+}
+
+func lookupRawStream(id uintptr) *rawStream {
+	rawStreamsMu.Lock()
+	defer rawStreamsMu.Unlock()
+	return rawStreams[id]
+}
+
+// exportRaw is the PFE_EXPORT_FUNC callback which receives the data read by
+// ReadEncryptedFileRaw.
+func exportRaw(data *byte, id uintptr, length uint32) uintptr {
+	s := lookupRawStream(id)
+	if s == nil {
+		return uintptr(windows.ERROR_INVALID_PARAMETER)
+	}
+	if length == 0 {
+		return 0
+	}
+	if _, err := s.w.Write(unsafe.Slice(data, length)); err != nil {
+		s.err = err
+		return uintptr(windows.ERROR_CANCELLED)
+	}
+	return 0
+}
+
+// importRaw is the PFE_IMPORT_FUNC callback which provides the data written by
+// WriteEncryptedFileRaw. Setting length to zero signals the end of the data.
+func importRaw(data *byte, id uintptr, length *uint32) uintptr {
+	s := lookupRawStream(id)
+	if s == nil {
+		return uintptr(windows.ERROR_INVALID_PARAMETER)
+	}
+	n, err := io.ReadFull(s.r, unsafe.Slice(data, *length))
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		s.err = err
+		return uintptr(windows.ERROR_READ_FAULT)
+	}
+	*length = uint32(n)
+	return 0
+}
+
+func openEncryptedFileRaw(path string, flags uint32) (uintptr, error) {
+	p, err := windows.UTF16PtrFromString(fixpath(path))
+	if err != nil {
+		return 0, err
+	}
+	var ctx uintptr
+	ret, _, _ := procOpenEncryptedFileRawW.Call(uintptr(unsafe.Pointer(p)), uintptr(flags), uintptr(unsafe.Pointer(&ctx)))
+	if ret != 0 {
+		return 0, &os.PathError{Op: "OpenEncryptedFileRaw", Path: path, Err: syscall.Errno(ret)}
+	}
+	return ctx, nil
+}
+
+func closeEncryptedFileRaw(ctx uintptr) {
+	_, _, _ = procCloseEncryptedFileRaw.Call(ctx)
+}
+
+// IsEncrypted reports whether the file described by fi is encrypted using EFS.
+func IsEncrypted(fi os.FileInfo) bool {
+	attrs, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&windows.FILE_ATTRIBUTE_ENCRYPTED != 0
+}
+
+// efsRawFile reads the raw encrypted backup stream of an EFS-encrypted file.
+// All other methods are passed to the file opened for reading the metadata.
+type efsRawFile struct {
+	File
+	rd   *io.PipeReader
+	done chan error
+}
+
+// OpenEncryptedRaw returns a file which reads the raw encrypted backup stream
+// of the EFS-encrypted file f instead of the decrypted content. Reading the
+// stream does not require the key of the file. Closing the returned file
+// also closes f.
+func OpenEncryptedRaw(f File) (File, error) {
+	ctx, err := openEncryptedFileRaw(f.Name(), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, wr := io.Pipe()
+	raw := &efsRawFile{File: f, rd: rd, done: make(chan error, 1)}
+	go func() {
+		s := &rawStream{w: wr}
+		id := registerRawStream(s)
+		ret, _, _ := procReadEncryptedFileRaw.Call(exportRawCallback, id, ctx)
+		unregisterRawStream(id)
+		closeEncryptedFileRaw(ctx)
+
+		var err error
+		switch {
+		case s.err != nil:
+			err = s.err
+		case ret != 0:
+			err = &os.PathError{Op: "ReadEncryptedFileRaw", Path: f.Name(), Err: syscall.Errno(ret)}
+		}
+		_ = wr.CloseWithError(err)
+		raw.done <- err
+	}()
+	return raw, nil
+}
+
+func (f *efsRawFile) Read(p []byte) (int, error) {
+	return f.rd.Read(p)
+}
+
+// Seek is not supported for the backup stream.
+func (f *efsRawFile) Seek(_ int64, _ int) (int64, error) {
+	return 0, fmt.Errorf("seek is not supported for the raw stream of %v", f.Name())
+}
+
+func (f *efsRawFile) Close() error {
+	// stops ReadEncryptedFileRaw if the stream was not read completely
+	_ = f.rd.Close()
+	<-f.done
+	return f.File.Close()
+}
+
+// WriteEncryptedRaw creates the EFS-encrypted file at path from the raw
+// encrypted backup stream read from rd, as returned by OpenEncryptedRaw. An
+// existing file at path is overwritten.
+func WriteEncryptedRaw(path string, rd io.Reader) error {
+	ctx, err := openEncryptedFileRaw(path, createForImport)
+	if err != nil {
+		return err
+	}
+	defer closeEncryptedFileRaw(ctx)
+
+	s := &rawStream{r: rd}
+	id := registerRawStream(s)
+	defer unregisterRawStream(id)
+
+	ret, _, _ := procWriteEncryptedFileRaw.Call(importRawCallback, id, ctx)
+	if s.err != nil {
+		return s.err
+	}
+	if ret != 0 {
+		return &os.PathError{Op: "WriteEncryptedFileRaw", Path: path, Err: syscall.Errno(ret)}
+	}
+	return nil
+}
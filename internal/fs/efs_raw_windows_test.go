@@ -0,0 +1,73 @@
+//go:build windows
+// +build windows
+
+package fs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sys/windows"
+)
+
+func TestEncryptedRawRoundtrip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	data := rtest.Random(23, 300*1024)
+	rtest.OK(t, os.WriteFile(src, data, 0600))
+
+	p, err := windows.UTF16PtrFromString(src)
+	rtest.OK(t, err)
+	ret, _, err := modAdvapi32.NewProc("EncryptFileW").Call(uintptr(unsafe.Pointer(p)))
+	if ret == 0 {
+		t.Skipf("unable to encrypt test file: %v", err)
+	}
+
+	fi, err := os.Stat(src)
+	rtest.OK(t, err)
+	rtest.Assert(t, IsEncrypted(fi), "file is not marked as encrypted")
+
+	f, err := Local{}.OpenFile(src, O_RDONLY, 0)
+	rtest.OK(t, err)
+	raw, err := OpenEncryptedRaw(f)
+	rtest.OK(t, err)
+	stream, err := io.ReadAll(raw)
+	rtest.OK(t, err)
+	rtest.OK(t, raw.Close())
+	rtest.Assert(t, !bytes.Contains(stream, data[:1024]), "raw stream contains the decrypted data")
+
+	dst := filepath.Join(dir, "dst")
+	rtest.OK(t, WriteEncryptedRaw(dst, bytes.NewReader(stream)))
+
+	fi, err = os.Stat(dst)
+	rtest.OK(t, err)
+	rtest.Assert(t, IsEncrypted(fi), "imported file is not marked as encrypted")
+	restored, err := os.ReadFile(dst)
+	rtest.OK(t, err)
+	rtest.Assert(t, bytes.Equal(data, restored), "imported file has wrong content")
+}
+
+func TestEncryptedRawCloseEarly(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	rtest.OK(t, os.WriteFile(src, rtest.Random(42, 1024*1024), 0600))
+
+	p, err := windows.UTF16PtrFromString(src)
+	rtest.OK(t, err)
+	ret, _, err := modAdvapi32.NewProc("EncryptFileW").Call(uintptr(unsafe.Pointer(p)))
+	if ret == 0 {
+		t.Skipf("unable to encrypt test file: %v", err)
+	}
+
+	f, err := Local{}.OpenFile(src, O_RDONLY, 0)
+	rtest.OK(t, err)
+	raw, err := OpenEncryptedRaw(f)
+	rtest.OK(t, err)
+	_, err = raw.Read(make([]byte, 10))
+	rtest.OK(t, err)
+	rtest.OK(t, raw.Close())
+}
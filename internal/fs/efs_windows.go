@@ -0,0 +1,140 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// EFSCertificate identifies a certificate whose private key can decrypt the
+// file encryption key of an EFS-encrypted file.
+type EFSCertificate struct {
+	// SID of the user the certificate belongs to, if known.
+	SID string `json:"sid,omitempty"`
+	// Hash is the SHA-1 thumbprint of the certificate.
+	Hash []byte `json:"hash"`
+	// DisplayName describes the certificate, usually the user principal name.
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// EFSMetadata lists the certificates which can decrypt an EFS-encrypted file.
+type EFSMetadata struct {
+	Users          []EFSCertificate `json:"users,omitempty"`
+	RecoveryAgents []EFSCertificate `json:"recovery_agents,omitempty"`
+}
+
+var (
+	modAdvapi32                            = windows.NewLazySystemDLL("advapi32.dll")
+	procQueryUsersOnEncryptedFile          = modAdvapi32.NewProc("QueryUsersOnEncryptedFile")
+	procQueryRecoveryAgentsOnEncryptedFile = modAdvapi32.NewProc("QueryRecoveryAgentsOnEncryptedFile")
+	procFreeEncryptionCertificateHashList  = modAdvapi32.NewProc("FreeEncryptionCertificateHashList")
+)
+
+// encryptionCertificateHashList is ENCRYPTION_CERTIFICATE_HASH_LIST.
+type encryptionCertificateHashList struct {
+	count uint32
+	users **encryptionCertificateHash
+}
+
+// encryptionCertificateHash is ENCRYPTION_CERTIFICATE_HASH.
+type encryptionCertificateHash struct {
+	totalLength        uint32
+	userSid            *windows.SID
+	hash               *efsHashBlob
+	displayInformation *uint16
+}
+
+// efsHashBlob is EFS_HASH_BLOB.
+type efsHashBlob struct {
+	size uint32
+	data *byte
+}
+
+// GetEFSMetadata returns the certificates of the users and recovery agents
+// which can decrypt the EFS-encrypted file at path.
+func GetEFSMetadata(path string) (*EFSMetadata, error) {
+	p, err := windows.UTF16PtrFromString(fixpath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := queryEncryptionCertificates(procQueryUsersOnEncryptedFile, p)
+	if err != nil {
+		return nil, fmt.Errorf("QueryUsersOnEncryptedFile: %w", err)
+	}
+	agents, err := queryEncryptionCertificates(procQueryRecoveryAgentsOnEncryptedFile, p)
+	if err != nil {
+		return nil, fmt.Errorf("QueryRecoveryAgentsOnEncryptedFile: %w", err)
+	}
+	return &EFSMetadata{Users: users, RecoveryAgents: agents}, nil
+}
+
+func queryEncryptionCertificates(proc *windows.LazyProc, path *uint16) ([]EFSCertificate, error) {
+	var list *encryptionCertificateHashList
+	ret, _, _ := proc.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&list)))
+	if ret != 0 {
+		return nil, syscall.Errno(ret)
+	}
+	if list == nil {
+		return nil, nil
+	}
+	defer func() {
+		_, _, _ = procFreeEncryptionCertificateHashList.Call(uintptr(unsafe.Pointer(list)))
+	}()
+
+	if list.count == 0 {
+		return nil, nil
+	}
+
+	var certs []EFSCertificate
+	for _, entry := range unsafe.Slice(list.users, list.count) {
+		if entry == nil || entry.hash == nil {
+			continue
+		}
+		cert := EFSCertificate{
+			Hash: append([]byte(nil), unsafe.Slice(entry.hash.data, entry.hash.size)...),
+		}
+		if entry.userSid != nil {
+			cert.SID = entry.userSid.String()
+		}
+		if entry.displayInformation != nil {
+			cert.DisplayName = windows.UTF16PtrToString(entry.displayInformation)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// HasEFSCertificate reports whether the personal certificate store of the
+// current user contains one of the certificates.
+func HasEFSCertificate(certs []EFSCertificate) (bool, error) {
+	store, err := windows.CertOpenSystemStore(0, windows.StringToUTF16Ptr("MY"))
+	if err != nil {
+		return false, fmt.Errorf("CertOpenSystemStore: %w", err)
+	}
+	defer func() {
+		_ = windows.CertCloseStore(store, 0)
+	}()
+
+	for _, cert := range certs {
+		if len(cert.Hash) == 0 {
+			continue
+		}
+		blob := windows.CryptHashBlob{Size: uint32(len(cert.Hash)), Data: &cert.Hash[0]}
+		ctx, err := windows.CertFindCertificateInStore(store,
+			windows.X509_ASN_ENCODING|windows.PKCS_7_ASN_ENCODING, 0,
+			windows.CERT_FIND_SHA1_HASH, unsafe.Pointer(&blob), nil)
+		if errors.Is(err, windows.Errno(windows.CRYPT_E_NOT_FOUND)) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("CertFindCertificateInStore: %w", err)
+		}
+		_ = windows.CertFreeCertificateContext(ctx)
+		return true, nil
+	}
+	return false, nil
+}
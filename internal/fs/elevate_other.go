@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import (
+	"io"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// RunElevated starts the current executable with administrative privileges.
+// This is only supported on Windows.
+func RunElevated(_ func(pipe string) []string) (io.ReadWriteCloser, error) {
+	return nil, errors.New("running an elevated helper is only supported on Windows")
+}
@@ -0,0 +1,76 @@
+//go:build windows
+// +build windows
+
+package fs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// RunElevated starts the current executable with administrative privileges,
+// for which Windows asks the user for consent. The process is started with the
+// arguments returned by args for the name of a named pipe, to which it must
+// connect using os.OpenFile. The returned connection to the process is closed
+// to stop it.
+func RunElevated(args func(pipe string) []string) (io.ReadWriteCloser, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return nil, err
+	}
+	name := `\\.\pipe\restic-elevated-` + hex.EncodeToString(buf[:])
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// only a single local client may connect, which is the elevated process
+	pipe, err := windows.CreateNamedPipe(namePtr,
+		windows.PIPE_ACCESS_DUPLEX|windows.FILE_FLAG_FIRST_PIPE_INSTANCE,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		1, 64*1024, 64*1024, 0, nil)
+	if err != nil {
+		return nil, fmt.Errorf("CreateNamedPipe: %w", err)
+	}
+
+	quoted := make([]string, 0)
+	for _, arg := range args(name) {
+		quoted = append(quoted, syscall.EscapeArg(arg))
+	}
+	verbPtr, _ := windows.UTF16PtrFromString("runas")
+	exePtr, err := windows.UTF16PtrFromString(exe)
+	if err != nil {
+		_ = windows.CloseHandle(pipe)
+		return nil, err
+	}
+	argsPtr, err := windows.UTF16PtrFromString(strings.Join(quoted, " "))
+	if err != nil {
+		_ = windows.CloseHandle(pipe)
+		return nil, err
+	}
+	err = windows.ShellExecute(0, verbPtr, exePtr, argsPtr, nil, windows.SW_HIDE)
+	if err != nil {
+		_ = windows.CloseHandle(pipe)
+		return nil, fmt.Errorf("starting elevated process failed: %w", err)
+	}
+
+	err = windows.ConnectNamedPipe(pipe, nil)
+	if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+		_ = windows.CloseHandle(pipe)
+		return nil, fmt.Errorf("ConnectNamedPipe: %w", err)
+	}
+
+	return os.NewFile(uintptr(pipe), name), nil
+}
@@ -0,0 +1,40 @@
+package fs
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// FileID identifies a file on a Windows volume, similar to the device and
+// inode numbers on Unix.
+type FileID struct {
+	VolumeSerialNumber uint64
+	FileIndex          uint64
+	// Links is the number of hard links of the file.
+	Links uint64
+}
+
+// GetFileID returns the file ID and the number of hard links of the file at
+// path. Unlike the file attributes returned by Lstat, this requires opening
+// the file.
+func GetFileID(path string) (FileID, error) {
+	h, err := openNoFollow(path, windows.FILE_READ_ATTRIBUTES)
+	if err != nil {
+		return FileID{}, err
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	var info windows.ByHandleFileInformation
+	err = windows.GetFileInformationByHandle(h, &info)
+	if err != nil {
+		return FileID{}, fmt.Errorf("GetFileInformationByHandle: %w", err)
+	}
+	return FileID{
+		VolumeSerialNumber: uint64(info.VolumeSerialNumber),
+		FileIndex:          uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow),
+		Links:              uint64(info.NumberOfLinks),
+	}, nil
+}
@@ -0,0 +1,33 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestGetFileID(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	link := filepath.Join(dir, "link")
+	other := filepath.Join(dir, "other")
+	rtest.OK(t, os.WriteFile(file, []byte("content"), 0600))
+	rtest.OK(t, os.WriteFile(other, []byte("content"), 0600))
+	rtest.OK(t, Link(file, link))
+
+	fileID, err := GetFileID(file)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(2), fileID.Links)
+
+	linkID, err := GetFileID(link)
+	rtest.OK(t, err)
+	rtest.Equals(t, fileID, linkID)
+
+	otherID, err := GetFileID(other)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(1), otherID.Links)
+	rtest.Equals(t, fileID.VolumeSerialNumber, otherID.VolumeSerialNumber)
+	rtest.Assert(t, fileID.FileIndex != otherID.FileIndex, "different files have the same file index %v", fileID.FileIndex)
+}
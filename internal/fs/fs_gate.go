@@ -0,0 +1,48 @@
+package fs
+
+import (
+	"os"
+)
+
+// Gate is a wrapper around another file system which calls Wait before data is
+// read from a file. This can be used to pause or slow down reading, for
+// example depending on the power state of the system.
+type Gate struct {
+	FS
+
+	// Wait is called with the size of the buffer before each read. If it
+	// returns an error, the read fails with this error.
+	Wait func(n int) error
+}
+
+// Open wraps the Open method of the underlying file system.
+func (fs Gate) Open(name string) (File, error) {
+	f, err := fs.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return gateFile{File: f, wait: fs.Wait}, nil
+}
+
+// OpenFile wraps the OpenFile method of the underlying file system.
+func (fs Gate) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := fs.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return gateFile{File: f, wait: fs.Wait}, nil
+}
+
+type gateFile struct {
+	File
+	wait func(n int) error
+}
+
+func (f gateFile) Read(p []byte) (int, error) {
+	if err := f.wait(len(p)); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
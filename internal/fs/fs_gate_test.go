@@ -0,0 +1,40 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestGate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file")
+	rtest.OK(t, os.WriteFile(filename, []byte("content"), 0600))
+
+	var requested int
+	errPaused := errors.New("paused")
+	paused := false
+	gate := Gate{FS: Local{}, Wait: func(n int) error {
+		if paused {
+			return errPaused
+		}
+		requested += n
+		return nil
+	}}
+
+	f, err := gate.Open(filename)
+	rtest.OK(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(f, buf)
+	rtest.OK(t, err)
+	rtest.Equals(t, "cont", string(buf))
+	rtest.Equals(t, 4, requested)
+
+	paused = true
+	_, err = f.Read(buf)
+	rtest.Assert(t, errors.Is(err, errPaused), "unexpected error %v", err)
+	rtest.OK(t, f.Close())
+}
@@ -18,6 +18,8 @@ type VSSConfig struct {
 	ExcludeVolumes        string        `option:"exclude-volumes" help:"semicolon separated list of volumes to exclude from snapshotting (ex. 'c:\\;e:\\mnt;\\\\?\\Volume{...}')"`
 	Timeout               time.Duration `option:"timeout" help:"time that the VSS can spend creating snapshot before timing out"`
 	Provider              string        `option:"provider" help:"VSS provider identifier which will be used for snapshotting"`
+	CSVProvider           string        `option:"csv-provider" help:"VSS provider identifier which will be used for snapshotting cluster shared volumes"`
+	CSVLock               bool          `option:"csv-lock" help:"serialize snapshot creation for cluster shared volumes across cluster nodes (default: true)"`
 }
 
 func init() {
@@ -30,6 +32,7 @@ func init() {
 func NewVSSConfig() VSSConfig {
 	return VSSConfig{
 		Timeout: time.Second * 120,
+		CSVLock: true,
 	}
 }
 
@@ -44,6 +47,14 @@ func ParseVSSConfig(o options.Options) (VSSConfig, error) {
 	return cfg, nil
 }
 
+// VssCSVOptions configures the snapshotting of cluster shared volumes.
+type VssCSVOptions struct {
+	// Provider is used instead of the default provider for cluster shared volumes.
+	Provider string
+	// Lock serializes the snapshot creation across cluster nodes.
+	Lock bool
+}
+
 // ErrorHandler is used to report errors via callback.
 type ErrorHandler func(item string, err error)
 
@@ -66,6 +77,7 @@ type LocalVss struct {
 	excludeVolumes        map[string]struct{}
 	timeout               time.Duration
 	provider              string
+	csv                   VssCSVOptions
 }
 
 // statically ensure that LocalVss implements FS.
@@ -105,6 +117,10 @@ func NewLocalVss(msgError ErrorHandler, msgMessage MessageHandler, cfg VSSConfig
 		excludeVolumes:        parseMountPoints(cfg.ExcludeVolumes, msgError),
 		timeout:               cfg.Timeout,
 		provider:              cfg.Provider,
+		csv: VssCSVOptions{
+			Provider: cfg.CSVProvider,
+			Lock:     cfg.CSVLock,
+		},
 	}
 }
 
@@ -210,7 +226,7 @@ func (fs *LocalVss) snapshotPath(path string) string {
 					}
 				}
 
-				if snapshot, err := NewVssSnapshot(fs.provider, vssVolume, fs.timeout, includeVolume, fs.msgError); err != nil {
+				if snapshot, err := NewVssSnapshot(fs.provider, fs.csv, vssVolume, fs.timeout, includeVolume, fs.msgError); err != nil {
 					fs.msgError(vssVolume, errors.Errorf("failed to create snapshot for [%s]: %s",
 						vssVolume, err))
 					fs.failedSnapshots[volumeNameLower] = struct{}{}
@@ -53,6 +53,7 @@ func TestVSSConfig(t *testing.T) {
 		excludeAllMountPoints bool
 		timeout               time.Duration
 		provider              string
+		csv                   VssCSVOptions
 	}
 	setTests := []struct {
 		input  options.Options
@@ -66,6 +67,7 @@ func TestVSSConfig(t *testing.T) {
 			config{
 				timeout:  23922000000000,
 				provider: "Ms",
+				csv:      VssCSVOptions{Lock: true},
 			},
 		},
 		{
@@ -77,6 +79,7 @@ func TestVSSConfig(t *testing.T) {
 				excludeAllMountPoints: true,
 				timeout:               120000000000,
 				provider:              "{b5946137-7b9f-4925-af80-51abd60b20d5}",
+				csv:                   VssCSVOptions{Lock: true},
 			},
 		},
 		{
@@ -89,6 +92,17 @@ func TestVSSConfig(t *testing.T) {
 			config{
 				timeout:  120000000000,
 				provider: "Microsoft Software Shadow Copy provider 1.0",
+				csv:      VssCSVOptions{Lock: true},
+			},
+		},
+		{
+			options.Options{
+				"vss.csv-provider": "csv",
+				"vss.csv-lock":     "false",
+			},
+			config{
+				timeout: 120000000000,
+				csv:     VssCSVOptions{Provider: "csv"},
 			},
 		},
 	}
@@ -110,7 +124,7 @@ func TestVSSConfig(t *testing.T) {
 
 			if dst.excludeAllMountPoints != test.output.excludeAllMountPoints ||
 				dst.excludeVolumes != nil || dst.timeout != test.output.timeout ||
-				dst.provider != test.output.provider {
+				dst.provider != test.output.provider || dst.csv != test.output.csv {
 				t.Fatalf("wrong result, want:\n  %#v\ngot:\n  %#v", test.output, dst)
 			}
 		})
@@ -283,3 +297,25 @@ func TestParseProvider(t *testing.T) {
 		})
 	}
 }
+
+func TestVssWriterState(t *testing.T) {
+	for _, test := range []struct {
+		state  VssWriterState
+		str    string
+		failed bool
+	}{
+		{VSS_WS_STABLE, "VSS_WS_STABLE", false},
+		{VSS_WS_WAITING_FOR_BACKUP_COMPLETE, "VSS_WS_WAITING_FOR_BACKUP_COMPLETE", false},
+		{VSS_WS_FAILED_AT_IDENTIFY, "VSS_WS_FAILED_AT_IDENTIFY", true},
+		{VSS_WS_FAILED_AT_FREEZE, "VSS_WS_FAILED_AT_FREEZE", true},
+		{VSS_WS_FAILED_AT_BACKUPSHUTDOWN, "VSS_WS_FAILED_AT_BACKUPSHUTDOWN", true},
+		{VssWriterState(100), "UNKNOWN", false},
+	} {
+		if test.state.Str() != test.str {
+			t.Errorf("wrong string for state %d, want %v, got %v", test.state, test.str, test.state.Str())
+		}
+		if test.state.Failed() != test.failed {
+			t.Errorf("wrong failed state for %v, want %v", test.str, test.failed)
+		}
+	}
+}
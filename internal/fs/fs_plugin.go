@@ -0,0 +1,443 @@
+package fs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// SourcePlugin is a file system which provides the virtual file tree of an
+// external program. The program receives requests as JSON objects, one per
+// line, on its standard input and answers on its standard output:
+//
+//	{"op":"list"}
+//
+// is answered with one JSON object per line for each entry of the tree, for
+// example {"path":"/db/users.sql","type":"file","size":123,"mode":420,
+// "mtime":"2024-01-02T15:04:05Z"}, followed by {"end":true}. The type is
+// either "file" or "dir", parent directories are added automatically.
+//
+//	{"op":"open","path":"/db/users.sql"}
+//
+// is answered with a line {"size":N} followed by exactly N bytes of file
+// content. Both requests can instead be answered with {"error":"message"}.
+//
+// Only a single file can be opened at a time, further calls to Open block
+// until the file is closed.
+type SourcePlugin struct {
+	entries map[string]*pluginEntry
+
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	wait   func() error
+
+	// mu is held while a file is open
+	mu sync.Mutex
+	// broken is set when the communication with the plugin failed
+	broken error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// statically ensure that SourcePlugin implements FS.
+var _ FS = &SourcePlugin{}
+
+type pluginRequest struct {
+	Op   string `json:"op"`
+	Path string `json:"path,omitempty"`
+}
+
+type pluginListResponse struct {
+	Path  string    `json:"path"`
+	Type  string    `json:"type"`
+	Size  int64     `json:"size"`
+	Mode  uint32    `json:"mode"`
+	MTime time.Time `json:"mtime"`
+	End   bool      `json:"end"`
+	Error string    `json:"error"`
+}
+
+type pluginOpenResponse struct {
+	Size  int64  `json:"size"`
+	Error string `json:"error"`
+}
+
+type pluginEntry struct {
+	fi       fakeFileInfo
+	children []string
+}
+
+// NewSourcePlugin starts the plugin command args and reads the list of its
+// entries. Messages written by the plugin to its standard error are passed to
+// logOutput.
+func NewSourcePlugin(ctx context.Context, args []string, logOutput io.Writer) (*SourcePlugin, error) {
+	command := exec.CommandContext(ctx, args[0], args[1:]...)
+	stdin, err := command.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup stdin pipe: %w", err)
+	}
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup stdout pipe: %w", err)
+	}
+
+	// Use a Go routine to handle the stderr to avoid deadlocks
+	stderr, err := command.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup stderr pipe: %w", err)
+	}
+	go func() {
+		sc := bufio.NewScanner(stderr)
+		for sc.Scan() {
+			_, _ = fmt.Fprintf(logOutput, "plugin %v: %v\n", command.Args[0], sc.Text())
+		}
+	}()
+
+	if err := command.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin: %w", err)
+	}
+
+	p, err := newSourcePlugin(stdin, stdout, command.Wait)
+	if err != nil {
+		_ = stdin.Close()
+		_ = command.Wait()
+		return nil, err
+	}
+	return p, nil
+}
+
+func newSourcePlugin(stdin io.WriteCloser, stdout io.Reader, wait func() error) (*SourcePlugin, error) {
+	p := &SourcePlugin{
+		entries: make(map[string]*pluginEntry),
+		stdin:   stdin,
+		stdout:  bufio.NewReader(stdout),
+		wait:    wait,
+	}
+	p.entries["/"] = &pluginEntry{fi: pluginDirInfo("/", 0755, time.Now())}
+
+	if err := p.request(pluginRequest{Op: "list"}); err != nil {
+		return nil, err
+	}
+	for {
+		var resp pluginListResponse
+		if err := p.response(&resp); err != nil {
+			return nil, err
+		}
+		if resp.Error != "" {
+			return nil, errors.Errorf("plugin failed to list entries: %v", resp.Error)
+		}
+		if resp.End {
+			break
+		}
+		if err := p.add(resp); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range p.entries {
+		sort.Strings(e.children)
+	}
+	return p, nil
+}
+
+func pluginDirInfo(name string, mode os.FileMode, modtime time.Time) fakeFileInfo {
+	return fakeFileInfo{
+		name:    path.Base(name),
+		mode:    os.ModeDir | mode,
+		modtime: modtime,
+	}
+}
+
+// add inserts the listed entry into the tree, missing parent directories are
+// created.
+func (p *SourcePlugin) add(resp pluginListResponse) error {
+	name := path.Clean(resp.Path)
+	if !path.IsAbs(resp.Path) || name == "/" {
+		return errors.Errorf("plugin returned invalid path %q", resp.Path)
+	}
+
+	mtime := resp.MTime
+	if mtime.IsZero() {
+		// an unknown modification time ensures that the file is always read
+		mtime = time.Now()
+	}
+	mode := os.FileMode(resp.Mode) & os.ModePerm
+
+	var fi fakeFileInfo
+	switch resp.Type {
+	case "file":
+		if mode == 0 {
+			mode = 0644
+		}
+		fi = fakeFileInfo{name: path.Base(name), size: resp.Size, mode: mode, modtime: mtime}
+	case "dir":
+		if mode == 0 {
+			mode = 0755
+		}
+		fi = pluginDirInfo(name, mode, mtime)
+	default:
+		return errors.Errorf("plugin returned unsupported type %q for %v", resp.Type, name)
+	}
+
+	if e, ok := p.entries[name]; ok {
+		if !e.fi.IsDir() || !fi.IsDir() {
+			return errors.Errorf("plugin returned duplicate path %v", name)
+		}
+		// replace the implicitly created directory
+		e.fi = fi
+		return nil
+	}
+	p.entries[name] = &pluginEntry{fi: fi}
+
+	// add the entry to its parent directories
+	for child := name; child != "/"; {
+		dir := path.Dir(child)
+		parent, ok := p.entries[dir]
+		if ok && !parent.fi.IsDir() {
+			return errors.Errorf("plugin returned file %v which contains other entries", dir)
+		}
+		if !ok {
+			parent = &pluginEntry{fi: pluginDirInfo(dir, 0755, mtime)}
+			p.entries[dir] = parent
+		}
+		parent.children = append(parent.children, path.Base(child))
+		if ok {
+			break
+		}
+		child = dir
+	}
+	return nil
+}
+
+func (p *SourcePlugin) request(req pluginRequest) error {
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = p.stdin.Write(append(buf, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to send request to plugin: %w", err)
+	}
+	return nil
+}
+
+func (p *SourcePlugin) response(resp interface{}) error {
+	line, err := p.stdout.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("failed to read response from plugin: %w", err)
+	}
+	if err := json.Unmarshal(line, resp); err != nil {
+		return fmt.Errorf("plugin returned invalid response: %w", err)
+	}
+	return nil
+}
+
+// Targets returns the entries in the root directory of the plugin.
+func (p *SourcePlugin) Targets() []string {
+	var targets []string
+	for _, name := range p.entries["/"].children {
+		targets = append(targets, path.Join("/", name))
+	}
+	return targets
+}
+
+// Close closes the standard input of the plugin and waits for it to exit.
+// Subsequent calls return the same result.
+func (p *SourcePlugin) Close() error {
+	p.closeOnce.Do(func() {
+		_ = p.stdin.Close()
+		if err := p.wait(); err != nil {
+			p.closeErr = fmt.Errorf("plugin failed: %w", err)
+		}
+	})
+	return p.closeErr
+}
+
+// VolumeName returns leading volume name, for the SourcePlugin file system
+// it's always the empty string.
+func (p *SourcePlugin) VolumeName(_ string) string {
+	return ""
+}
+
+// Open opens a file for reading.
+func (p *SourcePlugin) Open(name string) (File, error) {
+	return p.OpenFile(name, O_RDONLY, 0)
+}
+
+// OpenFile opens a file or directory for reading. Only O_RDONLY and
+// O_NOFOLLOW are supported for flag.
+func (p *SourcePlugin) OpenFile(name string, flag int, _ os.FileMode) (File, error) {
+	if flag & ^(O_RDONLY|O_NOFOLLOW) != 0 {
+		return nil, pathError("open", name,
+			fmt.Errorf("invalid combination of flags 0x%x", flag))
+	}
+
+	clean := p.Clean(name)
+	e, ok := p.entries[clean]
+	if !ok {
+		return nil, pathError("open", name, syscall.ENOENT)
+	}
+
+	if e.fi.IsDir() {
+		entries := make([]os.FileInfo, 0, len(e.children))
+		for _, child := range e.children {
+			entries = append(entries, p.entries[p.Join(clean, child)].fi)
+		}
+		return fakeDir{
+			entries:  entries,
+			fakeFile: fakeFile{name: name, FileInfo: e.fi},
+		}, nil
+	}
+
+	p.mu.Lock()
+	f, err := p.open(clean, e.fi)
+	if err != nil {
+		p.mu.Unlock()
+		return nil, pathError("open", name, err)
+	}
+	return f, nil
+}
+
+func (p *SourcePlugin) open(name string, fi fakeFileInfo) (*pluginFile, error) {
+	if p.broken != nil {
+		return nil, p.broken
+	}
+
+	err := p.request(pluginRequest{Op: "open", Path: name})
+	if err == nil {
+		var resp pluginOpenResponse
+		err = p.response(&resp)
+		if err == nil && resp.Error != "" {
+			return nil, errors.New(resp.Error)
+		}
+		if err == nil && resp.Size < 0 {
+			err = errors.Errorf("plugin returned invalid size %d", resp.Size)
+		}
+		fi.size = resp.Size
+	}
+	if err != nil {
+		p.broken = err
+		return nil, err
+	}
+
+	return &pluginFile{
+		plugin:   p,
+		rd:       &io.LimitedReader{R: p.stdout, N: fi.size},
+		fakeFile: fakeFile{name: name, FileInfo: fi},
+	}, nil
+}
+
+// Stat returns a FileInfo describing the named file.
+func (p *SourcePlugin) Stat(name string) (os.FileInfo, error) {
+	return p.Lstat(name)
+}
+
+// Lstat returns the FileInfo structure describing the named file.
+func (p *SourcePlugin) Lstat(name string) (os.FileInfo, error) {
+	e, ok := p.entries[p.Clean(name)]
+	if !ok {
+		return nil, pathError("lstat", name, os.ErrNotExist)
+	}
+	return e.fi, nil
+}
+
+// Join joins any number of path elements into a single path.
+func (p *SourcePlugin) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// Separator returns the OS and FS dependent separator for dirs/subdirs/files.
+func (p *SourcePlugin) Separator() string {
+	return "/"
+}
+
+// IsAbs reports whether the path is absolute. For the SourcePlugin, this is
+// always the case.
+func (p *SourcePlugin) IsAbs(_ string) bool {
+	return true
+}
+
+// Abs returns an absolute representation of path. For the SourcePlugin, all
+// paths are absolute.
+func (p *SourcePlugin) Abs(name string) (string, error) {
+	return p.Clean(name), nil
+}
+
+// Clean returns the cleaned path.
+func (p *SourcePlugin) Clean(name string) string {
+	return path.Clean(path.Join("/", name))
+}
+
+// Base returns the last element of name.
+func (p *SourcePlugin) Base(name string) string {
+	return path.Base(name)
+}
+
+// Dir returns name without the last element.
+func (p *SourcePlugin) Dir(name string) string {
+	return path.Dir(name)
+}
+
+// pluginFile is a file opened from a SourcePlugin. It reads the file content
+// directly from the standard output of the plugin.
+type pluginFile struct {
+	plugin *SourcePlugin
+	rd     *io.LimitedReader
+	closed bool
+
+	fakeFile
+}
+
+// ensure that pluginFile implements File
+var _ File = &pluginFile{}
+
+func (f *pluginFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, pathError("read", f.name, os.ErrClosed)
+	}
+	n, err := f.rd.Read(p)
+	if err == io.EOF && f.rd.N > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF {
+		f.plugin.broken = err
+		return n, pathError("read", f.name, err)
+	}
+	return n, err
+}
+
+// Close skips the unread part of the file content and allows opening the
+// next file.
+func (f *pluginFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	defer f.plugin.mu.Unlock()
+
+	if f.plugin.broken != nil {
+		return nil
+	}
+	if _, err := io.Copy(io.Discard, f.rd); err != nil || f.rd.N > 0 {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		f.plugin.broken = err
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package fs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// runTestPlugin answers the requests of a SourcePlugin using the listed
+// entries and the file contents in files.
+func runTestPlugin(t *testing.T, list []string, files map[string]string) (*SourcePlugin, error) {
+	reqRd, reqWr := io.Pipe()
+	respRd, respWr := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer func() {
+			_ = respWr.Close()
+		}()
+
+		sc := bufio.NewScanner(reqRd)
+		for sc.Scan() {
+			var req pluginRequest
+			if err := json.Unmarshal(sc.Bytes(), &req); err != nil {
+				t.Errorf("invalid request: %v", err)
+				return
+			}
+
+			switch req.Op {
+			case "list":
+				for _, line := range list {
+					_, _ = fmt.Fprintln(respWr, line)
+				}
+			case "open":
+				data, ok := files[req.Path]
+				if !ok {
+					_, _ = fmt.Fprintf(respWr, "{\"error\":\"%v not found\"}\n", req.Path)
+					continue
+				}
+				_, _ = fmt.Fprintf(respWr, "{\"size\":%d}\n%s", len(data), data)
+			}
+		}
+	}()
+
+	wait := func() error {
+		<-done
+		return nil
+	}
+	return newSourcePlugin(reqWr, respRd, wait)
+}
+
+func TestSourcePlugin(t *testing.T) {
+	p, err := runTestPlugin(t, []string{
+		`{"path":"/db/users.sql","type":"file","size":5,"mode":384,"mtime":"2024-01-02T15:04:05Z"}`,
+		`{"path":"/db/orders.sql","type":"file"}`,
+		`{"path":"/objects","type":"dir","mode":448}`,
+		`{"path":"/objects/a/b.json","type":"file"}`,
+		`{"end":true}`,
+	}, map[string]string{
+		"/db/users.sql":     "users",
+		"/db/orders.sql":    "orders",
+		"/objects/a/b.json": "{}",
+	})
+	rtest.OK(t, err)
+
+	rtest.Equals(t, []string{"/db", "/objects"}, p.Targets())
+
+	fi, err := p.Lstat("/db/users.sql")
+	rtest.OK(t, err)
+	rtest.Equals(t, os.FileMode(0600), fi.Mode())
+	rtest.Equals(t, int64(5), fi.Size())
+
+	fi, err = p.Lstat("/objects")
+	rtest.OK(t, err)
+	rtest.Equals(t, os.ModeDir|0700, fi.Mode())
+
+	fi, err = p.Lstat("/objects/a")
+	rtest.OK(t, err)
+	rtest.Assert(t, fi.IsDir(), "implicit parent directory is missing")
+
+	_, err = p.Lstat("/missing")
+	rtest.Assert(t, os.IsNotExist(err), "unexpected error %v", err)
+
+	dir, err := p.OpenFile("/db", O_RDONLY|O_NOFOLLOW, 0)
+	rtest.OK(t, err)
+	names, err := dir.Readdirnames(-1)
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"orders.sql", "users.sql"}, names)
+	rtest.OK(t, dir.Close())
+
+	// only read a part of the first file
+	f, err := p.OpenFile("/db/orders.sql", O_RDONLY|O_NOFOLLOW, 0)
+	rtest.OK(t, err)
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(f, buf)
+	rtest.OK(t, err)
+	rtest.Equals(t, "or", string(buf))
+	fi, err = f.Stat()
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(6), fi.Size())
+	rtest.OK(t, f.Close())
+
+	for name, content := range map[string]string{
+		"/db/users.sql":     "users",
+		"/objects/a/b.json": "{}",
+	} {
+		f, err := p.Open(name)
+		rtest.OK(t, err)
+		data, err := io.ReadAll(f)
+		rtest.OK(t, err)
+		rtest.Equals(t, content, string(data))
+		rtest.OK(t, f.Close())
+	}
+
+	rtest.OK(t, p.Close())
+}
+
+func TestSourcePluginOpenError(t *testing.T) {
+	p, err := runTestPlugin(t, []string{
+		`{"path":"/a","type":"file"}`,
+		`{"path":"/b","type":"file"}`,
+		`{"end":true}`,
+	}, map[string]string{"/b": "b"})
+	rtest.OK(t, err)
+
+	_, err = p.Open("/a")
+	rtest.Assert(t, err != nil, "missing error")
+
+	// the plugin must still be usable
+	f, err := p.Open("/b")
+	rtest.OK(t, err)
+	data, err := io.ReadAll(f)
+	rtest.OK(t, err)
+	rtest.Equals(t, "b", string(data))
+	rtest.OK(t, f.Close())
+	rtest.OK(t, p.Close())
+}
+
+func TestSourcePluginInvalidList(t *testing.T) {
+	for _, list := range [][]string{
+		{`{"error":"failed"}`},
+		{`{"path":"relative","type":"file"}`, `{"end":true}`},
+		{`{"path":"/a","type":"symlink"}`, `{"end":true}`},
+		{`{"path":"/a","type":"file"}`, `{"path":"/a","type":"file"}`, `{"end":true}`},
+		{`{"path":"/a","type":"file"}`, `{"path":"/a/b","type":"file"}`, `{"end":true}`},
+		{`invalid`},
+	} {
+		_, err := runTestPlugin(t, list, nil)
+		rtest.Assert(t, err != nil, "missing error for %v", list)
+	}
+}
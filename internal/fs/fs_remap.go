@@ -0,0 +1,63 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Remap is a wrapper around another file system which makes the directory
+// Source available at the path Target. This allows storing data from a
+// temporary mount point under its original logical path. Strict parent
+// directories of Target which do not exist in the underlying file system
+// report the metadata of Source. All other paths are passed on unchanged.
+type Remap struct {
+	FS
+	Source string
+	Target string
+}
+
+// statically ensure that Remap implements FS.
+var _ FS = &Remap{}
+
+// RealPath returns the path in the underlying file system for name.
+func (fs *Remap) RealPath(name string) string {
+	if !HasPathPrefix(fs.Target, name) {
+		if fs.isParent(name) {
+			if _, err := fs.FS.Lstat(name); os.IsNotExist(err) {
+				return fs.Source
+			}
+		}
+		return name
+	}
+
+	rel, err := filepath.Rel(fs.Target, name)
+	if err != nil {
+		return name
+	}
+	return filepath.Join(fs.Source, rel)
+}
+
+// isParent returns true if name is a strict parent directory of Target.
+func (fs *Remap) isParent(name string) bool {
+	return HasPathPrefix(name, fs.Target) && filepath.Clean(name) != filepath.Clean(fs.Target)
+}
+
+// Open wraps the Open method of the underlying file system.
+func (fs *Remap) Open(name string) (File, error) {
+	return fs.FS.Open(fs.RealPath(name))
+}
+
+// OpenFile wraps the OpenFile method of the underlying file system.
+func (fs *Remap) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return fs.FS.OpenFile(fs.RealPath(name), flag, perm)
+}
+
+// Stat wraps the Stat method of the underlying file system.
+func (fs *Remap) Stat(name string) (os.FileInfo, error) {
+	return fs.FS.Stat(fs.RealPath(name))
+}
+
+// Lstat wraps the Lstat method of the underlying file system.
+func (fs *Remap) Lstat(name string) (os.FileInfo, error) {
+	return fs.FS.Lstat(fs.RealPath(name))
+}
@@ -0,0 +1,35 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestRemap(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	source := filepath.Join(tempdir, "mnt")
+	rtest.OK(t, os.Mkdir(source, 0700))
+	rtest.OK(t, os.WriteFile(filepath.Join(source, "file"), []byte("content"), 0600))
+
+	target := filepath.Join(tempdir, "logical", "data")
+	fs := &Remap{FS: Local{}, Source: source, Target: target}
+
+	rtest.Equals(t, filepath.Join(source, "file"), fs.RealPath(filepath.Join(target, "file")))
+	rtest.Equals(t, source, fs.RealPath(target))
+	// missing parent directories of the target are mapped to the source
+	rtest.Equals(t, source, fs.RealPath(filepath.Join(tempdir, "logical")))
+	// existing parent directories and unrelated paths are not changed
+	rtest.Equals(t, tempdir, fs.RealPath(tempdir))
+	rtest.Equals(t, filepath.Join(tempdir, "other"), fs.RealPath(filepath.Join(tempdir, "other")))
+
+	fi, err := fs.Lstat(filepath.Join(target, "file"))
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(7), fi.Size())
+
+	f, err := fs.Open(filepath.Join(target, "file"))
+	rtest.OK(t, err)
+	rtest.OK(t, f.Close())
+}
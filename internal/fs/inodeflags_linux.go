@@ -0,0 +1,121 @@
+package fs
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Inode flags as shown by lsattr and changed by chattr, see
+// include/uapi/linux/fs.h. golang.org/x/sys/unix does not define them.
+const (
+	InodeFlagSecureRemove uint32 = 0x00000001 // s
+	InodeFlagUndelete     uint32 = 0x00000002 // u
+	InodeFlagCompress     uint32 = 0x00000004 // c
+	InodeFlagSync         uint32 = 0x00000008 // S
+	InodeFlagImmutable    uint32 = 0x00000010 // i
+	InodeFlagAppend       uint32 = 0x00000020 // a
+	InodeFlagNoDump       uint32 = 0x00000040 // d
+	InodeFlagNoAtime      uint32 = 0x00000080 // A
+	InodeFlagNoCompress   uint32 = 0x00000400 // m
+	InodeFlagDirSync      uint32 = 0x00010000 // D
+	InodeFlagTopDir       uint32 = 0x00020000 // T
+	InodeFlagNoCOW        uint32 = 0x00800000 // C
+	InodeFlagProjInherit  uint32 = 0x20000000 // P
+)
+
+// InodeFlagsMask contains the inode flags which can be changed using chattr.
+// Other flags, for example those describing the on-disk format, are managed
+// by the file system.
+const InodeFlagsMask = InodeFlagSecureRemove | InodeFlagUndelete | InodeFlagCompress |
+	InodeFlagSync | InodeFlagImmutable | InodeFlagAppend | InodeFlagNoDump |
+	InodeFlagNoAtime | InodeFlagNoCompress | InodeFlagDirSync | InodeFlagTopDir |
+	InodeFlagNoCOW | InodeFlagProjInherit
+
+// PrivilegedInodeFlags can only be changed with CAP_LINUX_IMMUTABLE.
+const PrivilegedInodeFlags = InodeFlagImmutable | InodeFlagAppend
+
+// openInodeFlags opens the regular file or directory at path such that its
+// inode flags can be read and changed. Symlinks are not followed, and device
+// files and FIFOs are not opened, as that could block or have side effects.
+func openInodeFlags(path string) (int, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return -1, err
+	}
+	if !fi.Mode().IsRegular() && !fi.IsDir() {
+		return -1, &os.PathError{Op: "open", Path: path, Err: unix.ENOTTY}
+	}
+
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return fd, nil
+}
+
+// inodeFlagsUnsupported returns true if err indicates that the file system
+// does not support inode flags.
+func inodeFlagsUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EINVAL)
+}
+
+// GetInodeFlags returns the inode flags of the regular file or directory at
+// path, restricted to InodeFlagsMask. It returns 0 if the file system does not
+// support inode flags.
+func GetInodeFlags(path string) (uint32, error) {
+	fd, err := openInodeFlags(path)
+	if inodeFlagsUnsupported(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = unix.Close(fd)
+	}()
+
+	flags, err := unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+	if inodeFlagsUnsupported(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, &os.PathError{Op: "FS_IOC_GETFLAGS", Path: path, Err: err}
+	}
+	return flags & InodeFlagsMask, nil
+}
+
+// SetInodeFlags replaces the flags in InodeFlagsMask of the regular file or
+// directory at path with flags. Flags managed by the file system are kept.
+func SetInodeFlags(path string, flags uint32) error {
+	fd, err := openInodeFlags(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = unix.Close(fd)
+	}()
+
+	current, err := unix.IoctlGetUint32(fd, unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return &os.PathError{Op: "FS_IOC_GETFLAGS", Path: path, Err: err}
+	}
+	flags = current&^InodeFlagsMask | flags&InodeFlagsMask
+	if flags == current {
+		return nil
+	}
+
+	// the kernel reads an int, regardless of the size of long
+	if err := unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, int(int32(flags))); err != nil {
+		return &os.PathError{Op: "FS_IOC_SETFLAGS", Path: path, Err: err}
+	}
+	return nil
+}
+
+// NoDump returns true if the nodump flag is set for the regular file or
+// directory at path.
+func NoDump(path string) bool {
+	flags, err := GetInodeFlags(path)
+	return err == nil && flags&InodeFlagNoDump != 0
+}
@@ -0,0 +1,35 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestInodeFlags(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	filename := filepath.Join(tempdir, "file")
+	rtest.OK(t, os.WriteFile(filename, []byte("content"), 0o600))
+
+	err := SetInodeFlags(filename, InodeFlagNoDump)
+	if err != nil {
+		t.Skipf("file system does not support inode flags: %v", err)
+	}
+	rtest.Assert(t, NoDump(filename), "nodump flag was not set")
+	flags, err := GetInodeFlags(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, InodeFlagNoDump, flags&InodeFlagNoDump)
+
+	rtest.OK(t, SetInodeFlags(filename, 0))
+	rtest.Assert(t, !NoDump(filename), "nodump flag was not removed")
+
+	// the flags of symlinks and special files cannot be read
+	link := filepath.Join(tempdir, "link")
+	rtest.OK(t, os.Symlink(filename, link))
+	flags, err = GetInodeFlags(link)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint32(0), flags)
+	rtest.Assert(t, SetInodeFlags(link, InodeFlagNoDump) != nil, "setting flags of a symlink succeeded")
+}
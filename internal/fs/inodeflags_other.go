@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package fs
+
+// NoDump returns false, as the nodump flag is only read on Linux.
+func NoDump(_ string) bool {
+	return false
+}
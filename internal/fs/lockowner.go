@@ -0,0 +1,56 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/restic/restic/internal/debug"
+)
+
+// ProcessInfo describes a process which has a file open.
+type ProcessInfo struct {
+	PID  int
+	Name string
+}
+
+func (p ProcessInfo) String() string {
+	return fmt.Sprintf("%s (pid %d)", p.Name, p.PID)
+}
+
+// LockedError is returned for files which could not be accessed because they
+// are locked by other processes.
+type LockedError struct {
+	Err       error
+	Processes []ProcessInfo
+}
+
+func (e *LockedError) Error() string {
+	names := make([]string, 0, len(e.Processes))
+	for _, p := range e.Processes {
+		names = append(names, p.String())
+	}
+	return fmt.Sprintf("%v (locked by %s)", e.Err, strings.Join(names, ", "))
+}
+
+func (e *LockedError) Unwrap() error {
+	return e.Err
+}
+
+// WithLockOwners returns err annotated with the processes which have the file
+// at path open, if accessing the file failed because it is locked. Otherwise,
+// or if the processes cannot be determined, err is returned unchanged.
+func WithLockOwners(path string, err error) error {
+	if err == nil || !isLockError(err) {
+		return err
+	}
+
+	procs, lerr := lockingProcesses(path)
+	if lerr != nil {
+		debug.Log("unable to determine processes locking %v: %v", path, lerr)
+		return err
+	}
+	if len(procs) == 0 {
+		return err
+	}
+	return &LockedError{Err: err, Processes: procs}
+}
@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// isLockError returns whether err was caused by a mandatory lock or a busy
+// file.
+func isLockError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EBUSY)
+}
+
+// lockingProcesses returns the processes which have the file at path open by
+// inspecting the file descriptors listed in /proc. Processes of other users
+// are only found when running as root.
+func lockingProcesses(path string) ([]ProcessInfo, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []ProcessInfo
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			// the process has exited or belongs to another user
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || target != path {
+				continue
+			}
+
+			name, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+			if err != nil {
+				name = []byte("unknown")
+			}
+			procs = append(procs, ProcessInfo{PID: pid, Name: strings.TrimSpace(string(name))})
+			break
+		}
+	}
+	return procs, nil
+}
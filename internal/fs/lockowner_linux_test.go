@@ -0,0 +1,36 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestWithLockOwners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	f, err := os.Create(path)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, f.Close())
+	}()
+
+	// other errors are returned unchanged
+	notExist := &os.PathError{Op: "open", Path: path, Err: syscall.ENOENT}
+	rtest.Equals(t, error(notExist), WithLockOwners(path, notExist))
+
+	busy := &os.PathError{Op: "open", Path: path, Err: syscall.EBUSY}
+	err = WithLockOwners(path, busy)
+	var lerr *LockedError
+	rtest.Assert(t, errors.As(err, &lerr), "expected LockedError, got %v", err)
+	rtest.Assert(t, errors.Is(err, syscall.EBUSY), "original error is not wrapped: %v", err)
+
+	found := false
+	for _, p := range lerr.Processes {
+		found = found || p.PID == os.Getpid()
+	}
+	rtest.Assert(t, found, "current process not found in %v", lerr.Processes)
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package fs
+
+// isLockError returns false, as the processes locking a file cannot be
+// determined on this platform.
+func isLockError(_ error) bool {
+	return false
+}
+
+// lockingProcesses is not supported on this platform.
+func lockingProcesses(_ string) ([]ProcessInfo, error) {
+	return nil, nil
+}
@@ -0,0 +1,116 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modRstrtmgr             = windows.NewLazySystemDLL("rstrtmgr.dll")
+	procRmStartSession      = modRstrtmgr.NewProc("RmStartSession")
+	procRmRegisterResources = modRstrtmgr.NewProc("RmRegisterResources")
+	procRmGetList           = modRstrtmgr.NewProc("RmGetList")
+	procRmEndSession        = modRstrtmgr.NewProc("RmEndSession")
+)
+
+const (
+	// cchRmSessionKey is the length of a Restart Manager session key.
+	cchRmSessionKey = 32
+	cchRmMaxAppName = 255
+	cchRmMaxSvcName = 63
+)
+
+// rmProcessInfo is the RM_PROCESS_INFO structure of the Restart Manager.
+type rmProcessInfo struct {
+	ProcessID        uint32
+	ProcessStartTime windows.Filetime
+	AppName          [cchRmMaxAppName + 1]uint16
+	ServiceShortName [cchRmMaxSvcName + 1]uint16
+	ApplicationType  uint32
+	AppStatus        uint32
+	TSSessionID      uint32
+	Restartable      int32
+}
+
+// isLockError returns whether err was caused by another process which opened
+// the file without sharing it or locked a range of the file.
+func isLockError(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
+
+// rmCall calls a Restart Manager function, which return an error code instead
+// of setting the last error.
+func rmCall(proc *windows.LazyProc, args ...uintptr) error {
+	ret, _, _ := proc.Call(args...)
+	if ret != 0 {
+		return syscall.Errno(ret)
+	}
+	return nil
+}
+
+// lockingProcesses returns the processes which have the file at path open,
+// using the Restart Manager API.
+func lockingProcesses(path string) ([]ProcessInfo, error) {
+	if err := procRmStartSession.Find(); err != nil {
+		return nil, err
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var session uint32
+	var key [cchRmSessionKey + 1]uint16
+	if err := rmCall(procRmStartSession, uintptr(unsafe.Pointer(&session)), 0, uintptr(unsafe.Pointer(&key[0]))); err != nil {
+		return nil, fmt.Errorf("RmStartSession: %w", err)
+	}
+	defer func() {
+		_ = rmCall(procRmEndSession, uintptr(session))
+	}()
+
+	files := []*uint16{pathPtr}
+	if err := rmCall(procRmRegisterResources, uintptr(session), 1, uintptr(unsafe.Pointer(&files[0])), 0, 0, 0, 0); err != nil {
+		return nil, fmt.Errorf("RmRegisterResources: %w", err)
+	}
+
+	var infos []rmProcessInfo
+	for {
+		var needed, reasons uint32
+		count := uint32(len(infos))
+		var infoPtr uintptr
+		if count > 0 {
+			infoPtr = uintptr(unsafe.Pointer(&infos[0]))
+		}
+		err := rmCall(procRmGetList, uintptr(session), uintptr(unsafe.Pointer(&needed)),
+			uintptr(unsafe.Pointer(&count)), infoPtr, uintptr(unsafe.Pointer(&reasons)))
+		if errors.Is(err, windows.ERROR_MORE_DATA) {
+			// the list of processes may grow between the calls
+			infos = make([]rmProcessInfo, needed)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("RmGetList: %w", err)
+		}
+		infos = infos[:count]
+		break
+	}
+
+	procs := make([]ProcessInfo, 0, len(infos))
+	for _, info := range infos {
+		procs = append(procs, ProcessInfo{
+			PID:  int(info.ProcessID),
+			Name: windows.UTF16ToString(info.AppName[:]),
+		})
+	}
+	return procs, nil
+}
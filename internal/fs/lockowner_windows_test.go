@@ -0,0 +1,38 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sys/windows"
+)
+
+func TestWithLockOwners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	rtest.OK(t, os.WriteFile(path, []byte("content"), 0600))
+
+	// open the file without allowing other processes to share it
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	rtest.OK(t, err)
+	h, err := windows.CreateFile(pathPtr, windows.GENERIC_READ, 0, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, windows.CloseHandle(h))
+	}()
+
+	_, err = os.Open(path)
+	rtest.Assert(t, errors.Is(err, windows.ERROR_SHARING_VIOLATION), "expected sharing violation, got %v", err)
+
+	err = WithLockOwners(path, err)
+	var lerr *LockedError
+	rtest.Assert(t, errors.As(err, &lerr), "expected LockedError, got %v", err)
+
+	found := false
+	for _, p := range lerr.Processes {
+		found = found || p.PID == os.Getpid()
+	}
+	rtest.Assert(t, found, "current process not found in %v", lerr.Processes)
+}
@@ -0,0 +1,5 @@
+package fs
+
+// ReparseTagMountPoint is the reparse tag of NTFS mount points, which are
+// either junctions to a directory or volume mount points.
+const ReparseTagMountPoint = 0xA0000003
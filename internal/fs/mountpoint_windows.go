@@ -0,0 +1,108 @@
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+// IsMountPoint returns whether the directory described by fi is a junction or
+// a volume mount point.
+func IsMountPoint(path string, fi os.FileInfo) bool {
+	attrs, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok || attrs.FileAttributes&windows.FILE_ATTRIBUTE_REPARSE_POINT == 0 ||
+		attrs.FileAttributes&windows.FILE_ATTRIBUTE_DIRECTORY == 0 {
+		return false
+	}
+	_, ok, err := ReadMountPoint(path)
+	return ok && err == nil
+}
+
+// ReadMountPoint returns the target of the junction or volume mount point at
+// path, for example C:\dir for a junction or \\?\Volume{GUID}\ for a volume
+// mount point. ok is false if path is not a mount point.
+func ReadMountPoint(path string) (target string, ok bool, err error) {
+	pathPtr, err := windows.UTF16PtrFromString(fixpath(path))
+	if err != nil {
+		return "", false, err
+	}
+	h, err := windows.CreateFile(pathPtr, 0,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil,
+		windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+	if err != nil {
+		return "", false, &os.PathError{Op: "CreateFile", Path: path, Err: err}
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	buf := make([]byte, windows.MAXIMUM_REPARSE_DATA_BUFFER_SIZE)
+	var n uint32
+	err = windows.DeviceIoControl(h, windows.FSCTL_GET_REPARSE_POINT, nil, 0, &buf[0], uint32(len(buf)), &n, nil)
+	if errors.Is(err, windows.ERROR_NOT_A_REPARSE_POINT) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, &os.PathError{Op: "FSCTL_GET_REPARSE_POINT", Path: path, Err: err}
+	}
+	return parseMountPoint(buf[:n])
+}
+
+// parseMountPoint returns the target stored in the REPARSE_DATA_BUFFER data.
+func parseMountPoint(data []byte) (target string, ok bool, err error) {
+	const headerLen = 16
+	if len(data) < headerLen {
+		return "", false, fmt.Errorf("reparse data too short: %d bytes", len(data))
+	}
+	if binary.LittleEndian.Uint32(data[0:]) != windows.IO_REPARSE_TAG_MOUNT_POINT {
+		return "", false, nil
+	}
+	offset := int(binary.LittleEndian.Uint16(data[8:]))
+	length := int(binary.LittleEndian.Uint16(data[10:]))
+	if headerLen+offset+length > len(data) || length%2 != 0 {
+		return "", false, fmt.Errorf("invalid mount point reparse data")
+	}
+	name := make([]uint16, length/2)
+	for i := range name {
+		name[i] = binary.LittleEndian.Uint16(data[headerLen+offset+2*i:])
+	}
+
+	// the substitute name is an NT path, for example \??\C:\dir
+	target = string(utf16.Decode(name))
+	if strings.HasPrefix(target, `\??\Volume{`) {
+		return `\\?\` + strings.TrimPrefix(target, `\??\`), true, nil
+	}
+	return strings.TrimPrefix(target, `\??\`), true, nil
+}
+
+// CreateMountPoint creates newname as a junction to the directory target or,
+// if target is a volume name like \\?\Volume{GUID}\, as a mount point of this
+// volume. Mounting a volume requires administrator privileges.
+func CreateMountPoint(target, newname string) error {
+	if !strings.HasPrefix(target, `\\?\Volume{`) {
+		return CreateJunction(target, newname)
+	}
+
+	if err := os.Mkdir(fixpath(newname), 0700); err != nil {
+		return err
+	}
+	mountPtr, err := windows.UTF16PtrFromString(strings.TrimSuffix(fixpath(newname), `\`) + `\`)
+	if err != nil {
+		return err
+	}
+	volumePtr, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return err
+	}
+	if err := windows.SetVolumeMountPoint(mountPtr, volumePtr); err != nil {
+		_ = os.Remove(fixpath(newname))
+		return &os.LinkError{Op: "SetVolumeMountPoint", Old: target, New: newname, Err: err}
+	}
+	return nil
+}
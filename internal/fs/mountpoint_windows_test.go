@@ -0,0 +1,45 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseMountPoint(t *testing.T) {
+	data, err := junctionReparseData(`C:\some\dir`)
+	rtest.OK(t, err)
+	target, ok, err := parseMountPoint(data)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "junction not detected")
+	rtest.Equals(t, `C:\some\dir`, target)
+
+	_, _, err = parseMountPoint(data[:10])
+	rtest.Assert(t, err != nil, "missing error for truncated data")
+}
+
+func TestReadMountPoint(t *testing.T) {
+	tempdir := t.TempDir()
+	target := filepath.Join(tempdir, "target")
+	rtest.OK(t, os.Mkdir(target, 0700))
+	junction := filepath.Join(tempdir, "junction")
+	rtest.OK(t, CreateMountPoint(target, junction))
+
+	link, ok, err := ReadMountPoint(junction)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "junction not detected")
+	rtest.Equals(t, target, link)
+
+	fi, err := os.Lstat(junction)
+	rtest.OK(t, err)
+	rtest.Assert(t, IsMountPoint(junction, fi), "junction not detected as mount point")
+
+	_, ok, err = ReadMountPoint(target)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "directory detected as mount point")
+	fi, err = os.Lstat(target)
+	rtest.OK(t, err)
+	rtest.Assert(t, !IsMountPoint(target, fi), "directory detected as mount point")
+}
@@ -0,0 +1,110 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// objectIDBufferSize is the size of a FILE_OBJECTID_BUFFER, which consists of
+// the object ID followed by the birth volume ID, the birth object ID and the
+// domain ID, each 16 bytes long.
+const objectIDBufferSize = 64
+
+// ErrObjectIDInUse is returned by SetObjectID if the object ID is already
+// assigned to a different file on the same volume. This is the case when
+// restoring a file next to its original.
+var ErrObjectIDInUse = errors.New("object ID is already in use on the volume")
+
+// GetObjectID returns the NTFS object ID of the file or directory at path,
+// including the birth IDs used by the distributed link tracking service. If
+// the file has no object ID or the filesystem does not support object IDs,
+// nil is returned.
+func GetObjectID(path string) (*[]byte, error) {
+	h, err := openNoFollow(path, windows.FILE_READ_ATTRIBUTES)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	buf := make([]byte, objectIDBufferSize)
+	var bytesReturned uint32
+	err = windows.DeviceIoControl(h, windows.FSCTL_GET_OBJECT_ID, nil, 0,
+		&buf[0], uint32(len(buf)), &bytesReturned, nil)
+	switch {
+	case errors.Is(err, windows.ERROR_FILE_NOT_FOUND),
+		errors.Is(err, windows.ERROR_INVALID_FUNCTION),
+		errors.Is(err, windows.ERROR_NOT_SUPPORTED):
+		// no object ID assigned or not an NTFS volume
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("FSCTL_GET_OBJECT_ID: %w", err)
+	}
+	buf = buf[:bytesReturned]
+	return &buf, nil
+}
+
+// SetObjectID assigns the object ID to the file or directory at path. An
+// object ID already assigned to the file is replaced. This requires the
+// SeRestorePrivilege.
+func SetObjectID(path string, objectID []byte) error {
+	if len(objectID) != objectIDBufferSize {
+		return fmt.Errorf("invalid object ID length %d", len(objectID))
+	}
+	onceRestore.Do(enableRestorePrivilege)
+
+	existing, err := GetObjectID(path)
+	if err != nil {
+		return err
+	}
+	if existing != nil && bytes.Equal(*existing, objectID) {
+		return nil
+	}
+
+	h, err := openNoFollow(path, windows.GENERIC_WRITE)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	var bytesReturned uint32
+	if existing != nil {
+		err = windows.DeviceIoControl(h, windows.FSCTL_DELETE_OBJECT_ID, nil, 0, nil, 0, &bytesReturned, nil)
+		if err != nil {
+			return fmt.Errorf("FSCTL_DELETE_OBJECT_ID: %w", err)
+		}
+	}
+
+	buf := append([]byte(nil), objectID...)
+	err = windows.DeviceIoControl(h, windows.FSCTL_SET_OBJECT_ID, &buf[0], uint32(len(buf)),
+		nil, 0, &bytesReturned, nil)
+	if errors.Is(err, windows.ERROR_DUP_NAME) {
+		return ErrObjectIDInUse
+	}
+	if err != nil {
+		return fmt.Errorf("FSCTL_SET_OBJECT_ID: %w", err)
+	}
+	return nil
+}
+
+// openNoFollow opens the file or directory at path without following
+// reparse points.
+func openNoFollow(path string, access uint32) (windows.Handle, error) {
+	pathPtr, err := windows.UTF16PtrFromString(fixpath(path))
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	h, err := windows.CreateFile(pathPtr, access,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+	if err != nil {
+		return windows.InvalidHandle, fmt.Errorf("CreateFile: %w", err)
+	}
+	return h, nil
+}
@@ -0,0 +1,39 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sys/windows"
+)
+
+func TestObjectID(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	other := filepath.Join(dir, "other")
+	rtest.OK(t, os.WriteFile(file, []byte("content"), 0600))
+	rtest.OK(t, os.WriteFile(other, []byte("content"), 0600))
+
+	id, err := GetObjectID(file)
+	rtest.OK(t, err)
+	rtest.Assert(t, id == nil, "new file has object ID %x", id)
+
+	objectID := rtest.Random(42, objectIDBufferSize)
+	err = SetObjectID(file, objectID)
+	if errors.Is(err, windows.ERROR_PRIVILEGE_NOT_HELD) || errors.Is(err, windows.ERROR_ACCESS_DENIED) ||
+		errors.Is(err, windows.ERROR_INVALID_FUNCTION) || errors.Is(err, windows.ERROR_NOT_SUPPORTED) {
+		t.Skipf("setting object IDs is not possible: %v", err)
+	}
+	rtest.OK(t, err)
+
+	id, err = GetObjectID(file)
+	rtest.OK(t, err)
+	rtest.Assert(t, id != nil, "object ID was not set")
+	rtest.Equals(t, objectID, *id)
+
+	rtest.Equals(t, ErrObjectIDInUse, SetObjectID(other, objectID))
+	rtest.OK(t, SetObjectID(other, rtest.Random(23, objectIDBufferSize)))
+}
@@ -0,0 +1,20 @@
+package fs
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// offlineAttributes mark files whose content is not stored locally, but
+// recalled by a hierarchical storage management system or a cloud sync
+// provider once the file is opened or read.
+const offlineAttributes = windows.FILE_ATTRIBUTE_OFFLINE | windows.FILE_ATTRIBUTE_RECALL_ON_OPEN | windows.FILE_ATTRIBUTE_RECALL_ON_DATA_ACCESS
+
+// IsOffline reports whether the content of the file described by fi is
+// offline, such that reading it triggers a recall.
+func IsOffline(fi os.FileInfo) bool {
+	attrs, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	return ok && attrs.FileAttributes&offlineAttributes != 0
+}
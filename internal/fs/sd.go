@@ -0,0 +1,33 @@
+package fs
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// sdControlOffset is the offset of the control flags within a self-relative
+// security descriptor.
+const sdControlOffset = 2
+
+// sdDACLProtected is the SE_DACL_PROTECTED control flag, which prevents the
+// DACL from inheriting entries from the parent directory.
+const sdDACLProtected = 0x1000
+
+// SetDACLProtected returns a copy of the self-relative security descriptor sd
+// in which the flag that blocks inheriting permissions from the parent
+// directory is set or cleared.
+func SetDACLProtected(sd []byte, protected bool) ([]byte, error) {
+	if len(sd) < sdControlOffset+2 {
+		return nil, fmt.Errorf("security descriptor too short (%d bytes)", len(sd))
+	}
+
+	res := append([]byte(nil), sd...)
+	control := binary.LittleEndian.Uint16(res[sdControlOffset:])
+	if protected {
+		control |= sdDACLProtected
+	} else {
+		control &^= sdDACLProtected
+	}
+	binary.LittleEndian.PutUint16(res[sdControlOffset:], control)
+	return res, nil
+}
@@ -0,0 +1,236 @@
+package fs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Control flags and ACE types used to translate between security descriptors
+// and POSIX permissions.
+const (
+	sdDACLPresent  = 0x0004
+	sdSelfRelative = 0x8000
+
+	aceTypeAccessAllowed = 0
+	aceTypeAccessDenied  = 1
+	aceFlagInheritOnly   = 0x08
+)
+
+// Access rights which are mapped to the read, write and execute bits.
+const (
+	accessRead  = 0x00000001 | 0x80000000 // FILE_READ_DATA, GENERIC_READ
+	accessWrite = 0x00000002 | 0x40000000 // FILE_WRITE_DATA, GENERIC_WRITE
+	accessExec  = 0x00000020 | 0x20000000 // FILE_EXECUTE, GENERIC_EXECUTE
+	accessAll   = 0x10000000              // GENERIC_ALL
+)
+
+// otherSIDs are the well-known groups whose permissions are translated to the
+// permissions for others: Everyone, Authenticated Users and Users.
+var otherSIDs = map[string]struct{}{
+	"S-1-1-0":      {},
+	"S-1-5-11":     {},
+	"S-1-5-32-545": {},
+}
+
+// ownerRightsSID is the OWNER RIGHTS SID, which always refers to the owner of
+// a file.
+const ownerRightsSID = "S-1-3-4"
+
+// parseSID returns the string representation of the SID at the start of b.
+func parseSID(b []byte) (string, error) {
+	if len(b) < 8 {
+		return "", fmt.Errorf("SID too short (%d bytes)", len(b))
+	}
+	count := int(b[1])
+	if len(b) < 8+4*count {
+		return "", fmt.Errorf("SID with %d sub-authorities too short (%d bytes)", count, len(b))
+	}
+
+	var authority uint64
+	for _, v := range b[2:8] {
+		authority = authority<<8 | uint64(v)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("S-")
+	sb.WriteString(strconv.Itoa(int(b[0])))
+	sb.WriteString("-")
+	sb.WriteString(strconv.FormatUint(authority, 10))
+	for i := 0; i < count; i++ {
+		sb.WriteString("-")
+		sb.WriteString(strconv.FormatUint(uint64(binary.LittleEndian.Uint32(b[8+4*i:])), 10))
+	}
+	return sb.String(), nil
+}
+
+// sidAt parses the SID at offset within the security descriptor sd, an offset
+// of zero denotes a missing SID.
+func sidAt(sd []byte, offset uint32) (string, error) {
+	if offset == 0 {
+		return "", nil
+	}
+	if int(offset) >= len(sd) {
+		return "", fmt.Errorf("SID offset %d out of range", offset)
+	}
+	return parseSID(sd[offset:])
+}
+
+// accessToPerm returns the read, write and execute bits for the access mask.
+func accessToPerm(mask uint32) os.FileMode {
+	if mask&accessAll != 0 {
+		return 07
+	}
+	var perm os.FileMode
+	if mask&accessRead != 0 {
+		perm |= 04
+	}
+	if mask&accessWrite != 0 {
+		perm |= 02
+	}
+	if mask&accessExec != 0 {
+		perm |= 01
+	}
+	return perm
+}
+
+// ModeFromSecurityDescriptor synthesizes approximate POSIX permissions from
+// the DACL of the self-relative security descriptor sd. Entries for the owner
+// and the primary group of the file map to the owner and group permissions,
+// entries for Everyone, Authenticated Users and Users to the permissions for
+// others. Permissions granted to others are also granted to owner and group.
+func ModeFromSecurityDescriptor(sd []byte) (os.FileMode, error) {
+	if len(sd) < 20 {
+		return 0, fmt.Errorf("security descriptor too short (%d bytes)", len(sd))
+	}
+	control := binary.LittleEndian.Uint16(sd[sdControlOffset:])
+	if control&sdSelfRelative == 0 {
+		return 0, fmt.Errorf("security descriptor is not self-relative")
+	}
+	if control&sdDACLPresent == 0 {
+		// a missing DACL grants full access to everyone
+		return 0777, nil
+	}
+
+	owner, err := sidAt(sd, binary.LittleEndian.Uint32(sd[4:]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid owner: %w", err)
+	}
+	group, err := sidAt(sd, binary.LittleEndian.Uint32(sd[8:]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid group: %w", err)
+	}
+
+	daclOffset := int(binary.LittleEndian.Uint32(sd[16:]))
+	if daclOffset == 0 {
+		return 0777, nil
+	}
+	if daclOffset+8 > len(sd) {
+		return 0, fmt.Errorf("DACL offset %d out of range", daclOffset)
+	}
+	aceCount := int(binary.LittleEndian.Uint16(sd[daclOffset+4:]))
+
+	// index 0 is the owner, 1 the group and 2 others
+	var allowed, denied [3]os.FileMode
+	pos := daclOffset + 8
+	for i := 0; i < aceCount; i++ {
+		if pos+8 > len(sd) {
+			return 0, fmt.Errorf("ACE %d out of range", i)
+		}
+		aceType, aceFlags := sd[pos], sd[pos+1]
+		aceSize := int(binary.LittleEndian.Uint16(sd[pos+2:]))
+		if aceSize < 8 || pos+aceSize > len(sd) {
+			return 0, fmt.Errorf("ACE %d has invalid size %d", i, aceSize)
+		}
+		ace := sd[pos : pos+aceSize]
+		pos += aceSize
+
+		if (aceType != aceTypeAccessAllowed && aceType != aceTypeAccessDenied) || aceFlags&aceFlagInheritOnly != 0 {
+			continue
+		}
+		sid, err := parseSID(ace[8:])
+		if err != nil {
+			return 0, fmt.Errorf("ACE %d: %w", i, err)
+		}
+		perm := accessToPerm(binary.LittleEndian.Uint32(ace[4:]))
+
+		var classes []int
+		switch _, other := otherSIDs[sid]; {
+		case other:
+			classes = []int{0, 1, 2}
+		case sid == owner || sid == ownerRightsSID:
+			classes = []int{0}
+		case sid == group:
+			classes = []int{1}
+		}
+		for _, c := range classes {
+			if aceType == aceTypeAccessAllowed {
+				allowed[c] |= perm
+			} else {
+				denied[c] |= perm
+			}
+		}
+	}
+
+	var mode os.FileMode
+	for c := 0; c < 3; c++ {
+		mode |= (allowed[c] &^ denied[c]) << (3 * (2 - c))
+	}
+	return mode, nil
+}
+
+// sddlRights returns the SDDL access rights for the read, write and execute
+// bits in perm.
+func sddlRights(perm os.FileMode) string {
+	if perm&07 == 07 {
+		return "FA"
+	}
+	var rights string
+	if perm&04 != 0 {
+		rights += "FR"
+	}
+	if perm&02 != 0 {
+		rights += "FW"
+	}
+	if perm&01 != 0 {
+		rights += "FX"
+	}
+	return rights
+}
+
+// SDDLFromMode returns a security descriptor in SDDL format with a protected
+// DACL which approximates the POSIX permissions in mode. The owner permissions
+// are granted to the SID owner, or to OWNER RIGHTS if owner is empty, the
+// group permissions to Users and the permissions for others to Everyone.
+// SYSTEM and Administrators always get full access.
+func SDDLFromMode(mode os.FileMode, owner, group string) string {
+	var sb strings.Builder
+	if owner != "" {
+		sb.WriteString("O:" + owner)
+	}
+	if group != "" {
+		sb.WriteString("G:" + group)
+	}
+	sb.WriteString("D:P(A;;FA;;;SY)(A;;FA;;;BA)")
+
+	// OWNER RIGHTS refers to the owner if it is not known
+	ownerSID := "OW"
+	if owner != "" {
+		ownerSID = owner
+	}
+	for _, ace := range []struct {
+		perm os.FileMode
+		sid  string
+	}{
+		{mode >> 6, ownerSID},
+		{mode >> 3, "BU"},
+		{mode, "WD"},
+	} {
+		if rights := sddlRights(ace.perm); rights != "" {
+			sb.WriteString("(A;;" + rights + ";;;" + ace.sid + ")")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,118 @@
+package fs
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// testSID encodes a SID with the identifier authority and sub-authorities.
+func testSID(authority byte, subAuthorities ...uint32) []byte {
+	sid := []byte{1, byte(len(subAuthorities)), 0, 0, 0, 0, 0, authority}
+	for _, sub := range subAuthorities {
+		sid = binary.LittleEndian.AppendUint32(sid, sub)
+	}
+	return sid
+}
+
+type testACE struct {
+	aceType byte
+	flags   byte
+	mask    uint32
+	sid     []byte
+}
+
+// testSecurityDescriptor builds a self-relative security descriptor.
+func testSecurityDescriptor(owner, group []byte, aces []testACE) []byte {
+	var dacl []byte
+	for _, ace := range aces {
+		size := 8 + len(ace.sid)
+		dacl = append(dacl, ace.aceType, ace.flags, byte(size), byte(size>>8))
+		dacl = binary.LittleEndian.AppendUint32(dacl, ace.mask)
+		dacl = append(dacl, ace.sid...)
+	}
+	aclHeader := []byte{2, 0, 0, 0, byte(len(aces)), 0, 0, 0}
+	binary.LittleEndian.PutUint16(aclHeader[2:], uint16(8+len(dacl)))
+
+	sd := []byte{1, 0, 0x04, 0x80}
+	offset := uint32(20)
+	sd = binary.LittleEndian.AppendUint32(sd, offset)
+	offset += uint32(len(owner))
+	sd = binary.LittleEndian.AppendUint32(sd, offset)
+	offset += uint32(len(group))
+	sd = binary.LittleEndian.AppendUint32(sd, 0)
+	sd = binary.LittleEndian.AppendUint32(sd, offset)
+	sd = append(sd, owner...)
+	sd = append(sd, group...)
+	sd = append(sd, aclHeader...)
+	return append(sd, dacl...)
+}
+
+func TestModeFromSecurityDescriptor(t *testing.T) {
+	owner := testSID(5, 21, 1, 2, 3, 1001)
+	group := testSID(5, 21, 1, 2, 3, 513)
+	everyone := testSID(1, 0)
+	users := testSID(5, 32, 545)
+	system := testSID(5, 18)
+
+	const (
+		fullControl = 0x1f01ff
+		readExecute = 0x1200a9
+		read        = 0x120089
+		write       = 0x000116
+	)
+
+	for _, test := range []struct {
+		name string
+		aces []testACE
+		mode os.FileMode
+	}{
+		{"owner-only", []testACE{
+			{aceTypeAccessAllowed, 0, fullControl, system},
+			{aceTypeAccessAllowed, 0, fullControl, owner},
+		}, 0700},
+		{"group-read", []testACE{
+			{aceTypeAccessAllowed, 0, read | write, owner},
+			{aceTypeAccessAllowed, 0, read, group},
+		}, 0640},
+		{"users-read-execute", []testACE{
+			{aceTypeAccessAllowed, 0, fullControl, owner},
+			{aceTypeAccessAllowed, 0, readExecute, users},
+		}, 0755},
+		{"everyone-generic-all", []testACE{
+			{aceTypeAccessAllowed, 0, accessAll, everyone},
+		}, 0777},
+		{"deny-write", []testACE{
+			{aceTypeAccessDenied, 0, write, group},
+			{aceTypeAccessAllowed, 0, read | write, everyone},
+		}, 0646},
+		{"inherit-only", []testACE{
+			{aceTypeAccessAllowed, 0, read, owner},
+			{aceTypeAccessAllowed, aceFlagInheritOnly, fullControl, everyone},
+		}, 0400},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sd := testSecurityDescriptor(owner, group, test.aces)
+			mode, err := ModeFromSecurityDescriptor(sd)
+			rtest.OK(t, err)
+			rtest.Equals(t, test.mode, mode)
+		})
+	}
+
+	// null DACL
+	mode, err := ModeFromSecurityDescriptor([]byte{1, 0, 0, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	rtest.OK(t, err)
+	rtest.Equals(t, os.FileMode(0777), mode)
+
+	_, err = ModeFromSecurityDescriptor([]byte{1, 0, 0x04, 0x80})
+	rtest.Assert(t, err != nil, "missing error for short security descriptor")
+}
+
+func TestSDDLFromMode(t *testing.T) {
+	rtest.Equals(t, "O:S-1-5-21-1G:S-1-5-21-2D:P(A;;FA;;;SY)(A;;FA;;;BA)(A;;FA;;;S-1-5-21-1)(A;;FRFX;;;BU)(A;;FR;;;WD)",
+		SDDLFromMode(0754, "S-1-5-21-1", "S-1-5-21-2"))
+	rtest.Equals(t, "D:P(A;;FA;;;SY)(A;;FA;;;BA)(A;;FRFW;;;OW)",
+		SDDLFromMode(0600, "", ""))
+}
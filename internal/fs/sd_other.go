@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import "github.com/restic/restic/internal/errors"
+
+// SetSecurityDescriptor sets the security descriptor of the file at filePath.
+// Security descriptors are only supported on Windows.
+func SetSecurityDescriptor(_ string, _ *[]byte) error {
+	return errors.New("security descriptors are only supported on Windows")
+}
@@ -0,0 +1,25 @@
+package fs
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestSetDACLProtected(t *testing.T) {
+	// revision 1, control SE_SELF_RELATIVE | SE_DACL_PRESENT
+	sd := []byte{1, 0, 0x04, 0x80, 0, 0, 0, 0}
+
+	protected, err := SetDACLProtected(sd, true)
+	rtest.OK(t, err)
+	rtest.Equals(t, []byte{1, 0, 0x04, 0x90, 0, 0, 0, 0}, protected)
+	// the input must not be modified
+	rtest.Equals(t, []byte{1, 0, 0x04, 0x80, 0, 0, 0, 0}, sd)
+
+	unprotected, err := SetDACLProtected(protected, false)
+	rtest.OK(t, err)
+	rtest.Equals(t, sd, unprotected)
+
+	_, err = SetDACLProtected([]byte{1, 0}, true)
+	rtest.Assert(t, err != nil, "missing error for short security descriptor")
+}
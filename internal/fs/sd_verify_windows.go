@@ -0,0 +1,165 @@
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// VerifySecurityDescriptor reads the security descriptor of the file at
+// filePath and compares it with the expected security descriptor, which was
+// previously passed to SetSecurityDescriptor. It returns an error describing
+// all differences, for example inherited entries which differ because of the
+// new parent directory or parts which could not be set due to missing
+// privileges.
+func VerifySecurityDescriptor(filePath string, expected []byte) error {
+	expectedSD, err := SecurityDescriptorBytesToStruct(expected)
+	if err != nil {
+		return fmt.Errorf("error converting bytes to security descriptor: %w", err)
+	}
+	actualBytes, err := GetSecurityDescriptor(filePath)
+	if err != nil {
+		return fmt.Errorf("unable to verify security descriptor: %w", err)
+	}
+	actualSD, err := SecurityDescriptorBytesToStruct(*actualBytes)
+	if err != nil {
+		return fmt.Errorf("error converting bytes to security descriptor: %w", err)
+	}
+
+	diffs := compareSecurityDescriptors(expectedSD, actualSD, lowerPrivileges.Load())
+	if len(diffs) > 0 {
+		return fmt.Errorf("security descriptor differs after restore: %v", strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
+// compareSecurityDescriptors returns the differences between the expected and
+// the actual security descriptor. If lowPrivileges is set, only the DACL could
+// be restored and read.
+func compareSecurityDescriptors(expected, actual *windows.SECURITY_DESCRIPTOR, lowPrivileges bool) []string {
+	var diffs []string
+
+	compareSID := func(name string, get func(*windows.SECURITY_DESCRIPTOR) (*windows.SID, bool, error)) {
+		exp, _, err := get(expected)
+		if err != nil || exp == nil {
+			return
+		}
+		act, _, err := get(actual)
+		if err != nil || act == nil || !exp.Equals(act) {
+			reason := ""
+			if lowPrivileges {
+				reason = " (missing privileges)"
+			}
+			diffs = append(diffs, fmt.Sprintf("%v is %v instead of %v%v", name, sidString(act), sidString(exp), reason))
+		}
+	}
+	compareSID("owner", (*windows.SECURITY_DESCRIPTOR).Owner)
+	compareSID("group", (*windows.SECURITY_DESCRIPTOR).Group)
+
+	expControl, _, _ := expected.Control()
+	actControl, _, _ := actual.Control()
+	if expControl&windows.SE_DACL_PROTECTED != actControl&windows.SE_DACL_PROTECTED {
+		if expControl&windows.SE_DACL_PROTECTED != 0 {
+			diffs = append(diffs, "DACL is not protected from inheritance")
+		} else {
+			diffs = append(diffs, "DACL is protected from inheritance")
+		}
+	}
+
+	expDACL, _, _ := expected.DACL()
+	actDACL, _, _ := actual.DACL()
+	if d := compareACL("DACL", expDACL, actDACL); d != "" {
+		diffs = append(diffs, d)
+	}
+
+	expSACL, _, _ := expected.SACL()
+	if lowPrivileges {
+		if expSACL != nil && aclCount(expSACL) > 0 {
+			diffs = append(diffs, "SACL was not restored (missing privileges)")
+		}
+	} else {
+		actSACL, _, _ := actual.SACL()
+		if d := compareACL("SACL", expSACL, actSACL); d != "" {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+func sidString(sid *windows.SID) string {
+	if sid == nil {
+		return "not set"
+	}
+	return sid.String()
+}
+
+// aclHeaderSize is the size of the ACL structure which precedes the ACEs.
+const aclHeaderSize = 8
+
+// aclCount returns the number of ACEs in the ACL.
+func aclCount(acl *windows.ACL) int {
+	return int(*(*uint16)(unsafe.Add(unsafe.Pointer(acl), 4)))
+}
+
+// aclEntries returns the explicit and the inherited ACEs of the ACL.
+func aclEntries(acl *windows.ACL) (explicit, inherited [][]byte) {
+	if acl == nil {
+		return nil, nil
+	}
+	size := int(*(*uint16)(unsafe.Add(unsafe.Pointer(acl), 2)))
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(acl)), size)
+
+	pos := aclHeaderSize
+	for i := 0; i < aclCount(acl) && pos+4 <= len(buf); i++ {
+		// ACE_HEADER: AceType byte, AceFlags byte, AceSize uint16
+		flags := buf[pos+1]
+		aceSize := int(buf[pos+2]) | int(buf[pos+3])<<8
+		if aceSize < 4 || pos+aceSize > len(buf) {
+			break
+		}
+		ace := buf[pos : pos+aceSize]
+		if flags&windows.INHERITED_ACE != 0 {
+			inherited = append(inherited, ace)
+		} else {
+			explicit = append(explicit, ace)
+		}
+		pos += aceSize
+	}
+	return explicit, inherited
+}
+
+func equalACEs(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareACL describes the difference between the expected and the actual
+// ACL, or returns an empty string if both are equal.
+func compareACL(name string, expected, actual *windows.ACL) string {
+	if (expected == nil) != (actual == nil) {
+		if expected == nil {
+			return fmt.Sprintf("%v is set but should be empty", name)
+		}
+		return fmt.Sprintf("%v is missing", name)
+	}
+
+	expExplicit, expInherited := aclEntries(expected)
+	actExplicit, actInherited := aclEntries(actual)
+	switch {
+	case !equalACEs(expExplicit, actExplicit):
+		return fmt.Sprintf("explicit %v entries differ (%d instead of %d entries)", name, len(actExplicit), len(expExplicit))
+	case !equalACEs(expInherited, actInherited):
+		return fmt.Sprintf("inherited %v entries differ (%d instead of %d entries), the parent directory grants other permissions", name, len(actInherited), len(expInherited))
+	}
+	return ""
+}
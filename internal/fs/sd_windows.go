@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"os"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -39,9 +40,13 @@ var lowBackupSecurityFlags windows.SECURITY_INFORMATION = windows.OWNER_SECURITY
 // Flags for restore without admin permissions. If there are no admin permissions, only the DACL from the SD can be restored and owner and group will be set based on the current user.
 var lowRestoreSecurityFlags windows.SECURITY_INFORMATION = windows.DACL_SECURITY_INFORMATION | windows.ATTRIBUTE_SECURITY_INFORMATION | windows.PROTECTED_DACL_SECURITY_INFORMATION
 
+// Flags which control whether the DACL and SACL inherit entries from the parent directory.
+var protectionSecurityFlags windows.SECURITY_INFORMATION = windows.PROTECTED_DACL_SECURITY_INFORMATION | windows.PROTECTED_SACL_SECURITY_INFORMATION | windows.UNPROTECTED_DACL_SECURITY_INFORMATION | windows.UNPROTECTED_SACL_SECURITY_INFORMATION
+
 // GetSecurityDescriptor takes the path of the file and returns the SecurityDescriptor for the file.
 // This needs admin permissions or SeBackupPrivilege for getting the full SD.
 // If there are no admin permissions, only the current user's owner, group and DACL will be got.
+// For symlinks, junctions and other reparse points, the SD of the reparse point itself is returned.
 func GetSecurityDescriptor(filePath string) (securityDescriptor *[]byte, err error) {
 	onceBackup.Do(enableBackupPrivilege)
 
@@ -77,6 +82,7 @@ func GetSecurityDescriptor(filePath string) (securityDescriptor *[]byte, err err
 // for setting the full SD.
 // If there are no admin permissions/required privileges, only the DACL from the SD can be set and
 // owner and group will be set based on the current user.
+// Symlinks, junctions and other reparse points are not followed.
 func SetSecurityDescriptor(filePath string, securityDescriptor *[]byte) error {
 	onceRestore.Do(enableRestorePrivilege)
 	// Set the security descriptor on the file
@@ -106,17 +112,19 @@ func SetSecurityDescriptor(filePath string, securityDescriptor *[]byte) error {
 		sacl = nil
 	}
 
+	protection := protectionFlags(sd)
+
 	if lowerPrivileges.Load() {
-		err = setNamedSecurityInfoLow(filePath, dacl)
+		err = setNamedSecurityInfoLow(filePath, dacl, protection)
 	} else {
-		err = setNamedSecurityInfoHigh(filePath, owner, group, dacl, sacl)
+		err = setNamedSecurityInfoHigh(filePath, owner, group, dacl, sacl, protection)
 	}
 
 	if err != nil {
 		if !lowerPrivileges.Load() && isHandlePrivilegeNotHeldError(err) {
 			// If ERROR_PRIVILEGE_NOT_HELD is encountered, fallback to backups/restores using lower non-admin privileges.
 			lowerPrivileges.Store(true)
-			err = setNamedSecurityInfoLow(filePath, dacl)
+			err = setNamedSecurityInfoLow(filePath, dacl, protection)
 			if err != nil {
 				return fmt.Errorf("set low-level named security info failed with: %w", err)
 			}
@@ -129,22 +137,82 @@ func SetSecurityDescriptor(filePath string, securityDescriptor *[]byte) error {
 
 // getNamedSecurityInfoHigh gets the higher level SecurityDescriptor which requires admin permissions.
 func getNamedSecurityInfoHigh(filePath string) (*windows.SECURITY_DESCRIPTOR, error) {
-	return windows.GetNamedSecurityInfo(filePath, windows.SE_FILE_OBJECT, highSecurityFlags)
+	return getSecurityInfo(filePath, windows.READ_CONTROL|windows.ACCESS_SYSTEM_SECURITY, highSecurityFlags)
 }
 
 // getNamedSecurityInfoLow gets the lower level SecurityDescriptor which requires no admin permissions.
 func getNamedSecurityInfoLow(filePath string) (*windows.SECURITY_DESCRIPTOR, error) {
-	return windows.GetNamedSecurityInfo(filePath, windows.SE_FILE_OBJECT, lowBackupSecurityFlags)
+	return getSecurityInfo(filePath, windows.READ_CONTROL, lowBackupSecurityFlags)
 }
 
 // setNamedSecurityInfoHigh sets the higher level SecurityDescriptor which requires admin permissions.
-func setNamedSecurityInfoHigh(filePath string, owner *windows.SID, group *windows.SID, dacl *windows.ACL, sacl *windows.ACL) error {
-	return windows.SetNamedSecurityInfo(filePath, windows.SE_FILE_OBJECT, highSecurityFlags, owner, group, dacl, sacl)
+func setNamedSecurityInfoHigh(filePath string, owner *windows.SID, group *windows.SID, dacl *windows.ACL, sacl *windows.ACL, protection windows.SECURITY_INFORMATION) error {
+	flags := highSecurityFlags&^protectionSecurityFlags | protection
+	return setSecurityInfo(filePath, windows.WRITE_DAC|windows.WRITE_OWNER|windows.ACCESS_SYSTEM_SECURITY, flags, owner, group, dacl, sacl)
 }
 
 // setNamedSecurityInfoLow sets the lower level SecurityDescriptor which requires no admin permissions.
-func setNamedSecurityInfoLow(filePath string, dacl *windows.ACL) error {
-	return windows.SetNamedSecurityInfo(filePath, windows.SE_FILE_OBJECT, lowRestoreSecurityFlags, nil, nil, dacl, nil)
+func setNamedSecurityInfoLow(filePath string, dacl *windows.ACL, protection windows.SECURITY_INFORMATION) error {
+	flags := lowRestoreSecurityFlags&^protectionSecurityFlags | protection&(windows.PROTECTED_DACL_SECURITY_INFORMATION|windows.UNPROTECTED_DACL_SECURITY_INFORMATION)
+	return setSecurityInfo(filePath, windows.WRITE_DAC, flags, nil, nil, dacl, nil)
+}
+
+// openSecurityHandle opens the file at filePath with the given access rights
+// for reading or writing its security descriptor. Symlinks, junctions and other
+// reparse points are opened themselves instead of the file they point to.
+func openSecurityHandle(filePath string, access uint32) (windows.Handle, error) {
+	pathPtr, err := windows.UTF16PtrFromString(fixpath(filePath))
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+	return windows.CreateFile(pathPtr, access,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil,
+		windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+}
+
+func getSecurityInfo(filePath string, access uint32, flags windows.SECURITY_INFORMATION) (*windows.SECURITY_DESCRIPTOR, error) {
+	h, err := openSecurityHandle(filePath, access)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+	return windows.GetSecurityInfo(h, windows.SE_FILE_OBJECT, flags)
+}
+
+func setSecurityInfo(filePath string, access uint32, flags windows.SECURITY_INFORMATION, owner *windows.SID, group *windows.SID, dacl *windows.ACL, sacl *windows.ACL) error {
+	h, err := openSecurityHandle(filePath, access)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+	return windows.SetSecurityInfo(h, windows.SE_FILE_OBJECT, flags, owner, group, dacl, sacl)
+}
+
+// protectionFlags returns the flags which restore the DACL and SACL of sd either
+// protected from or open to inheriting entries from the parent directory, as
+// recorded in the control flags of sd.
+func protectionFlags(sd *windows.SECURITY_DESCRIPTOR) windows.SECURITY_INFORMATION {
+	control, _, err := sd.Control()
+	if err != nil {
+		return windows.PROTECTED_DACL_SECURITY_INFORMATION | windows.PROTECTED_SACL_SECURITY_INFORMATION
+	}
+
+	var flags windows.SECURITY_INFORMATION
+	if control&windows.SE_DACL_PROTECTED != 0 {
+		flags |= windows.PROTECTED_DACL_SECURITY_INFORMATION
+	} else {
+		flags |= windows.UNPROTECTED_DACL_SECURITY_INFORMATION
+	}
+	if control&windows.SE_SACL_PROTECTED != 0 {
+		flags |= windows.PROTECTED_SACL_SECURITY_INFORMATION
+	} else {
+		flags |= windows.UNPROTECTED_SACL_SECURITY_INFORMATION
+	}
+	return flags
 }
 
 // enableBackupPrivilege enables privilege for backing up security descriptors
@@ -437,3 +505,29 @@ func errnoErr(e syscall.Errno) error {
 	}
 	return e
 }
+
+// SecurityDescriptorFromMode returns a self-relative security descriptor for
+// the current user, whose DACL approximates the POSIX permissions in mode.
+func SecurityDescriptorFromMode(mode os.FileMode) ([]byte, error) {
+	token := windows.GetCurrentProcessToken()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get token user: %w", err)
+	}
+	group, err := token.GetTokenPrimaryGroup()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get token primary group: %w", err)
+	}
+
+	sddl := SDDLFromMode(mode, user.User.Sid.String(), group.PrimaryGroup.String())
+	sd, err := windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert %q to security descriptor: %w", sddl, err)
+	}
+	b, err := securityDescriptorStructToBytes(sd)
+	if err != nil {
+		return nil, err
+	}
+	// the bytes are owned by the security descriptor
+	return append([]byte(nil), b...), nil
+}
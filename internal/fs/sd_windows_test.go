@@ -11,6 +11,7 @@ import (
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/test"
+	"golang.org/x/sys/windows"
 )
 
 func TestSetGetFileSecurityDescriptors(t *testing.T) {
@@ -43,6 +44,24 @@ func TestSetGetFolderSecurityDescriptors(t *testing.T) {
 	testSecurityDescriptors(t, TestDirSDs, testfolderPath)
 }
 
+func TestSetGetJunctionSecurityDescriptors(t *testing.T) {
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "target")
+	test.OK(t, os.Mkdir(target, os.ModeDir))
+	junction := filepath.Join(tempDir, "junction")
+	test.OK(t, CreateJunction(target, junction))
+
+	targetSD, err := GetSecurityDescriptor(target)
+	test.OK(t, err)
+
+	testSecurityDescriptors(t, TestDirSDs, junction)
+
+	// the security descriptor of the target must not change
+	sd, err := GetSecurityDescriptor(target)
+	test.OK(t, err)
+	CompareSecurityDescriptors(t, target, *targetSD, *sd)
+}
+
 func testSecurityDescriptors(t *testing.T, testSDs []string, testPath string) {
 	for _, testSD := range testSDs {
 		sdInputBytes, err := base64.StdEncoding.DecodeString(testSD)
@@ -58,3 +77,26 @@ func testSecurityDescriptors(t *testing.T, testSDs []string, testPath string) {
 		CompareSecurityDescriptors(t, testPath, sdInputBytes, *sdOutputBytes)
 	}
 }
+
+func TestCompareSecurityDescriptors(t *testing.T) {
+	var sds []*windows.SECURITY_DESCRIPTOR
+	for _, testSD := range TestFileSDs {
+		sdBytes, err := base64.StdEncoding.DecodeString(testSD)
+		test.OK(t, err)
+		sd, err := SecurityDescriptorBytesToStruct(sdBytes)
+		test.OK(t, err)
+		sds = append(sds, sd)
+	}
+
+	for i, sd := range sds {
+		diffs := compareSecurityDescriptors(sd, sd, false)
+		test.Assert(t, len(diffs) == 0, "unexpected differences for sd %d: %v", i, diffs)
+		for j := range sds {
+			if i == j {
+				continue
+			}
+			diffs := compareSecurityDescriptors(sd, sds[j], false)
+			test.Assert(t, len(diffs) > 0, "missing differences between sd %d and %d", i, j)
+		}
+	}
+}
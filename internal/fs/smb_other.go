@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import "github.com/restic/restic/internal/errors"
+
+// ConnectSMBShare connects to the SMB share \\server\share. This is only
+// supported on Windows, on other platforms the share must be mounted.
+func ConnectSMBShare(_, _, _ string) (func() error, error) {
+	return nil, errors.New("SMB targets are only supported on Windows, please mount the share instead")
+}
@@ -0,0 +1,79 @@
+//go:build windows
+// +build windows
+
+package fs
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	resourceTypeDisk = 0x1
+	connectTemporary = 0x4
+
+	errorSessionCredentialConflict = syscall.Errno(1219)
+)
+
+// netResource is the NETRESOURCEW structure.
+type netResource struct {
+	Scope       uint32
+	Type        uint32
+	DisplayType uint32
+	Usage       uint32
+	LocalName   *uint16
+	RemoteName  *uint16
+	Comment     *uint16
+	Provider    *uint16
+}
+
+var (
+	modmpr                    = windows.NewLazySystemDLL("mpr.dll")
+	procWNetAddConnection2W   = modmpr.NewProc("WNetAddConnection2W")
+	procWNetCancelConnection2 = modmpr.NewProc("WNetCancelConnection2W")
+)
+
+// ConnectSMBShare connects to the SMB share \\server\share using the given
+// credentials, without assigning a drive letter. If user is empty, the
+// credentials of the current user are used. Afterwards, the share can be
+// accessed using UNC paths. The returned function closes the connection.
+func ConnectSMBShare(share, user, password string) (func() error, error) {
+	remoteName, err := windows.UTF16PtrFromString(share)
+	if err != nil {
+		return nil, err
+	}
+	var userPtr, passwordPtr *uint16
+	if user != "" {
+		if userPtr, err = windows.UTF16PtrFromString(user); err != nil {
+			return nil, err
+		}
+		if passwordPtr, err = windows.UTF16PtrFromString(password); err != nil {
+			return nil, err
+		}
+	}
+
+	resource := netResource{
+		Type:       resourceTypeDisk,
+		RemoteName: remoteName,
+	}
+	r1, _, _ := syscall.SyscallN(procWNetAddConnection2W.Addr(), uintptr(unsafe.Pointer(&resource)),
+		uintptr(unsafe.Pointer(passwordPtr)), uintptr(unsafe.Pointer(userPtr)), connectTemporary)
+	if r1 != 0 {
+		err := syscall.Errno(r1)
+		if err == errorSessionCredentialConflict {
+			return nil, fmt.Errorf("connecting to %v failed, a connection to the server with other credentials already exists: %w", share, err)
+		}
+		return nil, fmt.Errorf("connecting to %v failed: %w", share, err)
+	}
+
+	return func() error {
+		r1, _, _ := syscall.SyscallN(procWNetCancelConnection2.Addr(), uintptr(unsafe.Pointer(remoteName)), 0, 1)
+		if r1 != 0 {
+			return fmt.Errorf("disconnecting from %v failed: %w", share, syscall.Errno(r1))
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// DeveloperModeEnabled returns whether the Windows developer mode is enabled.
+// It is always false on other platforms.
+func DeveloperModeEnabled() bool {
+	return false
+}
+
+// SymlinkOrJunction creates newname as a symbolic link to oldname. Junctions
+// only exist on Windows, thus junction is always false on other platforms.
+func SymlinkOrJunction(oldname, newname string) (junction bool, err error) {
+	return false, Symlink(oldname, newname)
+}
+
+// CreateJunction creates a junction to the directory target. Junctions are
+// only supported on Windows.
+func CreateJunction(_, _ string) error {
+	return errors.New("junctions are only supported on Windows")
+}
+
+// IsMountPoint returns whether the directory described by fi is a junction or
+// a volume mount point, which only exist on Windows.
+func IsMountPoint(_ string, _ os.FileInfo) bool {
+	return false
+}
+
+// CreateMountPoint creates a junction or volume mount point. Both are only
+// supported on Windows.
+func CreateMountPoint(_, _ string) error {
+	return errors.New("mount points are only supported on Windows")
+}
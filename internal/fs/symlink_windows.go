@@ -0,0 +1,180 @@
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// symbolicLinkFlagAllowUnprivilegedCreate allows creating symlinks without the
+// SeCreateSymbolicLinkPrivilege if developer mode is enabled. The flag is not
+// yet defined in golang.org/x/sys/windows.
+const symbolicLinkFlagAllowUnprivilegedCreate = 0x2
+
+var (
+	developerModeOnce    sync.Once
+	developerModeEnabled bool
+)
+
+// DeveloperModeEnabled returns whether the Windows developer mode is enabled,
+// which allows creating symlinks without administrator privileges.
+func DeveloperModeEnabled() bool {
+	developerModeOnce.Do(func() {
+		k, err := registry.OpenKey(registry.LOCAL_MACHINE,
+			`SOFTWARE\Microsoft\Windows\CurrentVersion\AppModelUnlock`, registry.QUERY_VALUE)
+		if err != nil {
+			return
+		}
+		defer func() {
+			_ = k.Close()
+		}()
+
+		v, _, err := k.GetIntegerValue("AllowDevelopmentWithoutDevLicense")
+		developerModeEnabled = err == nil && v == 1
+	})
+	return developerModeEnabled
+}
+
+// SymlinkOrJunction creates newname as a symbolic link to oldname. Without
+// administrator privileges, symlinks can only be created if the developer mode
+// is enabled. Otherwise, a junction is created instead if oldname is a
+// directory or does not exist (yet), and junction is set to true.
+func SymlinkOrJunction(oldname, newname string) (junction bool, err error) {
+	err = createSymbolicLink(oldname, newname)
+	if err == nil || !errors.Is(err, windows.ERROR_PRIVILEGE_NOT_HELD) {
+		return false, err
+	}
+
+	target := oldname
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(newname), target)
+	}
+	if fi, serr := os.Stat(fixpath(target)); serr == nil && !fi.IsDir() {
+		// junctions can only point to directories
+		return false, err
+	}
+
+	if jerr := CreateJunction(target, newname); jerr != nil {
+		return false, fmt.Errorf("%w, creating a junction instead failed: %v", err, jerr)
+	}
+	return true, nil
+}
+
+// createSymbolicLink creates the symlink like os.Symlink, but only requests
+// unprivileged creation if the developer mode is enabled.
+func createSymbolicLink(oldname, newname string) error {
+	var flags uint32
+	if DeveloperModeEnabled() {
+		flags |= symbolicLinkFlagAllowUnprivilegedCreate
+	}
+
+	target := oldname
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(newname), target)
+	}
+	if fi, err := os.Stat(fixpath(target)); err == nil && fi.IsDir() {
+		flags |= windows.SYMBOLIC_LINK_FLAG_DIRECTORY
+	}
+
+	linkPtr, err := windows.UTF16PtrFromString(fixpath(newname))
+	if err != nil {
+		return err
+	}
+	// symlink targets must use backslashes
+	targetPtr, err := windows.UTF16PtrFromString(filepath.FromSlash(oldname))
+	if err != nil {
+		return err
+	}
+	if err := windows.CreateSymbolicLink(linkPtr, targetPtr, flags); err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+// CreateJunction creates newname as a junction (NTFS mount point) to the
+// directory target. Creating junctions does not require any privileges.
+func CreateJunction(target, newname string) error {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	data, err := junctionReparseData(abs)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Mkdir(fixpath(newname), 0700); err != nil {
+		return err
+	}
+
+	pathPtr, err := windows.UTF16PtrFromString(fixpath(newname))
+	if err != nil {
+		return err
+	}
+	h, err := windows.CreateFile(pathPtr, windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+	if err != nil {
+		_ = os.Remove(fixpath(newname))
+		return fmt.Errorf("CreateFile: %w", err)
+	}
+
+	var bytesReturned uint32
+	err = windows.DeviceIoControl(h, windows.FSCTL_SET_REPARSE_POINT, &data[0], uint32(len(data)),
+		nil, 0, &bytesReturned, nil)
+	_ = windows.CloseHandle(h)
+	if err != nil {
+		_ = os.Remove(fixpath(newname))
+		return fmt.Errorf("FSCTL_SET_REPARSE_POINT: %w", err)
+	}
+	return nil
+}
+
+// junctionReparseData returns the REPARSE_DATA_BUFFER for a junction to the
+// absolute path target.
+func junctionReparseData(target string) ([]byte, error) {
+	target = strings.TrimPrefix(target, `\\?\`)
+	if strings.HasPrefix(target, `\\`) || filepath.VolumeName(target) == "" {
+		return nil, fmt.Errorf("junction target %v must be a path on a local volume", target)
+	}
+
+	substitute, err := windows.UTF16FromString(`\??\` + target)
+	if err != nil {
+		return nil, err
+	}
+	printName, err := windows.UTF16FromString(target)
+	if err != nil {
+		return nil, err
+	}
+
+	// the names are stored with the terminating null characters, which are
+	// not included in the lengths
+	substituteLen := 2 * (len(substitute) - 1)
+	printLen := 2 * (len(printName) - 1)
+	pathLen := 2 * (len(substitute) + len(printName))
+	const headerLen = 8
+	if headerLen+pathLen > 0xffff {
+		return nil, fmt.Errorf("junction target %v is too long", target)
+	}
+
+	data := make([]byte, 8+headerLen+pathLen)
+	binary.LittleEndian.PutUint32(data[0:], windows.IO_REPARSE_TAG_MOUNT_POINT)
+	binary.LittleEndian.PutUint16(data[4:], uint16(headerLen+pathLen))
+	binary.LittleEndian.PutUint16(data[8:], 0)
+	binary.LittleEndian.PutUint16(data[10:], uint16(substituteLen))
+	binary.LittleEndian.PutUint16(data[12:], uint16(substituteLen+2))
+	binary.LittleEndian.PutUint16(data[14:], uint16(printLen))
+
+	pos := 16
+	for _, c := range append(substitute, printName...) {
+		binary.LittleEndian.PutUint16(data[pos:], c)
+		pos += 2
+	}
+	return data, nil
+}
@@ -0,0 +1,49 @@
+package fs
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sys/windows"
+)
+
+func TestJunctionReparseData(t *testing.T) {
+	data, err := junctionReparseData(`C:\data`)
+	rtest.OK(t, err)
+
+	// `\??\C:\data` and `C:\data` with terminating null characters
+	substituteLen, printLen := 2*11, 2*7
+	rtest.Equals(t, 16+substituteLen+2+printLen+2, len(data))
+	rtest.Equals(t, uint32(windows.IO_REPARSE_TAG_MOUNT_POINT), binary.LittleEndian.Uint32(data[0:]))
+	rtest.Equals(t, uint16(len(data)-8), binary.LittleEndian.Uint16(data[4:]))
+	rtest.Equals(t, uint16(0), binary.LittleEndian.Uint16(data[8:]))
+	rtest.Equals(t, uint16(substituteLen), binary.LittleEndian.Uint16(data[10:]))
+	rtest.Equals(t, uint16(substituteLen+2), binary.LittleEndian.Uint16(data[12:]))
+	rtest.Equals(t, uint16(printLen), binary.LittleEndian.Uint16(data[14:]))
+
+	for _, target := range []string{`\\server\share\dir`, `relative\dir`} {
+		_, err := junctionReparseData(target)
+		rtest.Assert(t, err != nil, "missing error for %v", target)
+	}
+}
+
+func TestCreateJunction(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	target := filepath.Join(tempdir, "target")
+	rtest.OK(t, os.Mkdir(target, 0700))
+	rtest.OK(t, os.WriteFile(filepath.Join(target, "file"), []byte("data"), 0600))
+
+	link := filepath.Join(tempdir, "link")
+	rtest.OK(t, CreateJunction(target, link))
+
+	data, err := os.ReadFile(filepath.Join(link, "file"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "data", string(data))
+
+	dest, err := os.Readlink(link)
+	rtest.OK(t, err)
+	rtest.Equals(t, target, dest)
+}
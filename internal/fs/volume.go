@@ -0,0 +1,23 @@
+package fs
+
+// VolumeInfo describes the file system volume which contains a path.
+type VolumeInfo struct {
+	// Path is the root of the volume, for example C:\.
+	Path        string       `json:"path"`
+	Label       string       `json:"label,omitempty"`
+	Serial      uint32       `json:"serial,omitempty"`
+	FileSystem  string       `json:"filesystem,omitempty"`
+	ClusterSize uint64       `json:"cluster_size,omitempty"`
+	TotalSize   uint64       `json:"total_size,omitempty"`
+	UsedSize    uint64       `json:"used_size,omitempty"`
+	Quota       *VolumeQuota `json:"quota,omitempty"`
+}
+
+// VolumeQuota contains the disk quota settings of a volume.
+type VolumeQuota struct {
+	Tracked  bool `json:"tracked"`
+	Enforced bool `json:"enforced"`
+	// DefaultThreshold and DefaultLimit are -1 if no default is set.
+	DefaultThreshold int64 `json:"default_threshold"`
+	DefaultLimit     int64 `json:"default_limit"`
+}
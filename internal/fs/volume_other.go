@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+// IsVolumeRoot returns whether path is the root of a volume. Volumes are only
+// supported on Windows.
+func IsVolumeRoot(_ string) bool {
+	return false
+}
+
+// GetVolumeInfo returns the metadata of the volume which contains path. Volume
+// metadata is only supported on Windows, on other platforms nil is returned.
+func GetVolumeInfo(_ string) (*VolumeInfo, error) {
+	return nil, nil
+}
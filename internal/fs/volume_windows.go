@@ -0,0 +1,135 @@
+package fs
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/restic/restic/internal/debug"
+	"golang.org/x/sys/windows"
+)
+
+// IsVolumeRoot returns whether path is the root of a volume, like C:\ or
+// \\server\share\.
+func IsVolumeRoot(path string) bool {
+	path = filepath.Clean(path)
+	vol := filepath.VolumeName(path)
+	return vol != "" && path == vol+`\`
+}
+
+// GetVolumeInfo returns the metadata of the volume which contains path.
+func GetVolumeInfo(path string) (*VolumeInfo, error) {
+	pathPtr, err := windows.UTF16PtrFromString(fixpath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	rootBuf := make([]uint16, windows.MAX_LONG_PATH)
+	err = windows.GetVolumePathName(pathPtr, &rootBuf[0], uint32(len(rootBuf)))
+	if err != nil {
+		return nil, fmt.Errorf("GetVolumePathName: %w", err)
+	}
+	root := &rootBuf[0]
+	info := &VolumeInfo{Path: windows.UTF16ToString(rootBuf)}
+
+	label := make([]uint16, windows.MAX_PATH+1)
+	fsName := make([]uint16, windows.MAX_PATH+1)
+	var maxComponentLength, flags uint32
+	err = windows.GetVolumeInformation(root, &label[0], uint32(len(label)), &info.Serial,
+		&maxComponentLength, &flags, &fsName[0], uint32(len(fsName)))
+	if err != nil {
+		return nil, fmt.Errorf("GetVolumeInformation: %w", err)
+	}
+	info.Label = windows.UTF16ToString(label)
+	info.FileSystem = windows.UTF16ToString(fsName)
+
+	var sectorsPerCluster, bytesPerSector, freeClusters, totalClusters uint32
+	err = getDiskFreeSpace(root, &sectorsPerCluster, &bytesPerSector, &freeClusters, &totalClusters)
+	if err != nil {
+		return nil, fmt.Errorf("GetDiskFreeSpace: %w", err)
+	}
+	info.ClusterSize = uint64(sectorsPerCluster) * uint64(bytesPerSector)
+
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	err = windows.GetDiskFreeSpaceEx(root, &freeBytesAvailable, &totalBytes, &totalFreeBytes)
+	if err != nil {
+		return nil, fmt.Errorf("GetDiskFreeSpaceEx: %w", err)
+	}
+	info.TotalSize = totalBytes
+	info.UsedSize = totalBytes - totalFreeBytes
+
+	// reading the quota settings may require additional privileges
+	info.Quota, err = getVolumeQuota(root)
+	if err != nil {
+		debug.Log("unable to read quota settings of %v: %v", info.Path, err)
+	}
+
+	return info, nil
+}
+
+// fileFsControlInformation represents the FILE_FS_CONTROL_INFORMATION struct.
+type fileFsControlInformation struct {
+	FreeSpaceStartFiltering int64
+	FreeSpaceThreshold      int64
+	FreeSpaceStop           int64
+	DefaultQuotaThreshold   int64
+	DefaultQuotaLimit       int64
+	FileSystemControlFlags  uint32
+}
+
+const (
+	fileFsControlInformationClass = 6
+
+	fileVCQuotaTrack   = 0x1
+	fileVCQuotaEnforce = 0x2
+)
+
+// getVolumeQuota reads the quota settings of the volume with the given root.
+func getVolumeQuota(root *uint16) (*VolumeQuota, error) {
+	handle, err := windows.CreateFile(root, windows.FILE_READ_ATTRIBUTES,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = windows.CloseHandle(handle)
+	}()
+
+	var iosb ioStatusBlock
+	var fsci fileFsControlInformation
+	err = queryVolumeInformationFile(handle, &iosb, unsafe.Pointer(&fsci), uint32(unsafe.Sizeof(fsci)), fileFsControlInformationClass).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return &VolumeQuota{
+		Tracked:          fsci.FileSystemControlFlags&fileVCQuotaTrack != 0,
+		Enforced:         fsci.FileSystemControlFlags&fileVCQuotaEnforce != 0,
+		DefaultThreshold: fsci.DefaultQuotaThreshold,
+		DefaultLimit:     fsci.DefaultQuotaLimit,
+	}, nil
+}
+
+var (
+	modkernel32                      = windows.NewLazySystemDLL("kernel32.dll")
+	procGetDiskFreeSpaceW            = modkernel32.NewProc("GetDiskFreeSpaceW")
+	procNtQueryVolumeInformationFile = modntdll.NewProc("NtQueryVolumeInformationFile")
+)
+
+func getDiskFreeSpace(root *uint16, sectorsPerCluster, bytesPerSector, freeClusters, totalClusters *uint32) error {
+	r1, _, e1 := syscall.SyscallN(procGetDiskFreeSpaceW.Addr(), uintptr(unsafe.Pointer(root)),
+		uintptr(unsafe.Pointer(sectorsPerCluster)), uintptr(unsafe.Pointer(bytesPerSector)),
+		uintptr(unsafe.Pointer(freeClusters)), uintptr(unsafe.Pointer(totalClusters)))
+	if r1 == 0 {
+		return e1
+	}
+	return nil
+}
+
+func queryVolumeInformationFile(handle windows.Handle, iosb *ioStatusBlock, buf unsafe.Pointer, bufLen uint32, class uint32) ntStatus {
+	r0, _, _ := syscall.SyscallN(procNtQueryVolumeInformationFile.Addr(), uintptr(handle),
+		uintptr(unsafe.Pointer(iosb)), uintptr(buf), uintptr(bufLen), uintptr(class))
+	return ntStatus(r0)
+}
@@ -0,0 +1,32 @@
+package fs_test
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/fs"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestIsVolumeRoot(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		root bool
+	}{
+		{`C:\`, true},
+		{`c:\`, true},
+		{`\\server\share\`, true},
+		{`C:\Windows`, false},
+		{`C:`, false},
+		{`\\server\share\dir`, false},
+	} {
+		rtest.Equals(t, test.root, fs.IsVolumeRoot(test.path), test.path)
+	}
+}
+
+func TestGetVolumeInfo(t *testing.T) {
+	info, err := fs.GetVolumeInfo(t.TempDir())
+	rtest.OK(t, err)
+	rtest.Assert(t, fs.IsVolumeRoot(info.Path), "%v is not a volume root", info.Path)
+	rtest.Assert(t, info.ClusterSize > 0, "missing cluster size")
+	rtest.Assert(t, info.TotalSize >= info.UsedSize && info.TotalSize > 0, "invalid volume size %v, %v used", info.TotalSize, info.UsedSize)
+}
@@ -0,0 +1,108 @@
+//go:build windows
+// +build windows
+
+package fs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/windows"
+)
+
+// csvFileSystem is the file system name reported for cluster shared volumes.
+const csvFileSystem = "CSVFS"
+
+// csvProviderName is the name of the VSS provider for cluster shared volumes,
+// it can be selected using the alias "csv".
+const csvProviderName = "Microsoft CSV Shadow Copy Provider"
+
+// csvLockFile is the file in the root of a cluster shared volume which is
+// opened exclusively while a snapshot of the volume is created. As the CSV
+// file system enforces share modes across all cluster nodes, this serializes
+// the snapshot creation in the cluster.
+const csvLockFile = ".restic-vss.lock"
+
+// csvLockRetryInterval is the time to wait before retrying to acquire a lock
+// which is held by another node.
+const csvLockRetryInterval = 500 * time.Millisecond
+
+// isCSVVolume returns whether volume, which must end with a backslash, is a
+// cluster shared volume.
+func isCSVVolume(volume string) bool {
+	root, err := windows.UTF16PtrFromString(volume)
+	if err != nil {
+		return false
+	}
+
+	fsName := make([]uint16, windows.MAX_PATH+1)
+	err = windows.GetVolumeInformation(root, nil, 0, nil, nil, nil, &fsName[0], uint32(len(fsName)))
+	if err != nil {
+		debug.Log("GetVolumeInformation(%v) failed: %v", volume, err)
+		return false
+	}
+	return strings.EqualFold(windows.UTF16ToString(fsName), csvFileSystem)
+}
+
+// lockCSVVolume opens the lock file of the cluster shared volume exclusively.
+// If another node holds the lock, it retries until the deadline is reached.
+func lockCSVVolume(volume string, deadline time.Time) (windows.Handle, error) {
+	name, err := windows.UTF16PtrFromString(fixpath(filepath.Join(volume, csvLockFile)))
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+
+	for {
+		h, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil,
+			windows.OPEN_ALWAYS, windows.FILE_ATTRIBUTE_HIDDEN, 0)
+		if err == nil {
+			return h, nil
+		}
+		if !errors.Is(err, windows.ERROR_SHARING_VIOLATION) {
+			return windows.InvalidHandle, err
+		}
+		if time.Now().Add(csvLockRetryInterval).After(deadline) {
+			return windows.InvalidHandle, newVssTextError(fmt.Sprintf(
+				"timeout waiting for other cluster nodes to finish snapshotting %s", volume))
+		}
+		time.Sleep(csvLockRetryInterval)
+	}
+}
+
+// lockCSVVolumes locks all cluster shared volumes. The volumes are locked in a
+// fixed order to prevent deadlocks between nodes. If a lock file cannot be
+// created, the error is reported and the volume is snapshotted without lock.
+// The returned function releases all locks.
+func lockCSVVolumes(volumes []string, deadline time.Time, msgError ErrorHandler) (func(), error) {
+	volumes = append([]string(nil), volumes...)
+	sort.Slice(volumes, func(i, j int) bool {
+		return strings.ToLower(volumes[i]) < strings.ToLower(volumes[j])
+	})
+
+	var handles []windows.Handle
+	unlock := func() {
+		for _, h := range handles {
+			_ = windows.CloseHandle(h)
+		}
+	}
+
+	for _, volume := range volumes {
+		h, err := lockCSVVolume(volume, deadline)
+		if err != nil {
+			var vssErr *vssTextError
+			if errors.As(err, &vssErr) {
+				unlock()
+				return nil, err
+			}
+			msgError(volume, errors.Errorf("failed to lock cluster shared volume %s: %v", volume, err))
+			continue
+		}
+		handles = append(handles, h)
+	}
+	return unlock, nil
+}
@@ -250,6 +250,65 @@ const (
 	VSS_OBJECT_TYPE_COUNT
 )
 
+// VssWriterState is a custom type for the windows api VssWriterState type.
+type VssWriterState uint
+
+// VssWriterState constant values necessary for using VSS api.
+const (
+	VSS_WS_UNKNOWN VssWriterState = iota
+	VSS_WS_STABLE
+	VSS_WS_WAITING_FOR_FREEZE
+	VSS_WS_WAITING_FOR_THAW
+	VSS_WS_WAITING_FOR_POST_SNAPSHOT
+	VSS_WS_WAITING_FOR_BACKUP_COMPLETE
+	VSS_WS_FAILED_AT_IDENTIFY
+	VSS_WS_FAILED_AT_PREPARE_BACKUP
+	VSS_WS_FAILED_AT_PREPARE_SNAPSHOT
+	VSS_WS_FAILED_AT_FREEZE
+	VSS_WS_FAILED_AT_THAW
+	VSS_WS_FAILED_AT_POST_SNAPSHOT
+	VSS_WS_FAILED_AT_BACKUP_COMPLETE
+	VSS_WS_FAILED_AT_PRE_RESTORE
+	VSS_WS_FAILED_AT_POST_RESTORE
+	VSS_WS_FAILED_AT_BACKUPSHUTDOWN
+)
+
+// writerStateToString maps a VssWriterState value to a human readable string.
+var writerStateToString = map[VssWriterState]string{
+	VSS_WS_UNKNOWN:                     "VSS_WS_UNKNOWN",
+	VSS_WS_STABLE:                      "VSS_WS_STABLE",
+	VSS_WS_WAITING_FOR_FREEZE:          "VSS_WS_WAITING_FOR_FREEZE",
+	VSS_WS_WAITING_FOR_THAW:            "VSS_WS_WAITING_FOR_THAW",
+	VSS_WS_WAITING_FOR_POST_SNAPSHOT:   "VSS_WS_WAITING_FOR_POST_SNAPSHOT",
+	VSS_WS_WAITING_FOR_BACKUP_COMPLETE: "VSS_WS_WAITING_FOR_BACKUP_COMPLETE",
+	VSS_WS_FAILED_AT_IDENTIFY:          "VSS_WS_FAILED_AT_IDENTIFY",
+	VSS_WS_FAILED_AT_PREPARE_BACKUP:    "VSS_WS_FAILED_AT_PREPARE_BACKUP",
+	VSS_WS_FAILED_AT_PREPARE_SNAPSHOT:  "VSS_WS_FAILED_AT_PREPARE_SNAPSHOT",
+	VSS_WS_FAILED_AT_FREEZE:            "VSS_WS_FAILED_AT_FREEZE",
+	VSS_WS_FAILED_AT_THAW:              "VSS_WS_FAILED_AT_THAW",
+	VSS_WS_FAILED_AT_POST_SNAPSHOT:     "VSS_WS_FAILED_AT_POST_SNAPSHOT",
+	VSS_WS_FAILED_AT_BACKUP_COMPLETE:   "VSS_WS_FAILED_AT_BACKUP_COMPLETE",
+	VSS_WS_FAILED_AT_PRE_RESTORE:       "VSS_WS_FAILED_AT_PRE_RESTORE",
+	VSS_WS_FAILED_AT_POST_RESTORE:      "VSS_WS_FAILED_AT_POST_RESTORE",
+	VSS_WS_FAILED_AT_BACKUPSHUTDOWN:    "VSS_WS_FAILED_AT_BACKUPSHUTDOWN",
+}
+
+// Str converts a VssWriterState to a human readable string.
+func (s VssWriterState) Str() string {
+	if i, ok := writerStateToString[s]; ok {
+		return i
+	}
+
+	return "UNKNOWN"
+}
+
+// Failed is true if the writer failed to prepare its data for the snapshot.
+// The files of such a writer are contained in the snapshot, but may not be
+// consistent.
+func (s VssWriterState) Failed() bool {
+	return s >= VSS_WS_FAILED_AT_IDENTIFY && s <= VSS_WS_FAILED_AT_BACKUPSHUTDOWN
+}
+
 // UUID_IVSS defines the GUID of IVssBackupComponents.
 var UUID_IVSS = ole.NewGUID("{665c1d5f-c218-414d-a05d-7fef5f9d5c86}")
 
@@ -547,6 +606,54 @@ func (vss *IVssBackupComponents) BackupComplete() (*IVSSAsync, error) {
 	return vss.convertToVSSAsync(oleIUnknown, err)
 }
 
+// GatherWriterStatus calls the equivalent VSS api.
+func (vss *IVssBackupComponents) GatherWriterStatus() (*IVSSAsync, error) {
+	var oleIUnknown *ole.IUnknown
+	result, _, _ := syscall.Syscall(vss.getVTable().gatherWriterStatus, 2,
+		uintptr(unsafe.Pointer(vss)), uintptr(unsafe.Pointer(&oleIUnknown)), 0)
+
+	err := newVssErrorIfResultNotOK("GatherWriterStatus() failed", HRESULT(result))
+	return vss.convertToVSSAsync(oleIUnknown, err)
+}
+
+// GetWriterStatusCount calls the equivalent VSS api.
+func (vss *IVssBackupComponents) GetWriterStatusCount() (uint32, error) {
+	var count uint32
+	result, _, _ := syscall.Syscall(vss.getVTable().getWriterStatusCount, 2,
+		uintptr(unsafe.Pointer(vss)), uintptr(unsafe.Pointer(&count)), 0)
+
+	return count, newVssErrorIfResultNotOK("GetWriterStatusCount() failed", HRESULT(result))
+}
+
+// GetWriterStatus calls the equivalent VSS api. It returns the name and state
+// of the writer and the error which caused it to fail.
+func (vss *IVssBackupComponents) GetWriterStatus(index uint32) (string, VssWriterState, HRESULT, error) {
+	var instanceID, writerID ole.GUID
+	var name *uint16
+	var state, failure uint32
+
+	result, _, _ := syscall.Syscall9(vss.getVTable().getWriterStatus, 7,
+		uintptr(unsafe.Pointer(vss)), uintptr(index), uintptr(unsafe.Pointer(&instanceID)),
+		uintptr(unsafe.Pointer(&writerID)), uintptr(unsafe.Pointer(&name)),
+		uintptr(unsafe.Pointer(&state)), uintptr(unsafe.Pointer(&failure)), 0, 0)
+
+	if err := newVssErrorIfResultNotOK("GetWriterStatus() failed", HRESULT(result)); err != nil {
+		return "", VSS_WS_UNKNOWN, S_OK, err
+	}
+
+	writerName := ole.BstrToString(name)
+	_ = ole.SysFreeString((*int16)(unsafe.Pointer(name)))
+	return writerName, VssWriterState(state), HRESULT(failure), nil
+}
+
+// FreeWriterStatus calls the equivalent VSS api.
+func (vss *IVssBackupComponents) FreeWriterStatus() error {
+	result, _, _ := syscall.Syscall(vss.getVTable().freeWriterStatus, 1,
+		uintptr(unsafe.Pointer(vss)), 0, 0)
+
+	return newVssErrorIfResultNotOK("FreeWriterStatus() failed", HRESULT(result))
+}
+
 // VssSnapshotProperties defines the properties of a VSS snapshot as part of the VSS api.
 // nolint:structcheck
 type VssSnapshotProperties struct {
@@ -855,7 +962,7 @@ func GetVolumeNameForVolumeMountPoint(mountPoint string) (string, error) {
 
 // NewVssSnapshot creates a new vss snapshot. If creating the snapshots doesn't
 // finish within the timeout an error is returned.
-func NewVssSnapshot(provider string,
+func NewVssSnapshot(provider string, csv VssCSVOptions,
 	volume string, timeout time.Duration, filter VolumeFilter, msgError ErrorHandler) (VssSnapshot, error) {
 	is64Bit, err := isRunningOn64BitWindows()
 	if err != nil {
@@ -933,7 +1040,31 @@ func NewVssSnapshot(provider string,
 		return VssSnapshot{}, err
 	}
 
-	if isSupported, err := iVssBackupComponents.IsVolumeSupported(providerID, volume); err != nil {
+	// cluster shared volumes must be snapshotted by the CSV provider, other
+	// providers cause redirected I/O for the whole cluster
+	var csvProviderID *ole.GUID
+	var csvVolumes []string
+	volumeProviderID := func(volume string) (*ole.GUID, bool, error) {
+		if !isCSVVolume(volume) {
+			return providerID, false, nil
+		}
+		if csvProviderID == nil {
+			id, err := getProviderID(csv.Provider)
+			if err != nil {
+				return nil, false, err
+			}
+			csvProviderID = id
+		}
+		return csvProviderID, true, nil
+	}
+
+	mainProviderID, isCSV, err := volumeProviderID(volume)
+	if err != nil {
+		iVssBackupComponents.Release()
+		return VssSnapshot{}, err
+	}
+
+	if isSupported, err := iVssBackupComponents.IsVolumeSupported(mainProviderID, volume); err != nil {
 		iVssBackupComponents.Release()
 		return VssSnapshot{}, err
 	} else if !isSupported {
@@ -948,10 +1079,13 @@ func NewVssSnapshot(provider string,
 		return VssSnapshot{}, err
 	}
 
-	if err := iVssBackupComponents.AddToSnapshotSet(volume, providerID, &snapshotSetID); err != nil {
+	if err := iVssBackupComponents.AddToSnapshotSet(volume, mainProviderID, &snapshotSetID); err != nil {
 		iVssBackupComponents.Release()
 		return VssSnapshot{}, err
 	}
+	if isCSV {
+		csvVolumes = append(csvVolumes, volume)
+	}
 
 	mountPointInfo := make(map[string]MountPoint)
 
@@ -972,19 +1106,32 @@ func NewVssSnapshot(provider string,
 
 			if !filter(mountPoint) {
 				continue
-			} else if isSupported, err := iVssBackupComponents.IsVolumeSupported(providerID, mountPoint); err != nil {
+			}
+
+			mountPointProviderID, isCSV, err := volumeProviderID(mountPoint)
+			if err != nil {
+				msgError(mountPoint, errors.Errorf(
+					"VSS error: unable to select provider for cluster shared volume %s: %v",
+					mountPoint, err))
+				continue
+			}
+
+			if isSupported, err := iVssBackupComponents.IsVolumeSupported(mountPointProviderID, mountPoint); err != nil {
 				continue
 			} else if !isSupported {
 				continue
 			}
 
 			var mountPointSnapshotSetID ole.GUID
-			err := iVssBackupComponents.AddToSnapshotSet(mountPoint, providerID, &mountPointSnapshotSetID)
+			err = iVssBackupComponents.AddToSnapshotSet(mountPoint, mountPointProviderID, &mountPointSnapshotSetID)
 			if err != nil {
 				iVssBackupComponents.Release()
 
 				return VssSnapshot{}, err
 			}
+			if isCSV {
+				csvVolumes = append(csvVolumes, mountPoint)
+			}
 
 			mountPointInfo[mountPoint] = MountPoint{
 				isSnapshotted: true,
@@ -993,6 +1140,15 @@ func NewVssSnapshot(provider string,
 		}
 	}
 
+	if csv.Lock && len(csvVolumes) > 0 {
+		unlock, err := lockCSVVolumes(csvVolumes, deadline, msgError)
+		if err != nil {
+			iVssBackupComponents.Release()
+			return VssSnapshot{}, err
+		}
+		defer unlock()
+	}
+
 	err = callAsyncFunctionAndWait(iVssBackupComponents.PrepareForBackup, "PrepareForBackup",
 		deadline)
 	if err != nil {
@@ -1012,6 +1168,10 @@ func NewVssSnapshot(provider string,
 		return VssSnapshot{}, err
 	}
 
+	// writers which failed to freeze their data, for example a database, leave
+	// possibly inconsistent files in the snapshot
+	reportWriterErrors(iVssBackupComponents, volume, deadline, msgError)
+
 	var snapshotProperties VssSnapshotProperties
 	err = iVssBackupComponents.GetSnapshotProperties(snapshotSetID, &snapshotProperties)
 	if err != nil {
@@ -1045,6 +1205,37 @@ func NewVssSnapshot(provider string,
 	}, nil
 }
 
+// reportWriterErrors reports all VSS writers which failed while creating the
+// snapshot of volume via msgError.
+func reportWriterErrors(vss *IVssBackupComponents, volume string, deadline time.Time, msgError ErrorHandler) {
+	err := callAsyncFunctionAndWait(vss.GatherWriterStatus, "GatherWriterStatus", deadline)
+	if err != nil {
+		msgError(volume, err)
+		return
+	}
+	defer func() {
+		_ = vss.FreeWriterStatus()
+	}()
+
+	count, err := vss.GetWriterStatusCount()
+	if err != nil {
+		msgError(volume, err)
+		return
+	}
+
+	for i := uint32(0); i < count; i++ {
+		name, state, failure, err := vss.GetWriterStatus(i)
+		if err != nil {
+			msgError(volume, err)
+			continue
+		}
+		if state.Failed() {
+			msgError(volume, errors.Errorf("VSS error: writer %q failed with state %s: %s (%#x)",
+				name, state.Str(), failure.Str(), failure))
+		}
+	}
+}
+
 // Delete deletes the created snapshot.
 func (p *VssSnapshot) Delete() error {
 	var err error
@@ -1091,6 +1282,10 @@ func getProviderID(provider string) (*ole.GUID, error) {
 	case "ms":
 		return ole.NewGUID("{b5946137-7b9f-4925-af80-51abd60b20d5}"), nil
 	}
+	if providerLower == "csv" {
+		provider = csvProviderName
+		providerLower = strings.ToLower(provider)
+	}
 
 	comInterface, err := ole.CreateInstance(CLSID_VSS_COORDINATOR, UIID_IVSS_ADMIN)
 	if err != nil {
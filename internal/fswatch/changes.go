@@ -0,0 +1,116 @@
+package fswatch
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Changes is a set of changed paths. A change of a path also marks all of its
+// parent directories as changed.
+type Changes struct {
+	all   bool
+	paths map[string]struct{}
+	// trees contains the directories whose contents have changed entirely
+	trees map[string]struct{}
+	// fold is set if paths are compared case-insensitively
+	fold bool
+}
+
+// NewChanges returns an empty set of changes.
+func NewChanges() *Changes {
+	return &Changes{
+		paths: make(map[string]struct{}),
+		trees: make(map[string]struct{}),
+	}
+}
+
+func (c *Changes) clean(path string) string {
+	path = filepath.Clean(path)
+	if c.fold {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// AllChanged returns a set of changes which contains all paths.
+func AllChanged() *Changes {
+	c := NewChanges()
+	c.all = true
+	return c
+}
+
+// Add marks path and all of its parent directories as changed.
+func (c *Changes) Add(path string) {
+	path = c.clean(path)
+	for {
+		if _, ok := c.paths[path]; ok {
+			// the parent directories were marked before
+			return
+		}
+		c.paths[path] = struct{}{}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return
+		}
+		path = parent
+	}
+}
+
+// AddTree marks path, everything below it and all of its parent directories
+// as changed. This is used for directories which were created or moved, as
+// their contents may be unrelated to what was stored at path before.
+func (c *Changes) AddTree(path string) {
+	c.Add(path)
+	c.trees[c.clean(path)] = struct{}{}
+}
+
+// Merge adds all changes of other to c. Both must compare paths the same way.
+func (c *Changes) Merge(other *Changes) {
+	c.all = c.all || other.all
+	for path := range other.paths {
+		c.paths[path] = struct{}{}
+	}
+	for path := range other.trees {
+		c.trees[path] = struct{}{}
+	}
+}
+
+// Empty returns true if nothing has changed.
+func (c *Changes) Empty() bool {
+	return !c.all && len(c.paths) == 0
+}
+
+// All returns true if all paths must be treated as changed.
+func (c *Changes) All() bool {
+	return c.all
+}
+
+// Len returns the number of changed paths, including the parent directories
+// of changed paths.
+func (c *Changes) Len() int {
+	return len(c.paths)
+}
+
+// Unchanged returns true if neither path nor anything below it has changed.
+func (c *Changes) Unchanged(path string) bool {
+	if c.all {
+		return false
+	}
+	path = c.clean(path)
+	if _, ok := c.paths[path]; ok {
+		return false
+	}
+
+	for len(c.trees) > 0 {
+		if _, ok := c.trees[path]; ok {
+			return false
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package fswatch
+
+import (
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestChanges(t *testing.T) {
+	root := filepath.FromSlash("/data")
+	c := NewChanges()
+	rtest.Assert(t, c.Empty(), "new changes are not empty")
+	rtest.Assert(t, c.Unchanged(root), "root marked as changed")
+
+	c.Add(filepath.Join(root, "a", "b", "file"))
+	rtest.Assert(t, !c.Empty(), "changes are empty")
+	for _, p := range []string{"", "a", filepath.Join("a", "b"), filepath.Join("a", "b", "file")} {
+		rtest.Assert(t, !c.Unchanged(filepath.Join(root, p)), "path %q not marked as changed", p)
+	}
+	for _, p := range []string{"x", filepath.Join("a", "c"), filepath.Join("a", "b", "other")} {
+		rtest.Assert(t, c.Unchanged(filepath.Join(root, p)), "path %q marked as changed", p)
+	}
+
+	other := NewChanges()
+	other.Add(filepath.Join(root, "x", "y"))
+	c.Merge(other)
+	rtest.Assert(t, !c.Unchanged(filepath.Join(root, "x")), "merged path not marked as changed")
+	rtest.Assert(t, !c.All(), "all paths marked as changed")
+
+	c.Merge(AllChanged())
+	rtest.Assert(t, c.All(), "merging all changes lost the flag")
+	rtest.Assert(t, !c.Unchanged(filepath.Join(root, "z")), "path marked as unchanged")
+}
+
+func TestChangesTree(t *testing.T) {
+	root := filepath.FromSlash("/data")
+	c := NewChanges()
+	c.AddTree(filepath.Join(root, "moved"))
+	c.Add(filepath.Join(root, "other", "file"))
+
+	for _, p := range []string{"", "moved", filepath.Join("moved", "sub"), filepath.Join("moved", "sub", "dir"), "other"} {
+		rtest.Assert(t, !c.Unchanged(filepath.Join(root, p)), "path %q not marked as changed", p)
+	}
+	for _, p := range []string{"moved2", filepath.Join("other", "sub")} {
+		rtest.Assert(t, c.Unchanged(filepath.Join(root, p)), "path %q marked as changed", p)
+	}
+
+	other := NewChanges()
+	other.Merge(c)
+	rtest.Assert(t, !other.Unchanged(filepath.Join(root, "moved", "sub")), "merged tree not marked as changed")
+}
+
+func TestChangesFold(t *testing.T) {
+	root := filepath.FromSlash("/Data")
+	c := NewChanges()
+	c.fold = true
+	c.Add(filepath.Join(root, "Dir", "File"))
+	rtest.Assert(t, !c.Unchanged(filepath.FromSlash("/data/dir")), "path with different case not marked as changed")
+	rtest.Assert(t, c.Unchanged(filepath.FromSlash("/data/other")), "unrelated path marked as changed")
+}
@@ -0,0 +1,4 @@
+// Package fswatch records the files and directories which change below a set
+// of directories, such that a subsequent backup only needs to read the changed
+// parts of the file system.
+package fswatch
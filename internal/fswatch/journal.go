@@ -0,0 +1,63 @@
+package fswatch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/fs"
+)
+
+// QueryJournals returns the current positions in the change journals of all
+// volumes containing one of targets.
+func QueryJournals(targets []string) ([]fs.ChangeJournal, error) {
+	var journals []fs.ChangeJournal
+	seen := make(map[string]struct{})
+	for _, target := range targets {
+		j, err := fs.QueryChangeJournal(target)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", target, err)
+		}
+		if _, ok := seen[j.Volume]; ok {
+			continue
+		}
+		seen[j.Volume] = struct{}{}
+		journals = append(journals, *j)
+	}
+	return journals, nil
+}
+
+// JournalChanges returns the changes recorded in the change journals since the
+// positions in since, which must contain all volumes of current. The paths
+// are compared case-insensitively.
+func JournalChanges(ctx context.Context, since, current []fs.ChangeJournal) (*Changes, error) {
+	changes := NewChanges()
+	changes.fold = true
+
+	for _, cur := range current {
+		var start *fs.ChangeJournal
+		for i := range since {
+			if since[i].Volume == cur.Volume {
+				start = &since[i]
+			}
+		}
+		if start == nil || start.ID != cur.ID {
+			return nil, fmt.Errorf("%v: %w", cur.Volume, fs.ErrChangeJournalIncomplete)
+		}
+
+		count := 0
+		err := fs.ReadChangeJournal(ctx, *start, func(entry fs.ChangeJournalEntry) {
+			count++
+			if entry.IsDir && entry.Added {
+				changes.AddTree(entry.Path)
+			} else {
+				changes.Add(entry.Path)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", cur.Volume, err)
+		}
+		debug.Log("read %d changes of %v since usn %v", count, cur.Volume, start.USN)
+	}
+	return changes, nil
+}
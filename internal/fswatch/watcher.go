@@ -0,0 +1,168 @@
+package fswatch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher records the changes below a set of directories. Directories which
+// are created later on are watched as well.
+type Watcher struct {
+	w    *fsnotify.Watcher
+	warn func(err error)
+
+	mu      sync.Mutex
+	changes *Changes
+	// failed is set once a directory could not be watched. From then on, all
+	// paths are treated as changed.
+	failed bool
+
+	done chan struct{}
+}
+
+// New starts watching the targets, which are either directories or files. For
+// directories, all directories below them are watched as well. warn is called
+// when watching fails, afterwards all paths are treated as changed.
+func New(targets []string, warn func(err error)) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "NewWatcher")
+	}
+
+	w := &Watcher{
+		w:       fw,
+		warn:    warn,
+		changes: NewChanges(),
+		done:    make(chan struct{}),
+	}
+
+	for _, target := range targets {
+		fi, err := os.Lstat(target)
+		if err != nil {
+			_ = fw.Close()
+			return nil, errors.WithStack(err)
+		}
+		if fi.IsDir() {
+			err = w.addRecursive(target)
+		} else {
+			// changes of a file are reported for its directory
+			err = w.add(filepath.Dir(target))
+		}
+		if err != nil {
+			_ = fw.Close()
+			return nil, err
+		}
+	}
+
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) add(dir string) error {
+	if err := w.w.Add(dir); err != nil {
+		return errors.Errorf("unable to watch %v: %v", dir, err)
+	}
+	return nil
+}
+
+// addRecursive watches dir and all directories below it. Symlinks are not
+// followed.
+func (w *Watcher) addRecursive(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// unreadable directories are reported by the backup
+			debug.Log("unable to walk %v: %v", path, err)
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return w.add(path)
+	})
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	for {
+		select {
+		case ev, ok := <-w.w.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev)
+		case err, ok := <-w.w.Errors:
+			if !ok {
+				return
+			}
+			w.handleError(err)
+		}
+	}
+}
+
+func (w *Watcher) handle(ev fsnotify.Event) {
+	debug.Log("event %v", ev)
+	added := false
+	if ev.Has(fsnotify.Create) {
+		if fi, err := os.Lstat(ev.Name); err == nil && fi.IsDir() {
+			if err := w.addRecursive(ev.Name); err != nil {
+				w.handleError(err)
+			}
+			added = true
+		}
+	}
+
+	w.mu.Lock()
+	if added {
+		// the directory may have been moved here
+		w.changes.AddTree(ev.Name)
+	} else {
+		w.changes.Add(ev.Name)
+	}
+	w.mu.Unlock()
+}
+
+func (w *Watcher) handleError(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if errors.Is(err, fsnotify.ErrEventOverflow) {
+		// only the events up to now are lost
+		debug.Log("event queue overflowed, marking all paths as changed")
+		w.changes.all = true
+		return
+	}
+
+	debug.Log("watching failed: %v", err)
+	if !w.failed {
+		w.failed = true
+		w.warn(err)
+	}
+}
+
+// Changes returns the changes since the last call to Changes, or since New for
+// the first call, and starts recording new changes.
+func (w *Watcher) Changes() *Changes {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	c := w.changes
+	w.changes = NewChanges()
+	if w.failed {
+		c.all = true
+	}
+	return c
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	err := w.w.Close()
+	<-w.done
+	return err
+}
@@ -0,0 +1,53 @@
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// waitForChange polls w until path is reported as changed.
+func waitForChange(t *testing.T, w *Watcher, path string) *Changes {
+	t.Helper()
+	changes := NewChanges()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+		changes.Merge(w.Changes())
+		if !changes.Unchanged(path) {
+			return changes
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("change of %v was not reported", path)
+	return nil
+}
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	rtest.OK(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0700))
+	rtest.OK(t, os.MkdirAll(filepath.Join(root, "c"), 0700))
+
+	w, err := New([]string{root}, func(err error) {
+		t.Errorf("unexpected warning: %v", err)
+	})
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, w.Close())
+	}()
+
+	file := filepath.Join(root, "a", "b", "file")
+	rtest.OK(t, os.WriteFile(file, []byte("foo"), 0600))
+	changes := waitForChange(t, w, file)
+	rtest.Assert(t, !changes.Unchanged(filepath.Join(root, "a")), "parent directory not marked as changed")
+	rtest.Assert(t, changes.Unchanged(filepath.Join(root, "c")), "unrelated directory marked as changed")
+
+	// directories created after starting the watcher must be watched as well
+	dir := filepath.Join(root, "c", "new")
+	rtest.OK(t, os.Mkdir(dir, 0700))
+	waitForChange(t, w, dir)
+	file = filepath.Join(dir, "file")
+	rtest.OK(t, os.WriteFile(file, []byte("bar"), 0600))
+	waitForChange(t, w, file)
+}
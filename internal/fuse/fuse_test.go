@@ -116,7 +116,7 @@ func TestFuseFile(t *testing.T) {
 		Size:    filesize,
 		Content: content,
 	}
-	root := &Root{repo: repo, blobCache: bloblru.New(blobCacheSize)}
+	root := &Root{repo: repo, blobCache: bloblru.New(DefaultBlobCacheSize)}
 
 	inode := inodeFromNode(1, node)
 	f, err := newFile(root, inode, node)
@@ -150,7 +150,7 @@ func TestFuseFile(t *testing.T) {
 func TestFuseDir(t *testing.T) {
 	repo := repository.TestRepository(t)
 
-	root := &Root{repo: repo, blobCache: bloblru.New(blobCacheSize)}
+	root := &Root{repo: repo, blobCache: bloblru.New(DefaultBlobCacheSize)}
 
 	node := &restic.Node{
 		Mode:       0755,
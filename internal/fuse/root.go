@@ -19,6 +19,9 @@ type Config struct {
 	Filter        restic.SnapshotFilter
 	TimeTemplate  string
 	PathTemplates []string
+	// BlobCacheSize is the maximum size in bytes of the decrypted file
+	// contents kept in memory. DefaultBlobCacheSize is used if it is zero.
+	BlobCacheSize int
 }
 
 // Root is the root node of the fuse mount of a repository.
@@ -38,17 +41,22 @@ var _ = fs.NodeStringLookuper(&Root{})
 
 const rootInode = 1
 
-// Size of the blob cache. TODO: make this configurable.
-const blobCacheSize = 64 << 20
+// DefaultBlobCacheSize is the default size of the blob cache.
+const DefaultBlobCacheSize = 64 << 20
 
 // NewRoot initializes a new root node from a repository.
 func NewRoot(repo restic.Repository, cfg Config) *Root {
 	debug.Log("NewRoot(), config %v", cfg)
 
+	cacheSize := cfg.BlobCacheSize
+	if cacheSize == 0 {
+		cacheSize = DefaultBlobCacheSize
+	}
+
 	root := &Root{
 		repo:      repo,
 		cfg:       cfg,
-		blobCache: bloblru.New(blobCacheSize),
+		blobCache: bloblru.New(cacheSize),
 	}
 
 	if !cfg.OwnerIsRoot {
@@ -0,0 +1,247 @@
+// Package jobs implements a registry of the backup jobs running on a host. It
+// prevents two jobs from backing up the same paths to the same repository at
+// the same time by queueing the later job until the earlier one has finished.
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// Job states.
+const (
+	StateQueued  = "queued"
+	StateRunning = "running"
+)
+
+// errLocked is returned by tryLockFile if the file is locked already.
+var errLocked = errors.New("file is locked")
+
+// Job describes a backup job.
+type Job struct {
+	PID        int        `json:"pid"`
+	Repository string     `json:"repository"`
+	Paths      []string   `json:"paths"`
+	State      string     `json:"state"`
+	Queued     time.Time  `json:"queued"`
+	Started    *time.Time `json:"started,omitempty"`
+}
+
+// key identifies the jobs which must not run concurrently.
+func (j Job) key() string {
+	paths := append([]string(nil), j.Paths...)
+	sort.Strings(paths)
+	h := sha256.Sum256([]byte(j.Repository + "\x00" + strings.Join(paths, "\x00")))
+	return hex.EncodeToString(h[:])
+}
+
+// Registry stores the jobs in a directory. Each job holds a lock on its job
+// file while it exists, and the running job also on the lock file for its
+// repository and paths. As locks are released by the operating system when a
+// process exits, job files of crashed processes are detected reliably.
+type Registry struct {
+	dir string
+	// PollInterval is the interval in which a queued job checks whether it
+	// can start.
+	PollInterval time.Duration
+}
+
+// New returns a registry which stores the jobs in dir.
+func New(dir string) (*Registry, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &Registry{dir: dir, PollInterval: time.Second}, nil
+}
+
+// Handle represents a registered job.
+type Handle struct {
+	job     Job
+	jobFile *os.File
+	lock    *os.File
+}
+
+// Acquire registers the job and waits until no other job is backing up the
+// same paths to the same repository. While waiting, the job is listed as
+// queued and queued is called once with the job which is currently running.
+func (r *Registry) Acquire(ctx context.Context, job Job, queued func(running Job)) (*Handle, error) {
+	job.PID = os.Getpid()
+	job.State = StateQueued
+	job.Queued = time.Now()
+
+	jobFile, err := os.CreateTemp(r.dir, "job-*.json")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := tryLockFile(jobFile); err != nil {
+		_ = jobFile.Close()
+		_ = os.Remove(jobFile.Name())
+		return nil, err
+	}
+	h := &Handle{job: job, jobFile: jobFile}
+	if err := h.write(); err != nil {
+		_ = h.Release()
+		return nil, err
+	}
+
+	lock, err := fs.OpenFile(filepath.Join(r.dir, job.key()+".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		_ = h.Release()
+		return nil, errors.WithStack(err)
+	}
+	h.lock = lock
+
+	notified := false
+	for {
+		err := tryLockFile(lock)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLocked) {
+			_ = h.Release()
+			return nil, err
+		}
+
+		if !notified && queued != nil {
+			notified = true
+			if running, ok := r.findRunning(job.key()); ok {
+				queued(running)
+			} else {
+				queued(Job{})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = h.Release()
+			return nil, ctx.Err()
+		case <-time.After(r.PollInterval):
+		}
+	}
+
+	h.job.State = StateRunning
+	now := time.Now()
+	h.job.Started = &now
+	if err := h.write(); err != nil {
+		_ = h.Release()
+		return nil, err
+	}
+	return h, nil
+}
+
+// write stores the job in the job file.
+func (h *Handle) write() error {
+	buf, err := json.Marshal(h.job)
+	if err != nil {
+		return err
+	}
+	if err := h.jobFile.Truncate(0); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = h.jobFile.WriteAt(buf, 0)
+	return errors.WithStack(err)
+}
+
+// Release removes the job from the registry and allows queued jobs to start.
+func (h *Handle) Release() error {
+	// open files cannot be removed on Windows
+	err := h.jobFile.Close()
+	// List may have removed the job file already after it was unlocked
+	if rerr := os.Remove(h.jobFile.Name()); err == nil && !errors.Is(rerr, os.ErrNotExist) {
+		err = rerr
+	}
+	if h.lock != nil {
+		if cerr := h.lock.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return errors.WithStack(err)
+}
+
+// List returns the registered jobs, running jobs first. Job files left
+// behind by processes which no longer exist are removed.
+func (r *Registry) List() ([]Job, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var jobs []Job
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "job-") || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		job, ok := r.load(filepath.Join(r.dir, entry.Name()))
+		if ok {
+			jobs = append(jobs, job)
+		}
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].State != jobs[j].State {
+			return jobs[i].State == StateRunning
+		}
+		return jobs[i].Queued.Before(jobs[j].Queued)
+	})
+	return jobs, nil
+}
+
+// load reads the job file at filename. Stale job files are removed.
+func (r *Registry) load(filename string) (Job, bool) {
+	f, err := fs.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		debug.Log("unable to open job file %v: %v", filename, err)
+		return Job{}, false
+	}
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		_ = f.Close()
+		return Job{}, false
+	}
+
+	lockErr := tryLockFile(f)
+	_ = f.Close()
+	if lockErr == nil {
+		// The job file is not locked by its process. The process writes the
+		// job file only after locking it, thus it has exited if the file is
+		// not empty.
+		if len(buf) > 0 {
+			debug.Log("removing stale job file %v", filename)
+			_ = os.Remove(filename)
+		}
+		return Job{}, false
+	}
+
+	var job Job
+	if err := json.Unmarshal(buf, &job); err != nil {
+		debug.Log("unable to parse job file %v: %v", filename, err)
+		return Job{}, false
+	}
+	return job, true
+}
+
+// findRunning returns the running job with the given key.
+func (r *Registry) findRunning(key string) (Job, bool) {
+	jobs, err := r.List()
+	if err != nil {
+		return Job{}, false
+	}
+	for _, job := range jobs {
+		if job.State == StateRunning && job.key() == key {
+			return job, true
+		}
+	}
+	return Job{}, false
+}
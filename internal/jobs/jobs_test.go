@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	r, err := New(t.TempDir())
+	rtest.OK(t, err)
+	r.PollInterval = 10 * time.Millisecond
+	return r
+}
+
+func TestAcquireQueuesSamePaths(t *testing.T) {
+	r := newTestRegistry(t)
+	ctx := context.Background()
+
+	first, err := r.Acquire(ctx, Job{Repository: "/repo", Paths: []string{"/a", "/b"}}, nil)
+	rtest.OK(t, err)
+
+	// a job for other paths is not blocked
+	other, err := r.Acquire(ctx, Job{Repository: "/repo", Paths: []string{"/c"}}, nil)
+	rtest.OK(t, err)
+	rtest.OK(t, other.Release())
+
+	queued := make(chan Job, 1)
+	acquired := make(chan *Handle)
+	go func() {
+		// the order of the paths does not matter
+		h, err := r.Acquire(ctx, Job{Repository: "/repo", Paths: []string{"/b", "/a"}}, func(running Job) {
+			queued <- running
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- h
+	}()
+
+	running := <-queued
+	rtest.Equals(t, os.Getpid(), running.PID)
+	rtest.Equals(t, StateRunning, running.State)
+
+	jobs, err := r.List()
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(jobs))
+	rtest.Equals(t, StateRunning, jobs[0].State)
+	rtest.Equals(t, StateQueued, jobs[1].State)
+
+	select {
+	case <-acquired:
+		t.Fatal("queued job started while the first job is running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	rtest.OK(t, first.Release())
+	second := <-acquired
+	rtest.Assert(t, second != nil, "queued job did not start")
+
+	jobs, err = r.List()
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(jobs))
+	rtest.Equals(t, StateRunning, jobs[0].State)
+	rtest.OK(t, second.Release())
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	r := newTestRegistry(t)
+
+	first, err := r.Acquire(context.Background(), Job{Repository: "/repo", Paths: []string{"/a"}}, nil)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, first.Release())
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = r.Acquire(ctx, Job{Repository: "/repo", Paths: []string{"/a"}}, func(Job) {
+		cancel()
+	})
+	rtest.Equals(t, context.Canceled, err)
+
+	jobs, err := r.List()
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(jobs))
+}
+
+func TestListRemovesStaleJobs(t *testing.T) {
+	r := newTestRegistry(t)
+
+	// job file of a process which exited without removing it
+	stale := filepath.Join(r.dir, "job-stale.json")
+	rtest.OK(t, os.WriteFile(stale, []byte(`{"pid":1,"state":"running"}`), 0600))
+
+	jobs, err := r.List()
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(jobs))
+	_, err = os.Stat(stale)
+	rtest.Assert(t, os.IsNotExist(err), "stale job file was not removed")
+}
@@ -0,0 +1,26 @@
+//go:build aix
+// +build aix
+
+package jobs
+
+import (
+	"io"
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/unix"
+)
+
+// tryLockFile acquires an exclusive lock on f without waiting. The lock is
+// released when f is closed. errLocked is returned if f is locked already.
+//
+// As AIX does not support flock, a record lock is used instead, which does
+// not conflict with other locks held by the same process.
+func tryLockFile(f *os.File) error {
+	lk := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart}
+	err := unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lk)
+	if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EACCES) {
+		return errLocked
+	}
+	return errors.WithStack(err)
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !aix
+// +build !windows,!aix
+
+package jobs
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/unix"
+)
+
+// tryLockFile acquires an exclusive lock on f without waiting. The lock is
+// released when f is closed. errLocked is returned if f is locked already.
+func tryLockFile(f *os.File) error {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return errLocked
+	}
+	return errors.WithStack(err)
+}
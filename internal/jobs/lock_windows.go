@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package jobs
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/windows"
+)
+
+// tryLockFile acquires an exclusive lock on f without waiting. The lock is
+// released when f is closed. errLocked is returned if f is locked already.
+//
+// Locks on Windows are mandatory, thus a byte far beyond the end of the file
+// is locked such that the content can still be read by other processes.
+func tryLockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: 0x7fffffff}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLocked
+	}
+	return errors.WithStack(err)
+}
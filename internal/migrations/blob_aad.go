@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func init() {
+	register(&BlobAAD{})
+}
+
+// BlobAAD binds the encryption of all blobs to their type and ID.
+type BlobAAD struct{}
+
+func (*BlobAAD) Name() string {
+	return "blob_aad"
+}
+
+func (*BlobAAD) Desc() string {
+	return "bind the encryption of blobs to their type and ID, rewrites all pack files"
+}
+
+func (*BlobAAD) Check(_ context.Context, repo restic.Repository) (bool, string, error) {
+	cfg := repo.Config()
+	switch {
+	case cfg.Version < 3:
+		return false, "repository must be upgraded to version 3 first", nil
+	case cfg.HasFeature(restic.FeatureBlobAAD):
+		return false, "blobs are already bound to their type and ID", nil
+	}
+	return true, "", nil
+}
+
+func (*BlobAAD) RepoCheck() bool {
+	return true
+}
+
+func (*BlobAAD) Apply(ctx context.Context, repo restic.Repository) error {
+	r := repo.(*repository.Repository)
+	if err := r.LoadIndex(ctx, nil); err != nil {
+		return err
+	}
+	return repository.EnableBlobAAD(ctx, r, &progress.NoopPrinter{})
+}
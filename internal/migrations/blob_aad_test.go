@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestBlobAAD(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 3)
+	m := &BlobAAD{}
+
+	ok, _, err := m.Check(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "migration check returned false")
+
+	rtest.OK(t, m.Apply(context.Background(), repo))
+	cfg, err := restic.LoadConfig(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, cfg.HasFeature(restic.FeatureBlobAAD), "feature missing after migration")
+
+	ok, reason, err := m.Check(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "migration check returned true after migration")
+	rtest.Assert(t, reason != "", "missing reason")
+}
+
+func TestBlobAADFromV2(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 2)
+	ok, reason, err := (&BlobAAD{}).Check(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "migration check returned true for version 2")
+	rtest.Assert(t, reason != "", "missing reason")
+}
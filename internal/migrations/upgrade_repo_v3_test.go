@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestUpgradeRepoV3(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 2)
+	m := &UpgradeRepoV3{}
+
+	ok, _, err := m.Check(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "migration check returned false")
+
+	rtest.OK(t, m.Apply(context.Background(), repo))
+	cfg, err := restic.LoadConfig(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(3), cfg.Version)
+}
+
+func TestUpgradeRepoV3FromV1(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 1)
+	ok, reason, err := (&UpgradeRepoV3{}).Check(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "migration check returned true for version 1")
+	rtest.Assert(t, reason != "", "missing reason")
+}
+
+func TestUpgradeRepoV3ResticCompat(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 2)
+	rtest.OK(t, repository.EnableResticCompat(context.Background(), repo))
+	ok, reason, err := (&UpgradeRepoV3{}).Check(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "migration check returned true for restic compatible repository")
+	rtest.Assert(t, reason != "", "missing reason")
+}
@@ -211,6 +211,9 @@ func (o Options) Apply(ns string, dst interface{}) error {
 
 			v.Field(i).SetInt(int64(d))
 
+		case "SecretString":
+			v.Field(i).Set(reflect.ValueOf(NewSecretString(value)))
+
 		default:
 			panic("type " + v.Type().Field(i).Type.Name() + " not handled")
 		}
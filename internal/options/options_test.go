@@ -332,3 +332,21 @@ func TestAppendAllOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestOptionsApplySecretString(t *testing.T) {
+	var dst struct {
+		Key SecretString `option:"key"`
+	}
+
+	err := Options{"key": "s3cr3t"}.Apply("", &dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dst.Key.Unwrap() != "s3cr3t" {
+		t.Fatalf("wrong secret, want %q, got %q", "s3cr3t", dst.Key.Unwrap())
+	}
+	if dst.Key.String() == "s3cr3t" {
+		t.Fatalf("secret is not masked")
+	}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// blobAADMode determines whether the encryption of blobs is bound to their
+// type and ID by passing both as additional data to the AEAD. This prevents an
+// attacker with access to the storage from substituting a blob with another
+// blob encrypted using the same key. The pack ID cannot be included, as it is
+// the hash of the encrypted pack.
+type blobAADMode uint8
+
+const (
+	blobAADOff blobAADMode = iota
+	// blobAADTransition binds new blobs, but still accepts unbound blobs.
+	blobAADTransition
+	blobAADOn
+)
+
+func blobAADModeFor(cfg restic.Config) blobAADMode {
+	switch {
+	case cfg.HasFeature(restic.FeatureBlobAAD):
+		return blobAADOn
+	case cfg.HasFeature(restic.FeatureBlobAADTransition):
+		return blobAADTransition
+	}
+	return blobAADOff
+}
+
+// additionalData returns the additional data for encrypting the blob h.
+func (m blobAADMode) additionalData(h restic.BlobHandle) []byte {
+	if m == blobAADOff {
+		return nil
+	}
+	ad := make([]byte, 1+len(h.ID))
+	ad[0] = byte(h.Type)
+	copy(ad[1:], h.ID[:])
+	return ad
+}
+
+// open decrypts the ciphertext of blob h and appends the plaintext to dst.
+func (m blobAADMode) open(key *crypto.Key, dst []byte, h restic.BlobHandle, nonce, ciphertext []byte) ([]byte, error) {
+	// Open verifies the MAC before writing to dst, thus retrying is possible
+	// even if dst and ciphertext overlap
+	plaintext, err := key.Open(dst, nonce, ciphertext, m.additionalData(h))
+	if m == blobAADTransition && errors.Is(err, crypto.ErrUnauthenticated) {
+		return key.Open(dst, nonce, ciphertext, nil)
+	}
+	return plaintext, err
+}
+
+// BlobAdditionalData returns the additional data which authenticates the
+// blob h in a repository using cfg, or nil if the repository does not bind
+// blobs to their type and ID.
+func BlobAdditionalData(cfg restic.Config, h restic.BlobHandle) []byte {
+	return blobAADModeFor(cfg).additionalData(h)
+}
+
+// EnableBlobAAD migrates the repository to bind the encryption of all blobs to
+// their type and ID. All pack files are rewritten for this. If the migration is
+// interrupted, it can be continued by calling EnableBlobAAD again. The caller
+// must hold an exclusive lock and the index must be loaded.
+func EnableBlobAAD(ctx context.Context, repo *Repository, printer progress.Printer) error {
+	cfg := repo.Config()
+	if cfg.Version < 3 {
+		return errors.Errorf("binding blobs requires repository version 3, but repository has version %v", cfg.Version)
+	}
+	if cfg.HasFeature(restic.FeatureBlobAAD) {
+		return nil
+	}
+
+	if !cfg.HasFeature(restic.FeatureBlobAADTransition) {
+		printer.P("marking repository as being migrated\n")
+		cfg.Features = append(append([]string(nil), cfg.Features...), restic.FeatureBlobAADTransition)
+		if err := saveConfig(ctx, repo, cfg); err != nil {
+			return err
+		}
+	}
+
+	// keep all blobs, the migration must not remove any data
+	keepAll := func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		return repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+			usedBlobs.Insert(pb.BlobHandle)
+		})
+	}
+	plan, err := PlanPrune(ctx, PruneOptions{
+		MaxUnusedBytes: func(_ uint64) uint64 { return 0 },
+		MaxRepackBytes: ^uint64(0),
+		RepackAll:      true,
+	}, repo, keepAll, printer)
+	if err != nil {
+		return err
+	}
+	if err := plan.Execute(ctx, printer); err != nil {
+		return err
+	}
+
+	features := make([]string, 0, len(cfg.Features))
+	for _, f := range cfg.Features {
+		if f != restic.FeatureBlobAADTransition {
+			features = append(features, f)
+		}
+	}
+	cfg.Features = append(features, restic.FeatureBlobAAD)
+	return saveConfig(ctx, repo, cfg)
+}
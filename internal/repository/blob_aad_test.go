@@ -0,0 +1,53 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func TestEnableBlobAAD(t *testing.T) {
+	ctx := context.TODO()
+	repo, be := repository.TestRepositoryWithVersion(t, 3)
+	createRandomBlobs(t, repo, 10, 0.5, true)
+
+	var blobs []restic.BlobHandle
+	rtest.OK(t, repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+		blobs = append(blobs, pb.BlobHandle)
+	}))
+
+	rtest.OK(t, repository.EnableBlobAAD(ctx, repo, &progress.NoopPrinter{}))
+
+	repo = repository.TestOpenBackend(t, be)
+	rtest.Assert(t, repo.Config().HasFeature(restic.FeatureBlobAAD), "feature missing after migration")
+	rtest.Assert(t, !repo.Config().HasFeature(restic.FeatureBlobAADTransition), "transition feature not removed")
+	checker.TestCheckRepo(t, repo, true)
+
+	rtest.OK(t, repo.LoadIndex(ctx, nil))
+	for _, h := range blobs {
+		_, err := repo.LoadBlob(ctx, h.Type, h.ID, nil)
+		rtest.OK(t, err)
+	}
+
+	// the blobs can only be decrypted together with their type and ID
+	rtest.OK(t, repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+		pack, err := repo.LoadRaw(ctx, restic.PackFile, pb.PackID)
+		rtest.OK(t, err)
+		buf := pack[pb.Offset : pb.Offset+pb.Length]
+		nonce, ciphertext := buf[:repo.Key().NonceSize()], buf[repo.Key().NonceSize():]
+
+		_, err = repo.Key().Open(nil, nonce, ciphertext, nil)
+		rtest.Equals(t, crypto.ErrUnauthenticated, err)
+		other := restic.BlobHandle{ID: restic.NewRandomID(), Type: pb.Type}
+		_, err = repo.Key().Open(nil, nonce, ciphertext, repository.BlobAdditionalData(repo.Config(), other))
+		rtest.Equals(t, crypto.ErrUnauthenticated, err)
+		_, err = repo.Key().Open(nil, nonce, ciphertext, repository.BlobAdditionalData(repo.Config(), pb.BlobHandle))
+		rtest.OK(t, err)
+	}))
+}
@@ -93,7 +93,7 @@ func checkPackInner(ctx context.Context, r *Repository, id restic.ID, blobs []re
 		hrd := hashing.NewReader(rd, sha256.New())
 		bufRd.Reset(hrd)
 
-		it := newPackBlobIterator(id, newBufReader(bufRd), 0, blobs, r.Key(), dec)
+		it := newPackBlobIterator(id, newBufReader(bufRd), 0, blobs, r.Key(), blobAADModeFor(r.Config()), dec)
 		for {
 			val, err := it.Next()
 			if err == errPackEOF {
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/repository/index"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// CompactIndex merges the index files which are not full, for example those
+// written by many small backups and delta indexes, into as few index files as
+// possible. The index is only rewritten if at least two index files can be
+// merged. It returns the number of index files before and after compacting.
+func CompactIndex(ctx context.Context, repo *Repository, printer progress.Printer) (before, after int, err error) {
+	printer.P("loading indexes...\n")
+	repo.clearIndex()
+
+	sparse := 0
+	bar := printer.NewCounter("index files loaded")
+	err = repo.idx.Load(ctx, repo, bar, func(_ restic.ID, idx *index.Index, _ bool, err error) error {
+		if err != nil {
+			return err
+		}
+		before++
+		if !index.IndexFull(idx) || len(idx.ObsoletePacks()) > 0 {
+			sparse++
+		}
+		return nil
+	})
+	if err != nil {
+		repo.clearIndex()
+		return 0, 0, err
+	}
+
+	if sparse < 2 {
+		printer.V("index consists of %d files, nothing to compact\n", before)
+		return before, before, nil
+	}
+
+	err = rewriteIndexFiles(ctx, repo, repo, restic.NewIDSet(), nil, nil, printer)
+	// drop outdated in-memory index
+	repo.clearIndex()
+	if err != nil {
+		return before, 0, err
+	}
+
+	err = repo.List(ctx, restic.IndexFile, func(_ restic.ID, _ int64) error {
+		after++
+		return nil
+	})
+	return before, after, err
+}
@@ -0,0 +1,34 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/repository"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func TestCompactIndex(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 0)
+	for i := 0; i < 5; i++ {
+		createRandomBlobs(t, repo, 3, 0, true)
+	}
+	indexes := len(listIndex(t, repo))
+	rtest.Assert(t, indexes >= 5, "expected at least 5 index files, got %d", indexes)
+
+	before, after, err := repository.CompactIndex(context.TODO(), repo, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, indexes, before)
+	rtest.Equals(t, 1, after)
+	rtest.Equals(t, 1, len(listIndex(t, repo)))
+
+	// a compact index is left untouched
+	before, after, err = repository.CompactIndex(context.TODO(), repo, &progress.NoopPrinter{})
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, before)
+	rtest.Equals(t, 1, after)
+
+	checker.TestCheckRepo(t, repo, true)
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/restic"
+)
+
+type saveConfigError struct {
+	UploadNewConfigError   error
+	ReuploadOldConfigError error
+
+	BackupFilePath string
+}
+
+func (err *saveConfigError) Error() string {
+	if err.ReuploadOldConfigError != nil {
+		return fmt.Sprintf("error uploading config (%v), re-uploading old config filed failed as well (%v), but there is a backup of the config file in %v", err.UploadNewConfigError, err.ReuploadOldConfigError, err.BackupFilePath)
+	}
+
+	return fmt.Sprintf("error uploading config (%v), re-uploaded old config was successful, there is a backup of the config file in %v", err.UploadNewConfigError, err.BackupFilePath)
+}
+
+func (err *saveConfigError) Unwrap() error {
+	// consider the original upload error as the primary cause
+	return err.UploadNewConfigError
+}
+
+// replaceConfig replaces the config file of the repository with cfg.
+func replaceConfig(ctx context.Context, repo *Repository, cfg restic.Config) error {
+	h := backend.Handle{Type: backend.ConfigFile}
+	if !repo.be.HasAtomicReplace() {
+		// remove the original file for backends which do not support atomic overwriting
+		err := repo.be.Remove(ctx, h)
+		if err != nil {
+			return fmt.Errorf("remove config failed: %w", err)
+		}
+	}
+
+	err := restic.SaveConfig(ctx, repo, cfg)
+	if err != nil {
+		return fmt.Errorf("save new config file failed: %w", err)
+	}
+	return nil
+}
+
+// saveConfig replaces the config of the repository. All changes of the config
+// of an existing repository must use this function. While the new config is
+// saved, a copy of the original config file is kept in a temporary directory.
+// If saving fails, the original config file is uploaded again, the returned
+// error contains the location of the copy.
+func saveConfig(ctx context.Context, repo *Repository, cfg restic.Config) error {
+	tempdir, err := os.MkdirTemp("", "restic-config-backup-")
+	if err != nil {
+		return fmt.Errorf("create temp dir failed: %w", err)
+	}
+
+	// read raw config file and save it to a temp dir, just in case
+	rawConfigFile, err := repo.LoadRaw(ctx, restic.ConfigFile, restic.ID{})
+	if err != nil {
+		_ = os.Remove(tempdir)
+		return fmt.Errorf("load config file failed: %w", err)
+	}
+
+	backupFileName := filepath.Join(tempdir, "config")
+	err = os.WriteFile(backupFileName, rawConfigFile, 0600)
+	if err != nil {
+		_ = os.RemoveAll(tempdir)
+		return fmt.Errorf("write config file backup to %v failed: %w", tempdir, err)
+	}
+
+	err = replaceConfig(ctx, repo, cfg)
+	if err != nil {
+		// build an error we can return to the caller
+		repoError := &saveConfigError{
+			UploadNewConfigError: err,
+			BackupFilePath:       backupFileName,
+		}
+
+		// try contingency methods, reupload the original file
+		h := backend.Handle{Type: backend.ConfigFile}
+		_ = repo.be.Remove(ctx, h)
+		err = repo.be.Save(ctx, h, backend.NewByteReader(rawConfigFile, repo.be.Hasher()))
+		if err != nil {
+			repoError.ReuploadOldConfigError = err
+		}
+
+		return repoError
+	}
+
+	_ = os.Remove(backupFileName)
+	_ = os.Remove(tempdir)
+	repo.setConfig(cfg)
+	return nil
+}
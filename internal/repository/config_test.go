@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// failOnceBackend fails the next save of the config file if failConfigSave is
+// set.
+type failOnceBackend struct {
+	backend.Backend
+
+	mu             sync.Mutex
+	failConfigSave bool
+}
+
+func (be *failOnceBackend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	be.mu.Lock()
+	fail := h.Type == backend.ConfigFile && be.failConfigSave
+	if fail {
+		be.failConfigSave = false
+	}
+	be.mu.Unlock()
+
+	if fail {
+		return errors.New("failure induced for testing")
+	}
+	return be.Backend.Save(ctx, h, rd)
+}
+
+func TestSaveConfigFailure(t *testing.T) {
+	ctx := context.TODO()
+	// the memory backend cannot replace files atomically, thus the config is
+	// removed before the new one is saved
+	be := &failOnceBackend{Backend: TestBackend(t)}
+	repo, _ := TestRepositoryWithBackend(t, be, 2, Options{})
+
+	be.failConfigSave = true
+	err := EnableResticCompat(ctx, repo)
+	rtest.Assert(t, err != nil, "missing error for failed config upload")
+
+	var saveErr *saveConfigError
+	rtest.Assert(t, errors.As(err, &saveErr), "unexpected error %v", err)
+	rtest.Assert(t, saveErr.ReuploadOldConfigError == nil, "re-upload of old config failed: %v", saveErr.ReuploadOldConfigError)
+	rtest.OK(t, os.Remove(saveErr.BackupFilePath))
+	rtest.OK(t, os.Remove(filepath.Dir(saveErr.BackupFilePath)))
+
+	// the original config is still in place
+	for _, cfg := range []restic.Config{repo.Config(), TestOpenBackend(t, be).Config()} {
+		rtest.Assert(t, !cfg.ResticCompat, "config was changed")
+	}
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// MaxDictionaryBlobSize is the maximum size of data blobs which are compressed
+// using the compression dictionary. Larger blobs hardly benefit from it.
+const MaxDictionaryBlobSize = 64 * 1024
+
+// DefaultDictionarySize is the default size of a trained dictionary.
+const DefaultDictionarySize = 64 * 1024
+
+const (
+	// length of the byte sequences whose frequency is counted
+	dictKmerLength = 8
+	// length of the segments the dictionary is built from
+	dictSegmentLength = 256
+)
+
+// TrainDictionary builds a zstd dictionary of about size bytes from the given
+// samples. The dictionary content consists of the sample segments which
+// contain the most byte sequences that are common across the samples.
+func TrainDictionary(samples [][]byte, size int) ([]byte, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("no samples for training the dictionary")
+	}
+	if size < dictSegmentLength {
+		return nil, errors.Errorf("dictionary size must be at least %d bytes", dictSegmentLength)
+	}
+
+	history := selectDictionarySegments(samples, size)
+	if len(history) < dictKmerLength {
+		return nil, errors.New("samples are too small for training the dictionary")
+	}
+
+	// zstd reserves IDs below 32768
+	id := uint32(32768 + rand.Int31n(1<<31-32768))
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: samples,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("building dictionary failed: %w", err)
+	}
+	return dict, nil
+}
+
+// DictionaryID returns the ID of a zstd dictionary.
+func DictionaryID(dict []byte) (uint32, error) {
+	// magic number followed by the dictionary ID
+	if len(dict) < 8 || binary.LittleEndian.Uint32(dict) != 0xEC30A437 {
+		return 0, errors.New("invalid dictionary")
+	}
+	return binary.LittleEndian.Uint32(dict[4:]), nil
+}
+
+type dictSegment struct {
+	sample, offset int
+	score          int
+}
+
+type segmentHeap []dictSegment
+
+func (h segmentHeap) Len() int            { return len(h) }
+func (h segmentHeap) Less(i, j int) bool  { return h[i].score > h[j].score }
+func (h segmentHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *segmentHeap) Push(x interface{}) { *h = append(*h, x.(dictSegment)) }
+func (h *segmentHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// selectDictionarySegments greedily selects the segments of the samples whose
+// byte sequences occur in the most samples, until size bytes are selected.
+// Sequences which are already contained in a selected segment do not count
+// again. The best segments are placed at the end of the result, as zstd can
+// reference them using the shortest offsets.
+func selectDictionarySegments(samples [][]byte, size int) []byte {
+	kmer := func(buf []byte, i int) uint64 {
+		return binary.LittleEndian.Uint64(buf[i:])
+	}
+
+	// count in how many samples each sequence occurs
+	freq := make(map[uint64]int)
+	for _, sample := range samples {
+		seen := make(map[uint64]struct{})
+		for i := 0; i+dictKmerLength <= len(sample); i++ {
+			k := kmer(sample, i)
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				freq[k]++
+			}
+		}
+	}
+
+	segment := func(s dictSegment) []byte {
+		sample := samples[s.sample]
+		end := s.offset + dictSegmentLength
+		if end > len(sample) {
+			end = len(sample)
+		}
+		return sample[s.offset:end]
+	}
+	score := func(s dictSegment) int {
+		buf := segment(s)
+		seen := make(map[uint64]struct{})
+		total := 0
+		for i := 0; i+dictKmerLength <= len(buf); i++ {
+			k := kmer(buf, i)
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			// sequences occurring in a single sample are useless
+			if f := freq[k]; f > 1 {
+				total += f
+			}
+		}
+		return total
+	}
+
+	h := segmentHeap{}
+	for i, sample := range samples {
+		for offset := 0; offset+dictKmerLength <= len(sample); offset += dictSegmentLength {
+			s := dictSegment{sample: i, offset: offset}
+			s.score = score(s)
+			if s.score > 0 {
+				h = append(h, s)
+			}
+		}
+	}
+	heap.Init(&h)
+
+	var selected [][]byte
+	total := 0
+	for h.Len() > 0 && total < size {
+		s := heap.Pop(&h).(dictSegment)
+		// scores only decrease, so the segment is the best one if its updated
+		// score is still at least as high as the score of the next candidate
+		s.score = score(s)
+		if s.score == 0 {
+			continue
+		}
+		if h.Len() > 0 && s.score < h[0].score {
+			heap.Push(&h, s)
+			continue
+		}
+
+		buf := segment(s)
+		if total+len(buf) > size {
+			buf = buf[:size-total]
+		}
+		selected = append(selected, buf)
+		total += len(buf)
+		for i := 0; i+dictKmerLength <= len(buf); i++ {
+			delete(freq, kmer(buf, i))
+		}
+	}
+
+	history := make([]byte, 0, total)
+	for i := len(selected) - 1; i >= 0; i-- {
+		history = append(history, selected[i]...)
+	}
+	return history
+}
+
+// AddCompressionDictionary stores dict in the repository config. Afterwards,
+// the dictionary is used to compress new tree blobs and small data blobs.
+// Dictionaries can only be added to repositories using at least version 3.
+// The repository must be opened again to use the new dictionary.
+func AddCompressionDictionary(ctx context.Context, repo *Repository, dict []byte) error {
+	cfg := repo.Config()
+	if cfg.Version < 3 {
+		return errors.Errorf("compression dictionaries require repository version 3, but repository has version %v", cfg.Version)
+	}
+	if _, err := DictionaryID(dict); err != nil {
+		return err
+	}
+	// make sure the dictionary is usable
+	if _, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dict)); err != nil {
+		return fmt.Errorf("invalid dictionary: %w", err)
+	}
+
+	dicts := make([][]byte, 0, len(cfg.CompressionDictionaries)+1)
+	dicts = append(dicts, cfg.CompressionDictionaries...)
+	cfg.CompressionDictionaries = append(dicts, dict)
+	return saveConfig(ctx, repo, cfg)
+}
+
+// SampleDictionaryBlobs loads up to count randomly selected tree blobs and
+// data blobs of at most MaxDictionaryBlobSize bytes, which can be used to
+// train a compression dictionary. The index must already be loaded.
+func SampleDictionaryBlobs(ctx context.Context, repo *Repository, count int) ([][]byte, error) {
+	if count <= 0 {
+		return nil, errors.New("sample count must be positive")
+	}
+
+	// reservoir sampling to select blobs uniformly at random
+	var candidates []restic.BlobHandle
+	seen := restic.NewBlobSet()
+	n := 0
+	err := repo.ListBlobs(ctx, func(pb restic.PackedBlob) {
+		if seen.Has(pb.BlobHandle) {
+			return
+		}
+		seen.Insert(pb.BlobHandle)
+		if pb.Type == restic.DataBlob && int(pb.DataLength()) > MaxDictionaryBlobSize {
+			return
+		}
+
+		n++
+		if len(candidates) < count {
+			candidates = append(candidates, pb.BlobHandle)
+		} else if i := rand.Intn(n); i < count {
+			candidates[i] = pb.BlobHandle
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([][]byte, 0, len(candidates))
+	for _, h := range candidates {
+		buf, err := repo.LoadBlob(ctx, h.Type, h.ID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("loading blob %v failed: %w", h, err)
+		}
+		samples = append(samples, buf)
+	}
+	return samples, nil
+}
+
+// CompressedSize returns the total size of the samples after compressing
+// each of them individually, using dict unless it is nil.
+func CompressedSize(samples [][]byte, dict []byte) (int, error) {
+	opts := []zstd.EOption{zstd.WithEncoderCRC(false), zstd.WithWindowSize(512 * 1024)}
+	if dict != nil {
+		opts = append(opts, zstd.WithEncoderDict(dict))
+	}
+	enc, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = enc.Close()
+	}()
+
+	total := 0
+	var buf []byte
+	for _, sample := range samples {
+		buf = enc.EncodeAll(sample, buf[:0])
+		total += len(buf)
+	}
+	return total, nil
+}
@@ -0,0 +1,72 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
+)
+
+func dictionarySamples(n int) [][]byte {
+	samples := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		samples = append(samples, []byte(fmt.Sprintf(`{"nodes":[{"name":"file-%d.txt","type":"file",`+
+			`"mode":420,"mtime":"2023-0%d-1%dT10:11:12.%d+01:00","uid":1000,"gid":1000,`+
+			`"user":"restic","group":"restic","inode":%d,"device_id":66306,"size":%d,"links":1,"content":null}]}`,
+			i, i%9+1, i%10, i*7919, i*104729, i*31)))
+	}
+	return samples
+}
+
+func TestTrainDictionary(t *testing.T) {
+	samples := dictionarySamples(200)
+	dict, err := repository.TrainDictionary(samples[:150], 4096)
+	rtest.OK(t, err)
+	_, err = repository.DictionaryID(dict)
+	rtest.OK(t, err)
+
+	without, err := repository.CompressedSize(samples[150:], nil)
+	rtest.OK(t, err)
+	with, err := repository.CompressedSize(samples[150:], dict)
+	rtest.OK(t, err)
+	rtest.Assert(t, with < without, "dictionary does not improve compression: %v >= %v", with, without)
+
+	_, err = repository.TrainDictionary(nil, 4096)
+	rtest.Assert(t, err != nil, "training without samples succeeded")
+}
+
+func TestCompressionDictionary(t *testing.T) {
+	repo, _ := repository.TestRepositoryWithVersion(t, 2)
+	dict, err := repository.TrainDictionary(dictionarySamples(100), 4096)
+	rtest.OK(t, err)
+	err = repository.AddCompressionDictionary(context.TODO(), repo, dict)
+	rtest.Assert(t, err != nil, "dictionary was added to a version 2 repository")
+
+	repo, be := repository.TestRepositoryWithVersion(t, 3)
+	rtest.OK(t, repository.AddCompressionDictionary(context.TODO(), repo, dict))
+
+	repo = repository.TestOpenBackend(t, be)
+	rtest.Equals(t, 1, len(repo.Config().CompressionDictionaries))
+
+	data := dictionarySamples(101)[100]
+	var wg errgroup.Group
+	repo.StartPackUploader(context.TODO(), &wg)
+	id, _, _, err := repo.SaveBlob(context.TODO(), restic.TreeBlob, data, restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.TODO()))
+
+	// the blob must be readable after reopening the repository
+	repo = repository.TestOpenBackend(t, be)
+	rtest.OK(t, repo.LoadIndex(context.TODO(), nil))
+	buf, err := repo.LoadBlob(context.TODO(), restic.TreeBlob, id, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	samples, err := repository.SampleDictionaryBlobs(context.TODO(), repo, 10)
+	rtest.OK(t, err)
+	rtest.Equals(t, [][]byte{data}, samples)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import "math"
+
+const (
+	// entropySampleSize is the number of bytes sampled from a blob to
+	// estimate its entropy.
+	entropySampleSize = 16 * 1024
+	// entropySampleChunk is the size of the contiguous chunks the sample is
+	// made of, such that the sample covers the whole blob.
+	entropySampleChunk = 512
+	// minEntropySampleBlobSize is the size below which blobs are always
+	// compressed, as the estimated entropy of small blobs is unreliable.
+	minEntropySampleBlobSize = 4 * 1024
+)
+
+// sampleEntropy estimates the Shannon entropy of data in bits per byte, using
+// chunks evenly spread over data. Compressed or encrypted data has an entropy
+// close to 8, while text usually stays below 5.
+func sampleEntropy(data []byte) float64 {
+	var counts [256]int
+	total := 0
+
+	count := func(chunk []byte) {
+		for _, b := range chunk {
+			counts[b]++
+		}
+		total += len(chunk)
+	}
+
+	if len(data) <= entropySampleSize {
+		count(data)
+	} else {
+		chunks := entropySampleSize / entropySampleChunk
+		step := (len(data) - entropySampleChunk) / (chunks - 1)
+		for i := 0; i < chunks; i++ {
+			start := i * step
+			count(data[start : start+entropySampleChunk])
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	entropy := 0.0
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// skipCompression returns true if data is a blob which is not worth
+// compressing, as its sampled entropy reaches the configured threshold.
+func (r *Repository) skipCompression(data []byte) bool {
+	if r.opts.CompressionSkipEntropy == 0 || len(data) < minEntropySampleBlobSize {
+		return false
+	}
+	return sampleEntropy(data) >= r.opts.CompressionSkipEntropy
+}
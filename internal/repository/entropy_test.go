@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestSampleEntropy(t *testing.T) {
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 2000)
+
+	for _, test := range []struct {
+		name     string
+		data     []byte
+		min, max float64
+	}{
+		{"empty", nil, 0, 0},
+		{"zeros", make([]byte, 1<<20), 0, 0},
+		{"text", text, 3.5, 4.5},
+		{"random-small", rtest.Random(23, 8*1024), 7.9, 8},
+		{"random-large", rtest.Random(42, 4<<20), 7.9, 8},
+	} {
+		entropy := sampleEntropy(test.data)
+		rtest.Assert(t, entropy >= test.min && entropy <= test.max, "%v: entropy %v not in [%v, %v]", test.name, entropy, test.min, test.max)
+	}
+}
+
+func TestSaveSkipsCompressionOfIncompressibleData(t *testing.T) {
+	for _, threshold := range []float64{0, 7.5} {
+		t.Run(fmt.Sprint(threshold), func(t *testing.T) {
+			repo, _ := TestRepositoryWithBackend(t, mem.New(), restic.StableRepoVersion, Options{CompressionSkipEntropy: threshold})
+
+			random := rtest.Random(5, 1<<20)
+			text := bytes.Repeat([]byte("compressible "), 1<<16)
+
+			var wg errgroup.Group
+			repo.StartPackUploader(context.TODO(), &wg)
+			randomID, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, random, restic.ID{}, false)
+			rtest.OK(t, err)
+			textID, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, text, restic.ID{}, false)
+			rtest.OK(t, err)
+			rtest.OK(t, repo.Flush(context.Background()))
+
+			pb := repo.LookupBlob(restic.DataBlob, randomID)[0]
+			rtest.Equals(t, threshold == 0, pb.IsCompressed())
+			pb = repo.LookupBlob(restic.DataBlob, textID)[0]
+			rtest.Assert(t, pb.IsCompressed(), "compressible blob was not compressed")
+
+			buf, err := repo.LoadBlob(context.TODO(), restic.DataBlob, randomID, nil)
+			rtest.OK(t, err)
+			rtest.Assert(t, bytes.Equal(random, buf), "wrong data returned")
+		})
+	}
+}
+
+func TestInvalidCompressionSkipEntropy(t *testing.T) {
+	for _, threshold := range []float64{-1, 8.5} {
+		_, err := New(mem.New(), Options{CompressionSkipEntropy: threshold})
+		rtest.Assert(t, err != nil, "threshold %v was accepted", threshold)
+	}
+}
@@ -54,6 +54,10 @@ type Index struct {
 	final   bool       // set to true for all indexes read from the backend ("finalized")
 	ids     restic.IDs // set to the IDs of the contained finalized indexes
 	created time.Time
+
+	// obsolete contains packs which must be ignored in all other indexes.
+	// Only delta indexes in repository version 3 contain obsolete packs.
+	obsolete restic.IDSet
 }
 
 // NewIndex returns a new index.
@@ -331,7 +335,16 @@ func (idx *Index) generatePackList() ([]packJSON, error) {
 
 type jsonIndex struct {
 	// removed: Supersedes restic.IDs `json:"supersedes,omitempty"`
-	Packs []packJSON `json:"packs"`
+	Packs         []packJSON `json:"packs"`
+	ObsoletePacks restic.IDs `json:"obsolete_packs,omitempty"`
+}
+
+// obsoletePackList returns the sorted list of obsolete packs.
+func (idx *Index) obsoletePackList() restic.IDs {
+	if len(idx.obsolete) == 0 {
+		return nil
+	}
+	return idx.obsolete.List()
 }
 
 // Encode writes the JSON serialization of the index to the writer w.
@@ -347,7 +360,8 @@ func (idx *Index) Encode(w io.Writer) error {
 
 	enc := json.NewEncoder(w)
 	idxJSON := jsonIndex{
-		Packs: list,
+		Packs:         list,
+		ObsoletePacks: idx.obsoletePackList(),
 	}
 	return enc.Encode(idxJSON)
 }
@@ -424,7 +438,8 @@ func (idx *Index) Dump(w io.Writer) error {
 	}
 
 	outer := jsonIndex{
-		Packs: list,
+		Packs:         list,
+		ObsoletePacks: idx.obsoletePackList(),
 	}
 
 	buf, err := json.MarshalIndent(outer, "", "  ")
@@ -485,10 +500,69 @@ func (idx *Index) merge(idx2 *Index) error {
 	}
 
 	idx.ids = append(idx.ids, idx2.ids...)
+	if len(idx2.obsolete) > 0 {
+		if idx.obsolete == nil {
+			idx.obsolete = restic.NewIDSet()
+		}
+		idx.obsolete.Merge(idx2.obsolete)
+	}
 
 	return nil
 }
 
+// ObsoletePacks returns the packs which a delta index removes from all other
+// indexes. The returned set must not be modified.
+func (idx *Index) ObsoletePacks() restic.IDSet {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	return idx.obsolete
+}
+
+// hasPack returns true if the index contains at least one of the packs.
+func (idx *Index) hasPack(packs restic.IDSet) bool {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	for _, id := range idx.packs {
+		if packs.Has(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutPacks returns a copy of the finalized index which omits all blobs
+// stored in the given packs.
+func (idx *Index) withoutPacks(packs restic.IDSet) *Index {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	newIdx := NewIndex()
+	packIndex := make(map[int]int)
+	for typ := range idx.byType {
+		m := &idx.byType[typ]
+		m.foreach(func(e *indexEntry) bool {
+			packID := idx.packs[e.packIndex]
+			if packs.Has(packID) {
+				return true
+			}
+			i, ok := packIndex[e.packIndex]
+			if !ok {
+				i = newIdx.addToPacks(packID)
+				packIndex[e.packIndex] = i
+			}
+			newIdx.byType[typ].add(e.id, i, e.offset, e.length, e.uncompressedLength)
+			return true
+		})
+	}
+
+	newIdx.final = idx.final
+	newIdx.ids = append(newIdx.ids, idx.ids...)
+	newIdx.obsolete = idx.obsolete
+	return newIdx
+}
+
 // isErrOldIndex returns true if the error may be caused by an old index
 // format.
 func isErrOldIndex(err error) bool {
@@ -534,6 +608,9 @@ func DecodeIndex(buf []byte, id restic.ID) (idx *Index, oldFormat bool, err erro
 			})
 		}
 	}
+	if len(idxJSON.ObsoletePacks) > 0 {
+		idx.obsolete = restic.NewIDSet(idxJSON.ObsoletePacks...)
+	}
 	idx.ids = append(idx.ids, id)
 	idx.final = true
 
@@ -17,6 +17,9 @@ type MasterIndex struct {
 	idx          []*Index
 	pendingBlobs restic.BlobSet
 	idxMutex     sync.RWMutex
+
+	// deltas contains the IDs of merged delta indexes
+	deltas restic.IDSet
 }
 
 // NewMasterIndex creates a new master index.
@@ -30,6 +33,7 @@ func (mi *MasterIndex) clear() {
 	// Always add an empty final index, such that MergeFinalIndexes can merge into this.
 	mi.idx = []*Index{NewIndex()}
 	mi.idx[0].Finalize()
+	mi.deltas = restic.NewIDSet()
 }
 
 // Lookup queries all known Indexes for the ID and returns all matches.
@@ -243,6 +247,8 @@ func (mi *MasterIndex) MergeFinalIndexes() error {
 	mi.idxMutex.Lock()
 	defer mi.idxMutex.Unlock()
 
+	mi.removeObsoletePacks()
+
 	// The first index is always final and the one to merge into
 	newIdx := mi.idx[:1]
 	for i := 1; i < len(mi.idx); i++ {
@@ -254,6 +260,9 @@ func (mi *MasterIndex) MergeFinalIndexes() error {
 		if !idx.Final() || len(ids) == 0 {
 			newIdx = append(newIdx, idx)
 		} else {
+			if len(idx.ObsoletePacks()) > 0 {
+				mi.deltas.Merge(restic.NewIDSet(ids...))
+			}
 			err := mi.idx[0].merge(idx)
 			if err != nil {
 				return fmt.Errorf("MergeFinalIndexes: %w", err)
@@ -265,6 +274,76 @@ func (mi *MasterIndex) MergeFinalIndexes() error {
 	return nil
 }
 
+// removeObsoletePacks drops all blobs stored in packs which a delta index
+// marks as obsolete from the final indexes. Must be called with idxMutex held.
+func (mi *MasterIndex) removeObsoletePacks() {
+	obsolete := mi.idx[0].ObsoletePacks().Clone()
+	// the main index only has to be filtered again if there are new obsolete packs
+	filterMain := false
+	for _, idx := range mi.idx[1:] {
+		if !idx.Final() {
+			continue
+		}
+		for id := range idx.ObsoletePacks() {
+			if !obsolete.Has(id) {
+				obsolete.Insert(id)
+				filterMain = true
+			}
+		}
+	}
+	if len(obsolete) == 0 {
+		return
+	}
+
+	for i, idx := range mi.idx {
+		if !idx.Final() || (i == 0 && !filterMain) {
+			continue
+		}
+		if idx.hasPack(obsolete) {
+			debug.Log("removing obsolete packs from index %v", i)
+			mi.idx[i] = idx.withoutPacks(obsolete)
+		}
+	}
+}
+
+// DeltaCount returns the number of loaded delta indexes, which mark packs as
+// obsolete. These are removed once the index is rewritten.
+func (mi *MasterIndex) DeltaCount() int {
+	mi.idxMutex.RLock()
+	defer mi.idxMutex.RUnlock()
+
+	return len(mi.deltas)
+}
+
+// SaveDelta saves a delta index which marks the given packs as obsolete. This
+// removes the packs from the index without rewriting all index files which
+// reference them. Delta indexes require repository version 3.
+// Afterwards the in-memory index is cleared as it no longer matches the
+// stored state.
+//
+// Must not be called concurrently to any other MasterIndex operation.
+func (mi *MasterIndex) SaveDelta(ctx context.Context, repo restic.SaverUnpacked, obsoletePacks restic.IDSet) error {
+	for _, idx := range mi.idx {
+		if !idx.Final() {
+			panic("internal error - index must be saved before calling MasterIndex.SaveDelta")
+		}
+	}
+
+	idx := NewIndex()
+	idx.obsolete = obsoletePacks.Clone()
+	idx.Finalize()
+	id, err := idx.SaveIndex(ctx, repo)
+	if err != nil {
+		return err
+	}
+	debug.Log("saved delta index %v with %d obsolete packs", id, len(obsoletePacks))
+
+	mi.idxMutex.Lock()
+	defer mi.idxMutex.Unlock()
+	mi.clear()
+	return nil
+}
+
 func (mi *MasterIndex) Load(ctx context.Context, r restic.ListerLoaderUnpacked, p *progress.Counter, cb func(id restic.ID, idx *Index, oldFormat bool, err error) error) error {
 	indexList, err := restic.MemorizeList(ctx, r, restic.IndexFile)
 	if err != nil {
@@ -340,13 +419,18 @@ func (mi *MasterIndex) Rewrite(ctx context.Context, repo restic.Unpacked, exclud
 	p := opts.SaveProgress
 	p.SetMax(uint64(len(indexes)))
 
+	// copy excludePacks to prevent unintended sideeffects
+	excludePacks = excludePacks.Clone()
+	// packs removed by delta indexes must not be included in the rewritten indexes
+	for _, idx := range mi.idx {
+		excludePacks.Merge(idx.ObsoletePacks())
+	}
+
 	// reset state which is not necessary for Rewrite and just consumes a lot of memory
 	// the index state would be invalid after Rewrite completes anyways
 	mi.clear()
 	runtime.GC()
 
-	// copy excludePacks to prevent unintended sideeffects
-	excludePacks = excludePacks.Clone()
 	debug.Log("start rebuilding index of %d indexes, excludePacks: %v", len(indexes), excludePacks)
 	wg, wgCtx := errgroup.WithContext(ctx)
 
@@ -411,8 +495,10 @@ func (mi *MasterIndex) Rewrite(ctx context.Context, repo restic.Unpacked, exclud
 		defer close(saveCh)
 		newIndex := NewIndex()
 		for task := range rewriteCh {
-			// always rewrite indexes using the old format, that include a pack that must be removed or that are not full
-			if !task.oldFormat && len(task.idx.Packs().Intersect(excludePacks)) == 0 && IndexFull(task.idx) {
+			// always rewrite indexes using the old format, that include a pack that must be removed,
+			// that are not full or that are delta indexes
+			if !task.oldFormat && len(task.idx.Packs().Intersect(excludePacks)) == 0 && IndexFull(task.idx) &&
+				len(task.idx.ObsoletePacks()) == 0 {
 				// make sure that each pack is only stored exactly once in the index
 				excludePacks.Merge(task.idx.Packs())
 				// index is already up to date
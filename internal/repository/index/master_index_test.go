@@ -1,6 +1,7 @@
 package index_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math/rand"
@@ -459,3 +460,49 @@ func listPacks(t testing.TB, repo restic.Lister) restic.IDSet {
 	}))
 	return s
 }
+
+func TestMasterIndexDelta(t *testing.T) {
+	removed := restic.PackedBlob{
+		PackID: restic.NewRandomID(),
+		Blob:   restic.Blob{BlobHandle: restic.NewRandomBlobHandle(), Length: 10},
+	}
+	kept := restic.PackedBlob{
+		PackID: restic.NewRandomID(),
+		Blob:   restic.Blob{BlobHandle: restic.NewRandomBlobHandle(), Length: 20, Offset: 10},
+	}
+
+	idx := index.NewIndex()
+	idx.StorePack(removed.PackID, []restic.Blob{removed.Blob})
+	idx.StorePack(kept.PackID, []restic.Blob{kept.Blob})
+	idx.Finalize()
+	rtest.OK(t, idx.SetID(restic.NewRandomID()))
+
+	buf := []byte(fmt.Sprintf(`{"packs":[],"obsolete_packs":[%q]}`, removed.PackID))
+	deltaID := restic.NewRandomID()
+	delta, _, err := index.DecodeIndex(buf, deltaID)
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.NewIDSet(removed.PackID), delta.ObsoletePacks())
+
+	var encoded bytes.Buffer
+	rtest.OK(t, delta.Encode(&encoded))
+	roundtrip, _, err := index.DecodeIndex(encoded.Bytes(), deltaID)
+	rtest.OK(t, err)
+	rtest.Equals(t, delta.ObsoletePacks(), roundtrip.ObsoletePacks())
+
+	// the delta index applies regardless of the loading order
+	for _, order := range [][]*index.Index{{idx, delta}, {delta, idx}} {
+		mIdx := index.NewMasterIndex()
+		for _, i := range order {
+			mIdx.Insert(i)
+		}
+		rtest.OK(t, mIdx.MergeFinalIndexes())
+
+		rtest.Equals(t, 1, mIdx.DeltaCount())
+		rtest.Assert(t, !mIdx.Has(removed.BlobHandle), "blob from obsolete pack was found")
+		rtest.Equals(t, []restic.PackedBlob{kept}, mIdx.Lookup(kept.BlobHandle))
+		rtest.Equals(t, restic.NewIDSet(kept.PackID), mIdx.Packs(restic.NewIDSet()))
+	}
+
+	// the original index is not modified
+	rtest.Assert(t, idx.Has(removed.BlobHandle), "original index was modified")
+}
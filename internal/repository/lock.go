@@ -36,17 +36,18 @@ var lockerInst = &locker{
 	refreshabilityTimeout: restic.StaleLockTimeout - defaultRefreshInterval*3/2,
 }
 
-func Lock(ctx context.Context, repo *Repository, exclusive bool, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (*Unlocker, context.Context, error) {
-	return lockerInst.Lock(ctx, repo, exclusive, retryLock, printRetry, logger)
+func Lock(ctx context.Context, repo *Repository, exclusive bool, owner *restic.LockOwner, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (*Unlocker, context.Context, error) {
+	return lockerInst.Lock(ctx, repo, exclusive, owner, retryLock, printRetry, logger)
 }
 
 // Lock wraps the ctx such that it is cancelled when the repository is unlocked
-// cancelling the original context also stops the lock refresh
-func (l *locker) Lock(ctx context.Context, repo *Repository, exclusive bool, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (*Unlocker, context.Context, error) {
+// cancelling the original context also stops the lock refresh. If owner is set,
+// locks of the same failover cluster resource held by failed cluster nodes are
+// taken over.
+func (l *locker) Lock(ctx context.Context, repo *Repository, exclusive bool, owner *restic.LockOwner, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (*Unlocker, context.Context, error) {
 
-	lockFn := restic.NewLock
-	if exclusive {
-		lockFn = restic.NewExclusiveLock
+	lockFn := func(ctx context.Context, repo restic.Unpacked) (*restic.Lock, error) {
+		return restic.NewClusterLock(ctx, repo, exclusive, owner)
 	}
 
 	var lock *restic.Lock
@@ -92,6 +93,9 @@ retryLoop:
 		return nil, ctx, fmt.Errorf("unable to create lock in backend: %w", err)
 	}
 	debug.Log("create lock %p (exclusive %v)", lock, exclusive)
+	for _, other := range lock.TakenOver() {
+		logger("took over lock of failed cluster node %v:\n%v\n", other.Owner.Node, other)
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	lockInfo := &lockContext{
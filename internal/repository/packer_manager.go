@@ -158,7 +158,7 @@ func (r *Repository) savePacker(ctx context.Context, t restic.BlobType, p *packe
 	}
 
 	hr := hashing.NewReader(rd, sha256.New())
-	_, err = io.Copy(io.Discard, hr)
+	size, err := io.Copy(io.Discard, hr)
 	if err != nil {
 		return err
 	}
@@ -198,6 +198,7 @@ func (r *Repository) savePacker(ctx context.Context, t restic.BlobType, p *packe
 	// update blobs in the index
 	debug.Log("  updating blobs %v to pack %v", p.Packer.Blobs(), id)
 	r.idx.StorePack(id, p.Packer.Blobs())
+	r.recordPack(id, size)
 
 	// Save index if full
 	return r.idx.SaveFullIndex(ctx, r)
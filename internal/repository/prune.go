@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"sync"
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository/index"
@@ -28,6 +29,8 @@ type PruneOptions struct {
 	RepackCachableOnly bool
 	RepackSmall        bool
 	RepackUncompressed bool
+	// RepackAll rewrites all packs, for example to migrate the blob format.
+	RepackAll bool
 }
 
 type PruneStats struct {
@@ -67,9 +70,10 @@ type PrunePlan struct {
 	removePacks      restic.IDSet                // packs to remove
 	ignorePacks      restic.IDSet                // packs to ignore when rebuilding the index
 
-	repo  *Repository
-	stats PruneStats
-	opts  PruneOptions
+	repo    *Repository
+	stats   PruneStats
+	opts    PruneOptions
+	indexes restic.IDSet // index files after Execute
 }
 
 type packInfo struct {
@@ -86,7 +90,7 @@ type packInfo struct {
 type packInfoWithID struct {
 	ID restic.ID
 	packInfo
-	mustCompress bool
+	mustRepack bool
 }
 
 // PlanPrune selects which files to rewrite and which to delete and which blobs to keep.
@@ -366,11 +370,11 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 		if p.uncompressed {
 			stats.Size.Uncompressed += p.unusedSize + p.usedSize
 		}
-		mustCompress := false
+		mustRepack := opts.RepackAll
 		if repoVersion >= 2 {
 			// repo v2: always repack tree blobs if uncompressed
 			// compress data blobs if requested
-			mustCompress = (p.tpe == restic.TreeBlob || opts.RepackUncompressed) && p.uncompressed
+			mustRepack = mustRepack || (p.tpe == restic.TreeBlob || opts.RepackUncompressed) && p.uncompressed
 		}
 
 		// decide what to do
@@ -385,17 +389,17 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 			// if this is a data pack and --repack-cacheable-only is set => keep pack!
 			stats.Packs.Keep++
 
-		case p.unusedBlobs == 0 && p.tpe != restic.InvalidBlob && !mustCompress:
+		case p.unusedBlobs == 0 && p.tpe != restic.InvalidBlob && !mustRepack:
 			if packSize >= int64(targetPackSize) {
 				// All blobs in pack are used and not mixed => keep pack!
 				stats.Packs.Keep++
 			} else {
-				repackSmallCandidates = append(repackSmallCandidates, packInfoWithID{ID: id, packInfo: p, mustCompress: mustCompress})
+				repackSmallCandidates = append(repackSmallCandidates, packInfoWithID{ID: id, packInfo: p, mustRepack: mustRepack})
 			}
 
 		default:
 			// all other packs are candidates for repacking
-			repackCandidates = append(repackCandidates, packInfoWithID{ID: id, packInfo: p, mustCompress: mustCompress})
+			repackCandidates = append(repackCandidates, packInfoWithID{ID: id, packInfo: p, mustRepack: mustRepack})
 		}
 
 		delete(indexPack, id)
@@ -486,7 +490,7 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 		case reachedRepackSize:
 			stats.Packs.Keep++
 
-		case p.tpe != restic.DataBlob, p.mustCompress:
+		case p.tpe != restic.DataBlob, p.mustRepack:
 			// repacking non-data packs / uncompressed-trees is only limited by repackSize
 			repack(p.ID, p.packInfo)
 
@@ -519,6 +523,13 @@ func (plan *PrunePlan) Stats() PruneStats {
 	return plan.stats
 }
 
+// IndexIDs returns the IDs of the index files of the repository after the plan
+// was executed. It returns nil if the plan was not executed or if the index
+// was recovered using the UnsafeRecovery option.
+func (plan *PrunePlan) IndexIDs() restic.IDSet {
+	return plan.indexes
+}
+
 // Execute does the actual pruning:
 // - remove unreferenced packs first
 // - repack given pack files while keeping the given blobs
@@ -592,11 +603,15 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) er
 		if err != nil {
 			return errors.Fatalf("%s", err)
 		}
-	} else if len(plan.ignorePacks) != 0 {
-		err := rewriteIndexFiles(ctx, repo, plan.ignorePacks, nil, nil, printer)
-		if err != nil {
-			return errors.Fatalf("%s", err)
+	} else {
+		files := &indexFileTracker{Repository: repo, indexes: repo.idx.IDs()}
+		if len(plan.ignorePacks) != 0 {
+			err := removePacksFromIndex(ctx, repo, files, plan.ignorePacks, printer)
+			if err != nil {
+				return errors.Fatalf("%s", err)
+			}
 		}
+		plan.indexes = files.indexes
 	}
 
 	if len(plan.removePacks) != 0 {
@@ -621,6 +636,51 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer progress.Printer) er
 	return nil
 }
 
+// maxDeltaIndexes is the number of delta index files after which prune
+// consolidates the index by rewriting all index files.
+const maxDeltaIndexes = 20
+
+// removePacksFromIndex removes the given packs from the index. For repository
+// version 3, this saves a delta index which marks the packs as obsolete. Once
+// too many delta indexes exist, all affected index files are rewritten.
+func removePacksFromIndex(ctx context.Context, repo *Repository, files restic.Unpacked, removePacks restic.IDSet, printer progress.Printer) error {
+	if repo.Config().Version < 3 || repo.idx.DeltaCount() >= maxDeltaIndexes {
+		return rewriteIndexFiles(ctx, repo, files, removePacks, nil, nil, printer)
+	}
+
+	printer.P("saving delta index\n")
+	return repo.idx.SaveDelta(ctx, files, removePacks)
+}
+
+// indexFileTracker tracks the index files of the repository while they are
+// saved and removed.
+type indexFileTracker struct {
+	*Repository
+
+	m       sync.Mutex
+	indexes restic.IDSet
+}
+
+func (t *indexFileTracker) SaveUnpacked(ctx context.Context, tpe restic.FileType, buf []byte) (restic.ID, error) {
+	id, err := t.Repository.SaveUnpacked(ctx, tpe, buf)
+	if err == nil && tpe == restic.IndexFile {
+		t.m.Lock()
+		t.indexes.Insert(id)
+		t.m.Unlock()
+	}
+	return id, err
+}
+
+func (t *indexFileTracker) RemoveUnpacked(ctx context.Context, tpe restic.FileType, id restic.ID) error {
+	err := t.Repository.RemoveUnpacked(ctx, tpe, id)
+	if err == nil && tpe == restic.IndexFile {
+		t.m.Lock()
+		t.indexes.Delete(id)
+		t.m.Unlock()
+	}
+	return err
+}
+
 // deleteFiles deletes the given fileList of fileType in parallel
 // if ignoreError=true, it will print a warning if there was an error, else it will abort.
 func deleteFiles(ctx context.Context, ignoreError bool, repo restic.RemoverUnpacked, fileList restic.IDSet, fileType restic.FileType, printer progress.Printer) error {
@@ -7,14 +7,15 @@ import (
 
 	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/repository/index"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
 	"github.com/restic/restic/internal/ui/progress"
 	"golang.org/x/sync/errgroup"
 )
 
-func testPrune(t *testing.T, opts repository.PruneOptions, errOnUnused bool) {
-	repo, be := repository.TestRepositoryWithVersion(t, 0)
+func testPrune(t *testing.T, opts repository.PruneOptions, errOnUnused bool, version uint) {
+	repo, be := repository.TestRepositoryWithVersion(t, version)
 	createRandomBlobs(t, repo, 4, 0.5, true)
 	createRandomBlobs(t, repo, 5, 0.5, true)
 	keep, _ := selectBlobs(t, repo, 0.5)
@@ -43,6 +44,15 @@ func testPrune(t *testing.T, opts repository.PruneOptions, errOnUnused bool) {
 	repo = repository.TestOpenBackend(t, be)
 	checker.TestCheckRepo(t, repo, true)
 
+	if !opts.UnsafeRecovery {
+		indexes := restic.NewIDSet()
+		rtest.OK(t, repo.List(context.TODO(), restic.IndexFile, func(id restic.ID, _ int64) error {
+			indexes.Insert(id)
+			return nil
+		}))
+		rtest.Assert(t, indexes.Equals(plan.IndexIDs()), "unexpected index files, wanted %v got %v", indexes, plan.IndexIDs())
+	}
+
 	if errOnUnused {
 		existing := listBlobs(repo)
 		rtest.Assert(t, existing.Equals(keep), "unexpected blobs, wanted %v got %v", keep, existing)
@@ -96,13 +106,65 @@ func TestPrune(t *testing.T) {
 		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
-			testPrune(t, test.opts, test.errOnUnused)
+			testPrune(t, test.opts, test.errOnUnused, 0)
 		})
 		t.Run(test.name+"-recovery", func(t *testing.T) {
 			opts := test.opts
 			opts.UnsafeRecovery = true
 			// unsafeNoSpaceRecovery does not repack partially used pack files
-			testPrune(t, opts, false)
+			testPrune(t, opts, false, 0)
+		})
+		t.Run(test.name+"-v3", func(t *testing.T) {
+			// uses delta indexes
+			testPrune(t, test.opts, test.errOnUnused, 3)
 		})
 	}
 }
+
+func countDeltaIndexes(t *testing.T, repo restic.Repository) int {
+	count := 0
+	rtest.OK(t, index.ForAllIndexes(context.TODO(), repo, repo, func(_ restic.ID, idx *index.Index, _ bool, err error) error {
+		if err != nil {
+			return err
+		}
+		if len(idx.ObsoletePacks()) > 0 {
+			count++
+		}
+		return nil
+	}))
+	return count
+}
+
+func TestPruneDeltaIndex(t *testing.T) {
+	repo, be := repository.TestRepositoryWithVersion(t, 3)
+	opts := repository.PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+	}
+
+	for i := 1; i <= 2; i++ {
+		createRandomBlobs(t, repo, 5, 0.5, true)
+		keep, _ := selectBlobs(t, repo, 0.5)
+
+		plan, err := repository.PlanPrune(context.TODO(), opts, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+			for blob := range keep {
+				usedBlobs.Insert(blob)
+			}
+			return nil
+		}, &progress.NoopPrinter{})
+		rtest.OK(t, err)
+		rtest.OK(t, plan.Execute(context.TODO(), &progress.NoopPrinter{}))
+
+		repo = repository.TestOpenBackend(t, be)
+		rtest.Equals(t, i, countDeltaIndexes(t, repo))
+		checker.TestCheckRepo(t, repo, true)
+		existing := listBlobs(repo)
+		rtest.Assert(t, existing.Equals(keep), "unexpected blobs, wanted %v got %v", keep, existing)
+	}
+
+	// repairing the index consolidates all delta indexes
+	rtest.OK(t, repository.RepairIndex(context.TODO(), repo, repository.RepairIndexOptions{}, &progress.NoopPrinter{}))
+	repo = repository.TestOpenBackend(t, be)
+	rtest.Equals(t, 0, countDeltaIndexes(t, repo))
+	checker.TestCheckRepo(t, repo, true)
+}
@@ -56,7 +56,7 @@ func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printe
 	}
 
 	// remove salvaged packs from index
-	err = rewriteIndexFiles(ctx, repo, ids, nil, nil, printer)
+	err = rewriteIndexFiles(ctx, repo, repo, ids, nil, nil, printer)
 	if err != nil {
 		return err
 	}
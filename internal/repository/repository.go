@@ -3,6 +3,7 @@ package repository
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -47,16 +48,27 @@ type Repository struct {
 	treePM   *packerManager
 	dataPM   *packerManager
 
-	allocEnc sync.Once
-	allocDec sync.Once
-	enc      *zstd.Encoder
-	dec      *zstd.Decoder
+	allocEnc     sync.Once
+	allocDictEnc sync.Once
+	allocDec     sync.Once
+	enc          *zstd.Encoder
+	dictEnc      *zstd.Encoder
+	dec          *zstd.Decoder
+
+	savedPacksMu sync.Mutex
+	savedPacks   []restic.ManifestPack
 }
 
 type Options struct {
 	Compression   CompressionMode
 	PackSize      uint
 	NoExtraVerify bool
+	// CompressionSkipEntropy is the entropy in bits per byte from which data
+	// blobs are stored without compression, as they are most likely already
+	// compressed or encrypted. Zero disables the check.
+	CompressionSkipEntropy float64
+	// CompressionLevel overrides the zstd level selected by Compression.
+	CompressionLevel CompressionLevel
 }
 
 // CompressionMode configures if data should be compressed.
@@ -104,11 +116,72 @@ func (c *CompressionMode) Type() string {
 	return "mode"
 }
 
+// CompressionLevel selects the zstd compression level independently of the
+// compression mode.
+type CompressionLevel uint
+
+// Constants for the different compression levels. CompressionLevelUnset uses
+// the level of the compression mode.
+const (
+	CompressionLevelUnset   CompressionLevel = 0
+	CompressionLevelFastest CompressionLevel = 1
+	CompressionLevelDefault CompressionLevel = 2
+	CompressionLevelBetter  CompressionLevel = 3
+	CompressionLevelBest    CompressionLevel = 4
+	CompressionLevelInvalid CompressionLevel = 5
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (c *CompressionLevel) Set(s string) error {
+	switch s {
+	case "fastest":
+		*c = CompressionLevelFastest
+	case "default":
+		*c = CompressionLevelDefault
+	case "better":
+		*c = CompressionLevelBetter
+	case "best":
+		*c = CompressionLevelBest
+	default:
+		*c = CompressionLevelInvalid
+		return fmt.Errorf("invalid compression level %q, must be one of (fastest|default|better|best)", s)
+	}
+
+	return nil
+}
+
+func (c *CompressionLevel) String() string {
+	switch *c {
+	case CompressionLevelUnset:
+		return ""
+	case CompressionLevelFastest:
+		return "fastest"
+	case CompressionLevelDefault:
+		return "default"
+	case CompressionLevelBetter:
+		return "better"
+	case CompressionLevelBest:
+		return "best"
+	default:
+		return "invalid"
+	}
+}
+
+func (c *CompressionLevel) Type() string {
+	return "level"
+}
+
 // New returns a new repository with backend be.
 func New(be backend.Backend, opts Options) (*Repository, error) {
 	if opts.Compression == CompressionInvalid {
 		return nil, errors.New("invalid compression mode")
 	}
+	if opts.CompressionLevel == CompressionLevelInvalid {
+		return nil, errors.New("invalid compression level")
+	}
+	if opts.CompressionSkipEntropy < 0 || opts.CompressionSkipEntropy > 8 {
+		return nil, fmt.Errorf("invalid entropy threshold %v for skipping compression, must be between 0 and 8", opts.CompressionSkipEntropy)
+	}
 
 	if opts.PackSize == 0 {
 		opts.PackSize = DefaultPackSize
@@ -153,6 +226,55 @@ func (r *Repository) UseCache(c *cache.Cache) {
 	r.be = c.Wrap(r.be)
 }
 
+// SaveCacheJSON encrypts item as JSON and stores it in the file name in the
+// local cache. Such files are only meant to speed up later operations.
+func (r *Repository) SaveCacheJSON(name string, item interface{}) error {
+	if r.Cache == nil {
+		return errors.New("no cache in use")
+	}
+
+	plaintext, err := json.Marshal(item)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	ciphertext := crypto.NewBlobBuffer(len(plaintext))
+	ciphertext = ciphertext[:0]
+	nonce := crypto.NewRandomNonce()
+	ciphertext = append(ciphertext, nonce...)
+	ciphertext = r.key.Seal(ciphertext, nonce, plaintext, nil)
+
+	return r.Cache.SaveFile(name, ciphertext)
+}
+
+// LoadCacheJSON loads the file name stored using SaveCacheJSON into item.
+func (r *Repository) LoadCacheJSON(name string, item interface{}) error {
+	if r.Cache == nil {
+		return errors.New("no cache in use")
+	}
+
+	buf, err := r.Cache.LoadFile(name)
+	if err != nil {
+		return err
+	}
+	if len(buf) < r.key.NonceSize() {
+		return errors.Errorf("cache file %v is truncated", name)
+	}
+
+	nonce, ciphertext := buf[:r.key.NonceSize()], buf[r.key.NonceSize():]
+	plaintext, err := r.key.Open(ciphertext[:0], nonce, ciphertext, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, item)
+}
+
+// IndexIDs returns the IDs of all index files which are loaded or were
+// written by this repository.
+func (r *Repository) IndexIDs() restic.IDSet {
+	return r.idx.IDs()
+}
+
 // SetDryRun sets the repo backend into dry-run mode.
 func (r *Repository) SetDryRun() {
 	r.be = dryrun.New(r.be)
@@ -263,7 +385,7 @@ func (r *Repository) loadBlob(ctx context.Context, blobs []restic.PackedBlob, bu
 			continue
 		}
 
-		it := newPackBlobIterator(blob.PackID, newByteReader(buf), uint(blob.Offset), []restic.Blob{blob.Blob}, r.key, r.getZstdDecoder())
+		it := newPackBlobIterator(blob.PackID, newByteReader(buf), uint(blob.Offset), []restic.Blob{blob.Blob}, r.key, blobAADModeFor(r.cfg), r.getZstdDecoder())
 		pbv, err := it.Next()
 
 		if err == nil {
@@ -292,31 +414,63 @@ func (r *Repository) loadBlob(ctx context.Context, blobs []restic.PackedBlob, bu
 	return nil, errors.Errorf("loading %v from %v packs failed", blobs[0].BlobHandle, len(blobs))
 }
 
+func (r *Repository) zstdEncoderOptions() []zstd.EOption {
+	level := zstd.SpeedDefault
+	if r.opts.Compression == CompressionMax {
+		level = zstd.SpeedBestCompression
+	}
+	switch r.opts.CompressionLevel {
+	case CompressionLevelFastest:
+		level = zstd.SpeedFastest
+	case CompressionLevelDefault:
+		level = zstd.SpeedDefault
+	case CompressionLevelBetter:
+		level = zstd.SpeedBetterCompression
+	case CompressionLevelBest:
+		level = zstd.SpeedBestCompression
+	}
+
+	return []zstd.EOption{
+		// Set the compression level configured.
+		zstd.WithEncoderLevel(level),
+		// Disable CRC, we have enough checks in place, makes the
+		// compressed data four bytes shorter.
+		zstd.WithEncoderCRC(false),
+		// Set a window of 512kbyte, so we have good lookbehind for usual
+		// blob sizes.
+		zstd.WithWindowSize(512 * 1024),
+	}
+}
+
 func (r *Repository) getZstdEncoder() *zstd.Encoder {
 	r.allocEnc.Do(func() {
-		level := zstd.SpeedDefault
-		if r.opts.Compression == CompressionMax {
-			level = zstd.SpeedBestCompression
+		enc, err := zstd.NewWriter(nil, r.zstdEncoderOptions()...)
+		if err != nil {
+			panic(err)
 		}
+		r.enc = enc
+	})
+	return r.enc
+}
 
-		opts := []zstd.EOption{
-			// Set the compression level configured.
-			zstd.WithEncoderLevel(level),
-			// Disable CRC, we have enough checks in place, makes the
-			// compressed data four bytes shorter.
-			zstd.WithEncoderCRC(false),
-			// Set a window of 512kbyte, so we have good lookbehind for usual
-			// blob sizes.
-			zstd.WithWindowSize(512 * 1024),
-		}
+// getZstdBlobEncoder returns the encoder for a blob of the given type and
+// size. Tree blobs and small data blobs are compressed using the current
+// compression dictionary of the repository, if there is one.
+func (r *Repository) getZstdBlobEncoder(t restic.BlobType, size int) *zstd.Encoder {
+	dicts := r.cfg.CompressionDictionaries
+	if len(dicts) == 0 || (t == restic.DataBlob && size > MaxDictionaryBlobSize) {
+		return r.getZstdEncoder()
+	}
 
+	r.allocDictEnc.Do(func() {
+		opts := append(r.zstdEncoderOptions(), zstd.WithEncoderDict(dicts[len(dicts)-1]))
 		enc, err := zstd.NewWriter(nil, opts...)
 		if err != nil {
 			panic(err)
 		}
-		r.enc = enc
+		r.dictEnc = enc
 	})
-	return r.enc
+	return r.dictEnc
 }
 
 func (r *Repository) getZstdDecoder() *zstd.Decoder {
@@ -327,6 +481,8 @@ func (r *Repository) getZstdDecoder() *zstd.Decoder {
 			// Limit the maximum decompressed memory. Set to a very high,
 			// conservative value.
 			zstd.WithDecoderMaxMemory(16 * 1024 * 1024 * 1024),
+			// Support all dictionaries ever used in the repository.
+			zstd.WithDecoderDicts(r.cfg.CompressionDictionaries...),
 		}
 
 		dec, err := zstd.NewReader(nil, opts...)
@@ -350,10 +506,12 @@ func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data
 
 		// we have a repo v2, so compression is available. if the user opts to
 		// not compress, we won't compress any data, but everything else is
-		// compressed.
-		if r.opts.Compression != CompressionOff || t != restic.DataBlob {
+		// compressed. Data blobs which look incompressible are not
+		// compressed either.
+		skip := t == restic.DataBlob && (r.opts.Compression == CompressionOff || r.skipCompression(data))
+		if !skip {
 			uncompressedLength = len(data)
-			data = r.getZstdEncoder().EncodeAll(data, nil)
+			data = r.getZstdBlobEncoder(t, len(data)).EncodeAll(data, nil)
 		}
 	}
 
@@ -363,9 +521,10 @@ func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data
 	ciphertext = append(ciphertext, nonce...)
 
 	// encrypt blob
-	ciphertext = r.key.Seal(ciphertext, nonce, data, nil)
+	h := restic.BlobHandle{ID: id, Type: t}
+	ciphertext = r.key.Seal(ciphertext, nonce, data, blobAADModeFor(r.cfg).additionalData(h))
 
-	if err := r.verifyCiphertext(ciphertext, uncompressedLength, id); err != nil {
+	if err := r.verifyCiphertext(ciphertext, uncompressedLength, h); err != nil {
 		//nolint:revive // ignore linter warnings about error message spelling
 		return 0, fmt.Errorf("Detected data corruption while saving blob %v: %w\nCorrupted blobs are either caused by hardware issues or software bugs. Please open an issue at https://github.com/restic/restic/issues/new/choose for further troubleshooting.", id, err)
 	}
@@ -385,13 +544,13 @@ func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data
 	return pm.SaveBlob(ctx, t, id, ciphertext, uncompressedLength)
 }
 
-func (r *Repository) verifyCiphertext(buf []byte, uncompressedLength int, id restic.ID) error {
+func (r *Repository) verifyCiphertext(buf []byte, uncompressedLength int, h restic.BlobHandle) error {
 	if r.opts.NoExtraVerify {
 		return nil
 	}
 
 	nonce, ciphertext := buf[:r.key.NonceSize()], buf[r.key.NonceSize():]
-	plaintext, err := r.key.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := r.key.Open(nil, nonce, ciphertext, blobAADModeFor(r.cfg).additionalData(h))
 	if err != nil {
 		return fmt.Errorf("decryption failed: %w", err)
 	}
@@ -403,7 +562,7 @@ func (r *Repository) verifyCiphertext(buf []byte, uncompressedLength int, id res
 			return fmt.Errorf("decompression failed: %w", err)
 		}
 	}
-	if !restic.Hash(plaintext).Equal(id) {
+	if !restic.Hash(plaintext).Equal(h.ID) {
 		return errors.New("hash mismatch")
 	}
 
@@ -562,6 +721,21 @@ func (r *Repository) flushPacks(ctx context.Context) error {
 	return err
 }
 
+// recordPack remembers that the pack file id with the given size was saved.
+func (r *Repository) recordPack(id restic.ID, size int64) {
+	r.savedPacksMu.Lock()
+	defer r.savedPacksMu.Unlock()
+	r.savedPacks = append(r.savedPacks, restic.ManifestPack{ID: id, Size: size})
+}
+
+// SavedPacks returns the pack files which were uploaded using this repository
+// so far, in the order in which they were saved.
+func (r *Repository) SavedPacks() []restic.ManifestPack {
+	r.savedPacksMu.Lock()
+	defer r.savedPacksMu.Unlock()
+	return append([]restic.ManifestPack(nil), r.savedPacks...)
+}
+
 func (r *Repository) Connections() uint {
 	return r.be.Connections()
 }
@@ -597,12 +771,21 @@ func (r *Repository) clearIndex() {
 
 // LoadIndex loads all index files from the backend in parallel and stores them
 func (r *Repository) LoadIndex(ctx context.Context, p *progress.Counter) error {
+	return r.LoadIndexFromList(ctx, r, p)
+}
+
+// LoadIndexFromList loads the index files returned by indexList, which allows
+// reusing a list of index files that was already retrieved from the backend.
+func (r *Repository) LoadIndexFromList(ctx context.Context, indexList restic.Lister, p *progress.Counter) error {
 	debug.Log("Loading index")
 
 	// reset in-memory index before loading it from the repository
 	r.clearIndex()
 
-	err := r.idx.Load(ctx, r, p, nil)
+	err := r.idx.Load(ctx, struct {
+		restic.Lister
+		restic.LoaderUnpacked
+	}{indexList, r}, p, nil)
 	if err != nil {
 		return err
 	}
@@ -726,6 +909,47 @@ func (r *Repository) prepareCache() error {
 	return nil
 }
 
+// CachePacks downloads the pack files to the local cache unless they are
+// already cached. Cached pack files are used for all later reads, including
+// those of data blobs.
+func (r *Repository) CachePacks(ctx context.Context, packs restic.IDSet, p *progress.Counter) error {
+	cb, ok := r.be.(*cache.Backend)
+	if !ok {
+		return errors.New("no cache in use")
+	}
+
+	p.SetMax(uint64(len(packs)))
+	defer p.Done()
+
+	wg, wgCtx := errgroup.WithContext(ctx)
+	ch := make(chan restic.ID)
+	wg.Go(func() error {
+		defer close(ch)
+		for id := range packs {
+			select {
+			case ch <- id:
+			case <-wgCtx.Done():
+				return wgCtx.Err()
+			}
+		}
+		return nil
+	})
+
+	for i := uint(0); i < r.Connections(); i++ {
+		wg.Go(func() error {
+			for id := range ch {
+				h := backend.Handle{Type: restic.PackFile, Name: id.String()}
+				if err := cb.Prefetch(wgCtx, h); err != nil {
+					return fmt.Errorf("caching pack %v failed: %w", id.Str(), err)
+				}
+				p.Add(1)
+			}
+			return nil
+		})
+	}
+	return wg.Wait()
+}
+
 // SearchKey finds a key with the supplied password, afterwards the config is
 // read and parsed. It tries at most maxKeys key files in the repo.
 func (r *Repository) SearchKey(ctx context.Context, password string, maxKeys int, keyHint string) error {
@@ -907,10 +1131,10 @@ const maxUnusedRange = 1 * 1024 * 1024
 // then LoadBlobsFromPack will abort and not retry it. The buf passed to the callback is only valid within
 // this specific call. The callback must not keep a reference to buf.
 func (r *Repository) LoadBlobsFromPack(ctx context.Context, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
-	return streamPack(ctx, r.be.Load, r.LoadBlob, r.getZstdDecoder(), r.key, packID, blobs, handleBlobFn)
+	return streamPack(ctx, r.be.Load, r.LoadBlob, r.getZstdDecoder(), r.key, blobAADModeFor(r.cfg), packID, blobs, handleBlobFn)
 }
 
-func streamPack(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+func streamPack(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, aad blobAADMode, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
 	if len(blobs) == 0 {
 		// nothing to do
 		return nil
@@ -944,7 +1168,7 @@ func streamPack(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn
 
 		if split {
 			// load everything up to the skipped file section
-			err := streamPackPart(ctx, beLoad, loadBlobFn, dec, key, packID, blobs[lowerIdx:i], handleBlobFn)
+			err := streamPackPart(ctx, beLoad, loadBlobFn, dec, key, aad, packID, blobs[lowerIdx:i], handleBlobFn)
 			if err != nil {
 				return err
 			}
@@ -953,10 +1177,10 @@ func streamPack(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn
 		lastPos = blobs[i].Offset + blobs[i].Length
 	}
 	// load remainder
-	return streamPackPart(ctx, beLoad, loadBlobFn, dec, key, packID, blobs[lowerIdx:], handleBlobFn)
+	return streamPackPart(ctx, beLoad, loadBlobFn, dec, key, aad, packID, blobs[lowerIdx:], handleBlobFn)
 }
 
-func streamPackPart(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+func streamPackPart(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBlobFn, dec *zstd.Decoder, key *crypto.Key, aad blobAADMode, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
 	h := backend.Handle{Type: restic.PackFile, Name: packID.String(), IsMetadata: blobs[0].Type.IsMetadata()}
 
 	dataStart := blobs[0].Offset
@@ -988,7 +1212,7 @@ func streamPackPart(ctx context.Context, beLoad backendLoadFn, loadBlobFn loadBl
 		return errors.Wrap(err, "StreamPack")
 	}
 
-	it := newPackBlobIterator(packID, newByteReader(data), dataStart, blobs, key, dec)
+	it := newPackBlobIterator(packID, newByteReader(data), dataStart, blobs, key, aad, dec)
 
 	for {
 		val, err := it.Next()
@@ -1064,6 +1288,7 @@ type packBlobIterator struct {
 
 	blobs []restic.Blob
 	key   *crypto.Key
+	aad   blobAADMode
 	dec   *zstd.Decoder
 
 	decode []byte
@@ -1078,13 +1303,14 @@ type packBlobValue struct {
 var errPackEOF = errors.New("reached EOF of pack file")
 
 func newPackBlobIterator(packID restic.ID, rd discardReader, currentOffset uint,
-	blobs []restic.Blob, key *crypto.Key, dec *zstd.Decoder) *packBlobIterator {
+	blobs []restic.Blob, key *crypto.Key, aad blobAADMode, dec *zstd.Decoder) *packBlobIterator {
 	return &packBlobIterator{
 		packID:        packID,
 		rd:            rd,
 		currentOffset: currentOffset,
 		blobs:         blobs,
 		key:           key,
+		aad:           aad,
 		dec:           dec,
 	}
 }
@@ -1127,7 +1353,7 @@ func (b *packBlobIterator) Next() (packBlobValue, error) {
 
 	// decryption errors are likely permanent, give the caller a chance to skip them
 	nonce, ciphertext := buf[:b.key.NonceSize()], buf[b.key.NonceSize():]
-	plaintext, err := b.key.Open(ciphertext[:0], nonce, ciphertext, nil)
+	plaintext, err := b.aad.open(b.key, ciphertext[:0], h, nonce, ciphertext)
 	if err != nil {
 		err = fmt.Errorf("decrypting blob %v from %v failed: %w", h, b.packID.Str(), err)
 	}
@@ -424,6 +424,37 @@ func TestInvalidCompression(t *testing.T) {
 	rtest.Assert(t, err != nil, "missing error")
 }
 
+func TestInvalidCompressionLevel(t *testing.T) {
+	var level repository.CompressionLevel
+	err := level.Set("nope")
+	rtest.Assert(t, err != nil, "missing error")
+	_, err = repository.New(nil, repository.Options{CompressionLevel: level})
+	rtest.Assert(t, err != nil, "missing error")
+}
+
+func TestCompressionLevel(t *testing.T) {
+	data := bytes.Repeat([]byte("compressible "), 1<<14)
+	for _, name := range []string{"fastest", "default", "better", "best"} {
+		t.Run(name, func(t *testing.T) {
+			var level repository.CompressionLevel
+			rtest.OK(t, level.Set(name))
+			rtest.Equals(t, name, level.String())
+			repo, _ := repository.TestRepositoryWithBackend(t, nil, restic.StableRepoVersion, repository.Options{CompressionLevel: level})
+
+			var wg errgroup.Group
+			repo.StartPackUploader(context.TODO(), &wg)
+			id, _, size, err := repo.SaveBlob(context.TODO(), restic.DataBlob, data, restic.ID{}, false)
+			rtest.OK(t, err)
+			rtest.OK(t, repo.Flush(context.Background()))
+			rtest.Assert(t, size < len(data), "data was not compressed")
+
+			buf, err := repo.LoadBlob(context.TODO(), restic.DataBlob, id, nil)
+			rtest.OK(t, err)
+			rtest.Assert(t, bytes.Equal(data, buf), "wrong data returned")
+		})
+	}
+}
+
 func TestListPack(t *testing.T) {
 	be := mem.New()
 	repo, _ := repository.TestRepositoryWithBackend(t, &damageOnceBackend{Backend: be}, restic.StableRepoVersion, repository.Options{})
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// EnableResticCompat restricts the repository to data formats which can be
+// read by upstream restic. Afterwards, the repository can no longer be
+// upgraded to a version which upstream restic does not support. Data which was
+// already saved is not modified.
+func EnableResticCompat(ctx context.Context, repo *Repository) error {
+	cfg := repo.Config()
+	if cfg.ResticCompat {
+		return nil
+	}
+	if cfg.Version > restic.ResticCompatRepoVersion {
+		return fmt.Errorf("repository version %v cannot be read by upstream restic, only versions up to %v are supported", cfg.Version, restic.ResticCompatRepoVersion)
+	}
+
+	cfg.ResticCompat = true
+	return saveConfig(ctx, repo, cfg)
+}
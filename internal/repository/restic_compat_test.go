@@ -0,0 +1,25 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestEnableResticCompat(t *testing.T) {
+	ctx := context.TODO()
+	repo, be := repository.TestRepositoryWithVersion(t, 2)
+	rtest.OK(t, repository.EnableResticCompat(ctx, repo))
+
+	repo = repository.TestOpenBackend(t, be)
+	rtest.Assert(t, repo.Config().ResticCompat, "restic compatibility missing")
+	err := repository.UpgradeRepoV3(ctx, repo)
+	rtest.Assert(t, err != nil, "upgrade of restic compatible repository to version 3 succeeded")
+	rtest.Equals(t, uint(2), repository.TestOpenBackend(t, be).Config().Version)
+
+	repo, _ = repository.TestRepositoryWithVersion(t, 3)
+	err = repository.EnableResticCompat(ctx, repo)
+	rtest.Assert(t, err != nil, "version 3 repository marked as restic compatible")
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// SaveRuleSet stores rs as the new current version of the rule set name in the
+// repository and returns the current version. If the rules are identical to
+// the current version, no new version is created and changed is false.
+func SaveRuleSet(ctx context.Context, repo restic.Unpacked, name string, rs restic.RuleSet) (current restic.RuleSet, changed bool, err error) {
+	if err := restic.ValidateRuleSetName(name); err != nil {
+		return restic.RuleSet{}, false, err
+	}
+
+	ruleSets, err := restic.LoadRuleSets(ctx, repo)
+	if err != nil {
+		return restic.RuleSet{}, false, err
+	}
+	versions := ruleSets[name]
+	rs.Name = name
+	rs.Version = 1
+	if len(versions) > 0 {
+		current := versions[len(versions)-1]
+		if current.Equal(rs) {
+			return current, false, nil
+		}
+		rs.Version = current.Version + 1
+	}
+	rs.Time = time.Now()
+
+	if _, err := restic.SaveRuleSet(ctx, repo, rs); err != nil {
+		return restic.RuleSet{}, false, err
+	}
+	return rs, true, nil
+}
+
+// RemoveRuleSet removes all versions of the rule set name from the repository.
+func RemoveRuleSet(ctx context.Context, repo restic.Unpacked, name string) error {
+	ruleSets, err := restic.LoadRuleSets(ctx, repo)
+	if err != nil {
+		return err
+	}
+	versions, ok := ruleSets[name]
+	if !ok {
+		return fmt.Errorf("rule set %q not found in repository", name)
+	}
+
+	for _, rs := range versions {
+		if err := repo.RemoveUnpacked(ctx, restic.RuleSetFile, *rs.ID()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestSaveRuleSet(t *testing.T) {
+	ctx := context.TODO()
+	repo, be := repository.TestRepositoryWithVersion(t, 2)
+	config, err := repo.LoadRaw(ctx, restic.ConfigFile, restic.ID{})
+	rtest.OK(t, err)
+
+	rs, changed, err := repository.SaveRuleSet(ctx, repo, "corp-default", restic.RuleSet{Excludes: []string{"*.tmp"}})
+	rtest.OK(t, err)
+	rtest.Assert(t, changed, "first version was not saved")
+	rtest.Equals(t, 1, rs.Version)
+
+	// unchanged rules do not create a new version
+	rs, changed, err = repository.SaveRuleSet(ctx, repo, "corp-default", restic.RuleSet{Excludes: []string{"*.tmp"}})
+	rtest.OK(t, err)
+	rtest.Assert(t, !changed, "unchanged rule set was saved")
+	rtest.Equals(t, 1, rs.Version)
+
+	rs, _, err = repository.SaveRuleSet(ctx, repo, "corp-default", restic.RuleSet{Excludes: []string{"*.tmp", "/cache"}})
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, rs.Version)
+	_, _, err = repository.SaveRuleSet(ctx, repo, "other", restic.RuleSet{ExcludePresets: []string{"caches"}})
+	rtest.OK(t, err)
+
+	_, _, err = repository.SaveRuleSet(ctx, repo, "in@valid", restic.RuleSet{})
+	rtest.Assert(t, err != nil, "missing error for invalid name")
+
+	// each version is stored in a separate file, the config is not modified
+	files := 0
+	rtest.OK(t, repo.List(ctx, restic.RuleSetFile, func(restic.ID, int64) error {
+		files++
+		return nil
+	}))
+	rtest.Equals(t, 3, files)
+	newConfig, err := repo.LoadRaw(ctx, restic.ConfigFile, restic.ID{})
+	rtest.OK(t, err)
+	rtest.Equals(t, config, newConfig)
+
+	ruleSets, err := restic.LoadRuleSets(ctx, repository.TestOpenBackend(t, be))
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"corp-default", "other"}, ruleSets.Names())
+	rs, err = ruleSets.Lookup("corp-default@1")
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"*.tmp"}, rs.Excludes)
+
+	rtest.OK(t, repository.RemoveRuleSet(ctx, repo, "corp-default"))
+	err = repository.RemoveRuleSet(ctx, repo, "corp-default")
+	rtest.Assert(t, err != nil, "missing error for removed rule set")
+	ruleSets, err = restic.LoadRuleSets(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"other"}, ruleSets.Names())
+}
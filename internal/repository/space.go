@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+)
+
+// ErrNoSpaceInfo is returned by Space if the backend cannot report its
+// storage space.
+var ErrNoSpaceInfo = errors.New("the backend does not report its storage space")
+
+// Space returns the storage space of the backend.
+func (r *Repository) Space(ctx context.Context) (backend.SpaceInfo, error) {
+	be := backend.AsBackend[backend.SpaceBackend](r.be)
+	if be == nil {
+		return backend.SpaceInfo{}, ErrNoSpaceInfo
+	}
+	return be.Space(ctx)
+}
@@ -0,0 +1,54 @@
+package restic
+
+import (
+	"math"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// ByteRange selects a contiguous part of the content of a file.
+type ByteRange struct {
+	Offset uint64
+	// Length of the range, zero selects everything up to the end of the file.
+	Length uint64
+}
+
+func (r ByteRange) end() uint64 {
+	if r.Length == 0 || r.Length > math.MaxUint64-r.Offset {
+		return math.MaxUint64
+	}
+	return r.Offset + r.Length
+}
+
+// SelectBlobs returns the blobs of content which cover the byte range. It
+// also returns the number of bytes to skip at the start of the first blob and
+// the length of the range, which is shorter than requested if the range
+// extends beyond the end of the file. lookupSize must return the plaintext
+// size of a blob.
+func (r ByteRange) SelectBlobs(content IDs, lookupSize func(BlobType, ID) (uint, bool)) (blobs IDs, skip uint64, length uint64, err error) {
+	end := r.end()
+	first := -1
+	var start uint64
+	for i, id := range content {
+		size, ok := lookupSize(DataBlob, id)
+		if !ok {
+			return nil, 0, 0, errors.Errorf("unknown blob %v", id.Str())
+		}
+
+		blobEnd := start + uint64(size)
+		if first < 0 && blobEnd > r.Offset {
+			first = i
+			skip = r.Offset - start
+		}
+		if first >= 0 && blobEnd >= end {
+			return content[first : i+1], skip, end - r.Offset, nil
+		}
+		start = blobEnd
+	}
+
+	if first < 0 {
+		// range starts beyond the end of the file
+		return IDs{}, 0, 0, nil
+	}
+	return content[first:], skip, start - r.Offset, nil
+}
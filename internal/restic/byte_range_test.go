@@ -0,0 +1,42 @@
+package restic_test
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestByteRangeSelectBlobs(t *testing.T) {
+	content := restic.IDs{restic.NewRandomID(), restic.NewRandomID(), restic.NewRandomID()}
+	sizes := map[restic.ID]uint{content[0]: 100, content[1]: 50, content[2]: 200}
+	lookupSize := func(_ restic.BlobType, id restic.ID) (uint, bool) {
+		size, ok := sizes[id]
+		return size, ok
+	}
+
+	for _, test := range []struct {
+		r      restic.ByteRange
+		blobs  restic.IDs
+		skip   uint64
+		length uint64
+	}{
+		{restic.ByteRange{}, content, 0, 350},
+		{restic.ByteRange{Offset: 0, Length: 100}, content[:1], 0, 100},
+		{restic.ByteRange{Offset: 100, Length: 10}, content[1:2], 0, 10},
+		{restic.ByteRange{Offset: 120, Length: 100}, content[1:], 20, 100},
+		{restic.ByteRange{Offset: 99, Length: 2}, content[:2], 99, 2},
+		{restic.ByteRange{Offset: 300, Length: 100}, content[2:], 150, 50},
+		{restic.ByteRange{Offset: 350}, restic.IDs{}, 0, 0},
+		{restic.ByteRange{Offset: 1000, Length: 10}, restic.IDs{}, 0, 0},
+	} {
+		blobs, skip, length, err := test.r.SelectBlobs(content, lookupSize)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.blobs, blobs)
+		rtest.Equals(t, test.skip, skip)
+		rtest.Equals(t, test.length, length)
+	}
+
+	_, _, _, err := restic.ByteRange{}.SelectBlobs(restic.IDs{restic.NewRandomID()}, lookupSize)
+	rtest.Assert(t, err != nil, "missing error for unknown blob")
+}
@@ -17,10 +17,52 @@ type Config struct {
 	Version           uint        `json:"version"`
 	ID                string      `json:"id"`
 	ChunkerPolynomial chunker.Pol `json:"chunker_polynomial"`
+
+	// CompressionDictionaries contains zstd dictionaries for compressing
+	// small blobs. New blobs use the last dictionary. Requires version 3.
+	CompressionDictionaries [][]byte `json:"compression_dictionaries,omitempty"`
+
+	// Features lists the optional features used by the repository. Clients
+	// refuse to open repositories using features they do not support.
+	// Requires version 3.
+	Features []string `json:"features,omitempty"`
+
+	// ResticCompat restricts the repository to data formats which can be
+	// read by upstream restic, which ignores this field.
+	ResticCompat bool `json:"restic_compat,omitempty"`
+}
+
+// Repository features.
+const (
+	// FeatureBlobAAD binds the encryption of each blob to its type and ID.
+	FeatureBlobAAD = "blob-aad"
+	// FeatureBlobAADTransition is used while a repository is migrated to
+	// FeatureBlobAAD. New blobs are bound to their type and ID, but blobs
+	// without binding are still accepted.
+	FeatureBlobAADTransition = "blob-aad-transition"
+)
+
+var knownFeatures = map[string]struct{}{
+	FeatureBlobAAD:           {},
+	FeatureBlobAADTransition: {},
+}
+
+// HasFeature returns whether the repository uses the feature.
+func (cfg Config) HasFeature(feature string) bool {
+	for _, f := range cfg.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
 }
 
 const MinRepoVersion = 1
-const MaxRepoVersion = 2
+const MaxRepoVersion = 3
+
+// ResticCompatRepoVersion is the highest repository version which can be read
+// by upstream restic.
+const ResticCompatRepoVersion = 2
 
 // StableRepoVersion is the version that is written to the config when a repository
 // is newly created with Init().
@@ -78,6 +120,19 @@ func LoadConfig(ctx context.Context, r LoaderUnpacked) (Config, error) {
 		return Config{}, errors.Errorf("unsupported repository version %v", cfg.Version)
 	}
 
+	if len(cfg.Features) > 0 && cfg.Version < 3 {
+		return Config{}, errors.Errorf("repository features require repository version 3, but repository has version %v", cfg.Version)
+	}
+	for _, f := range cfg.Features {
+		if _, ok := knownFeatures[f]; !ok {
+			return Config{}, errors.Errorf("unsupported repository feature %q", f)
+		}
+	}
+
+	if cfg.ResticCompat && cfg.Version > ResticCompatRepoVersion {
+		return Config{}, errors.Errorf("repository is restricted to formats readable by upstream restic, but has version %v", cfg.Version)
+	}
+
 	if checkPolynomial {
 		if !cfg.ChunkerPolynomial.Irreducible() {
 			return Config{}, errors.New("invalid chunker polynomial")
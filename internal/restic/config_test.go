@@ -60,6 +60,64 @@ func TestConfig(t *testing.T) {
 	cfg2, err := restic.LoadConfig(context.TODO(), loader{load})
 	rtest.OK(t, err)
 
-	rtest.Assert(t, cfg1 == cfg2,
-		"configs aren't equal: %v != %v", cfg1, cfg2)
+	rtest.Equals(t, cfg1, cfg2)
+}
+
+func TestConfigFeatures(t *testing.T) {
+	for _, test := range []struct {
+		version  uint
+		features []string
+		ok       bool
+	}{
+		{3, []string{restic.FeatureBlobAAD}, true},
+		{3, []string{restic.FeatureBlobAADTransition}, true},
+		{3, []string{"unknown"}, false},
+		{2, []string{restic.FeatureBlobAAD}, false},
+	} {
+		cfg, err := restic.CreateConfig(test.version)
+		rtest.OK(t, err)
+		cfg.Features = test.features
+
+		var buf []byte
+		err = restic.SaveConfig(context.TODO(), saver{func(_ restic.FileType, data []byte) (restic.ID, error) {
+			buf = data
+			return restic.ID{}, nil
+		}}, cfg)
+		rtest.OK(t, err)
+
+		cfg2, err := restic.LoadConfig(context.TODO(), loader{func(_ restic.FileType, _ restic.ID) ([]byte, error) {
+			return buf, nil
+		}})
+		if !test.ok {
+			rtest.Assert(t, err != nil, "expected error for version %v with features %v", test.version, test.features)
+			continue
+		}
+		rtest.OK(t, err)
+		rtest.Assert(t, cfg2.HasFeature(test.features[0]), "feature %v missing", test.features[0])
+	}
+}
+
+func TestConfigResticCompat(t *testing.T) {
+	for _, version := range []uint{1, 2, 3} {
+		cfg, err := restic.CreateConfig(version)
+		rtest.OK(t, err)
+		cfg.ResticCompat = true
+
+		var buf []byte
+		err = restic.SaveConfig(context.TODO(), saver{func(_ restic.FileType, data []byte) (restic.ID, error) {
+			buf = data
+			return restic.ID{}, nil
+		}}, cfg)
+		rtest.OK(t, err)
+
+		cfg2, err := restic.LoadConfig(context.TODO(), loader{func(_ restic.FileType, _ restic.ID) ([]byte, error) {
+			return buf, nil
+		}})
+		if version > restic.ResticCompatRepoVersion {
+			rtest.Assert(t, err != nil, "expected error for version %v", version)
+			continue
+		}
+		rtest.OK(t, err)
+		rtest.Assert(t, cfg2.ResticCompat, "restic compatibility was not stored")
+	}
 }
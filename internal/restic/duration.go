@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/restic/restic/internal/errors"
@@ -136,3 +137,8 @@ func (d Duration) Type() string {
 func (d Duration) Zero() bool {
 	return d.Years == 0 && d.Months == 0 && d.Days == 0 && d.Hours == 0
 }
+
+// AddTo returns the time t plus the duration d.
+func (d Duration) AddTo(t time.Time) time.Time {
+	return t.AddDate(d.Years, d.Months, d.Days).Add(time.Hour * time.Duration(d.Hours))
+}
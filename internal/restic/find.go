@@ -58,3 +58,27 @@ func FindUsedBlobs(ctx context.Context, repo Loader, treeIDs IDs, blobs FindBlob
 	})
 	return wg.Wait()
 }
+
+// FindUsedTrees traverses the tree ID and adds all seen tree blobs to the set
+// blobs. In contrast to FindUsedBlobs, data blobs are not added. It is used
+// for snapshots whose data blobs are stored in a different repository.
+func FindUsedTrees(ctx context.Context, repo Loader, treeIDs IDs, blobs FindBlobSet, p *progress.Counter) error {
+	wg, ctx := errgroup.WithContext(ctx)
+	treeStream := StreamTrees(ctx, wg, repo, treeIDs, func(treeID ID) bool {
+		h := BlobHandle{ID: treeID, Type: TreeBlob}
+		blobReferenced := blobs.Has(h)
+		// noop if already referenced
+		blobs.Insert(h)
+		return blobReferenced
+	}, p)
+
+	wg.Go(func() error {
+		for tree := range treeStream {
+			if tree.Error != nil {
+				return tree.Error
+			}
+		}
+		return nil
+	})
+	return wg.Wait()
+}
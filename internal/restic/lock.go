@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"os/user"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
 
 	"github.com/restic/restic/internal/debug"
 )
@@ -31,16 +33,77 @@ const UnlockCancelDelay time.Duration = 1 * time.Minute
 // triggered by regularly calling Refresh.
 type Lock struct {
 	lock      sync.Mutex
-	Time      time.Time `json:"time"`
-	Exclusive bool      `json:"exclusive"`
-	Hostname  string    `json:"hostname"`
-	Username  string    `json:"username"`
-	PID       int       `json:"pid"`
-	UID       uint32    `json:"uid,omitempty"`
-	GID       uint32    `json:"gid,omitempty"`
+	Time      time.Time  `json:"time"`
+	Exclusive bool       `json:"exclusive"`
+	Hostname  string     `json:"hostname"`
+	Username  string     `json:"username"`
+	PID       int        `json:"pid"`
+	UID       uint32     `json:"uid,omitempty"`
+	GID       uint32     `json:"gid,omitempty"`
+	Owner     *LockOwner `json:"owner,omitempty"`
+
+	repo      Unpacked
+	lockID    *ID
+	takenOver []*Lock
+}
+
+// LockOwner identifies the failover cluster resource a lock was created for.
+// A lock held by a cluster node which has failed can be taken over by another
+// node running the same resource.
+type LockOwner struct {
+	Cluster  string `json:"cluster"`
+	Node     string `json:"node"`
+	Resource string `json:"resource"`
+}
+
+func (o LockOwner) String() string {
+	return fmt.Sprintf("resource %v on node %v of cluster %v", o.Resource, o.Node, o.Cluster)
+}
+
+// NewClusterLockOwner returns the lock owner for the failover cluster resource
+// running on the local cluster node.
+func NewClusterLockOwner(resource string) (*LockOwner, error) {
+	cluster, node, err := fs.LocalCluster()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fs.ClusterResourceOwner(resource); err != nil {
+		return nil, err
+	}
+	return &LockOwner{Cluster: cluster, Node: node, Resource: resource}, nil
+}
+
+// clusterNodeFailed reports whether the cluster node of the owner has failed.
+// This is the case if the node is down or if the resource has moved to another
+// node. It can be replaced for tests using TestSetClusterNodeFailed.
+var clusterNodeFailed = func(owner *LockOwner) (bool, error) {
+	cluster, _, err := fs.LocalCluster()
+	if err != nil {
+		return false, err
+	}
+	if !strings.EqualFold(cluster, owner.Cluster) {
+		return false, nil
+	}
 
-	repo   Unpacked
-	lockID *ID
+	down, err := fs.ClusterNodeDown(owner.Node)
+	if err != nil || down {
+		return down, err
+	}
+	node, err := fs.ClusterResourceOwner(owner.Resource)
+	if err != nil {
+		return false, err
+	}
+	return node != "" && !strings.EqualFold(node, owner.Node), nil
+}
+
+// TestSetClusterNodeFailed replaces the check whether a cluster node has
+// failed for the duration of the test.
+func TestSetClusterNodeFailed(t testing.TB, fn func(owner *LockOwner) (bool, error)) {
+	old := clusterNodeFailed
+	clusterNodeFailed = fn
+	t.Cleanup(func() {
+		clusterNodeFailed = old
+	})
 }
 
 // alreadyLockedError is returned when NewLock or NewExclusiveLock are unable to
@@ -91,14 +154,22 @@ var ErrRemovedLock = errors.New("lock file was removed in the meantime")
 // exclusive lock is already held by another process, it returns an error
 // that satisfies IsAlreadyLocked.
 func NewLock(ctx context.Context, repo Unpacked) (*Lock, error) {
-	return newLock(ctx, repo, false)
+	return newLock(ctx, repo, false, nil)
 }
 
 // NewExclusiveLock returns a new, exclusive lock for the repository. If
 // another lock (normal and exclusive) is already held by another process,
 // it returns an error that satisfies IsAlreadyLocked.
 func NewExclusiveLock(ctx context.Context, repo Unpacked) (*Lock, error) {
-	return newLock(ctx, repo, true)
+	return newLock(ctx, repo, true, nil)
+}
+
+// NewClusterLock returns a new lock for the repository which is owned by a
+// failover cluster resource. Conflicting locks of the same resource held by a
+// failed cluster node are removed, see TakenOver. If owner is nil, it behaves
+// like NewLock or NewExclusiveLock.
+func NewClusterLock(ctx context.Context, repo Unpacked, exclusive bool, owner *LockOwner) (*Lock, error) {
+	return newLock(ctx, repo, exclusive, owner)
 }
 
 var waitBeforeLockCheck = 200 * time.Millisecond
@@ -109,11 +180,12 @@ func TestSetLockTimeout(t testing.TB, d time.Duration) {
 	waitBeforeLockCheck = d
 }
 
-func newLock(ctx context.Context, repo Unpacked, excl bool) (*Lock, error) {
+func newLock(ctx context.Context, repo Unpacked, excl bool, owner *LockOwner) (*Lock, error) {
 	lock := &Lock{
 		Time:      time.Now(),
 		PID:       os.Getpid(),
 		Exclusive: excl,
+		Owner:     owner,
 		repo:      repo,
 	}
 
@@ -183,12 +255,16 @@ func (l *Lock) checkForOtherLocks(ctx context.Context) error {
 				return err
 			}
 
-			if l.Exclusive {
-				return &alreadyLockedError{otherLock: lock}
-			}
-
-			if !l.Exclusive && lock.Exclusive {
-				return &alreadyLockedError{otherLock: lock}
+			if l.Exclusive || lock.Exclusive {
+				if !l.canTakeOver(lock) {
+					return &alreadyLockedError{otherLock: lock}
+				}
+				debug.Log("taking over lock %v of failed cluster node %v", id, lock.Owner.Node)
+				if err := l.repo.RemoveUnpacked(ctx, LockFile, id); err != nil {
+					return err
+				}
+				l.takenOver = append(l.takenOver, lock)
+				return nil
 			}
 
 			// valid locks will remain valid
@@ -213,6 +289,31 @@ func (l *Lock) checkForOtherLocks(ctx context.Context) error {
 	return err
 }
 
+// canTakeOver returns true if the other lock was created for the same cluster
+// resource by another cluster node, which has failed.
+func (l *Lock) canTakeOver(other *Lock) bool {
+	if l.Owner == nil || other.Owner == nil {
+		return false
+	}
+	if !strings.EqualFold(l.Owner.Cluster, other.Owner.Cluster) || l.Owner.Resource != other.Owner.Resource ||
+		strings.EqualFold(l.Owner.Node, other.Owner.Node) {
+		return false
+	}
+
+	failed, err := clusterNodeFailed(other.Owner)
+	if err != nil {
+		debug.Log("unable to determine state of cluster node %v: %v", other.Owner.Node, err)
+		return false
+	}
+	return failed
+}
+
+// TakenOver returns the locks of failed cluster nodes which were removed
+// while creating the lock.
+func (l *Lock) TakenOver() []*Lock {
+	return l.takenOver
+}
+
 // createLock acquires the lock by creating a file in the repository.
 func (l *Lock) createLock(ctx context.Context) (ID, error) {
 	id, err := SaveJSONUnpacked(ctx, l.repo, LockFile, l)
@@ -389,6 +490,9 @@ func (l *Lock) String() string {
 		l.PID, l.Hostname, l.Username, l.UID, l.GID,
 		l.Time.Format("2006-01-02 15:04:05"), time.Since(l.Time),
 		l.lockID.Str())
+	if l.Owner != nil {
+		text += fmt.Sprintf("\nowned by %s", l.Owner)
+	}
 
 	return text
 }
@@ -325,3 +325,48 @@ func TestLockRefreshStaleMissing(t *testing.T) {
 	err = lock.RefreshStaleLock(context.TODO())
 	rtest.Assert(t, err == restic.ErrRemovedLock, "unexpected error, expected %v, got %v", restic.ErrRemovedLock, err)
 }
+
+func TestLockClusterTakeover(t *testing.T) {
+	repo := repository.TestRepository(t)
+	restic.TestSetLockTimeout(t, 5*time.Millisecond)
+
+	failed := map[string]bool{"node2": true}
+	restic.TestSetClusterNodeFailed(t, func(owner *restic.LockOwner) (bool, error) {
+		return failed[owner.Node], nil
+	})
+
+	owner := &restic.LockOwner{Cluster: "cluster", Node: "node1", Resource: "backup"}
+	for _, test := range []struct {
+		other    restic.LockOwner
+		takeover bool
+	}{
+		{restic.LockOwner{Cluster: "CLUSTER", Node: "node2", Resource: "backup"}, true},
+		{restic.LockOwner{Cluster: "cluster", Node: "node3", Resource: "backup"}, false},
+		{restic.LockOwner{Cluster: "cluster", Node: "node2", Resource: "other"}, false},
+		{restic.LockOwner{Cluster: "other", Node: "node2", Resource: "backup"}, false},
+	} {
+		other := &restic.Lock{Time: time.Now(), Exclusive: true, Hostname: test.other.Node, PID: 1, Owner: &test.other}
+		id, err := restic.SaveJSONUnpacked(context.TODO(), repo, restic.LockFile, other)
+		rtest.OK(t, err)
+
+		lock, err := restic.NewClusterLock(context.TODO(), repo, false, owner)
+		if !test.takeover {
+			rtest.Assert(t, restic.IsAlreadyLocked(err), "lock of %v was taken over: %v", test.other, err)
+			rtest.OK(t, removeLock(repo, id))
+			continue
+		}
+
+		rtest.OK(t, err)
+		rtest.Assert(t, !lockExists(repo, t, id), "lock of failed node %v still exists", test.other)
+		rtest.Equals(t, 1, len(lock.TakenOver()))
+		rtest.Equals(t, test.other, *lock.TakenOver()[0].Owner)
+		rtest.OK(t, lock.Unlock(context.TODO()))
+	}
+
+	// locks without owner are never taken over
+	id, err := createFakeLock(repo, time.Now(), os.Getpid())
+	rtest.OK(t, err)
+	_, err = restic.NewClusterLock(context.TODO(), repo, true, owner)
+	rtest.Assert(t, restic.IsAlreadyLocked(err), "lock without owner was taken over: %v", err)
+	rtest.OK(t, removeLock(repo, id))
+}
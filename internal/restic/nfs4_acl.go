@@ -0,0 +1,411 @@
+package restic
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// NFSv4ACL is the NFSv4 ACL of a file or directory as used by ZFS on FreeBSD
+// and Solaris. It is stored independently of the representation of the
+// operating system, using the access mask and flag values of RFC 7530, such
+// that it can be restored on either system.
+type NFSv4ACL []NFSv4ACE
+
+// NFSv4ACE is a single access control entry of an NFSv4 ACL. ID is only set
+// for entries with the tags "user" and "group".
+type NFSv4ACE struct {
+	Tag   string  `json:"tag"`
+	ID    *uint32 `json:"id,omitempty"`
+	Type  string  `json:"type"`
+	Mask  uint32  `json:"mask"`
+	Flags uint32  `json:"flags,omitempty"`
+}
+
+// Tags of NFSv4 access control entries.
+const (
+	NFSv4ACLOwner    = "owner@"
+	NFSv4ACLGroupObj = "group@"
+	NFSv4ACLEveryone = "everyone@"
+	NFSv4ACLUser     = "user"
+	NFSv4ACLGroup    = "group"
+)
+
+// Types of NFSv4 access control entries.
+const (
+	NFSv4ACLAllow = "allow"
+	NFSv4ACLDeny  = "deny"
+	NFSv4ACLAudit = "audit"
+	NFSv4ACLAlarm = "alarm"
+)
+
+var nfs4ACETypes = []string{NFSv4ACLAllow, NFSv4ACLDeny, NFSv4ACLAudit, NFSv4ACLAlarm}
+
+// access mask bits, see RFC 7530 section 6.2.1.3
+const (
+	nfs4ReadData        = 0x00000001
+	nfs4WriteData       = 0x00000002
+	nfs4AppendData      = 0x00000004
+	nfs4ReadNamedAttrs  = 0x00000008
+	nfs4WriteNamedAttrs = 0x00000010
+	nfs4Execute         = 0x00000020
+	nfs4DeleteChild     = 0x00000040
+	nfs4ReadAttributes  = 0x00000080
+	nfs4WriteAttributes = 0x00000100
+	nfs4Delete          = 0x00010000
+	nfs4ReadACL         = 0x00020000
+	nfs4WriteACL        = 0x00040000
+	nfs4WriteOwner      = 0x00080000
+	nfs4Synchronize     = 0x00100000
+
+	nfs4MaskAll = nfs4ReadData | nfs4WriteData | nfs4AppendData | nfs4ReadNamedAttrs |
+		nfs4WriteNamedAttrs | nfs4Execute | nfs4DeleteChild | nfs4ReadAttributes |
+		nfs4WriteAttributes | nfs4Delete | nfs4ReadACL | nfs4WriteACL | nfs4WriteOwner | nfs4Synchronize
+)
+
+// flag bits, see RFC 7530 section 6.2.1.4. The identifier group flag is
+// represented by the tag of the entry.
+const (
+	nfs4FileInherit        = 0x00000001
+	nfs4DirectoryInherit   = 0x00000002
+	nfs4NoPropagateInherit = 0x00000004
+	nfs4InheritOnly        = 0x00000008
+	nfs4SuccessfulAccess   = 0x00000010
+	nfs4FailedAccess       = 0x00000020
+	nfs4IdentifierGroup    = 0x00000040
+	nfs4Inherited          = 0x00000080
+
+	nfs4InheritanceFlags = nfs4FileInherit | nfs4DirectoryInherit | nfs4NoPropagateInherit | nfs4InheritOnly
+	nfs4FlagsAll         = nfs4InheritanceFlags | nfs4SuccessfulAccess | nfs4FailedAccess | nfs4Inherited
+)
+
+// Validate checks that the ACL can be restored for a node of type nodeType.
+func (acl NFSv4ACL) Validate(nodeType string) error {
+	if len(acl) == 0 {
+		return fmt.Errorf("empty ACL")
+	}
+
+	for i, ace := range acl {
+		switch ace.Tag {
+		case NFSv4ACLOwner, NFSv4ACLGroupObj, NFSv4ACLEveryone:
+			if ace.ID != nil {
+				return fmt.Errorf("entry %d: unexpected id for %v", i, ace.Tag)
+			}
+		case NFSv4ACLUser, NFSv4ACLGroup:
+			if ace.ID == nil {
+				return fmt.Errorf("entry %d: %v entry without id", i, ace.Tag)
+			}
+		default:
+			return fmt.Errorf("entry %d: unknown tag %q", i, ace.Tag)
+		}
+
+		if nfs4ACETypeValue(ace.Type) < 0 {
+			return fmt.Errorf("entry %d: unknown type %q", i, ace.Type)
+		}
+		if ace.Mask&^nfs4MaskAll != 0 {
+			return fmt.Errorf("entry %d: invalid access mask %#x", i, ace.Mask)
+		}
+		if ace.Flags&^nfs4FlagsAll != 0 {
+			return fmt.Errorf("entry %d: invalid flags %#x", i, ace.Flags)
+		}
+		if ace.Flags&nfs4InheritanceFlags != 0 && nodeType != "dir" {
+			return fmt.Errorf("entry %d: inheritance flags are only allowed for directories", i)
+		}
+	}
+	return nil
+}
+
+// isTrivial reports whether the ACL only contains entries for the owner,
+// group and everyone without flags. File systems derive such ACLs from the
+// mode of a file, thus they need not be stored.
+func (acl NFSv4ACL) isTrivial() bool {
+	for _, ace := range acl {
+		if ace.Tag != NFSv4ACLOwner && ace.Tag != NFSv4ACLGroupObj && ace.Tag != NFSv4ACLEveryone {
+			return false
+		}
+		if ace.Flags != 0 || (ace.Type != NFSv4ACLAllow && ace.Type != NFSv4ACLDeny) {
+			return false
+		}
+	}
+	return true
+}
+
+func nfs4ACETypeValue(name string) int {
+	for i, t := range nfs4ACETypes {
+		if t == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// solarisACE is the ace_t structure used by acl(2) on Solaris. It uses the
+// values of RFC 7530 for the access mask, flags and types.
+type solarisACE struct {
+	Who  uint32
+	Mask uint32
+	Flag uint16
+	Type uint16
+}
+
+// flags of solarisACE which identify the entry
+const (
+	solarisACEOwner    = 0x1000
+	solarisACEGroup    = 0x2000
+	solarisACEEveryone = 0x4000
+)
+
+func nfs4ACLFromSolaris(aces []solarisACE) (NFSv4ACL, error) {
+	acl := make(NFSv4ACL, 0, len(aces))
+	for _, sace := range aces {
+		if int(sace.Type) >= len(nfs4ACETypes) {
+			return nil, fmt.Errorf("unknown ACE type %d", sace.Type)
+		}
+		ace := NFSv4ACE{
+			Type:  nfs4ACETypes[sace.Type],
+			Mask:  sace.Mask,
+			Flags: uint32(sace.Flag) & nfs4FlagsAll,
+		}
+		switch {
+		case sace.Flag&solarisACEOwner != 0:
+			ace.Tag = NFSv4ACLOwner
+		case sace.Flag&solarisACEGroup != 0:
+			ace.Tag = NFSv4ACLGroupObj
+		case sace.Flag&solarisACEEveryone != 0:
+			ace.Tag = NFSv4ACLEveryone
+		case sace.Flag&nfs4IdentifierGroup != 0:
+			ace.Tag = NFSv4ACLGroup
+		default:
+			ace.Tag = NFSv4ACLUser
+		}
+		if ace.Tag == NFSv4ACLUser || ace.Tag == NFSv4ACLGroup {
+			id := sace.Who
+			ace.ID = &id
+		}
+		acl = append(acl, ace)
+	}
+	return acl, nil
+}
+
+func nfs4ACLToSolaris(acl NFSv4ACL) []solarisACE {
+	aces := make([]solarisACE, 0, len(acl))
+	for _, ace := range acl {
+		sace := solarisACE{
+			Who:  0xffffffff,
+			Mask: ace.Mask,
+			Flag: uint16(ace.Flags),
+			Type: uint16(nfs4ACETypeValue(ace.Type)),
+		}
+		switch ace.Tag {
+		case NFSv4ACLOwner:
+			sace.Flag |= solarisACEOwner
+		case NFSv4ACLGroupObj:
+			sace.Flag |= solarisACEGroup | nfs4IdentifierGroup
+		case NFSv4ACLEveryone:
+			sace.Flag |= solarisACEEveryone
+		case NFSv4ACLGroup:
+			sace.Flag |= nfs4IdentifierGroup
+		}
+		if ace.ID != nil {
+			sace.Who = *ace.ID
+		}
+		aces = append(aces, sace)
+	}
+	return aces
+}
+
+// freebsdACLEntry is the acl_entry structure used by the __acl_get_link and
+// __acl_set_link system calls on FreeBSD.
+type freebsdACLEntry struct {
+	Tag       uint32
+	ID        uint32
+	Perm      uint32
+	EntryType uint16
+	Flags     uint16
+}
+
+const (
+	freebsdACLTypeNFS4    = 4
+	freebsdACLMaxEntries  = 254
+	freebsdACLUndefinedID = 0xffffffff
+)
+
+// freebsdACL is the acl structure used by the ACL system calls on FreeBSD.
+type freebsdACL struct {
+	MaxCount uint32
+	Count    uint32
+	Spare    [4]int32
+	Entries  [freebsdACLMaxEntries]freebsdACLEntry
+}
+
+var freebsdACLTags = map[string]uint32{
+	NFSv4ACLOwner:    0x01,
+	NFSv4ACLUser:     0x02,
+	NFSv4ACLGroupObj: 0x04,
+	NFSv4ACLGroup:    0x08,
+	NFSv4ACLEveryone: 0x40,
+}
+
+var freebsdACLEntryTypes = map[string]uint16{
+	NFSv4ACLAllow: 0x100,
+	NFSv4ACLDeny:  0x200,
+	NFSv4ACLAudit: 0x400,
+	NFSv4ACLAlarm: 0x800,
+}
+
+// freebsdACLPerms maps the permission bits of FreeBSD to those of RFC 7530.
+var freebsdACLPerms = []struct{ freebsd, nfs4 uint32 }{
+	{0x0001, nfs4Execute},
+	{0x0008, nfs4ReadData},
+	{0x0010, nfs4WriteData},
+	{0x0020, nfs4AppendData},
+	{0x0040, nfs4ReadNamedAttrs},
+	{0x0080, nfs4WriteNamedAttrs},
+	{0x0100, nfs4DeleteChild},
+	{0x0200, nfs4ReadAttributes},
+	{0x0400, nfs4WriteAttributes},
+	{0x0800, nfs4Delete},
+	{0x1000, nfs4ReadACL},
+	{0x2000, nfs4WriteACL},
+	{0x4000, nfs4WriteOwner},
+	{0x8000, nfs4Synchronize},
+}
+
+func nfs4ACLFromFreeBSD(facl *freebsdACL) (NFSv4ACL, error) {
+	if facl.Count > freebsdACLMaxEntries {
+		return nil, fmt.Errorf("invalid ACL entry count %d", facl.Count)
+	}
+
+	acl := make(NFSv4ACL, 0, facl.Count)
+	for _, entry := range facl.Entries[:facl.Count] {
+		var ace NFSv4ACE
+		for name, value := range freebsdACLTags {
+			if value == entry.Tag {
+				ace.Tag = name
+			}
+		}
+		if ace.Tag == "" {
+			return nil, fmt.Errorf("unknown ACL tag %#x", entry.Tag)
+		}
+		for name, value := range freebsdACLEntryTypes {
+			if value == entry.EntryType {
+				ace.Type = name
+			}
+		}
+		if ace.Type == "" {
+			return nil, fmt.Errorf("unknown ACL entry type %#x", entry.EntryType)
+		}
+		for _, perm := range freebsdACLPerms {
+			if entry.Perm&perm.freebsd != 0 {
+				ace.Mask |= perm.nfs4
+			}
+		}
+		// the flags use the values of RFC 7530
+		ace.Flags = uint32(entry.Flags) & nfs4FlagsAll
+		if ace.Tag == NFSv4ACLUser || ace.Tag == NFSv4ACLGroup {
+			id := entry.ID
+			ace.ID = &id
+		}
+		acl = append(acl, ace)
+	}
+	return acl, nil
+}
+
+func nfs4ACLToFreeBSD(acl NFSv4ACL) (*freebsdACL, error) {
+	if len(acl) > freebsdACLMaxEntries {
+		return nil, fmt.Errorf("ACL has %d entries, at most %d are supported", len(acl), freebsdACLMaxEntries)
+	}
+
+	facl := &freebsdACL{
+		MaxCount: freebsdACLMaxEntries,
+		Count:    uint32(len(acl)),
+	}
+	for i, ace := range acl {
+		entry := freebsdACLEntry{
+			Tag:       freebsdACLTags[ace.Tag],
+			ID:        freebsdACLUndefinedID,
+			EntryType: freebsdACLEntryTypes[ace.Type],
+			Flags:     uint16(ace.Flags),
+		}
+		for _, perm := range freebsdACLPerms {
+			if ace.Mask&perm.nfs4 != 0 {
+				entry.Perm |= perm.freebsd
+			}
+		}
+		if ace.ID != nil {
+			entry.ID = *ace.ID
+		}
+		facl.Entries[i] = entry
+	}
+	return facl, nil
+}
+
+// NFSv4Attributes are the genericAttributes for the NFSv4 ACLs of FreeBSD and
+// Solaris.
+type NFSv4Attributes struct {
+	// ACL is used for storing the NFSv4 ACL of files and directories.
+	ACL *NFSv4ACL `generic:"acl"`
+}
+
+// fillNFSv4ACL stores the ACL returned by getACL for path as generic
+// attribute. Trivial ACLs are not stored.
+// nolint:unused
+func (node *Node) fillNFSv4ACL(path string, getACL func(path string) (NFSv4ACL, error)) error {
+	if node.Type != "file" && node.Type != "dir" {
+		return nil
+	}
+
+	acl, err := getACL(path)
+	if err != nil || len(acl) == 0 || acl.isTrivial() {
+		return err
+	}
+	attrsValue := reflect.ValueOf(NFSv4Attributes{ACL: &acl})
+	node.GenericAttributes, err = osAttrsToGenericAttributes(reflect.TypeOf(NFSv4Attributes{}), &attrsValue, "nfs4")
+	return err
+}
+
+// restoreNFSv4ACL restores the NFSv4 ACL of the node using setACL. As chmod
+// resets the ACL on some file systems, the mode is restored first.
+// nolint:unused
+func (node Node) restoreNFSv4ACL(path string, warn func(msg string), setACL func(path string, acl NFSv4ACL) error) error {
+	if len(node.GenericAttributes) == 0 {
+		return nil
+	}
+	var attrs NFSv4Attributes
+	attrsValue := reflect.ValueOf(&attrs).Elem()
+	unknownAttribs, err := genericAttributesToOSAttrs(node.GenericAttributes, reflect.TypeOf(attrs), &attrsValue, "nfs4")
+	if err != nil {
+		return fmt.Errorf("error parsing generic attribute for: %s : %v", path, err)
+	}
+
+	var errs []error
+	if attrs.ACL != nil && node.Type != "symlink" {
+		if err := fs.Chmod(path, node.Mode); err != nil {
+			errs = append(errs, errors.WithStack(err))
+		}
+		err := attrs.ACL.Validate(node.Type)
+		if err == nil {
+			err = setACL(path, *attrs.ACL)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error restoring NFSv4 ACL for: %s : %v", path, err))
+		}
+	}
+
+	HandleUnknownGenericAttributesFound(unknownAttribs, warn)
+	return errors.CombineErrors(errs...)
+}
+
+// hasNFSv4ACL reports whether an NFSv4 ACL is restored for the node on the
+// current platform.
+func (node Node) hasNFSv4ACL() bool {
+	switch runtime.GOOS {
+	case "freebsd", "solaris", "illumos":
+		_, ok := node.GenericAttributes[TypeNFSv4ACL]
+		return ok && node.Type != "symlink"
+	}
+	return false
+}
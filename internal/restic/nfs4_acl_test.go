@@ -0,0 +1,97 @@
+package restic
+
+import (
+	"os"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func nfs4ACLID(id uint32) *uint32 {
+	return &id
+}
+
+var testNFSv4ACL = NFSv4ACL{
+	{Tag: NFSv4ACLUser, ID: nfs4ACLID(1001), Type: NFSv4ACLDeny, Mask: nfs4WriteData | nfs4AppendData},
+	{Tag: NFSv4ACLGroup, ID: nfs4ACLID(0), Type: NFSv4ACLAllow, Mask: nfs4ReadData | nfs4Execute, Flags: nfs4FileInherit | nfs4DirectoryInherit},
+	{Tag: NFSv4ACLOwner, Type: NFSv4ACLAllow, Mask: nfs4MaskAll},
+	{Tag: NFSv4ACLGroupObj, Type: NFSv4ACLAllow, Mask: nfs4ReadData | nfs4ReadACL | nfs4Synchronize, Flags: nfs4Inherited},
+	{Tag: NFSv4ACLEveryone, Type: NFSv4ACLAudit, Mask: nfs4Delete, Flags: nfs4FailedAccess},
+}
+
+func TestNFSv4ACLSolaris(t *testing.T) {
+	aces := nfs4ACLToSolaris(testNFSv4ACL)
+	rtest.Equals(t, solarisACE{Who: 0, Mask: nfs4ReadData | nfs4Execute, Flag: 0x43, Type: 0}, aces[1])
+	rtest.Equals(t, solarisACE{Who: 0xffffffff, Mask: nfs4ReadData | nfs4ReadACL | nfs4Synchronize, Flag: 0x20c0, Type: 0}, aces[3])
+
+	acl, err := nfs4ACLFromSolaris(aces)
+	rtest.OK(t, err)
+	rtest.Equals(t, testNFSv4ACL, acl)
+
+	_, err = nfs4ACLFromSolaris([]solarisACE{{Type: 4}})
+	rtest.Assert(t, err != nil, "missing error for unknown type")
+}
+
+func TestNFSv4ACLFreeBSD(t *testing.T) {
+	facl, err := nfs4ACLToFreeBSD(testNFSv4ACL)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint32(len(testNFSv4ACL)), facl.Count)
+	rtest.Equals(t, freebsdACLEntry{Tag: 0x08, ID: 0, Perm: 0x0009, EntryType: 0x100, Flags: 0x03}, facl.Entries[1])
+	rtest.Equals(t, freebsdACLEntry{Tag: 0x01, ID: freebsdACLUndefinedID, Perm: 0xfff9, EntryType: 0x100}, facl.Entries[2])
+
+	acl, err := nfs4ACLFromFreeBSD(facl)
+	rtest.OK(t, err)
+	rtest.Equals(t, testNFSv4ACL, acl)
+
+	facl.Entries[0].Tag = 0x10
+	_, err = nfs4ACLFromFreeBSD(facl)
+	rtest.Assert(t, err != nil, "missing error for unknown tag")
+
+	_, err = nfs4ACLToFreeBSD(make(NFSv4ACL, freebsdACLMaxEntries+1))
+	rtest.Assert(t, err != nil, "missing error for too many entries")
+}
+
+func TestNFSv4ACLValidate(t *testing.T) {
+	rtest.OK(t, testNFSv4ACL.Validate("dir"))
+	// inheritance flags are only allowed for directories
+	rtest.Assert(t, testNFSv4ACL.Validate("file") != nil, "missing error for inheritance flags on file")
+
+	for _, acl := range []NFSv4ACL{
+		{},
+		{{Tag: "other", Type: NFSv4ACLAllow}},
+		{{Tag: NFSv4ACLUser, Type: NFSv4ACLAllow}},
+		{{Tag: NFSv4ACLOwner, ID: nfs4ACLID(1), Type: NFSv4ACLAllow}},
+		{{Tag: NFSv4ACLOwner, Type: "permit"}},
+		{{Tag: NFSv4ACLOwner, Type: NFSv4ACLAllow, Mask: 0x200}},
+		{{Tag: NFSv4ACLOwner, Type: NFSv4ACLAllow, Flags: nfs4IdentifierGroup}},
+	} {
+		rtest.Assert(t, acl.Validate("dir") != nil, "missing error for %v", acl)
+	}
+}
+
+func TestNFSv4ACLTrivial(t *testing.T) {
+	trivial := NFSv4ACL{
+		{Tag: NFSv4ACLOwner, Type: NFSv4ACLAllow, Mask: nfs4ReadData | nfs4WriteData},
+		{Tag: NFSv4ACLGroupObj, Type: NFSv4ACLAllow, Mask: nfs4ReadData},
+		{Tag: NFSv4ACLEveryone, Type: NFSv4ACLAllow, Mask: nfs4ReadData},
+	}
+	rtest.Assert(t, trivial.isTrivial(), "ACL %v should be trivial", trivial)
+	rtest.Assert(t, !testNFSv4ACL.isTrivial(), "ACL %v should not be trivial", testNFSv4ACL)
+}
+
+func TestNFSv4ACLGenericAttributes(t *testing.T) {
+	node := Node{Type: "dir", Mode: os.ModeDir | 0700}
+	rtest.OK(t, node.fillNFSv4ACL("/path", func(string) (NFSv4ACL, error) {
+		return testNFSv4ACL, nil
+	}))
+	_, ok := node.GenericAttributes[TypeNFSv4ACL]
+	rtest.Assert(t, ok, "missing generic attribute in %v", node.GenericAttributes)
+
+	var restored NFSv4ACL
+	rtest.OK(t, node.restoreNFSv4ACL(t.TempDir(), func(msg string) { t.Errorf("unexpected warning: %v", msg) },
+		func(_ string, acl NFSv4ACL) error {
+			restored = acl
+			return nil
+		}))
+	rtest.Equals(t, testNFSv4ACL, restored)
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/user"
 	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -50,13 +51,48 @@ const (
 	TypeFileAttributes GenericAttributeType = "windows.file_attributes"
 	// TypeSecurityDescriptor is the GenericAttributeType used for storing security descriptors including owner, group, discretionary access control list (DACL), system access control list (SACL)) for windows files within the generic attributes map.
 	TypeSecurityDescriptor GenericAttributeType = "windows.security_descriptor"
+	// TypeObjectID is the GenericAttributeType used for storing the NTFS object ID including the birth IDs for distributed link tracking for windows files within the generic attributes map.
+	TypeObjectID GenericAttributeType = "windows.object_id"
+	// TypeEFSMetadata is the GenericAttributeType used for storing the certificates of the users and recovery agents which can decrypt an EFS-encrypted windows file within the generic attributes map.
+	TypeEFSMetadata GenericAttributeType = "windows.efs_metadata"
+	// TypeEFSRaw is the GenericAttributeType used for marking EFS-encrypted windows files whose content was saved as the raw encrypted backup stream within the generic attributes map.
+	TypeEFSRaw GenericAttributeType = "windows.efs_raw"
+	// TypeReparseTag is the GenericAttributeType used for storing the reparse tag of junctions and volume mount points, which are saved as symlinks, within the generic attributes map.
+	TypeReparseTag GenericAttributeType = "windows.reparse_tag"
+	// TypeOfflineStub is the GenericAttributeType used for marking offline windows files of which only the metadata was saved within the generic attributes map.
+	TypeOfflineStub GenericAttributeType = "windows.offline_stub"
+
+	// Below are linux specific attributes.
+
+	// TypePosixACL is the GenericAttributeType used for storing the access and default POSIX ACLs for linux files within the generic attributes map.
+	TypePosixACL GenericAttributeType = "linux.posix_acl"
+	// TypeInodeFlags is the GenericAttributeType used for storing the inode flags of linux files set by chattr, like immutable or nodump, within the generic attributes map.
+	TypeInodeFlags GenericAttributeType = "linux.inode_flags"
+	// TypeSELinuxContext is the GenericAttributeType used for storing the SELinux context of linux files, which is kept in the security.selinux extended attribute, within the generic attributes map.
+	TypeSELinuxContext GenericAttributeType = "linux.selinux_context"
+	// TypeCapabilities is the GenericAttributeType used for storing the file capabilities of linux executables, which are kept in the security.capability extended attribute, within the generic attributes map.
+	TypeCapabilities GenericAttributeType = "linux.capabilities"
+
+	// Below are macOS specific attributes.
+
+	// TypeFileFlags is the GenericAttributeType used for storing the flags of macOS files set by chflags, like UF_HIDDEN or SF_IMMUTABLE, within the generic attributes map.
+	TypeFileFlags GenericAttributeType = "darwin.file_flags"
+	// TypeQuarantine is the GenericAttributeType used for storing the parsed com.apple.quarantine extended attribute of macOS files within the generic attributes map.
+	TypeQuarantine GenericAttributeType = "darwin.quarantine"
+	// TypeResourceFork is the GenericAttributeType used for storing the resource fork of macOS files within the generic attributes map.
+	TypeResourceFork GenericAttributeType = "darwin.resource_fork"
+
+	// Below are attributes shared by FreeBSD and Solaris.
+
+	// TypeNFSv4ACL is the GenericAttributeType used for storing the NFSv4 ACL of files on FreeBSD and Solaris within the generic attributes map.
+	TypeNFSv4ACL GenericAttributeType = "nfs4.acl"
 
 	// Generic Attributes for other OS types should be defined here.
 )
 
 // init is called when the package is initialized. Any new GenericAttributeTypes being created must be added here as well.
 func init() {
-	storeGenericAttributeType(TypeCreationTime, TypeFileAttributes, TypeSecurityDescriptor)
+	storeGenericAttributeType(TypeCreationTime, TypeFileAttributes, TypeSecurityDescriptor, TypeObjectID, TypeEFSMetadata, TypeEFSRaw, TypeReparseTag, TypeOfflineStub, TypePosixACL, TypeInodeFlags, TypeSELinuxContext, TypeCapabilities, TypeFileFlags, TypeQuarantine, TypeResourceFork, TypeNFSv4ACL)
 }
 
 // genericAttributesForOS maintains a map of known genericAttributesForOS to the OSType
@@ -97,7 +133,10 @@ type Node struct {
 	GenericAttributes  map[GenericAttributeType]json.RawMessage `json:"generic_attributes,omitempty"`
 	Device             uint64                                   `json:"device,omitempty"` // in case of Type == "dev", stat.st_rdev
 	Content            IDs                                      `json:"content"`
-	Subtree            *ID                                      `json:"subtree,omitempty"`
+	// ContentSHA256 is the SHA-256 hash of the whole file content, computed
+	// while chunking the file. It is not set for files saved by older versions.
+	ContentSHA256 *ID `json:"content_sha256,omitempty"`
+	Subtree       *ID `json:"subtree,omitempty"`
 
 	Error string `json:"error,omitempty"`
 
@@ -149,6 +188,11 @@ func NodeFromFileInfo(path string, fi os.FileInfo, ignoreXattrListError bool) (*
 	if node.Type == "file" {
 		node.Size = uint64(fi.Size())
 	}
+	if node.Type != "symlink" && fi.IsDir() && fs.IsMountPoint(path, fi) {
+		// junctions and volume mount points are saved like symlinks
+		node.Type = "symlink"
+		node.Mode = node.Mode&os.ModePerm | os.ModeSymlink
+	}
 
 	err := node.fillExtra(path, fi, ignoreXattrListError)
 	return node, err
@@ -188,7 +232,8 @@ func (node Node) GetExtendedAttribute(a string) []byte {
 }
 
 // CreateAt creates the node at the given path but does NOT restore node meta data.
-func (node *Node) CreateAt(ctx context.Context, path string, repo BlobLoader) error {
+// Problems which do not prevent creating the node are reported using warn.
+func (node *Node) CreateAt(ctx context.Context, path string, repo BlobLoader, warn func(msg string)) error {
 	debug.Log("create node %v at %v", node.Name, path)
 
 	switch node.Type {
@@ -201,7 +246,7 @@ func (node *Node) CreateAt(ctx context.Context, path string, repo BlobLoader) er
 			return err
 		}
 	case "symlink":
-		if err := node.createSymlinkAt(path); err != nil {
+		if err := node.createSymlinkAt(path, warn); err != nil {
 			return err
 		}
 	case "dev":
@@ -235,6 +280,165 @@ func (node Node) RestoreMetadata(path string, warn func(msg string)) error {
 	return err
 }
 
+// VerifySecurityDescriptor compares the security descriptor stored for the node
+// with the one of the file at path and returns an error describing any
+// differences. Nodes without security descriptor are not verified.
+func (node Node) VerifySecurityDescriptor(path string) error {
+	raw, ok := node.GenericAttributes[TypeSecurityDescriptor]
+	if !ok {
+		return nil
+	}
+	var sd []byte
+	if err := json.Unmarshal(raw, &sd); err != nil {
+		return fmt.Errorf("error parsing security descriptor for: %s : %v", path, err)
+	}
+	return fs.VerifySecurityDescriptor(path, sd)
+}
+
+// SetDACLProtected sets or clears the flag of the stored security descriptor
+// which blocks inheriting permissions from the parent directory. Nodes without
+// security descriptor are unchanged.
+func (node *Node) SetDACLProtected(protected bool) error {
+	raw, ok := node.GenericAttributes[TypeSecurityDescriptor]
+	if !ok {
+		return nil
+	}
+	var sd []byte
+	if err := json.Unmarshal(raw, &sd); err != nil {
+		return fmt.Errorf("error parsing security descriptor for: %s : %v", node.Name, err)
+	}
+	sd, err := fs.SetDACLProtected(sd, protected)
+	if err != nil {
+		return fmt.Errorf("error changing security descriptor for: %s : %v", node.Name, err)
+	}
+	raw, err = json.Marshal(sd)
+	if err != nil {
+		return err
+	}
+	node.replaceGenericAttribute(TypeSecurityDescriptor, raw)
+	return nil
+}
+
+// replaceGenericAttribute sets the generic attribute of type t to raw on a copy
+// of the generic attributes, as they may be shared with other copies of the
+// node.
+func (node *Node) replaceGenericAttribute(t GenericAttributeType, raw json.RawMessage) {
+	attrs := make(map[GenericAttributeType]json.RawMessage, len(node.GenericAttributes)+1)
+	for k, v := range node.GenericAttributes {
+		attrs[k] = v
+	}
+	attrs[t] = raw
+	node.GenericAttributes = attrs
+}
+
+// EFSRawInfo is stored for EFS-encrypted files whose content is the raw
+// encrypted backup stream instead of the decrypted data.
+type EFSRawInfo struct {
+	// Size is the size of the decrypted file, Node.Size is the size of the
+	// backup stream.
+	Size uint64 `json:"size"`
+}
+
+// EFSRaw returns the information stored for a file whose content is the raw
+// encrypted backup stream. It returns nil for all other nodes.
+func (node Node) EFSRaw() *EFSRawInfo {
+	raw, ok := node.GenericAttributes[TypeEFSRaw]
+	if !ok {
+		return nil
+	}
+	var info EFSRawInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		debug.Log("invalid %v attribute of %v: %v", TypeEFSRaw, node.Name, err)
+		return nil
+	}
+	return &info
+}
+
+// MarkEFSRaw records that the content of the file is saved as the raw
+// encrypted backup stream. It must be called before the content is saved, as
+// the size of the decrypted file is taken from node.Size.
+func (node *Node) MarkEFSRaw() error {
+	raw, err := json.Marshal(EFSRawInfo{Size: node.Size})
+	if err != nil {
+		return err
+	}
+	node.replaceGenericAttribute(TypeEFSRaw, raw)
+	return nil
+}
+
+// MountPoint returns whether the node is a junction or a volume mount point,
+// which is saved as a symlink to its target.
+func (node Node) MountPoint() bool {
+	raw, ok := node.GenericAttributes[TypeReparseTag]
+	if !ok {
+		return false
+	}
+	var tag uint32
+	if err := json.Unmarshal(raw, &tag); err != nil {
+		debug.Log("invalid %v attribute of %v: %v", TypeReparseTag, node.Name, err)
+		return false
+	}
+	return tag == fs.ReparseTagMountPoint
+}
+
+// createMountPointAt recreates the junction or volume mount point at path.
+// Volumes are identified by a GUID which differs on each system, thus volume
+// mount points are restored as empty directories if the volume is missing.
+func (node Node) createMountPointAt(path string, warn func(msg string)) error {
+	err := fs.CreateMountPoint(node.LinkTarget, path)
+	if err == nil || !strings.HasPrefix(node.LinkTarget, `\\?\Volume{`) {
+		return errors.WithStack(err)
+	}
+
+	warn(fmt.Sprintf("%v: unable to mount volume %v, restored as empty directory: %v", path, node.LinkTarget, err))
+	return errors.WithStack(fs.MkdirAll(path, 0700))
+}
+
+// OfflineStubInfo is stored for offline files of which only the metadata was
+// saved, to avoid recalling their content from a hierarchical storage
+// management system.
+type OfflineStubInfo struct {
+	// Size is the size of the offline file, Node.Size is zero.
+	Size uint64 `json:"size"`
+}
+
+// OfflineStub returns the information stored for an offline file of which
+// only the metadata was saved. It returns nil for all other nodes.
+func (node Node) OfflineStub() *OfflineStubInfo {
+	raw, ok := node.GenericAttributes[TypeOfflineStub]
+	if !ok {
+		return nil
+	}
+	var info OfflineStubInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		debug.Log("invalid %v attribute of %v: %v", TypeOfflineStub, node.Name, err)
+		return nil
+	}
+	return &info
+}
+
+// MarkOfflineStub records that only the metadata of the offline file was
+// saved. The size of the file is taken from node.Size, which is then reset
+// along with the content.
+func (node *Node) MarkOfflineStub() error {
+	raw, err := json.Marshal(OfflineStubInfo{Size: node.Size})
+	if err != nil {
+		return err
+	}
+	node.replaceGenericAttribute(TypeOfflineStub, raw)
+	node.Size = 0
+	node.Content = IDs{}
+	return nil
+}
+
+// TranslatePermissions synthesizes the permissions of a node which was backed
+// up on a different operating system. On Windows, nodes without a security
+// descriptor get one which approximates their mode. On other systems, the
+// mode of nodes with a security descriptor is derived from its DACL.
+func (node *Node) TranslatePermissions() error {
+	return node.translatePermissions()
+}
+
 func (node Node) restoreMetadata(path string, warn func(msg string)) error {
 	var firsterr error
 
@@ -258,14 +462,14 @@ func (node Node) restoreMetadata(path string, warn func(msg string)) error {
 
 	if err := node.restoreExtendedAttributes(path); err != nil {
 		debug.Log("error restoring extended attributes for %v: %v", path, err)
-		if firsterr != nil {
+		if firsterr == nil {
 			firsterr = err
 		}
 	}
 
 	if err := node.restoreGenericAttributes(path, warn); err != nil {
 		debug.Log("error restoring generic attributes for %v: %v", path, err)
-		if firsterr != nil {
+		if firsterr == nil {
 			firsterr = err
 		}
 	}
@@ -273,7 +477,9 @@ func (node Node) restoreMetadata(path string, warn func(msg string)) error {
 	// Moving RestoreTimestamps and restoreExtendedAttributes calls above as for readonly files in windows
 	// calling Chmod below will no longer allow any modifications to be made on the file and the
 	// calls above would fail.
-	if node.Type != "symlink" {
+	// The mode of nodes with an NFSv4 ACL was already restored together with
+	// the ACL, as chmod would reset the ACL.
+	if node.Type != "symlink" && !node.hasNFSv4ACL() {
 		if err := fs.Chmod(path, node.Mode); err != nil {
 			if firsterr != nil {
 				firsterr = errors.WithStack(err)
@@ -281,6 +487,15 @@ func (node Node) restoreMetadata(path string, warn func(msg string)) error {
 		}
 	}
 
+	// flags like immutable prevent all further changes, thus they are
+	// restored last
+	if err := node.restoreFileFlags(path); err != nil {
+		debug.Log("error restoring file flags for %v: %v", path, err)
+		if firsterr == nil {
+			firsterr = err
+		}
+	}
+
 	return firsterr
 }
 
@@ -347,15 +562,23 @@ func (node Node) writeNodeContent(ctx context.Context, repo BlobLoader, f *os.Fi
 	return nil
 }
 
-func (node Node) createSymlinkAt(path string) error {
+func (node Node) createSymlinkAt(path string, warn func(msg string)) error {
 
 	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
 		return errors.Wrap(err, "Symlink")
 	}
 
-	if err := fs.Symlink(node.LinkTarget, path); err != nil {
+	if node.MountPoint() && runtime.GOOS == "windows" {
+		return node.createMountPointAt(path, warn)
+	}
+
+	junction, err := fs.SymlinkOrJunction(node.LinkTarget, path)
+	if err != nil {
 		return errors.WithStack(err)
 	}
+	if junction {
+		warn(fmt.Sprintf("%v: creating symlinks requires administrator privileges or developer mode, restored as junction to %v", path, node.LinkTarget))
+	}
 
 	return nil
 }
@@ -481,24 +704,17 @@ func (node Node) Equals(other Node) bool {
 	if !node.sameContent(other) {
 		return false
 	}
+	if !sameOptionalID(node.ContentSHA256, other.ContentSHA256) {
+		return false
+	}
 	if !node.sameExtendedAttributes(other) {
 		return false
 	}
 	if !node.sameGenericAttributes(other) {
 		return false
 	}
-	if node.Subtree != nil {
-		if other.Subtree == nil {
-			return false
-		}
-
-		if !node.Subtree.Equal(*other.Subtree) {
-			return false
-		}
-	} else {
-		if other.Subtree != nil {
-			return false
-		}
+	if !sameOptionalID(node.Subtree, other.Subtree) {
+		return false
 	}
 	if node.Error != other.Error {
 		return false
@@ -507,6 +723,13 @@ func (node Node) Equals(other Node) bool {
 	return true
 }
 
+func sameOptionalID(a, b *ID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
 func (node Node) sameContent(other Node) bool {
 	if node.Content == nil {
 		return other.Content == nil
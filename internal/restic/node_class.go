@@ -0,0 +1,47 @@
+package restic
+
+import (
+	"runtime"
+	"strings"
+)
+
+// NodeClass determines how a node is accounted for in progress reports and
+// summaries.
+type NodeClass int
+
+const (
+	// MainNode is a file, directory or other entry of a file system.
+	MainNode NodeClass = iota
+	// StreamNode is an alternate data stream of a file on Windows. It is
+	// stored as a node named "file:stream" next to the node of its file.
+	StreamNode
+)
+
+func (c NodeClass) String() string {
+	switch c {
+	case MainNode:
+		return "main"
+	case StreamNode:
+		return "stream"
+	}
+	return "unknown"
+}
+
+// ClassifyNode returns the class of the node with the given name. The name
+// must not contain directories.
+func ClassifyNode(name string) NodeClass {
+	return classifyNode(runtime.GOOS, name)
+}
+
+func classifyNode(goos, name string) NodeClass {
+	if goos == "windows" && strings.Contains(name, ":") {
+		return StreamNode
+	}
+	return MainNode
+}
+
+// IsMainFile reports whether name is the name of a main node, that is not
+// an alternate data stream.
+func IsMainFile(name string) bool {
+	return ClassifyNode(name) == MainNode
+}
@@ -0,0 +1,22 @@
+package restic
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestClassifyNode(t *testing.T) {
+	for _, test := range []struct {
+		goos, name string
+		class      NodeClass
+	}{
+		{"windows", "file.txt", MainNode},
+		{"windows", "file.txt:stream", StreamNode},
+		{"windows", "file.txt:stream:$DATA", StreamNode},
+		{"linux", "file.txt:stream", MainNode},
+		{"darwin", "file.txt", MainNode},
+	} {
+		rtest.Equals(t, test.class, classifyNode(test.goos, test.name))
+	}
+}
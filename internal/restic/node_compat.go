@@ -0,0 +1,131 @@
+package restic
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// resticGenericAttributes are the generic attributes which are supported by
+// upstream restic. All other generic attributes are specific to this fork.
+var resticGenericAttributes = map[GenericAttributeType]struct{}{
+	TypeCreationTime:       {},
+	TypeFileAttributes:     {},
+	TypeSecurityDescriptor: {},
+}
+
+// IsResticGenericAttribute reports whether the generic attribute t is
+// supported by upstream restic.
+func IsResticGenericAttribute(t GenericAttributeType) bool {
+	_, ok := resticGenericAttributes[t]
+	return ok
+}
+
+// ResticIncompatible returns a description for each part of the node which
+// upstream restic cannot read. The content of files saved as raw EFS backup
+// stream or as offline stub differs from the file restored by upstream restic.
+func (node Node) ResticIncompatible() []string {
+	var parts []string
+	if node.EFSRaw() != nil {
+		parts = append(parts, "content saved as raw EFS backup stream")
+	}
+	if node.OfflineStub() != nil {
+		parts = append(parts, "content of offline file not saved")
+	}
+	return parts
+}
+
+// ResticCompatible returns a copy of the node which only contains data
+// structures supported by upstream restic. Generic attributes which replace
+// extended attributes, like POSIX ACLs or the SELinux context, are converted
+// back to extended attributes. All other generic attributes specific to this
+// fork and the content hash are removed. An error is returned for nodes whose
+// content cannot be read by upstream restic.
+func (node *Node) ResticCompatible() (*Node, error) {
+	if parts := node.ResticIncompatible(); len(parts) > 0 {
+		return nil, errors.Errorf("%v: %v", node.Name, parts[0])
+	}
+
+	compat := *node
+	compat.ContentSHA256 = nil
+	compat.GenericAttributes = nil
+	compat.ExtendedAttributes = append([]ExtendedAttribute(nil), node.ExtendedAttributes...)
+
+	// the extended attributes must be added in a stable order, otherwise
+	// the tree changes with each backup
+	types := make([]GenericAttributeType, 0, len(node.GenericAttributes))
+	for t := range node.GenericAttributes {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	for _, t := range types {
+		raw := node.GenericAttributes[t]
+		if IsResticGenericAttribute(t) {
+			if compat.GenericAttributes == nil {
+				compat.GenericAttributes = make(map[GenericAttributeType]json.RawMessage)
+			}
+			compat.GenericAttributes[t] = raw
+			continue
+		}
+
+		xattrs, err := genericAttributeToXattrs(t, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid %v attribute: %w", node.Name, t, err)
+		}
+		compat.ExtendedAttributes = append(compat.ExtendedAttributes, xattrs...)
+	}
+	return &compat, nil
+}
+
+// genericAttributeToXattrs returns the extended attributes the generic
+// attribute was created from. It returns nil for generic attributes which do
+// not correspond to extended attributes.
+func genericAttributeToXattrs(t GenericAttributeType, raw json.RawMessage) ([]ExtendedAttribute, error) {
+	switch t {
+	case TypePosixACL:
+		var acl PosixACL
+		if err := json.Unmarshal(raw, &acl); err != nil {
+			return nil, err
+		}
+		var xattrs []ExtendedAttribute
+		if len(acl.Access) > 0 {
+			xattrs = append(xattrs, ExtendedAttribute{Name: posixACLAccessAttribute, Value: encodePosixACL(acl.Access)})
+		}
+		if len(acl.Default) > 0 {
+			xattrs = append(xattrs, ExtendedAttribute{Name: posixACLDefaultAttribute, Value: encodePosixACL(acl.Default)})
+		}
+		return xattrs, nil
+
+	case TypeSELinuxContext:
+		var context string
+		if err := json.Unmarshal(raw, &context); err != nil {
+			return nil, err
+		}
+		return []ExtendedAttribute{{Name: selinuxAttribute, Value: append([]byte(context), 0)}}, nil
+
+	case TypeCapabilities:
+		var caps []byte
+		if err := json.Unmarshal(raw, &caps); err != nil {
+			return nil, err
+		}
+		return []ExtendedAttribute{{Name: capabilityAttribute, Value: caps}}, nil
+
+	case TypeQuarantine:
+		var q Quarantine
+		if err := json.Unmarshal(raw, &q); err != nil {
+			return nil, err
+		}
+		return []ExtendedAttribute{{Name: quarantineAttribute, Value: q.Value()}}, nil
+
+	case TypeResourceFork:
+		var fork []byte
+		if err := json.Unmarshal(raw, &fork); err != nil {
+			return nil, err
+		}
+		return []ExtendedAttribute{{Name: resourceForkAttribute, Value: fork}}, nil
+	}
+	return nil, nil
+}
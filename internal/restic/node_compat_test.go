@@ -0,0 +1,59 @@
+package restic
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestNodeResticCompatible(t *testing.T) {
+	attrs := map[GenericAttributeType]interface{}{
+		TypeFileAttributes: uint32(32),
+		TypeInodeFlags:     uint32(0x10),
+		TypeSELinuxContext: "system_u:object_r:bin_t:s0",
+		TypeCapabilities:   []byte{1, 2, 3},
+		TypePosixACL: PosixACL{Access: []PosixACLEntry{
+			{Tag: PosixACLUserObj, Perm: 7},
+			{Tag: PosixACLGroupObj, Perm: 5},
+			{Tag: PosixACLOther, Perm: 0},
+		}},
+		TypeQuarantine: Quarantine{Flags: 0x83, Timestamp: time.Unix(1705308610, 0).UTC(), Agent: "Safari"},
+	}
+
+	hash := NewRandomID()
+	node := &Node{
+		Name:               "file",
+		Type:               "file",
+		ContentSHA256:      &hash,
+		ExtendedAttributes: []ExtendedAttribute{{Name: "user.foo", Value: []byte("bar")}},
+		GenericAttributes:  make(map[GenericAttributeType]json.RawMessage),
+	}
+	for typ, v := range attrs {
+		raw, err := json.Marshal(v)
+		rtest.OK(t, err)
+		node.GenericAttributes[typ] = raw
+	}
+
+	compat, err := node.ResticCompatible()
+	rtest.OK(t, err)
+	rtest.Assert(t, compat.ContentSHA256 == nil, "content hash was not removed")
+	rtest.Equals(t, 1, len(compat.GenericAttributes))
+	rtest.Equals(t, node.GenericAttributes[TypeFileAttributes], compat.GenericAttributes[TypeFileAttributes])
+	rtest.Equals(t, []ExtendedAttribute{
+		{Name: "user.foo", Value: []byte("bar")},
+		{Name: quarantineAttribute, Value: []byte("0083;65a4f1c2;Safari;")},
+		{Name: capabilityAttribute, Value: []byte{1, 2, 3}},
+		{Name: posixACLAccessAttribute, Value: encodePosixACL(attrs[TypePosixACL].(PosixACL).Access)},
+		{Name: selinuxAttribute, Value: []byte("system_u:object_r:bin_t:s0\x00")},
+	}, compat.ExtendedAttributes)
+
+	// the original node is not modified
+	rtest.Equals(t, len(attrs), len(node.GenericAttributes))
+	rtest.Equals(t, 1, len(node.ExtendedAttributes))
+
+	rtest.OK(t, node.MarkEFSRaw())
+	_, err = node.ResticCompatible()
+	rtest.Assert(t, err != nil, "raw EFS file converted")
+}
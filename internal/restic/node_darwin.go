@@ -1,6 +1,29 @@
 package restic
 
-import "syscall"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"syscall"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/unix"
+)
+
+// DarwinAttributes are the genericAttributes for macOS
+type DarwinAttributes struct {
+	// FileFlags are the flags set by chflags, like UF_HIDDEN or SF_IMMUTABLE.
+	FileFlags *uint32 `generic:"file_flags"`
+	// Quarantine is the parsed com.apple.quarantine extended attribute.
+	Quarantine *Quarantine `generic:"quarantine"`
+	// ResourceFork is the content of the resource fork of a file.
+	ResourceFork *[]byte `generic:"resource_fork"`
+}
+
+// systemFileFlags are the flags which can only be changed by root.
+const systemFileFlags = 0xffff0000
 
 func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespec) error {
 	return nil
@@ -9,3 +32,102 @@ func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespe
 func (s statT) atim() syscall.Timespec { return s.Atimespec }
 func (s statT) mtim() syscall.Timespec { return s.Mtimespec }
 func (s statT) ctim() syscall.Timespec { return s.Ctimespec }
+
+// fillGenericAttributes fills in the file flags, the quarantine attribute and
+// the resource fork on darwin.
+func (node *Node) fillGenericAttributes(path string, _ os.FileInfo, stat *statT) (allowExtended bool, err error) {
+	var attrs DarwinAttributes
+	if stat.Flags != 0 {
+		flags := stat.Flags
+		attrs.FileFlags = &flags
+	}
+
+	value, err := getxattr(path, quarantineAttribute)
+	if err != nil {
+		return true, err
+	}
+	if value != nil {
+		attrs.Quarantine, err = ParseQuarantine(value)
+		if err != nil {
+			// the attribute is saved as a regular extended attribute
+			debug.Log("unable to parse quarantine attribute of %v: %v", path, err)
+		}
+	}
+
+	if node.Type == "file" {
+		value, err = getxattr(path, resourceForkAttribute)
+		if err != nil {
+			return true, err
+		}
+		if len(value) > 0 {
+			attrs.ResourceFork = &value
+		}
+	}
+
+	if attrs.FileFlags == nil && attrs.Quarantine == nil && attrs.ResourceFork == nil {
+		return true, nil
+	}
+	node.GenericAttributes, err = DarwinAttrsToGenericAttributes(attrs)
+	return true, err
+}
+
+// restoreGenericAttributes restores the quarantine attribute and the resource
+// fork on darwin. The file flags are restored by restoreFileFlags.
+func (node *Node) restoreGenericAttributes(path string, warn func(msg string)) error {
+	if len(node.GenericAttributes) == 0 {
+		return nil
+	}
+	var errs []error
+	darwinAttributes, unknownAttribs, err := genericAttributesToDarwinAttrs(node.GenericAttributes)
+	if err != nil {
+		return fmt.Errorf("error parsing generic attribute for: %s : %v", path, err)
+	}
+	if darwinAttributes.Quarantine != nil {
+		if err := setxattr(path, quarantineAttribute, darwinAttributes.Quarantine.Value()); err != nil {
+			errs = append(errs, fmt.Errorf("error restoring quarantine attribute for: %s : %v", path, err))
+		}
+	}
+	if darwinAttributes.ResourceFork != nil && node.Type == "file" {
+		if err := setxattr(path, resourceForkAttribute, *darwinAttributes.ResourceFork); err != nil {
+			errs = append(errs, fmt.Errorf("error restoring resource fork for: %s : %v", path, err))
+		}
+	}
+
+	HandleUnknownGenericAttributesFound(unknownAttribs, warn)
+	return errors.CombineErrors(errs...)
+}
+
+// restoreFileFlags sets the flags of the file at path using chflags. Flags
+// which can only be changed by root are skipped for other users.
+func (node Node) restoreFileFlags(path string) error {
+	raw, ok := node.GenericAttributes[TypeFileFlags]
+	if !ok || node.Type == "symlink" {
+		// chflags follows symlinks
+		return nil
+	}
+	var flags uint32
+	if err := json.Unmarshal(raw, &flags); err != nil {
+		return fmt.Errorf("error parsing file flags for: %s : %v", path, err)
+	}
+	if os.Geteuid() != 0 && flags&systemFileFlags != 0 {
+		debug.Log("not running as root, skipping system flags %x of %v", flags&systemFileFlags, path)
+		flags &^= systemFileFlags
+	}
+	if err := unix.Chflags(path, int(flags)); err != nil {
+		return &os.PathError{Op: "chflags", Path: path, Err: err}
+	}
+	return nil
+}
+
+// genericAttributesToDarwinAttrs converts the generic attributes map to a DarwinAttributes and also returns a string of unknown attributes that it could not convert.
+func genericAttributesToDarwinAttrs(attrs map[GenericAttributeType]json.RawMessage) (darwinAttributes DarwinAttributes, unknownAttribs []GenericAttributeType, err error) {
+	daValue := reflect.ValueOf(&darwinAttributes).Elem()
+	unknownAttribs, err = genericAttributesToOSAttrs(attrs, reflect.TypeOf(darwinAttributes), &daValue, "darwin")
+	return darwinAttributes, unknownAttribs, err
+}
+
+// DarwinAttrsToGenericAttributes converts the DarwinAttributes to a generic attributes map using reflection
+func DarwinAttrsToGenericAttributes(darwinAttributes DarwinAttributes) (attrs map[GenericAttributeType]json.RawMessage, err error) {
+	darwinAttributesValue := reflect.ValueOf(darwinAttributes)
+	return osAttrsToGenericAttributes(reflect.TypeOf(darwinAttributes), &darwinAttributesValue, "darwin")
+}
@@ -0,0 +1,51 @@
+package restic
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sys/unix"
+)
+
+func TestDarwinAttributesRoundTrip(t *testing.T) {
+	tempdir := t.TempDir()
+	source := filepath.Join(tempdir, "source")
+	rtest.OK(t, os.WriteFile(source, []byte("content"), 0644))
+	rtest.OK(t, unix.Chflags(source, unix.UF_HIDDEN))
+
+	quarantine := Quarantine{Flags: 0x83, Timestamp: time.Unix(0x65a4f1c2, 0).UTC(), Agent: "Safari", EventID: "C1B6A2D4"}
+	rtest.OK(t, setxattr(source, quarantineAttribute, quarantine.Value()))
+	rtest.OK(t, setxattr(source, resourceForkAttribute, []byte("resource fork")))
+
+	fi, err := os.Lstat(source)
+	rtest.OK(t, err)
+	node, err := NodeFromFileInfo(source, fi, false)
+	rtest.OK(t, err)
+
+	attrs, unknown, err := genericAttributesToDarwinAttrs(node.GenericAttributes)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(unknown))
+	rtest.Assert(t, attrs.FileFlags != nil && *attrs.FileFlags&unix.UF_HIDDEN != 0, "hidden flag was not saved")
+	rtest.Equals(t, &quarantine, attrs.Quarantine)
+	rtest.Equals(t, "resource fork", string(*attrs.ResourceFork))
+	for _, attr := range node.ExtendedAttributes {
+		rtest.Assert(t, !node.xattrInGenericAttributes(attr.Name), "%v saved twice", attr.Name)
+	}
+
+	target := filepath.Join(tempdir, "target")
+	rtest.OK(t, os.WriteFile(target, []byte("content"), 0644))
+	rtest.OK(t, node.RestoreMetadata(target, func(msg string) { t.Errorf("unexpected warning: %v", msg) }))
+
+	var stat unix.Stat_t
+	rtest.OK(t, unix.Lstat(target, &stat))
+	rtest.Assert(t, stat.Flags&unix.UF_HIDDEN != 0, "hidden flag was not restored")
+	value, err := getxattr(target, quarantineAttribute)
+	rtest.OK(t, err)
+	rtest.Equals(t, string(quarantine.Value()), string(value))
+	value, err = getxattr(target, resourceForkAttribute)
+	rtest.OK(t, err)
+	rtest.Equals(t, "resource fork", string(value))
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package restic
+
+// AddEFSMetadata is a no-op, EFS only exists on Windows.
+func (node *Node) AddEFSMetadata(_ string) error {
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package restic
+
+// restoreFileFlags is a no-op on this platform.
+func (node Node) restoreFileFlags(_ string) error {
+	return nil
+}
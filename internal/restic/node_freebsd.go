@@ -3,7 +3,13 @@
 
 package restic
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
 
 func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespec) error {
 	return nil
@@ -16,3 +22,49 @@ func mknod(path string, mode uint32, dev uint64) (err error) {
 func (s statT) atim() syscall.Timespec { return s.Atimespec }
 func (s statT) mtim() syscall.Timespec { return s.Mtimespec }
 func (s statT) ctim() syscall.Timespec { return s.Ctimespec }
+
+// restoreGenericAttributes restores the NFSv4 ACL on freebsd.
+func (node *Node) restoreGenericAttributes(path string, warn func(msg string)) error {
+	return node.restoreNFSv4ACL(path, warn, setNFSv4ACL)
+}
+
+// fillGenericAttributes fills in the NFSv4 ACL on freebsd.
+func (node *Node) fillGenericAttributes(path string, _ os.FileInfo, _ *statT) (allowExtended bool, err error) {
+	return true, node.fillNFSv4ACL(path, getNFSv4ACL)
+}
+
+// getNFSv4ACL returns the NFSv4 ACL of path. File systems without support
+// for NFSv4 ACLs return no ACL.
+func getNFSv4ACL(path string) (NFSv4ACL, error) {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	facl := &freebsdACL{MaxCount: freebsdACLMaxEntries}
+	_, _, errno := unix.Syscall(unix.SYS___ACL_GET_LINK, uintptr(unsafe.Pointer(p)), freebsdACLTypeNFS4, uintptr(unsafe.Pointer(facl)))
+	switch errno {
+	case 0:
+	case unix.EINVAL, unix.EOPNOTSUPP:
+		return nil, nil
+	default:
+		return nil, &os.PathError{Op: "__acl_get_link", Path: path, Err: errno}
+	}
+	return nfs4ACLFromFreeBSD(facl)
+}
+
+// setNFSv4ACL sets the NFSv4 ACL of path.
+func setNFSv4ACL(path string, acl NFSv4ACL) error {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	facl, err := nfs4ACLToFreeBSD(acl)
+	if err != nil {
+		return err
+	}
+	_, _, errno := unix.Syscall(unix.SYS___ACL_SET_LINK, uintptr(unsafe.Pointer(p)), freebsdACLTypeNFS4, uintptr(unsafe.Pointer(facl)))
+	if errno != 0 {
+		return &os.PathError{Op: "__acl_set_link", Path: path, Err: errno}
+	}
+	return nil
+}
@@ -1,15 +1,34 @@
 package restic
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
 	"syscall"
 
+	"github.com/pkg/xattr"
 	"golang.org/x/sys/unix"
 
+	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
 )
 
+// LinuxAttributes are the genericAttributes for Linux OS
+type LinuxAttributes struct {
+	// PosixACL is used for storing the access and default POSIX ACLs of files
+	// and directories.
+	PosixACL *PosixACL `generic:"posix_acl"`
+	// InodeFlags are the flags set by chattr, like immutable or nodump.
+	InodeFlags *uint32 `generic:"inode_flags"`
+	// SELinuxContext is the security context stored in security.selinux.
+	SELinuxContext *string `generic:"selinux_context"`
+	// Capabilities is the raw vfs_cap_data stored in security.capability.
+	Capabilities *[]byte `generic:"capabilities"`
+}
+
 func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespec) error {
 	dir, err := fs.Open(filepath.Dir(path))
 	if err != nil {
@@ -35,3 +54,219 @@ func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespe
 func (s statT) atim() syscall.Timespec { return s.Atim }
 func (s statT) mtim() syscall.Timespec { return s.Mtim }
 func (s statT) ctim() syscall.Timespec { return s.Ctim }
+
+// fillGenericAttributes fills in the generic attributes for linux, which
+// currently are the SELinux context of all nodes, the capabilities of files
+// and the POSIX ACLs and the inode flags of files and directories.
+func (node *Node) fillGenericAttributes(path string, _ os.FileInfo, _ *statT) (allowExtended bool, err error) {
+	var attrs LinuxAttributes
+	attrs.SELinuxContext, err = getSELinuxContext(path)
+	if err != nil {
+		return true, err
+	}
+
+	if node.Type == "file" {
+		attrs.Capabilities, err = getCapabilities(path)
+		if err != nil {
+			return true, err
+		}
+	}
+
+	if node.Type == "file" || node.Type == "dir" {
+		attrs.PosixACL, err = getPosixACL(path, node.Type == "dir")
+		if err != nil {
+			return true, err
+		}
+
+		flags, err := fs.GetInodeFlags(path)
+		if err != nil {
+			return true, err
+		}
+		if flags != 0 {
+			attrs.InodeFlags = &flags
+		}
+	}
+
+	if attrs.PosixACL == nil && attrs.InodeFlags == nil && attrs.SELinuxContext == nil && attrs.Capabilities == nil {
+		return true, nil
+	}
+	node.GenericAttributes, err = LinuxAttrsToGenericAttributes(attrs)
+	return true, err
+}
+
+// getSELinuxContext returns the SELinux context of path or nil if it has none.
+// Invalid contexts are saved as regular extended attributes.
+func getSELinuxContext(path string) (*string, error) {
+	value, err := getxattr(path, selinuxAttribute)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	context, err := ParseSELinuxContext(value)
+	if err != nil {
+		debug.Log("unable to parse SELinux context of %v: %v", path, err)
+		return nil, nil
+	}
+	return &context, nil
+}
+
+// getCapabilities returns the file capabilities of path or nil if it has none.
+// Invalid capabilities are saved as regular extended attributes.
+func getCapabilities(path string) (*[]byte, error) {
+	value, err := getxattr(path, capabilityAttribute)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	if err := ValidateCapabilities(value); err != nil {
+		debug.Log("unable to parse capabilities of %v: %v", path, err)
+		return nil, nil
+	}
+	return &value, nil
+}
+
+// getPosixACL returns the POSIX ACLs of path or nil if there are none.
+func getPosixACL(path string, isDir bool) (*PosixACL, error) {
+	var acl PosixACL
+	data, err := getxattr(path, posixACLAccessAttribute)
+	if err != nil {
+		return nil, err
+	}
+	if acl.Access, err = decodePosixACL(data); err != nil {
+		return nil, fmt.Errorf("error reading access ACL for: %s : %v", path, err)
+	}
+
+	if isDir {
+		data, err = getxattr(path, posixACLDefaultAttribute)
+		if err != nil {
+			return nil, err
+		}
+		if acl.Default, err = decodePosixACL(data); err != nil {
+			return nil, fmt.Errorf("error reading default ACL for: %s : %v", path, err)
+		}
+	}
+
+	if len(acl.Access) == 0 && len(acl.Default) == 0 {
+		return nil, nil
+	}
+	return &acl, nil
+}
+
+// restoreGenericAttributes restores generic attributes for linux. The inode
+// flags are restored by restoreFileFlags.
+func (node *Node) restoreGenericAttributes(path string, warn func(msg string)) error {
+	if len(node.GenericAttributes) == 0 {
+		return nil
+	}
+	var errs []error
+	linuxAttributes, unknownAttribs, err := genericAttributesToLinuxAttrs(node.GenericAttributes)
+	if err != nil {
+		return fmt.Errorf("error parsing generic attribute for: %s : %v", path, err)
+	}
+	if linuxAttributes.PosixACL != nil && node.Type != "symlink" {
+		if err := restorePosixACL(path, node.Type, linuxAttributes.PosixACL); err != nil {
+			errs = append(errs, fmt.Errorf("error restoring POSIX ACL for: %s : %v", path, err))
+		}
+	}
+	if linuxAttributes.SELinuxContext != nil {
+		if err := restoreSELinuxContext(path, *linuxAttributes.SELinuxContext); err != nil {
+			errs = append(errs, fmt.Errorf("error restoring SELinux context for: %s : %v", path, err))
+		}
+	}
+	// the kernel removes the capabilities whenever the file is written or its
+	// owner changes, thus they are restored after both
+	if linuxAttributes.Capabilities != nil && node.Type == "file" {
+		if err := restoreCapabilities(path, *linuxAttributes.Capabilities); err != nil {
+			errs = append(errs, fmt.Errorf("error restoring capabilities for: %s : %v", path, err))
+		}
+	}
+
+	HandleUnknownGenericAttributesFound(unknownAttribs, warn)
+	return errors.CombineErrors(errs...)
+}
+
+// restoreSELinuxContext validates context and sets it for the file at path.
+func restoreSELinuxContext(path string, context string) error {
+	if err := validateSELinuxContext(context); err != nil {
+		return err
+	}
+	err := xattr.LSet(path, selinuxAttribute, append([]byte(context), 0))
+	switch {
+	case errors.Is(err, unix.ENOTSUP):
+		return errors.New("the target file system does not support SELinux contexts, use --no-selinux to skip them")
+	case errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES):
+		return errors.New("permission denied, changing the SELinux context requires the relabelto permission, use --no-selinux to skip it")
+	}
+	return errors.WithStack(err)
+}
+
+// restoreCapabilities validates the vfs_cap_data caps and sets it for the file
+// at path.
+func restoreCapabilities(path string, caps []byte) error {
+	if err := ValidateCapabilities(caps); err != nil {
+		return err
+	}
+	err := xattr.LSet(path, capabilityAttribute, caps)
+	switch {
+	case errors.Is(err, unix.ENOTSUP):
+		return errors.New("the target file system does not support file capabilities, use --no-caps to skip them")
+	case errors.Is(err, unix.EPERM):
+		return errors.New("permission denied, setting file capabilities requires root, use --no-caps to skip them")
+	}
+	return errors.WithStack(err)
+}
+
+// restorePosixACL validates acl and sets it for the file at path.
+func restorePosixACL(path string, nodeType string, acl *PosixACL) error {
+	if err := acl.Validate(nodeType); err != nil {
+		return err
+	}
+	if len(acl.Access) > 0 {
+		if err := setxattr(path, posixACLAccessAttribute, encodePosixACL(acl.Access)); err != nil {
+			return err
+		}
+	}
+	if len(acl.Default) > 0 {
+		if err := setxattr(path, posixACLDefaultAttribute, encodePosixACL(acl.Default)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreFileFlags sets the inode flags of the file at path. The immutable
+// and append-only flags are skipped when not running as root. The no-COW flag
+// is only applied to directories and empty files, as btrfs ignores it for
+// files with content.
+func (node Node) restoreFileFlags(path string) error {
+	raw, ok := node.GenericAttributes[TypeInodeFlags]
+	if !ok || (node.Type != "file" && node.Type != "dir") {
+		return nil
+	}
+	var flags uint32
+	if err := json.Unmarshal(raw, &flags); err != nil {
+		return fmt.Errorf("error parsing inode flags for: %s : %v", path, err)
+	}
+	if os.Geteuid() != 0 && flags&fs.PrivilegedInodeFlags != 0 {
+		debug.Log("not running as root, skipping privileged flags %x of %v", flags&fs.PrivilegedInodeFlags, path)
+		flags &^= fs.PrivilegedInodeFlags
+	}
+	if node.Type == "file" && node.Size > 0 {
+		flags &^= fs.InodeFlagNoCOW
+	}
+	if flags == 0 {
+		return nil
+	}
+	return fs.SetInodeFlags(path, flags)
+}
+
+// genericAttributesToLinuxAttrs converts the generic attributes map to a LinuxAttributes and also returns a string of unknown attributes that it could not convert.
+func genericAttributesToLinuxAttrs(attrs map[GenericAttributeType]json.RawMessage) (linuxAttributes LinuxAttributes, unknownAttribs []GenericAttributeType, err error) {
+	laValue := reflect.ValueOf(&linuxAttributes).Elem()
+	unknownAttribs, err = genericAttributesToOSAttrs(attrs, reflect.TypeOf(linuxAttributes), &laValue, "linux")
+	return linuxAttributes, unknownAttribs, err
+}
+
+// LinuxAttrsToGenericAttributes converts the LinuxAttributes to a generic attributes map using reflection
+func LinuxAttrsToGenericAttributes(linuxAttributes LinuxAttributes) (attrs map[GenericAttributeType]json.RawMessage, err error) {
+	linuxAttributesValue := reflect.ValueOf(linuxAttributes)
+	return osAttrsToGenericAttributes(reflect.TypeOf(linuxAttributes), &linuxAttributesValue, "linux")
+}
@@ -0,0 +1,120 @@
+package restic
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/fs"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestPosixACLRoundTrip(t *testing.T) {
+	tempdir := t.TempDir()
+	source := filepath.Join(tempdir, "source")
+	rtest.OK(t, os.Mkdir(source, 0750))
+
+	acl := &PosixACL{
+		Access: []PosixACLEntry{
+			{Tag: PosixACLUserObj, Perm: 7},
+			{Tag: PosixACLUser, ID: posixACLID(12345), Perm: 5},
+			{Tag: PosixACLGroupObj, Perm: 5},
+			{Tag: PosixACLMask, Perm: 5},
+			{Tag: PosixACLOther, Perm: 0},
+		},
+		Default: []PosixACLEntry{
+			{Tag: PosixACLUserObj, Perm: 7},
+			{Tag: PosixACLGroupObj, Perm: 5},
+			{Tag: PosixACLGroup, ID: posixACLID(54321), Perm: 7},
+			{Tag: PosixACLMask, Perm: 7},
+			{Tag: PosixACLOther, Perm: 0},
+		},
+	}
+	rtest.OK(t, restorePosixACL(source, "dir", acl))
+
+	fi, err := os.Lstat(source)
+	rtest.OK(t, err)
+	node, err := NodeFromFileInfo(source, fi, false)
+	rtest.OK(t, err)
+	if _, ok := node.GenericAttributes[TypePosixACL]; !ok {
+		t.Skip("file system does not support POSIX ACLs")
+	}
+	for _, attr := range node.ExtendedAttributes {
+		rtest.Assert(t, !isPosixACLAttribute(attr.Name), "POSIX ACL stored as extended attribute %v", attr.Name)
+	}
+
+	target := filepath.Join(tempdir, "target")
+	rtest.OK(t, os.Mkdir(target, 0700))
+	rtest.OK(t, node.RestoreMetadata(target, func(msg string) { t.Errorf("unexpected warning: %v", msg) }))
+
+	restored, err := getPosixACL(target, true)
+	rtest.OK(t, err)
+	rtest.Equals(t, acl, restored)
+}
+
+func TestInodeFlagsRoundTrip(t *testing.T) {
+	tempdir := t.TempDir()
+	source := filepath.Join(tempdir, "source")
+	rtest.OK(t, os.WriteFile(source, []byte("content"), 0644))
+	if err := fs.SetInodeFlags(source, fs.InodeFlagNoDump|fs.InodeFlagNoAtime); err != nil {
+		t.Skipf("file system does not support inode flags: %v", err)
+	}
+
+	fi, err := os.Lstat(source)
+	rtest.OK(t, err)
+	node, err := NodeFromFileInfo(source, fi, false)
+	rtest.OK(t, err)
+
+	attrs, unknown, err := genericAttributesToLinuxAttrs(node.GenericAttributes)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(unknown))
+	rtest.Assert(t, attrs.InodeFlags != nil, "inode flags were not saved")
+	rtest.Equals(t, fs.InodeFlagNoDump|fs.InodeFlagNoAtime, *attrs.InodeFlags)
+
+	target := filepath.Join(tempdir, "target")
+	rtest.OK(t, os.WriteFile(target, []byte("content"), 0644))
+	rtest.OK(t, node.RestoreMetadata(target, func(msg string) { t.Errorf("unexpected warning: %v", msg) }))
+
+	flags, err := fs.GetInodeFlags(target)
+	rtest.OK(t, err)
+	rtest.Equals(t, fs.InodeFlagNoDump|fs.InodeFlagNoAtime, flags)
+}
+
+func TestSecurityAttributesRoundTrip(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("setting file capabilities requires root")
+	}
+
+	tempdir := t.TempDir()
+	source := filepath.Join(tempdir, "source")
+	rtest.OK(t, os.WriteFile(source, []byte("content"), 0755))
+	// cap_net_bind_service+ep
+	caps := []byte{0x01, 0, 0, 0x02, 0, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if err := setxattr(source, capabilityAttribute, caps); err != nil {
+		t.Skipf("unable to set file capabilities: %v", err)
+	}
+
+	fi, err := os.Lstat(source)
+	rtest.OK(t, err)
+	node, err := NodeFromFileInfo(source, fi, false)
+	rtest.OK(t, err)
+
+	attrs, _, err := genericAttributesToLinuxAttrs(node.GenericAttributes)
+	rtest.OK(t, err)
+	rtest.Assert(t, attrs.Capabilities != nil, "capabilities were not saved")
+	rtest.Equals(t, caps, *attrs.Capabilities)
+	for _, attr := range node.ExtendedAttributes {
+		rtest.Assert(t, attr.Name != capabilityAttribute, "capabilities saved twice")
+	}
+
+	target := filepath.Join(tempdir, "target")
+	rtest.OK(t, os.WriteFile(target, []byte("content"), 0755))
+	rtest.OK(t, node.RestoreMetadata(target, func(msg string) { t.Errorf("unexpected warning: %v", msg) }))
+	value, err := getxattr(target, capabilityAttribute)
+	rtest.OK(t, err)
+	rtest.Equals(t, caps, value)
+
+	// invalid capabilities are reported instead of being lost silently
+	node.GenericAttributes[TypeCapabilities] = []byte(`"AQAA"`)
+	rtest.Assert(t, node.RestoreMetadata(target, func(msg string) {}) != nil, "invalid capabilities were restored")
+}
@@ -0,0 +1,80 @@
+package restic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Extended attributes of macOS files which are stored as generic attributes.
+const (
+	quarantineAttribute   = "com.apple.quarantine"
+	resourceForkAttribute = "com.apple.ResourceFork"
+)
+
+// Quarantine is the com.apple.quarantine extended attribute, which macOS adds
+// to downloaded files so that Gatekeeper checks them before they are opened.
+type Quarantine struct {
+	Flags     uint16    `json:"flags"`
+	Timestamp time.Time `json:"timestamp"`
+	// Agent is the name of the application which downloaded the file.
+	Agent string `json:"agent,omitempty"`
+	// EventID refers to the entry in the quarantine events database.
+	EventID string `json:"event_id,omitempty"`
+}
+
+// ParseQuarantine parses the value of the com.apple.quarantine extended
+// attribute, which looks like "0083;65a4f1c2;Safari;C1B6...".
+func ParseQuarantine(value []byte) (*Quarantine, error) {
+	fields := strings.SplitN(string(value), ";", 4)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid quarantine attribute %q", value)
+	}
+
+	flags, err := strconv.ParseUint(fields[0], 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quarantine flags %q", fields[0])
+	}
+	timestamp, err := strconv.ParseInt(fields[1], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quarantine timestamp %q", fields[1])
+	}
+
+	q := &Quarantine{
+		Flags:     uint16(flags),
+		Timestamp: time.Unix(timestamp, 0).UTC(),
+	}
+	if len(fields) > 2 {
+		q.Agent = fields[2]
+	}
+	if len(fields) > 3 {
+		q.EventID = fields[3]
+	}
+	return q, nil
+}
+
+// Value returns the value of the com.apple.quarantine extended attribute.
+func (q Quarantine) Value() []byte {
+	return []byte(fmt.Sprintf("%04x;%08x;%s;%s", q.Flags, q.Timestamp.Unix(), q.Agent, q.EventID))
+}
+
+// xattrInGenericAttributes returns whether the extended attribute is stored
+// as part of the generic attributes of the node.
+func (node *Node) xattrInGenericAttributes(name string) bool {
+	var t GenericAttributeType
+	switch name {
+	case quarantineAttribute:
+		t = TypeQuarantine
+	case resourceForkAttribute:
+		t = TypeResourceFork
+	case selinuxAttribute:
+		t = TypeSELinuxContext
+	case capabilityAttribute:
+		t = TypeCapabilities
+	default:
+		return false
+	}
+	_, ok := node.GenericAttributes[t]
+	return ok
+}
@@ -0,0 +1,41 @@
+package restic
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseQuarantine(t *testing.T) {
+	value := []byte("0083;65a4f1c2;Safari;C1B6A2D4-5E6F-4A1B-8C9D-0E1F2A3B4C5D")
+	q, err := ParseQuarantine(value)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint16(0x83), q.Flags)
+	rtest.Equals(t, time.Unix(0x65a4f1c2, 0).UTC(), q.Timestamp)
+	rtest.Equals(t, "Safari", q.Agent)
+	rtest.Equals(t, "C1B6A2D4-5E6F-4A1B-8C9D-0E1F2A3B4C5D", q.EventID)
+	rtest.Equals(t, string(value), string(q.Value()))
+
+	q, err = ParseQuarantine([]byte("0081;65a4f1c2"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "0081;65a4f1c2;;", string(q.Value()))
+
+	for _, invalid := range []string{"", "0083", "xyz;65a4f1c2", "0083;xyz"} {
+		_, err = ParseQuarantine([]byte(invalid))
+		rtest.Assert(t, err != nil, "no error for %q", invalid)
+	}
+}
+
+func TestXattrInGenericAttributes(t *testing.T) {
+	node := Node{}
+	rtest.Assert(t, !node.xattrInGenericAttributes(quarantineAttribute), "quarantine without generic attribute")
+
+	node.GenericAttributes = map[GenericAttributeType]json.RawMessage{
+		TypeQuarantine: json.RawMessage(`{}`),
+	}
+	rtest.Assert(t, node.xattrInGenericAttributes(quarantineAttribute), "quarantine not in generic attributes")
+	rtest.Assert(t, !node.xattrInGenericAttributes(resourceForkAttribute), "unexpected resource fork")
+	rtest.Assert(t, !node.xattrInGenericAttributes("user.foo"), "unexpected extended attribute")
+}
@@ -1,6 +1,12 @@
 package restic
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/restic/restic/internal/errors"
+)
 
 func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespec) error {
 	return nil
@@ -9,3 +15,74 @@ func (node Node) restoreSymlinkTimestamps(path string, utimes [2]syscall.Timespe
 func (s statT) atim() syscall.Timespec { return s.Atim }
 func (s statT) mtim() syscall.Timespec { return s.Mtim }
 func (s statT) ctim() syscall.Timespec { return s.Ctim }
+
+// restoreGenericAttributes restores the NFSv4 ACL on solaris.
+func (node *Node) restoreGenericAttributes(path string, warn func(msg string)) error {
+	return node.restoreNFSv4ACL(path, warn, setNFSv4ACL)
+}
+
+// fillGenericAttributes fills in the NFSv4 ACL on solaris.
+func (node *Node) fillGenericAttributes(path string, _ os.FileInfo, _ *statT) (allowExtended bool, err error) {
+	return true, node.fillNFSv4ACL(path, getNFSv4ACL)
+}
+
+// commands of acl(2)
+const (
+	solarisACESetACL    = 5
+	solarisACEGetACL    = 4
+	solarisACEGetACLCnt = 6
+)
+
+//go:cgo_import_dynamic libc_acl acl "libc.so"
+
+//go:linkname procacl libc_acl
+var procacl uintptr
+
+//go:linkname sysvicall6 runtime.syscall_sysvicall6
+func sysvicall6(fn, nargs, a1, a2, a3, a4, a5, a6 uintptr) (r1, r2, err uintptr)
+
+// aclCall calls acl(2) for path.
+func aclCall(path string, cmd int, cnt int, buf unsafe.Pointer) (int, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	r1, _, errno := sysvicall6(uintptr(unsafe.Pointer(&procacl)), 4,
+		uintptr(unsafe.Pointer(p)), uintptr(cmd), uintptr(cnt), uintptr(buf), 0, 0)
+	if errno != 0 {
+		return int(r1), &os.PathError{Op: "acl", Path: path, Err: syscall.Errno(errno)}
+	}
+	return int(r1), nil
+}
+
+// isACLNotSupported reports whether err indicates that the file system does
+// not support NFSv4 ACLs.
+func isACLNotSupported(err error) bool {
+	return errors.Is(err, syscall.ENOSYS) || errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EINVAL)
+}
+
+// getNFSv4ACL returns the NFSv4 ACL of path. File systems without support
+// for NFSv4 ACLs return no ACL.
+func getNFSv4ACL(path string) (NFSv4ACL, error) {
+	cnt, err := aclCall(path, solarisACEGetACLCnt, 0, nil)
+	if isACLNotSupported(err) {
+		return nil, nil
+	}
+	if err != nil || cnt == 0 {
+		return nil, err
+	}
+
+	aces := make([]solarisACE, cnt)
+	cnt, err = aclCall(path, solarisACEGetACL, len(aces), unsafe.Pointer(&aces[0]))
+	if err != nil {
+		return nil, err
+	}
+	return nfs4ACLFromSolaris(aces[:cnt])
+}
+
+// setNFSv4ACL sets the NFSv4 ACL of path.
+func setNFSv4ACL(path string, acl NFSv4ACL) error {
+	aces := nfs4ACLToSolaris(acl)
+	_, err := aclCall(path, solarisACESetACL, len(aces), unsafe.Pointer(&aces[0]))
+	return err
+}
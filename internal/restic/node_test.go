@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/test"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -225,7 +226,7 @@ func TestNodeRestoreAt(t *testing.T) {
 			} else {
 				nodePath = filepath.Join(tempdir, test.Name)
 			}
-			rtest.OK(t, test.CreateAt(context.TODO(), nodePath, nil))
+			rtest.OK(t, test.CreateAt(context.TODO(), nodePath, nil, func(msg string) { rtest.OK(t, fmt.Errorf("Warning triggered for path: %s: %s", nodePath, msg)) }))
 			rtest.OK(t, test.RestoreMetadata(nodePath, func(msg string) { rtest.OK(t, fmt.Errorf("Warning triggered for path: %s: %s", nodePath, msg)) }))
 
 			if test.Type == "dir" {
@@ -382,3 +383,31 @@ func TestSymlinkSerializationFormat(t *testing.T) {
 		test.Assert(t, n2.LinkTargetRaw == nil, "quoted link target is just a helper field and must be unset after decoding")
 	}
 }
+
+func TestNodeSetDACLProtected(t *testing.T) {
+	sd, err := json.Marshal([]byte{1, 0, 0x04, 0x80, 0, 0, 0, 0})
+	test.OK(t, err)
+	attrs := map[GenericAttributeType]json.RawMessage{TypeSecurityDescriptor: sd}
+	node := Node{Name: "file", GenericAttributes: attrs}
+
+	test.OK(t, node.SetDACLProtected(true))
+	var res []byte
+	test.OK(t, json.Unmarshal(node.GenericAttributes[TypeSecurityDescriptor], &res))
+	test.Equals(t, []byte{1, 0, 0x04, 0x90, 0, 0, 0, 0}, res)
+	// the original attributes must be unchanged
+	test.Equals(t, json.RawMessage(sd), attrs[TypeSecurityDescriptor])
+
+	node = Node{Name: "file"}
+	test.OK(t, node.SetDACLProtected(true))
+	test.Assert(t, node.GenericAttributes == nil, "unexpected generic attributes %v", node.GenericAttributes)
+}
+
+func TestNodeMountPoint(t *testing.T) {
+	node := Node{Name: "junction", Type: "symlink", LinkTarget: `C:\dir`}
+	rtest.Assert(t, !node.MountPoint(), "symlink detected as mount point")
+
+	node.GenericAttributes = map[GenericAttributeType]json.RawMessage{
+		TypeReparseTag: json.RawMessage(fmt.Sprint(uint32(fs.ReparseTagMountPoint))),
+	}
+	rtest.Assert(t, node.MountPoint(), "mount point not detected")
+}
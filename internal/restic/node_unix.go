@@ -4,14 +4,41 @@
 package restic
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"syscall"
+
+	"github.com/restic/restic/internal/fs"
 )
 
 func lchown(name string, uid, gid int) error {
 	return os.Lchown(name, uid, gid)
 }
 
+// translatePermissions derives the mode of nodes backed up on Windows from
+// their security descriptor.
+func (node *Node) translatePermissions() error {
+	raw, ok := node.GenericAttributes[TypeSecurityDescriptor]
+	if !ok {
+		return nil
+	}
+	var sd []byte
+	if err := json.Unmarshal(raw, &sd); err != nil {
+		return fmt.Errorf("error parsing security descriptor for: %s : %v", node.Name, err)
+	}
+	perm, err := fs.ModeFromSecurityDescriptor(sd)
+	if err != nil {
+		return fmt.Errorf("error translating security descriptor for: %s : %v", node.Name, err)
+	}
+	// read-only files are stored without write permissions
+	if node.Mode&0200 == 0 {
+		perm &^= 0222
+	}
+	node.Mode = node.Mode&^os.ModePerm | perm
+	return nil
+}
+
 type statT syscall.Stat_t
 
 func toStatT(i interface{}) (*statT, bool) {
@@ -26,6 +26,20 @@ type WindowsAttributes struct {
 	// SecurityDescriptor is used for storing security descriptors which includes
 	// owner, group, discretionary access control list (DACL), system access control list (SACL)
 	SecurityDescriptor *[]byte `generic:"security_descriptor"`
+	// ObjectID is used for storing the NTFS object ID and the birth IDs, which
+	// allow the link tracking service to resolve shortcuts to the file.
+	ObjectID *[]byte `generic:"object_id"`
+	// EFSMetadata is used for storing the certificates which can decrypt an
+	// EFS-encrypted file. It is only stored if requested for the backup.
+	EFSMetadata *fs.EFSMetadata `generic:"efs_metadata"`
+	// EFSRaw marks EFS-encrypted files whose content is the raw encrypted
+	// backup stream. It is only stored if requested for the backup.
+	EFSRaw *EFSRawInfo `generic:"efs_raw"`
+	// ReparseTag is stored for junctions and volume mount points, which are
+	// saved as symlinks.
+	ReparseTag *uint32 `generic:"reparse_tag"`
+	// OfflineStub marks offline files of which only the metadata was saved.
+	OfflineStub *OfflineStubInfo `generic:"offline_stub"`
 }
 
 var (
@@ -34,6 +48,24 @@ var (
 	procDecryptFile = modAdvapi32.NewProc("DecryptFileW")
 )
 
+// translatePermissions creates a security descriptor which approximates the
+// mode of nodes backed up on other systems.
+func (node *Node) translatePermissions() error {
+	if _, ok := node.GenericAttributes[TypeSecurityDescriptor]; ok || node.Type == "symlink" {
+		return nil
+	}
+	sd, err := fs.SecurityDescriptorFromMode(node.Mode)
+	if err != nil {
+		return fmt.Errorf("error creating security descriptor for: %s : %v", node.Name, err)
+	}
+	raw, err := json.Marshal(sd)
+	if err != nil {
+		return err
+	}
+	node.replaceGenericAttribute(TypeSecurityDescriptor, raw)
+	return nil
+}
+
 // mknod is not supported on Windows.
 func mknod(_ string, _ uint32, _ uint64) (err error) {
 	return errors.New("device nodes cannot be created on windows")
@@ -205,11 +237,26 @@ func (node Node) restoreGenericAttributes(path string, warn func(msg string)) (e
 	if err != nil {
 		return fmt.Errorf("error parsing generic attribute for: %s : %v", path, err)
 	}
+	if windowsAttributes.EFSRaw != nil && node.Type == "file" {
+		// must be imported first, as this replaces the file
+		if err := node.importEFSRaw(path); err != nil {
+			errs = append(errs, fmt.Errorf("error importing raw EFS stream for: %s : %v", path, err))
+		}
+	}
 	if windowsAttributes.CreationTime != nil {
 		if err := restoreCreationTime(path, windowsAttributes.CreationTime); err != nil {
 			errs = append(errs, fmt.Errorf("error restoring creation time for: %s : %v", path, err))
 		}
 	}
+	if windowsAttributes.ObjectID != nil {
+		// must be set before the file attributes, which may mark the file read-only
+		err := fs.SetObjectID(path, *windowsAttributes.ObjectID)
+		if errors.Is(err, fs.ErrObjectIDInUse) {
+			warn(fmt.Sprintf("object ID of %s not restored, it is in use by another file on the volume", path))
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("error restoring object ID for: %s : %v", path, err))
+		}
+	}
 	if windowsAttributes.FileAttributes != nil {
 		if err := restoreFileAttributes(path, windowsAttributes.FileAttributes); err != nil {
 			errs = append(errs, fmt.Errorf("error restoring file attributes for: %s : %v", path, err))
@@ -220,6 +267,10 @@ func (node Node) restoreGenericAttributes(path string, warn func(msg string)) (e
 			errs = append(errs, fmt.Errorf("error restoring security descriptor for: %s : %v", path, err))
 		}
 	}
+	if windowsAttributes.EFSMetadata != nil && windowsAttributes.FileAttributes != nil &&
+		*windowsAttributes.FileAttributes&windows.FILE_ATTRIBUTE_ENCRYPTED != 0 {
+		checkEFSCertificates(path, windowsAttributes.EFSMetadata, windowsAttributes.EFSRaw != nil, warn)
+	}
 
 	HandleUnknownGenericAttributesFound(unknownAttribs, warn)
 	return errors.CombineErrors(errs...)
@@ -241,7 +292,7 @@ func restoreCreationTime(path string, creationTime *syscall.Filetime) (err error
 	}
 	handle, err := syscall.CreateFile(pathPointer,
 		syscall.FILE_WRITE_ATTRIBUTES, syscall.FILE_SHARE_WRITE, nil,
-		syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+		syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OPEN_REPARSE_POINT, 0)
 	if err != nil {
 		return err
 	}
@@ -347,10 +398,87 @@ func decryptFile(pathPointer *uint16) error {
 	return nil
 }
 
+// checkEFSCertificates warns if none of the certificates which could decrypt
+// the file at backup time is available to the current user. The restored file
+// is encrypted for the current user instead, so the original users cannot
+// decrypt it. Files restored from the raw encrypted stream keep the original
+// encryption, so the current user cannot decrypt them.
+func checkEFSCertificates(path string, efs *fs.EFSMetadata, raw bool, warn func(msg string)) {
+	found, err := fs.HasEFSCertificate(append(efs.Users, efs.RecoveryAgents...))
+	if err != nil {
+		debug.Log("unable to check EFS certificates for %v: %v", path, err)
+		return
+	}
+	if found {
+		return
+	}
+
+	var users []string
+	for _, cert := range efs.Users {
+		name := cert.DisplayName
+		if name == "" {
+			name = cert.SID
+		}
+		users = append(users, name)
+	}
+	if raw {
+		warn(fmt.Sprintf("EFS certificates of %s are not available for the current user, %s cannot be decrypted by the current user", strings.Join(users, ", "), path))
+		return
+	}
+	warn(fmt.Sprintf("EFS certificates of %s are not available for the current user, %s is encrypted for the current user instead", strings.Join(users, ", "), path))
+}
+
+// importEFSRaw replaces the file at path, which contains the raw encrypted
+// backup stream, with the EFS-encrypted file created from the stream. The
+// timestamps are restored again for the new file.
+func (node Node) importEFSRaw(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".restic-efs-import"
+	err = fs.WriteEncryptedRaw(tmp, f)
+	_ = f.Close()
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return node.RestoreTimestamps(path)
+}
+
+// AddEFSMetadata stores the certificates of the users and recovery agents
+// which can decrypt the file at path, if it is encrypted using EFS.
+func (node *Node) AddEFSMetadata(path string) error {
+	if node.Type != "file" {
+		return nil
+	}
+	windowsAttributes, _, err := genericAttributesToWindowsAttrs(node.GenericAttributes)
+	if err != nil {
+		return err
+	}
+	if windowsAttributes.FileAttributes == nil || *windowsAttributes.FileAttributes&windows.FILE_ATTRIBUTE_ENCRYPTED == 0 {
+		return nil
+	}
+
+	efs, err := fs.GetEFSMetadata(path)
+	if err != nil {
+		return fmt.Errorf("error reading EFS metadata for: %s : %v", path, err)
+	}
+	raw, err := json.Marshal(efs)
+	if err != nil {
+		return err
+	}
+	node.replaceGenericAttribute(TypeEFSMetadata, raw)
+	return nil
+}
+
 // fillGenericAttributes fills in the generic attributes for windows like File Attributes,
 // Created time etc.
 func (node *Node) fillGenericAttributes(path string, fi os.FileInfo, stat *statT) (allowExtended bool, err error) {
-	if strings.Contains(filepath.Base(path), ":") {
+	if !IsMainFile(filepath.Base(path)) {
 		//Do not process for Alternate Data Streams in Windows
 		// Also do not allow processing of extended attributes for ADS.
 		return false, nil
@@ -359,23 +487,62 @@ func (node *Node) fillGenericAttributes(path string, fi os.FileInfo, stat *statT
 		// Do not process file attributes and created time for windows directories like
 		// C:, D:
 		// Filepath.Clean(path) ends with '\' for Windows root drives only.
-		var sd *[]byte
-		if node.Type == "file" || node.Type == "dir" {
+		var sd, objectID *[]byte
+		if node.Type == "file" || node.Type == "dir" || node.Type == "symlink" {
+			// the security descriptor of a symlink or junction is read from the
+			// reparse point, not from its target
 			if sd, err = fs.GetSecurityDescriptor(path); err != nil {
 				return true, err
 			}
 		}
+		if node.Type == "file" {
+			node.fillFileID(path)
+		}
+		if node.Type == "file" || node.Type == "dir" {
+			if objectID, err = fs.GetObjectID(path); err != nil {
+				return true, err
+			}
+		}
+		var reparseTag *uint32
+		if node.Type == "symlink" {
+			target, ok, err := fs.ReadMountPoint(path)
+			if err != nil {
+				return true, err
+			}
+			if ok {
+				node.LinkTarget = target
+				tag := uint32(fs.ReparseTagMountPoint)
+				reparseTag = &tag
+			}
+		}
 
 		// Add Windows attributes
 		node.GenericAttributes, err = WindowsAttrsToGenericAttributes(WindowsAttributes{
 			CreationTime:       getCreationTime(fi, path),
 			FileAttributes:     &stat.FileAttributes,
 			SecurityDescriptor: sd,
+			ObjectID:           objectID,
+			ReparseTag:         reparseTag,
 		})
 	}
 	return true, err
 }
 
+// fillFileID sets the inode, device ID and number of links of the file at
+// path, which are required to restore hard links. Lstat does not return them
+// on Windows.
+func (node *Node) fillFileID(path string) {
+	id, err := fs.GetFileID(path)
+	if err != nil {
+		// the file is saved without hard link information
+		debug.Log("unable to get file ID of %v: %v", path, err)
+		return
+	}
+	node.Inode = id.FileIndex
+	node.DeviceID = id.VolumeSerialNumber
+	node.Links = id.Links
+}
+
 // windowsAttrsToGenericAttributes converts the WindowsAttributes to a generic attributes map using reflection
 func WindowsAttrsToGenericAttributes(windowsAttributes WindowsAttributes) (attrs map[GenericAttributeType]json.RawMessage, err error) {
 	// Get the value of the WindowsAttributes
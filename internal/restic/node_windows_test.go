@@ -4,6 +4,7 @@
 package restic
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -329,3 +330,92 @@ func TestRestoreExtendedAttributes(t *testing.T) {
 		}
 	}
 }
+
+func TestObjectIDGenericAttribute(t *testing.T) {
+	objectID := test.Random(42, 64)
+	genericAttrs, err := WindowsAttrsToGenericAttributes(WindowsAttributes{ObjectID: &objectID})
+	test.OK(t, err)
+	_, ok := genericAttrs[TypeObjectID]
+	test.Assert(t, ok, "object ID missing in generic attributes %v", genericAttrs)
+
+	node := getNode("testfile", "file", genericAttrs)
+	test.Equals(t, objectID, *getWindowsAttr(t, "testfile", &node).ObjectID)
+}
+
+func TestEFSMetadataGenericAttribute(t *testing.T) {
+	efs := fs.EFSMetadata{
+		Users: []fs.EFSCertificate{{
+			SID:         "S-1-5-21-1004336348-1177238915-682003330-1001",
+			Hash:        test.Random(23, 20),
+			DisplayName: "user(user@example.com)",
+		}},
+		RecoveryAgents: []fs.EFSCertificate{{Hash: test.Random(24, 20)}},
+	}
+	genericAttrs, err := WindowsAttrsToGenericAttributes(WindowsAttributes{EFSMetadata: &efs})
+	test.OK(t, err)
+	_, ok := genericAttrs[TypeEFSMetadata]
+	test.Assert(t, ok, "EFS metadata missing in generic attributes %v", genericAttrs)
+
+	node := getNode("testfile", "file", genericAttrs)
+	test.Equals(t, efs, *getWindowsAttr(t, "testfile", &node).EFSMetadata)
+}
+
+func TestAddEFSMetadataUnencrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testfile")
+	test.OK(t, os.WriteFile(path, []byte("foo"), 0o600))
+	fi, err := os.Lstat(path)
+	test.OK(t, err)
+
+	node, err := NodeFromFileInfo(path, fi, false)
+	test.OK(t, err)
+	test.OK(t, node.AddEFSMetadata(path))
+	_, ok := node.GenericAttributes[TypeEFSMetadata]
+	test.Assert(t, !ok, "unexpected EFS metadata for unencrypted file")
+}
+
+func TestJunctionNode(t *testing.T) {
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "target")
+	test.OK(t, os.Mkdir(target, 0700))
+	junction := filepath.Join(tempDir, "junction")
+	test.OK(t, fs.CreateJunction(target, junction))
+
+	fi, err := os.Lstat(junction)
+	test.OK(t, err)
+	node, err := NodeFromFileInfo(junction, fi, false)
+	test.OK(t, err)
+	test.Equals(t, "symlink", node.Type)
+	test.Equals(t, target, node.LinkTarget)
+	test.Assert(t, node.MountPoint(), "junction not marked as mount point")
+
+	restored := filepath.Join(tempDir, "restored")
+	test.OK(t, node.CreateAt(context.TODO(), restored, nil, func(msg string) {
+		t.Errorf("unexpected warning: %v", msg)
+	}))
+	link, ok, err := fs.ReadMountPoint(restored)
+	test.OK(t, err)
+	test.Assert(t, ok, "junction was not restored as junction")
+	test.Equals(t, target, link)
+}
+
+func TestHardlinkNode(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "file")
+	test.OK(t, os.WriteFile(file, []byte("content"), 0600))
+	link := filepath.Join(tempDir, "link")
+	test.OK(t, fs.Link(file, link))
+
+	nodes := make([]*Node, 0, 2)
+	for _, name := range []string{file, link} {
+		fi, err := os.Lstat(name)
+		test.OK(t, err)
+		node, err := NodeFromFileInfo(name, fi, false)
+		test.OK(t, err)
+		nodes = append(nodes, node)
+	}
+
+	test.Equals(t, uint64(2), nodes[0].Links)
+	test.Assert(t, nodes[0].Inode != 0, "file has no inode")
+	test.Equals(t, nodes[0].Inode, nodes[1].Inode)
+	test.Equals(t, nodes[0].DeviceID, nodes[1].DeviceID)
+}
@@ -59,16 +59,6 @@ func handleXattrErr(err error) error {
 	}
 }
 
-// restoreGenericAttributes is no-op.
-func (node *Node) restoreGenericAttributes(_ string, warn func(msg string)) error {
-	return node.handleAllUnknownGenericAttributesFound(warn)
-}
-
-// fillGenericAttributes is a no-op.
-func (node *Node) fillGenericAttributes(_ string, _ os.FileInfo, _ *statT) (allowExtended bool, err error) {
-	return true, nil
-}
-
 func (node Node) restoreExtendedAttributes(path string) error {
 	for _, attr := range node.ExtendedAttributes {
 		err := setxattr(path, attr.Name, attr.Value)
@@ -91,6 +81,11 @@ func (node *Node) fillExtendedAttributes(path string, ignoreListError bool) erro
 
 	node.ExtendedAttributes = make([]ExtendedAttribute, 0, len(xattrs))
 	for _, attr := range xattrs {
+		if isPosixACLAttribute(attr) || node.xattrInGenericAttributes(attr) {
+			// POSIX ACLs, SELinux contexts, file capabilities and some
+			// attributes of macOS files are stored as generic attributes
+			continue
+		}
 		attrVal, err := getxattr(path, attr)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "can not obtain extended attribute %v for %v:\n", attr, path)
@@ -0,0 +1,189 @@
+package restic
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Names of the extended attributes which store the POSIX ACLs on Linux.
+const (
+	posixACLAccessAttribute  = "system.posix_acl_access"
+	posixACLDefaultAttribute = "system.posix_acl_default"
+)
+
+// isPosixACLAttribute reports whether the extended attribute name stores a
+// POSIX ACL.
+func isPosixACLAttribute(name string) bool {
+	return name == posixACLAccessAttribute || name == posixACLDefaultAttribute
+}
+
+// PosixACL contains the POSIX ACLs of a file or directory. The access ACL
+// controls the access to the file itself, the default ACL of a directory is
+// inherited by new files and subdirectories.
+type PosixACL struct {
+	Access  []PosixACLEntry `json:"access,omitempty"`
+	Default []PosixACLEntry `json:"default,omitempty"`
+}
+
+// PosixACLEntry is a single entry of a POSIX ACL. ID is only set for entries
+// of the tags "user" and "group".
+type PosixACLEntry struct {
+	Tag  string  `json:"tag"`
+	ID   *uint32 `json:"id,omitempty"`
+	Perm uint16  `json:"perm"`
+}
+
+// Tags of POSIX ACL entries.
+const (
+	PosixACLUserObj  = "user_obj"
+	PosixACLUser     = "user"
+	PosixACLGroupObj = "group_obj"
+	PosixACLGroup    = "group"
+	PosixACLMask     = "mask"
+	PosixACLOther    = "other"
+)
+
+// binary representation used by the Linux kernel, see linux/posix_acl_xattr.h
+const (
+	posixACLXattrVersion = 2
+	posixACLUndefinedID  = 0xffffffff
+	posixACLHeaderSize   = 4
+	posixACLEntrySize    = 8
+)
+
+var posixACLTags = map[string]uint16{
+	PosixACLUserObj:  0x01,
+	PosixACLUser:     0x02,
+	PosixACLGroupObj: 0x04,
+	PosixACLGroup:    0x08,
+	PosixACLMask:     0x10,
+	PosixACLOther:    0x20,
+}
+
+// decodePosixACL parses the value of a POSIX ACL extended attribute. An empty
+// value yields an empty ACL.
+func decodePosixACL(data []byte) ([]PosixACLEntry, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if len(data) < posixACLHeaderSize || (len(data)-posixACLHeaderSize)%posixACLEntrySize != 0 {
+		return nil, errors.Errorf("invalid POSIX ACL size %d", len(data))
+	}
+	if v := binary.LittleEndian.Uint32(data); v != posixACLXattrVersion {
+		return nil, errors.Errorf("unsupported POSIX ACL version %d", v)
+	}
+
+	entries := make([]PosixACLEntry, 0, (len(data)-posixACLHeaderSize)/posixACLEntrySize)
+	for buf := data[posixACLHeaderSize:]; len(buf) > 0; buf = buf[posixACLEntrySize:] {
+		tag := binary.LittleEndian.Uint16(buf[0:])
+		entry := PosixACLEntry{Perm: binary.LittleEndian.Uint16(buf[2:])}
+		for name, value := range posixACLTags {
+			if value == tag {
+				entry.Tag = name
+			}
+		}
+		if entry.Tag == "" {
+			return nil, errors.Errorf("unknown POSIX ACL tag %#x", tag)
+		}
+		if entry.Tag == PosixACLUser || entry.Tag == PosixACLGroup {
+			id := binary.LittleEndian.Uint32(buf[4:])
+			entry.ID = &id
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// encodePosixACL returns the value of the POSIX ACL extended attribute for
+// entries.
+func encodePosixACL(entries []PosixACLEntry) []byte {
+	data := make([]byte, posixACLHeaderSize, posixACLHeaderSize+len(entries)*posixACLEntrySize)
+	binary.LittleEndian.PutUint32(data, posixACLXattrVersion)
+	for _, entry := range entries {
+		var buf [posixACLEntrySize]byte
+		binary.LittleEndian.PutUint16(buf[0:], posixACLTags[entry.Tag])
+		binary.LittleEndian.PutUint16(buf[2:], entry.Perm)
+		id := uint32(posixACLUndefinedID)
+		if entry.ID != nil {
+			id = *entry.ID
+		}
+		binary.LittleEndian.PutUint32(buf[4:], id)
+		data = append(data, buf[:]...)
+	}
+	return data
+}
+
+// validatePosixACL checks that entries form a valid ACL, following the rules
+// of acl_valid(3): the owner, owning group and other entries must exist
+// exactly once, a mask entry is required if named users or groups are present
+// and each named user or group may only appear once.
+func validatePosixACL(entries []PosixACLEntry) error {
+	counts := make(map[string]int)
+	users := make(map[uint32]struct{})
+	groups := make(map[uint32]struct{})
+
+	for _, entry := range entries {
+		if _, ok := posixACLTags[entry.Tag]; !ok {
+			return fmt.Errorf("unknown tag %q", entry.Tag)
+		}
+		if entry.Perm&^0o7 != 0 {
+			return fmt.Errorf("invalid permissions %#o for %v entry", entry.Perm, entry.Tag)
+		}
+		counts[entry.Tag]++
+
+		named := entry.Tag == PosixACLUser || entry.Tag == PosixACLGroup
+		if named != (entry.ID != nil) {
+			if named {
+				return fmt.Errorf("%v entry without id", entry.Tag)
+			}
+			return fmt.Errorf("unexpected id for %v entry", entry.Tag)
+		}
+
+		switch entry.Tag {
+		case PosixACLUser:
+			if _, ok := users[*entry.ID]; ok {
+				return fmt.Errorf("duplicate entry for user %d", *entry.ID)
+			}
+			users[*entry.ID] = struct{}{}
+		case PosixACLGroup:
+			if _, ok := groups[*entry.ID]; ok {
+				return fmt.Errorf("duplicate entry for group %d", *entry.ID)
+			}
+			groups[*entry.ID] = struct{}{}
+		}
+	}
+
+	for _, tag := range []string{PosixACLUserObj, PosixACLGroupObj, PosixACLOther} {
+		if counts[tag] != 1 {
+			return fmt.Errorf("expected one %v entry, found %d", tag, counts[tag])
+		}
+	}
+	if counts[PosixACLMask] > 1 {
+		return fmt.Errorf("expected at most one %v entry, found %d", PosixACLMask, counts[PosixACLMask])
+	}
+	if (len(users) > 0 || len(groups) > 0) && counts[PosixACLMask] == 0 {
+		return fmt.Errorf("missing %v entry", PosixACLMask)
+	}
+	return nil
+}
+
+// Validate checks that the access and default ACL are valid. Default ACLs
+// are only allowed for directories.
+func (acl *PosixACL) Validate(nodeType string) error {
+	if len(acl.Access) > 0 {
+		if err := validatePosixACL(acl.Access); err != nil {
+			return fmt.Errorf("invalid access ACL: %v", err)
+		}
+	}
+	if len(acl.Default) > 0 {
+		if nodeType != "dir" {
+			return fmt.Errorf("default ACL is only allowed for directories")
+		}
+		if err := validatePosixACL(acl.Default); err != nil {
+			return fmt.Errorf("invalid default ACL: %v", err)
+		}
+	}
+	return nil
+}
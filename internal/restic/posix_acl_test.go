@@ -0,0 +1,78 @@
+package restic
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func posixACLID(id uint32) *uint32 {
+	return &id
+}
+
+func TestPosixACLEncoding(t *testing.T) {
+	entries := []PosixACLEntry{
+		{Tag: PosixACLUserObj, Perm: 6},
+		{Tag: PosixACLUser, ID: posixACLID(1000), Perm: 4},
+		{Tag: PosixACLGroupObj, Perm: 4},
+		{Tag: PosixACLGroup, ID: posixACLID(0), Perm: 5},
+		{Tag: PosixACLMask, Perm: 5},
+		{Tag: PosixACLOther, Perm: 0},
+	}
+
+	data := encodePosixACL(entries)
+	rtest.Equals(t, 4+8*len(entries), len(data))
+	decoded, err := decodePosixACL(data)
+	rtest.OK(t, err)
+	rtest.Equals(t, entries, decoded)
+
+	decoded, err = decodePosixACL(nil)
+	rtest.OK(t, err)
+	rtest.Assert(t, decoded == nil, "unexpected entries %v", decoded)
+
+	for _, data := range [][]byte{
+		{2, 0, 0},
+		{2, 0, 0, 0, 1, 0},
+		{1, 0, 0, 0},
+		{2, 0, 0, 0, 0x40, 0, 7, 0, 0xff, 0xff, 0xff, 0xff},
+	} {
+		_, err := decodePosixACL(data)
+		rtest.Assert(t, err != nil, "missing error for %v", data)
+	}
+}
+
+func TestPosixACLValidate(t *testing.T) {
+	minimal := []PosixACLEntry{
+		{Tag: PosixACLUserObj, Perm: 7},
+		{Tag: PosixACLGroupObj, Perm: 5},
+		{Tag: PosixACLOther, Perm: 5},
+	}
+	named := append([]PosixACLEntry{
+		{Tag: PosixACLUser, ID: posixACLID(1000), Perm: 7},
+		{Tag: PosixACLMask, Perm: 7},
+	}, minimal...)
+
+	for _, test := range []struct {
+		acl      PosixACL
+		nodeType string
+		valid    bool
+	}{
+		{PosixACL{Access: minimal}, "file", true},
+		{PosixACL{Access: named, Default: minimal}, "dir", true},
+		{PosixACL{Default: minimal}, "file", false},
+		{PosixACL{Access: minimal[1:]}, "file", false},
+		{PosixACL{Access: append([]PosixACLEntry{{Tag: PosixACLUser, ID: posixACLID(1000), Perm: 7}}, minimal...)}, "file", false},
+		{PosixACL{Access: append([]PosixACLEntry{{Tag: PosixACLUser, Perm: 7}, {Tag: PosixACLMask, Perm: 7}}, minimal...)}, "file", false},
+		{PosixACL{Access: append([]PosixACLEntry{{Tag: PosixACLMask, ID: posixACLID(1), Perm: 7}}, minimal...)}, "file", false},
+		{PosixACL{Access: append([]PosixACLEntry{named[0]}, named...)}, "file", false},
+		{PosixACL{Access: append([]PosixACLEntry{{Tag: PosixACLMask, Perm: 8}}, minimal...)}, "file", false},
+		{PosixACL{Access: append([]PosixACLEntry{{Tag: "unknown", Perm: 7}}, minimal...)}, "file", false},
+	} {
+		err := test.acl.Validate(test.nodeType)
+		if test.valid {
+			rtest.OK(t, err)
+		} else {
+			rtest.Assert(t, err != nil, "missing error for %v", test.acl)
+		}
+	}
+}
@@ -72,6 +72,7 @@ const (
 	SnapshotFile FileType = backend.SnapshotFile
 	IndexFile    FileType = backend.IndexFile
 	ConfigFile   FileType = backend.ConfigFile
+	RuleSetFile  FileType = backend.RuleSetFile
 )
 
 // LoaderUnpacked allows loading a blob not stored in a pack file
@@ -0,0 +1,138 @@
+package restic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// RuleSet is a version of a named set of exclude rules, such that all clients
+// of a repository can use the same rules for their backups. Each version is
+// stored as a separate file in the repository.
+type RuleSet struct {
+	Name                string    `json:"name"`
+	Version             int       `json:"version"`
+	Time                time.Time `json:"time"`
+	Excludes            []string  `json:"excludes,omitempty"`
+	InsensitiveExcludes []string  `json:"iexcludes,omitempty"`
+	ExcludePresets      []string  `json:"exclude_presets,omitempty"`
+
+	id *ID // ID of the file the rule set was loaded from
+}
+
+// ID returns the ID of the file the rule set was loaded from.
+func (rs RuleSet) ID() *ID {
+	return rs.id
+}
+
+// Equal returns whether both rule sets contain the same rules.
+func (rs RuleSet) Equal(other RuleSet) bool {
+	equal := func(a, b []string) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+	return equal(rs.Excludes, other.Excludes) &&
+		equal(rs.InsensitiveExcludes, other.InsensitiveExcludes) &&
+		equal(rs.ExcludePresets, other.ExcludePresets)
+}
+
+// ValidateRuleSetName returns an error if name cannot be used as the name of a
+// rule set.
+func ValidateRuleSetName(name string) error {
+	if name == "" {
+		return errors.New("empty rule set name")
+	}
+	if strings.ContainsAny(name, "@ \t\r\n") {
+		return errors.Errorf("invalid rule set name %q, must not contain '@' or whitespace", name)
+	}
+	return nil
+}
+
+// RuleSets contains the versions of the rule sets stored in a repository by
+// name. The versions are sorted, the last one is the current version.
+type RuleSets map[string][]RuleSet
+
+// LoadRuleSets loads all rule sets stored in the repository.
+func LoadRuleSets(ctx context.Context, repo ListerLoaderUnpacked) (RuleSets, error) {
+	var m sync.Mutex
+	ruleSets := make(RuleSets)
+	err := ParallelList(ctx, repo, RuleSetFile, repo.Connections(), func(ctx context.Context, id ID, _ int64) error {
+		rs := RuleSet{id: &id}
+		err := LoadJSONUnpacked(ctx, repo, RuleSetFile, id, &rs)
+		if err != nil {
+			return fmt.Errorf("failed to load rule set %v: %w", id.Str(), err)
+		}
+
+		m.Lock()
+		defer m.Unlock()
+		ruleSets[rs.Name] = append(ruleSets[rs.Name], rs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, versions := range ruleSets {
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].Version < versions[j].Version
+		})
+	}
+	return ruleSets, nil
+}
+
+// SaveRuleSet saves the rule set rs and returns its ID.
+func SaveRuleSet(ctx context.Context, repo SaverUnpacked, rs RuleSet) (ID, error) {
+	return SaveJSONUnpacked(ctx, repo, RuleSetFile, rs)
+}
+
+// Names returns the sorted names of the rule sets.
+func (r RuleSets) Names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the rule set referenced by spec, which has the form "name"
+// for the current version or "name@version" for a specific version of the
+// rule set.
+func (r RuleSets) Lookup(spec string) (RuleSet, error) {
+	name, version, hasVersion := strings.Cut(spec, "@")
+	if err := ValidateRuleSetName(name); err != nil {
+		return RuleSet{}, err
+	}
+
+	versions := r[name]
+	if len(versions) == 0 {
+		return RuleSet{}, errors.Errorf("rule set %q not found in repository", name)
+	}
+	if !hasVersion {
+		return versions[len(versions)-1], nil
+	}
+
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return RuleSet{}, errors.Errorf("invalid version %q of rule set %q", version, name)
+	}
+	for _, rs := range versions {
+		if rs.Version == v {
+			return rs, nil
+		}
+	}
+	return RuleSet{}, errors.Errorf("rule set %q has no version %d", name, v)
+}
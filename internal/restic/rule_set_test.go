@@ -0,0 +1,38 @@
+package restic_test
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestLookupRuleSet(t *testing.T) {
+	ruleSets := restic.RuleSets{
+		"corp-default": {
+			{Name: "corp-default", Version: 1, Excludes: []string{"*.tmp"}},
+			{Name: "corp-default", Version: 2, Excludes: []string{"*.tmp", "/cache"}},
+		},
+	}
+
+	rs, err := ruleSets.Lookup("corp-default")
+	rtest.OK(t, err)
+	rtest.Equals(t, "corp-default", rs.Name)
+	rtest.Equals(t, 2, rs.Version)
+
+	rs, err = ruleSets.Lookup("corp-default@1")
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"*.tmp"}, rs.Excludes)
+
+	for _, spec := range []string{"", "other", "corp-default@3", "corp-default@x", "corp default", "@1"} {
+		_, err = ruleSets.Lookup(spec)
+		rtest.Assert(t, err != nil, "missing error for %q", spec)
+	}
+}
+
+func TestRuleSetEqual(t *testing.T) {
+	rs := restic.RuleSet{Version: 1, Excludes: []string{"*.tmp"}, ExcludePresets: []string{"caches"}}
+	rtest.Assert(t, rs.Equal(restic.RuleSet{Version: 2, Excludes: []string{"*.tmp"}, ExcludePresets: []string{"caches"}}), "versions should not matter")
+	rtest.Assert(t, !rs.Equal(restic.RuleSet{Excludes: []string{"*.tmp"}}), "presets should matter")
+	rtest.Assert(t, !rs.Equal(restic.RuleSet{InsensitiveExcludes: []string{"*.tmp"}, ExcludePresets: []string{"caches"}}), "casing should matter")
+}
@@ -0,0 +1,116 @@
+package restic
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Names of the extended attributes which store the SELinux context and the
+// file capabilities on Linux.
+const (
+	selinuxAttribute    = "security.selinux"
+	capabilityAttribute = "security.capability"
+)
+
+// Revisions of the vfs_cap_data structure stored in security.capability, see
+// include/uapi/linux/capability.h.
+const (
+	vfsCapRevisionMask  = 0xff000000
+	vfsCapFlagsMask     = 0x00ffffff
+	vfsCapFlagEffective = 0x000001
+
+	vfsCapRevision1 = 0x01000000
+	vfsCapRevision2 = 0x02000000
+	vfsCapRevision3 = 0x03000000
+)
+
+// ParseSELinuxContext returns the SELinux context stored in the extended
+// attribute security.selinux and checks that it has the form
+// user:role:type[:level].
+func ParseSELinuxContext(value []byte) (string, error) {
+	context := strings.TrimRight(string(value), "\x00")
+	if err := validateSELinuxContext(context); err != nil {
+		return "", err
+	}
+	return context, nil
+}
+
+func validateSELinuxContext(context string) error {
+	for _, c := range context {
+		if c < 0x20 || c > 0x7e {
+			return fmt.Errorf("invalid SELinux context %q: contains non-printable characters", context)
+		}
+	}
+	// the level may contain colons as well, e.g. s0-s0:c0.c1023
+	fields := strings.SplitN(context, ":", 4)
+	if len(fields) < 3 {
+		return fmt.Errorf("invalid SELinux context %q: expected user:role:type[:level]", context)
+	}
+	for _, field := range fields {
+		if field == "" {
+			return fmt.Errorf("invalid SELinux context %q: empty field", context)
+		}
+	}
+	return nil
+}
+
+// ValidateCapabilities checks that value is a valid vfs_cap_data structure
+// as stored in the extended attribute security.capability.
+func ValidateCapabilities(value []byte) error {
+	if len(value) < 4 {
+		return fmt.Errorf("invalid file capabilities: %d bytes are too short", len(value))
+	}
+	magic := binary.LittleEndian.Uint32(value)
+	if magic&vfsCapFlagsMask&^vfsCapFlagEffective != 0 {
+		return fmt.Errorf("invalid file capabilities: unknown flags %#x", magic&vfsCapFlagsMask)
+	}
+
+	var size int
+	switch magic & vfsCapRevisionMask {
+	case vfsCapRevision1:
+		size = 12
+	case vfsCapRevision2:
+		size = 20
+	case vfsCapRevision3:
+		size = 24
+	default:
+		return fmt.Errorf("invalid file capabilities: unknown revision %#x", magic&vfsCapRevisionMask)
+	}
+	if len(value) != size {
+		return fmt.Errorf("invalid file capabilities: expected %d bytes for revision %d, got %d", size, magic>>24, len(value))
+	}
+	return nil
+}
+
+// WithoutSecurityAttributes returns a copy of the node without its SELinux
+// context if selinux is set and without its file capabilities if caps is set.
+// Both are removed from the generic and the extended attributes, as older
+// versions of restic saved them as extended attributes.
+func (node *Node) WithoutSecurityAttributes(selinux, caps bool) *Node {
+	if !selinux && !caps {
+		return node
+	}
+
+	n := *node
+	n.GenericAttributes = nil
+	for t, v := range node.GenericAttributes {
+		if (selinux && t == TypeSELinuxContext) || (caps && t == TypeCapabilities) {
+			continue
+		}
+		if n.GenericAttributes == nil {
+			n.GenericAttributes = make(map[GenericAttributeType]json.RawMessage)
+		}
+		n.GenericAttributes[t] = v
+	}
+
+	n.ExtendedAttributes = nil
+	for _, attr := range node.ExtendedAttributes {
+		if (selinux && attr.Name == selinuxAttribute) || (caps && attr.Name == capabilityAttribute) {
+			continue
+		}
+		n.ExtendedAttributes = append(n.ExtendedAttributes, attr)
+	}
+	return &n
+}
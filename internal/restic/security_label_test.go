@@ -0,0 +1,78 @@
+package restic
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseSELinuxContext(t *testing.T) {
+	for _, test := range []struct {
+		value   string
+		context string
+		valid   bool
+	}{
+		{"system_u:object_r:etc_t:s0\x00", "system_u:object_r:etc_t:s0", true},
+		{"unconfined_u:object_r:user_home_t:s0-s0:c0.c1023", "unconfined_u:object_r:user_home_t:s0-s0:c0.c1023", true},
+		{"user_u:role_r:type_t", "user_u:role_r:type_t", true},
+		{"", "", false},
+		{"system_u:object_r", "", false},
+		{"system_u::etc_t:s0", "", false},
+		{"system_u:object_r:etc\n_t:s0", "", false},
+	} {
+		context, err := ParseSELinuxContext([]byte(test.value))
+		rtest.Equals(t, test.valid, err == nil, fmt.Sprintf("context %q: %v", test.value, err))
+		rtest.Equals(t, test.context, context)
+	}
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	for _, test := range []struct {
+		value []byte
+		valid bool
+	}{
+		// cap_net_bind_service+ep, revision 2
+		{[]byte{0x01, 0, 0, 0x02, 0, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, true},
+		// revision 3 with root ID
+		{[]byte{0, 0, 0, 0x03, 0, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xe8, 0x03, 0, 0}, true},
+		{[]byte{0, 0, 0, 0x01, 0, 0x04, 0, 0, 0, 0, 0, 0}, true},
+		{nil, false},
+		{[]byte{0x01, 0, 0, 0x02, 0, 0x04, 0, 0}, false},
+		{[]byte{0x01, 0, 0, 0x04, 0, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, false},
+		{[]byte{0x02, 0, 0, 0x02, 0, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, false},
+	} {
+		err := ValidateCapabilities(test.value)
+		rtest.Equals(t, test.valid, err == nil, fmt.Sprintf("capabilities %x: %v", test.value, err))
+	}
+}
+
+func TestWithoutSecurityAttributes(t *testing.T) {
+	node := &Node{
+		Name: "ping",
+		GenericAttributes: map[GenericAttributeType]json.RawMessage{
+			TypeSELinuxContext: json.RawMessage(`"system_u:object_r:ping_exec_t:s0"`),
+			TypeCapabilities:   json.RawMessage(`"AQAAAgAgAAAAAAAAAAAAAAAAAAA="`),
+			TypeInodeFlags:     json.RawMessage(`16`),
+		},
+		ExtendedAttributes: []ExtendedAttribute{
+			{Name: selinuxAttribute, Value: []byte("system_u:object_r:ping_exec_t:s0")},
+			{Name: "user.comment", Value: []byte("foo")},
+		},
+	}
+
+	rtest.Assert(t, node.WithoutSecurityAttributes(false, false) == node, "node was copied")
+
+	n := node.WithoutSecurityAttributes(true, false)
+	rtest.Equals(t, 2, len(n.GenericAttributes))
+	rtest.Assert(t, n.GenericAttributes[TypeSELinuxContext] == nil, "SELinux context was not removed")
+	rtest.Equals(t, []ExtendedAttribute{{Name: "user.comment", Value: []byte("foo")}}, n.ExtendedAttributes)
+
+	n = node.WithoutSecurityAttributes(true, true)
+	rtest.Equals(t, 1, len(n.GenericAttributes))
+
+	// the original node is unchanged
+	rtest.Equals(t, 3, len(node.GenericAttributes))
+	rtest.Equals(t, 2, len(node.ExtendedAttributes))
+}
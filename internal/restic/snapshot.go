@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/fs"
 )
 
 // Snapshot is the state of a resource at one point in time.
@@ -25,6 +26,38 @@ type Snapshot struct {
 	Tags     []string  `json:"tags,omitempty"`
 	Original *ID       `json:"original,omitempty"`
 
+	// Volumes contains the metadata of volumes whose root was backed up.
+	Volumes []fs.VolumeInfo `json:"volumes,omitempty"`
+
+	// ChangeJournals contains the positions in the change journals of the
+	// backed up volumes at the start of the backup. They are only set if all
+	// files could be read.
+	ChangeJournals []fs.ChangeJournal `json:"change_journals,omitempty"`
+
+	// Manifest lists the pack files which were uploaded while creating the
+	// snapshot. It allows quickly detecting pack files which were deleted
+	// from the storage.
+	Manifest []ManifestPack `json:"manifest,omitempty"`
+
+	// Catalog references the catalog of the snapshot created using "backup
+	// --catalog", which lists all paths of the snapshot.
+	Catalog *ID `json:"catalog,omitempty"`
+
+	// Expires is the time after which the snapshot may be removed by
+	// "forget --honor-expiry", regardless of the keep policy.
+	Expires *time.Time `json:"expires,omitempty"`
+
+	// Notes contains the annotations added using the "note" command.
+	Notes []SnapshotNote `json:"notes,omitempty"`
+
+	// Remote is set for snapshots which were copied without their file
+	// contents using "copy --trees-only". The data blobs referenced by the
+	// trees are only stored in the repository described by Remote.
+	Remote *SnapshotRemote `json:"remote,omitempty"`
+
+	// Trashed is set for snapshots which were moved to the trash by forget.
+	Trashed *SnapshotTrash `json:"trashed,omitempty"`
+
 	ProgramVersion string           `json:"program_version,omitempty"`
 	Summary        *SnapshotSummary `json:"summary,omitempty"`
 
@@ -48,6 +81,29 @@ type SnapshotSummary struct {
 	DataAddedPacked     uint64 `json:"data_added_packed"`
 	TotalFilesProcessed uint   `json:"total_files_processed"`
 	TotalBytesProcessed uint64 `json:"total_bytes_processed"`
+	// Deduplicated data which was already present in the repository, see
+	// archiver.ItemStats.
+	DataFromParent uint64 `json:"data_from_parent,omitempty"`
+	DataFromBackup uint64 `json:"data_from_backup,omitempty"`
+	DataFromRepo   uint64 `json:"data_from_repo,omitempty"`
+	// Errors is the number of files and directories which could not be read.
+	Errors uint `json:"errors,omitempty"`
+}
+
+// ManifestPack describes a pack file uploaded during a backup. As the ID of a
+// pack file is the SHA-256 hash of its content, the ID also serves as hash.
+type ManifestPack struct {
+	ID   ID    `json:"id"`
+	Size int64 `json:"size"`
+}
+
+// SnapshotRemote describes the repository which stores the data blobs of a
+// snapshot copied using "copy --trees-only".
+type SnapshotRemote struct {
+	// Repository is the ID of the repository.
+	Repository string `json:"repository"`
+	// Location is the location of the repository without password.
+	Location string `json:"location,omitempty"`
 }
 
 // NewSnapshot returns an initialized snapshot struct for the current user and
@@ -120,6 +176,18 @@ func (sn Snapshot) String() string {
 		sn.id.Str(), sn.Paths, sn.Time, sn.Username, sn.Hostname)
 }
 
+// Expired reports whether the snapshot has an expiry time which is not after
+// now.
+func (sn *Snapshot) Expired(now time.Time) bool {
+	return sn.Expires != nil && !sn.Expires.After(now)
+}
+
+// MetadataOnly reports whether the data blobs of the snapshot are stored in
+// a different repository.
+func (sn *Snapshot) MetadataOnly() bool {
+	return sn.Remote != nil
+}
+
 // ID returns the snapshot's ID.
 func (sn Snapshot) ID() *ID {
 	return sn.id
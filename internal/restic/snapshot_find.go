@@ -13,6 +13,9 @@ import (
 // ErrNoSnapshotFound is returned when no snapshot for the given criteria could be found.
 var ErrNoSnapshotFound = errors.New("no snapshot found")
 
+// ErrSnapshotInTrash is returned when a snapshot was requested which is in the trash.
+var ErrSnapshotInTrash = errors.New("snapshot is in the trash")
+
 // A SnapshotFilter denotes a set of snapshots based on hosts, tags and paths.
 type SnapshotFilter struct {
 	_ struct{} // Force naming fields in literals.
@@ -29,7 +32,7 @@ func (f *SnapshotFilter) Empty() bool {
 }
 
 func (f *SnapshotFilter) matches(sn *Snapshot) bool {
-	return sn.HasHostname(f.Hosts) && sn.HasTagList(f.Tags) && sn.HasPaths(f.Paths)
+	return !sn.InTrash() && sn.HasHostname(f.Hosts) && sn.HasTagList(f.Tags) && sn.HasPaths(f.Paths)
 }
 
 // findLatest finds the latest snapshot with optional target/directory,
@@ -103,6 +106,9 @@ func FindSnapshot(ctx context.Context, be Lister, loader LoaderUnpacked, s strin
 		}
 	}
 	sn, err := LoadSnapshot(ctx, loader, id)
+	if err == nil && sn.InTrash() {
+		return nil, "", fmt.Errorf("snapshot %v: %w", id.Str(), ErrSnapshotInTrash)
+	}
 	return sn, subfolder, err
 }
 
@@ -0,0 +1,18 @@
+package restic
+
+import (
+	"time"
+)
+
+// SnapshotNote is a short annotation of a snapshot, for example the result of
+// a restore test using the snapshot.
+type SnapshotNote struct {
+	Time   time.Time `json:"time"`
+	Author string    `json:"author,omitempty"`
+	Text   string    `json:"text"`
+}
+
+// AddNote appends note to the notes of the snapshot.
+func (sn *Snapshot) AddNote(note SnapshotNote) {
+	sn.Notes = append(sn.Notes, note)
+}
@@ -25,6 +25,7 @@ type ExpirePolicy struct {
 	WithinMonthly Duration  // keep monthly snapshots made within this duration
 	WithinYearly  Duration  // keep yearly snapshots made within this duration
 	Tags          []TagList // keep all snapshots that include at least one of the tag lists.
+	Expiry        bool      // remove snapshots whose expiry time has passed, regardless of the rules above
 }
 
 func (e ExpirePolicy) String() (s string) {
@@ -96,8 +97,14 @@ func (e ExpirePolicy) String() (s string) {
 
 	if s == "" {
 		s = "remove"
+		if e.Expiry {
+			s += " expired snapshots"
+		}
 	} else {
 		s = "keep " + s
+		if e.Expiry {
+			s += ", remove expired snapshots"
+		}
 	}
 
 	return s
@@ -224,11 +231,26 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 	}
 
 	latest := findLatestTimestamp(list)
+	now := time.Now()
+	// without other rules, all snapshots which are not expired are kept
+	rules := p
+	rules.Expiry = false
+	onlyExpiry := p.Expiry && rules.Empty()
 
 	for nr, cur := range list {
 		var keepSnap bool
 		var keepSnapReasons []string
 
+		// Expired snapshots are removed and do not count for the other rules.
+		if p.Expiry && cur.Expired(now) {
+			remove = append(remove, cur)
+			continue
+		}
+		if onlyExpiry {
+			keepSnap = true
+			keepSnapReasons = append(keepSnapReasons, "not expired")
+		}
+
 		// Tags are handled specially as they are not counted.
 		for _, l := range p.Tags {
 			if cur.HasTags(l) {
@@ -285,3 +285,46 @@ func TestApplyPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyPolicyExpiry(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	snapshots := restic.Snapshots{
+		{Time: parseTimeUTC("2014-09-04 10:20:30"), Expires: &future},
+		{Time: parseTimeUTC("2014-09-03 10:20:30")},
+		{Time: parseTimeUTC("2014-09-02 10:20:30"), Expires: &past},
+		{Time: parseTimeUTC("2014-09-01 10:20:30"), Expires: &past, Tags: []string{"foo"}},
+	}
+
+	for _, test := range []struct {
+		policy restic.ExpirePolicy
+		keep   []int
+		descr  string
+	}{
+		// without other rules, all snapshots which are not expired are kept
+		{restic.ExpirePolicy{Expiry: true}, []int{0, 1}, "remove expired snapshots"},
+		// expired snapshots do not count for the keep rules
+		{restic.ExpirePolicy{Last: 3, Expiry: true}, []int{0, 1}, "keep 3 latest snapshots, remove expired snapshots"},
+		{restic.ExpirePolicy{Last: 1, Tags: []restic.TagList{{"foo"}}, Expiry: true}, []int{0}, "keep 1 latest snapshots and all snapshots with tags [[foo]], remove expired snapshots"},
+		// the expiry time is ignored without Expiry
+		{restic.ExpirePolicy{Last: 3}, []int{0, 1, 2}, "keep 3 latest snapshots"},
+	} {
+		t.Run(test.descr, func(t *testing.T) {
+			keep, remove, reasons := restic.ApplyPolicy(append(restic.Snapshots{}, snapshots...), test.policy)
+			var expected restic.Snapshots
+			for _, i := range test.keep {
+				expected = append(expected, snapshots[i])
+			}
+			cmpOpts := cmpopts.IgnoreUnexported(restic.Snapshot{})
+			if !cmp.Equal(expected, keep, cmpOpts) {
+				t.Error(cmp.Diff(expected, keep, cmpOpts))
+			}
+			if len(keep)+len(remove) != len(snapshots) || len(reasons) != len(keep) {
+				t.Errorf("unexpected result: keep %v, remove %v, reasons %v", len(keep), len(remove), len(reasons))
+			}
+			if test.policy.String() != test.descr {
+				t.Errorf("wrong description, want %q, got %q", test.descr, test.policy.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package restic
+
+import (
+	"context"
+	"time"
+)
+
+// SnapshotTrash records that a snapshot was moved to the trash. Snapshots in
+// the trash are ignored when searching for snapshots, but their data is kept
+// by prune until the trash period has expired.
+type SnapshotTrash struct {
+	// ID is the ID of the snapshot before it was moved to the trash.
+	ID      ID        `json:"id"`
+	Time    time.Time `json:"time"`
+	Expires time.Time `json:"expires"`
+}
+
+// InTrash returns whether the snapshot was moved to the trash.
+func (sn *Snapshot) InTrash() bool {
+	return sn.Trashed != nil
+}
+
+// TrashExpired returns whether the snapshot is in the trash and its trash
+// period has expired at time now.
+func (sn *Snapshot) TrashExpired(now time.Time) bool {
+	return sn.Trashed != nil && !now.Before(sn.Trashed.Expires)
+}
+
+// TrashSnapshot moves the snapshot sn to the trash, where it is kept for the
+// given period. It returns the ID of the snapshot in the trash.
+func TrashSnapshot(ctx context.Context, repo SaverRemoverUnpacked, sn *Snapshot, now time.Time, period Duration) (ID, error) {
+	if sn.InTrash() {
+		return *sn.ID(), nil
+	}
+
+	trashed := *sn
+	trashed.Trashed = &SnapshotTrash{
+		ID:      *sn.ID(),
+		Time:    now,
+		Expires: now.AddDate(period.Years, period.Months, period.Days).Add(time.Duration(period.Hours) * time.Hour),
+	}
+
+	id, err := SaveSnapshot(ctx, repo, &trashed)
+	if err != nil {
+		return ID{}, err
+	}
+	return id, repo.RemoveUnpacked(ctx, SnapshotFile, *sn.ID())
+}
+
+// UndeleteSnapshot restores the snapshot sn from the trash and returns the
+// new ID of the restored snapshot.
+func UndeleteSnapshot(ctx context.Context, repo SaverRemoverUnpacked, sn *Snapshot) (ID, error) {
+	if !sn.InTrash() {
+		return *sn.ID(), nil
+	}
+
+	restored := *sn
+	restored.Trashed = nil
+
+	id, err := SaveSnapshot(ctx, repo, &restored)
+	if err != nil {
+		return ID{}, err
+	}
+	return id, repo.RemoveUnpacked(ctx, SnapshotFile, *sn.ID())
+}
@@ -0,0 +1,52 @@
+package restic_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestTrashSnapshot(t *testing.T) {
+	repo := repository.TestRepository(t)
+	restic.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1)
+	sn := restic.TestCreateSnapshot(t, repo, parseTimeUTC("2019-09-09 09:09:09"), 1)
+	id := *sn.ID()
+
+	now := parseTimeUTC("2020-01-01 00:00:00")
+	trashID, err := restic.TrashSnapshot(context.TODO(), repo, sn, now, restic.Duration{Days: 7})
+	rtest.OK(t, err)
+	rtest.Assert(t, trashID != id, "snapshot ID did not change")
+
+	trashed, err := restic.LoadSnapshot(context.TODO(), repo, trashID)
+	rtest.OK(t, err)
+	rtest.Equals(t, id, trashed.Trashed.ID)
+	rtest.Equals(t, now, trashed.Trashed.Time)
+	rtest.Assert(t, !trashed.TrashExpired(now.Add(6*24*time.Hour)), "trash period expired too early")
+	rtest.Assert(t, trashed.TrashExpired(now.Add(7*24*time.Hour)), "trash period did not expire")
+
+	// snapshots in the trash are ignored
+	latest, _, err := (&restic.SnapshotFilter{}).FindLatest(context.TODO(), repo, repo, "latest")
+	rtest.OK(t, err)
+	rtest.Assert(t, *latest.ID() != trashID, "FindLatest returned snapshot in the trash")
+	_, _, err = restic.FindSnapshot(context.TODO(), repo, repo, trashID.String())
+	rtest.Assert(t, errors.Is(err, restic.ErrSnapshotInTrash), "unexpected error %v", err)
+
+	restoredID, err := restic.UndeleteSnapshot(context.TODO(), repo, trashed)
+	rtest.OK(t, err)
+	restored, _, err := restic.FindSnapshot(context.TODO(), repo, repo, restoredID.String())
+	rtest.OK(t, err)
+	rtest.Equals(t, sn.Tree, restored.Tree)
+	rtest.Equals(t, sn.Time, restored.Time)
+
+	ids := restic.NewIDSet()
+	rtest.OK(t, repo.List(context.TODO(), restic.SnapshotFile, func(id restic.ID, _ int64) error {
+		ids.Insert(id)
+		return nil
+	}))
+	rtest.Assert(t, len(ids) == 2 && !ids.Has(id) && !ids.Has(trashID), "unexpected snapshots %v", ids)
+}
@@ -0,0 +1,144 @@
+package restorer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+)
+
+const (
+	// number of concurrent pack downloads an auto-tuned restore starts with
+	autoTuneMinWorkers = 2
+	// minimum number of packs downloaded before each tuning decision
+	autoTuneWindow = 8
+	// required throughput gain for keeping an increased worker count
+	autoTuneMinGain = 1.1
+	// minimum share of the latency in the download time of a pack for which
+	// more concurrent downloads are worth trying
+	autoTuneMinLatencyShare = 0.2
+)
+
+// autoTuner adjusts the number of concurrent pack downloads during a restore.
+// It starts with few downloads and doubles their number as long as the packs
+// spend a noticeable time waiting for the backend and the overall throughput
+// improves. Thus, a restore from a high-latency backend uses many concurrent
+// downloads, whereas a restore from a local NAS is not slowed down by
+// competing requests.
+type autoTuner struct {
+	m       sync.Mutex
+	max     int
+	active  int
+	settled bool
+	tokens  chan struct{}
+	now     func() time.Time
+
+	windowStart    time.Time
+	windowPacks    int
+	windowBytes    uint64
+	windowLatency  time.Duration
+	windowDuration time.Duration
+	lastThroughput float64
+}
+
+func newAutoTuner(maxWorkers int) *autoTuner {
+	t := &autoTuner{
+		max:    maxWorkers,
+		active: autoTuneMinWorkers,
+		tokens: make(chan struct{}, maxWorkers),
+		now:    time.Now,
+	}
+	if t.active > t.max {
+		t.active = t.max
+	}
+	for i := 0; i < t.active; i++ {
+		t.tokens <- struct{}{}
+	}
+	return t
+}
+
+// acquire waits until another pack download may start.
+func (t *autoTuner) acquire(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.tokens:
+		return nil
+	}
+}
+
+// release marks a pack download started by acquire as finished.
+func (t *autoTuner) release() {
+	t.tokens <- struct{}{}
+}
+
+// workers returns the current number of concurrent pack downloads.
+func (t *autoTuner) workers() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.active
+}
+
+// report records a downloaded pack. latency is the time until the first blob
+// was received, duration the time needed for the whole pack.
+func (t *autoTuner) report(latency, duration time.Duration, bytes uint64) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if t.settled {
+		return
+	}
+	if t.windowStart.IsZero() {
+		// the first pack was started before it was reported
+		t.windowStart = t.now().Add(-duration)
+	}
+	t.windowPacks++
+	t.windowBytes += bytes
+	t.windowLatency += latency
+	t.windowDuration += duration
+
+	window := autoTuneWindow
+	if 2*t.active > window {
+		window = 2 * t.active
+	}
+	if t.windowPacks < window {
+		return
+	}
+
+	elapsed := t.now().Sub(t.windowStart)
+	if elapsed <= 0 || t.windowDuration <= 0 {
+		return
+	}
+	throughput := float64(t.windowBytes) / elapsed.Seconds()
+	latencyShare := float64(t.windowLatency) / float64(t.windowDuration)
+	debug.Log("%d workers: throughput %.0f B/s, latency share %.2f", t.active, throughput, latencyShare)
+
+	switch {
+	case t.lastThroughput > 0 && throughput < t.lastThroughput*autoTuneMinGain:
+		debug.Log("more workers did not improve the throughput, keeping %d workers", t.active)
+		t.settled = true
+	case latencyShare < autoTuneMinLatencyShare:
+		debug.Log("restore is not limited by the backend latency, keeping %d workers", t.active)
+		t.settled = true
+	case t.active >= t.max:
+		debug.Log("reached the maximum of %d workers", t.max)
+		t.settled = true
+	default:
+		n := 2 * t.active
+		if n > t.max {
+			n = t.max
+		}
+		for i := t.active; i < n; i++ {
+			t.tokens <- struct{}{}
+		}
+		debug.Log("increasing workers from %d to %d", t.active, n)
+		t.active = n
+		t.lastThroughput = throughput
+		t.windowStart = t.now()
+		t.windowPacks = 0
+		t.windowBytes = 0
+		t.windowLatency = 0
+		t.windowDuration = 0
+	}
+}
@@ -0,0 +1,124 @@
+package restorer
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+// downloadWindow reports a full measurement window of packs which each need
+// duration, with latency until the first blob.
+func downloadWindow(tuner *autoTuner, clock *fakeClock, latency, duration time.Duration, bytes uint64) {
+	n := tuner.workers()
+	packs := autoTuneWindow
+	if 2*n > packs {
+		packs = 2 * n
+	}
+	for i := 0; i < packs; i++ {
+		if i%n == 0 {
+			clock.t = clock.t.Add(duration)
+		}
+		tuner.report(latency, duration, bytes)
+	}
+}
+
+func newTestAutoTuner(maxWorkers int) (*autoTuner, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	tuner := newAutoTuner(maxWorkers)
+	tuner.now = clock.now
+	return tuner, clock
+}
+
+func TestAutoTunerHighLatency(t *testing.T) {
+	tuner, clock := newTestAutoTuner(16)
+	rtest.Equals(t, autoTuneMinWorkers, tuner.workers())
+
+	// packs spend most of the time waiting for the backend, thus the
+	// throughput grows with the number of workers
+	for i := 0; i < 10; i++ {
+		downloadWindow(tuner, clock, 900*time.Millisecond, time.Second, 1<<20)
+	}
+	rtest.Equals(t, 16, tuner.workers())
+	rtest.Equals(t, 16, len(tuner.tokens))
+}
+
+func TestAutoTunerLowLatency(t *testing.T) {
+	tuner, clock := newTestAutoTuner(16)
+
+	// the restore is limited by the transfer, not by the latency
+	for i := 0; i < 10; i++ {
+		downloadWindow(tuner, clock, 10*time.Millisecond, time.Second, 1<<20)
+	}
+	rtest.Equals(t, autoTuneMinWorkers, tuner.workers())
+}
+
+func TestAutoTunerNoGain(t *testing.T) {
+	tuner, clock := newTestAutoTuner(16)
+
+	downloadWindow(tuner, clock, 900*time.Millisecond, time.Second, 1<<20)
+	rtest.Equals(t, 4, tuner.workers())
+
+	// twice the workers, but each pack takes twice as long
+	for i := 0; i < 10; i++ {
+		downloadWindow(tuner, clock, 1800*time.Millisecond, 2*time.Second, 1<<20)
+	}
+	rtest.Equals(t, 4, tuner.workers())
+}
+
+func TestAutoTunerMaxWorkers(t *testing.T) {
+	tuner, _ := newTestAutoTuner(1)
+	rtest.Equals(t, 1, tuner.workers())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rtest.OK(t, tuner.acquire(ctx))
+	cancel()
+	rtest.Equals(t, context.Canceled, tuner.acquire(ctx))
+	tuner.release()
+}
+
+func TestFileRestorerAutoTune(t *testing.T) {
+	content := []TestFile{
+		{
+			name: "file1",
+			blobs: []TestBlob{
+				{"data1-1", "pack1-1"},
+				{"data1-2", "pack1-2"},
+			},
+		},
+		{
+			name: "file2",
+			blobs: []TestBlob{
+				{"data2-1", "pack2-1"},
+				{"data2-2", "pack2-2"},
+			},
+		},
+	}
+
+	tempdir := rtest.TempDir(t)
+	repo := newTestRepo(content)
+	r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 4, false, nil)
+	r.tuner = newAutoTuner(r.workerCount)
+	r.files = repo.files
+	rtest.OK(t, r.restoreFiles(context.TODO()))
+
+	for _, file := range content {
+		data, err := os.ReadFile(r.targetPath(file.name))
+		rtest.OK(t, err)
+		var expected string
+		for _, blob := range file.blobs {
+			expected += blob.data
+		}
+		rtest.Equals(t, expected, string(data))
+	}
+}
@@ -0,0 +1,198 @@
+package restorer
+
+import (
+	"io"
+	"os"
+	"sort"
+
+	"github.com/restic/chunker"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// blobCopy moves a blob within an existing file from offset src to offset dst.
+type blobCopy struct {
+	idx    int // index of the blob in the file content
+	id     restic.ID
+	src    int64
+	dst    int64
+	length int64
+}
+
+// findLocalBlobs splits the file into chunks like the backup command does and
+// returns the offsets of the chunks which are one of the wanted blobs.
+func (res *Restorer) findLocalBlobs(f *os.File, wanted restic.IDSet, buf []byte) (map[restic.ID]int64, []byte, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, buf, err
+	}
+
+	found := make(map[restic.ID]int64)
+	chnk := chunker.New(f, res.repo.Config().ChunkerPolynomial)
+	for {
+		chunk, err := chnk.Next(buf)
+		if err == io.EOF {
+			return found, buf, nil
+		}
+		if err != nil {
+			return nil, buf, err
+		}
+		buf = chunk.Data
+
+		id := restic.Hash(chunk.Data)
+		if _, ok := found[id]; !ok && wanted.Has(id) {
+			found[id] = int64(chunk.Start)
+		}
+	}
+}
+
+// orderCopies returns the copies in an order in which no copy overwrites the
+// source of a later one. Copies which form a cycle cannot be ordered, the
+// first copy of each cycle is dropped and its blob has to be downloaded.
+func orderCopies(copies []blobCopy) []blobCopy {
+	// sources are either disjoint or identical, thus sorting them by start
+	// also sorts them by end
+	bySource := make([]int, len(copies))
+	for i := range bySource {
+		bySource[i] = i
+	}
+	sort.Slice(bySource, func(a, b int) bool {
+		return copies[bySource[a]].src < copies[bySource[b]].src
+	})
+
+	// copy j must run before copy i if the destination of i overlaps the
+	// source of j
+	before := make([][]int, len(copies))
+	pending := make([]int, len(copies))
+	for i, c := range copies {
+		first := sort.Search(len(bySource), func(k int) bool {
+			s := copies[bySource[k]]
+			return s.src+s.length > c.dst
+		})
+		for _, j := range bySource[first:] {
+			if copies[j].src >= c.dst+c.length {
+				break
+			}
+			if j != i {
+				before[j] = append(before[j], i)
+				pending[i]++
+			}
+		}
+	}
+
+	done := make([]bool, len(copies))
+	var queue []int
+	for i := range copies {
+		if pending[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+
+	ordered := make([]blobCopy, 0, len(copies))
+	next := 0
+	for {
+		if len(queue) == 0 {
+			// all remaining copies depend on each other, drop one
+			for next < len(copies) && done[next] {
+				next++
+			}
+			if next == len(copies) {
+				return ordered
+			}
+			done[next] = true
+			for _, k := range before[next] {
+				pending[k]--
+				if pending[k] == 0 && !done[k] {
+					queue = append(queue, k)
+				}
+			}
+			continue
+		}
+
+		i := queue[0]
+		queue = queue[1:]
+		if done[i] {
+			continue
+		}
+		done[i] = true
+		ordered = append(ordered, copies[i])
+		for _, k := range before[i] {
+			pending[k]--
+			if pending[k] == 0 && !done[k] {
+				queue = append(queue, k)
+			}
+		}
+	}
+}
+
+// reuseMovedBlobs looks for the blobs of node which do not match at their
+// offset in the existing file target, but at a different offset, for example
+// after data was inserted or removed in front of them. These blobs are moved
+// to their offset within the file and marked as matching, such that they are
+// not downloaded from the repository. Errors are not fatal, the affected
+// blobs are just restored from the repository.
+func (res *Restorer) reuseMovedBlobs(target string, node *restic.Node, state *fileState, buf []byte) []byte {
+	f, err := os.OpenFile(target, os.O_RDWR|fs.O_NOFOLLOW, 0)
+	if err != nil {
+		debug.Log("cannot open %v for reuse of moved blobs: %v", target, err)
+		return buf
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	wanted := restic.NewIDSet()
+	for i, id := range node.Content {
+		if !state.HasMatchingBlob(i) {
+			wanted.Insert(id)
+		}
+	}
+
+	found, buf, err := res.findLocalBlobs(f, wanted, buf)
+	if err != nil {
+		debug.Log("cannot split %v into chunks: %v", target, err)
+		return buf
+	}
+	if len(found) == 0 {
+		return buf
+	}
+
+	var copies []blobCopy
+	var offset int64
+	for i, id := range node.Content {
+		length, ok := res.repo.LookupBlobSize(restic.DataBlob, id)
+		if !ok {
+			return buf
+		}
+		src, ok := found[id]
+		if ok && !state.HasMatchingBlob(i) {
+			copies = append(copies, blobCopy{idx: i, id: id, src: src, dst: offset, length: int64(length)})
+		}
+		offset += int64(length)
+	}
+
+	for _, c := range orderCopies(copies) {
+		if int64(cap(buf)) < c.length {
+			buf = make([]byte, c.length)
+		}
+		buf = buf[:c.length]
+
+		if _, err := f.ReadAt(buf, c.src); err != nil {
+			debug.Log("reading blob %v from %v failed: %v", c.id, target, err)
+			return buf
+		}
+		// guards against concurrent modifications of the file
+		if !c.id.Equal(restic.Hash(buf)) {
+			continue
+		}
+		if c.src != c.dst {
+			if _, err := f.WriteAt(buf, c.dst); err != nil {
+				debug.Log("moving blob %v within %v failed: %v", c.id, target, err)
+				return buf
+			}
+		}
+		state.blobMatches[c.idx] = true
+	}
+
+	return buf
+}
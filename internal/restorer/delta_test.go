@@ -0,0 +1,127 @@
+package restorer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/chunker"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestOrderCopies(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		copies  []blobCopy
+		ordered []int
+	}{
+		{
+			// data was removed in front of the blobs
+			name:    "forward",
+			copies:  []blobCopy{{idx: 0, src: 10, dst: 0, length: 10}, {idx: 1, src: 20, dst: 10, length: 10}},
+			ordered: []int{0, 1},
+		},
+		{
+			// data was inserted in front of the blobs
+			name:    "backward",
+			copies:  []blobCopy{{idx: 0, src: 0, dst: 10, length: 10}, {idx: 1, src: 10, dst: 20, length: 10}},
+			ordered: []int{1, 0},
+		},
+		{
+			name:    "swap",
+			copies:  []blobCopy{{idx: 0, src: 10, dst: 0, length: 10}, {idx: 1, src: 0, dst: 10, length: 10}},
+			ordered: []int{1},
+		},
+		{
+			name:    "shared source",
+			copies:  []blobCopy{{idx: 0, src: 30, dst: 0, length: 10}, {idx: 1, src: 30, dst: 10, length: 10}, {idx: 2, src: 10, dst: 40, length: 10}},
+			ordered: []int{0, 2, 1},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var ordered []int
+			for _, c := range orderCopies(test.copies) {
+				ordered = append(ordered, c.idx)
+			}
+			rtest.Equals(t, test.ordered, ordered)
+		})
+	}
+}
+
+// saveChunkedFile saves data split into chunks and returns the node of the
+// file.
+func saveChunkedFile(t *testing.T, repo restic.Repository, name string, data []byte) *restic.Node {
+	node := &restic.Node{Type: "file", Name: name, Mode: 0644, Size: uint64(len(data))}
+	chnk := chunker.New(bytes.NewReader(data), repo.Config().ChunkerPolynomial)
+	for {
+		chunk, err := chnk.Next(nil)
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		id, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, chunk.Data, restic.ID{}, false)
+		rtest.OK(t, err)
+		node.Content = append(node.Content, id)
+	}
+	return node
+}
+
+type countingRepo struct {
+	restic.Repository
+	loaded int
+}
+
+func (r *countingRepo) LoadBlobsFromPack(ctx context.Context, packID restic.ID, blobs []restic.Blob, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error {
+	r.loaded += len(blobs)
+	return r.Repository.LoadBlobsFromPack(ctx, packID, blobs, handleBlobFn)
+}
+
+func TestRestoreDelta(t *testing.T) {
+	repo := repository.TestRepository(t)
+	wg, wgCtx := errgroup.WithContext(context.TODO())
+	repo.StartPackUploader(wgCtx, wg)
+
+	data := make([]byte, 12*1024*1024)
+	_, _ = rand.New(rand.NewSource(42)).Read(data)
+
+	node := saveChunkedFile(t, repo, "file", data)
+	rtest.Assert(t, len(node.Content) > 4, "too few chunks: %d", len(node.Content))
+	tree := &restic.Tree{}
+	rtest.OK(t, tree.Insert(node))
+	treeID, err := restic.SaveTree(context.TODO(), repo, tree)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.TODO()))
+	sn := &restic.Snapshot{Tree: &treeID}
+
+	// data was inserted near the start and removed near the end of the file
+	junk := bytes.Repeat([]byte("junk"), 50000)
+	existing := append(append(append([]byte{}, data[:100000]...), junk...), data[100000:9*1024*1024]...)
+	existing = append(existing, data[10*1024*1024:]...)
+
+	for _, delta := range []bool{false, true} {
+		tempdir := rtest.TempDir(t)
+		rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "file"), existing, 0644))
+
+		counter := &countingRepo{Repository: repo}
+		res := NewRestorer(counter, sn, Options{Delta: delta})
+		rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+		restored, err := os.ReadFile(filepath.Join(tempdir, "file"))
+		rtest.OK(t, err)
+		rtest.Assert(t, bytes.Equal(data, restored), "delta %v: restored file differs", delta)
+
+		if delta {
+			// only the chunks at the changed parts are downloaded
+			rtest.Assert(t, counter.loaded > 0 && counter.loaded <= 4, "delta restore loaded %d of %d blobs", counter.loaded, len(node.Content))
+		} else {
+			rtest.Equals(t, len(node.Content), counter.loaded)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package restorer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Operations supported by the elevated helper.
+const (
+	elevatedOpSecurityDescriptor = "security-descriptor"
+	elevatedOpSymlink            = "symlink"
+)
+
+type elevatedRequest struct {
+	Op                 string `json:"op"`
+	Path               string `json:"path"`
+	LinkTarget         string `json:"link_target,omitempty"`
+	SecurityDescriptor []byte `json:"security_descriptor,omitempty"`
+}
+
+type elevatedResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+// ElevatedHelper sends the metadata operations which require administrative
+// privileges to a helper process running with these privileges. This allows
+// running the restore itself without administrative privileges. The helper
+// sets security descriptors, including owner and SACL, and creates symlinks.
+type ElevatedHelper struct {
+	m   sync.Mutex
+	rwc io.ReadWriteCloser
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+// NewElevatedHelper returns an ElevatedHelper which sends requests to the
+// helper process connected to rwc.
+func NewElevatedHelper(rwc io.ReadWriteCloser) *ElevatedHelper {
+	return &ElevatedHelper{
+		rwc: rwc,
+		enc: json.NewEncoder(rwc),
+		dec: json.NewDecoder(rwc),
+	}
+}
+
+func (h *ElevatedHelper) call(req elevatedRequest) error {
+	path, err := filepath.Abs(req.Path)
+	if err != nil {
+		return err
+	}
+	req.Path = path
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if err := h.enc.Encode(req); err != nil {
+		return errors.Wrap(err, "elevated helper")
+	}
+	var res elevatedResponse
+	if err := h.dec.Decode(&res); err != nil {
+		return errors.Wrap(err, "elevated helper")
+	}
+	if res.Error != "" {
+		return errors.New(res.Error)
+	}
+	return nil
+}
+
+// SetSecurityDescriptor sets the security descriptor of the file at path.
+func (h *ElevatedHelper) SetSecurityDescriptor(path string, sd []byte) error {
+	return h.call(elevatedRequest{Op: elevatedOpSecurityDescriptor, Path: path, SecurityDescriptor: sd})
+}
+
+// Symlink replaces the file at path with a symlink to linkTarget.
+func (h *ElevatedHelper) Symlink(linkTarget, path string) error {
+	return h.call(elevatedRequest{Op: elevatedOpSymlink, Path: path, LinkTarget: linkTarget})
+}
+
+// Close stops the helper process.
+func (h *ElevatedHelper) Close() error {
+	return h.rwc.Close()
+}
+
+// ServeElevatedHelper runs the helper side of an ElevatedHelper and applies the
+// requests received from rw until it is closed. Only files below root are
+// modified.
+func ServeElevatedHelper(rw io.ReadWriter, root string) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(rw)
+	dec := json.NewDecoder(rw)
+	for {
+		var req elevatedRequest
+		err := dec.Decode(&req)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "Decode")
+		}
+
+		var res elevatedResponse
+		if err := applyElevatedRequest(req, root); err != nil {
+			res.Error = err.Error()
+		}
+		if err := enc.Encode(res); err != nil {
+			return errors.Wrap(err, "Encode")
+		}
+	}
+}
+
+func applyElevatedRequest(req elevatedRequest, root string) error {
+	rel, err := filepath.Rel(root, req.Path)
+	if err != nil || !filepath.IsAbs(req.Path) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%v is not below the restore target %v", req.Path, root)
+	}
+
+	switch req.Op {
+	case elevatedOpSecurityDescriptor:
+		return fs.SetSecurityDescriptor(req.Path, &req.SecurityDescriptor)
+	case elevatedOpSymlink:
+		if err := os.Remove(req.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return fs.Symlink(req.LinkTarget, req.Path)
+	}
+	return fmt.Errorf("unknown operation %q", req.Op)
+}
+
+// splitSecurityDescriptor returns a copy of node without its security
+// descriptor together with the security descriptor. If node has no security
+// descriptor, node itself and nil are returned.
+func splitSecurityDescriptor(node *restic.Node) (*restic.Node, []byte, error) {
+	raw, ok := node.GenericAttributes[restic.TypeSecurityDescriptor]
+	if !ok {
+		return node, nil, nil
+	}
+	var sd []byte
+	if err := json.Unmarshal(raw, &sd); err != nil {
+		return nil, nil, fmt.Errorf("error parsing security descriptor for: %s : %v", node.Name, err)
+	}
+
+	n := *node
+	n.GenericAttributes = make(map[restic.GenericAttributeType]json.RawMessage, len(node.GenericAttributes))
+	for k, v := range node.GenericAttributes {
+		if k != restic.TypeSecurityDescriptor {
+			n.GenericAttributes[k] = v
+		}
+	}
+	return &n, sd, nil
+}
@@ -0,0 +1,77 @@
+package restorer
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func startTestElevatedHelper(t *testing.T, root string) *ElevatedHelper {
+	client, server := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeElevatedHelper(server, root)
+		_ = server.Close()
+	}()
+
+	h := NewElevatedHelper(client)
+	t.Cleanup(func() {
+		rtest.OK(t, h.Close())
+		rtest.OK(t, <-done)
+	})
+	return h
+}
+
+func TestElevatedHelperSymlink(t *testing.T) {
+	root := t.TempDir()
+	h := startTestElevatedHelper(t, root)
+
+	target := filepath.Join(root, "link")
+	rtest.OK(t, os.WriteFile(target, []byte("replaced"), 0600))
+	rtest.OK(t, h.Symlink("file", target))
+
+	linkTarget, err := os.Readlink(target)
+	rtest.OK(t, err)
+	rtest.Equals(t, "file", linkTarget)
+
+	// the helper must not modify files outside of the restore target
+	err = h.Symlink("file", filepath.Join(filepath.Dir(root), "outside"))
+	rtest.Assert(t, err != nil, "expected error for path outside of the restore target")
+	err = h.Symlink("file", filepath.Join(root, "..", filepath.Base(root)+"-sibling"))
+	rtest.Assert(t, err != nil, "expected error for path outside of the restore target")
+}
+
+func TestSplitSecurityDescriptor(t *testing.T) {
+	sd := []byte{1, 2, 3}
+	raw, err := json.Marshal(sd)
+	rtest.OK(t, err)
+
+	node := &restic.Node{
+		Name: "file",
+		GenericAttributes: map[restic.GenericAttributeType]json.RawMessage{
+			restic.TypeSecurityDescriptor: raw,
+			restic.TypeCreationTime:       json.RawMessage("{}"),
+		},
+	}
+
+	n, extracted, err := splitSecurityDescriptor(node)
+	rtest.OK(t, err)
+	rtest.Equals(t, sd, extracted)
+	_, ok := n.GenericAttributes[restic.TypeSecurityDescriptor]
+	rtest.Assert(t, !ok, "security descriptor was not removed")
+	_, ok = n.GenericAttributes[restic.TypeCreationTime]
+	rtest.Assert(t, ok, "other generic attributes were removed")
+	// the original node must not be modified
+	_, ok = node.GenericAttributes[restic.TypeSecurityDescriptor]
+	rtest.Assert(t, ok, "security descriptor was removed from the original node")
+
+	n, extracted, err = splitSecurityDescriptor(&restic.Node{Name: "plain"})
+	rtest.OK(t, err)
+	rtest.Assert(t, extracted == nil, "unexpected security descriptor %v", extracted)
+	rtest.Equals(t, "plain", n.Name)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -24,9 +26,12 @@ type fileInfo struct {
 	inProgress bool
 	sparse     bool
 	size       int64
+	partial    bool        // only a byte range of the file is restored
+	skip       int64       // bytes to skip at the start of the first blob of a partial file
 	location   string      // file on local filesystem relative to restorer basedir
 	blobs      interface{} // blobs of the file
 	state      *fileState
+	remaining  int64 // number of blob writes until the file is complete, accessed atomically
 }
 
 type fileBlobInfo struct {
@@ -52,6 +57,10 @@ type fileRestorer struct {
 	zeroChunk   restic.ID
 	sparse      bool
 	progress    *restore.Progress
+	// tuner limits the concurrent pack downloads, if set
+	tuner *autoTuner
+	// journal records completely restored files, if set
+	journal *Journal
 
 	dst   string
 	files []*fileInfo
@@ -85,6 +94,36 @@ func (r *fileRestorer) addFile(location string, content restic.IDs, size int64,
 	r.files = append(r.files, &fileInfo{location: location, blobs: content, size: size, state: state})
 }
 
+// addFileRange adds a file of which only size bytes are restored, starting at
+// byte skip of the first blob in content.
+func (r *fileRestorer) addFileRange(location string, content restic.IDs, size int64, skip int64) {
+	r.files = append(r.files, &fileInfo{location: location, blobs: content, size: size, partial: true, skip: skip})
+}
+
+// clip returns the part of the blob data at offset within the file content
+// which must be written and the corresponding offset in the restored file.
+func (f *fileInfo) clip(data []byte, offset int64) ([]byte, int64) {
+	if !f.partial {
+		return data, offset
+	}
+
+	offset -= f.skip
+	if offset < 0 {
+		if -offset >= int64(len(data)) {
+			return nil, 0
+		}
+		data = data[-offset:]
+		offset = 0
+	}
+	if offset+int64(len(data)) > f.size {
+		if offset >= f.size {
+			return nil, 0
+		}
+		data = data[:f.size-offset]
+	}
+	return data, offset
+}
+
 func (r *fileRestorer) targetPath(location string) string {
 	return filepath.Join(r.dst, location)
 }
@@ -118,6 +157,9 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 		fileBlobs := file.blobs.(restic.IDs)
 		if len(fileBlobs) == 0 {
 			err := r.restoreEmptyFileAt(file.location)
+			if err == nil && !file.partial {
+				err = r.journal.Add(file.location)
+			}
 			if errFile := r.sanitizeError(file, err); errFile != nil {
 				return errFile
 			}
@@ -130,10 +172,17 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 		}
 		fileOffset := int64(0)
 		err := r.forEachBlob(fileBlobs, func(packID restic.ID, blob restic.Blob, idx int) {
-			if largeFile && !file.state.HasMatchingBlob(idx) {
-				packsMap[packID] = append(packsMap[packID], fileBlobInfo{id: blob.ID, offset: fileOffset})
+			if file.state.HasMatchingBlob(idx) {
+				// the blob is not written, but counts towards the progress
+				r.progress.AddProgress(file.location, uint64(blob.DataLength()), uint64(file.size))
 				fileOffset += int64(blob.DataLength())
+				return
 			}
+			file.remaining++
+			if largeFile {
+				packsMap[packID] = append(packsMap[packID], fileBlobInfo{id: blob.ID, offset: fileOffset})
+			}
+			fileOffset += int64(blob.DataLength())
 			pack, ok := packs[packID]
 			if !ok {
 				pack = &packInfo{
@@ -174,16 +223,28 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 	wg, ctx := errgroup.WithContext(ctx)
 	downloadCh := make(chan *packInfo)
 
-	worker := func() error {
+	worker := func(id int) error {
 		for pack := range downloadCh {
-			if err := r.downloadPack(ctx, pack); err != nil {
+			if r.tuner != nil {
+				if err := r.tuner.acquire(ctx); err != nil {
+					return err
+				}
+			}
+			r.progress.StartPack(id, pack.id.Str())
+			err := r.downloadPack(ctx, id, pack)
+			r.progress.FinishPack(id, err)
+			if r.tuner != nil {
+				r.tuner.release()
+			}
+			if err != nil {
 				return err
 			}
 		}
 		return nil
 	}
 	for i := 0; i < r.workerCount; i++ {
-		wg.Go(worker)
+		id := i + 1
+		wg.Go(func() error { return worker(id) })
 	}
 
 	// the main restore loop
@@ -224,7 +285,7 @@ type blobToFileOffsetsMapping map[restic.ID]struct {
 	blob  restic.Blob
 }
 
-func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
+func (r *fileRestorer) downloadPack(ctx context.Context, worker int, pack *packInfo) error {
 	// calculate blob->[]files->[]offsets mappings
 	blobs := make(blobToFileOffsetsMapping)
 	for file := range pack.files {
@@ -264,7 +325,10 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 
 	// track already processed blobs for precise error reporting
 	processedBlobs := restic.NewBlobSet()
-	err := r.downloadBlobs(ctx, pack.id, blobs, processedBlobs)
+	err := r.downloadBlobs(ctx, worker, pack.id, blobs, processedBlobs)
+	if err != nil {
+		r.progress.WorkerError(worker, err)
+	}
 	return r.reportError(blobs, processedBlobs, err)
 }
 
@@ -299,18 +363,27 @@ func (r *fileRestorer) reportError(blobs blobToFileOffsetsMapping, processedBlob
 	return nil
 }
 
-func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
+func (r *fileRestorer) downloadBlobs(ctx context.Context, worker int, packID restic.ID,
 	blobs blobToFileOffsetsMapping, processedBlobs restic.BlobSet) error {
 
 	blobList := make([]restic.Blob, 0, len(blobs))
 	for _, entry := range blobs {
 		blobList = append(blobList, entry.blob)
 	}
-	return r.blobsLoader(ctx, packID, blobList,
+
+	start := time.Now()
+	var latency time.Duration
+	var bytes uint64
+	err := r.blobsLoader(ctx, packID, blobList,
 		func(h restic.BlobHandle, blobData []byte, err error) error {
+			if latency == 0 {
+				latency = time.Since(start)
+			}
+			bytes += uint64(len(blobData))
 			processedBlobs.Insert(h)
 			blob := blobs[h.ID]
 			if err != nil {
+				r.progress.WorkerError(worker, err)
 				for file := range blob.files {
 					if errFile := r.sanitizeError(file, err); errFile != nil {
 						return errFile
@@ -320,6 +393,7 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 			}
 			for file, offsets := range blob.files {
 				for _, offset := range offsets {
+					blobData, offset := file.clip(blobData, offset)
 					writeToFile := func() error {
 						// this looks overly complicated and needs explanation
 						// two competing requirements:
@@ -338,6 +412,10 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 						}
 						writeErr := r.filesWriter.writeToFile(r.targetPath(file.location), blobData, offset, createSize, file.sparse)
 						r.progress.AddProgress(file.location, uint64(len(blobData)), uint64(file.size))
+						r.progress.AddWorkerProgress(worker, file.location, uint64(len(blobData)))
+						if writeErr == nil && !file.partial && atomic.AddInt64(&file.remaining, -1) == 0 {
+							writeErr = r.journal.Add(file.location)
+						}
 						return writeErr
 					}
 					err := r.sanitizeError(file, writeToFile())
@@ -348,4 +426,8 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 			}
 			return nil
 		})
+	if r.tuner != nil && err == nil {
+		r.tuner.report(latency, time.Since(start), bytes)
+	}
+	return err
 }
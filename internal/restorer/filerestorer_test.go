@@ -342,3 +342,21 @@ func TestFatalDownloadError(t *testing.T) {
 	rtest.Assert(t, len(errors) == 1, "unexpected number of restore errors, expected: 1, got: %v", len(errors))
 	rtest.Assert(t, errors[0] == "file2", "expected error for file2, got: %v", errors[0])
 }
+
+func TestFileInfoClip(t *testing.T) {
+	file := &fileInfo{partial: true, skip: 3, size: 6}
+	for _, test := range []struct {
+		offset     int64
+		data       string
+		expected   string
+		fileOffset int64
+	}{
+		{0, "abcd", "d", 0},
+		{4, "efg", "efg", 1},
+		{7, "hijk", "hi", 4},
+	} {
+		data, offset := file.clip([]byte(test.data), test.offset)
+		rtest.Equals(t, test.expected, string(data))
+		rtest.Equals(t, test.fileOffset, offset)
+	}
+}
@@ -0,0 +1,155 @@
+package restorer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// journalHeader is the first line of a restore journal, followed by the ID of
+// the restored tree.
+const journalHeader = "restic restore journal v1"
+
+// Journal records the files which have been restored completely, such that an
+// interrupted restore of the same tree to the same target can skip them. The
+// journal is stored in the temporary directory, one file per target.
+type Journal struct {
+	path string
+	done map[string]struct{}
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// journalPath returns the path of the journal for the target directory dst.
+func journalPath(dst string) (string, error) {
+	dst, err := filepath.Abs(dst)
+	if err != nil {
+		return "", errors.Wrap(err, "Abs")
+	}
+	sum := sha256.Sum256([]byte(dst))
+	return filepath.Join(os.TempDir(), "restic-restore-"+hex.EncodeToString(sum[:8])+".journal"), nil
+}
+
+// OpenJournal opens the journal for restoring tree to dst. If resume is set,
+// the files recorded by an earlier restore of the same tree are loaded,
+// otherwise an existing journal is discarded. Resuming with a journal which was
+// created for a different tree returns an error.
+func OpenJournal(dst string, tree restic.ID, resume bool) (*Journal, error) {
+	path, err := journalPath(dst)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{path: path, done: make(map[string]struct{})}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		found, err := j.load(tree)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			flags = os.O_WRONLY | os.O_APPEND
+		}
+		debug.Log("loaded %d restored files from journal %v", len(j.done), path)
+	}
+
+	j.f, err = os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if flags&os.O_TRUNC != 0 {
+		if _, err := fmt.Fprintf(j.f, "%s %s\n", journalHeader, tree); err != nil {
+			_ = j.f.Close()
+			return nil, errors.WithStack(err)
+		}
+	}
+	return j, nil
+}
+
+// load reads the files restored previously from the journal. It returns false
+// if there is no journal.
+func (j *Journal) load(tree restic.ID) (bool, error) {
+	f, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	if !sc.Scan() {
+		// an empty journal was never used
+		return false, sc.Err()
+	}
+	if header := sc.Text(); header != journalHeader+" "+tree.String() {
+		id := strings.TrimPrefix(header, journalHeader+" ")
+		if id == header {
+			return false, errors.Errorf("invalid restore journal %v", j.path)
+		}
+		return false, errors.Errorf("the interrupted restore to this target was for a different snapshot or subfolder (tree %.8s)", id)
+	}
+
+	for sc.Scan() {
+		location, err := strconv.Unquote(sc.Text())
+		if err != nil {
+			// the last line may be incomplete if restic was interrupted
+			debug.Log("ignoring invalid journal line %q: %v", sc.Text(), err)
+			continue
+		}
+		j.done[location] = struct{}{}
+	}
+	return true, sc.Err()
+}
+
+// Restored returns whether the file at location was completely restored by an
+// earlier, interrupted restore.
+func (j *Journal) Restored(location string) bool {
+	if j == nil {
+		return false
+	}
+	_, ok := j.done[location]
+	return ok
+}
+
+// Add records that the file at location has been restored completely.
+func (j *Journal) Add(location string) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err := j.f.WriteString(strconv.Quote(location) + "\n")
+	return errors.WithStack(err)
+}
+
+// Close closes the journal, which can be used to resume the restore later.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+// Remove closes and removes the journal once the restore has completed.
+func (j *Journal) Remove() error {
+	if err := j.Close(); err != nil {
+		return err
+	}
+	return os.Remove(j.path)
+}
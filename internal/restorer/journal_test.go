@@ -0,0 +1,78 @@
+package restorer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestRestoreResume(t *testing.T) {
+	// keep the journals of this test separate
+	t.Setenv("TMPDIR", rtest.TempDir(t))
+	t.Setenv("TMP", os.Getenv("TMPDIR"))
+
+	origData := "content: foo\n"
+	modData := "content: bar\n"
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: origData, ModTime: time.Now()},
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"bar": File{Data: "content: bar\n", ModTime: time.Now()},
+				},
+			},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	tempdir := filepath.Join(rtest.TempDir(t), "target")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+
+	journal, err := OpenJournal(tempdir, *sn.Tree, false)
+	rtest.OK(t, err)
+	res := NewRestorer(repo, sn, Options{Journal: journal})
+	rtest.OK(t, res.RestoreTo(ctx, tempdir))
+	rtest.OK(t, journal.Close())
+
+	// simulate an interrupted restore: foo was restored, bar is missing
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "foo"), []byte(modData), 0600))
+	rtest.OK(t, os.Remove(filepath.Join(tempdir, "dir", "bar")))
+
+	journal, err = OpenJournal(tempdir, *sn.Tree, true)
+	rtest.OK(t, err)
+	rtest.Assert(t, journal.Restored(string(filepath.Separator)+"foo"), "foo not recorded in journal")
+	res = NewRestorer(repo, sn, Options{Journal: journal})
+	rtest.OK(t, res.RestoreTo(ctx, tempdir))
+
+	data, err := os.ReadFile(filepath.Join(tempdir, "foo"))
+	rtest.OK(t, err)
+	rtest.Equals(t, modData, string(data), "foo should not have been restored again")
+	data, err = os.ReadFile(filepath.Join(tempdir, "dir", "bar"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "content: bar\n", string(data))
+	rtest.OK(t, journal.Remove())
+
+	// without a journal, resuming starts from scratch
+	journal, err = OpenJournal(tempdir, *sn.Tree, true)
+	rtest.OK(t, err)
+	rtest.Assert(t, !journal.Restored(string(filepath.Separator)+"foo"), "unexpected journal entry")
+	rtest.OK(t, journal.Close())
+
+	// resuming the restore of a different tree fails
+	_, err = OpenJournal(tempdir, restic.NewRandomID(), true)
+	rtest.Assert(t, err != nil, "resuming with a journal for a different tree did not fail")
+
+	// starting a new restore discards the journal
+	journal, err = OpenJournal(tempdir, restic.NewRandomID(), false)
+	rtest.OK(t, err)
+	rtest.OK(t, journal.Remove())
+}
@@ -0,0 +1,90 @@
+package restorer
+
+import (
+	"path/filepath"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// LinkReport counts the absolute symlink and junction targets handled while
+// restoring with Options.RewriteLinks.
+type LinkReport struct {
+	// Rewritten is the number of links whose target was changed to point
+	// into the restored tree.
+	Rewritten uint64
+	// Unchanged is the number of links which were restored as-is, as their
+	// target is outside of the restored paths.
+	Unchanged uint64
+}
+
+// LinkReport returns the number of rewritten and unchanged link targets.
+func (res *Restorer) LinkReport() LinkReport {
+	return res.links
+}
+
+// snapshotLocation returns the location within the snapshot of the absolute
+// path p. Like the archiver, it replaces the drive letter of Windows paths by
+// a directory without the colon. ok is false for other volume names, as they
+// cannot be mapped to a location.
+func snapshotLocation(p string) (location string, ok bool) {
+	volume := filepath.VolumeName(p)
+	switch {
+	case volume == "":
+	case len(volume) == 2 && volume[1] == ':':
+		p = filepath.Join(volume[:1], p[len(volume):])
+	default:
+		return "", false
+	}
+	return filepath.Join(string(filepath.Separator), p), true
+}
+
+// rewriteLink returns node with its link target relocated into dst if the
+// target is an absolute path within the backed-up paths of the snapshot and
+// within the restored subfolder. Other nodes are returned unchanged.
+func (res *Restorer) rewriteLink(node *restic.Node, dst, location string) *restic.Node {
+	if node.Type != "symlink" || !filepath.IsAbs(node.LinkTarget) {
+		return node
+	}
+
+	inside := false
+	for _, p := range res.sn.Paths {
+		if fs.HasPathPrefix(p, node.LinkTarget) {
+			inside = true
+			break
+		}
+	}
+	targetLocation, ok := snapshotLocation(node.LinkTarget)
+	if ok && res.opts.Subfolder != "" {
+		// only the subfolder is restored to dst
+		subfolder := filepath.Join(string(filepath.Separator), filepath.FromSlash(res.opts.Subfolder))
+		ok = fs.HasPathPrefix(subfolder, targetLocation)
+		if ok {
+			targetLocation, _ = filepath.Rel(subfolder, targetLocation)
+		}
+	}
+	if !inside || !ok {
+		debug.Log("link %v points outside of the restored paths to %v", location, node.LinkTarget)
+		res.links.Unchanged++
+		if res.ReportLink != nil {
+			res.ReportLink(location, node.LinkTarget, node.LinkTarget)
+		}
+		return node
+	}
+
+	target := filepath.Join(dst, targetLocation)
+	if target == filepath.Clean(node.LinkTarget) {
+		// restored to the original location
+		return node
+	}
+
+	debug.Log("rewriting target of link %v from %v to %v", location, node.LinkTarget, target)
+	res.links.Rewritten++
+	if res.ReportLink != nil {
+		res.ReportLink(location, node.LinkTarget, target)
+	}
+	n := *node
+	n.LinkTarget = target
+	return &n
+}
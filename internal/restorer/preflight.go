@@ -0,0 +1,205 @@
+package restorer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"unicode/utf16"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+)
+
+// PreflightIssue is a problem found by Preflight. Blocking issues make the
+// restore fail, while the others only lose some metadata.
+type PreflightIssue struct {
+	Blocking bool   `json:"blocking"`
+	Message  string `json:"message"`
+}
+
+// PreflightResult describes the selected files of a snapshot and whether the
+// restore target is able to store them.
+type PreflightResult struct {
+	// RestoreSize is the size of all selected files. Files which already
+	// exist in the target are not taken into account.
+	RestoreSize uint64 `json:"restore_size"`
+	Files       uint64 `json:"files"`
+	Dirs        uint64 `json:"dirs"`
+	Symlinks    uint64 `json:"symlinks"`
+	Streams     uint64 `json:"streams"`
+	// ExtendedAttributes is the number of files and directories with
+	// extended attributes.
+	ExtendedAttributes uint64 `json:"extended_attributes"`
+	LongestName        string `json:"longest_name"`
+	LongestPath        string `json:"longest_path"`
+
+	// Target contains the capabilities of the file system of the target,
+	// which are compared with the selected files.
+	Target *fs.Capabilities `json:"target"`
+	Issues []PreflightIssue `json:"issues"`
+}
+
+// Blocking returns true if any of the issues would make the restore fail.
+func (r *PreflightResult) Blocking() bool {
+	for _, issue := range r.Issues {
+		if issue.Blocking {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PreflightResult) addIssue(blocking bool, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, PreflightIssue{Blocking: blocking, Message: fmt.Sprintf(format, args...)})
+}
+
+// pathLength returns the length of path as counted by the file system.
+func pathLength(path string) int {
+	if runtime.GOOS == "windows" {
+		return len(utf16.Encode([]rune(path)))
+	}
+	return len(path)
+}
+
+// Preflight checks whether the files selected for restore fit on the file
+// system containing dst and whether it supports all of their features,
+// without writing anything except for a temporary file used to probe the
+// file system.
+func (res *Restorer) Preflight(ctx context.Context, dst string) (*PreflightResult, error) {
+	var err error
+	if !filepath.IsAbs(dst) {
+		dst, err = filepath.Abs(dst)
+		if err != nil {
+			return nil, errors.Wrap(err, "Abs")
+		}
+	}
+
+	result := &PreflightResult{}
+	var longestName, longestPath int
+
+	// errors are reported by the restore itself
+	errorFn := res.Error
+	res.Error = restorerAbortOnAllErrors
+	defer func() {
+		res.Error = errorFn
+	}()
+
+	_, err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+		enterDir: func(node *restic.Node, target, _ string) error {
+			result.Dirs++
+			if len(node.ExtendedAttributes) > 0 {
+				result.ExtendedAttributes++
+			}
+			if l := pathLength(target); l > longestPath {
+				longestPath, result.LongestPath = l, target
+			}
+			if l := pathLength(node.Name); l > longestName {
+				longestName, result.LongestName = l, node.Name
+			}
+			return nil
+		},
+		visitNode: func(node *restic.Node, target, _ string) error {
+			if restic.ClassifyNode(node.Name) == restic.StreamNode {
+				result.Streams++
+				result.RestoreSize += res.restoreSize(node)
+				return nil
+			}
+
+			switch node.Type {
+			case "file":
+				result.Files++
+				result.RestoreSize += res.restoreSize(node)
+			case "symlink":
+				// junctions can be created without privileges
+				if !node.MountPoint() {
+					result.Symlinks++
+				}
+			}
+			if len(node.ExtendedAttributes) > 0 {
+				result.ExtendedAttributes++
+			}
+			if l := pathLength(target); l > longestPath {
+				longestPath, result.LongestPath = l, target
+			}
+			if l := pathLength(node.Name); l > longestName {
+				longestName, result.LongestName = l, node.Name
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Target, err = fs.ProbeCapabilities(existingParent(dst))
+	if err != nil {
+		return nil, fmt.Errorf("unable to probe the target file system: %w", err)
+	}
+	res.checkPreflight(result, longestName, longestPath)
+	return result, nil
+}
+
+// restoreSize returns the number of bytes written when restoring the file
+// node.
+func (res *Restorer) restoreSize(node *restic.Node) uint64 {
+	r := res.opts.ByteRange
+	if r == nil {
+		return node.Size
+	}
+	if r.Offset >= node.Size {
+		return 0
+	}
+	size := node.Size - r.Offset
+	if r.Length != 0 && r.Length < size {
+		size = r.Length
+	}
+	return size
+}
+
+// checkPreflight adds the issues found by comparing the selected files with
+// the capabilities of the target.
+func (res *Restorer) checkPreflight(r *PreflightResult, longestName, longestPath int) {
+	c := r.Target
+
+	if c.FreeSpace != nil && r.RestoreSize > *c.FreeSpace {
+		r.addIssue(true, "the selected files need %v, but only %v are available on the target",
+			ui.FormatBytes(r.RestoreSize), ui.FormatBytes(*c.FreeSpace))
+	}
+	if c.MaxNameLength > 0 && longestName > c.MaxNameLength {
+		r.addIssue(true, "the name %q is longer than the %d characters supported by the target", r.LongestName, c.MaxNameLength)
+	}
+	if c.MaxPathLength > 0 && longestPath > c.MaxPathLength {
+		r.addIssue(true, "the path %q is longer than the %d characters supported by the target", r.LongestPath, c.MaxPathLength)
+	}
+	// the elevated helper is able to create symlinks
+	if r.Symlinks > 0 && c.Symlinks != nil && !*c.Symlinks && res.opts.Elevated == nil {
+		r.addIssue(true, "%d symlinks cannot be created on the target", r.Symlinks)
+	}
+	if r.Streams > 0 && c.AlternateDataStreams != nil && !*c.AlternateDataStreams {
+		r.addIssue(true, "%d alternate data streams cannot be created on the target", r.Streams)
+	}
+	if r.ExtendedAttributes > 0 && c.ExtendedAttributes != nil && !*c.ExtendedAttributes {
+		r.addIssue(false, "the extended attributes of %d files and directories are not supported by the target", r.ExtendedAttributes)
+	}
+	if res.opts.Sparse && c.SparseFiles != nil && !*c.SparseFiles {
+		r.addIssue(false, "the target does not support sparse files, --sparse has no effect")
+	}
+}
+
+// existingParent returns dir or its closest parent directory which exists.
+func existingParent(dir string) string {
+	for {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
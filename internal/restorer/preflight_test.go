@@ -0,0 +1,87 @@
+package restorer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestPreflight(t *testing.T) {
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: "0123456789", ModTime: time.Now()},
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"bar": File{Data: "abc", ModTime: time.Now()},
+				},
+			},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	tempdir := filepath.Join(rtest.TempDir(t), "target")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+
+	res := NewRestorer(repo, sn, Options{})
+	result, err := res.Preflight(ctx, tempdir)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(13), result.RestoreSize)
+	rtest.Equals(t, uint64(2), result.Files)
+	rtest.Equals(t, uint64(1), result.Dirs)
+	rtest.Equals(t, filepath.Join(tempdir, "dir", "bar"), result.LongestPath)
+	rtest.Assert(t, result.Target != nil, "missing target capabilities")
+	rtest.Assert(t, !result.Blocking(), "unexpected blocking issues %v", result.Issues)
+
+	_, err = os.Stat(tempdir)
+	rtest.Assert(t, os.IsNotExist(err), "preflight created the target directory")
+
+	res = NewRestorer(repo, sn, Options{ByteRange: &restic.ByteRange{Offset: 3, Length: 4}})
+	result, err = res.Preflight(ctx, tempdir)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(4), result.RestoreSize)
+}
+
+func TestPreflightIssues(t *testing.T) {
+	free := uint64(100)
+	res := &Restorer{opts: Options{Sparse: true}}
+	result := &PreflightResult{
+		RestoreSize:        1000,
+		Symlinks:           1,
+		ExtendedAttributes: 1,
+		LongestName:        strings.Repeat("a", 20),
+		Target: &fs.Capabilities{
+			FreeSpace:          &free,
+			ExtendedAttributes: boolPtr(false),
+			Symlinks:           boolPtr(true),
+			SparseFiles:        boolPtr(false),
+			MaxNameLength:      10,
+		},
+	}
+	res.checkPreflight(result, 20, 30)
+
+	rtest.Assert(t, result.Blocking(), "missing blocking issue")
+	var blocking int
+	for _, issue := range result.Issues {
+		if issue.Blocking {
+			blocking++
+		}
+	}
+	// free space and name length
+	rtest.Equals(t, 2, blocking)
+	rtest.Equals(t, 4, len(result.Issues))
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
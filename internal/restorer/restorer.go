@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sync/atomic"
 
 	"github.com/restic/restic/internal/debug"
@@ -24,18 +25,61 @@ type Restorer struct {
 	opts Options
 
 	fileList map[string]bool
+	links    LinkReport
 
 	Error        func(location string, err error) error
 	Warn         func(message string)
 	SelectFilter func(item string, dstpath string, node *restic.Node) (selectedForRestore bool, childMayBeSelected bool)
+	// ReportLink is called for each link handled by Options.RewriteLinks,
+	// newTarget equals oldTarget if the link is left as-is.
+	ReportLink func(location, oldTarget, newTarget string)
 }
 
 var restorerAbortOnAllErrors = func(_ string, err error) error { return err }
 
 type Options struct {
-	Sparse    bool
+	Sparse bool
+	// AutoTune adjusts the number of concurrent pack downloads, up to the
+	// number of backend connections, to the measured backend performance.
+	AutoTune  bool
 	Progress  *restoreui.Progress
 	Overwrite OverwriteBehavior
+	// ByteRange restricts the restore to the selected part of each file.
+	ByteRange *restic.ByteRange
+	// VerifySecurityDescriptors re-reads the security descriptors after
+	// restoring them and reports all differences as errors.
+	VerifySecurityDescriptors bool
+	// ACLInheritance controls whether restored DACLs inherit permissions from
+	// the parent directory of the target.
+	ACLInheritance ACLInheritance
+	// TranslatePermissions synthesizes permissions for nodes which were
+	// backed up on a different operating system.
+	TranslatePermissions bool
+	// SkipSELinux and SkipCapabilities skip restoring the SELinux contexts
+	// and the file capabilities on Linux.
+	SkipSELinux      bool
+	SkipCapabilities bool
+	// Elevated applies security descriptors and creates symlinks in a helper
+	// process with administrative privileges, if set.
+	Elevated *ElevatedHelper
+	// Delta reuses blobs of existing files which were moved to a different
+	// offset instead of downloading them again.
+	Delta bool
+	// Streams selects the alternate data streams which are restored, if set.
+	Streams *StreamFilter
+	// StreamMode selects whether only the main contents of files, only their
+	// alternate data streams or both are restored.
+	StreamMode StreamMode
+	// Journal records the restored files. Files which it lists as restored by
+	// an earlier run are not restored again, if set.
+	Journal *Journal
+	// RewriteLinks relocates absolute symlink and junction targets which
+	// point into the backed-up paths such that they point into the restored
+	// tree instead.
+	RewriteLinks bool
+	// Subfolder is the location within the snapshot of the restored tree,
+	// if only a subfolder of the snapshot is restored.
+	Subfolder string
 }
 
 type OverwriteBehavior int
@@ -48,6 +92,8 @@ const (
 	OverwriteIfChanged
 	OverwriteIfNewer
 	OverwriteNever
+	// OverwriteFail reports existing items as errors and does not modify them.
+	OverwriteFail
 	OverwriteInvalid
 )
 
@@ -62,9 +108,11 @@ func (c *OverwriteBehavior) Set(s string) error {
 		*c = OverwriteIfNewer
 	case "never":
 		*c = OverwriteNever
+	case "fail":
+		*c = OverwriteFail
 	default:
 		*c = OverwriteInvalid
-		return fmt.Errorf("invalid overwrite behavior %q, must be one of (always|if-newer|never)", s)
+		return fmt.Errorf("invalid overwrite behavior %q, must be one of (always|if-changed|if-newer|never|fail)", s)
 	}
 
 	return nil
@@ -80,6 +128,8 @@ func (c *OverwriteBehavior) String() string {
 		return "if-newer"
 	case OverwriteNever:
 		return "never"
+	case OverwriteFail:
+		return "fail"
 	default:
 		return "invalid"
 	}
@@ -89,6 +139,55 @@ func (c *OverwriteBehavior) Type() string {
 	return "behavior"
 }
 
+// ACLInheritance controls the inheritance of restored DACLs on Windows.
+type ACLInheritance int
+
+// Constants for the different ACL inheritance modes
+const (
+	// ACLInheritanceKeep restores the inheritance setting stored in the snapshot.
+	ACLInheritanceKeep ACLInheritance = iota
+	// ACLInheritanceBlock prevents restored DACLs from inheriting permissions.
+	ACLInheritanceBlock
+	// ACLInheritanceMerge lets restored DACLs inherit the permissions of the
+	// new parent directory in addition to their explicit entries.
+	ACLInheritanceMerge
+	ACLInheritanceInvalid
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (c *ACLInheritance) Set(s string) error {
+	switch s {
+	case "keep":
+		*c = ACLInheritanceKeep
+	case "block":
+		*c = ACLInheritanceBlock
+	case "merge":
+		*c = ACLInheritanceMerge
+	default:
+		*c = ACLInheritanceInvalid
+		return fmt.Errorf("invalid ACL inheritance mode %q, must be one of (keep|block|merge)", s)
+	}
+
+	return nil
+}
+
+func (c *ACLInheritance) String() string {
+	switch *c {
+	case ACLInheritanceKeep:
+		return "keep"
+	case ACLInheritanceBlock:
+		return "block"
+	case ACLInheritanceMerge:
+		return "merge"
+	default:
+		return "invalid"
+	}
+}
+
+func (c *ACLInheritance) Type() string {
+	return "mode"
+}
+
 // NewRestorer creates a restorer preloaded with the content from the snapshot id.
 func NewRestorer(repo restic.Repository, sn *restic.Snapshot, opts Options) *Restorer {
 	r := &Restorer{
@@ -151,6 +250,11 @@ func (res *Restorer) traverseTree(ctx context.Context, target, location string,
 			continue
 		}
 
+		if !res.selectStreams(node) {
+			debug.Log("%q excluded by stream mode or filter", nodeLocation)
+			continue
+		}
+
 		selectedForRestore, childMayBeSelected := res.SelectFilter(nodeLocation, nodeTarget, node)
 		debug.Log("SelectFilter returned %v %v for %q", selectedForRestore, childMayBeSelected, nodeLocation)
 
@@ -222,7 +326,13 @@ func (res *Restorer) traverseTree(ctx context.Context, target, location string,
 func (res *Restorer) restoreNodeTo(ctx context.Context, node *restic.Node, target, location string) error {
 	debug.Log("restoreNode %v %v %v", node.Name, target, location)
 
-	err := node.CreateAt(ctx, target, res.repo)
+	var err error
+	// junctions do not require administrative privileges
+	if node.Type == "symlink" && !node.MountPoint() && res.opts.Elevated != nil {
+		err = res.opts.Elevated.Symlink(node.LinkTarget, target)
+	} else {
+		err = node.CreateAt(ctx, target, res.repo, res.Warn)
+	}
 	if err != nil {
 		debug.Log("node.CreateAt(%s) error %v", target, err)
 		return err
@@ -234,9 +344,46 @@ func (res *Restorer) restoreNodeTo(ctx context.Context, node *restic.Node, targe
 
 func (res *Restorer) restoreNodeMetadataTo(node *restic.Node, target, location string) error {
 	debug.Log("restoreNodeMetadata %v %v %v", node.Name, target, location)
-	err := node.RestoreMetadata(target, res.Warn)
+	if res.opts.TranslatePermissions {
+		n := *node
+		if err := n.TranslatePermissions(); err != nil {
+			return err
+		}
+		node = &n
+	}
+	node = node.WithoutSecurityAttributes(res.opts.SkipSELinux, res.opts.SkipCapabilities)
+	if res.opts.ACLInheritance != ACLInheritanceKeep {
+		n := *node
+		if err := n.SetDACLProtected(res.opts.ACLInheritance == ACLInheritanceBlock); err != nil {
+			return err
+		}
+		node = &n
+	}
+
+	// the security descriptor is set by the elevated helper after all other
+	// metadata, as it may revoke the permissions of this process
+	metadata, sd := node, []byte(nil)
+	if res.opts.Elevated != nil {
+		var err error
+		metadata, sd, err = splitSecurityDescriptor(node)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := metadata.RestoreMetadata(target, res.Warn)
 	if err != nil {
 		debug.Log("node.RestoreMetadata(%s) error %v", target, err)
+		return err
+	}
+	if sd != nil {
+		err = res.opts.Elevated.SetSecurityDescriptor(target, sd)
+		if err != nil {
+			return fmt.Errorf("error restoring security descriptor for: %s : %v", target, err)
+		}
+	}
+	if res.opts.VerifySecurityDescriptors {
+		err = node.VerifySecurityDescriptor(target)
 	}
 	return err
 }
@@ -271,6 +418,10 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 	filerestorer := newFileRestorer(dst, res.repo.LoadBlobsFromPack, res.repo.LookupBlob,
 		res.repo.Connections(), res.opts.Sparse, res.opts.Progress)
 	filerestorer.Error = res.Error
+	filerestorer.journal = res.opts.Journal
+	if res.opts.AutoTune {
+		filerestorer.tuner = newAutoTuner(filerestorer.workerCount)
+	}
 
 	debug.Log("first pass for %q", dst)
 
@@ -280,7 +431,7 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 	_, err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
 		enterDir: func(_ *restic.Node, target, location string) error {
 			debug.Log("first pass, enterDir: mkdir %q, leaveDir should restore metadata", location)
-			res.opts.Progress.AddFile(0)
+			res.opts.Progress.AddFile(location, 0)
 			// create dir with default permissions
 			// #leaveDir restores dir metadata after visiting all children
 			return fs.MkdirAll(target, 0700)
@@ -296,24 +447,42 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 			}
 
 			if node.Type != "file" {
-				res.opts.Progress.AddFile(0)
+				res.opts.Progress.AddFile(location, 0)
 				return nil
 			}
+			if node.OfflineStub() != nil && res.Warn != nil {
+				res.Warn(fmt.Sprintf("%v was offline during the backup, only its metadata is restored", location))
+			}
 
 			if node.Links > 1 {
 				if idx.Has(node.Inode, node.DeviceID) {
 					// a hardlinked file does not increase the restore size
-					res.opts.Progress.AddFile(0)
+					res.opts.Progress.AddFile(location, 0)
 					return nil
 				}
 				idx.Add(node.Inode, node.DeviceID, location)
 			}
 
-			buf, err = res.withOverwriteCheck(node, target, false, buf, func(updateMetadataOnly bool, matches *fileState) error {
+			if res.opts.ByteRange != nil {
+				return res.addFileRange(filerestorer, node, target, location)
+			}
+
+			if res.opts.Journal.Restored(location) && res.hasSize(target, node.Size) {
+				debug.Log("%q was restored by the interrupted restore", location)
+				res.opts.Progress.AddSkippedFile(location, node.Size)
+				res.opts.Progress.ReportItem(restoreui.ActionUnchanged, location, node.Size)
+				res.trackFile(location, false)
+				return nil
+			}
+
+			buf, err = res.withOverwriteCheck(node, target, location, false, buf, func(updateMetadataOnly bool, matches *fileState) error {
 				if updateMetadataOnly {
-					res.opts.Progress.AddSkippedFile(node.Size)
+					res.opts.Progress.AddSkippedFile(location, node.Size)
+					if err := res.opts.Journal.Add(location); err != nil {
+						return err
+					}
 				} else {
-					res.opts.Progress.AddFile(node.Size)
+					res.opts.Progress.AddFile(location, node.Size)
 					filerestorer.addFile(location, node.Content, int64(node.Size), matches)
 				}
 				res.trackFile(location, updateMetadataOnly)
@@ -337,15 +506,18 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 	_, err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
 		visitNode: func(node *restic.Node, target, location string) error {
 			debug.Log("second pass, visitNode: restore node %q", location)
+			if res.opts.RewriteLinks {
+				node = res.rewriteLink(node, dst, location)
+			}
 			if node.Type != "file" {
-				_, err := res.withOverwriteCheck(node, target, false, nil, func(_ bool, _ *fileState) error {
+				_, err := res.withOverwriteCheck(node, target, location, false, nil, func(_ bool, _ *fileState) error {
 					return res.restoreNodeTo(ctx, node, target, location)
 				})
 				return err
 			}
 
 			if idx.Has(node.Inode, node.DeviceID) && idx.Value(node.Inode, node.DeviceID) != location {
-				_, err := res.withOverwriteCheck(node, target, true, nil, func(_ bool, _ *fileState) error {
+				_, err := res.withOverwriteCheck(node, target, location, true, nil, func(_ bool, _ *fileState) error {
 					return res.restoreHardlinkAt(node, filerestorer.targetPath(idx.Value(node.Inode, node.DeviceID)), target, location)
 				})
 				return err
@@ -368,6 +540,31 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 	return err
 }
 
+// addFileRange schedules restoring the part of a file selected by the byte
+// range. Existing files are never reused, as their content differs.
+func (res *Restorer) addFileRange(filerestorer *fileRestorer, node *restic.Node, target, location string) error {
+	overwrite, exists, err := res.checkOverwrite(node, target, location, node.Size)
+	if err != nil || !overwrite {
+		return err
+	}
+
+	content, skip, length, err := res.opts.ByteRange.SelectBlobs(node.Content, res.repo.LookupBlobSize)
+	if err != nil {
+		return err
+	}
+	res.opts.Progress.AddFile(location, length)
+	res.reportRestore(exists, location, length)
+	filerestorer.addFileRange(location, content, int64(length), int64(skip))
+	res.trackFile(location, false)
+	return nil
+}
+
+// hasSize returns whether target is a regular file of the given size.
+func (res *Restorer) hasSize(target string, size uint64) bool {
+	fi, err := fs.Lstat(target)
+	return err == nil && fi.Mode().IsRegular() && uint64(fi.Size()) == size
+}
+
 func (res *Restorer) trackFile(location string, metadataOnly bool) {
 	res.fileList[location] = metadataOnly
 }
@@ -377,50 +574,110 @@ func (res *Restorer) hasRestoredFile(location string) (metadataOnly bool, ok boo
 	return metadataOnly, ok
 }
 
-func (res *Restorer) withOverwriteCheck(node *restic.Node, target string, isHardlink bool, buf []byte, cb func(updateMetadataOnly bool, matches *fileState) error) ([]byte, error) {
-	overwrite, err := shouldOverwrite(res.opts.Overwrite, node, target)
-	if err != nil {
+// checkOverwrite applies the overwrite behavior to the item at target and
+// reports items which are skipped or conflict with an existing item.
+func (res *Restorer) checkOverwrite(node *restic.Node, target, location string, size uint64) (overwrite bool, exists bool, err error) {
+	overwrite, exists, err = shouldOverwrite(res.opts.Overwrite, node, target)
+	switch {
+	case errors.Is(err, errTargetExists):
+		res.opts.Progress.ReportItem(restoreui.ActionConflict, location, size)
+	case err == nil && !overwrite:
+		res.opts.Progress.AddSkippedFile(location, size)
+		res.opts.Progress.ReportItem(restoreui.ActionSkip, location, size)
+	}
+	return overwrite, exists, err
+}
+
+// reportRestore reports that the item at location is restored.
+func (res *Restorer) reportRestore(exists bool, location string, size uint64) {
+	if exists {
+		res.opts.Progress.ReportItem(restoreui.ActionUpdate, location, size)
+	} else {
+		res.opts.Progress.ReportItem(restoreui.ActionRestore, location, size)
+	}
+}
+
+func (res *Restorer) withOverwriteCheck(node *restic.Node, target, location string, isHardlink bool, buf []byte, cb func(updateMetadataOnly bool, matches *fileState) error) ([]byte, error) {
+	size := node.Size
+	if isHardlink || node.Type != "file" {
+		size = 0
+	}
+	overwrite, exists, err := res.checkOverwrite(node, target, location, size)
+	if err != nil || !overwrite {
 		return buf, err
-	} else if !overwrite {
-		size := node.Size
-		if isHardlink {
-			size = 0
-		}
-		res.opts.Progress.AddSkippedFile(size)
-		return buf, nil
 	}
 
 	var matches *fileState
 	updateMetadataOnly := false
-	if node.Type == "file" && !isHardlink {
+	if node.Type == "file" && !isHardlink && efsImported(node) {
+		// the existing file cannot be compared to the raw stream and may not
+		// be writable without its key
+		if err := fs.RemoveIfExists(target); err != nil {
+			return buf, err
+		}
+	} else if node.Type == "file" && !isHardlink {
 		// if a file fails to verify, then matches is nil which results in restoring from scratch
 		matches, buf, _ = res.verifyFile(target, node, false, res.opts.Overwrite == OverwriteIfChanged, buf)
+		if res.opts.Delta && matches.NeedsRestore() && matches != nil {
+			buf = res.reuseMovedBlobs(target, node, matches, buf)
+		}
+		if matches != nil && !matches.sizeMatches && matches.HasAllBlobs() {
+			// only the size differs, for example after data was appended
+			if err := os.Truncate(target, int64(node.Size)); err != nil {
+				debug.Log("truncate %v failed: %v", target, err)
+				matches = nil
+			} else {
+				matches.sizeMatches = true
+			}
+		}
 		// skip files that are already correct completely
 		updateMetadataOnly = !matches.NeedsRestore()
 	}
 
+	if updateMetadataOnly {
+		res.opts.Progress.ReportItem(restoreui.ActionUnchanged, location, size)
+	} else {
+		res.reportRestore(exists, location, size)
+	}
 	return buf, cb(updateMetadataOnly, matches)
 }
 
-func shouldOverwrite(overwrite OverwriteBehavior, node *restic.Node, destination string) (bool, error) {
-	if overwrite == OverwriteAlways || overwrite == OverwriteIfChanged {
-		return true, nil
-	}
+// efsImported returns whether the restored content of node, which is the raw
+// encrypted backup stream of an EFS-encrypted file, is replaced by the file
+// imported from the stream.
+func efsImported(node *restic.Node) bool {
+	return runtime.GOOS == "windows" && node.EFSRaw() != nil
+}
 
+// errTargetExists is returned for existing items if the overwrite behavior is
+// OverwriteFail.
+var errTargetExists = errors.New("already exists, not overwriting it due to --overwrite fail")
+
+// shouldOverwrite returns whether the item at destination is overwritten and
+// whether it exists.
+func shouldOverwrite(overwrite OverwriteBehavior, node *restic.Node, destination string) (bool, bool, error) {
 	fi, err := fs.Lstat(destination)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return true, nil
+			return true, false, nil
+		}
+		if overwrite == OverwriteAlways || overwrite == OverwriteIfChanged {
+			// restoring the item reports a more specific error
+			return true, true, nil
 		}
-		return false, err
+		return false, false, err
 	}
 
-	if overwrite == OverwriteIfNewer {
+	switch overwrite {
+	case OverwriteAlways, OverwriteIfChanged:
+		return true, true, nil
+	case OverwriteIfNewer:
 		// return if node is newer
-		return node.ModTime.After(fi.ModTime()), nil
-	} else if overwrite == OverwriteNever {
-		// file exists
-		return false, nil
+		return node.ModTime.After(fi.ModTime()), true, nil
+	case OverwriteNever:
+		return false, true, nil
+	case OverwriteFail:
+		return false, true, errTargetExists
 	}
 	panic("unknown overwrite behavior")
 }
@@ -456,7 +713,7 @@ func (res *Restorer) VerifyFiles(ctx context.Context, dst string) (int, error) {
 
 		_, err := res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
 			visitNode: func(node *restic.Node, target, location string) error {
-				if node.Type != "file" {
+				if node.Type != "file" || efsImported(node) {
 					return nil
 				}
 				if metadataOnly, ok := res.hasRestoredFile(location); !ok || metadataOnly {
@@ -513,6 +770,20 @@ func (s *fileState) NeedsRestore() bool {
 	return false
 }
 
+// HasAllBlobs reports whether all blobs of the file match, its size may
+// differ nevertheless.
+func (s *fileState) HasAllBlobs() bool {
+	if s == nil || s.blobMatches == nil {
+		return false
+	}
+	for _, match := range s.blobMatches {
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *fileState) HasMatchingBlob(i int) bool {
 	if s == nil || s.blobMatches == nil {
 		return false
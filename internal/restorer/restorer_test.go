@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"io"
 	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -19,6 +20,7 @@ import (
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	restoreui "github.com/restic/restic/internal/ui/restore"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -44,6 +46,10 @@ type Dir struct {
 	attributes *FileAttributes
 }
 
+type Symlink struct {
+	Target string
+}
+
 type FileAttributes struct {
 	ReadOnly  bool
 	Hidden    bool
@@ -122,6 +128,16 @@ func saveDir(t testing.TB, repo restic.BlobSaver, nodes map[string]Node, inode u
 				GenericAttributes: getGenericAttributes(node.attributes, false),
 			})
 			rtest.OK(t, err)
+		case Symlink:
+			err := tree.Insert(&restic.Node{
+				Type:       "symlink",
+				Mode:       os.ModeSymlink | 0777,
+				Name:       name,
+				UID:        uint32(os.Getuid()),
+				GID:        uint32(os.Getgid()),
+				LinkTarget: node.Target,
+			})
+			rtest.OK(t, err)
 		default:
 			t.Fatalf("unknown node type %T", node)
 		}
@@ -1029,6 +1045,80 @@ func TestRestorerOverwriteBehavior(t *testing.T) {
 	}
 }
 
+type itemPrinter struct {
+	items map[string]restoreui.ItemAction
+}
+
+func (p *itemPrinter) Update(_ restoreui.State, _ time.Duration) {}
+func (p *itemPrinter) Finish(_ restoreui.State, _ time.Duration) {}
+func (p *itemPrinter) ReportItem(action restoreui.ItemAction, item string, _ uint64) {
+	p.items[item] = action
+}
+
+func TestRestorerOverwriteItems(t *testing.T) {
+	baseTime := time.Now()
+	repo := repository.TestRepository(t)
+	tempdir := filepath.Join(rtest.TempDir(t), "target")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"same":    File{Data: "content: same\n", ModTime: baseTime},
+			"changed": File{Data: "content: old\n", ModTime: baseTime},
+		},
+	}, noopGetGenericAttributes)
+	rtest.OK(t, NewRestorer(repo, sn, Options{}).RestoreTo(ctx, tempdir))
+
+	sn, _ = saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"same":    File{Data: "content: same\n", ModTime: baseTime},
+			"changed": File{Data: "content: new\n", ModTime: baseTime.Add(time.Second)},
+			"new":     File{Data: "content: new\n", ModTime: baseTime},
+		},
+	}, noopGetGenericAttributes)
+
+	for _, test := range []struct {
+		overwrite OverwriteBehavior
+		items     map[string]restoreui.ItemAction
+	}{
+		{OverwriteIfNewer, map[string]restoreui.ItemAction{
+			"/same":    restoreui.ActionSkip,
+			"/changed": restoreui.ActionUpdate,
+			"/new":     restoreui.ActionRestore,
+		}},
+		{OverwriteAlways, map[string]restoreui.ItemAction{
+			"/same":    restoreui.ActionUnchanged,
+			"/changed": restoreui.ActionUnchanged,
+			"/new":     restoreui.ActionUnchanged,
+		}},
+		{OverwriteFail, map[string]restoreui.ItemAction{
+			"/same":    restoreui.ActionConflict,
+			"/changed": restoreui.ActionConflict,
+			"/new":     restoreui.ActionConflict,
+		}},
+	} {
+		printer := &itemPrinter{items: make(map[string]restoreui.ItemAction)}
+		progress := restoreui.NewProgress(printer, 0)
+		res := NewRestorer(repo, sn, Options{Overwrite: test.overwrite, Progress: progress})
+		var conflicts []string
+		res.Error = func(location string, err error) error {
+			rtest.Assert(t, err == errTargetExists, "unexpected error for %v: %v", location, err)
+			conflicts = append(conflicts, location)
+			return nil
+		}
+		rtest.OK(t, res.RestoreTo(ctx, tempdir))
+		progress.Finish()
+
+		rtest.Equals(t, test.items, printer.items, "overwrite %v", test.overwrite.String())
+		if test.overwrite == OverwriteFail {
+			rtest.Equals(t, 3, len(conflicts))
+		} else {
+			rtest.Equals(t, 0, len(conflicts))
+		}
+	}
+}
+
 func TestRestoreModified(t *testing.T) {
 	// overwrite files between snapshots and also change their filesize
 	snapshots := []Snapshot{
@@ -1113,3 +1203,62 @@ func TestRestoreIfChanged(t *testing.T) {
 		}
 	}
 }
+
+func TestRestoreByteRange(t *testing.T) {
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: "0123456789", ModTime: time.Now()},
+			"bar": File{Data: "abc", ModTime: time.Now()},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	tempdir := filepath.Join(rtest.TempDir(t), "target")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+	res := NewRestorer(repo, sn, Options{ByteRange: &restic.ByteRange{Offset: 3, Length: 4}})
+	rtest.OK(t, res.RestoreTo(ctx, tempdir))
+
+	for name, expected := range map[string]string{"foo": "3456", "bar": ""} {
+		data, err := os.ReadFile(filepath.Join(tempdir, name))
+		rtest.OK(t, err)
+		rtest.Equals(t, expected, string(data), "unexpected content of %v", name)
+	}
+}
+
+func TestRestoreLargeFilePartialMatch(t *testing.T) {
+	repo := repository.TestRepository(t)
+	wg, wgCtx := errgroup.WithContext(context.TODO())
+	repo.StartPackUploader(wgCtx, wg)
+
+	data := make([]byte, 2*largeFileBlobCount*1000)
+	_, _ = rand.New(rand.NewSource(23)).Read(data)
+	node := &restic.Node{Type: "file", Name: "file", Mode: 0644, Size: uint64(len(data))}
+	for i := 0; i < len(data); i += 1000 {
+		id, _, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, data[i:i+1000], restic.ID{}, false)
+		rtest.OK(t, err)
+		node.Content = append(node.Content, id)
+	}
+	tree := &restic.Tree{}
+	rtest.OK(t, tree.Insert(node))
+	treeID, err := restic.SaveTree(context.TODO(), repo, tree)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.TODO()))
+
+	// every third blob differs
+	existing := append([]byte{}, data...)
+	for i := 0; i < len(existing); i += 3000 {
+		existing[i] ^= 0xff
+	}
+	tempdir := rtest.TempDir(t)
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "file"), existing, 0644))
+
+	res := NewRestorer(repo, &restic.Snapshot{Tree: &treeID}, Options{})
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+	restored, err := os.ReadFile(filepath.Join(tempdir, "file"))
+	rtest.OK(t, err)
+	rtest.Assert(t, bytes.Equal(data, restored), "restored file differs")
+}
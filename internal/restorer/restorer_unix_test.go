@@ -79,6 +79,8 @@ func (p *printerMock) Update(_ restoreui.State, _ time.Duration) {
 func (p *printerMock) Finish(s restoreui.State, _ time.Duration) {
 	p.s = s
 }
+func (p *printerMock) ReportItem(_ restoreui.ItemAction, _ string, _ uint64) {
+}
 
 func TestRestorerProgressBar(t *testing.T) {
 	repo := repository.TestRepository(t)
@@ -150,3 +152,112 @@ func TestRestorePermissions(t *testing.T) {
 		rtest.Equals(t, fs.FileMode(0o600), fi.Mode().Perm(), "unexpected permissions")
 	}
 }
+
+func TestRestorerRestoreHardlinkFarm(t *testing.T) {
+	repo := repository.TestRepository(t)
+
+	// links of the same file in several directories, as created by rsnapshot
+	nodes := map[string]Node{}
+	for _, name := range []string{"daily.0", "daily.1", "daily.2"} {
+		nodes[name] = Dir{
+			Nodes: map[string]Node{
+				"file": File{Data: "content of the hardlinked file", Links: 3, Inode: 42},
+			},
+		}
+	}
+	sn, _ := saveSnapshot(t, repo, Snapshot{Nodes: nodes}, noopGetGenericAttributes)
+
+	res := NewRestorer(repo, sn, Options{})
+
+	tempdir := rtest.TempDir(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := res.RestoreTo(ctx, tempdir)
+	rtest.OK(t, err)
+
+	var inode uint64
+	for name := range nodes {
+		filename := filepath.Join(tempdir, name, "file")
+		data, err := os.ReadFile(filename)
+		rtest.OK(t, err)
+		rtest.Equals(t, "content of the hardlinked file", string(data))
+
+		fi, err := os.Stat(filename)
+		rtest.OK(t, err)
+		s := fi.Sys().(*syscall.Stat_t)
+		rtest.Equals(t, uint64(3), uint64(s.Nlink))
+		if inode == 0 {
+			inode = uint64(s.Ino)
+		}
+		rtest.Equals(t, inode, uint64(s.Ino))
+	}
+}
+
+func TestRestorerRewriteLinks(t *testing.T) {
+	repo := repository.TestRepository(t)
+
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"srv": Dir{
+				Nodes: map[string]Node{
+					"data": Dir{
+						Nodes: map[string]Node{
+							"file":     File{Data: "content"},
+							"inside":   Symlink{Target: "/srv/data/file"},
+							"outside":  Symlink{Target: "/srv/other"},
+							"relative": Symlink{Target: "file"},
+						},
+					},
+				},
+			},
+		},
+	}, noopGetGenericAttributes)
+	sn.Paths = []string{"/srv/data"}
+
+	for _, test := range []struct {
+		subfolder string
+		inside    string
+	}{
+		{"", "srv/data/file"},
+		{"/srv/data", "file"},
+	} {
+		t.Run(test.subfolder, func(t *testing.T) {
+			root := sn
+			if test.subfolder != "" {
+				s := *sn
+				var err error
+				s.Tree, err = restic.FindTreeDirectory(context.TODO(), repo, sn.Tree, test.subfolder)
+				rtest.OK(t, err)
+				root = &s
+			}
+
+			res := NewRestorer(repo, root, Options{RewriteLinks: true, Subfolder: test.subfolder})
+			var reported []string
+			res.ReportLink = func(location, _, _ string) {
+				reported = append(reported, location)
+			}
+
+			tempdir := rtest.TempDir(t)
+			rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+			dir := filepath.Join(tempdir, filepath.Dir(test.inside))
+			for name, expected := range map[string]string{
+				"inside":   filepath.Join(tempdir, test.inside),
+				"outside":  "/srv/other",
+				"relative": "file",
+			} {
+				target, err := os.Readlink(filepath.Join(dir, name))
+				rtest.OK(t, err)
+				rtest.Equals(t, expected, target, name)
+			}
+
+			data, err := os.ReadFile(filepath.Join(dir, "inside"))
+			rtest.OK(t, err)
+			rtest.Equals(t, "content", string(data))
+
+			rtest.Equals(t, LinkReport{Rewritten: 1, Unchanged: 1}, res.LinkReport())
+			rtest.Equals(t, 2, len(reported))
+		})
+	}
+}
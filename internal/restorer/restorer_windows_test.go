@@ -539,3 +539,86 @@ func TestDirAttributeCombinationsOverwrite(t *testing.T) {
 		}
 	}
 }
+
+func TestRestoreStreamFilter(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"file":                 File{Data: "content"},
+			"file:Zone.Identifier": File{Data: "[ZoneTransfer]"},
+			"file:summary":         File{Data: "summary"},
+		},
+	}, noopGetGenericAttributes)
+
+	streams, err := NewStreamFilter(nil, []string{"zone.identifier"})
+	rtest.OK(t, err)
+	res := NewRestorer(repo, sn, Options{Streams: streams})
+
+	tempdir := rtest.TempDir(t)
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+	data, err := os.ReadFile(path.Join(tempdir, "file:summary"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "summary", string(data))
+
+	_, err = os.Stat(path.Join(tempdir, "file:Zone.Identifier"))
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "excluded stream was restored: %v", err)
+}
+
+func TestRestoreStreamMode(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"dir": Dir{Nodes: map[string]Node{
+				"file":                 File{Data: "content"},
+				"file:Zone.Identifier": File{Data: "[ZoneTransfer]"},
+			}},
+		},
+	}, noopGetGenericAttributes)
+
+	tempdir := rtest.TempDir(t)
+	res := NewRestorer(repo, sn, Options{StreamMode: StreamsSkip})
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+	data, err := os.ReadFile(path.Join(tempdir, "dir", "file"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "content", string(data))
+	_, err = os.Stat(path.Join(tempdir, "dir", "file:Zone.Identifier"))
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "skipped stream was restored: %v", err)
+
+	tempdir = rtest.TempDir(t)
+	res = NewRestorer(repo, sn, Options{StreamMode: StreamsOnly})
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+	data, err = os.ReadFile(path.Join(tempdir, "dir", "file:Zone.Identifier"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "[ZoneTransfer]", string(data))
+	// the file is created for the stream, but its contents are not restored
+	data, err = os.ReadFile(path.Join(tempdir, "dir", "file"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "", string(data))
+}
+
+func TestRestoreHardlinks(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"file": File{Data: "content", Links: 2, Inode: 42},
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"link": File{Data: "content", Links: 2, Inode: 42},
+				},
+			},
+		},
+	}, noopGetGenericAttributes)
+
+	res := NewRestorer(repo, sn, Options{})
+	tempdir := rtest.TempDir(t)
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+	file, err := os.Stat(path.Join(tempdir, "file"))
+	rtest.OK(t, err)
+	link, err := os.Stat(path.Join(tempdir, "dir", "link"))
+	rtest.OK(t, err)
+	rtest.Assert(t, os.SameFile(file, link), "hard link was restored as a copy")
+}
@@ -0,0 +1,135 @@
+package restorer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/filter"
+	"github.com/restic/restic/internal/restic"
+)
+
+// StreamMode selects whether the main contents of files, their alternate data
+// streams or both are restored.
+type StreamMode int
+
+// Constants for the different stream modes
+const (
+	// StreamsAll restores files together with their streams.
+	StreamsAll StreamMode = iota
+	// StreamsOnly restores only the streams and the directories containing
+	// them. Files which do not exist yet are created empty.
+	StreamsOnly
+	// StreamsSkip restores files without their streams.
+	StreamsSkip
+	StreamsInvalid
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (m *StreamMode) Set(s string) error {
+	switch s {
+	case "all":
+		*m = StreamsAll
+	case "only":
+		*m = StreamsOnly
+	case "skip":
+		*m = StreamsSkip
+	default:
+		*m = StreamsInvalid
+		return fmt.Errorf("invalid stream mode %q, must be one of (all|only|skip)", s)
+	}
+
+	return nil
+}
+
+func (m *StreamMode) String() string {
+	switch *m {
+	case StreamsAll:
+		return "all"
+	case StreamsOnly:
+		return "only"
+	case StreamsSkip:
+		return "skip"
+	default:
+		return "invalid"
+	}
+}
+
+func (m *StreamMode) Type() string {
+	return "mode"
+}
+
+// StreamFilter selects the alternate data streams of files which are
+// restored. Patterns are matched case-insensitively against the stream name,
+// for example "Zone.Identifier", as stream names on NTFS are not case
+// sensitive.
+type StreamFilter struct {
+	includes []filter.Pattern
+	excludes []filter.Pattern
+}
+
+// NewStreamFilter returns a filter which restores the streams matching one of
+// the include patterns, or all streams if there are none, except for those
+// matching one of the exclude patterns.
+func NewStreamFilter(includes, excludes []string) (*StreamFilter, error) {
+	prepare := func(patterns []string) ([]filter.Pattern, error) {
+		lower := make([]string, 0, len(patterns))
+		for _, p := range patterns {
+			lower = append(lower, strings.ToLower(p))
+		}
+		if err := filter.ValidatePatterns(lower); err != nil {
+			return nil, err
+		}
+		return filter.ParsePatterns(lower), nil
+	}
+
+	f := &StreamFilter{}
+	var err error
+	if f.includes, err = prepare(includes); err != nil {
+		return nil, errors.Fatalf("--include-ads-pattern: %s", err)
+	}
+	if f.excludes, err = prepare(excludes); err != nil {
+		return nil, errors.Fatalf("--exclude-ads-pattern: %s", err)
+	}
+	return f, nil
+}
+
+// streamName returns the name of the stream of the node name "file:stream" or
+// "file:stream:$DATA", or "" if name is no stream.
+func streamName(name string) string {
+	_, stream, found := strings.Cut(name, ":")
+	if !found {
+		return ""
+	}
+	return strings.TrimSuffix(stream, ":$DATA")
+}
+
+// Select reports whether the stream node with the given name is restored.
+// Nodes which are not streams are always selected.
+func (f *StreamFilter) Select(name string) bool {
+	stream := strings.ToLower(streamName(name))
+	if stream == "" {
+		return true
+	}
+
+	if len(f.includes) > 0 {
+		if matched, _ := filter.List(f.includes, stream); !matched {
+			return false
+		}
+	}
+	matched, _ := filter.List(f.excludes, stream)
+	return !matched
+}
+
+// selectStreams reports whether the node is restored according to the stream
+// mode and the stream filter.
+func (res *Restorer) selectStreams(node *restic.Node) bool {
+	if restic.ClassifyNode(node.Name) != restic.StreamNode {
+		// directories are required to restore the streams within them
+		return res.opts.StreamMode != StreamsOnly || node.Type == "dir"
+	}
+	if res.opts.StreamMode == StreamsSkip {
+		return false
+	}
+	return res.opts.Streams == nil || res.opts.Streams.Select(node.Name)
+}
@@ -0,0 +1,43 @@
+package restorer
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestStreamFilter(t *testing.T) {
+	for _, test := range []struct {
+		includes, excludes []string
+		name               string
+		selected           bool
+	}{
+		{nil, []string{"Zone.Identifier"}, "file.txt", true},
+		{nil, []string{"Zone.Identifier"}, "file.txt:Zone.Identifier", false},
+		{nil, []string{"Zone.Identifier"}, "file.txt:zone.identifier:$DATA", false},
+		{nil, []string{"Zone.Identifier"}, "file.txt:summary", true},
+		{[]string{"summary*"}, nil, "file.txt:SummaryInformation", true},
+		{[]string{"summary*"}, nil, "file.txt:Zone.Identifier", false},
+		{[]string{"*"}, []string{"Zone.*"}, "file.txt:Zone.Identifier", false},
+	} {
+		f, err := NewStreamFilter(test.includes, test.excludes)
+		rtest.OK(t, err)
+		rtest.Assert(t, f.Select(test.name) == test.selected,
+			"includes %v, excludes %v: expected Select(%q) to return %v", test.includes, test.excludes, test.name, test.selected)
+	}
+
+	_, err := NewStreamFilter(nil, []string{"[a"})
+	rtest.Assert(t, err != nil, "missing error for invalid pattern")
+}
+
+func TestStreamMode(t *testing.T) {
+	var m StreamMode
+	rtest.Equals(t, "all", m.String())
+	for _, s := range []string{"all", "only", "skip"} {
+		rtest.OK(t, m.Set(s))
+		rtest.Equals(t, s, m.String())
+	}
+
+	err := m.Set("streams")
+	rtest.Assert(t, err != nil, "missing error for invalid mode")
+}
@@ -0,0 +1,96 @@
+// Package schedule generates definitions for the task schedulers of the
+// operating system, which run restic with the given arguments regularly.
+package schedule
+
+import (
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Task describes a scheduled run of restic.
+type Task struct {
+	// Name identifies the task, it is used for the task and unit names.
+	Name string
+	// Description is shown by the task scheduler.
+	Description string
+
+	// Command is the absolute path of the restic binary and Args are the
+	// arguments passed to it.
+	Command string
+	Args    []string
+
+	// Hour and Minute is the time of day at which the task runs.
+	Hour, Minute int
+	// Weekdays restricts the task to the given days, it runs every day if
+	// Weekdays is empty.
+	Weekdays []time.Weekday
+
+	// User is the account which runs the task.
+	User string
+
+	// Retries is the number of times a failed task is restarted after
+	// RetryInterval.
+	Retries       int
+	RetryInterval time.Duration
+}
+
+// ParseTime parses a time of day in the format HH:MM.
+func ParseTime(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWeekdays parses abbreviated weekday names like "mon" or "Fri".
+func ParseWeekdays(names []string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	seen := make(map[time.Weekday]bool)
+	for _, name := range names {
+		day, ok := weekdays[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, errors.Errorf("invalid weekday %q, expected one of mon, tue, wed, thu, fri, sat or sun", name)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// Validate checks that the task can be scheduled.
+func (t *Task) Validate() error {
+	if t.Name == "" {
+		return errors.New("no task name specified")
+	}
+	if strings.ContainsAny(t.Name, `\/`) {
+		return errors.Errorf("invalid task name %q, must not contain slashes", t.Name)
+	}
+	if t.Command == "" {
+		return errors.New("no command specified")
+	}
+	if len(t.Args) == 0 {
+		return errors.New("no arguments for restic specified")
+	}
+	if t.Retries < 0 {
+		return errors.New("the number of retries must not be negative")
+	}
+	if t.Retries > 0 && t.RetryInterval < time.Minute {
+		return errors.New("the retry interval must be at least one minute")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package schedule
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testTask() Task {
+	return Task{
+		Name:          "restic-backup",
+		Description:   "Backup of the home directories",
+		Command:       `C:\Program Files\restic\restic.exe`,
+		Args:          []string{"-r", `D:\backup repo`, "--password-file", `C:\restic\pw.txt`, "backup", `C:\Users\`, `say "hi"`},
+		Hour:          2,
+		Minute:        30,
+		Weekdays:      []time.Weekday{time.Monday, time.Friday},
+		Retries:       3,
+		RetryInterval: 10 * time.Minute,
+	}
+}
+
+func decodeUTF16(t testing.TB, buf []byte) string {
+	rtest.Assert(t, len(buf)%2 == 0 && buf[0] == 0xff && buf[1] == 0xfe, "missing byte order mark")
+	units := make([]uint16, 0, len(buf)/2-1)
+	for i := 2; i < len(buf); i += 2 {
+		units = append(units, uint16(buf[i])|uint16(buf[i+1])<<8)
+	}
+	return string(utf16.Decode(units))
+}
+
+func TestTaskXML(t *testing.T) {
+	buf, err := TaskXML(testTask(), time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local))
+	rtest.OK(t, err)
+	doc := decodeUTF16(t, buf)
+
+	rtest.Assert(t, strings.HasPrefix(doc, `<?xml version="1.0" encoding="UTF-16"?>`), "missing declaration in %v", doc)
+
+	var def struct {
+		URI           string `xml:"RegistrationInfo>URI"`
+		StartBoundary string `xml:"Triggers>CalendarTrigger>StartBoundary"`
+		UserID        string `xml:"Principals>Principal>UserId"`
+		Interval      string `xml:"Settings>RestartOnFailure>Interval"`
+		Count         int    `xml:"Settings>RestartOnFailure>Count"`
+		Command       string `xml:"Actions>Exec>Command"`
+		Arguments     string `xml:"Actions>Exec>Arguments"`
+	}
+	// the decoder does not support UTF-16, the document is decoded already
+	dec := xml.NewDecoder(strings.NewReader(strings.Replace(doc, "UTF-16", "UTF-8", 1)))
+	rtest.OK(t, dec.Decode(&def))
+
+	rtest.Equals(t, `\restic-backup`, def.URI)
+	rtest.Equals(t, "2026-10-17T02:30:00", def.StartBoundary)
+	rtest.Assert(t, strings.Contains(doc, "<DaysOfWeek>\r\n          <Monday></Monday>\r\n          <Friday></Friday>\r\n        </DaysOfWeek>"), "unexpected days in %v", doc)
+	rtest.Equals(t, "S-1-5-18", def.UserID)
+	rtest.Equals(t, "PT10M", def.Interval)
+	rtest.Equals(t, 3, def.Count)
+	rtest.Equals(t, `C:\Program Files\restic\restic.exe`, def.Command)
+	rtest.Equals(t, `-r "D:\backup repo" --password-file C:\restic\pw.txt backup C:\Users\ "say \"hi\""`, def.Arguments)
+}
+
+func TestPrincipal(t *testing.T) {
+	for _, test := range []struct {
+		user      string
+		userID    string
+		logonType string
+	}{
+		{"", "S-1-5-18", ""},
+		{"SYSTEM", "S-1-5-18", ""},
+		{`NT AUTHORITY\Network Service`, "S-1-5-20", "ServiceAccount"},
+		{"LocalService", "S-1-5-19", "ServiceAccount"},
+		{`EXAMPLE\backup`, `EXAMPLE\backup`, "Password"},
+	} {
+		p := principal(test.user)
+		rtest.Equals(t, test.userID, p.UserID)
+		rtest.Equals(t, test.logonType, p.LogonType)
+	}
+}
+
+func TestQuoteWindowsArg(t *testing.T) {
+	for _, test := range []struct {
+		arg, quoted string
+	}{
+		{"", `""`},
+		{`C:\data`, `C:\data`},
+		{`C:\my data\`, `"C:\my data\\"`},
+		{`a"b`, `"a\"b"`},
+		{`a\"b`, `"a\\\"b"`},
+	} {
+		rtest.Equals(t, test.quoted, quoteWindowsArg(test.arg))
+	}
+}
+
+func TestSystemdUnits(t *testing.T) {
+	task := testTask()
+	task.Command = "/usr/bin/restic"
+	task.Args = []string{"-r", "/srv/backup repo", "backup", "/home", "--exclude", "*.tmp", "--tag", "100%", "$HOME"}
+	task.User = "backup"
+
+	service, timer, err := SystemdUnits(task)
+	rtest.OK(t, err)
+
+	rtest.Assert(t, strings.Contains(service, `ExecStart=/usr/bin/restic -r "/srv/backup repo" backup /home --exclude *.tmp --tag 100%% $$HOME`+"\n"), "unexpected service %v", service)
+	rtest.Assert(t, strings.Contains(service, "User=backup\n"), "unexpected service %v", service)
+	rtest.Assert(t, strings.Contains(service, "Restart=on-failure\nRestartSec=600\n"), "unexpected service %v", service)
+	rtest.Assert(t, strings.Contains(service, "StartLimitBurst=4\n"), "unexpected service %v", service)
+	rtest.Assert(t, strings.Contains(timer, "OnCalendar=Mon,Fri *-*-* 02:30:00\n"), "unexpected timer %v", timer)
+
+	task.Name = "restic backup"
+	_, _, err = SystemdUnits(task)
+	rtest.Assert(t, err != nil, "expected error for invalid unit name")
+}
+
+func TestParse(t *testing.T) {
+	hour, minute, err := ParseTime("23:05")
+	rtest.OK(t, err)
+	rtest.Equals(t, 23, hour)
+	rtest.Equals(t, 5, minute)
+
+	_, _, err = ParseTime("25:00")
+	rtest.Assert(t, err != nil, "expected error for invalid time")
+
+	days, err := ParseWeekdays([]string{"Mon", "fri", "mon"})
+	rtest.OK(t, err)
+	rtest.Equals(t, []time.Weekday{time.Monday, time.Friday}, days)
+
+	_, err = ParseWeekdays([]string{"monday"})
+	rtest.Assert(t, err != nil, "expected error for invalid weekday")
+}
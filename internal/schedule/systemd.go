@@ -0,0 +1,99 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// unitNameChars are the characters allowed in the names of systemd units.
+const unitNameChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789:-_."
+
+// quoteSystemdArg quotes s for the command line of ExecStart. Specifiers and
+// environment variables are escaped, such that s is passed unchanged.
+func quoteSystemdArg(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	s = strings.ReplaceAll(s, "$", "$$")
+	if s != "" && s != ";" && !strings.ContainsAny(s, " \t\n\"'\\") {
+		return s
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// onCalendar returns the calendar event of the task for systemd timers.
+func onCalendar(t Task) string {
+	var days []string
+	for _, day := range t.Weekdays {
+		days = append(days, day.String()[:3])
+	}
+
+	event := fmt.Sprintf("*-*-* %02d:%02d:00", t.Hour, t.Minute)
+	if len(days) > 0 {
+		event = strings.Join(days, ",") + " " + event
+	}
+	return event
+}
+
+// SystemdUnits returns the service and the timer unit which run the task.
+// The units are named after the task, for example restic-backup.service and
+// restic-backup.timer.
+func SystemdUnits(t Task) (service, timer string, err error) {
+	if err := t.Validate(); err != nil {
+		return "", "", err
+	}
+
+	for _, c := range t.Name {
+		if !strings.ContainsRune(unitNameChars, c) {
+			return "", "", errors.Errorf("invalid task name %q, unit names may only contain letters, digits and the characters %q", t.Name, ":-_.")
+		}
+	}
+
+	description := strings.Join(strings.Fields(t.Description), " ")
+	if description == "" {
+		description = t.Name
+	}
+
+	args := []string{quoteSystemdArg(t.Command)}
+	for _, arg := range t.Args {
+		args = append(args, quoteSystemdArg(arg))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[Unit]\n")
+	fmt.Fprintf(&sb, "Description=%s\n", description)
+	fmt.Fprintf(&sb, "Wants=network-online.target\n")
+	fmt.Fprintf(&sb, "After=network-online.target\n")
+	if t.Retries > 0 {
+		// allow the initial start and the retries
+		fmt.Fprintf(&sb, "StartLimitIntervalSec=%d\n", int64(t.Retries+1)*int64(t.RetryInterval/time.Second)+60)
+		fmt.Fprintf(&sb, "StartLimitBurst=%d\n", t.Retries+1)
+	}
+	fmt.Fprintf(&sb, "\n[Service]\n")
+	fmt.Fprintf(&sb, "Type=oneshot\n")
+	if t.User != "" && t.User != "root" {
+		fmt.Fprintf(&sb, "User=%s\n", t.User)
+	}
+	fmt.Fprintf(&sb, "ExecStart=%s\n", strings.Join(args, " "))
+	if t.Retries > 0 {
+		fmt.Fprintf(&sb, "Restart=on-failure\n")
+		fmt.Fprintf(&sb, "RestartSec=%d\n", int64(t.RetryInterval/time.Second))
+	}
+	service = sb.String()
+
+	sb.Reset()
+	fmt.Fprintf(&sb, "[Unit]\n")
+	fmt.Fprintf(&sb, "Description=%s\n", description)
+	fmt.Fprintf(&sb, "\n[Timer]\n")
+	fmt.Fprintf(&sb, "OnCalendar=%s\n", onCalendar(t))
+	// run missed backups when the system is up again
+	fmt.Fprintf(&sb, "Persistent=true\n")
+	fmt.Fprintf(&sb, "\n[Install]\n")
+	fmt.Fprintf(&sb, "WantedBy=timers.target\n")
+	timer = sb.String()
+
+	return service, timer, nil
+}
@@ -0,0 +1,239 @@
+package schedule
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// taskNamespace is the namespace of Task Scheduler definitions.
+const taskNamespace = "http://schemas.microsoft.com/windows/2004/02/mit/task"
+
+type taskDefinition struct {
+	XMLName xml.Name `xml:"Task"`
+	Version string   `xml:"version,attr"`
+	Xmlns   string   `xml:"xmlns,attr"`
+
+	Description string          `xml:"RegistrationInfo>Description"`
+	URI         string          `xml:"RegistrationInfo>URI"`
+	Trigger     calendarTrigger `xml:"Triggers>CalendarTrigger"`
+	Principal   taskPrincipal   `xml:"Principals>Principal"`
+	Settings    taskSettings    `xml:"Settings"`
+	Actions     taskActions     `xml:"Actions"`
+}
+
+type calendarTrigger struct {
+	StartBoundary  string          `xml:"StartBoundary"`
+	Enabled        bool            `xml:"Enabled"`
+	ScheduleByDay  *scheduleByDay  `xml:"ScheduleByDay,omitempty"`
+	ScheduleByWeek *scheduleByWeek `xml:"ScheduleByWeek,omitempty"`
+}
+
+type scheduleByDay struct {
+	DaysInterval int `xml:"DaysInterval"`
+}
+
+type scheduleByWeek struct {
+	DaysOfWeek    daysOfWeek `xml:"DaysOfWeek"`
+	WeeksInterval int        `xml:"WeeksInterval"`
+}
+
+type daysOfWeek struct {
+	Days []xml.Name
+}
+
+func (d daysOfWeek) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, day := range d.Days {
+		if err := e.EncodeElement("", xml.StartElement{Name: day}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+type taskPrincipal struct {
+	ID        string `xml:"id,attr"`
+	UserID    string `xml:"UserId"`
+	LogonType string `xml:"LogonType,omitempty"`
+	RunLevel  string `xml:"RunLevel"`
+}
+
+type taskSettings struct {
+	MultipleInstancesPolicy    string        `xml:"MultipleInstancesPolicy"`
+	DisallowStartIfOnBatteries bool          `xml:"DisallowStartIfOnBatteries"`
+	StopIfGoingOnBatteries     bool          `xml:"StopIfGoingOnBatteries"`
+	StartWhenAvailable         bool          `xml:"StartWhenAvailable"`
+	ExecutionTimeLimit         string        `xml:"ExecutionTimeLimit"`
+	Enabled                    bool          `xml:"Enabled"`
+	RestartOnFailure           *taskRestarts `xml:"RestartOnFailure,omitempty"`
+}
+
+type taskRestarts struct {
+	Interval string `xml:"Interval"`
+	Count    int    `xml:"Count"`
+}
+
+type taskActions struct {
+	Context string   `xml:"Context,attr"`
+	Exec    taskExec `xml:"Exec"`
+}
+
+type taskExec struct {
+	Command   string `xml:"Command"`
+	Arguments string `xml:"Arguments"`
+}
+
+// serviceAccounts maps the names of the built-in service accounts to their
+// SIDs. The accounts do not have a password.
+var serviceAccounts = map[string]string{
+	"system":                       "S-1-5-18",
+	"localsystem":                  "S-1-5-18",
+	`nt authority\system`:          "S-1-5-18",
+	"localservice":                 "S-1-5-19",
+	`nt authority\localservice`:    "S-1-5-19",
+	`nt authority\local service`:   "S-1-5-19",
+	"networkservice":               "S-1-5-20",
+	`nt authority\networkservice`:  "S-1-5-20",
+	`nt authority\network service`: "S-1-5-20",
+}
+
+// principal returns the principal running the task as user. Without user,
+// the task runs as SYSTEM. Other accounts than the built-in service accounts
+// run whether the user is logged on or not, their password is requested when
+// the task is imported.
+func principal(user string) taskPrincipal {
+	p := taskPrincipal{ID: "Author", RunLevel: "HighestAvailable"}
+	if user == "" {
+		user = "SYSTEM"
+	}
+
+	switch sid := serviceAccounts[strings.ToLower(user)]; sid {
+	case "":
+		p.UserID = user
+		p.LogonType = "Password"
+	case "S-1-5-18":
+		p.UserID = sid
+	default:
+		p.UserID = sid
+		p.LogonType = "ServiceAccount"
+	}
+	return p
+}
+
+// isoDuration formats d as an ISO 8601 duration in minutes.
+func isoDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%dM", int(d/time.Minute))
+}
+
+// quoteWindowsArg quotes s such that it is parsed as a single argument by
+// CommandLineToArgvW.
+func quoteWindowsArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"") {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	backslashes := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			// backslashes before a quote and the quote itself are escaped
+			sb.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+		default:
+			sb.WriteString(strings.Repeat(`\`, backslashes))
+		}
+		backslashes = 0
+		sb.WriteByte(s[i])
+	}
+	// backslashes before the closing quote are escaped
+	sb.WriteString(strings.Repeat(`\`, 2*backslashes))
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// TaskXML returns the definition of the task for the Windows Task Scheduler,
+// which can be imported using the Task Scheduler or "schtasks /create /xml".
+// The first run is scheduled on the day of now. The document is encoded as
+// UTF-16 with byte order mark, as expected by schtasks.
+func TaskXML(t Task, now time.Time) ([]byte, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, len(t.Args))
+	for _, arg := range t.Args {
+		args = append(args, quoteWindowsArg(arg))
+	}
+
+	start := time.Date(now.Year(), now.Month(), now.Day(), t.Hour, t.Minute, 0, 0, time.Local)
+	def := taskDefinition{
+		Version:     "1.2",
+		Xmlns:       taskNamespace,
+		Description: t.Description,
+		URI:         `\` + t.Name,
+		Trigger: calendarTrigger{
+			// local time without time zone, the task follows changes of the
+			// time zone and daylight saving time
+			StartBoundary: start.Format("2006-01-02T15:04:05"),
+			Enabled:       true,
+		},
+		Principal: principal(t.User),
+		Settings: taskSettings{
+			MultipleInstancesPolicy: "IgnoreNew",
+			StartWhenAvailable:      true,
+			ExecutionTimeLimit:      "PT0S",
+			Enabled:                 true,
+		},
+		Actions: taskActions{
+			Context: "Author",
+			Exec: taskExec{
+				Command:   t.Command,
+				Arguments: strings.Join(args, " "),
+			},
+		},
+	}
+
+	if len(t.Weekdays) == 0 {
+		def.Trigger.ScheduleByDay = &scheduleByDay{DaysInterval: 1}
+	} else {
+		week := &scheduleByWeek{WeeksInterval: 1}
+		for _, day := range t.Weekdays {
+			week.DaysOfWeek.Days = append(week.DaysOfWeek.Days, xml.Name{Local: day.String()})
+		}
+		def.Trigger.ScheduleByWeek = week
+	}
+
+	if t.Retries > 0 {
+		def.Settings.RestartOnFailure = &taskRestarts{
+			Interval: isoDuration(t.RetryInterval),
+			Count:    t.Retries,
+		}
+	}
+
+	buf, err := xml.MarshalIndent(def, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	doc := `<?xml version="1.0" encoding="UTF-16"?>` + "\r\n" + strings.ReplaceAll(string(buf), "\n", "\r\n") + "\r\n"
+
+	return encodeUTF16(doc), nil
+}
+
+// encodeUTF16 encodes s as UTF-16 little endian with byte order mark.
+func encodeUTF16(s string) []byte {
+	units := utf16.Encode([]rune("\ufeff" + s))
+	buf := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		buf = append(buf, byte(u), byte(u>>8))
+	}
+	return buf
+}
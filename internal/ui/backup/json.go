@@ -183,6 +183,13 @@ func (b *JSONProgress) Finish(snapshotID restic.ID, start time.Time, summary *ar
 		DataAddedPacked:     summary.ItemStats.DataSizeInRepo + summary.ItemStats.TreeSizeInRepo,
 		TotalFilesProcessed: summary.Files.New + summary.Files.Changed + summary.Files.Unchanged,
 		TotalBytesProcessed: summary.ProcessedBytes,
+		DataFromParent:      summary.ItemStats.DataSizeFromParent,
+		DataFromBackup:      summary.ItemStats.DataSizeFromBackup,
+		DataFromRepo:        summary.ItemStats.DataSizeFromRepo,
+		StreamsNew:          summary.Streams.New,
+		StreamsChanged:      summary.Streams.Changed,
+		StreamsUnmodified:   summary.Streams.Unchanged,
+		StreamBytes:         summary.StreamBytes,
 		TotalDuration:       time.Since(start).Seconds(),
 		SnapshotID:          id,
 		DryRun:              dryRun,
@@ -239,6 +246,13 @@ type summaryOutput struct {
 	DataAddedPacked     uint64  `json:"data_added_packed"`
 	TotalFilesProcessed uint    `json:"total_files_processed"`
 	TotalBytesProcessed uint64  `json:"total_bytes_processed"`
+	DataFromParent      uint64  `json:"data_from_parent"`
+	DataFromBackup      uint64  `json:"data_from_backup"`
+	DataFromRepo        uint64  `json:"data_from_repo"`
+	StreamsNew          uint    `json:"streams_new,omitempty"`
+	StreamsChanged      uint    `json:"streams_changed,omitempty"`
+	StreamsUnmodified   uint    `json:"streams_unmodified,omitempty"`
+	StreamBytes         uint64  `json:"stream_bytes_processed,omitempty"`
 	TotalDuration       float64 `json:"total_duration"` // in seconds
 	SnapshotID          string  `json:"snapshot_id,omitempty"`
 	DryRun              bool    `json:"dry_run,omitempty"`
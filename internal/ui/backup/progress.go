@@ -26,6 +26,9 @@ type ProgressPrinter interface {
 
 type Counter struct {
 	Files, Dirs, Bytes uint64
+	// Streams counts the alternate data streams, which are not included in
+	// Files.
+	Streams uint64
 }
 
 // Progress reports progress for the `backup` command.
@@ -101,6 +104,7 @@ func (p *Progress) addProcessed(c Counter) {
 	p.processed.Files += c.Files
 	p.processed.Dirs += c.Dirs
 	p.processed.Bytes += c.Bytes
+	p.processed.Streams += c.Streams
 	p.estimator.recordBytes(time.Now(), c.Bytes)
 	p.scanStarted = true
 }
@@ -139,8 +143,12 @@ func (p *Progress) CompleteItem(item string, previous, current *restic.Node, s a
 		}
 
 	case "file":
+		c := Counter{Files: 1}
+		if restic.ClassifyNode(current.Name) == restic.StreamNode {
+			c = Counter{Streams: 1}
+		}
 		p.mu.Lock()
-		p.addProcessed(Counter{Files: 1})
+		p.addProcessed(c)
 		delete(p.currentFiles, item)
 		p.mu.Unlock()
 
@@ -160,7 +168,7 @@ func (p *Progress) ReportTotal(item string, s archiver.ScanStats) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.total = Counter{Files: uint64(s.Files), Dirs: uint64(s.Dirs), Bytes: s.Bytes}
+	p.total = Counter{Files: uint64(s.Files), Dirs: uint64(s.Dirs), Bytes: s.Bytes, Streams: uint64(s.Streams)}
 	p.scanStarted = true
 
 	if item == "" {
@@ -169,6 +177,14 @@ func (p *Progress) ReportTotal(item string, s archiver.ScanStats) {
 	}
 }
 
+// Status returns the totals reported by the scanner, the processed items, the
+// number of errors and the number of files currently being read.
+func (p *Progress) Status() (total, processed Counter, errors uint, currentFiles int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.total, p.processed, p.errors, len(p.currentFiles)
+}
+
 // Finish prints the finishing messages.
 func (p *Progress) Finish(snapshotID restic.ID, summary *archiver.Summary, dryrun bool) {
 	// wait for the status update goroutine to shut down
@@ -139,6 +139,10 @@ func (b *TextProgress) Finish(id restic.ID, start time.Time, summary *archiver.S
 	b.P("%s to the repository: %-5s (%-5s stored)\n", verb,
 		ui.FormatBytes(summary.ItemStats.DataSize+summary.ItemStats.TreeSize),
 		ui.FormatBytes(summary.ItemStats.DataSizeInRepo+summary.ItemStats.TreeSizeInRepo))
+	if stats := summary.ItemStats; stats.DataSizeFromParent+stats.DataSizeFromBackup+stats.DataSizeFromRepo > 0 {
+		b.P("Deduplicated: %-5s from the parent snapshot, %-5s within this backup, %-5s from other files and snapshots\n",
+			ui.FormatBytes(stats.DataSizeFromParent), ui.FormatBytes(stats.DataSizeFromBackup), ui.FormatBytes(stats.DataSizeFromRepo))
+	}
 	b.P("\n")
 	b.P("processed %v files, %v in %s",
 		summary.Files.New+summary.Files.Changed+summary.Files.Unchanged,
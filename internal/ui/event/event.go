@@ -0,0 +1,184 @@
+// Package event defines the versioned schema of the JSON events which
+// commands print when the --json flag is used, and a printer which emits them.
+//
+// Every event is printed as a single line and contains the header fields
+// message_type, schema_version and command. The schema version is increased
+// whenever a field is removed or changes its meaning; adding message types or
+// fields does not change the version.
+package event
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// SchemaVersion is the version of the event schema.
+const SchemaVersion = 1
+
+// Header contains the fields common to all events.
+type Header struct {
+	MessageType   string `json:"message_type"`
+	SchemaVersion int    `json:"schema_version"`
+	Command       string `json:"command"`
+}
+
+func (h *Header) header() *Header {
+	return h
+}
+
+// Event is implemented by all events, which embed a Header.
+type Event interface {
+	header() *Header
+}
+
+// Status reports the progress of a step of the command.
+type Status struct {
+	Header
+	Step           string  `json:"step"`
+	Current        uint64  `json:"current"`
+	Total          uint64  `json:"total,omitempty"`
+	PercentDone    float64 `json:"percent_done,omitempty"`
+	SecondsElapsed uint64  `json:"seconds_elapsed"`
+	Final          bool    `json:"final,omitempty"`
+}
+
+// Message is an informational message, its level is "info", "verbose" or
+// "debug".
+type Message struct {
+	Header
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// Problem is a warning or an error. Errors are printed to stderr, warnings
+// report non-critical problems and are printed to stdout.
+type Problem struct {
+	Header
+	Message string `json:"message"`
+}
+
+// Output is where events are printed to, it is implemented by
+// termstatus.Terminal.
+type Output interface {
+	Print(line string)
+	Error(line string)
+}
+
+// Printer emits events for the messages and counters of a command. It
+// implements progress.Printer.
+type Printer struct {
+	out       Output
+	command   string
+	verbosity uint
+	interval  time.Duration
+}
+
+var _ progress.Printer = (*Printer)(nil)
+
+// NewPrinter returns a printer for the events of command. Messages are only
+// emitted if the verbosity is high enough, like for ui.Message. Counters
+// report their status every interval and when they are done, no status is
+// reported if verbosity is zero.
+func NewPrinter(out Output, command string, verbosity uint, interval time.Duration) *Printer {
+	return &Printer{
+		out:       out,
+		command:   command,
+		verbosity: verbosity,
+		interval:  interval,
+	}
+}
+
+func (p *Printer) fill(messageType string, e Event) {
+	h := e.header()
+	h.MessageType = messageType
+	h.SchemaVersion = SchemaVersion
+	h.Command = p.command
+}
+
+// Print prints the event using the given message type.
+func (p *Printer) Print(messageType string, e Event) {
+	p.fill(messageType, e)
+	p.out.Print(ui.ToJSONString(e))
+}
+
+// Summary prints the summary of the command. The summary is a command
+// specific struct which embeds Header.
+func (p *Printer) Summary(e Event) {
+	p.Print("summary", e)
+}
+
+// Warn prints a warning.
+func (p *Printer) Warn(msg string, args ...interface{}) {
+	p.Print("warning", &Problem{Message: format(msg, args...)})
+}
+
+// NewCounter returns a counter which reports its value as the status of the
+// step description.
+func (p *Printer) NewCounter(description string) *progress.Counter {
+	if p.verbosity == 0 {
+		return nil
+	}
+	return progress.NewCounter(p.interval, 0, func(value uint64, total uint64, d time.Duration, final bool) {
+		s := &Status{
+			Step:           description,
+			Current:        value,
+			Total:          total,
+			SecondsElapsed: uint64(d / time.Second),
+			Final:          final,
+		}
+		if total > 0 {
+			s.PercentDone = float64(value) / float64(total)
+		}
+		p.Print("status", s)
+	})
+}
+
+// E prints an error to stderr.
+func (p *Printer) E(msg string, args ...interface{}) {
+	msg = format(msg, args...)
+	if msg == "" {
+		return
+	}
+	e := &Problem{Message: msg}
+	p.fill("error", e)
+	p.out.Error(ui.ToJSONString(e))
+}
+
+func (p *Printer) message(level string, msg string, args ...interface{}) {
+	msg = format(msg, args...)
+	if msg == "" {
+		return
+	}
+	p.Print("message", &Message{Level: level, Message: msg})
+}
+
+// P prints an informational message if verbosity >= 1.
+func (p *Printer) P(msg string, args ...interface{}) {
+	if p.verbosity >= 1 {
+		p.message("info", msg, args...)
+	}
+}
+
+// V prints a verbose message if verbosity >= 2.
+func (p *Printer) V(msg string, args ...interface{}) {
+	if p.verbosity >= 2 {
+		p.message("verbose", msg, args...)
+	}
+}
+
+// VV prints a debug message if verbosity >= 3.
+func (p *Printer) VV(msg string, args ...interface{}) {
+	if p.verbosity >= 3 {
+		p.message("debug", msg, args...)
+	}
+}
+
+// format formats the message and strips the surrounding line breaks, which
+// are only used to layout the text output.
+func format(msg string, args ...interface{}) string {
+	return strings.Trim(fmt.Sprintf(msg, args...), "\n")
+}
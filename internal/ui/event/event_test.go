@@ -0,0 +1,100 @@
+package event
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type testOutput struct {
+	mu     sync.Mutex
+	stdout []map[string]interface{}
+	stderr []map[string]interface{}
+}
+
+func (o *testOutput) add(events *[]map[string]interface{}, line string) {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		panic(err)
+	}
+	o.mu.Lock()
+	*events = append(*events, m)
+	o.mu.Unlock()
+}
+
+func (o *testOutput) Print(line string) {
+	o.add(&o.stdout, line)
+}
+
+func (o *testOutput) Error(line string) {
+	o.add(&o.stderr, line)
+}
+
+func TestPrinterMessages(t *testing.T) {
+	out := &testOutput{}
+	p := NewPrinter(out, "check", 2, 0)
+
+	p.P("\nload indexes\n")
+	p.V("verbose %d\n", 2)
+	p.VV("not printed\n")
+	p.P("\n")
+	p.Warn("duplicate pack %v", "abc")
+	p.E("error: %v\n", "broken")
+
+	rtest.Equals(t, []map[string]interface{}{
+		{"message_type": "message", "schema_version": float64(SchemaVersion), "command": "check", "level": "info", "message": "load indexes"},
+		{"message_type": "message", "schema_version": float64(SchemaVersion), "command": "check", "level": "verbose", "message": "verbose 2"},
+		{"message_type": "warning", "schema_version": float64(SchemaVersion), "command": "check", "message": "duplicate pack abc"},
+	}, out.stdout)
+	rtest.Equals(t, []map[string]interface{}{
+		{"message_type": "error", "schema_version": float64(SchemaVersion), "command": "check", "message": "error: broken"},
+	}, out.stderr)
+}
+
+func TestPrinterQuiet(t *testing.T) {
+	out := &testOutput{}
+	p := NewPrinter(out, "prune", 0, 0)
+
+	p.P("message")
+	rtest.Assert(t, p.NewCounter("packs") == nil, "expected no counter")
+	p.E("error")
+
+	rtest.Equals(t, 0, len(out.stdout))
+	rtest.Equals(t, 1, len(out.stderr))
+}
+
+func TestPrinterCounter(t *testing.T) {
+	out := &testOutput{}
+	p := NewPrinter(out, "copy", 1, 0)
+
+	c := p.NewCounter("packs copied")
+	c.SetMax(4)
+	c.Add(1)
+	c.Done()
+
+	rtest.Equals(t, 1, len(out.stdout))
+	status := out.stdout[0]
+	rtest.Equals(t, "status", status["message_type"])
+	rtest.Equals(t, "packs copied", status["step"])
+	rtest.Equals(t, float64(1), status["current"])
+	rtest.Equals(t, float64(4), status["total"])
+	rtest.Equals(t, 0.25, status["percent_done"])
+	rtest.Equals(t, true, status["final"])
+}
+
+func TestPrinterSummary(t *testing.T) {
+	type summary struct {
+		Header
+		Removed int `json:"removed"`
+	}
+
+	out := &testOutput{}
+	p := NewPrinter(out, "forget", 0, 0)
+	p.Summary(&summary{Removed: 3})
+
+	rtest.Equals(t, []map[string]interface{}{
+		{"message_type": "summary", "schema_version": float64(SchemaVersion), "command": "forget", "removed": float64(3)},
+	}, out.stdout)
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+)
+
+// Backend records the latency, errors and number of in-flight requests of the
+// wrapped backend.
+type Backend struct {
+	backend.Backend
+}
+
+// statically ensure that Backend implements backend.Backend.
+var _ backend.Backend = &Backend{}
+
+// NewBackend wraps be such that its requests are recorded in the default
+// registry.
+func NewBackend(be backend.Backend) *Backend {
+	return &Backend{Backend: be}
+}
+
+func (be *Backend) observe(op string, fn func() error) error {
+	backendInFlight.Add(1, op)
+	start := time.Now()
+	err := fn()
+	backendDuration.Observe(time.Since(start).Seconds(), op)
+	backendInFlight.Add(-1, op)
+	if err != nil && !be.Backend.IsNotExist(err) {
+		backendErrors.Add(1, op)
+	}
+	return err
+}
+
+// Save records the duration of saving the file.
+func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	return be.observe("save", func() error {
+		return be.Backend.Save(ctx, h, rd)
+	})
+}
+
+// Remove records the duration of removing the file.
+func (be *Backend) Remove(ctx context.Context, h backend.Handle) error {
+	return be.observe("remove", func() error {
+		return be.Backend.Remove(ctx, h)
+	})
+}
+
+// Load records the duration of loading the file, including the time spent in fn.
+func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(io.Reader) error) error {
+	return be.observe("load", func() error {
+		return be.Backend.Load(ctx, h, length, offset, fn)
+	})
+}
+
+// Stat records the duration of the request for the file information.
+func (be *Backend) Stat(ctx context.Context, h backend.Handle) (fi backend.FileInfo, err error) {
+	err = be.observe("stat", func() error {
+		fi, err = be.Backend.Stat(ctx, h)
+		return err
+	})
+	return fi, err
+}
+
+// List records the duration of listing the files, including the time spent
+// in fn.
+func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	return be.observe("list", func() error {
+		return be.Backend.List(ctx, t, fn)
+	})
+}
+
+func (be *Backend) Unwrap() backend.Backend { return be.Backend }
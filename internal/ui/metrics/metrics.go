@@ -0,0 +1,264 @@
+// Package metrics exports the progress of long-running operations in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the buckets used for
+// latency histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// A Registry holds a set of metrics. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// family is a metric with all its label combinations.
+type family struct {
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	buckets []float64
+
+	reg    *Registry
+	series map[string]*series
+}
+
+// series is a single time series of a family.
+type series struct {
+	labelValues []string
+
+	value float64
+	fn    func() float64
+
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (r *Registry) register(name, help string, typ metricType, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, f := range r.families {
+		if f.name == name {
+			panic(fmt.Sprintf("metric %v registered twice", name))
+		}
+	}
+	f := &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		reg:     r,
+		series:  make(map[string]*series),
+	}
+	r.families = append(r.families, f)
+	return f
+}
+
+// get returns the series for the label values, r.mu must be held by the caller.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %v expects %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\x00")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.typ == typeHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct{ f *family }
+
+// NewCounter registers a new counter. The name should end in "_total".
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{r.register(name, help, typeCounter, nil, labels)}
+}
+
+// Add increases the counter for the label values by v, which must not be
+// negative.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("counter cannot decrease")
+	}
+	c.f.reg.mu.Lock()
+	c.f.get(labelValues).value += v
+	c.f.reg.mu.Unlock()
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct{ f *family }
+
+// NewGauge registers a new gauge.
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{r.register(name, help, typeGauge, nil, labels)}
+}
+
+// Set sets the gauge for the label values to v.
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.f.reg.mu.Lock()
+	s := g.f.get(labelValues)
+	s.value, s.fn = v, nil
+	g.f.reg.mu.Unlock()
+}
+
+// Add adds v to the gauge for the label values, v may be negative.
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.f.reg.mu.Lock()
+	g.f.get(labelValues).value += v
+	g.f.reg.mu.Unlock()
+}
+
+// SetFunc sets the gauge for the label values to the value returned by fn,
+// which is called each time the metrics are written. fn must not access the
+// registry.
+func (g *GaugeVec) SetFunc(fn func() float64, labelValues ...string) {
+	g.f.reg.mu.Lock()
+	g.f.get(labelValues).fn = fn
+	g.f.reg.mu.Unlock()
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct{ f *family }
+
+// NewHistogram registers a new histogram with the given bucket upper bounds,
+// which must be sorted in increasing order.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of metric %v are not sorted", name))
+	}
+	return &HistogramVec{r.register(name, help, typeHistogram, buckets, labels)}
+}
+
+// Observe adds the value v to the histogram for the label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.f.reg.mu.Lock()
+	defer h.f.reg.mu.Unlock()
+
+	s := h.f.get(labelValues)
+	for i, le := range h.f.buckets {
+		if v <= le {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Write writes all metrics in the Prometheus text exposition format. Series
+// are sorted by their label values.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wr := bufio.NewWriter(w)
+	for _, f := range r.families {
+		if len(f.series) == 0 {
+			continue
+		}
+		fmt.Fprintf(wr, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(wr, "# TYPE %s %s\n", f.name, f.typ)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			switch f.typ {
+			case typeHistogram:
+				for i, le := range f.buckets {
+					writeSample(wr, f.name+"_bucket", f.labels, s.labelValues, "le", formatFloat(le), float64(s.counts[i]))
+				}
+				writeSample(wr, f.name+"_bucket", f.labels, s.labelValues, "le", "+Inf", float64(s.count))
+				writeSample(wr, f.name+"_sum", f.labels, s.labelValues, "", "", s.sum)
+				writeSample(wr, f.name+"_count", f.labels, s.labelValues, "", "", float64(s.count))
+			default:
+				v := s.value
+				if s.fn != nil {
+					v = s.fn()
+				}
+				writeSample(wr, f.name, f.labels, s.labelValues, "", "", v)
+			}
+		}
+	}
+	return wr.Flush()
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	_, _ = w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		_ = w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabelValue(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				_ = w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		_ = w.WriteByte('}')
+	}
+	fmt.Fprintf(w, " %s\n", formatFloat(v))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_requests_total", "Number of requests.", "method")
+	g := r.NewGauge("test_queue", "Queue \\ length.\nSecond line.")
+	h := r.NewHistogram("test_duration_seconds", "Duration.", []float64{0.1, 1}, "op")
+	r.NewGauge("test_unused", "Never set.")
+
+	c.Add(2, "get")
+	c.Add(1, `p"o\st`)
+	c.Add(1, "get")
+	g.Set(5)
+	g.Add(-2)
+	h.Observe(0.05, "load")
+	h.Observe(0.5, "load")
+	h.Observe(3, "load")
+
+	var buf bytes.Buffer
+	rtest.OK(t, r.Write(&buf))
+	rtest.Equals(t, `# HELP test_requests_total Number of requests.
+# TYPE test_requests_total counter
+test_requests_total{method="get"} 3
+test_requests_total{method="p\"o\\st"} 1
+# HELP test_queue Queue \\ length.\nSecond line.
+# TYPE test_queue gauge
+test_queue 3
+# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="load",le="0.1"} 1
+test_duration_seconds_bucket{op="load",le="1"} 2
+test_duration_seconds_bucket{op="load",le="+Inf"} 3
+test_duration_seconds_sum{op="load"} 3.55
+test_duration_seconds_count{op="load"} 3
+`, buf.String())
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("test_value", "Value.", "name")
+
+	v := 1.0
+	g.SetFunc(func() float64 { return v }, "a")
+	g.Set(7, "b")
+
+	var buf bytes.Buffer
+	rtest.OK(t, r.Write(&buf))
+	rtest.Assert(t, strings.Contains(buf.String(), "test_value{name=\"a\"} 1\n"), "missing value in %q", buf.String())
+
+	v = 2.5
+	buf.Reset()
+	rtest.OK(t, r.Write(&buf))
+	rtest.Assert(t, strings.Contains(buf.String(), "test_value{name=\"a\"} 2.5\n"), "missing value in %q", buf.String())
+	rtest.Assert(t, strings.Contains(buf.String(), "test_value{name=\"b\"} 7\n"), "missing value in %q", buf.String())
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("test_value", "Value.").Set(42)
+
+	srv := httptest.NewServer(r.Handler())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	rtest.OK(t, err)
+	body, err := io.ReadAll(res.Body)
+	rtest.OK(t, err)
+	rtest.OK(t, res.Body.Close())
+	rtest.Equals(t, http.StatusOK, res.StatusCode)
+	rtest.Assert(t, strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain"), "wrong content type %v", res.Header.Get("Content-Type"))
+	rtest.Assert(t, strings.Contains(string(body), "test_value 42\n"), "missing value in %q", body)
+
+	res, err = http.Post(srv.URL, "text/plain", nil)
+	rtest.OK(t, err)
+	rtest.OK(t, res.Body.Close())
+	rtest.Equals(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
+
+func TestServe(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("test_value", "Value.").Set(1)
+
+	stop, err := Serve("127.0.0.1:0", r)
+	rtest.OK(t, err)
+	rtest.OK(t, stop())
+
+	_, err = Serve("127.0.0.1:invalid", r)
+	rtest.Assert(t, err != nil, "invalid address was accepted")
+}
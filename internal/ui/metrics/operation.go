@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/restic/restic/internal/ui/backup"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/restic/restic/internal/ui/restore"
+)
+
+// Default is the registry which contains the metrics of the running
+// operation.
+var Default = NewRegistry()
+
+var (
+	operationStart = Default.NewGauge("restic_operation_start_time_seconds",
+		"Start time of the operation since the Unix epoch in seconds.", "operation")
+	filesProcessed = Default.NewGauge("restic_files_processed",
+		"Number of files processed by the operation.", "operation")
+	filesExpected = Default.NewGauge("restic_files_expected",
+		"Number of files the operation is expected to process.", "operation")
+	bytesProcessed = Default.NewGauge("restic_bytes_processed",
+		"Number of bytes processed by the operation.", "operation")
+	bytesExpected = Default.NewGauge("restic_bytes_expected",
+		"Number of bytes the operation is expected to process.", "operation")
+	errorCount = Default.NewGauge("restic_errors",
+		"Number of errors the operation encountered.", "operation")
+	itemsProcessed = Default.NewGauge("restic_items_processed",
+		"Number of items processed by a step of the operation.", "operation", "step")
+	itemsExpected = Default.NewGauge("restic_items_expected",
+		"Number of items a step of the operation is expected to process, 0 if unknown.", "operation", "step")
+	queueDepth = Default.NewGauge("restic_queue_depth",
+		"Number of items in an internal queue of the operation.", "operation", "queue")
+
+	backendDuration = Default.NewHistogram("restic_backend_request_duration_seconds",
+		"Duration of backend requests in seconds.", DefaultBuckets, "operation")
+	backendErrors = Default.NewCounter("restic_backend_request_errors_total",
+		"Number of failed backend requests.", "operation")
+	backendInFlight = Default.NewGauge("restic_backend_requests_in_flight",
+		"Number of backend requests which are currently running.", "operation")
+)
+
+// StartOperation records the start time of the operation.
+func StartOperation(operation string) {
+	operationStart.Set(float64(time.Now().UnixNano())/1e9, operation)
+}
+
+// CountError increases the number of errors of the operation.
+func CountError(operation string) {
+	errorCount.Add(1, operation)
+}
+
+// ObserveBackup exports the progress of the backup.
+func ObserveBackup(p *backup.Progress) {
+	const op = "backup"
+	StartOperation(op)
+
+	type status struct {
+		total, processed backup.Counter
+		errors           uint
+		currentFiles     int
+	}
+	get := func(fn func(s status) uint64) func() float64 {
+		return func() float64 {
+			var s status
+			s.total, s.processed, s.errors, s.currentFiles = p.Status()
+			return float64(fn(s))
+		}
+	}
+	filesProcessed.SetFunc(get(func(s status) uint64 { return s.processed.Files }), op)
+	filesExpected.SetFunc(get(func(s status) uint64 { return s.total.Files }), op)
+	bytesProcessed.SetFunc(get(func(s status) uint64 { return s.processed.Bytes }), op)
+	bytesExpected.SetFunc(get(func(s status) uint64 { return s.total.Bytes }), op)
+	errorCount.SetFunc(get(func(s status) uint64 { return uint64(s.errors) }), op)
+	queueDepth.SetFunc(get(func(s status) uint64 { return uint64(s.currentFiles) }), op, "files_in_progress")
+}
+
+// ObserveRestore exports the progress of the restore.
+func ObserveRestore(p *restore.Progress) {
+	const op = "restore"
+	StartOperation(op)
+
+	state := func(fn func(s restore.State) uint64) func() float64 {
+		return func() float64 {
+			return float64(fn(p.State()))
+		}
+	}
+	filesProcessed.SetFunc(state(func(s restore.State) uint64 { return s.FilesFinished + s.FilesSkipped }), op)
+	filesExpected.SetFunc(state(func(s restore.State) uint64 { return s.FilesTotal }), op)
+	bytesProcessed.SetFunc(state(func(s restore.State) uint64 { return s.AllBytesWritten + s.AllBytesSkipped }), op)
+	bytesExpected.SetFunc(state(func(s restore.State) uint64 { return s.AllBytesTotal }), op)
+	queueDepth.SetFunc(state(func(s restore.State) uint64 { return s.PacksInFlight }), op, "packs_in_flight")
+}
+
+// printer exports the counters created by the wrapped printer.
+type printer struct {
+	progress.Printer
+	operation string
+}
+
+// NewPrinter returns a printer which exports the value of all counters
+// created using NewCounter as the step of the operation named by their
+// description.
+func NewPrinter(p progress.Printer, operation string) progress.Printer {
+	StartOperation(operation)
+	return &printer{Printer: p, operation: operation}
+}
+
+func (p *printer) NewCounter(description string) *progress.Counter {
+	c := p.Printer.NewCounter(description)
+	if c == nil {
+		// the wrapped printer does not show progress, use a counter which
+		// only tracks the value
+		c = progress.NewCounter(0, 0, func(uint64, uint64, time.Duration, bool) {})
+	}
+	itemsProcessed.SetFunc(func() float64 {
+		v, _ := c.Get()
+		return float64(v)
+	}, p.operation, description)
+	itemsExpected.SetFunc(func() float64 {
+		_, max := c.Get()
+		return float64(max)
+	}, p.operation, description)
+	return c
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func writeDefault(t *testing.T) string {
+	var buf bytes.Buffer
+	rtest.OK(t, Default.Write(&buf))
+	return buf.String()
+}
+
+func TestPrinter(t *testing.T) {
+	p := NewPrinter(&progress.NoopPrinter{}, "prune")
+	c := p.NewCounter("packs processed")
+	c.SetMax(10)
+	c.Add(3)
+
+	out := writeDefault(t)
+	rtest.Assert(t, strings.Contains(out, `restic_items_processed{operation="prune",step="packs processed"} 3`+"\n"), "missing processed items in %q", out)
+	rtest.Assert(t, strings.Contains(out, `restic_items_expected{operation="prune",step="packs processed"} 10`+"\n"), "missing expected items in %q", out)
+	rtest.Assert(t, strings.Contains(out, `restic_operation_start_time_seconds{operation="prune"}`), "missing start time in %q", out)
+	c.Done()
+}
+
+func TestBackend(t *testing.T) {
+	be := NewBackend(mem.New())
+	h := backend.Handle{Type: restic.PackFile, Name: restic.NewRandomID().String()}
+	data := []byte("foobar")
+
+	rtest.OK(t, be.Save(context.TODO(), h, backend.NewByteReader(data, be.Hasher())))
+	_, err := be.Stat(context.TODO(), h)
+	rtest.OK(t, err)
+	_, err = be.Stat(context.TODO(), backend.Handle{Type: restic.PackFile, Name: "missing"})
+	rtest.Assert(t, be.IsNotExist(err), "unexpected error %v", err)
+	rtest.OK(t, be.Remove(context.TODO(), h))
+	err = be.Remove(context.TODO(), h)
+	rtest.Assert(t, err != nil, "removing missing file succeeded")
+
+	out := writeDefault(t)
+	for _, line := range []string{
+		`restic_backend_request_duration_seconds_count{operation="save"} 1`,
+		`restic_backend_request_duration_seconds_count{operation="stat"} 2`,
+		`restic_backend_requests_in_flight{operation="save"} 0`,
+	} {
+		rtest.Assert(t, strings.Contains(out, line+"\n"), "missing %q in %q", line, out)
+	}
+	// missing files are not counted as errors
+	rtest.Assert(t, !strings.Contains(out, `restic_backend_request_errors_total{operation="stat"}`), "missing file counted as error in %q", out)
+}
@@ -7,12 +7,14 @@ import (
 )
 
 type jsonPrinter struct {
-	terminal term
+	terminal  term
+	verbosity uint
 }
 
-func NewJSONProgress(terminal term) ProgressPrinter {
+func NewJSONProgress(terminal term, verbosity uint) ProgressPrinter {
 	return &jsonPrinter{
-		terminal: terminal,
+		terminal:  terminal,
+		verbosity: verbosity,
 	}
 }
 
@@ -30,6 +32,19 @@ func (t *jsonPrinter) Update(p State, duration time.Duration) {
 		TotalBytes:     p.AllBytesTotal,
 		BytesRestored:  p.AllBytesWritten,
 		BytesSkipped:   p.AllBytesSkipped,
+		PacksInFlight:  p.PacksInFlight,
+	}
+	for _, w := range p.Workers {
+		status.Workers = append(status.Workers, workerStatus{
+			ID:             w.ID,
+			Pack:           w.Pack,
+			CurrentFile:    w.File,
+			BytesRestored:  w.BytesWritten,
+			BytesPerSecond: w.BytesPerSecond,
+			Stalled:        w.Stalled,
+			SecondsIdle:    uint64(w.Idle / time.Second),
+			LastError:      w.LastError,
+		})
 	}
 
 	if p.AllBytesTotal > 0 {
@@ -41,37 +56,76 @@ func (t *jsonPrinter) Update(p State, duration time.Duration) {
 
 func (t *jsonPrinter) Finish(p State, duration time.Duration) {
 	status := summaryOutput{
-		MessageType:    "summary",
-		SecondsElapsed: uint64(duration / time.Second),
-		TotalFiles:     p.FilesTotal,
-		FilesRestored:  p.FilesFinished,
-		FilesSkipped:   p.FilesSkipped,
-		TotalBytes:     p.AllBytesTotal,
-		BytesRestored:  p.AllBytesWritten,
-		BytesSkipped:   p.AllBytesSkipped,
+		MessageType:     "summary",
+		SecondsElapsed:  uint64(duration / time.Second),
+		TotalFiles:      p.FilesTotal,
+		FilesRestored:   p.FilesFinished,
+		FilesSkipped:    p.FilesSkipped,
+		TotalBytes:      p.AllBytesTotal,
+		BytesRestored:   p.AllBytesWritten,
+		BytesSkipped:    p.AllBytesSkipped,
+		TotalStreams:    p.StreamsTotal,
+		StreamsRestored: p.StreamsFinished,
+		StreamsSkipped:  p.StreamsSkipped,
 	}
 	t.print(status)
 }
 
+func (t *jsonPrinter) ReportItem(action ItemAction, item string, size uint64) {
+	if t.verbosity < 2 {
+		return
+	}
+
+	t.print(verboseUpdate{
+		MessageType: "verbose_status",
+		Action:      string(action),
+		Item:        item,
+		Size:        size,
+	})
+}
+
 type statusUpdate struct {
-	MessageType    string  `json:"message_type"` // "status"
-	SecondsElapsed uint64  `json:"seconds_elapsed,omitempty"`
-	PercentDone    float64 `json:"percent_done"`
-	TotalFiles     uint64  `json:"total_files,omitempty"`
-	FilesRestored  uint64  `json:"files_restored,omitempty"`
-	FilesSkipped   uint64  `json:"files_skipped,omitempty"`
-	TotalBytes     uint64  `json:"total_bytes,omitempty"`
-	BytesRestored  uint64  `json:"bytes_restored,omitempty"`
-	BytesSkipped   uint64  `json:"bytes_skipped,omitempty"`
+	MessageType    string         `json:"message_type"` // "status"
+	SecondsElapsed uint64         `json:"seconds_elapsed,omitempty"`
+	PercentDone    float64        `json:"percent_done"`
+	TotalFiles     uint64         `json:"total_files,omitempty"`
+	FilesRestored  uint64         `json:"files_restored,omitempty"`
+	FilesSkipped   uint64         `json:"files_skipped,omitempty"`
+	TotalBytes     uint64         `json:"total_bytes,omitempty"`
+	BytesRestored  uint64         `json:"bytes_restored,omitempty"`
+	BytesSkipped   uint64         `json:"bytes_skipped,omitempty"`
+	PacksInFlight  uint64         `json:"packs_in_flight,omitempty"`
+	Workers        []workerStatus `json:"workers,omitempty"`
 }
 
-type summaryOutput struct {
-	MessageType    string `json:"message_type"` // "summary"
-	SecondsElapsed uint64 `json:"seconds_elapsed,omitempty"`
-	TotalFiles     uint64 `json:"total_files,omitempty"`
-	FilesRestored  uint64 `json:"files_restored,omitempty"`
-	FilesSkipped   uint64 `json:"files_skipped,omitempty"`
-	TotalBytes     uint64 `json:"total_bytes,omitempty"`
+type workerStatus struct {
+	ID             int    `json:"id"`
+	Pack           string `json:"pack,omitempty"`
+	CurrentFile    string `json:"current_file,omitempty"`
 	BytesRestored  uint64 `json:"bytes_restored,omitempty"`
-	BytesSkipped   uint64 `json:"bytes_skipped,omitempty"`
+	BytesPerSecond uint64 `json:"bytes_per_second,omitempty"`
+	Stalled        bool   `json:"stalled,omitempty"`
+	SecondsIdle    uint64 `json:"seconds_idle,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+}
+
+type verboseUpdate struct {
+	MessageType string `json:"message_type"` // "verbose_status"
+	Action      string `json:"action"`
+	Item        string `json:"item"`
+	Size        uint64 `json:"size"`
+}
+
+type summaryOutput struct {
+	MessageType     string `json:"message_type"` // "summary"
+	SecondsElapsed  uint64 `json:"seconds_elapsed,omitempty"`
+	TotalFiles      uint64 `json:"total_files,omitempty"`
+	FilesRestored   uint64 `json:"files_restored,omitempty"`
+	FilesSkipped    uint64 `json:"files_skipped,omitempty"`
+	TotalBytes      uint64 `json:"total_bytes,omitempty"`
+	BytesRestored   uint64 `json:"bytes_restored,omitempty"`
+	BytesSkipped    uint64 `json:"bytes_skipped,omitempty"`
+	TotalStreams    uint64 `json:"total_streams,omitempty"`
+	StreamsRestored uint64 `json:"streams_restored,omitempty"`
+	StreamsSkipped  uint64 `json:"streams_skipped,omitempty"`
 }
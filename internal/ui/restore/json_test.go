@@ -9,35 +9,65 @@ import (
 
 func TestJSONPrintUpdate(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewJSONProgress(term)
-	printer.Update(State{3, 11, 0, 29, 47, 0}, 5*time.Second)
+	printer := NewJSONProgress(term, 1)
+	printer.Update(State{3, 11, 0, 29, 47, 0, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"status\",\"seconds_elapsed\":5,\"percent_done\":0.6170212765957447,\"total_files\":11,\"files_restored\":3,\"total_bytes\":47,\"bytes_restored\":29}\n"}, term.output)
 }
 
 func TestJSONPrintUpdateWithSkipped(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewJSONProgress(term)
-	printer.Update(State{3, 11, 2, 29, 47, 59}, 5*time.Second)
+	printer := NewJSONProgress(term, 1)
+	printer.Update(State{3, 11, 2, 29, 47, 59, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"status\",\"seconds_elapsed\":5,\"percent_done\":0.6170212765957447,\"total_files\":11,\"files_restored\":3,\"files_skipped\":2,\"total_bytes\":47,\"bytes_restored\":29,\"bytes_skipped\":59}\n"}, term.output)
 }
 
 func TestJSONPrintSummaryOnSuccess(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewJSONProgress(term)
-	printer.Finish(State{11, 11, 0, 47, 47, 0}, 5*time.Second)
+	printer := NewJSONProgress(term, 1)
+	printer.Finish(State{11, 11, 0, 47, 47, 0, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"total_bytes\":47,\"bytes_restored\":47}\n"}, term.output)
 }
 
 func TestJSONPrintSummaryOnErrors(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewJSONProgress(term)
-	printer.Finish(State{3, 11, 0, 29, 47, 0}, 5*time.Second)
+	printer := NewJSONProgress(term, 1)
+	printer.Finish(State{3, 11, 0, 29, 47, 0, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":3,\"total_bytes\":47,\"bytes_restored\":29}\n"}, term.output)
 }
 
 func TestJSONPrintSummaryOnSuccessWithSkipped(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewJSONProgress(term)
-	printer.Finish(State{11, 11, 2, 47, 47, 59}, 5*time.Second)
+	printer := NewJSONProgress(term, 1)
+	printer.Finish(State{11, 11, 2, 47, 47, 59, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"files_skipped\":2,\"total_bytes\":47,\"bytes_restored\":47,\"bytes_skipped\":59}\n"}, term.output)
 }
+
+func TestJSONPrintSummaryStreams(t *testing.T) {
+	term := &mockTerm{}
+	printer := NewJSONProgress(term, 1)
+	printer.Finish(State{11, 11, 0, 47, 47, 0, 2, 3, 1, 0, nil}, 5*time.Second)
+	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"total_bytes\":47,\"bytes_restored\":47,\"total_streams\":3,\"streams_restored\":2,\"streams_skipped\":1}\n"}, term.output)
+}
+
+func TestJSONPrintUpdateWithWorkers(t *testing.T) {
+	term := &mockTerm{}
+	printer := NewJSONProgress(term, 1)
+	printer.Update(State{3, 11, 0, 29, 47, 0, 0, 0, 0, 1, []WorkerState{
+		{ID: 1, Pack: "11111111", File: "file", BytesWritten: 29, BytesPerSecond: 5, Stalled: true, Idle: 31 * time.Second},
+	}}, 5*time.Second)
+	test.Equals(t, []string{"{\"message_type\":\"status\",\"seconds_elapsed\":5,\"percent_done\":0.6170212765957447,\"total_files\":11,\"files_restored\":3,\"total_bytes\":47,\"bytes_restored\":29,\"packs_in_flight\":1,\"workers\":[{\"id\":1,\"pack\":\"11111111\",\"current_file\":\"file\",\"bytes_restored\":29,\"bytes_per_second\":5,\"stalled\":true,\"seconds_idle\":31}]}\n"}, term.output)
+}
+
+func TestJSONPrintReportItem(t *testing.T) {
+	term := &mockTerm{}
+	NewJSONProgress(term, 1).ReportItem(ActionSkip, "/file", 42)
+	test.Equals(t, []string(nil), term.output)
+
+	printer := NewJSONProgress(term, 2)
+	printer.ReportItem(ActionUpdate, "/file", 42)
+	printer.ReportItem(ActionConflict, "/link", 0)
+	test.Equals(t, []string{
+		"{\"message_type\":\"verbose_status\",\"action\":\"update\",\"item\":\"/file\",\"size\":42}\n",
+		"{\"message_type\":\"verbose_status\",\"action\":\"conflict\",\"item\":\"/link\",\"size\":0}\n",
+	}, term.output)
+}
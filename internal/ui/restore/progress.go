@@ -1,9 +1,12 @@
 package restore
 
 import (
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui/progress"
 )
 
@@ -14,6 +17,39 @@ type State struct {
 	AllBytesWritten uint64
 	AllBytesTotal   uint64
 	AllBytesSkipped uint64
+
+	// Alternate data streams are counted separately from the files. Their
+	// size is included in the AllBytes counters.
+	StreamsFinished uint64
+	StreamsTotal    uint64
+	StreamsSkipped  uint64
+
+	// PacksInFlight is the number of packs currently being downloaded.
+	PacksInFlight uint64
+	// Workers contains the state of all workers which are busy restoring a
+	// pack or have encountered an error, sorted by their ID.
+	Workers []WorkerState
+}
+
+// WorkerState describes what a single restore worker is currently doing.
+type WorkerState struct {
+	ID int
+	// Pack is the short ID of the pack being downloaded, empty if the worker
+	// is idle.
+	Pack string
+	// File is the file the worker has written to most recently.
+	File string
+	// BytesWritten is the number of bytes restored from the current pack.
+	BytesWritten uint64
+	// BytesPerSecond is the restore rate for the current pack.
+	BytesPerSecond uint64
+	// Stalled is set if the worker has not made progress for some time. Idle
+	// is the duration since the last progress.
+	Stalled bool
+	Idle    time.Duration
+	// LastError is the most recent error the worker encountered, for example
+	// when the backend could not provide a pack.
+	LastError string
 }
 
 type Progress struct {
@@ -21,8 +57,12 @@ type Progress struct {
 	m       sync.Mutex
 
 	progressInfoMap map[string]progressInfoEntry
+	workers         map[int]*workerInfo
 	s               State
 	started         time.Time
+	// stallTimeout is the time after which a worker without progress is
+	// reported as stalled.
+	stallTimeout time.Duration
 
 	printer ProgressPrinter
 }
@@ -32,20 +72,63 @@ type progressInfoEntry struct {
 	bytesTotal   uint64
 }
 
+// isStream reports whether name is the name or path of an alternate data
+// stream.
+func isStream(name string) bool {
+	return restic.ClassifyNode(filepath.Base(name)) == restic.StreamNode
+}
+
+type workerInfo struct {
+	pack         string
+	file         string
+	bytesWritten uint64
+	start        time.Time
+	lastActivity time.Time
+	lastError    string
+	// failed is set if an error occurred while restoring the current pack
+	failed bool
+}
+
+// defaultStallTimeout is the time without progress after which a worker is
+// reported as stalled.
+const defaultStallTimeout = 30 * time.Second
+
 type term interface {
 	Print(line string)
 	SetStatus(lines []string)
 }
 
+// ItemAction is the decision of the restorer for an item of the snapshot.
+type ItemAction string
+
+// Constants for the decisions reported for items
+const (
+	// ActionRestore restores an item which does not exist at the target.
+	ActionRestore ItemAction = "restore"
+	// ActionUpdate overwrites an existing item.
+	ActionUpdate ItemAction = "update"
+	// ActionUnchanged keeps an existing file with the expected content, only
+	// its metadata is restored.
+	ActionUnchanged ItemAction = "unchanged"
+	// ActionSkip keeps an existing item as the overwrite behavior does not
+	// allow replacing it.
+	ActionSkip ItemAction = "skip"
+	// ActionConflict reports an existing item as an error.
+	ActionConflict ItemAction = "conflict"
+)
+
 type ProgressPrinter interface {
 	Update(progress State, duration time.Duration)
 	Finish(progress State, duration time.Duration)
+	ReportItem(action ItemAction, item string, size uint64)
 }
 
 func NewProgress(printer ProgressPrinter, interval time.Duration) *Progress {
 	p := &Progress{
 		progressInfoMap: make(map[string]progressInfoEntry),
+		workers:         make(map[int]*workerInfo),
 		started:         time.Now(),
+		stallTimeout:    defaultStallTimeout,
 		printer:         printer,
 	}
 	p.updater = *progress.NewUpdater(interval, p.update)
@@ -57,14 +140,122 @@ func (p *Progress) update(runtime time.Duration, final bool) {
 	defer p.m.Unlock()
 
 	if !final {
-		p.printer.Update(p.s, runtime)
+		s := p.s
+		s.Workers = p.workerStates(time.Now())
+		p.printer.Update(s, runtime)
 	} else {
 		p.printer.Finish(p.s, runtime)
 	}
 }
 
-// AddFile starts tracking a new file with the given size
-func (p *Progress) AddFile(size uint64) {
+// State returns the current state of the restore without the worker states.
+func (p *Progress) State() State {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.s
+}
+
+// workerStates returns the state of all workers which are busy or have
+// encountered an error. p.m must be held by the caller.
+func (p *Progress) workerStates(now time.Time) []WorkerState {
+	if len(p.workers) == 0 {
+		return nil
+	}
+
+	states := make([]WorkerState, 0, len(p.workers))
+	for id, w := range p.workers {
+		if w.pack == "" && w.lastError == "" {
+			continue
+		}
+
+		state := WorkerState{
+			ID:        id,
+			Pack:      w.pack,
+			File:      w.file,
+			LastError: w.lastError,
+		}
+		if w.pack != "" {
+			state.BytesWritten = w.bytesWritten
+			if elapsed := now.Sub(w.start); elapsed > 0 {
+				state.BytesPerSecond = uint64(float64(w.bytesWritten) / elapsed.Seconds())
+			}
+			state.Idle = now.Sub(w.lastActivity)
+			state.Stalled = state.Idle >= p.stallTimeout
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].ID < states[j].ID
+	})
+	return states
+}
+
+// StartPack records that worker has started to download the pack with the
+// given short ID.
+func (p *Progress) StartPack(worker int, pack string) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	w, ok := p.workers[worker]
+	if !ok {
+		w = &workerInfo{}
+		p.workers[worker] = w
+	}
+	now := time.Now()
+	w.pack = pack
+	w.file = ""
+	w.bytesWritten = 0
+	w.failed = false
+	w.start = now
+	w.lastActivity = now
+	p.s.PacksInFlight++
+}
+
+// AddWorkerProgress records that worker has written bytes to the file with
+// the given name.
+func (p *Progress) AddWorkerProgress(worker int, name string, bytes uint64) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	w, ok := p.workers[worker]
+	if !ok {
+		return
+	}
+	w.file = name
+	w.bytesWritten += bytes
+	w.lastActivity = time.Now()
+}
+
+// WorkerError records an error encountered by worker. The error is shown
+// until the worker finishes a pack without errors.
+func (p *Progress) WorkerError(worker int, err error) {
+	if p == nil || err == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	w, ok := p.workers[worker]
+	if !ok {
+		w = &workerInfo{}
+		p.workers[worker] = w
+	}
+	w.lastError = err.Error()
+	w.failed = true
+}
+
+// FinishPack records that worker has finished the download of its current
+// pack. A non-nil err is recorded as the last error of the worker.
+func (p *Progress) FinishPack(worker int, err error) {
 	if p == nil {
 		return
 	}
@@ -72,7 +263,35 @@ func (p *Progress) AddFile(size uint64) {
 	p.m.Lock()
 	defer p.m.Unlock()
 
-	p.s.FilesTotal++
+	w, ok := p.workers[worker]
+	if !ok || w.pack == "" {
+		return
+	}
+	w.pack = ""
+	w.file = ""
+	w.bytesWritten = 0
+	if err != nil {
+		w.lastError = err.Error()
+	} else if !w.failed {
+		w.lastError = ""
+	}
+	p.s.PacksInFlight--
+}
+
+// AddFile starts tracking a new file with the given name and size
+func (p *Progress) AddFile(name string, size uint64) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if isStream(name) {
+		p.s.StreamsTotal++
+	} else {
+		p.s.FilesTotal++
+	}
 	p.s.AllBytesTotal += size
 }
 
@@ -95,11 +314,16 @@ func (p *Progress) AddProgress(name string, bytesWrittenPortion uint64, bytesTot
 	p.s.AllBytesWritten += bytesWrittenPortion
 	if entry.bytesWritten == entry.bytesTotal {
 		delete(p.progressInfoMap, name)
-		p.s.FilesFinished++
+		if isStream(name) {
+			p.s.StreamsFinished++
+		} else {
+			p.s.FilesFinished++
+		}
 	}
 }
 
-func (p *Progress) AddSkippedFile(size uint64) {
+// ReportItem reports the decision of the restorer for an item.
+func (p *Progress) ReportItem(action ItemAction, name string, size uint64) {
 	if p == nil {
 		return
 	}
@@ -107,7 +331,22 @@ func (p *Progress) AddSkippedFile(size uint64) {
 	p.m.Lock()
 	defer p.m.Unlock()
 
-	p.s.FilesSkipped++
+	p.printer.ReportItem(action, name, size)
+}
+
+func (p *Progress) AddSkippedFile(name string, size uint64) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if isStream(name) {
+		p.s.StreamsSkipped++
+	} else {
+		p.s.FilesSkipped++
+	}
 	p.s.AllBytesSkipped += size
 }
 
@@ -1,6 +1,7 @@
 package restore
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -28,6 +29,8 @@ func (p *mockPrinter) Update(progress State, duration time.Duration) {
 func (p *mockPrinter) Finish(progress State, _ time.Duration) {
 	p.trace = append(p.trace, printerTraceEntry{progress, mockFinishDuration, true})
 }
+func (p *mockPrinter) ReportItem(_ ItemAction, _ string, _ uint64) {
+}
 
 func testProgress(fn func(progress *Progress) bool) printerTrace {
 	printer := &mockPrinter{}
@@ -45,7 +48,7 @@ func TestNew(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{0, 0, 0, 0, 0, 0}, 0, false},
+		printerTraceEntry{State{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, nil}, 0, false},
 	}, result)
 }
 
@@ -53,11 +56,11 @@ func TestAddFile(t *testing.T) {
 	fileSize := uint64(100)
 
 	result := testProgress(func(progress *Progress) bool {
-		progress.AddFile(fileSize)
+		progress.AddFile("test", fileSize)
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{0, 1, 0, 0, fileSize, 0}, 0, false},
+		printerTraceEntry{State{0, 1, 0, 0, fileSize, 0, 0, 0, 0, 0, nil}, 0, false},
 	}, result)
 }
 
@@ -66,12 +69,12 @@ func TestFirstProgressOnAFile(t *testing.T) {
 	expectedBytesTotal := uint64(100)
 
 	result := testProgress(func(progress *Progress) bool {
-		progress.AddFile(expectedBytesTotal)
+		progress.AddFile("test", expectedBytesTotal)
 		progress.AddProgress("test", expectedBytesWritten, expectedBytesTotal)
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{0, 1, 0, expectedBytesWritten, expectedBytesTotal, 0}, 0, false},
+		printerTraceEntry{State{0, 1, 0, expectedBytesWritten, expectedBytesTotal, 0, 0, 0, 0, 0, nil}, 0, false},
 	}, result)
 }
 
@@ -79,14 +82,14 @@ func TestLastProgressOnAFile(t *testing.T) {
 	fileSize := uint64(100)
 
 	result := testProgress(func(progress *Progress) bool {
-		progress.AddFile(fileSize)
+		progress.AddFile("test", fileSize)
 		progress.AddProgress("test", 30, fileSize)
 		progress.AddProgress("test", 35, fileSize)
 		progress.AddProgress("test", 35, fileSize)
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{1, 1, 0, fileSize, fileSize, 0}, 0, false},
+		printerTraceEntry{State{1, 1, 0, fileSize, fileSize, 0, 0, 0, 0, 0, nil}, 0, false},
 	}, result)
 }
 
@@ -94,15 +97,15 @@ func TestLastProgressOnLastFile(t *testing.T) {
 	fileSize := uint64(100)
 
 	result := testProgress(func(progress *Progress) bool {
-		progress.AddFile(fileSize)
-		progress.AddFile(50)
+		progress.AddFile("test", fileSize)
+		progress.AddFile("test", 50)
 		progress.AddProgress("test1", 50, 50)
 		progress.AddProgress("test2", 50, fileSize)
 		progress.AddProgress("test2", 50, fileSize)
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{2, 2, 0, 50 + fileSize, 50 + fileSize, 0}, 0, false},
+		printerTraceEntry{State{2, 2, 0, 50 + fileSize, 50 + fileSize, 0, 0, 0, 0, 0, nil}, 0, false},
 	}, result)
 }
 
@@ -110,14 +113,14 @@ func TestSummaryOnSuccess(t *testing.T) {
 	fileSize := uint64(100)
 
 	result := testProgress(func(progress *Progress) bool {
-		progress.AddFile(fileSize)
-		progress.AddFile(50)
+		progress.AddFile("test", fileSize)
+		progress.AddFile("test", 50)
 		progress.AddProgress("test1", 50, 50)
 		progress.AddProgress("test2", fileSize, fileSize)
 		return true
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{2, 2, 0, 50 + fileSize, 50 + fileSize, 0}, mockFinishDuration, true},
+		printerTraceEntry{State{2, 2, 0, 50 + fileSize, 50 + fileSize, 0, 0, 0, 0, 0, nil}, mockFinishDuration, true},
 	}, result)
 }
 
@@ -125,14 +128,14 @@ func TestSummaryOnErrors(t *testing.T) {
 	fileSize := uint64(100)
 
 	result := testProgress(func(progress *Progress) bool {
-		progress.AddFile(fileSize)
-		progress.AddFile(50)
+		progress.AddFile("test", fileSize)
+		progress.AddFile("test", 50)
 		progress.AddProgress("test1", 50, 50)
 		progress.AddProgress("test2", fileSize/2, fileSize)
 		return true
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{1, 2, 0, 50 + fileSize/2, 50 + fileSize, 0}, mockFinishDuration, true},
+		printerTraceEntry{State{1, 2, 0, 50 + fileSize/2, 50 + fileSize, 0, 0, 0, 0, 0, nil}, mockFinishDuration, true},
 	}, result)
 }
 
@@ -140,10 +143,48 @@ func TestSkipFile(t *testing.T) {
 	fileSize := uint64(100)
 
 	result := testProgress(func(progress *Progress) bool {
-		progress.AddSkippedFile(fileSize)
+		progress.AddSkippedFile("test", fileSize)
 		return true
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{0, 0, 1, 0, 0, fileSize}, mockFinishDuration, true},
+		printerTraceEntry{State{0, 0, 1, 0, 0, fileSize, 0, 0, 0, 0, nil}, mockFinishDuration, true},
 	}, result)
 }
+
+func TestWorkerProgress(t *testing.T) {
+	printer := &mockPrinter{}
+	progress := NewProgress(printer, 0)
+	defer progress.Finish()
+
+	progress.StartPack(1, "11111111")
+	progress.AddWorkerProgress(1, "test", 10)
+	progress.StartPack(2, "22222222")
+	progress.FinishPack(2, errors.New("pack not found"))
+
+	now := time.Now()
+	workers := progress.workerStates(now)
+	test.Equals(t, 2, len(workers))
+	test.Equals(t, 1, workers[0].ID)
+	test.Equals(t, "11111111", workers[0].Pack)
+	test.Equals(t, "test", workers[0].File)
+	test.Equals(t, uint64(10), workers[0].BytesWritten)
+	test.Assert(t, !workers[0].Stalled, "worker 1 must not be stalled")
+	test.Equals(t, WorkerState{ID: 2, LastError: "pack not found"}, workers[1])
+	test.Equals(t, uint64(1), progress.s.PacksInFlight)
+
+	workers = progress.workerStates(now.Add(defaultStallTimeout))
+	test.Assert(t, workers[0].Stalled, "worker 1 must be stalled")
+
+	// errors are kept until a pack was restored without errors
+	progress.StartPack(2, "33333333")
+	progress.WorkerError(2, errors.New("blob not found"))
+	progress.FinishPack(2, nil)
+	workers = progress.workerStates(now)
+	test.Equals(t, "blob not found", workers[1].LastError)
+
+	progress.StartPack(2, "44444444")
+	progress.FinishPack(2, nil)
+	progress.FinishPack(1, nil)
+	test.Equals(t, 0, len(progress.workerStates(now)))
+	test.Equals(t, uint64(0), progress.s.PacksInFlight)
+}
@@ -8,12 +8,14 @@ import (
 )
 
 type textPrinter struct {
-	terminal term
+	terminal  term
+	verbosity uint
 }
 
-func NewTextProgress(terminal term) ProgressPrinter {
+func NewTextProgress(terminal term, verbosity uint) ProgressPrinter {
 	return &textPrinter{
-		terminal: terminal,
+		terminal:  terminal,
+		verbosity: verbosity,
 	}
 }
 
@@ -27,8 +29,36 @@ func (t *textPrinter) Update(p State, duration time.Duration) {
 	if p.FilesSkipped > 0 {
 		progress += fmt.Sprintf(", skipped %v files/dirs %v", p.FilesSkipped, ui.FormatBytes(p.AllBytesSkipped))
 	}
+	if p.PacksInFlight > 0 {
+		progress += fmt.Sprintf(", %d packs in flight", p.PacksInFlight)
+	}
 
-	t.terminal.SetStatus([]string{progress})
+	lines := make([]string, 0, len(p.Workers)+1)
+	lines = append(lines, progress)
+	for _, w := range p.Workers {
+		lines = append(lines, formatWorker(w))
+	}
+	t.terminal.SetStatus(lines)
+}
+
+// formatWorker returns a status line for a single worker.
+func formatWorker(w WorkerState) string {
+	if w.Pack == "" {
+		return fmt.Sprintf("  worker %d: idle, last error: %v", w.ID, w.LastError)
+	}
+
+	line := fmt.Sprintf("  worker %d: pack %v", w.ID, w.Pack)
+	if w.File != "" {
+		line += fmt.Sprintf(", %v", w.File)
+	}
+	line += fmt.Sprintf(", %s/s", ui.FormatBytes(w.BytesPerSecond))
+	if w.Stalled {
+		line += fmt.Sprintf(", stalled for %s", ui.FormatDuration(w.Idle))
+	}
+	if w.LastError != "" {
+		line += fmt.Sprintf(", last error: %v", w.LastError)
+	}
+	return line
 }
 
 func (t *textPrinter) Finish(p State, duration time.Duration) {
@@ -51,3 +81,15 @@ func (t *textPrinter) Finish(p State, duration time.Duration) {
 
 	t.terminal.Print(summary)
 }
+
+func (t *textPrinter) ReportItem(action ItemAction, item string, size uint64) {
+	if t.verbosity < 2 {
+		return
+	}
+
+	if size == 0 {
+		t.terminal.Print(fmt.Sprintf("%-9v %v", action, item))
+	} else {
+		t.terminal.Print(fmt.Sprintf("%-9v %v with size %v", action, item, ui.FormatBytes(size)))
+	}
+}
@@ -21,35 +21,62 @@ func (m *mockTerm) SetStatus(lines []string) {
 
 func TestPrintUpdate(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewTextProgress(term)
-	printer.Update(State{3, 11, 0, 29, 47, 0}, 5*time.Second)
+	printer := NewTextProgress(term, 1)
+	printer.Update(State{3, 11, 0, 29, 47, 0, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"[0:05] 61.70%  3 files/dirs 29 B, total 11 files/dirs 47 B"}, term.output)
 }
 
 func TestPrintUpdateWithSkipped(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewTextProgress(term)
-	printer.Update(State{3, 11, 2, 29, 47, 59}, 5*time.Second)
+	printer := NewTextProgress(term, 1)
+	printer.Update(State{3, 11, 2, 29, 47, 59, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"[0:05] 61.70%  3 files/dirs 29 B, total 11 files/dirs 47 B, skipped 2 files/dirs 59 B"}, term.output)
 }
 
 func TestPrintSummaryOnSuccess(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewTextProgress(term)
-	printer.Finish(State{11, 11, 0, 47, 47, 0}, 5*time.Second)
+	printer := NewTextProgress(term, 1)
+	printer.Finish(State{11, 11, 0, 47, 47, 0, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05"}, term.output)
 }
 
 func TestPrintSummaryOnErrors(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewTextProgress(term)
-	printer.Finish(State{3, 11, 0, 29, 47, 0}, 5*time.Second)
+	printer := NewTextProgress(term, 1)
+	printer.Finish(State{3, 11, 0, 29, 47, 0, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 3 / 11 files/dirs (29 B / 47 B) in 0:05"}, term.output)
 }
 
 func TestPrintSummaryOnSuccessWithSkipped(t *testing.T) {
 	term := &mockTerm{}
-	printer := NewTextProgress(term)
-	printer.Finish(State{11, 11, 2, 47, 47, 59}, 5*time.Second)
+	printer := NewTextProgress(term, 1)
+	printer.Finish(State{11, 11, 2, 47, 47, 59, 0, 0, 0, 0, nil}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05, skipped 2 files/dirs 59 B"}, term.output)
 }
+
+func TestPrintUpdateWithWorkers(t *testing.T) {
+	term := &mockTerm{}
+	printer := NewTextProgress(term, 1)
+	printer.Update(State{3, 11, 0, 29, 47, 0, 0, 0, 0, 2, []WorkerState{
+		{ID: 1, Pack: "11111111", File: "dir/file", BytesWritten: 2048, BytesPerSecond: 1024},
+		{ID: 2, Pack: "22222222", Stalled: true, Idle: 45 * time.Second, LastError: "timeout"},
+		{ID: 3, LastError: "pack not found"},
+	}}, 5*time.Second)
+	test.Equals(t, []string{
+		"[0:05] 61.70%  3 files/dirs 29 B, total 11 files/dirs 47 B, 2 packs in flight",
+		"  worker 1: pack 11111111, dir/file, 1.000 KiB/s",
+		"  worker 2: pack 22222222, 0 B/s, stalled for 0:45, last error: timeout",
+		"  worker 3: idle, last error: pack not found",
+	}, term.output)
+}
+
+func TestPrintReportItem(t *testing.T) {
+	term := &mockTerm{}
+	NewTextProgress(term, 1).ReportItem(ActionSkip, "/file", 42)
+	test.Equals(t, []string(nil), term.output)
+
+	printer := NewTextProgress(term, 2)
+	printer.ReportItem(ActionUnchanged, "/file", 42)
+	printer.ReportItem(ActionRestore, "/link", 0)
+	test.Equals(t, []string{"unchanged /file with size 42 B", "restore   /link"}, term.output)
+}
@@ -0,0 +1,244 @@
+package vfs
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Mem is an in-memory file system. Entries are added together with their
+// metadata, which is returned unchanged by NodeFromFileInfo. This allows
+// testing the archiver with metadata like extended attributes or Windows
+// security descriptors on any platform.
+type Mem struct {
+	slashPaths
+
+	m       sync.Mutex
+	entries map[string]*memEntry
+}
+
+type memEntry struct {
+	node     *restic.Node
+	content  []byte
+	children map[string]struct{}
+	streams  []string
+}
+
+// statically ensure that Mem implements FS.
+var _ FS = &Mem{}
+
+// NewMem returns an empty in-memory file system.
+func NewMem() *Mem {
+	return &Mem{
+		entries: map[string]*memEntry{
+			"/": {
+				node:     &restic.Node{Name: "/", Type: "dir", Mode: os.ModeDir | 0755},
+				children: make(map[string]struct{}),
+			},
+		},
+	}
+}
+
+// Add adds an entry with the metadata of node at the absolute path p. The
+// name and, for files, the size of node are set from p and content. Missing
+// parent directories are created with mode 0755.
+func (m *Mem) Add(p string, node restic.Node, content []byte) error {
+	if !path.IsAbs(p) {
+		return errors.Errorf("path %q is not absolute", p)
+	}
+	if node.Type == "" {
+		return errors.Errorf("missing type for %q", p)
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	p = path.Clean(p)
+	parent, err := m.mkdirAll(path.Dir(p))
+	if err != nil {
+		return err
+	}
+
+	node.Name = path.Base(p)
+	node.Mode = node.Mode&^os.ModeType | typeMode(node.Type)
+	entry := &memEntry{node: &node}
+	switch node.Type {
+	case "file":
+		entry.content = append([]byte(nil), content...)
+		node.Size = uint64(len(content))
+	case "dir":
+		entry.children = make(map[string]struct{})
+		if old, ok := m.entries[p]; ok && old.children != nil {
+			entry.children = old.children
+		}
+	}
+
+	m.entries[p] = entry
+	parent.children[node.Name] = struct{}{}
+	return nil
+}
+
+// AddStream adds the alternate data stream named stream with content to the
+// file at the absolute path p. The stream has the metadata of the file.
+func (m *Mem) AddStream(p, stream string, content []byte) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	p = path.Clean(p)
+	entry, ok := m.entries[p]
+	if !ok {
+		return pathError("addstream", p, syscall.ENOENT)
+	}
+	if entry.node.Type != "file" {
+		return errors.Errorf("%q is not a file", p)
+	}
+
+	node := *entry.node
+	node.Name += ":" + stream
+	node.Size = uint64(len(content))
+	node.ExtendedAttributes = nil
+	node.GenericAttributes = nil
+	m.entries[p+":"+stream] = &memEntry{node: &node, content: append([]byte(nil), content...)}
+	entry.streams = append(entry.streams, stream)
+	return nil
+}
+
+// mkdirAll returns the directory at p, which is created along with its parents
+// if it does not exist.
+func (m *Mem) mkdirAll(p string) (*memEntry, error) {
+	if entry, ok := m.entries[p]; ok {
+		if entry.node.Type != "dir" {
+			return nil, pathError("mkdir", p, syscall.ENOTDIR)
+		}
+		return entry, nil
+	}
+
+	parent, err := m.mkdirAll(path.Dir(p))
+	if err != nil {
+		return nil, err
+	}
+	entry := &memEntry{
+		node:     &restic.Node{Name: path.Base(p), Type: "dir", Mode: os.ModeDir | 0755},
+		children: make(map[string]struct{}),
+	}
+	m.entries[p] = entry
+	parent.children[entry.node.Name] = struct{}{}
+	return entry, nil
+}
+
+// lookup returns the entry at name, following symlinks if requested.
+func (m *Mem) lookup(op, name string, follow bool) (string, *memEntry, error) {
+	p := path.Clean("/" + name)
+	for i := 0; i < maxSymlinks; i++ {
+		entry, ok := m.entries[p]
+		if !ok {
+			return "", nil, pathError(op, name, syscall.ENOENT)
+		}
+		if !follow || entry.node.Type != "symlink" {
+			return p, entry, nil
+		}
+		p = resolveSymlink(p, entry.node)
+	}
+	return "", nil, pathError(op, name, syscall.ELOOP)
+}
+
+// Open opens the file or directory at name for reading.
+func (m *Mem) Open(name string) (fs.File, error) {
+	return m.OpenFile(name, fs.O_RDONLY, 0)
+}
+
+// OpenFile opens the file or directory at name for reading, no other flags than
+// O_RDONLY and O_NOFOLLOW are supported.
+func (m *Mem) OpenFile(name string, flag int, _ os.FileMode) (fs.File, error) {
+	if err := checkFlags(name, flag); err != nil {
+		return nil, err
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	p, entry, err := m.lookup("open", name, flag&fs.O_NOFOLLOW == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &file{name: name, fi: fileInfo{node: entry.node}}
+	switch entry.node.Type {
+	case "file":
+		f.r = bytes.NewReader(entry.content)
+	case "dir":
+		names := make([]string, 0, len(entry.children))
+		for name := range entry.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f.entries = append(f.entries, fileInfo{node: m.entries[path.Join(p, name)].node})
+		}
+	default:
+		return nil, pathError("open", name, syscall.ELOOP)
+	}
+	return f, nil
+}
+
+// Stat returns a FileInfo describing the entry at name, following symlinks.
+func (m *Mem) Stat(name string) (os.FileInfo, error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	_, entry, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{node: entry.node}, nil
+}
+
+// Lstat returns a FileInfo describing the entry at name.
+func (m *Mem) Lstat(name string) (os.FileInfo, error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	_, entry, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{node: entry.node}, nil
+}
+
+// ReadDir returns the entries of the directory at name.
+func (m *Mem) ReadDir(name string) ([]os.FileInfo, error) {
+	return readDir(m, name)
+}
+
+// Streams returns the streams which were added to the file at name.
+func (m *Mem) Streams(name string) ([]string, error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	_, entry, err := m.lookup("streams", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), entry.streams...), nil
+}
+
+// NodeFromFileInfo returns a copy of the node which was added at path.
+func (m *Mem) NodeFromFileInfo(path string, fi os.FileInfo, _ bool) (*restic.Node, error) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	_, entry, err := m.lookup("lstat", path, false)
+	if err != nil {
+		return &restic.Node{Name: fi.Name(), Path: path}, err
+	}
+	node := *entry.node
+	node.Path = path
+	return &node, nil
+}
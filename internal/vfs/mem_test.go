@@ -0,0 +1,88 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestMem(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := NewMem()
+	rtest.OK(t, m.Add("/dir/file", restic.Node{
+		Type:               "file",
+		Mode:               0640,
+		ModTime:            mtime,
+		ExtendedAttributes: []restic.ExtendedAttribute{{Name: "user.foo", Value: []byte("bar")}},
+	}, []byte("content")))
+	rtest.OK(t, m.Add("/dir/link", restic.Node{Type: "symlink", Mode: 0777, LinkTarget: "file"}, nil))
+	rtest.OK(t, m.Add("/dir/a", restic.Node{Type: "dir", Mode: 0700}, nil))
+
+	fi, err := m.Lstat("/dir/file")
+	rtest.OK(t, err)
+	rtest.Equals(t, "file", fi.Name())
+	rtest.Equals(t, int64(7), fi.Size())
+	rtest.Equals(t, os.FileMode(0640), fi.Mode())
+	rtest.Equals(t, mtime, fi.ModTime())
+
+	fi, err = m.Lstat("/dir/link")
+	rtest.OK(t, err)
+	rtest.Equals(t, os.ModeSymlink|0777, fi.Mode())
+	fi, err = m.Stat("/dir/link")
+	rtest.OK(t, err)
+	rtest.Equals(t, "file", fi.Name())
+
+	fi, err = m.Lstat("/dir")
+	rtest.OK(t, err)
+	rtest.Assert(t, fi.IsDir(), "parent directory was not created")
+
+	f, err := m.Open("/dir")
+	rtest.OK(t, err)
+	names, err := f.Readdirnames(-1)
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"a", "file", "link"}, names)
+	rtest.OK(t, f.Close())
+
+	rtest.OK(t, m.AddStream("/dir/file", "ads", []byte("stream")))
+	entries, err := m.ReadDir("/dir")
+	rtest.OK(t, err)
+	names = nil
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	rtest.Equals(t, []string{"a", "file", "link"}, names)
+	streams, err := m.Streams("/dir/file")
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"ads"}, streams)
+	fi, err = m.Lstat("/dir/file:ads")
+	rtest.OK(t, err)
+	rtest.Equals(t, "file:ads", fi.Name())
+	rtest.Equals(t, int64(6), fi.Size())
+
+	f, err = m.OpenFile("/dir/file", fs.O_RDONLY|fs.O_NOFOLLOW, 0)
+	rtest.OK(t, err)
+	buf, err := io.ReadAll(f)
+	rtest.OK(t, err)
+	rtest.Equals(t, "content", string(buf))
+	rtest.OK(t, f.Close())
+
+	_, err = m.OpenFile("/dir/link", fs.O_RDONLY|fs.O_NOFOLLOW, 0)
+	rtest.Assert(t, errors.Is(err, syscall.ELOOP), "unexpected error %v", err)
+	_, err = m.Lstat("/missing")
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "unexpected error %v", err)
+	rtest.Assert(t, m.Add("/dir/file/sub", restic.Node{Type: "file"}, nil) != nil, "missing error for file as parent")
+
+	fi, err = m.Lstat("/dir/file")
+	rtest.OK(t, err)
+	node, err := m.NodeFromFileInfo("/dir/file", fi, false)
+	rtest.OK(t, err)
+	rtest.Equals(t, "/dir/file", node.Path)
+	rtest.Equals(t, []byte("bar"), node.GetExtendedAttribute("user.foo"))
+}
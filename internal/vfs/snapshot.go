@@ -0,0 +1,277 @@
+package vfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Snapshot is a read-only file system which provides the tree of a snapshot
+// stored in a repository, including the metadata of all entries. Trees are
+// loaded on demand and cached.
+type Snapshot struct {
+	slashPaths
+
+	ctx  context.Context
+	repo restic.Loader
+	root *restic.Node
+
+	m     sync.Mutex
+	trees map[restic.ID]*restic.Tree
+}
+
+// statically ensure that Snapshot implements FS.
+var _ FS = &Snapshot{}
+
+// NewSnapshot returns a file system for the tree with the given id.
+func NewSnapshot(ctx context.Context, repo restic.Loader, tree restic.ID) *Snapshot {
+	return &Snapshot{
+		ctx:  ctx,
+		repo: repo,
+		root: &restic.Node{
+			Name:    "/",
+			Type:    "dir",
+			Mode:    os.ModeDir | 0755,
+			Subtree: &tree,
+		},
+		trees: make(map[restic.ID]*restic.Tree),
+	}
+}
+
+// loadTree returns the tree with the given id.
+func (s *Snapshot) loadTree(id restic.ID) (*restic.Tree, error) {
+	s.m.Lock()
+	tree, ok := s.trees[id]
+	s.m.Unlock()
+	if ok {
+		return tree, nil
+	}
+
+	tree, err := restic.LoadTree(s.ctx, s.repo, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.m.Lock()
+	s.trees[id] = tree
+	s.m.Unlock()
+	return tree, nil
+}
+
+// lookup returns the node at name, following symlinks if requested.
+func (s *Snapshot) lookup(op, name string, follow bool) (string, *restic.Node, error) {
+	p := path.Clean("/" + name)
+	for i := 0; i < maxSymlinks; i++ {
+		node := s.root
+		for _, elem := range strings.Split(p, "/") {
+			if elem == "" {
+				continue
+			}
+			if node.Type != "dir" || node.Subtree == nil {
+				return "", nil, pathError(op, name, syscall.ENOTDIR)
+			}
+			tree, err := s.loadTree(*node.Subtree)
+			if err != nil {
+				return "", nil, pathError(op, name, err)
+			}
+			node = tree.Find(elem)
+			if node == nil {
+				return "", nil, pathError(op, name, syscall.ENOENT)
+			}
+		}
+
+		if !follow || node.Type != "symlink" {
+			return p, node, nil
+		}
+		p = resolveSymlink(p, node)
+	}
+	return "", nil, pathError(op, name, syscall.ELOOP)
+}
+
+// Open opens the file or directory at name for reading.
+func (s *Snapshot) Open(name string) (fs.File, error) {
+	return s.OpenFile(name, fs.O_RDONLY, 0)
+}
+
+// OpenFile opens the file or directory at name for reading, no other flags than
+// O_RDONLY and O_NOFOLLOW are supported.
+func (s *Snapshot) OpenFile(name string, flag int, _ os.FileMode) (fs.File, error) {
+	if err := checkFlags(name, flag); err != nil {
+		return nil, err
+	}
+
+	_, node, err := s.lookup("open", name, flag&fs.O_NOFOLLOW == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &file{name: name, fi: fileInfo{node: node}}
+	switch node.Type {
+	case "file":
+		r, err := newBlobReader(s.ctx, s.repo, node.Content)
+		if err != nil {
+			return nil, pathError("open", name, err)
+		}
+		f.r = r
+	case "dir":
+		tree, err := s.loadTree(*node.Subtree)
+		if err != nil {
+			return nil, pathError("open", name, err)
+		}
+		for _, child := range tree.Nodes {
+			if restic.ClassifyNode(child.Name) == restic.StreamNode {
+				// streams are returned by Streams
+				continue
+			}
+			f.entries = append(f.entries, fileInfo{node: child})
+		}
+	default:
+		return nil, pathError("open", name, syscall.ELOOP)
+	}
+	return f, nil
+}
+
+// Stat returns a FileInfo describing the entry at name, following symlinks.
+func (s *Snapshot) Stat(name string) (os.FileInfo, error) {
+	_, node, err := s.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{node: node}, nil
+}
+
+// Lstat returns a FileInfo describing the entry at name.
+func (s *Snapshot) Lstat(name string) (os.FileInfo, error) {
+	_, node, err := s.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{node: node}, nil
+}
+
+// ReadDir returns the entries of the directory at name.
+func (s *Snapshot) ReadDir(name string) ([]os.FileInfo, error) {
+	return readDir(s, name)
+}
+
+// Streams returns the streams of the file at name, which are stored as nodes
+// named "file:stream" next to the node of the file.
+func (s *Snapshot) Streams(name string) ([]string, error) {
+	p, node, err := s.lookup("streams", name, false)
+	if err != nil {
+		return nil, err
+	}
+	if node.Type != "file" {
+		return nil, nil
+	}
+
+	_, dir, err := s.lookup("streams", path.Dir(p), false)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := s.loadTree(*dir.Subtree)
+	if err != nil {
+		return nil, pathError("streams", name, err)
+	}
+
+	var streams []string
+	prefix := node.Name + ":"
+	for _, child := range tree.Nodes {
+		if strings.HasPrefix(child.Name, prefix) && restic.ClassifyNode(child.Name) == restic.StreamNode {
+			streams = append(streams, strings.TrimPrefix(child.Name, prefix))
+		}
+	}
+	return streams, nil
+}
+
+// NodeFromFileInfo returns a copy of the node stored in the snapshot at path.
+func (s *Snapshot) NodeFromFileInfo(path string, fi os.FileInfo, _ bool) (*restic.Node, error) {
+	_, node, err := s.lookup("lstat", path, false)
+	if err != nil {
+		return &restic.Node{Name: fi.Name(), Path: path}, err
+	}
+	n := *node
+	n.Path = path
+	return &n, nil
+}
+
+// blobReader reads the content of a file from its data blobs.
+type blobReader struct {
+	ctx   context.Context
+	repo  restic.Loader
+	blobs restic.IDs
+	// offsets contains the start offset of each blob and the file size
+	offsets []int64
+	pos     int64
+
+	cur    int
+	curBuf []byte
+}
+
+func newBlobReader(ctx context.Context, repo restic.Loader, blobs restic.IDs) (*blobReader, error) {
+	offsets := make([]int64, 0, len(blobs)+1)
+	var offset int64
+	for _, id := range blobs {
+		offsets = append(offsets, offset)
+		size, ok := repo.LookupBlobSize(restic.DataBlob, id)
+		if !ok {
+			return nil, errors.Errorf("data blob %v not found", id.Str())
+		}
+		offset += int64(size)
+	}
+	offsets = append(offsets, offset)
+
+	return &blobReader{ctx: ctx, repo: repo, blobs: blobs, offsets: offsets, cur: -1}, nil
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	size := r.offsets[len(r.offsets)-1]
+	if r.pos >= size {
+		return 0, io.EOF
+	}
+
+	// find the blob which contains pos
+	i := r.cur
+	if i < 0 || r.pos < r.offsets[i] || r.pos >= r.offsets[i+1] {
+		i = 0
+		for r.pos >= r.offsets[i+1] {
+			i++
+		}
+	}
+	if i != r.cur {
+		buf, err := r.repo.LoadBlob(r.ctx, restic.DataBlob, r.blobs[i], r.curBuf)
+		if err != nil {
+			return 0, err
+		}
+		r.cur, r.curBuf = i, buf
+	}
+
+	n := copy(p, r.curBuf[r.pos-r.offsets[i]:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *blobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.offsets[len(r.offsets)-1]
+	default:
+		return 0, errors.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
@@ -0,0 +1,76 @@
+package vfs_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/vfs"
+)
+
+func snapshot(t *testing.T, repo restic.Repository, filesystem vfs.FS) *restic.Snapshot {
+	arch := archiver.New(repo, filesystem, archiver.Options{})
+	sn, _, _, err := arch.Snapshot(context.TODO(), []string{"/data"}, archiver.SnapshotOptions{
+		Hostname: "host",
+		Time:     time.Now(),
+	})
+	rtest.OK(t, err)
+	return sn
+}
+
+func TestSnapshotRoundtrip(t *testing.T) {
+	repo := repository.TestRepository(t)
+
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mem := vfs.NewMem()
+	rtest.OK(t, mem.Add("/data/dir", restic.Node{Type: "dir", Mode: 0750, ModTime: mtime, AccessTime: mtime}, nil))
+	rtest.OK(t, mem.Add("/data/dir/file", restic.Node{
+		Type:               "file",
+		Mode:               0600,
+		ModTime:            mtime,
+		AccessTime:         mtime,
+		UID:                1000,
+		ExtendedAttributes: []restic.ExtendedAttribute{{Name: "user.foo", Value: []byte("bar")}},
+		GenericAttributes: map[restic.GenericAttributeType]json.RawMessage{
+			restic.TypeFileAttributes: json.RawMessage("32"),
+		},
+	}, []byte("some file content")))
+	rtest.OK(t, mem.AddStream("/data/dir/file", "ads", []byte("stream content")))
+	rtest.OK(t, mem.Add("/data/link", restic.Node{Type: "symlink", Mode: 0777, ModTime: mtime, AccessTime: mtime, LinkTarget: "dir/file"}, nil))
+
+	sn := snapshot(t, repo, mem)
+
+	snfs := vfs.NewSnapshot(context.TODO(), repo, *sn.Tree)
+	fi, err := snfs.Lstat("/data/dir/file")
+	rtest.OK(t, err)
+	node, err := snfs.NodeFromFileInfo("/data/dir/file", fi, false)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint32(1000), node.UID)
+	rtest.Equals(t, []byte("bar"), node.GetExtendedAttribute("user.foo"))
+	rtest.Equals(t, json.RawMessage("32"), node.GenericAttributes[restic.TypeFileAttributes])
+
+	// streams are saved as nodes next to their file
+	f, err := snfs.Open("/data/dir/file:ads")
+	rtest.OK(t, err)
+	buf, err := io.ReadAll(f)
+	rtest.OK(t, err)
+	rtest.Equals(t, "stream content", string(buf))
+	rtest.OK(t, f.Close())
+
+	f, err = snfs.Open("/data/link")
+	rtest.OK(t, err)
+	buf, err = io.ReadAll(f)
+	rtest.OK(t, err)
+	rtest.Equals(t, "some file content", string(buf))
+	rtest.OK(t, f.Close())
+
+	// backing up the snapshot again results in the same tree
+	sn2 := snapshot(t, repo, snfs)
+	rtest.Equals(t, *sn.Tree, *sn2.Tree)
+}
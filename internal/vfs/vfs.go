@@ -0,0 +1,281 @@
+// Package vfs provides file systems for the archiver which supply the complete
+// metadata of their entries as nodes, including extended attributes and
+// generic attributes like alternate data streams. This allows backing up data
+// which does not reside on the local file system and testing the archiver
+// with metadata the local file system does not support.
+package vfs
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+// FS is a file system which provides the metadata of its entries itself
+// instead of the archiver reading it from the local file system. The archiver
+// reads all entries through this interface.
+type FS interface {
+	fs.FS
+
+	// ReadDir returns the entries of the directory at name as returned by
+	// Lstat, sorted by name. Alternate data streams are not included.
+	ReadDir(name string) ([]os.FileInfo, error)
+
+	// Streams returns the names of the alternate data streams of the file at
+	// name. The stream s can be accessed at the path name + ":" + s.
+	Streams(name string) ([]string, error)
+
+	// NodeFromFileInfo returns the node for the entry at path, for which fi
+	// was returned by Lstat, including its extended and generic attributes.
+	// Like restic.NodeFromFileInfo, it returns a node together with the first
+	// error that is encountered.
+	NodeFromFileInfo(path string, fi os.FileInfo, ignoreXattrListError bool) (*restic.Node, error)
+}
+
+// Wrap returns filesystem as an FS. Unless filesystem implements FS itself,
+// the metadata of its entries is read from the local file system.
+func Wrap(filesystem fs.FS) FS {
+	if vfsys, ok := filesystem.(FS); ok {
+		return vfsys
+	}
+	return Local{FS: filesystem}
+}
+
+// Local is a file system whose metadata is read from the local file system by
+// restic.NodeFromFileInfo. For an fs.Remap, the metadata is read from the
+// real location of an entry.
+type Local struct {
+	fs.FS
+}
+
+// statically ensure that Local implements FS.
+var _ FS = Local{}
+
+// ReadDir returns the entries of the directory at name.
+func (l Local) ReadDir(name string) ([]os.FileInfo, error) {
+	return readDir(l.FS, name)
+}
+
+// Streams returns no streams, alternate data streams are not read from the
+// local file system.
+func (Local) Streams(_ string) ([]string, error) {
+	return nil, nil
+}
+
+// NodeFromFileInfo reads the metadata of the file at path.
+func (l Local) NodeFromFileInfo(path string, fi os.FileInfo, ignoreXattrListError bool) (*restic.Node, error) {
+	return restic.NodeFromFileInfo(l.RealPath(path), fi, ignoreXattrListError)
+}
+
+// RealPath returns the path of the entry at name on the local file system.
+func (l Local) RealPath(name string) string {
+	if remap, ok := l.FS.(*fs.Remap); ok {
+		return remap.RealPath(name)
+	}
+	return name
+}
+
+// readDir returns the entries of the directory at name in filesystem, sorted
+// by name.
+func readDir(filesystem fs.FS, name string) ([]os.FileInfo, error) {
+	f, err := filesystem.OpenFile(name, fs.O_RDONLY|fs.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	entries, err := f.Readdir(-1)
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(err, "Readdir %v failed", name)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// slashPaths implements the path handling of the FS interface for file
+// systems which use slash-separated absolute paths on all platforms.
+type slashPaths struct{}
+
+func (slashPaths) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (slashPaths) Separator() string {
+	return "/"
+}
+
+func (slashPaths) Abs(p string) (string, error) {
+	return path.Clean("/" + p), nil
+}
+
+func (slashPaths) Clean(p string) string {
+	return path.Clean(p)
+}
+
+func (slashPaths) VolumeName(_ string) string {
+	return ""
+}
+
+func (slashPaths) IsAbs(p string) bool {
+	return path.IsAbs(p)
+}
+
+func (slashPaths) Dir(p string) string {
+	return path.Dir(p)
+}
+
+func (slashPaths) Base(p string) string {
+	return path.Base(p)
+}
+
+// typeMode returns the file mode type bits for a node type.
+func typeMode(nodeType string) os.FileMode {
+	switch nodeType {
+	case "dir":
+		return os.ModeDir
+	case "symlink":
+		return os.ModeSymlink
+	case "dev":
+		return os.ModeDevice
+	case "chardev":
+		return os.ModeDevice | os.ModeCharDevice
+	case "fifo":
+		return os.ModeNamedPipe
+	case "socket":
+		return os.ModeSocket
+	case "irregular":
+		return os.ModeIrregular
+	}
+	return 0
+}
+
+// fileInfo describes the entry for a node. Sys returns the node.
+type fileInfo struct {
+	node *restic.Node
+}
+
+func (fi fileInfo) Name() string {
+	return fi.node.Name
+}
+
+func (fi fileInfo) Size() int64 {
+	return int64(fi.node.Size)
+}
+
+func (fi fileInfo) Mode() os.FileMode {
+	return fi.node.Mode&^os.ModeType | typeMode(fi.node.Type)
+}
+
+func (fi fileInfo) ModTime() time.Time {
+	return fi.node.ModTime
+}
+
+func (fi fileInfo) IsDir() bool {
+	return fi.node.Type == "dir"
+}
+
+func (fi fileInfo) Sys() interface{} {
+	return fi.node
+}
+
+// file is an open entry of a virtual file system. The content of files is
+// read from r, directories list their entries.
+type file struct {
+	name    string
+	fi      fileInfo
+	r       io.ReadSeeker
+	entries []os.FileInfo
+}
+
+// statically ensure that file implements fs.File.
+var _ fs.File = &file{}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.r == nil {
+		return 0, pathError("read", f.name, syscall.EISDIR)
+	}
+	return f.r.Read(p)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.r == nil {
+		return 0, pathError("seek", f.name, syscall.EISDIR)
+	}
+	return f.r.Seek(offset, whence)
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+func (f *file) Fd() uintptr {
+	return 0
+}
+
+func (f *file) Readdir(n int) ([]os.FileInfo, error) {
+	if !f.fi.IsDir() {
+		return nil, pathError("readdir", f.name, syscall.ENOTDIR)
+	}
+	if n > 0 {
+		return nil, pathError("readdir", f.name, errors.New("not implemented"))
+	}
+	return f.entries, nil
+}
+
+func (f *file) Readdirnames(n int) ([]string, error) {
+	entries, err := f.Readdir(n)
+	if err != nil {
+		return nil, pathError("readdirnames", f.name, errors.Unwrap(err))
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return f.fi, nil
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func pathError(op, name string, err error) *os.PathError {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// checkFlags returns an error if flag requests anything else than reading.
+func checkFlags(name string, flag int) error {
+	if flag&^(fs.O_RDONLY|fs.O_NOFOLLOW) != 0 {
+		return pathError("open", name, errors.Errorf("invalid combination of flags 0x%x", flag))
+	}
+	return nil
+}
+
+// maxSymlinks is the number of symlinks which are followed before giving up.
+const maxSymlinks = 40
+
+// resolveSymlink returns the path of the target of the symlink at name.
+func resolveSymlink(name string, node *restic.Node) string {
+	if path.IsAbs(node.LinkTarget) {
+		return path.Clean(node.LinkTarget)
+	}
+	return path.Join(path.Dir(name), node.LinkTarget)
+}